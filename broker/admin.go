@@ -0,0 +1,106 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+)
+
+const (
+	// DefaultAdminTopic is the topic system events & statistics are broadcast to when the broker
+	// is configured using the WithAdminStats method without an admin topic of its own.
+	DefaultAdminTopic = "__admin"
+
+	// The type of the events containing the broker's statistics broadcast to the admin topic.
+	adminStatsEvent = systemEventPrefix + "stats"
+)
+
+var (
+	// ErrAdminTopic is the error returned when a client requests to subscribe to the admin topic
+	// without being allowed to, see the broker.WithAdminAuthorizer method.
+	ErrAdminTopic = errors.New("subscribing to the admin topic is not allowed")
+)
+
+// WithAdminStats configures the broker to broadcast a snapshot of its statistics to the admin topic
+// once per interval, so that dashboards can subscribe to it like any other client rather than polling
+// the broker. Each event's type is 'sse:stats' and its data is the result of the Stats method encoded
+// as JSON. If no admin topic is configured using the WithAdminTopic method, the topic is '__admin',
+// which also receives the broker's system events. Like system events, the statistics are written
+// directly to the topic's subscribers, so they are not stored or forwarded to a collector. Clients
+// can only subscribe to the admin topic over HTTP if allowed, see the broker.WithAdminAuthorizer
+// method. If 'interval' is zero, statistics are not broadcast.
+func WithAdminStats(interval time.Duration) Option {
+	return func(b *defaultBroker) {
+		b.adminStats = interval
+	}
+}
+
+// broadcastStats starts broadcasting the broker's statistics to the admin topic, if configured to.
+func (b *defaultBroker) broadcastStats() {
+	if b.adminStats <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := b.clock.NewTicker(b.adminStats)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				data, err := json.Marshal(b.Stats())
+				if err != nil {
+					continue
+				}
+
+				b.sendAdmin(event.Event{Type: adminStatsEvent, Topic: b.adminTopic, Data: data, Timestamp: b.clock.Now()})
+			case <-b.closed:
+				return
+			}
+		}
+	}()
+}
+
+// WithAdminAuthorizer configures a function that authorizes requests to subscribe to the admin topic,
+// which carries the broker's statistics & system events, including the ids of its clients. Requests
+// to the client, subscription & history handlers for the admin topic that 'fn' does not allow are
+// rejected with a 403 status code & the CodeTopicForbidden error code. If 'fn' is nil, clients can
+// only be subscribed to the admin topic using the Subscribe method.
+func WithAdminAuthorizer(fn Authorizer) Option {
+	return func(b *defaultBroker) {
+		b.adminAuthorizer = fn
+	}
+}
+
+// checkAdmin returns an error wrapping ErrAdminTopic if the topics include the admin topic & the
+// request is not allowed to subscribe to it.
+func (b *defaultBroker) checkAdmin(r *http.Request, topics []string) error {
+	if b.adminTopic == "" || !contains(topics, b.adminTopic) {
+		return nil
+	}
+
+	if b.adminAuthorizer == nil {
+		return ErrAdminTopic
+	}
+
+	if err := b.adminAuthorizer(r); err != nil {
+		return fmt.Errorf("%w: %v", ErrAdminTopic, err)
+	}
+
+	return nil
+}
+
+// subscriptionError responds with an error returned when determining the topics the request
+// subscribes to.
+func (b *defaultBroker) subscriptionError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrAdminTopic) {
+		b.httpError(w, r, CodeTopicForbidden, err, http.StatusForbidden)
+		return
+	}
+
+	b.httpError(w, r, CodeInvalidSubscription, err, http.StatusBadRequest)
+}
@@ -0,0 +1,165 @@
+package broker_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithAdminStats(t *testing.T) {
+	tt := []struct {
+		Name          string
+		Options       []broker.Option
+		ExpectedTopic string
+	}{
+		{
+			Name:          "It should broadcast statistics to the default admin topic",
+			ExpectedTopic: broker.DefaultAdminTopic,
+		},
+		{
+			Name:          "It should broadcast statistics to the configured admin topic",
+			Options:       []broker.Option{broker.WithAdminTopic("ops")},
+			ExpectedTopic: "ops",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := append(tc.Options, broker.WithAdminStats(time.Millisecond*50))
+
+			brk := broker.New(time.Second, 3, nil, opts...)
+			defer brk.Close()
+
+			c := ssetest.NewClient("dashboard", client.WithQueueSize(10), client.WithTopics(tc.ExpectedTopic))
+			defer c.Close()
+
+			assert.NoError(t, brk.Subscribe(c.Client))
+
+			var stats *event.Event
+
+			for deadline := time.Now().Add(time.Second); stats == nil && time.Now().Before(deadline); {
+				for _, e := range c.Events() {
+					if e.Type == "sse:stats" {
+						e := e
+						stats = &e
+					}
+				}
+
+				time.Sleep(time.Millisecond * 10)
+			}
+
+			if !assert.NotNil(t, stats, "statistics were not broadcast") {
+				return
+			}
+
+			assert.Equal(t, tc.ExpectedTopic, stats.Topic)
+
+			var snapshot broker.Stats
+
+			if assert.NoError(t, json.Unmarshal(stats.Data, &snapshot)) {
+				assert.Equal(t, 1, snapshot.Clients)
+				assert.Equal(t, 1, snapshot.Topics[tc.ExpectedTopic].Subscribers)
+			}
+		})
+	}
+}
+
+func TestBroker_WithAdminAuthorizer(t *testing.T) {
+	allowOps := func(r *http.Request) error {
+		if r.Header.Get("Authorization") != "Bearer ops" {
+			return errors.New("not an operator")
+		}
+
+		return nil
+	}
+
+	tt := []struct {
+		Name            string
+		Authorizer      broker.Authorizer
+		Topics          string
+		Token           string
+		ExpectRefused   bool
+		ExpectedClients int
+	}{
+		{
+			Name:          "It should refuse admin subscriptions without an admin authorizer",
+			Topics:        broker.DefaultAdminTopic,
+			Token:         "ops",
+			ExpectRefused: true,
+		},
+		{
+			Name:          "It should refuse admin subscriptions the admin authorizer does not allow",
+			Authorizer:    allowOps,
+			Topics:        "news," + broker.DefaultAdminTopic,
+			Token:         "user",
+			ExpectRefused: true,
+		},
+		{
+			Name:            "It should accept admin subscriptions the admin authorizer allows",
+			Authorizer:      allowOps,
+			Topics:          broker.DefaultAdminTopic,
+			Token:           "ops",
+			ExpectedClients: 1,
+		},
+		{
+			Name:            "It should accept other subscriptions without an admin authorizer",
+			Topics:          "news",
+			ExpectedClients: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil,
+				broker.WithAdminStats(time.Hour),
+				broker.WithAdminAuthorizer(tc.Authorizer))
+			defer brk.Close()
+
+			w := ssetest.NewStreamRecorder()
+			r := w.NewRequest(http.MethodGet, "/connect?id=dashboard&topics="+tc.Topics, nil)
+			r.Header.Set("Authorization", "Bearer "+tc.Token)
+			done := make(chan struct{})
+
+			go func() {
+				brk.ClientHandler(w, r)
+				close(done)
+			}()
+
+			<-time.After(time.Millisecond * 100)
+
+			assert.Equal(t, tc.ExpectedClients, brk.Stats().Clients)
+
+			w.Close()
+			<-done
+
+			if tc.ExpectRefused {
+				assert.Equal(t, http.StatusForbidden, w.Code())
+			}
+		})
+	}
+}
+
+func TestBroker_WithAdminAuthorizerSubscriptions(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithAdminStats(time.Hour))
+	defer brk.Close()
+
+	c := client.NewWithOptions(time.Second, 3, "test", client.WithTopics("news"))
+	assert.NoError(t, brk.Subscribe(c))
+
+	w := httptest.NewRecorder()
+	body := strings.NewReader(`{"subscribe": ["` + broker.DefaultAdminTopic + `"]}`)
+	brk.SubscriptionHandler(w, httptest.NewRequest(http.MethodPost, "/subscriptions?id=test", body))
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, []string{"news"}, c.Topics())
+}
@@ -0,0 +1,258 @@
+package broker
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Selector type is a set of requirements over the metadata of a client, used to describe
+	// the audience of an event. A client matches the selector if it meets every requirement.
+	// See the broker.ParseSelector function for the supported syntax.
+	Selector []Requirement
+
+	// The Requirement type is a single condition on a key of a client's metadata.
+	Requirement struct {
+		Key      string   // The metadata key the requirement applies to.
+		Operator Operator // How the metadata value is compared.
+		Value    string   // The value compared against, if the operator uses one.
+	}
+
+	// Operator determines how a requirement compares a client's metadata.
+	Operator int
+
+	// The metadataIndex type indexes connected clients by each key & value of their metadata, so
+	// that the audience of an event can be found without checking every client.
+	metadataIndex struct {
+		mux     sync.RWMutex
+		entries map[string]map[string]map[*client.Client]struct{}
+	}
+)
+
+const (
+	// OpEquals requires the metadata value to equal the requirement's value.
+	OpEquals Operator = iota
+
+	// OpNotEquals requires the metadata value to differ from the requirement's value, or the key
+	// to be missing.
+	OpNotEquals
+
+	// OpExists requires the key to be present in the metadata.
+	OpExists
+
+	// OpNotExists requires the key to be missing from the metadata.
+	OpNotExists
+)
+
+// ParseSelector converts the given text into a Selector. The text is a comma separated list of
+// requirements, each of which is one of 'key=value', 'key==value', 'key!=value', 'key' to require
+// that the key exists, or '!key' to require that it does not. For example, 'role=admin,region=eu'
+// matches clients whose metadata has the 'admin' role & the 'eu' region. Blank text matches every
+// client.
+func ParseSelector(text string) (Selector, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	var out Selector
+
+	for _, part := range strings.Split(text, ",") {
+		part = strings.TrimSpace(part)
+
+		var req Requirement
+
+		switch {
+		case strings.Contains(part, "!="):
+			kv := strings.SplitN(part, "!=", 2)
+			req = Requirement{Key: kv[0], Operator: OpNotEquals, Value: kv[1]}
+		case strings.Contains(part, "=="):
+			kv := strings.SplitN(part, "==", 2)
+			req = Requirement{Key: kv[0], Operator: OpEquals, Value: kv[1]}
+		case strings.Contains(part, "="):
+			kv := strings.SplitN(part, "=", 2)
+			req = Requirement{Key: kv[0], Operator: OpEquals, Value: kv[1]}
+		case strings.HasPrefix(part, "!"):
+			req = Requirement{Key: part[1:], Operator: OpNotExists}
+		default:
+			req = Requirement{Key: part, Operator: OpExists}
+		}
+
+		req.Key = strings.TrimSpace(req.Key)
+		req.Value = strings.TrimSpace(req.Value)
+
+		if req.Key == "" || strings.ContainsAny(req.Key, "=!") {
+			return nil, fmt.Errorf("invalid selector requirement %q", part)
+		}
+
+		out = append(out, req)
+	}
+
+	return out, nil
+}
+
+// Matches determines if the metadata meets every requirement of the selector.
+func (s Selector) Matches(metadata map[string]string) bool {
+	for _, req := range s {
+		if !req.Matches(metadata) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Matches determines if the metadata meets the requirement.
+func (r Requirement) Matches(metadata map[string]string) bool {
+	value, ok := metadata[r.Key]
+
+	switch r.Operator {
+	case OpEquals:
+		return ok && value == r.Value
+	case OpNotEquals:
+		return !ok || value != r.Value
+	case OpExists:
+		return ok
+	case OpNotExists:
+		return !ok
+	default:
+		return false
+	}
+}
+
+// audience returns a group containing the connected clients that match the event's audience
+// selector & are subscribed to its topic.
+func (b *defaultBroker) audience(e event.Event) (*fanout, error) {
+	selector, err := ParseSelector(e.Audience)
+
+	if err != nil {
+		return nil, err
+	}
+
+	group := newFanout(b.shards, b.clock)
+	add := func(c *client.Client) {
+		if e.Matches(c.Topics()) && selector.Matches(c.Metadata()) {
+			group.add(c)
+		}
+	}
+
+	// If the selector requires specific values, only the clients that have them need to
+	// be checked.
+	if candidates, ok := b.index.candidates(selector); ok {
+		for _, c := range candidates {
+			if b.connected(c) {
+				add(c)
+			}
+		}
+
+		return group, nil
+	}
+
+	b.clients.Range(func(key, value interface{}) bool {
+		if c, ok := value.(*client.Client); ok {
+			add(c)
+		}
+
+		return true
+	})
+
+	return group, nil
+}
+
+// inAudience determines if the client is part of the event's audience. Events without an audience
+// are delivered to every client.
+func inAudience(e event.Event, c *client.Client) bool {
+	if e.Audience == "" {
+		return true
+	}
+
+	selector, err := ParseSelector(e.Audience)
+
+	return err == nil && selector.Matches(c.Metadata())
+}
+
+// add indexes the client by its metadata.
+func (idx *metadataIndex) add(c *client.Client) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	if idx.entries == nil {
+		idx.entries = make(map[string]map[string]map[*client.Client]struct{})
+	}
+
+	for key, value := range c.Metadata() {
+		values, ok := idx.entries[key]
+
+		if !ok {
+			values = make(map[string]map[*client.Client]struct{})
+			idx.entries[key] = values
+		}
+
+		clients, ok := values[value]
+
+		if !ok {
+			clients = make(map[*client.Client]struct{})
+			values[value] = clients
+		}
+
+		clients[c] = struct{}{}
+	}
+}
+
+// remove removes the client from the index, discarding any keys & values no other client has.
+func (idx *metadataIndex) remove(c *client.Client) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	for key, value := range c.Metadata() {
+		clients := idx.entries[key][value]
+		delete(clients, c)
+
+		if len(clients) == 0 {
+			delete(idx.entries[key], value)
+		}
+
+		if len(idx.entries[key]) == 0 {
+			delete(idx.entries, key)
+		}
+	}
+}
+
+// candidates returns the clients that have the value of the selector's most selective equality
+// requirement. If the selector has no equality requirements, false is returned & every client
+// must be checked.
+func (idx *metadataIndex) candidates(s Selector) ([]*client.Client, bool) {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	var smallest map[*client.Client]struct{}
+	found := false
+
+	for _, req := range s {
+		if req.Operator != OpEquals {
+			continue
+		}
+
+		clients := idx.entries[req.Key][req.Value]
+
+		if !found || len(clients) < len(smallest) {
+			smallest = clients
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, false
+	}
+
+	out := make([]*client.Client, 0, len(smallest))
+
+	for c := range smallest {
+		out = append(out, c)
+	}
+
+	return out, true
+}
@@ -0,0 +1,95 @@
+package broker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSelector(t *testing.T) {
+	tt := []struct {
+		Selector         string
+		Metadata         map[string]string
+		ExpectedMatch    bool
+		ExpectedErrorMsg string
+	}{
+		{Selector: "", Metadata: nil, ExpectedMatch: true},
+		{Selector: "role=admin", Metadata: map[string]string{"role": "admin"}, ExpectedMatch: true},
+		{Selector: "role==admin", Metadata: map[string]string{"role": "user"}, ExpectedMatch: false},
+		{Selector: "role=admin, region=eu", Metadata: map[string]string{"role": "admin", "region": "eu"}, ExpectedMatch: true},
+		{Selector: "role=admin,region=eu", Metadata: map[string]string{"role": "admin", "region": "us"}, ExpectedMatch: false},
+		{Selector: "region!=eu", Metadata: map[string]string{"region": "us"}, ExpectedMatch: true},
+		{Selector: "region!=eu", Metadata: nil, ExpectedMatch: true},
+		{Selector: "beta", Metadata: map[string]string{"beta": ""}, ExpectedMatch: true},
+		{Selector: "!beta", Metadata: map[string]string{"beta": ""}, ExpectedMatch: false},
+		{Selector: "role=admin,,region=eu", ExpectedErrorMsg: `invalid selector requirement ""`},
+		{Selector: "=admin", ExpectedErrorMsg: `invalid selector requirement "=admin"`},
+	}
+
+	for _, tc := range tt {
+		selector, err := broker.ParseSelector(tc.Selector)
+
+		if tc.ExpectedErrorMsg != "" {
+			assert.EqualError(t, err, tc.ExpectedErrorMsg)
+			continue
+		}
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, tc.ExpectedMatch, selector.Matches(tc.Metadata), tc.Selector)
+		}
+	}
+}
+
+func TestBroker_BroadcastAudience(t *testing.T) {
+	tt := []struct {
+		Audience      string
+		Topic         string
+		ExpectedIDs   []string
+		ExpectedError string
+	}{
+		{Audience: "role=admin", ExpectedIDs: []string{"1", "2"}},
+		{Audience: "role=admin,region=eu", ExpectedIDs: []string{"1"}},
+		{Audience: "role=admin", Topic: "alerts", ExpectedIDs: []string{"2"}},
+		{Audience: "region!=eu", ExpectedIDs: []string{"2", "3"}},
+		{Audience: "role=nobody"},
+		{Audience: "!", ExpectedError: `invalid selector requirement "!"`},
+	}
+
+	for _, tc := range tt {
+		b := broker.New(time.Second, 3, nil)
+		clients := []*client.Client{
+			client.NewWithOptions(time.Second, 3, "1", client.WithQueueSize(1), client.WithMetadata(map[string]string{"role": "admin", "region": "eu"})),
+			client.NewWithOptions(time.Second, 3, "2", client.WithQueueSize(1), client.WithTopics("alerts"), client.WithMetadata(map[string]string{"role": "admin", "region": "us"})),
+			client.NewWithOptions(time.Second, 3, "3", client.WithQueueSize(1), client.WithMetadata(map[string]string{"role": "user"})),
+		}
+
+		for _, c := range clients {
+			assert.NoError(t, b.Subscribe(c))
+		}
+
+		err := b.BroadcastEvent(event.Event{Topic: tc.Topic, Audience: tc.Audience, Data: []byte("hello")})
+
+		if tc.ExpectedError != "" {
+			assert.EqualError(t, err, tc.ExpectedError)
+			b.Close()
+			continue
+		}
+
+		assert.NoError(t, err)
+
+		var received []string
+
+		for _, c := range clients {
+			if _, ok := c.Next(); ok {
+				received = append(received, c.ID())
+			}
+		}
+
+		assert.Equal(t, tc.ExpectedIDs, received, tc.Audience)
+		b.Close()
+	}
+}
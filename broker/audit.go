@@ -0,0 +1,92 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// DeliveryResult describes the outcome of an attempt to deliver an event to a client.
+	DeliveryResult int
+
+	// DeliveryHook is a function that is called after each attempt to deliver an event to a
+	// client. For events written to the client's stream, the latency is the time since the
+	// event was broadcast. For events that could not be queued for the client, the latency
+	// is how long the broker waited before giving up.
+	DeliveryHook func(clientID string, eventID string, result DeliveryResult, latency time.Duration)
+)
+
+const (
+	// DeliveryWritten indicates the event was written to the client's stream.
+	DeliveryWritten DeliveryResult = iota
+
+	// DeliveryFailed indicates the event could not be written to the client's stream.
+	DeliveryFailed
+
+	// DeliveryRejected indicates the event could not be queued for the client, because its queue
+	// remained full until the timeout or it was too slow to keep up.
+	DeliveryRejected
+
+	// DeliveryDiscarded indicates the event was not written because the client exceeded its
+	// bandwidth quota, see the broker.WithBandwidthQuota method.
+	DeliveryDiscarded
+)
+
+// WithDeliveryHook configures a function that is called after each attempt to deliver an event to
+// a client, allowing a record to be kept of exactly which events were delivered to each client.
+// This includes events that are replayed or sent when the client subscribes. Events discarded by
+// a client's slow policy or priority are not attempted, so are not reported. The hook is called
+// synchronously, so it should return quickly.
+func WithDeliveryHook(fn DeliveryHook) Option {
+	return func(b *defaultBroker) {
+		b.onDelivery = fn
+	}
+}
+
+// String returns a human readable description of the result.
+func (r DeliveryResult) String() string {
+	switch r {
+	case DeliveryWritten:
+		return "written"
+	case DeliveryFailed:
+		return "failed"
+	case DeliveryRejected:
+		return "rejected"
+	case DeliveryDiscarded:
+		return "discarded"
+	default:
+		return "unknown"
+	}
+}
+
+// write writes the event to the client's stream & reports the outcome to the delivery hook.
+func (b *defaultBroker) write(enc FrameWriter, c *client.Client, e event.Event) error {
+	err := enc.Encode(e)
+
+	if err != nil {
+		b.audit(c, e, DeliveryFailed, b.latency(e))
+	} else {
+		b.audit(c, e, DeliveryWritten, b.latency(e))
+	}
+
+	return err
+}
+
+// audit reports the outcome of delivering the event to the client, if a delivery hook is set.
+func (b *defaultBroker) audit(c *client.Client, e event.Event, result DeliveryResult, latency time.Duration) {
+	if b.onDelivery != nil {
+		b.onDelivery(c.ID(), e.ID, result, latency)
+	}
+}
+
+// latency returns the time since the event was broadcast, or zero if it has no timestamp.
+func (b *defaultBroker) latency(e event.Event) time.Duration {
+	if e.Timestamp.IsZero() {
+		return 0
+	}
+
+	return clock.Since(b.clock, e.Timestamp)
+}
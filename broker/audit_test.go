@@ -0,0 +1,88 @@
+package broker_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	DeliveryRecord struct {
+		ClientID string
+		EventID  string
+		Result   broker.DeliveryResult
+	}
+
+	DeliveryLog struct {
+		mux     sync.Mutex
+		records []DeliveryRecord
+	}
+)
+
+func (dl *DeliveryLog) Hook(clientID, eventID string, result broker.DeliveryResult, latency time.Duration) {
+	dl.mux.Lock()
+	defer dl.mux.Unlock()
+
+	dl.records = append(dl.records, DeliveryRecord{ClientID: clientID, EventID: eventID, Result: result})
+}
+
+func (dl *DeliveryLog) Records() []DeliveryRecord {
+	dl.mux.Lock()
+	defer dl.mux.Unlock()
+
+	return append([]DeliveryRecord(nil), dl.records...)
+}
+
+func TestBroker_WithDeliveryHook(t *testing.T) {
+	log := &DeliveryLog{}
+	b := broker.New(time.Second, 3, nil, broker.WithDeliveryHook(log.Hook))
+	defer b.Close()
+
+	w := ssetest.NewStreamRecorder()
+	defer w.Close()
+
+	go b.ClientHandler(w, w.NewRequest("GET", "/connect?id=reader", nil))
+	<-time.Tick(time.Second)
+
+	// A client that never takes events from its queue.
+	stuck := client.NewWithOptions(time.Millisecond*10, 3, "stuck", client.WithQueueSize(1))
+	assert.NoError(t, b.Subscribe(stuck))
+
+	assert.NoError(t, b.BroadcastTo("reader", []byte("hello")))
+	assert.NoError(t, b.BroadcastEvent(event.Event{ID: "1", Data: []byte("a")}))
+	assert.Error(t, b.BroadcastEvent(event.Event{ID: "2", Data: []byte("b")}))
+
+	_, err := w.WaitForEvents(3, time.Second)
+	assert.NoError(t, err)
+
+	records := log.Records()
+
+	assert.Contains(t, records, DeliveryRecord{ClientID: "reader", Result: broker.DeliveryWritten})
+	assert.Contains(t, records, DeliveryRecord{ClientID: "reader", EventID: "1", Result: broker.DeliveryWritten})
+	assert.Contains(t, records, DeliveryRecord{ClientID: "reader", EventID: "2", Result: broker.DeliveryWritten})
+	assert.Contains(t, records, DeliveryRecord{ClientID: "stuck", EventID: "2", Result: broker.DeliveryRejected})
+	assert.NotContains(t, records, DeliveryRecord{ClientID: "stuck", EventID: "1", Result: broker.DeliveryRejected})
+}
+
+func TestDeliveryResult_String(t *testing.T) {
+	tt := []struct {
+		Result   broker.DeliveryResult
+		Expected string
+	}{
+		{Result: broker.DeliveryWritten, Expected: "written"},
+		{Result: broker.DeliveryFailed, Expected: "failed"},
+		{Result: broker.DeliveryRejected, Expected: "rejected"},
+		{Result: broker.DeliveryDiscarded, Expected: "discarded"},
+		{Result: broker.DeliveryResult(-1), Expected: "unknown"},
+	}
+
+	for _, tc := range tt {
+		assert.Equal(t, tc.Expected, tc.Result.String())
+	}
+}
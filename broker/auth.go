@@ -0,0 +1,36 @@
+package broker
+
+import (
+	"net/http"
+)
+
+type (
+	// Authorizer is a function that determines if a request to one of the broker's handlers is
+	// allowed. If it returns an error, the request is rejected with a 401 status code & the
+	// CodeUnauthorized error code.
+	Authorizer func(r *http.Request) error
+)
+
+// WithAuthorizer configures a function that authorizes each request to the broker's client, event,
+// subscription & history handlers before it is handled. Requests between members of a cluster
+// are only authorized using this function if the cluster has no secret, see the broker.WithCluster
+// method. If 'fn' is nil, all requests are allowed.
+func WithAuthorizer(fn Authorizer) Option {
+	return func(b *defaultBroker) {
+		b.authorizer = fn
+	}
+}
+
+// authorize determines if the request is allowed, responding with an error if it is not.
+func (b *defaultBroker) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if b.authorizer == nil {
+		return true
+	}
+
+	if err := b.authorizer(r); err != nil {
+		b.httpError(w, r, CodeUnauthorized, err, http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,74 @@
+package broker_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Authorizer(t *testing.T) {
+	tt := []struct {
+		Name           string
+		Token          string
+		ExpectedStatus int
+		ExpectedCode   broker.ErrorCode
+	}{
+		{
+			Name:           "It should reject requests the authorizer returns an error for",
+			Token:          "invalid",
+			ExpectedStatus: http.StatusUnauthorized,
+			ExpectedCode:   broker.CodeUnauthorized,
+		},
+		{
+			Name:           "It should handle requests the authorizer allows",
+			Token:          "valid",
+			ExpectedStatus: http.StatusOK,
+		},
+	}
+
+	authorizer := func(r *http.Request) error {
+		if r.Header.Get("Authorization") != "valid" {
+			return errors.New("invalid token")
+		}
+
+		return nil
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var received *broker.Error
+
+			handler := func(w http.ResponseWriter, r *http.Request, err error) {
+				received, _ = err.(*broker.Error)
+				w.WriteHeader(received.Status)
+			}
+
+			b := broker.New(time.Second, 3, handler, broker.WithAuthorizer(authorizer))
+			defer b.Close()
+
+			r := httptest.NewRequest("POST", "/publish", nil)
+			r.Header.Set("Authorization", tc.Token)
+			w := httptest.NewRecorder()
+
+			b.EventHandler(w, r)
+
+			assert.Equal(t, tc.ExpectedStatus, w.Code)
+
+			if tc.ExpectedCode == "" {
+				assert.Nil(t, received)
+				return
+			}
+
+			if assert.NotNil(t, received) {
+				assert.Equal(t, tc.ExpectedCode, received.Code)
+				assert.Equal(t, tc.ExpectedStatus, received.Status)
+				assert.Equal(t, "invalid token", received.Error())
+			}
+		})
+	}
+}
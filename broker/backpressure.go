@@ -0,0 +1,166 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+)
+
+type (
+	// The BackpressureLimit type configures when the broker rejects broadcasts because its clients
+	// cannot keep up with them, see the broker.WithBackpressure method.
+	BackpressureLimit struct {
+		MaxPending int           // The number of events queued across all clients at which broadcasts are rejected. Zero disables backpressure.
+		Resume     int           // The number of queued events below which broadcasts are accepted again. Defaults to MaxPending.
+		Interval   time.Duration // How often the clients' queues are measured. Defaults to 100 milliseconds.
+		RetryAfter time.Duration // How long publishers are told to wait before retrying. Defaults to one second.
+	}
+
+	// The BackpressureError type is the error returned when an event is broadcast while the broker's
+	// clients are saturated. It matches ErrBackpressure using errors.Is.
+	BackpressureError struct {
+		Pending    int           // The number of events queued across all clients when they were last measured.
+		Saturation float64       // The number of queued events as a fraction of the configured maximum.
+		RetryAfter time.Duration // How long the publisher should wait before retrying.
+	}
+
+	// The backpressure type records the number of events queued across all clients, measuring
+	// them at most once per interval.
+	backpressure struct {
+		config    BackpressureLimit
+		mux       sync.Mutex
+		measured  time.Time
+		pending   int
+		saturated bool
+	}
+)
+
+const (
+	// How often the clients' queues are measured if the broker is not configured with an interval.
+	defaultBackpressureInterval = time.Millisecond * 100
+
+	// How long publishers are told to wait if the broker is not configured with a delay.
+	defaultBackpressureRetry = time.Second
+)
+
+var (
+	// ErrBackpressure is the error matched by a *BackpressureError, returned when an event is
+	// broadcast while the broker's clients have too many events queued.
+	ErrBackpressure = errors.New("the broker's clients cannot keep up with the events published")
+)
+
+// WithBackpressure configures the broker to reject broadcasts once the number of events queued or held
+// across all of its clients reaches 'cfg.MaxPending', until it falls below 'cfg.Resume', so that
+// publishers slow down rather than the broker's memory growing without bound. Rejected broadcasts return
+// a *BackpressureError, which matches ErrBackpressure, & the EventHandler responds with a 503 status code
+// & a Retry-After header, see the broker.WithErrorPolicy method to respond with a 429 instead. The
+// Dispatch & BroadcastAsync methods reject events in the same way before queueing them. Queues are
+// measured at most once per interval, so a burst of broadcasts can briefly exceed the maximum. The
+// saturation of the clients' queues is reported by the Stats method.
+func WithBackpressure(cfg BackpressureLimit) Option {
+	return func(b *defaultBroker) {
+		if cfg.MaxPending <= 0 {
+			b.backpressure = nil
+			return
+		}
+
+		if cfg.Resume <= 0 || cfg.Resume > cfg.MaxPending {
+			cfg.Resume = cfg.MaxPending
+		}
+
+		if cfg.Interval <= 0 {
+			cfg.Interval = defaultBackpressureInterval
+		}
+
+		if cfg.RetryAfter <= 0 {
+			cfg.RetryAfter = defaultBackpressureRetry
+		}
+
+		b.backpressure = &backpressure{config: cfg}
+	}
+}
+
+// Error returns a message describing how saturated the broker's clients are.
+func (e *BackpressureError) Error() string {
+	return fmt.Sprintf("%v: %d events are queued", ErrBackpressure, e.Pending)
+}
+
+// Is determines if the target is ErrBackpressure.
+func (e *BackpressureError) Is(target error) bool {
+	return target == ErrBackpressure
+}
+
+// admit returns an error if the broker should not accept another broadcast, because its memory
+// budget is exceeded or its clients are saturated.
+func (b *defaultBroker) admit() error {
+	if b.memory.reject() {
+		return ErrMemoryBudget
+	}
+
+	return b.checkBackpressure()
+}
+
+// checkBackpressure returns a *BackpressureError if the broker's clients are saturated, measuring
+// their queues if they have not been measured within the configured interval.
+func (b *defaultBroker) checkBackpressure() error {
+	p := b.backpressure
+
+	if p == nil {
+		return nil
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	now := b.clock.Now()
+
+	if p.measured.IsZero() || now.Sub(p.measured) >= p.config.Interval {
+		p.measured = now
+		p.pending = b.pending()
+
+		if p.pending >= p.config.MaxPending {
+			p.saturated = true
+		} else if p.pending < p.config.Resume {
+			p.saturated = false
+		}
+	}
+
+	if !p.saturated {
+		return nil
+	}
+
+	return &BackpressureError{
+		Pending:    p.pending,
+		Saturation: p.saturation(p.pending),
+		RetryAfter: p.config.RetryAfter,
+	}
+}
+
+// pending returns the number of events queued or held across all of the broker's clients.
+func (b *defaultBroker) pending() int {
+	var total int
+
+	b.clients.Range(func(key, value interface{}) bool {
+		if c, ok := value.(*client.Client); ok {
+			lag := c.Lag()
+			total += lag.Pending + lag.Held
+		}
+
+		return true
+	})
+
+	return total
+}
+
+// saturation returns the number of queued events as a fraction of the maximum, or zero if
+// backpressure is not enabled.
+func (p *backpressure) saturation(pending int) float64 {
+	if p == nil {
+		return 0
+	}
+
+	return float64(pending) / float64(p.config.MaxPending)
+}
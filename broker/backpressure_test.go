@@ -0,0 +1,88 @@
+package broker_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithBackpressure(t *testing.T) {
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	b := broker.New(time.Second, 3, nil,
+		broker.WithClock(clk),
+		broker.WithBackpressure(broker.BackpressureLimit{MaxPending: 4, Resume: 2, Interval: time.Second}),
+	)
+	defer b.Close()
+
+	c := client.NewWithOptions(time.Second, 3, "test", client.WithQueueSize(10))
+	assert.NoError(t, b.Subscribe(c))
+
+	// Queues are only measured once per interval, so broadcasts can briefly exceed the maximum.
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, b.Broadcast([]byte("hello")))
+	}
+
+	clk.Advance(time.Second)
+
+	err := b.Broadcast([]byte("hello"))
+	assert.True(t, errors.Is(err, broker.ErrBackpressure))
+	assert.Equal(t, &broker.BackpressureError{Pending: 4, Saturation: 1, RetryAfter: time.Second}, err)
+	assert.Equal(t, 1.0, b.Stats().Saturation)
+
+	assert.True(t, errors.Is(b.Dispatch(event.Event{Data: []byte("hello")}, nil), broker.ErrBackpressure))
+
+	w := httptest.NewRecorder()
+	b.EventHandler(w, httptest.NewRequest(http.MethodPost, "/broadcast", strings.NewReader("hello")))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Broadcasts are only accepted again once the queues fall below the resume threshold.
+	c.Next()
+	clk.Advance(time.Second)
+
+	assert.True(t, errors.Is(b.Broadcast([]byte("hello")), broker.ErrBackpressure))
+
+	c.Next()
+	c.Next()
+	clk.Advance(time.Second)
+
+	assert.NoError(t, b.Broadcast([]byte("hello")))
+	assert.Equal(t, 0.5, b.Stats().Saturation)
+}
+
+func TestBroker_WithBackpressureErrorPolicy(t *testing.T) {
+	policy := func(r *http.Request, err *broker.Error) broker.ErrorResponse {
+		if err.Code == broker.CodeBackpressure {
+			return broker.ErrorResponse{Status: http.StatusTooManyRequests}
+		}
+
+		return broker.ErrorResponse{}
+	}
+
+	b := broker.New(time.Second, 3, nil,
+		broker.WithErrorPolicy(policy),
+		broker.WithBackpressure(broker.BackpressureLimit{MaxPending: 1, RetryAfter: time.Second * 5}),
+	)
+	defer b.Close()
+
+	assert.NoError(t, b.Subscribe(client.NewWithOptions(time.Second, 3, "test", client.WithQueueSize(10))))
+	assert.NoError(t, b.Broadcast([]byte("hello")))
+	<-time.After(time.Millisecond * 150)
+
+	w := httptest.NewRecorder()
+	b.EventHandler(w, httptest.NewRequest(http.MethodPost, "/broadcast", strings.NewReader("hello")))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+}
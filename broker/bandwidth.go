@@ -0,0 +1,200 @@
+package broker
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+)
+
+type (
+	// The BandwidthQuota type limits the number of bytes written to each client within a
+	// window of time. Once a client has been sent its quota, further events are discarded
+	// until the window ends. As the size of an event is only known once it is written, a
+	// client may exceed its quota by a single event.
+	BandwidthQuota struct {
+		Bytes      uint64        // The number of bytes each client can be sent within the window. Zero means no limit.
+		Window     time.Duration // The length of each window. Defaults to one second.
+		OnExceeded QuotaHook     // If set, called the first time a client exceeds its quota within each window.
+	}
+
+	// QuotaHook is a function that is called when the client with the given id exceeds its
+	// bandwidth quota. The 'sent' parameter is the number of bytes written to the client
+	// within the current window.
+	QuotaHook func(clientID string, sent uint64)
+
+	// The Bandwidth type contains the number of bytes written to a client.
+	Bandwidth struct {
+		Sent      uint64 // The number of bytes written to the client since it connected.
+		Window    uint64 // The number of bytes written to the client within the current quota window.
+		Discarded uint64 // The number of events discarded because the client exceeded its quota.
+	}
+
+	// The bandwidthMeter type records the number of bytes written to each client & topic.
+	bandwidthMeter struct {
+		mux     sync.Mutex
+		quota   BandwidthQuota
+		total   uint64
+		clients map[*client.Client]*clientBandwidth
+		topics  map[string]uint64
+	}
+
+	// The clientBandwidth type records the number of bytes written to a single client.
+	clientBandwidth struct {
+		Bandwidth
+		started  time.Time
+		exceeded bool
+	}
+
+	// The countingWriter type counts the number of bytes written through it.
+	countingWriter struct {
+		w io.Writer
+		n uint64
+	}
+)
+
+// WithBandwidthQuota configures the number of bytes that can be written to each client within
+// a window of time. The number of bytes written to each client & topic is always recorded & is
+// available using the broker.Stats method. Bytes are counted before any compression is applied.
+func WithBandwidthQuota(q BandwidthQuota) Option {
+	return func(b *defaultBroker) {
+		if q.Window <= 0 {
+			q.Window = time.Second
+		}
+
+		b.bandwidth.quota = q
+	}
+}
+
+// track starts recording the bytes written to the client.
+func (m *bandwidthMeter) track(c *client.Client, now time.Time) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if m.clients == nil {
+		m.clients = make(map[*client.Client]*clientBandwidth)
+	}
+
+	m.clients[c] = &clientBandwidth{started: now}
+}
+
+// untrack stops recording the bytes written to the client.
+func (m *bandwidthMeter) untrack(c *client.Client) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	delete(m.clients, c)
+}
+
+// exceeded determines if the client has been sent its quota within the current window. If
+// so, the event about to be written is counted as discarded.
+func (m *bandwidthMeter) exceeded(c *client.Client, now time.Time) bool {
+	if m.quota.Bytes == 0 {
+		return false
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	cb, ok := m.clients[c]
+
+	if !ok {
+		return false
+	}
+
+	m.rotate(cb, now)
+
+	if cb.Window < m.quota.Bytes {
+		return false
+	}
+
+	cb.Discarded++
+
+	return true
+}
+
+// record adds the number of bytes written to the client for an event on the given topic. If
+// this causes the client to exceed its quota, the quota hook is called.
+func (m *bandwidthMeter) record(c *client.Client, topic string, n uint64, now time.Time) {
+	if n == 0 {
+		return
+	}
+
+	m.mux.Lock()
+
+	m.total += n
+
+	if topic != "" {
+		if m.topics == nil {
+			m.topics = make(map[string]uint64)
+		}
+
+		m.topics[topic] += n
+	}
+
+	cb, ok := m.clients[c]
+
+	if !ok {
+		m.mux.Unlock()
+		return
+	}
+
+	m.rotate(cb, now)
+	cb.Sent += n
+	cb.Window += n
+
+	// Only call the hook the first time the quota is exceeded within the window.
+	notify := m.quota.Bytes > 0 && cb.Window >= m.quota.Bytes && !cb.exceeded
+
+	if notify {
+		cb.exceeded = true
+	}
+
+	sent := cb.Window
+	m.mux.Unlock()
+
+	if notify && m.quota.OnExceeded != nil {
+		m.quota.OnExceeded(c.ID(), sent)
+	}
+}
+
+// rotate starts a new window for the client if the current one has ended. It must be called
+// while holding the meter's lock.
+func (m *bandwidthMeter) rotate(cb *clientBandwidth, now time.Time) {
+	if m.quota.Window <= 0 || now.Sub(cb.started) < m.quota.Window {
+		return
+	}
+
+	cb.started = now
+	cb.Window = 0
+	cb.exceeded = false
+}
+
+// stats returns the bandwidth of each client by id, the bytes written for each topic & the
+// total bytes written to all clients.
+func (m *bandwidthMeter) stats(now time.Time) (map[string]Bandwidth, map[string]uint64, uint64) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	clients := make(map[string]Bandwidth, len(m.clients))
+	topics := make(map[string]uint64, len(m.topics))
+
+	for c, cb := range m.clients {
+		m.rotate(cb, now)
+		clients[c.ID()] = cb.Bandwidth
+	}
+
+	for topic, n := range m.topics {
+		topics[topic] = n
+	}
+
+	return clients, topics, m.total
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += uint64(n)
+
+	return n, err
+}
@@ -0,0 +1,67 @@
+package broker_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithBandwidthQuota(t *testing.T) {
+	tt := []struct {
+		Quota             uint64
+		Window            time.Duration
+		Events            int
+		ExpectedSent      uint64
+		ExpectedDiscarded uint64
+		ExpectedExceeded  int
+	}{
+		// Each event is written as 'data: hello\n\n'.
+		{Events: 3, ExpectedSent: 39},
+		{Quota: 20, Window: time.Minute, Events: 3, ExpectedSent: 26, ExpectedDiscarded: 1, ExpectedExceeded: 1},
+		{Quota: 10, Window: time.Minute, Events: 3, ExpectedSent: 13, ExpectedDiscarded: 2, ExpectedExceeded: 1},
+	}
+
+	for _, tc := range tt {
+		var mux sync.Mutex
+		var exceeded int
+
+		hook := func(clientID string, sent uint64) {
+			mux.Lock()
+			defer mux.Unlock()
+
+			assert.Equal(t, "test", clientID)
+			exceeded++
+		}
+
+		quota := broker.BandwidthQuota{Bytes: tc.Quota, Window: tc.Window, OnExceeded: hook}
+		b := broker.New(time.Second, 3, nil, broker.WithBandwidthQuota(quota))
+		w := ssetest.NewStreamRecorder()
+
+		go b.ClientHandler(w, w.NewRequest("GET", "/connect?id=test&topic=prices", nil))
+		<-time.Tick(time.Second)
+
+		for i := 0; i < tc.Events; i++ {
+			assert.NoError(t, b.BroadcastTopic("prices", []byte("hello")))
+		}
+
+		<-time.Tick(time.Millisecond * 100)
+
+		stats := b.Stats()
+
+		assert.Equal(t, tc.ExpectedSent, stats.BytesSent)
+		assert.Equal(t, tc.ExpectedSent, stats.Topics["prices"].BytesSent)
+		assert.Equal(t, tc.ExpectedSent, stats.Bandwidth["test"].Sent)
+		assert.Equal(t, tc.ExpectedDiscarded, stats.Bandwidth["test"].Discarded)
+
+		mux.Lock()
+		assert.Equal(t, tc.ExpectedExceeded, exceeded)
+		mux.Unlock()
+
+		w.Close()
+		b.Close()
+	}
+}
@@ -0,0 +1,120 @@
+package broker_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+)
+
+func BenchmarkBroker_Broadcast(b *testing.B) {
+	for _, n := range []int{1, 100, 1000} {
+		b.Run(fmt.Sprintf("%v clients", n), func(b *testing.B) {
+			brk := broker.New(time.Second, 3, nil, broker.WithShards(1))
+			defer brk.Close()
+
+			stop := subscribeDraining(brk, n, "")
+			defer stop()
+
+			data := make([]byte, 1024)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				brk.Broadcast(data)
+			}
+		})
+	}
+}
+
+func BenchmarkBroker_BroadcastTopic(b *testing.B) {
+	brk := broker.New(time.Second, 3, nil, broker.WithShards(1))
+	defer brk.Close()
+
+	stop := subscribeDraining(brk, 100, "news")
+	defer stop()
+
+	data := make([]byte, 1024)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		brk.BroadcastTopic("news", data)
+	}
+}
+
+func BenchmarkBroker_BroadcastTopicEmpty(b *testing.B) {
+	brk := broker.New(time.Second, 3, nil, broker.WithShards(1))
+	defer brk.Close()
+
+	data := make([]byte, 1024)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		brk.BroadcastTopic("news", data)
+	}
+}
+
+func BenchmarkEncoder_Encode(b *testing.B) {
+	tt := []struct {
+		Name  string
+		Event event.Event
+	}{
+		{Name: "data", Event: event.Event{Data: make([]byte, 1024)}},
+		{Name: "fields", Event: event.Event{ID: "1", Type: "update", Data: []byte("hello")}},
+		{Name: "multiline", Event: event.Event{Data: []byte("line one\nline two\nline three")}},
+	}
+
+	for _, tc := range tt {
+		b.Run(tc.Name, func(b *testing.B) {
+			enc := protocol.NewEncoder(ioutil.Discard)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				enc.Encode(tc.Event)
+			}
+		})
+	}
+}
+
+// subscribeDraining subscribes 'n' clients to the broker, each discarding the events written
+// to it, returning a function that stops them.
+func subscribeDraining(brk broker.Broker, n int, topic string) func() {
+	done := make(chan struct{})
+
+	for i := 0; i < n; i++ {
+		var opts []client.Option
+
+		if topic != "" {
+			opts = append(opts, client.WithTopics(topic))
+		}
+
+		c := client.NewWithOptions(time.Second, 3, fmt.Sprint(i), append(opts, client.WithQueueSize(64))...)
+		brk.Subscribe(c)
+
+		go func() {
+			for {
+				select {
+				case <-c.Ready():
+					for _, ok := c.Next(); ok; _, ok = c.Next() {
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	return func() { close(done) }
+}
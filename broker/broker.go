@@ -4,33 +4,222 @@ package broker
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/compress"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/store"
+	"github.com/rs/xid"
 )
 
 type (
 	// The Broker interface describes the Server Side Events broker, propagating messages
-	// to all connected clients.
+	// to all connected clients. Each of its features is described by a smaller interface,
+	// which code should depend on where it only needs that feature.
 	Broker interface {
+		Publisher
+		AsyncPublisher
+		PublisherProvider
+		Scheduler
+		Subscriber
+		ClientManager
+		KeyManager
+		TopicCatalog
+		Notifier
+		HandlerProvider
+		EndpointProvider
+		Stats() Stats
+		Tenant(name string) Broker
+		Close() error
+	}
+
+	// The Publisher interface describes types that events can be published to. Code that
+	// only broadcasts events should depend on this interface rather than the Broker.
+	Publisher interface {
 		Broadcast(data []byte) error
 		BroadcastTo(id string, data []byte) error
+		BroadcastTopic(topic string, data []byte) error
+		BroadcastExcept(excludeIDs []string, data []byte) error
+		BroadcastGroup(group string, data []byte) error
+		BroadcastEvent(e event.Event) error
+		BroadcastWithin(e event.Event, budget ErrorBudget) error
+		BroadcastSummary(e event.Event) (Summary, error)
+	}
+
+	// The AsyncPublisher interface describes types that events can be published to without
+	// waiting for them to be delivered.
+	AsyncPublisher interface {
+		Dispatch(e event.Event, fn DispatchCallback) error
+		BroadcastAsync(e event.Event) <-chan BroadcastResult
+	}
+
+	// The PublisherProvider interface describes types that provide publishers scoped to a
+	// stream, a delivery deadline or a single event type.
+	PublisherProvider interface {
+		Stream(name string) Publisher
+		Timeout(d time.Duration) Publisher
+		Writer(eventType string) io.WriteCloser
+	}
+
+	// The ClientManager interface describes types that manage the clients connected to them
+	// by their identifier.
+	ClientManager interface {
+		Pending(id string, payloads bool) ([]client.Pending, error)
+		Pause(id string) error
+		Resume(id string) error
+		Reconnect(hint ReconnectHint, ids ...string) error
+		Kick(id string) error
+	}
+
+	// The KeyManager interface describes types that manage the keys used to publish events.
+	KeyManager interface {
+		CreateKey(key store.Key) (store.Key, error)
+		RevokeKey(id string) error
+	}
+
+	// The TopicCatalog interface describes types that keep a catalog of the topics events are
+	// published to.
+	TopicCatalog interface {
+		RegisterTopic(info TopicInfo)
+		Catalog() []TopicInfo
+	}
+
+	// The Notifier interface describes types that notify callers of system events & client
+	// disconnections. Each method returns a function that removes the callback.
+	Notifier interface {
+		OnSystemEvent(fn func(SystemEvent)) func()
+		OnClientDisconnect(fn func(id string, reason DisconnectReason)) func()
+	}
+
+	// The Scheduler interface describes types that events can be scheduled to be published to
+	// in the future.
+	Scheduler interface {
+		BroadcastAt(t time.Time, e event.Event) Scheduled
+		BroadcastAfter(d time.Duration, e event.Event) Scheduled
+		Every(interval time.Duration, fn EventSource) func()
+	}
+
+	// The Subscriber interface describes types that clients can be subscribed to without an
+	// HTTP connection. Subscribed clients receive events using their Ready & Next methods.
+	Subscriber interface {
+		Subscribe(c *client.Client) error
+		Unsubscribe(c *client.Client)
+		UpdateSubscriptions(id string, change SubscriptionChange) ([]string, error)
+		SubscribeLocal(topic string) (<-chan event.Event, func())
+	}
+
+	// The HandlerProvider interface describes types that provide the HTTP handlers used to
+	// connect clients, publish events, change subscriptions, read past events, communicate
+	// with other members of a cluster, manage publisher keys & receive pings from clients.
+	HandlerProvider interface {
 		ClientHandler(w http.ResponseWriter, r *http.Request)
 		EventHandler(w http.ResponseWriter, r *http.Request)
+		SubscriptionHandler(w http.ResponseWriter, r *http.Request)
+		HistoryHandler(w http.ResponseWriter, r *http.Request)
+		ClusterHandler(w http.ResponseWriter, r *http.Request)
+		KeyHandler(w http.ResponseWriter, r *http.Request)
+		PingHandler(w http.ResponseWriter, r *http.Request)
 	}
 
-	// ErrorHandler is a convenience wrapper for the HTTP error handling function.
+	// Option is a function that modifies the broker's optional configuration.
+	Option func(*defaultBroker)
+
+	// ErrorHandler is a convenience wrapper for the HTTP error handling function. The
+	// error passed to the handler is always of type *Error.
 	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
 
 	defaultBroker struct {
-		timeout      time.Duration
-		clients      *sync.Map
-		errorHandler ErrorHandler
-		tolerance    int
+		timeout           time.Duration
+		clients           *sync.Map
+		errorHandler      ErrorHandler
+		errorPolicy       ErrorPolicy
+		methodChecks      bool
+		tolerance         int
+		upstream          *upstream
+		collectorURL      string
+		codecs            []compress.Codec
+		coalesceWindow    time.Duration
+		proxyProfile      ProxyProfile
+		dispatchQueue     int
+		dispatcher        *dispatcher
+		asyncPublish      bool
+		keys              *publisherKeys
+		deduper           *deduper
+		encoding          PayloadEncoding
+		frameWriter       FrameWriterFunc
+		shards            int
+		all               *fanout
+		empty             *fanout
+		topics            map[string]*fanout
+		topicsMux         sync.RWMutex
+		maxConnectionAge  time.Duration
+		queueSize         int
+		store             store.Store
+		slowPolicy        client.SlowPolicy
+		clientFromContext ClientFromContext
+		replayTTL         time.Duration
+		takeover          bool
+		onSubscribe       SubscribeHook
+		limitsValidator   LimitsValidator
+		wheel             *timerWheel
+		closed            chan struct{}
+		closeOnce         sync.Once
+		idempotency       *idempotencyFilter
+		opts              []Option
+		tenants           tenants
+		maxClients        int
+		limiter           *rateLimiter
+		bandwidth         bandwidthMeter
+		chunkSize         int
+		onDelivery        DeliveryHook
+		onWrite           WriteHook
+		cluster           *cluster
+		clusterConfig     ClusterConfig
+		polyfill          bool
+		authorizer        Authorizer
+		adminAuthorizer   Authorizer
+		sessions          *sessions
+		pauseLimit        int
+		statsdConfig      StatsDConfig
+		statsd            *statsd
+		index             metadataIndex
+		securityHeaders   bool
+		handshake         bool
+		cookie            *CookieConfig
+		topicParser       SubscriptionParser
+		system            *systemBus
+		adminTopic        string
+		adminStats        time.Duration
+		admin             chan event.Event
+		liveness          *liveness
+		catalog           catalog
+		faults            *faults
+		backpressure      *backpressure
+		memory            *memoryBudget
+		resources         accounting
+		leakReport        func(err error)
+		retryPolicy       client.RetryPolicy
+		deltas            *deltaEncoder
+		retained          retainer
+		groups            groupIndex
+		encrypter         Encrypter
+		guard             *connectionGuard
+		shutdownHint      *ReconnectHint
+		interceptors      []Interceptor
+		sequences         *sequencer
+		persistSubs       bool
+		subscriptionKeys  sync.Map
+		streams           sync.Map
+		profilerLabels    bool
+		clock             clock.Clock
 	}
 )
 
@@ -39,77 +228,403 @@ type (
 // will not recieve that message. The 'tolerance' parameter indicates how many sequential errors
 // can occur when communicating with a client until the client is forcefully disconnected. The
 // 'eh' parameter is a custom HTTP error handler that the broker will use when HTTP errors are
-// raised. If 'eh' is null, the default http.Error method is used.
-func New(timeout time.Duration, tolerance int, eh ErrorHandler) Broker {
-	return &defaultBroker{
+// raised. If 'eh' is null, the default http.Error method is used. Any additional options
+// are applied to the broker in the order they are given.
+func New(timeout time.Duration, tolerance int, eh ErrorHandler, opts ...Option) Broker {
+	return newBroker(timeout, tolerance, eh, opts...)
+}
+
+func newBroker(timeout time.Duration, tolerance int, eh ErrorHandler, opts ...Option) *defaultBroker {
+	broker := configure(timeout, tolerance, eh, opts...)
+
+	// Configure the store, connect to the collector & join the cluster once every option has been
+	// applied, so that they use the broker's clock & timeout.
+	if s, ok := broker.store.(store.ClockedStore); ok {
+		s.SetClock(broker.clock)
+	}
+
+	if broker.collectorURL != "" {
+		broker.upstream = newUpstream(broker.collectorURL, &http.Client{Timeout: broker.timeout}, broker.clock)
+	}
+
+	if broker.clusterConfig.Advertise != "" {
+		broker.cluster = newCluster(broker.clusterConfig, &http.Client{Timeout: broker.timeout}, broker.localClients, broker.clock)
+	}
+
+	broker.start()
+
+	return broker
+}
+
+// configure returns a broker with the options applied, without starting any of the work it does
+// in the background.
+func configure(timeout time.Duration, tolerance int, eh ErrorHandler, opts ...Option) *defaultBroker {
+	broker := &defaultBroker{
 		timeout:      timeout,
 		clients:      &sync.Map{},
 		tolerance:    tolerance,
 		errorHandler: eh,
+		methodChecks: true,
+		topics:       make(map[string]*fanout),
+		closed:       make(chan struct{}),
+		opts:         opts,
+		clock:        clock.Real(),
+	}
+
+	for _, opt := range opts {
+		opt(broker)
+	}
+
+	if broker.shards <= 0 {
+		broker.shards = runtime.NumCPU()
+	}
+
+	broker.all = newFanout(broker.shards, broker.clock)
+	broker.empty = newFanout(1, broker.clock)
+	broker.wheel = newTimerWheel(broker.BroadcastEvent, broker.clock)
+	broker.dispatcher = newDispatcher(broker.dispatchQueue, broker.BroadcastSummary, broker.closed)
+	broker.sessions.useClock(broker.clock)
+	broker.system = newSystemBus(broker.clock)
+
+	if broker.adminStats > 0 && broker.adminTopic == "" {
+		broker.adminTopic = DefaultAdminTopic
+	}
+
+	return broker
+}
+
+// start begins the work the broker does in the background, such as forwarding system events,
+// broadcasting statistics & enforcing its memory budget.
+func (b *defaultBroker) start() {
+	b.listenSystem()
+	b.broadcastStats()
+	b.enforceMemory()
+
+	// Push statistics once the broker is ready to report them.
+	if b.statsdConfig.Address != "" {
+		b.statsd = newStatsD(b.statsdConfig, b.Stats, b.clock)
 	}
 }
 
+// Close disconnects all clients from the broker and stops any background work that
+// the broker has started, such as publishing events to a collector, exchanging cluster
+// membership, pushing statistics or broadcasting periodic events. Scheduled events that
+// have not yet been broadcast are cancelled, and any tenants of the broker are closed.
+func (b *defaultBroker) Close() error {
+	b.closeOnce.Do(func() { close(b.closed) })
+	b.dispatcher.wait()
+
+	b.clients.Range(func(key, value interface{}) bool {
+		b.removeClient(key.(string), DisconnectShutdown)
+		return true
+	})
+
+	if b.upstream != nil {
+		b.upstream.close()
+	}
+
+	b.wheel.close()
+	b.tenants.close()
+	b.sessions.close()
+
+	if b.cluster != nil {
+		b.cluster.close()
+	}
+
+	if b.statsd != nil {
+		b.statsd.close()
+	}
+
+	b.system.close()
+
+	return nil
+}
+
 func (b *defaultBroker) BroadcastTo(id string, data []byte) error {
+	return b.sendTo(id, event.Event{Data: data})
+}
+
+// sendTo writes the event to the client with the given id.
+func (b *defaultBroker) sendTo(id string, e event.Event) error {
+	if !b.limiter.allow(b.clock.Now()) {
+		return ErrRateLimited
+	}
+
+	e, err := b.intercept(e)
+
+	if err == ErrDropEvent {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	err = b.sendLocal(id, e)
+
+	// If the client isn't connected to this broker, it may be connected to another
+	// member of the cluster.
+	if err == errUnknownClient && b.cluster != nil {
+		err = b.cluster.route(id, e)
+	}
+
+	if err == errUnknownClient {
+		return fmt.Errorf("no client with id %v exists", id)
+	}
+
+	return err
+}
+
+// sendLocal writes the event to the client with the given id, if it is connected to this broker.
+func (b *defaultBroker) sendLocal(id string, e event.Event) error {
 	item, ok := b.clients.Load(id)
 
 	if !ok {
-		return fmt.Errorf("no client with id %v exists", id)
+		return errUnknownClient
 	}
 
 	client, ok := item.(*client.Client)
 
 	if !ok {
-		b.removeClient(id)
+		b.removeClient(id, "")
 		return errors.New("client is malformed, disconnecting")
 	}
 
-	return client.Write(data)
+	if e.Timestamp.IsZero() {
+		e.Timestamp = b.clock.Now()
+	}
+
+	var single [1]event.Event
+
+	for _, chunk := range b.chunk(single[:0], e) {
+		started := b.clock.Now()
+
+		if err := client.WriteEvent(chunk); err != nil {
+			b.audit(client, chunk, DeliveryRejected, clock.Since(b.clock, started))
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Broadcast writes the given data to all connected clients. If a client exceeds its error tolerance, it is
 // forcefully disconnected from the broker. All errors are concatenated with newlines and returned from this
-// method as a single error.
+// method as a single error. If deduplication is enabled and the data is a duplicate of the previous broadcast,
+// it is not written to any clients.
 func (b *defaultBroker) Broadcast(data []byte) error {
+	return b.BroadcastEvent(event.Event{Data: data})
+}
+
+// BroadcastExcept writes the given data to all connected clients other than those with the given
+// ids, such as the client whose action caused the event, so that it is not echoed back to them.
+// Clients are excluded as the event is written, rather than by listing every other client. Errors
+// are handled in the same way as the Broadcast method.
+func (b *defaultBroker) BroadcastExcept(excludeIDs []string, data []byte) error {
+	return b.BroadcastEvent(event.Event{Data: data, Except: excludeIDs})
+}
+
+// BroadcastTopic writes the given data to all clients subscribed to the given topic. Errors are handled in
+// the same way as the Broadcast method. If no clients are subscribed to the topic, the data is discarded.
+func (b *defaultBroker) BroadcastTopic(topic string, data []byte) error {
+	return b.BroadcastEvent(event.Event{Topic: topic, Data: data})
+}
+
+// BroadcastEvent writes the given event to all clients subscribed to the event's topic, or to all connected
+// clients if the event has no topic. If the event has an audience, only the clients whose metadata matches it
+// receive the event, see the broker.ParseSelector function. If the event has a group, only the group's members
+// receive it, see the BroadcastGroup method. Clients whose ids are in the event's Except field do not
+// receive it. If the broker has a store, the event is appended to it so that it can be replayed to
+// reconnecting clients, and is given a unique identifier if it does not already have one. Errors are
+// handled in the same way as the Broadcast method.
+func (b *defaultBroker) BroadcastEvent(e event.Event) error {
+	if !b.limiter.allow(b.clock.Now()) {
+		return ErrRateLimited
+	}
+
+	if err := b.admit(); err != nil {
+		return err
+	}
+
+	e, err := b.intercept(e)
+
+	if err == ErrDropEvent {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	group, err := b.group(e)
+
+	if err != nil {
+		return err
+	}
+
+	return b.broadcast(group, e, nil)
+}
+
+// group returns the group of clients that the event should be written to.
+func (b *defaultBroker) group(e event.Event) (*fanout, error) {
+	if e.Group != "" {
+		return b.members(e)
+	}
+
+	if e.Audience != "" {
+		return b.audience(e)
+	}
+
+	if e.Topic == "" {
+		return b.all, nil
+	}
+
+	b.topicsMux.RLock()
+	group, ok := b.topics[e.Topic]
+	b.topicsMux.RUnlock()
+
+	// If nobody is subscribed, we still store the event and forward it
+	// upstream as the collector may have subscribers of its own. Clients
+	// are never added to the empty group, so it is shared between events.
+	if !ok {
+		group = b.empty
+	}
+
+	return group, nil
+}
+
+// broadcast writes the event to every client in the group. If 'budget' is set, each chunk of the
+// event is written within it, see the BroadcastWithin method.
+func (b *defaultBroker) broadcast(group *fanout, e event.Event, budget *ErrorBudget) error {
+	_, err := b.broadcastSummary(group, e, budget)
+
+	return err
+}
+
+// broadcastSummary writes the event to every client in the group in the same way as the broadcast
+// method, returning a summary of its delivery.
+func (b *defaultBroker) broadcastSummary(group *fanout, e event.Event, budget *ErrorBudget) (summary Summary, err error) {
 	var out []string
 
-	// Loop through each connected client.
-	b.clients.Range(func(key, value interface{}) bool {
-		client, ok := value.(*client.Client)
+	started := b.clock.Now()
+	defer func() { summary.Duration = clock.Since(b.clock, started) }()
 
-		// If we couldn't cast the client, something strange has
-		// gotten into the map. Add an error to the array and
-		// force disconnect the client.
-		if !ok {
-			err := fmt.Errorf("found malformed client with id %v, disconnecting", key)
+	if b.idempotency.duplicate(e.ID, b.clock.Now()) {
+		summary.Duplicate = true
+		return summary, nil
+	}
+
+	if b.deduper != nil && b.deduper.duplicate(e) {
+		summary.Duplicate = true
+		return summary, nil
+	}
+
+	if e.Timestamp.IsZero() {
+		e.Timestamp = b.clock.Now()
+	}
+
+	b.recordTopic(e)
+
+	// If events are numbered, number the event within its topic. The topic is locked until the
+	// event has been written, so that clients receive the numbers in order.
+	if topic := b.sequences.topic(e); topic != nil {
+		topic.mux.Lock()
+		defer topic.mux.Unlock()
+
+		e.Sequence = topic.next()
+	}
+
+	// If the broker has a store, persist the event so it can be replayed.
+	if b.store != nil {
+		if e.ID == "" {
+			e.ID = xid.New().String()
+		}
+
+		if err := b.store.Append(e); err != nil {
+			out = append(out, err.Error())
+		}
+	}
+
+	// If the broker is connected to a collector, forward the event upstream.
+	if b.upstream != nil {
+		if err := b.upstream.publish(e); err != nil {
 			out = append(out, err.Error())
-			b.clients.Delete(key)
 		}
+	}
+
+	// If the event is retained, replace the topic's retained event. The topic is locked until the
+	// event has been written, so that subscribing clients are not sent an older event afterwards.
+	if topic := b.retained.topic(e); topic != nil {
+		topic.mux.Lock()
+		defer topic.mux.Unlock()
+
+		topic.retain(e)
+	}
+
+	// If the topic is delta encoded, send the event as a patch against the previous one. The
+	// topic is locked until the event has been written, so that patches are not reordered.
+	if topic := b.deltas.topic(e); topic != nil {
+		topic.mux.Lock()
+		defer topic.mux.Unlock()
 
-		// Attempt to write data to the client
-		if err := client.Write(data); err != nil {
-			// If an error occured, check if we should force
-			// disconnect the client.
-			if client.ShouldDisconnect() {
-				b.removeClient(client.ID())
+		e = topic.encode(e, b.deltas.interval)
+	}
+
+	// Write each chunk of the event in turn, so that other events can be written
+	// between them.
+	var single [1]event.Event
+
+	for _, chunk := range b.chunk(single[:0], e) {
+		var result delivery
+
+		b.labelTopic(chunk, func() {
+			if budget != nil {
+				result = group.broadcastWithin(chunk, b.onDelivery, *budget, func(c *client.Client) {
+					b.evict(c, DisconnectTolerance)
+				})
+			} else {
+				result = group.broadcast(chunk, b.onDelivery)
 			}
+		})
 
-			out = append(out, err.Error())
+		out = append(out, result.errors...)
+		summary.Delivered = result.delivered
+		summary.Failed = len(result.errors)
+		summary.Skipped = result.excluded
+
+		// Force disconnect any clients that have exceeded their tolerance.
+		for _, client := range result.evicted {
+			b.evict(client, DisconnectTolerance)
 		}
 
-		return true
-	})
+		// If the budget has been exceeded, the remaining chunks are not written.
+		if result.exceeded {
+			return summary, fmt.Errorf("%w: %v", ErrBudgetExceeded, strings.Join(out, "\n"))
+		}
+	}
 
 	// If we have multiple errors, concatenate them with newlines.
 	if len(out) > 0 {
-		return errors.New(strings.Join(out, "\n"))
+		return summary, errors.New(strings.Join(out, "\n"))
 	}
 
-	return nil
+	return summary, nil
 }
 
 // EventHandler is an HTTP handler that allows a client to broadcast an event to the
 // broker. This method should be registered to an endpoint of your choosing. For information
-// on error handling, see the broker.SetErrorHandler method.
+// on error handling, see the broker.SetErrorHandler method. The event can be sent to a
+// single client using the 'id' query parameter, or to the subscribers of a topic using the
+// 'topic' query parameter. Broadcast events can be limited to the clients whose metadata matches
+// the 'audience' query parameter, see the broker.ParseSelector function. Clients can be excluded
+// from broadcast events using one or more 'except' query parameters containing their ids, and
+// limited to the members of the 'group' query parameter, see the broker.BroadcastGroup method.
+// Topic events with the 'retain' query parameter set to 'true' are retained, see the
+// broker.WithRetainedTopics method. The priority of the event can be set to 'low', 'normal' or
+// 'high' using the 'priority' query parameter. Retried requests can be discarded using the
+// 'Idempotency-Key' header, see the broker.WithIdempotencyWindow method. Events given a 'key' query parameter
+// replace any event with the same key still queued for a client, so that slow clients only receive the latest.
+// Events are sent to the logical stream given by the 'stream' query parameter, see the broker.Stream method.
+// The 'timeout' query parameter, such as '250ms', overrides how long the broker waits to write the event to
+// each client, see the broker.Timeout method. Brokers can respond before the event has been written to every
+// client, see the broker.WithAsyncPublishing method.
+// Requests using methods other than POST receive a 405 status code, see the broker.WithMethodChecks method.
 //
 // Example using http (https://golang.org/pkg/net/http/)
 //
@@ -123,37 +638,159 @@ func (b *defaultBroker) Broadcast(data []byte) error {
 //
 // http.ListenAndServe(":8080", r)
 func (b *defaultBroker) EventHandler(w http.ResponseWriter, r *http.Request) {
+	if b.allowMethod(w, r, http.MethodPost) {
+		b.publishEvent(w, r)
+	}
+}
+
+// publishEvent broadcasts the request body as an event, regardless of the request's method. It is
+// used by the EventHandler method & by endpoints, which check the method themselves.
+func (b *defaultBroker) publishEvent(w http.ResponseWriter, r *http.Request) {
+	if !b.authorize(w, r) {
+		return
+	}
+
+	// Events sent to a single client have no topic.
+	topic := r.URL.Query().Get("topic")
+
+	if r.URL.Query().Get("id") != "" {
+		topic = ""
+	}
+
+	key, ok := b.checkKey(w, r, topic)
+
+	if !ok {
+		return
+	}
+
+	// Stop reading the event data once it is larger than the publisher's key allows, rather
+	// than buffering all of it first.
+	if key.MaxPayload > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(key.MaxPayload))
+	}
+
 	// Attempt to read the provided event data.
 	data, err := ioutil.ReadAll(r.Body)
 
+	var tooLarge *http.MaxBytesError
+
 	// If we fail to read, either use the custom error handler or
 	// use the default http error.
+	if errors.As(err, &tooLarge) {
+		b.httpError(w, r, CodePayloadTooLarge, ErrPayloadTooLarge, http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		b.httpError(w, r, CodeInvalidEvent, err, http.StatusInternalServerError)
+		return
+	}
+
+	if !b.allowKey(w, r, key) {
+		return
+	}
+
+	priority, err := event.ParsePriority(r.URL.Query().Get("priority"))
+
 	if err != nil {
-		b.httpError(w, r, err, http.StatusInternalServerError)
+		b.httpError(w, r, CodeInvalidEvent, err, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := ParseSelector(r.URL.Query().Get("audience")); err != nil {
+		b.httpError(w, r, CodeInvalidEvent, err, http.StatusBadRequest)
+		return
+	}
+
+	deadline, err := parseDeadline(r.URL.Query().Get("timeout"), b.clock.Now())
+
+	if err != nil {
+		b.httpError(w, r, CodeInvalidEvent, err, http.StatusBadRequest)
+		return
+	}
+
+	// If the event has already been published, report success without
+	// publishing it again. If publishing fails, the key is released so that
+	// the publisher can retry using it.
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+
+	if b.idempotency.duplicate(idempotencyKey, b.clock.Now()) {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
+	published := false
+
+	defer func() {
+		if !published {
+			b.idempotency.release(idempotencyKey)
+		}
+	}()
+
 	id := r.URL.Query().Get("id")
+	e := event.Event{Data: data, Priority: priority, Key: r.URL.Query().Get("key"), Stream: r.URL.Query().Get("stream"), Deadline: deadline}
 
 	// Attempt to broadcast the event data to the connected clients. If this
 	// fails, use either the custom error handler or the default http handler.
 	if id != "" {
-		err = b.BroadcastTo(id, data)
+		err = b.sendTo(id, e)
 	} else {
-		err = b.Broadcast(data)
+		e.Topic = topic
+		e.Audience = r.URL.Query().Get("audience")
+		e.Group = r.URL.Query().Get("group")
+		e.Except = r.URL.Query()["except"]
+		e.Retain = r.URL.Query().Get("retain") == "true"
+
+		// Respond once the event has been queued, rather than waiting for it to be written
+		// to every client, if configured.
+		if b.asyncPublish {
+			published = b.dispatchEvent(w, r, e)
+			return
+		}
+
+		err = b.BroadcastEvent(e)
 	}
 
-	if err != nil {
-		b.httpError(w, r, err, http.StatusInternalServerError)
+	if err == ErrRateLimited {
+		b.httpError(w, r, CodeQuotaExceeded, err, http.StatusTooManyRequests)
+		return
+	} else if errors.Is(err, ErrEventRejected) {
+		b.httpError(w, r, CodeEventRejected, err, http.StatusUnprocessableEntity)
+		return
+	} else if errors.Is(err, ErrBackpressure) {
+		b.httpError(w, r, CodeBackpressure, err, http.StatusServiceUnavailable)
+		return
+	} else if err == ErrMemoryBudget {
+		b.httpError(w, r, CodeMemoryBudget, err, http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		b.httpError(w, r, CodePublishFailed, err, http.StatusInternalServerError)
 		return
 	}
 
+	published = true
 	w.WriteHeader(http.StatusOK)
 }
 
 // ClientHandler is an HTTP handler that allows a client to connect to the
 // broker. This method should be registered to an endpoint of your choosing.
 // For information on error handling, see the broker.SetErrorHandler method.
+// Clients can subscribe to topics by providing one or more 'topic' query
+// parameters, a comma separated 'topics' query parameter or 'X-SSE-Topics' header,
+// see the broker.ParseTopics function & broker.WithSubscriptionParser method, unless
+// the broker derives client details from the request context, see the
+// broker.WithClientFromContext method. If the broker has a store, events the client missed are replayed
+// when it reconnects, or events newer than the RFC 3339 timestamp in the 'since' query parameter, see the
+// broker.WithStore method. The current state of each topic can be sent to clients
+// when they subscribe, see the broker.WithOnSubscribe method. Clients that send an 'Accept' header preferring
+// 'application/x-ndjson' receive events as newline-delimited JSON instead, see the protocol.NDJSONEncoder type.
+// Clients can resume a dropped connection using the 'session' query parameter, see the broker.WithSessions method.
+// Streams can begin with an event describing the broker's capabilities, see the broker.WithHandshake method.
+// Browsers can be identified using a signed cookie, see the broker.WithClientCookie method.
+// The protocol each client is connected using is reported by the broker's Stats method, which helps to diagnose
+// proxies that downgrade HTTP/2 connections. Clients that read the stream using fetch rather than EventSource
+// can open it using a POST request whose body is a JSON encoded StreamRequest, which can carry a token for the
+// broker's Authorizer. The body's content type is not checked, so cross-origin clients can send it as 'text/plain'
+// to avoid a preflight request. Requests using methods other than GET & POST receive a 405 status code, see the
+// broker.WithMethodChecks method.
 //
 // Example using http (https://golang.org/pkg/net/http/)
 //
@@ -167,72 +804,528 @@ func (b *defaultBroker) EventHandler(w http.ResponseWriter, r *http.Request) {
 //
 // http.ListenAndServe(":8080", r)
 func (b *defaultBroker) ClientHandler(w http.ResponseWriter, r *http.Request) {
-	// Attempt to cast the response writer to a flusher & close notifier
-	flusher, ok := w.(http.Flusher)
-	notify, ok := w.(http.CloseNotifier)
+	if b.allowMethod(w, r, http.MethodGet, http.MethodPost) {
+		b.serveClient(w, r)
+	}
+}
+
+// serveClient streams events to the client making the request, regardless of the request's method.
+// It is used by the ClientHandler method & by endpoints, which check the method themselves.
+func (b *defaultBroker) serveClient(w http.ResponseWriter, r *http.Request) {
+	// Streams opened using POST carry their parameters in the request body.
+	req, err := ParseStreamRequest(r)
+
+	if err != nil {
+		b.httpError(w, r, CodeInvalidSubscription, err, http.StatusBadRequest)
+		return
+	}
+
+	r = req
+
+	if !b.authorize(w, r) {
+		return
+	}
+
+	// Attempt to cast the response writer to a flusher & close notifier, unwrapping
+	// it if it has been wrapped by middleware.
+	flusher, notify, ok := streamWriter(w)
 
 	if !ok {
 		// If we fail to cast, use the custom error handler if set. Otherwise,
 		// use the default http error handler.
 		err := errors.New("client does not support streaming")
 
-		b.httpError(w, r, err, http.StatusInternalServerError)
+		b.httpError(w, r, CodeStreamingUnsupported, err, http.StatusInternalServerError)
 		return
 	}
 
-	// Set the required headers.
-	w.Header().Set("Content-Type", "text/event-stream")
+	// Set the required headers, writing the stream as newline-delimited JSON
+	// if the client prefers it.
+	contentType := negotiateFormat(r.Header.Get("Accept"))
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	// Create a new client with the configured timeout &
-	// tolerance.
-	client := client.New(b.timeout, b.tolerance, r.URL.Query().Get("id"))
-	id := client.ID()
+	// Connection-specific headers are forbidden in HTTP/2, where connections are
+	// persistent anyway.
+	if r.ProtoMajor < 2 {
+		w.Header().Set("Connection", "keep-alive")
+	}
+
+	b.polyfillHeaders(w.Header())
+	b.proxyHeaders(w.Header())
+	b.setSecurityHeaders(w.Header(), r)
 
-	// Ensure that no custom identifiers collide.
-	if b.hasClient(id) {
-		err := fmt.Errorf("a client with id %v already exists", id)
+	// Resume the client's session if it has one, otherwise create a new client.
+	info, err := b.clientInfo(r)
 
-		b.httpError(w, r, err, http.StatusInternalServerError)
+	if err != nil {
+		b.subscriptionError(w, r, err)
 		return
 	}
 
-	defer b.removeClient(id)
-	b.addClient(client)
+	if _, err := replaySince(r); err != nil {
+		b.httpError(w, r, CodeInvalidReplay, err, http.StatusBadRequest)
+		return
+	}
+
+	b.clientCookie(w, r, &info)
+
+	// Limit the number of streams open at once from the same source.
+	release, ok := b.guard.acquire(r, info)
+
+	if !ok {
+		b.httpError(w, r, CodeQuotaExceeded, ErrTooManyConnections, http.StatusTooManyRequests)
+		return
+	}
+
+	defer release()
+
+	// Sessions issued to another identity are ignored & a new session is started, so that
+	// a session identifier cannot be used to take over another identity's client.
+	sessionID := r.URL.Query().Get("session")
+
+	if !b.sessions.owned(sessionID, info.ID) {
+		sessionID = ""
+	}
+
+	sess, done := b.resumeSession(sessionID)
+	resumed := sess != nil
+
+	// Subscribe clients that cannot resume their session to the topics recorded
+	// for them, if they have not listed their own.
+	if !resumed {
+		b.restoreSubscription(&info, sessionID)
+	}
+
+	client, ok := b.connectClient(w, r, info, sess)
+
+	if !ok {
+		return
+	}
 
-	// Listen if the client disconnects.
-	close := notify.CloseNotify()
-	go b.listenForClose(id, close)
+	// Attribute the connection's goroutines to the client in profiles, if configured.
+	defer b.labelClient(r.Context(), client)()
+
+	// Count the goroutines & timers started for the connection, checking they have all
+	// been released once it ends.
+	res := b.resources.open()
+	defer b.releaseResources(res)
+
+	if !resumed {
+		sess, done = b.sessions.open(client)
+		b.persistSubscription(client, info, sess.key())
+	}
+
+	// Record why the stream ends, so that the client can be told & the client is released with
+	// the same reason.
+	reason := DisconnectConnectionLost
+	defer func() { b.release(client, sess, done, reason) }()
+
+	reasons := b.trackStream(client)
+	defer b.untrackStream(client, reasons)
+
+	// Establish how the client's events are encrypted, if they are.
+	seal, err := b.encryption(r, client)
+
+	if err != nil {
+		b.httpError(w, r, CodeEncryptionFailed, err, http.StatusBadRequest)
+		return
+	}
+
+	// Compress the stream if the client accepts one of the configured codecs.
+	stream, flush, closeStream := b.compressStream(w, r, flusher)
+	defer closeStream()
+
+	// Count the bytes written to the client, encoding events in the negotiated format.
+	out := &countingWriter{w: stream}
+	enc := b.newEncoder(out, contentType)
+
+	// Identify events by the cursor of each logical stream the connection carries.
+	enc = multiplex(enc, r, contentType)
+
+	// Report each event written to the client, if configured.
+	enc = b.instrument(enc, out, client)
+
+	// Drop, delay or disconnect events written to the client, if configured.
+	enc, cut := b.inject(enc)
+
+	b.bandwidth.track(client, b.clock.Now())
+	defer b.bandwidth.untrack(client)
+
+	// Flush events together if a coalescing window is configured.
+	coalescer := newCoalescer(b.coalescing(), flush, res, b.clock)
+	defer coalescer.stop()
+
+	// Tell the client why the stream ended, after any reconnect hint.
+	defer func(enc FrameWriter) { b.writeClose(enc, reason, flush) }(enc)
+
+	// Tell the client where & when to reconnect if the stream ends because the
+	// broker is closing.
+	defer b.reconnectOnClose(enc, client, flush)
+
+	// Listen if the client disconnects, until the handler returns.
+	notified := notify.CloseNotify()
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	res.goroutine(func() {
+		b.listenForClose(notified, stopped, func() { b.release(client, sess, done, DisconnectConnectionLost) })
+	})
+
+	// End the stream once the connection reaches its maximum age.
+	expired, stopExpiry := res.timer(b.connectionExpiry())
+	defer stopExpiry()
+
+	// Keep the stream open for browsers that use a polyfill.
+	heartbeat, stopHeartbeat := res.timer(b.heartbeat())
+	defer stopHeartbeat()
+
+	// Evict the client if it stops acknowledging that it is reading the stream,
+	// if configured.
+	b.liveness.track(client, b.clock.Now())
+	defer b.liveness.untrack(client)
+
+	alive, stopAlive := res.timer(b.livenessCheck())
+	defer stopAlive()
+
+	// HTTP/2 proxies may hold back a stream until its headers arrive, so send
+	// them before waiting for the first event.
+	if b.writePadding(enc) || r.ProtoMajor >= 2 {
+		flush()
+	}
+
+	// Describe the broker's capabilities before any other events.
+	if b.handshake {
+		enc.Encode(b.handshakeEvent(client))
+		flush()
+	}
+
+	// Let the client know its session, so that it can resume it if the
+	// connection drops.
+	if sess != nil && !resumed {
+		enc.Encode(sessionEvent(sess, b.clock.Now()))
+		flush()
+	}
+
+	// Encrypt the data of all events written from here on, if configured.
+	enc = sealed(enc, seal)
+
+	// Replay any events the client missed while disconnected. Live events may
+	// also have been stored while replaying, so skip any we've already written.
+	replayed := b.replay(enc, r, client, info.ID != "")
+
+	// Send the current state of the client's topics before any live events, unless
+	// the client is resuming a session & has already received it.
+	if (!resumed && b.snapshot(enc, client) > 0) || len(replayed) > 0 {
+		coalescer.written()
+	}
+
+	b.bandwidth.record(client, "", out.n, b.clock.Now())
+
+	// Wake periodically to check that the client is still connected, reusing a
+	// single ticker rather than creating one each time around the loop.
+	tick, stopTick := res.timer(b.ticker())
+	defer stopTick()
 
 	// While the client is connected
-	for b.hasClient(id) {
+	for b.connected(client) {
 		select {
-		// If we read an event, write it to the client
-		case data := <-client.Listen():
-			fmt.Fprintf(w, "data: %s\n\n", data)
-			flusher.Flush()
+		// If events are queued, write them to the client
+		case <-client.Ready():
+			for e, ok := client.Next(); ok; e, ok = client.Next() {
+				if _, ok := replayed[e.ID]; ok && e.ID != "" {
+					continue
+				}
+
+				// End the stream once the client has been told to reconnect elsewhere.
+				if e.Type == ReconnectEventType {
+					b.writeReconnect(enc, client, e)
+					flush()
+					reason = DisconnectReconnect
+					return
+				}
+
+				// Discard the event if the client has exceeded its bandwidth quota.
+				if b.bandwidth.exceeded(client, b.clock.Now()) {
+					b.audit(client, e, DeliveryDiscarded, b.latency(e))
+					continue
+				}
+
+				written := out.n
+				b.write(enc, client, e)
+				b.bandwidth.record(client, e.Topic, out.n-written, b.clock.Now())
+				b.acknowledge(client, e)
+			}
+
+			coalescer.written()
+			break
+
+		// If the coalescing window has elapsed, flush the stream.
+		case <-coalescer.C():
+			coalescer.elapsed()
+			break
+
+		// If the connection has reached its maximum age, tell the client to
+		// reconnect & end the stream.
+		case <-expired:
+			enc.Retry(rotationRetry)
+			flush()
+			reason = DisconnectMaxAge
+			return
+
+		// Periodically write a comment so that polyfills do not consider an
+		// idle stream disconnected.
+		case <-heartbeat:
+			enc.Comment("")
+			flush()
+			break
+
+		// If the client has missed too many pings, evict it.
+		case <-alive:
+			if !b.liveness.alive(client, b.clock.Now()) {
+				reason = DisconnectUnresponsive
+				b.evict(client, reason)
+				return
+			}
+
 			break
 
+		// If the client's session has been resumed by another connection,
+		// end the stream.
+		case <-done:
+			reason = DisconnectReplaced
+			return
+
+		// If the broker is closing, end the stream.
+		case <-b.closed:
+			reason = DisconnectShutdown
+			return
+
+		// If an injected fault has dropped the stream, end it without telling
+		// the client to reconnect.
+		case <-cut:
+			return
+
 		// If we exceed the timeout, continue.
-		case <-time.Tick(b.timeout):
+		case <-tick:
 			continue
 		}
 	}
+
+	// The client has been removed from the broker, so end the stream with the reason it was removed.
+	select {
+	case reason = <-reasons:
+	default:
+	}
+}
+
+// ticker returns a channel that receives each time the broker's timeout elapses, along with a
+// function that stops it. If the broker has no timeout, the channel never receives.
+func (b *defaultBroker) ticker() (<-chan time.Time, func()) {
+	if b.timeout <= 0 {
+		return nil, func() {}
+	}
+
+	t := b.clock.NewTicker(b.timeout)
+
+	return t.C(), t.Stop
+}
+
+// connectClient returns the client for the request. If the request resumes a session, the
+// session's client is returned, keeping its queue, subscriptions & metadata. Otherwise, a new
+// client is created & subscribed to the broker. If this fails, an error is written to the
+// response & false is returned.
+func (b *defaultBroker) connectClient(w http.ResponseWriter, r *http.Request, info ClientInfo, sess *session) (*client.Client, bool) {
+	if sess != nil {
+		return sess.client, true
+	}
+
+	// Create a new client with the configured timeout &
+	// tolerance, unless the client has overridden them.
+	timeout, tolerance, err := b.clientLimits(r, info)
+
+	if err != nil {
+		b.httpError(w, r, CodeInvalidLimits, err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	c := client.NewWithOptions(timeout, tolerance, info.ID,
+		client.WithTopics(info.Topics...),
+		client.WithMetadata(info.Metadata),
+		client.WithGroups(info.Groups...),
+		client.WithQueueSize(b.queueSize),
+		client.WithSlowPolicy(b.slowPolicy),
+		client.WithRetryPolicy(b.retryPolicy),
+		client.WithProtocol(r.Proto),
+		client.WithClock(b.clock),
+	)
+
+	// Ensure that no custom identifiers collide, unless the new connection
+	// should take over from the existing one.
+	if err := b.Subscribe(c); err == ErrTooManyClients {
+		b.httpError(w, r, CodeQuotaExceeded, err, http.StatusTooManyRequests)
+		return nil, false
+	} else if err != nil {
+		b.httpError(w, r, CodeClientConflict, err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return c, true
+}
+
+// Subscribe adds the client to the broker, subscribing it to each of its topics. Events broadcast to the
+// client are queued until they are taken using its Next method. If a client with the same identifier is
+// already connected, an error is returned unless the broker allows takeovers, see the broker.WithTakeover
+// method.
+func (b *defaultBroker) Subscribe(client *client.Client) error {
+	if b.maxClients > 0 && b.all.len() >= b.maxClients {
+		return ErrTooManyClients
+	}
+
+	if b.hasClient(client.ID()) {
+		if !b.takeover {
+			return fmt.Errorf("a client with id %v already exists", client.ID())
+		}
+
+		b.removeClient(client.ID(), DisconnectReplaced)
+	}
+
+	b.addClient(client)
+
+	return nil
+}
+
+// Unsubscribe removes the client from the broker & each of its topics. If the client has already been
+// replaced by another client with the same identifier, this method does nothing.
+func (b *defaultBroker) Unsubscribe(client *client.Client) {
+	b.disconnect(client, DisconnectUnsubscribed)
 }
 
 func (b *defaultBroker) addClient(client *client.Client) {
 	b.clients.Store(client.ID(), client)
+	b.all.add(client)
+	b.index.add(client)
+	b.groups.add(client, b.shards, b.clock)
+	b.emitClient(SystemClientConnected, client)
+
+	// Let the cluster know where the client is connected.
+	if b.cluster != nil {
+		id := client.ID()
+		b.resources.goroutine(func() { b.cluster.register(id) })
+	}
+
+	b.topicsMux.Lock()
+
+	// Add the client to the groups for each of its topics, creating
+	// them if this is the first subscriber.
+	for _, topic := range client.Topics() {
+		group, ok := b.topics[topic]
+
+		if !ok {
+			group = newFanout(b.shards, b.clock)
+			b.topics[topic] = group
+		}
+
+		group.add(client)
+	}
+
+	b.topicsMux.Unlock()
+	b.deltas.subscribe(client, client.Topics())
+	b.subscribeRetained(client, client.Topics())
+}
+
+// removeClient removes the client with the given id from the broker for the given reason.
+func (b *defaultBroker) removeClient(id string, reason DisconnectReason) {
+	// The reason is noted before the client is removed, so that its stream has it once it sees
+	// the client is no longer connected.
+	if item, ok := b.clients.Load(id); ok {
+		if client, ok := item.(*client.Client); ok {
+			b.noteReason(client, reason)
+		}
+	}
+
+	item, ok := b.clients.LoadAndDelete(id)
+
+	if !ok {
+		return
+	}
+
+	if client, ok := item.(*client.Client); ok {
+		b.unsubscribe(client, reason)
+	}
 }
 
-func (b *defaultBroker) removeClient(id string) {
-	b.clients.Delete(id)
+// disconnect removes the client from the broker for the given reason, unless it has already been
+// replaced by another client with the same identifier.
+func (b *defaultBroker) disconnect(client *client.Client, reason DisconnectReason) {
+	if b.connected(client) {
+		b.noteReason(client, reason)
+	}
+
+	if b.clients.CompareAndDelete(client.ID(), client) {
+		b.unsubscribe(client, reason)
+	}
 }
 
-func (b *defaultBroker) listenForClose(id string, notify <-chan bool) {
-	<-notify
-	b.removeClient(id)
+// connected determines if the client is still connected to the broker.
+func (b *defaultBroker) connected(client *client.Client) bool {
+	item, ok := b.clients.Load(client.ID())
+
+	return ok && item == client
+}
+
+func (b *defaultBroker) unsubscribe(client *client.Client, reason DisconnectReason) {
+	b.all.remove(client)
+	b.index.remove(client)
+	b.groups.remove(client)
+	b.subscriptionKeys.Delete(client)
+	b.emitDisconnect(client, reason)
+
+	// Closing the client releases any writers still waiting to queue events for it.
+	client.Close()
+
+	if b.cluster != nil {
+		go b.unregister(client.ID())
+	}
+
+	b.topicsMux.Lock()
+	defer b.topicsMux.Unlock()
+
+	// Remove the client from each of its topics, discarding any topics
+	// that no longer have subscribers.
+	for _, topic := range client.Topics() {
+		group, ok := b.topics[topic]
+
+		if !ok {
+			continue
+		}
+
+		group.remove(client)
+
+		if group.len() == 0 {
+			delete(b.topics, topic)
+		}
+	}
+}
+
+// unregister removes the cluster's record of where the client is connected, unless another
+// client with the same identifier has since connected to this broker.
+func (b *defaultBroker) unregister(id string) {
+	if !b.hasClient(id) {
+		b.cluster.unregister(id)
+	}
+}
+
+// listenForClose releases the client once its connection is closed. If the handler returns first,
+// such as when the stream reaches its maximum age, it stops listening so that the goroutine does
+// not outlive a connection that is kept alive for other requests.
+func (b *defaultBroker) listenForClose(notify <-chan bool, stopped <-chan struct{}, release func()) {
+	select {
+	case <-notify:
+		release()
+	case <-stopped:
+	}
 }
 
 func (b *defaultBroker) hasClient(id string) bool {
@@ -241,11 +1334,24 @@ func (b *defaultBroker) hasClient(id string) bool {
 	return ok
 }
 
-func (b *defaultBroker) httpError(w http.ResponseWriter, r *http.Request, err error, code int) {
-	if b.errorHandler != nil {
-		b.errorHandler(w, r, err)
-		return
-	}
+// streamWriter returns the flusher & close notifier for the response writer. If the response
+// writer does not implement them, response writers it wraps are checked using their Unwrap
+// method, as middleware commonly wraps the response writer & hides these interfaces.
+func streamWriter(w http.ResponseWriter) (http.Flusher, http.CloseNotifier, bool) {
+	for {
+		flusher, isFlusher := w.(http.Flusher)
+		notify, isNotifier := w.(http.CloseNotifier)
+
+		if isFlusher && isNotifier {
+			return flusher, notify, true
+		}
 
-	http.Error(w, err.Error(), code)
+		wrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+
+		if !ok {
+			return nil, nil, false
+		}
+
+		w = wrapper.Unwrap()
+	}
 }
@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,6 +23,14 @@ type (
 		code    int
 		flushed []byte
 	}
+
+	FlushRecorder struct {
+		mux     sync.Mutex
+		close   chan bool
+		header  http.Header
+		data    bytes.Buffer
+		flushes int
+	}
 )
 
 func (tr TestRecorder) CloseNotify() <-chan bool {
@@ -43,6 +55,44 @@ func (tr TestRecorder) Flush() {
 	tr.flushed = tr.data
 }
 
+func (fr *FlushRecorder) CloseNotify() <-chan bool {
+	return fr.close
+}
+
+func (fr *FlushRecorder) Header() http.Header {
+	return fr.header
+}
+
+func (fr *FlushRecorder) Write(data []byte) (int, error) {
+	fr.mux.Lock()
+	defer fr.mux.Unlock()
+
+	return fr.data.Write(data)
+}
+
+func (fr *FlushRecorder) WriteHeader(code int) {}
+
+func (fr *FlushRecorder) Flush() {
+	fr.mux.Lock()
+	defer fr.mux.Unlock()
+
+	fr.flushes++
+}
+
+func (fr *FlushRecorder) Flushes() int {
+	fr.mux.Lock()
+	defer fr.mux.Unlock()
+
+	return fr.flushes
+}
+
+func (fr *FlushRecorder) String() string {
+	fr.mux.Lock()
+	defer fr.mux.Unlock()
+
+	return fr.data.String()
+}
+
 func TestBroker_New(t *testing.T) {
 	tt := []struct {
 		Timeout   time.Duration
@@ -222,3 +272,121 @@ func TestBroker_BroadcastTo(t *testing.T) {
 		}
 	}
 }
+
+type (
+	WrappedRecorder struct {
+		http.ResponseWriter
+	}
+)
+
+func (wr *WrappedRecorder) Unwrap() http.ResponseWriter {
+	return wr.ResponseWriter
+}
+
+func TestBroker_WrappedResponseWriter(t *testing.T) {
+	tt := []struct {
+		Recorder      http.ResponseWriter
+		ExpectClients int
+	}{
+		{Recorder: &WrappedRecorder{ResponseWriter: &FlushRecorder{header: http.Header{}}}, ExpectClients: 1},
+		{Recorder: &WrappedRecorder{ResponseWriter: httptest.NewRecorder()}},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil)
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(tc.Recorder, httptest.NewRequest("GET", "/connect", nil))
+		<-time.Tick(time.Second)
+
+		assert.Equal(t, tc.ExpectClients, broker.Stats().Clients)
+		broker.Close()
+	}
+}
+
+func TestBroker_EventHandlerPriority(t *testing.T) {
+	tt := []struct {
+		Priority         string
+		ExpectedCode     int
+		ExpectedPriority event.Priority
+	}{
+		{Priority: "high", ExpectedCode: http.StatusOK, ExpectedPriority: event.PriorityHigh},
+		{Priority: "", ExpectedCode: http.StatusOK, ExpectedPriority: event.PriorityNormal},
+		{Priority: "urgent", ExpectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range tt {
+		b := broker.New(time.Second, 3, nil)
+		c := ssetest.NewClient("test", client.WithQueueSize(10))
+
+		assert.NoError(t, b.Subscribe(c.Client))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/broadcast?id=test&priority="+tc.Priority, bytes.NewBufferString("hello"))
+
+		b.EventHandler(w, r)
+		assert.Equal(t, tc.ExpectedCode, w.Code)
+
+		if tc.ExpectedCode == http.StatusOK {
+			events, err := c.Wait(1, time.Second)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedPriority, events[0].Priority)
+		}
+
+		c.Close()
+		b.Close()
+	}
+}
+
+func TestBroker_Protocol(t *testing.T) {
+	tt := []struct {
+		Name               string
+		Proto              string
+		ProtoMajor         int
+		ExpectedConnection string
+		ExpectedFlushes    int
+	}{
+		{
+			Name:               "It should keep HTTP/1.1 connections alive",
+			Proto:              "HTTP/1.1",
+			ProtoMajor:         1,
+			ExpectedConnection: "keep-alive",
+		},
+		{
+			Name:            "It should send headers immediately without connection headers over HTTP/2",
+			Proto:           "HTTP/2.0",
+			ProtoMajor:      2,
+			ExpectedFlushes: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil)
+			defer b.Close()
+
+			w := ssetest.NewStreamRecorder()
+			r := w.NewRequest("GET", "/connect?id=test", nil)
+			r.Proto, r.ProtoMajor = tc.Proto, tc.ProtoMajor
+			done := make(chan struct{})
+
+			go func() {
+				b.ClientHandler(w, r)
+				close(done)
+			}()
+
+			<-time.Tick(time.Millisecond * 100)
+
+			assert.Equal(t, tc.ExpectedFlushes, w.Flushes())
+			assert.Equal(t, map[string]int{tc.Proto: 1}, b.Stats().Protocols)
+
+			// The handler owns the headers until it returns.
+			w.Close()
+			<-done
+
+			assert.Equal(t, tc.ExpectedConnection, w.Header().Get("Connection"))
+		})
+	}
+}
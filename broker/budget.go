@@ -0,0 +1,176 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"golang.org/x/sync/errgroup"
+)
+
+type (
+	// The ErrorBudget type limits how long a broadcast can take, so that callers broadcasting from
+	// within request handlers have predictable latency. See the BroadcastWithin method.
+	ErrorBudget struct {
+		MaxFailures int           // Return once this many clients could not be written to. Zero means no limit.
+		Timeout     time.Duration // Return once this much time has passed. Zero means no limit.
+		Concurrency int           // The number of clients written to at once. Defaults to the number of shards.
+	}
+)
+
+var (
+	// ErrBudgetExceeded is the error returned when a broadcast returns early because its error
+	// budget was exceeded. Errors from the clients that could not be written to are appended.
+	ErrBudgetExceeded = errors.New("broadcast error budget exceeded")
+)
+
+// BroadcastWithin writes the given event in the same way as the BroadcastEvent method, returning
+// early once the budget's maximum number of failures or its timeout is reached. Clients that have
+// not been written to when the budget is exceeded do not receive the event, while writes that are
+// already underway finish in the background. If the budget is exceeded, the returned error wraps
+// ErrBudgetExceeded.
+func (b *defaultBroker) BroadcastWithin(e event.Event, budget ErrorBudget) error {
+	if !b.limiter.allow(b.clock.Now()) {
+		return ErrRateLimited
+	}
+
+	if err := b.admit(); err != nil {
+		return err
+	}
+
+	e, err := b.intercept(e)
+
+	if err == ErrDropEvent {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	group, err := b.group(e)
+
+	if err != nil {
+		return err
+	}
+
+	return b.broadcast(group, e, &budget)
+}
+
+// broadcastWithin writes the event to every client in the group using an errgroup with bounded
+// concurrency, returning early if the budget is exceeded. Clients that exceed their error tolerance
+// after it has returned are passed to 'evict'.
+func (f *fanout) broadcastWithin(e event.Event, hook DeliveryHook, budget ErrorBudget, evict func(*client.Client)) delivery {
+	parent, cancel := context.WithCancel(context.Background())
+	stop := cancel
+
+	if budget.Timeout > 0 {
+		parent, stop = context.WithTimeout(parent, budget.Timeout)
+	}
+
+	g, ctx := errgroup.WithContext(parent)
+
+	if budget.Concurrency <= 0 {
+		budget.Concurrency = len(f.shards)
+	}
+
+	g.SetLimit(budget.Concurrency)
+
+	var (
+		mux      sync.Mutex
+		out      delivery
+		failures int
+		returned bool
+		once     sync.Once
+	)
+
+	exceeded := make(chan struct{})
+	done := make(chan struct{})
+	except := newExclusion(e.Except)
+
+	go func() {
+		defer cancel()
+		defer stop()
+		defer close(done)
+
+		for _, s := range f.shards {
+			for _, c := range s.list() {
+				s, c := s, c
+
+				if except.contains(c.ID()) {
+					mux.Lock()
+					out.excluded++
+					mux.Unlock()
+
+					continue
+				}
+
+				g.Go(func() error {
+					// Once the budget is exceeded, the remaining clients are skipped.
+					if ctx.Err() != nil {
+						return nil
+					}
+
+					err := s.write(c, e, hook)
+
+					mux.Lock()
+					defer mux.Unlock()
+
+					if err == nil {
+						if !returned {
+							out.delivered++
+						}
+
+						return nil
+					}
+
+					// If the broadcast has already returned, the broker can no longer
+					// be told about the eviction through its result.
+					if returned {
+						if c.ShouldDisconnect() {
+							evict(c)
+						}
+
+						return nil
+					}
+
+					out.errors = append(out.errors, err.Error())
+
+					if c.ShouldDisconnect() {
+						out.evicted = append(out.evicted, c)
+					}
+
+					if failures++; budget.MaxFailures > 0 && failures >= budget.MaxFailures {
+						once.Do(func() { close(exceeded) })
+						return ErrBudgetExceeded
+					}
+
+					return nil
+				})
+			}
+		}
+
+		g.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-exceeded:
+		out.exceeded = true
+	case <-parent.Done():
+		// The parent is also cancelled once every client has been written to.
+		select {
+		case <-done:
+		default:
+			out.exceeded = true
+		}
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	returned = true
+
+	return out
+}
@@ -0,0 +1,76 @@
+package broker_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_BroadcastWithin(t *testing.T) {
+	tt := []struct {
+		Name           string
+		Budget         broker.ErrorBudget
+		Healthy        int
+		Stuck          int
+		ExpectExceeded bool
+		MaxDuration    time.Duration
+	}{
+		{
+			Name:        "It should write to every client within the budget",
+			Budget:      broker.ErrorBudget{MaxFailures: 1, Timeout: time.Second},
+			Healthy:     10,
+			MaxDuration: time.Millisecond * 500,
+		},
+		{
+			Name:           "It should return after the maximum number of failures",
+			Budget:         broker.ErrorBudget{MaxFailures: 2, Concurrency: 2},
+			Healthy:        2,
+			Stuck:          10,
+			ExpectExceeded: true,
+			MaxDuration:    time.Millisecond * 900,
+		},
+		{
+			Name:           "It should return once the timeout is reached",
+			Budget:         broker.ErrorBudget{Timeout: time.Millisecond * 200, Concurrency: 1},
+			Stuck:          10,
+			ExpectExceeded: true,
+			MaxDuration:    time.Millisecond * 900,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil)
+			defer b.Close()
+
+			for i := 0; i < tc.Healthy; i++ {
+				c := client.NewWithOptions(time.Millisecond*500, 3, fmt.Sprintf("healthy-%v", i), client.WithQueueSize(10))
+				assert.NoError(t, b.Subscribe(c))
+			}
+
+			// Clients that never take events from their queue block each write
+			// until the timeout.
+			for i := 0; i < tc.Stuck; i++ {
+				c := client.New(time.Millisecond*500, 3, fmt.Sprintf("stuck-%v", i))
+				assert.NoError(t, b.Subscribe(c))
+			}
+
+			started := time.Now()
+			err := b.BroadcastWithin(event.Event{Data: []byte("hello")}, tc.Budget)
+
+			assert.True(t, time.Since(started) < tc.MaxDuration, time.Since(started).String())
+
+			if tc.ExpectExceeded {
+				assert.True(t, errors.Is(err, broker.ErrBudgetExceeded), err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
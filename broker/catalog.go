@@ -0,0 +1,129 @@
+package broker
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+type (
+	// Visibility determines whether a topic is listed by the CatalogHandler.
+	Visibility string
+
+	// The TopicInfo type describes a topic registered in the broker's catalog, so that front-end
+	// teams can discover which streams exist & what their events contain.
+	TopicInfo struct {
+		Name        string          `json:"name"`                  // The name clients subscribe to the topic using.
+		Description string          `json:"description,omitempty"` // What the topic's events describe.
+		Schema      json.RawMessage `json:"schema,omitempty"`      // The schema of the data of the topic's events, such as a JSON schema.
+		Visibility  Visibility      `json:"visibility,omitempty"`  // Whether the topic is listed by the CatalogHandler. Defaults to public.
+		Subscribers int             `json:"subscribers"`           // The number of clients currently subscribed to the topic. Set by the broker.
+	}
+
+	// The catalog type holds the topics registered with the broker, by name.
+	catalog struct {
+		mux    sync.RWMutex
+		topics map[string]TopicInfo
+	}
+)
+
+const (
+	// VisibilityPublic topics are listed by the CatalogHandler. Topics without a visibility are public.
+	VisibilityPublic Visibility = "public"
+
+	// VisibilityPrivate topics are only returned by the broker's Catalog method, for topics used
+	// internally or not yet ready to be consumed.
+	VisibilityPrivate Visibility = "private"
+)
+
+// WithCatalog configures the broker's catalog with the given topics, in the same way as calling the
+// RegisterTopic method for each of them.
+func WithCatalog(topics ...TopicInfo) Option {
+	return func(b *defaultBroker) {
+		for _, info := range topics {
+			b.catalog.register(info)
+		}
+	}
+}
+
+// RegisterTopic adds the topic to the broker's catalog, replacing any topic registered with the same
+// name. Registering a topic does not restrict the topics clients can subscribe or publish to, it
+// only describes them, see the broker's Catalog method & the CatalogHandler function.
+func (b *defaultBroker) RegisterTopic(info TopicInfo) {
+	b.catalog.register(info)
+}
+
+// Catalog returns the topics registered with the broker, including private topics, sorted by name.
+// Each topic's Subscribers field is set to the number of clients currently subscribed to it.
+func (b *defaultBroker) Catalog() []TopicInfo {
+	topics := b.catalog.list()
+
+	b.topicsMux.RLock()
+	defer b.topicsMux.RUnlock()
+
+	for i, info := range topics {
+		if topic, ok := b.topics[info.Name]; ok {
+			topics[i].Subscribers = topic.len()
+		}
+	}
+
+	return topics
+}
+
+// CatalogHandler returns an http.Handler serving the public topics in the broker's catalog as a JSON
+// array of TopicInfo, sorted by name, so that front-end teams can discover the streams that exist.
+// Private topics are not listed.
+//
+// Example using http (https://golang.org/pkg/net/http/)
+//
+// http.Handle("/topics", broker.CatalogHandler(b))
+func CatalogHandler(b Broker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		topics := make([]TopicInfo, 0)
+
+		for _, info := range b.Catalog() {
+			if info.Visibility != VisibilityPrivate {
+				topics = append(topics, info)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(topics)
+	})
+}
+
+// register adds the topic to the catalog, creating the catalog if this is its first topic.
+func (c *catalog) register(info TopicInfo) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.topics == nil {
+		c.topics = make(map[string]TopicInfo)
+	}
+
+	if info.Visibility == "" {
+		info.Visibility = VisibilityPublic
+	}
+
+	info.Subscribers = 0
+	c.topics[info.Name] = info
+}
+
+// list returns the registered topics sorted by name.
+func (c *catalog) list() []TopicInfo {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	out := make([]TopicInfo, 0, len(c.topics))
+
+	for _, info := range c.topics {
+		out = append(out, info)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
@@ -0,0 +1,56 @@
+package broker_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Catalog(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithCatalog(
+		broker.TopicInfo{Name: "news", Description: "Breaking news", Schema: json.RawMessage(`{"type":"object"}`)},
+		broker.TopicInfo{Name: "audit", Visibility: broker.VisibilityPrivate},
+	))
+	defer brk.Close()
+
+	brk.RegisterTopic(broker.TopicInfo{Name: "alerts", Description: "System alerts"})
+
+	assert.NoError(t, brk.Subscribe(client.NewWithOptions(time.Second, 3, "", client.WithTopics("news"), client.WithQueueSize(10))))
+
+	expected := []broker.TopicInfo{
+		{Name: "alerts", Description: "System alerts", Visibility: broker.VisibilityPublic},
+		{Name: "audit", Visibility: broker.VisibilityPrivate},
+		{Name: "news", Description: "Breaking news", Schema: json.RawMessage(`{"type":"object"}`), Visibility: broker.VisibilityPublic, Subscribers: 1},
+	}
+
+	assert.Equal(t, expected, brk.Catalog())
+
+	// Private topics are not listed by the handler.
+	w := httptest.NewRecorder()
+	broker.CatalogHandler(brk).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/topics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var listed []broker.TopicInfo
+
+	if assert.NoError(t, json.NewDecoder(w.Body).Decode(&listed)) {
+		assert.Equal(t, []broker.TopicInfo{expected[0], expected[2]}, listed)
+	}
+}
+
+func TestCatalogHandler_Empty(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil)
+	defer brk.Close()
+
+	w := httptest.NewRecorder()
+	broker.CatalogHandler(brk).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/topics", nil))
+
+	assert.Equal(t, "[]\n", w.Body.String())
+}
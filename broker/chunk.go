@@ -0,0 +1,37 @@
+package broker
+
+import (
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+	"github.com/rs/xid"
+)
+
+// WithChunking configures the broker to split events with data larger than 'size' bytes into
+// several events that are written to clients separately, so that a large payload does not
+// delay higher priority events queued behind it. Each chunk contains a 'chunk' field that
+// consumers use to reassemble the payload, see the protocol.Reassembler type. Events are
+// stored & replayed whole. The size is measured before any compression is applied. If 'size'
+// is zero, events are not split.
+func WithChunking(size int) Option {
+	return func(b *defaultBroker) {
+		b.chunkSize = size
+	}
+}
+
+// chunk splits the event into chunks if its data is larger than the configured chunk size, appending
+// them to 'dst'. The chunks are correlated using the event's identifier, or a random one if it has
+// none. Events that are not split are appended as-is, so that callers can pass a buffer on the stack
+// to avoid allocating.
+func (b *defaultBroker) chunk(dst []event.Event, e event.Event) []event.Event {
+	if b.chunkSize <= 0 || len(e.Data) <= b.chunkSize {
+		return append(dst, e)
+	}
+
+	id := e.ID
+
+	if id == "" {
+		id = xid.New().String()
+	}
+
+	return append(dst, protocol.Split(e, b.chunkSize, id)...)
+}
@@ -0,0 +1,57 @@
+package broker_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithChunking(t *testing.T) {
+	tt := []struct {
+		Size           int
+		Data           string
+		ExpectedChunks int
+	}{
+		{Size: 4, Data: "hello world", ExpectedChunks: 3},
+		{Size: 16, Data: "hello world", ExpectedChunks: 1},
+		{Data: strings.Repeat("a", 1024), ExpectedChunks: 1},
+	}
+
+	for _, tc := range tt {
+		b := broker.New(time.Second, 3, nil, broker.WithChunking(tc.Size), broker.WithQueueSize(10))
+		w := ssetest.NewStreamRecorder()
+
+		go b.ClientHandler(w, w.NewRequest("GET", "/connect?id=test", nil))
+		<-time.Tick(time.Second)
+
+		assert.NoError(t, b.BroadcastEvent(event.Event{ID: "1", Data: []byte(tc.Data)}))
+
+		events, err := w.WaitForEvents(tc.ExpectedChunks, time.Second)
+		assert.NoError(t, err)
+		assert.Len(t, events, tc.ExpectedChunks)
+
+		r := protocol.NewReassembler()
+
+		for i, e := range events {
+			out, ok := r.Add(e)
+
+			if i < len(events)-1 {
+				assert.False(t, ok)
+				continue
+			}
+
+			assert.True(t, ok)
+			assert.Equal(t, "1", out.ID)
+			assert.Equal(t, tc.Data, string(out.Data))
+		}
+
+		w.Close()
+		b.Close()
+	}
+}
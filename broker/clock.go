@@ -0,0 +1,19 @@
+package broker
+
+import (
+	"github.com/davidsbond/sse/clock"
+)
+
+// WithClock configures the broker to use the given clock for timestamps, heartbeats, write timeouts,
+// connection & session expiry, scheduled or periodic events, system events, exchanging cluster
+// membership, pushing statistics, retrying requests to the collector & webhooks, and compacting
+// stores that implement store.ClockedStore, so that tests can control the passage of time, see
+// the ssetest.Clock type. The timeouts of HTTP requests always use the system time. If 'c' is nil,
+// the system time is used.
+func WithClock(c clock.Clock) Option {
+	return func(b *defaultBroker) {
+		if c != nil {
+			b.clock = c
+		}
+	}
+}
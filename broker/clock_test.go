@@ -0,0 +1,51 @@
+package broker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := ssetest.NewClock(start)
+
+	brk := broker.New(time.Second, 3, nil, broker.WithClock(clk))
+	defer brk.Close()
+
+	c := client.NewWithOptions(time.Second, 3, "", client.WithQueueSize(10))
+	assert.NoError(t, brk.Subscribe(c))
+
+	scheduled := brk.BroadcastAfter(time.Hour, event.Event{Data: []byte("later")})
+
+	// Wait for the broker to start waiting on the clock.
+	assert.True(t, clk.WaitForTimers(1, time.Second))
+
+	clk.Advance(time.Minute * 59)
+
+	select {
+	case <-scheduled.Done():
+		t.Fatal("event was broadcast early")
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	clk.Advance(time.Minute)
+
+	select {
+	case <-scheduled.Done():
+	case <-time.After(time.Second):
+		t.Fatal("event was not broadcast")
+	}
+
+	assert.NoError(t, scheduled.Err())
+
+	e, ok := c.Next()
+	assert.True(t, ok)
+	assert.Equal(t, "later", string(e.Data))
+	assert.Equal(t, start.Add(time.Hour), e.Timestamp)
+}
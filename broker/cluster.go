@@ -0,0 +1,524 @@
+package broker
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The ClusterConfig type configures how a broker joins a cluster of brokers. Members of a
+	// cluster exchange their membership with each other & use consistent hashing to assign
+	// each client identifier to a member, which records where that client is connected. This
+	// allows an event sent to a single client to be routed to the member holding its connection,
+	// wherever the load balancer placed it.
+	ClusterConfig struct {
+		Advertise string        // The URL at which other members can reach this broker's ClusterHandler.
+		Peers     []string      // The URLs of the ClusterHandlers of one or more existing members.
+		Interval  time.Duration // How often membership is exchanged with other members. Defaults to one second.
+		Secret    string        // Shared by every member & sent with each request between them, so that only members can make them.
+	}
+
+	// The cluster type contains the broker's view of the cluster it belongs to.
+	cluster struct {
+		self     string
+		peers    []string
+		interval time.Duration
+		secret   string
+		client   *http.Client
+		local    func() []string
+		clock    clock.Clock
+
+		mux       sync.RWMutex
+		members   map[string]time.Time // The latest time each member reported, using its own clock.
+		seen      map[string]time.Time // When each member's reported time last advanced, using this member's clock.
+		expired   map[string]time.Time // The last time reported by each member that has been removed.
+		ring      *hashRing
+		locations map[string]string
+
+		done   chan struct{}
+		closed chan struct{}
+		once   sync.Once
+	}
+
+	// The membership type is exchanged between members, containing the latest time each member
+	// reported being alive, according to its own clock.
+	membership struct {
+		Members map[string]time.Time `json:"members"`
+	}
+)
+
+const (
+	// The number of intervals a member can go without being heard from before it is removed
+	// from the cluster.
+	clusterExpiry = 3
+
+	// The header containing the cluster's shared secret in requests between members.
+	clusterSecretHeader = "X-SSE-Cluster-Secret"
+)
+
+var (
+	errUnknownClient = errors.New("unknown client")
+)
+
+// WithCluster configures the broker to join a cluster of brokers. Members periodically exchange
+// their membership using each other's ClusterHandler, which must be registered at the advertised
+// URL. When an event is sent to a client that isn't connected to this broker, it is routed to the
+// member holding the client's connection. Requests between members must carry the cluster's
+// secret. If 'cfg.Secret' is blank, they are authorized using the broker's Authorizer instead, &
+// refused if the broker has none, see the broker.WithAuthorizer method. If 'cfg.Advertise' is
+// blank, this option does nothing.
+func WithCluster(cfg ClusterConfig) Option {
+	return func(b *defaultBroker) {
+		if cfg.Advertise == "" {
+			return
+		}
+
+		b.clusterConfig = cfg
+	}
+}
+
+func newCluster(cfg ClusterConfig, client *http.Client, local func() []string, clk clock.Clock) *cluster {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+
+	now := clk.Now()
+	c := &cluster{
+		self:      cfg.Advertise,
+		peers:     cfg.Peers,
+		interval:  cfg.Interval,
+		secret:    cfg.Secret,
+		client:    client,
+		local:     local,
+		clock:     clk,
+		members:   map[string]time.Time{cfg.Advertise: now},
+		seen:      map[string]time.Time{cfg.Advertise: now},
+		expired:   make(map[string]time.Time),
+		locations: make(map[string]string),
+		done:      make(chan struct{}),
+		closed:    make(chan struct{}),
+	}
+
+	c.ring = newHashRing(c.memberList())
+
+	go c.run()
+
+	return c
+}
+
+// ClusterHandler is an HTTP handler used by the members of a cluster to exchange membership &
+// route events to each other. It should be registered at the URL advertised to other members,
+// see the broker.WithCluster method. If the broker is not part of a cluster, the handler responds
+// with a 501 status code.
+func (b *defaultBroker) ClusterHandler(w http.ResponseWriter, r *http.Request) {
+	if b.cluster == nil {
+		b.httpError(w, r, CodeClusterUnavailable, errors.New("the broker is not part of a cluster"), http.StatusNotImplemented)
+		return
+	}
+
+	if !b.authorizeMember(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	id := query.Get("id")
+
+	switch query.Get("op") {
+	case "gossip":
+		var in membership
+
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			b.httpError(w, r, CodeInvalidClusterRequest, err, http.StatusBadRequest)
+			return
+		}
+
+		b.cluster.merge(in.Members, b.clock.Now())
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(membership{Members: b.cluster.snapshot()})
+	case "register":
+		b.cluster.locate(id, query.Get("member"))
+		w.WriteHeader(http.StatusOK)
+	case "unregister":
+		b.cluster.forget(id, query.Get("member"))
+		w.WriteHeader(http.StatusOK)
+	case "route", "deliver":
+		var e event.Event
+
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			b.httpError(w, r, CodeInvalidEvent, err, http.StatusBadRequest)
+			return
+		}
+
+		// Events are delivered to the members holding the client's connection, and routed
+		// by the member assigned its identifier. Routed events are never routed again, so
+		// members with a different view of the cluster cannot route an event in a loop.
+		err := b.sendLocal(id, e)
+
+		if err == errUnknownClient && query.Get("op") == "route" {
+			err = b.cluster.forward(id, e)
+		}
+
+		switch {
+		case err == errUnknownClient:
+			b.httpError(w, r, CodeUnknownClient, fmt.Errorf("no client with id %v exists", id), http.StatusNotFound)
+		case err != nil:
+			b.httpError(w, r, CodePublishFailed, err, http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	default:
+		b.httpError(w, r, CodeInvalidClusterRequest, fmt.Errorf("unknown operation %v", query.Get("op")), http.StatusBadRequest)
+	}
+}
+
+// authorizeMember determines if the request was made by another member of the cluster, responding
+// with an error if it was not.
+func (b *defaultBroker) authorizeMember(w http.ResponseWriter, r *http.Request) bool {
+	if b.cluster.secret != "" {
+		secret := r.Header.Get(clusterSecretHeader)
+
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(b.cluster.secret)) != 1 {
+			b.httpError(w, r, CodeUnauthorized, errors.New("the request does not contain the cluster's secret"), http.StatusUnauthorized)
+			return false
+		}
+
+		return true
+	}
+
+	if b.authorizer == nil {
+		b.httpError(w, r, CodeUnauthorized, errors.New("the cluster has no secret & the broker has no authorizer"), http.StatusUnauthorized)
+		return false
+	}
+
+	return b.authorize(w, r)
+}
+
+// localClients returns the identifiers of the clients connected to the broker.
+func (b *defaultBroker) localClients() []string {
+	var out []string
+
+	b.clients.Range(func(key, value interface{}) bool {
+		out = append(out, key.(string))
+		return true
+	})
+
+	return out
+}
+
+// register records where the client is connected with the member assigned its identifier.
+func (c *cluster) register(id string) {
+	if owner := c.owner(id); owner == c.self {
+		c.locate(id, c.self)
+	} else if owner != "" {
+		c.post(owner, "register", id, nil)
+	}
+}
+
+// unregister removes the record of where the client is connected from the member assigned
+// its identifier.
+func (c *cluster) unregister(id string) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+
+	if owner := c.owner(id); owner == c.self {
+		c.forget(id, c.self)
+	} else if owner != "" {
+		c.post(owner, "unregister", id, nil)
+	}
+}
+
+// route sends the event to the member holding the connection of the client with the given
+// identifier, via the member assigned the identifier if it is not this one.
+func (c *cluster) route(id string, e event.Event) error {
+	if owner := c.owner(id); owner != c.self {
+		return c.post(owner, "route", id, &e)
+	}
+
+	return c.forward(id, e)
+}
+
+// forward sends the event to the member recorded as holding the connection of the client with
+// the given identifier.
+func (c *cluster) forward(id string, e event.Event) error {
+	c.mux.RLock()
+	member, ok := c.locations[id]
+	c.mux.RUnlock()
+
+	if !ok || member == c.self {
+		return errUnknownClient
+	}
+
+	return c.post(member, "deliver", id, &e)
+}
+
+// owner returns the member assigned the given client identifier.
+func (c *cluster) owner(id string) string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return c.ring.owner(id)
+}
+
+// locate records that the client with the given identifier is connected to the member.
+func (c *cluster) locate(id, member string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.locations[id] = member
+}
+
+// forget removes the record of the client's connection, unless it has since connected to a
+// different member.
+func (c *cluster) forget(id, member string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.locations[id] == member {
+		delete(c.locations, id)
+	}
+}
+
+func (c *cluster) run() {
+	defer close(c.closed)
+
+	ticker := c.clock.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	// Exchange membership straight away, so that joining the cluster is quick.
+	c.exchange()
+
+	for {
+		select {
+		case <-ticker.C():
+			c.exchange()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// exchange sends this member's view of the cluster to every other known member & the peers it
+// was configured with, merging their views into its own.
+func (c *cluster) exchange() {
+	targets := map[string]struct{}{}
+
+	for _, peer := range c.peers {
+		targets[peer] = struct{}{}
+	}
+
+	for _, member := range c.memberList() {
+		targets[member] = struct{}{}
+	}
+
+	delete(targets, c.self)
+
+	for target := range targets {
+		c.mux.Lock()
+		c.members[c.self] = c.clock.Now()
+		c.mux.Unlock()
+
+		body, _ := json.Marshal(membership{Members: c.snapshot()})
+		resp, err := c.do(target, "gossip", "", body)
+
+		if err != nil {
+			continue
+		}
+
+		var in membership
+
+		if resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&in) == nil {
+			c.merge(in.Members, c.clock.Now())
+		}
+
+		resp.Body.Close()
+	}
+
+	c.merge(nil, c.clock.Now())
+}
+
+// merge combines the given view of the cluster with this member's, keeping the most recent
+// time each member reported & removing members that haven't been heard from recently. Each
+// member only reports times from its own clock, which may differ from this member's, so members
+// are expired using the time their reports were received rather than the times they reported.
+// If the membership changes, clients are reassigned to members.
+func (c *cluster) merge(members map[string]time.Time, now time.Time) {
+	c.mux.Lock()
+
+	before := len(c.members)
+	added := false
+
+	for member, reported := range members {
+		current, ok := c.members[member]
+
+		// Removed members are only added again once they report a later time, so that members
+		// still relaying their last report do not keep them in the cluster.
+		if last, removed := c.expired[member]; !ok && removed && !reported.After(last) {
+			continue
+		}
+
+		if !ok {
+			added = true
+		}
+
+		if !ok || reported.After(current) {
+			c.members[member] = reported
+			c.seen[member] = now
+			delete(c.expired, member)
+		}
+	}
+
+	c.members[c.self] = now
+	c.seen[c.self] = now
+
+	for member, seen := range c.seen {
+		if member != c.self && now.Sub(seen) > c.interval*clusterExpiry {
+			c.expired[member] = c.members[member]
+			delete(c.members, member)
+			delete(c.seen, member)
+		}
+	}
+
+	changed := added || len(c.members) != before
+
+	if changed {
+		c.ring = newHashRing(c.memberListLocked())
+
+		// Discard the locations of clients that are now assigned to other members, they
+		// will be registered with them again.
+		for id := range c.locations {
+			if c.ring.owner(id) != c.self {
+				delete(c.locations, id)
+			}
+		}
+	}
+
+	c.mux.Unlock()
+
+	if changed {
+		for _, id := range c.local() {
+			c.register(id)
+		}
+	}
+}
+
+// snapshot returns a copy of the latest time each member reported.
+func (c *cluster) snapshot() map[string]time.Time {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	out := make(map[string]time.Time, len(c.members))
+
+	for member, seen := range c.members {
+		out[member] = seen
+	}
+
+	return out
+}
+
+// memberList returns the URLs of the known members, in order.
+func (c *cluster) memberList() []string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return c.memberListLocked()
+}
+
+// memberListLocked returns the URLs of the known members, in order. It must be called while
+// holding the cluster's lock.
+func (c *cluster) memberListLocked() []string {
+	out := make([]string, 0, len(c.members))
+
+	for member := range c.members {
+		out = append(out, member)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
+// post sends a request for the given operation to another member. If the member has no client
+// with the given identifier, errUnknownClient is returned.
+func (c *cluster) post(member, op, id string, e *event.Event) error {
+	var body []byte
+
+	if e != nil {
+		body, _ = json.Marshal(e)
+	}
+
+	resp, err := c.do(member, op, id, body)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errUnknownClient
+	case resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("cluster request %v to member %v failed: %v", op, member, resp.Status)
+	default:
+		return nil
+	}
+}
+
+// do sends a request for the given operation to the member's ClusterHandler, including the
+// cluster's secret.
+func (c *cluster) do(member, op, id string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, c.endpoint(member, op, id), bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.secret != "" {
+		req.Header.Set(clusterSecretHeader, c.secret)
+	}
+
+	return c.client.Do(req)
+}
+
+// endpoint returns the URL of the member's ClusterHandler for the given operation.
+func (c *cluster) endpoint(member, op, id string) string {
+	parsed, err := url.Parse(member)
+
+	if err != nil {
+		return member
+	}
+
+	query := parsed.Query()
+	query.Set("op", op)
+	query.Set("member", c.self)
+
+	if id != "" {
+		query.Set("id", id)
+	}
+
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+func (c *cluster) close() {
+	c.once.Do(func() { close(c.done) })
+	<-c.closed
+}
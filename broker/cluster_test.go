@@ -0,0 +1,205 @@
+package broker_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// The skewedClock type is a clock that is ahead of or behind the system time, such as
+	// the clock of another machine.
+	skewedClock struct {
+		clock.Clock
+		skew time.Duration
+	}
+
+	ClusterMember struct {
+		Broker broker.Broker
+		Server *httptest.Server
+		ready  chan struct{}
+	}
+)
+
+func (c skewedClock) Now() time.Time {
+	return c.Clock.Now().Add(c.skew)
+}
+
+func NewClusterMember(peers ...string) *ClusterMember {
+	return NewClusterMemberWithOptions(peers)
+}
+
+func NewClusterMemberWithOptions(peers []string, opts ...broker.Option) *ClusterMember {
+	m := &ClusterMember{ready: make(chan struct{})}
+
+	// The broker needs to know its own URL, so wait for it to be created before
+	// handling any requests.
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-m.ready
+		m.Broker.ClusterHandler(w, r)
+	}))
+
+	opts = append(opts, broker.WithCluster(broker.ClusterConfig{
+		Advertise: m.Server.URL,
+		Peers:     peers,
+		Interval:  time.Millisecond * 100,
+		Secret:    "secret",
+	}))
+
+	m.Broker = broker.New(time.Second, 3, nil, opts...)
+
+	close(m.ready)
+
+	return m
+}
+
+func (m *ClusterMember) Close() {
+	m.Broker.Close()
+	m.Server.Close()
+}
+
+func TestBroker_WithCluster(t *testing.T) {
+	first := NewClusterMember()
+	members := []*ClusterMember{first, NewClusterMember(first.Server.URL), NewClusterMember(first.Server.URL)}
+
+	defer func() {
+		for _, m := range members {
+			m.Close()
+		}
+	}()
+
+	<-time.Tick(time.Second)
+
+	// Every member should have learned of every other member.
+	for _, m := range members {
+		assert.Len(t, m.Broker.Stats().Members, len(members))
+	}
+
+	clients := make([]*ssetest.Client, len(members))
+
+	for i, m := range members {
+		clients[i] = ssetest.NewClient(fmt.Sprintf("client-%v", i))
+		defer clients[i].Close()
+
+		assert.NoError(t, m.Broker.Subscribe(clients[i].Client))
+	}
+
+	<-time.Tick(time.Second)
+
+	// The goroutines registering the clients with the cluster have finished.
+	for _, m := range members {
+		assert.Equal(t, int64(0), m.Broker.Stats().Resources.Goroutines)
+	}
+
+	// Events sent from any member reach the client, wherever it is connected.
+	for _, m := range members {
+		for i := range clients {
+			assert.NoError(t, m.Broker.BroadcastTo(fmt.Sprintf("client-%v", i), []byte("hello")))
+		}
+
+		err := m.Broker.BroadcastTo("missing", []byte("hello"))
+
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "no client with id")
+		}
+	}
+
+	for _, c := range clients {
+		_, err := c.Wait(len(members), time.Second)
+		assert.NoError(t, err)
+	}
+
+	// Members that leave are removed from the cluster.
+	members[2].Close()
+	members = members[:2]
+
+	<-time.Tick(time.Second)
+
+	for _, m := range members {
+		assert.Len(t, m.Broker.Stats().Members, len(members))
+	}
+}
+
+func TestBroker_WithClusterClockSkew(t *testing.T) {
+	tt := []struct {
+		Name string
+		Skew time.Duration
+	}{
+		{Name: "It should keep members whose clocks are behind", Skew: -time.Hour},
+		{Name: "It should remove members whose clocks are ahead once they leave", Skew: time.Hour},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			first := NewClusterMember()
+			defer first.Close()
+
+			skewed := NewClusterMemberWithOptions([]string{first.Server.URL}, broker.WithClock(skewedClock{Clock: clock.Real(), skew: tc.Skew}))
+
+			<-time.After(time.Second)
+
+			assert.Len(t, first.Broker.Stats().Members, 2)
+			assert.Len(t, skewed.Broker.Stats().Members, 2)
+
+			skewed.Close()
+			<-time.After(time.Second)
+
+			assert.Equal(t, []string{first.Server.URL}, first.Broker.Stats().Members)
+		})
+	}
+}
+
+func TestBroker_ClusterHandler(t *testing.T) {
+	tt := []struct {
+		Cluster      bool
+		Secret       string
+		Authorizer   broker.Authorizer
+		Header       string
+		Query        string
+		ExpectedCode int
+	}{
+		{Query: "?op=gossip", ExpectedCode: http.StatusNotImplemented},
+		{Cluster: true, Secret: "secret", Header: "secret", Query: "?op=unknown", ExpectedCode: http.StatusBadRequest},
+		{Cluster: true, Secret: "secret", Header: "secret", Query: "?op=deliver&id=missing", ExpectedCode: http.StatusBadRequest},
+		{Cluster: true, Secret: "secret", Header: "guess", Query: "?op=register&id=a&member=http://evil", ExpectedCode: http.StatusUnauthorized},
+		{Cluster: true, Query: "?op=register&id=a&member=http://evil", ExpectedCode: http.StatusUnauthorized},
+		{
+			Cluster:      true,
+			Authorizer:   func(r *http.Request) error { return nil },
+			Query:        "?op=register&id=a&member=http://localhost:1",
+			ExpectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		var opts []broker.Option
+
+		if tc.Cluster {
+			opts = append(opts, broker.WithCluster(broker.ClusterConfig{Advertise: "http://localhost:0", Secret: tc.Secret}))
+		}
+
+		if tc.Authorizer != nil {
+			opts = append(opts, broker.WithAuthorizer(tc.Authorizer))
+		}
+
+		b := broker.New(time.Second, 3, nil, opts...)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/cluster"+tc.Query, nil)
+
+		if tc.Header != "" {
+			r.Header.Set("X-SSE-Cluster-Secret", tc.Header)
+		}
+
+		b.ClusterHandler(w, r)
+
+		assert.Equal(t, tc.ExpectedCode, w.Code)
+		b.Close()
+	}
+}
@@ -0,0 +1,78 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/davidsbond/sse/clock"
+)
+
+type (
+	// The coalescer type delays flushing a client's stream so that all events written
+	// within the coalescing window are flushed together, reducing the number of syscalls
+	// made under high event rates.
+	coalescer struct {
+		window time.Duration
+		flush  func()
+		timer  clock.Timer
+		res    *connResources
+		clock  clock.Clock
+	}
+)
+
+// WithCoalesceWindow configures the broker to flush events written to a client within the
+// given window together, rather than flushing after every event. The first event written
+// to a client starts the window, so no event is delayed by more than 'window'. If 'window'
+// is zero, every event is flushed as soon as it is written.
+func WithCoalesceWindow(window time.Duration) Option {
+	return func(b *defaultBroker) {
+		b.coalesceWindow = window
+	}
+}
+
+// newCoalescer creates a coalescer that flushes using the given function, timing windows using
+// the clock. If 'res' is set, the coalescer's timers are counted against it.
+func newCoalescer(window time.Duration, flush func(), res *connResources, clk clock.Clock) *coalescer {
+	return &coalescer{window: window, flush: flush, res: res, clock: clk}
+}
+
+// written notifies the coalescer that an event has been written to the stream. The stream
+// is flushed immediately if coalescing is disabled, otherwise the window is started if it
+// is not already running.
+func (c *coalescer) written() {
+	if c.window <= 0 {
+		c.flush()
+		return
+	}
+
+	if c.timer == nil {
+		c.timer = c.clock.NewTimer(c.window)
+		c.res.timerStarted()
+	}
+}
+
+// C returns a channel that is signalled once the coalescing window has elapsed and the
+// stream should be flushed. If no window is running, the channel is nil.
+func (c *coalescer) C() <-chan time.Time {
+	if c.timer == nil {
+		return nil
+	}
+
+	return c.timer.C()
+}
+
+// elapsed flushes the stream at the end of a coalescing window.
+func (c *coalescer) elapsed() {
+	c.timer = nil
+	c.res.timerStopped()
+	c.flush()
+}
+
+// stop flushes any events waiting for the window to end.
+func (c *coalescer) stop() {
+	if c.timer == nil {
+		return
+	}
+
+	c.timer.Stop()
+	c.elapsed()
+}
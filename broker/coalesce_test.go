@@ -0,0 +1,43 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithCoalesceWindow(t *testing.T) {
+	tt := []struct {
+		Window          time.Duration
+		Events          int
+		ExpectedFlushes int
+	}{
+		{Window: 0, Events: 3, ExpectedFlushes: 3},
+		{Window: time.Millisecond * 200, Events: 3, ExpectedFlushes: 1},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil, broker.WithCoalesceWindow(tc.Window))
+		w := &FlushRecorder{header: http.Header{}}
+		r := httptest.NewRequest("GET", "/", nil)
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, r)
+		<-time.Tick(time.Second)
+
+		for i := 0; i < tc.Events; i++ {
+			assert.NoError(t, broker.Broadcast([]byte("hello world")))
+		}
+
+		// Wait for the coalescing window to elapse.
+		<-time.After(tc.Window + time.Millisecond*100)
+
+		assert.Equal(t, tc.ExpectedFlushes, w.Flushes())
+		broker.Close()
+	}
+}
@@ -0,0 +1,46 @@
+package broker
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/davidsbond/sse/compress"
+)
+
+// WithCompression configures the broker to compress event streams using one of the given
+// codecs. The codec is negotiated per client using the Accept-Encoding header, preferring
+// codecs in the order they are given. Clients that do not accept any of the codecs receive
+// an uncompressed stream.
+func WithCompression(codecs ...compress.Codec) Option {
+	return func(b *defaultBroker) {
+		b.codecs = codecs
+	}
+}
+
+// compressStream negotiates a codec for the request and, if one is accepted by the client,
+// returns a writer & flush function that compress the stream. The returned close function
+// must be called once the stream has ended.
+func (b *defaultBroker) compressStream(w http.ResponseWriter, r *http.Request, flusher http.Flusher) (io.Writer, func(), func()) {
+	codec := compress.Negotiate(r.Header.Get("Accept-Encoding"), b.codecs)
+
+	if codec == nil {
+		return w, flusher.Flush, func() {}
+	}
+
+	cw, err := codec.NewWriter(w)
+
+	// If we can't create the compressor, fall back to an uncompressed stream.
+	if err != nil {
+		return w, flusher.Flush, func() {}
+	}
+
+	w.Header().Set("Content-Encoding", codec.Name())
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	flush := func() {
+		cw.Flush()
+		flusher.Flush()
+	}
+
+	return cw, flush, func() { cw.Close() }
+}
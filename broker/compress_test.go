@@ -0,0 +1,81 @@
+package broker_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/compress"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithCompression(t *testing.T) {
+	tt := []struct {
+		AcceptEncoding   string
+		ExpectedEncoding string
+		Data             []byte
+	}{
+		{AcceptEncoding: "gzip", ExpectedEncoding: "gzip", Data: []byte("hello world")},
+		{AcceptEncoding: "br", ExpectedEncoding: "", Data: []byte("hello world")},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil, broker.WithCompression(compress.Gzip(gzip.BestSpeed)))
+		server := httptest.NewServer(http.HandlerFunc(broker.ClientHandler))
+
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("Accept-Encoding", tc.AcceptEncoding)
+
+		// Connect to the broker, the response headers are not sent until the first
+		// event is written, so wait for the response in the background.
+		responses := make(chan *http.Response, 1)
+
+		go func() {
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			assert.NoError(t, err)
+			responses <- resp
+		}()
+
+		// Give the broker 1 second to create the client
+		<-time.Tick(time.Second)
+		assert.NoError(t, broker.Broadcast(tc.Data))
+
+		resp := <-responses
+
+		if resp == nil {
+			server.Close()
+			continue
+		}
+
+		assert.Equal(t, tc.ExpectedEncoding, resp.Header.Get("Content-Encoding"))
+
+		var reader *bufio.Reader
+
+		if tc.ExpectedEncoding == "gzip" {
+			gr, err := gzip.NewReader(resp.Body)
+
+			if !assert.NoError(t, err) {
+				resp.Body.Close()
+				server.Close()
+				continue
+			}
+
+			reader = bufio.NewReader(gr)
+		} else {
+			reader = bufio.NewReader(resp.Body)
+		}
+
+		line, err := reader.ReadString('\n')
+
+		assert.NoError(t, err)
+		assert.Equal(t, "data: "+string(tc.Data)+"\n", line)
+
+		broker.Close()
+		resp.Body.Close()
+		server.Close()
+	}
+}
@@ -0,0 +1,60 @@
+package broker
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type (
+	// The ClientInfo type contains details of a client that are derived from its request,
+	// such as those placed in the request context by authentication middleware.
+	ClientInfo struct {
+		ID        string            // The client's identifier. If blank, the 'id' query parameter is used.
+		Topics    []string          // The topics to subscribe the client to. If nil, the 'topic' query parameters are used.
+		Metadata  map[string]string // Arbitrary metadata to associate with the client.
+		Groups    []string          // The groups the client is a member of, such as those in its token's claims. These are never read from the request.
+		Timeout   time.Duration     // If non-zero, overrides how long the broker will wait to write to the client.
+		Tolerance int               // If non-zero, overrides how many sequential errors the client can have before it is disconnected.
+	}
+
+	// ClientFromContext is a function that derives a client's details from the context of
+	// its request.
+	ClientFromContext func(ctx context.Context) ClientInfo
+)
+
+// WithClientFromContext configures the broker to derive the details of connecting clients
+// from values placed in the request context by upstream middleware. Values returned by 'fn'
+// take precedence over those provided in the request's query parameters. If 'fn' is nil,
+// only the query parameters are used.
+func WithClientFromContext(fn ClientFromContext) Option {
+	return func(b *defaultBroker) {
+		b.clientFromContext = fn
+	}
+}
+
+// clientInfo returns the details of the client making the request. An error is returned if the
+// topics it requests cannot be parsed, see the broker.WithSubscriptionParser method.
+func (b *defaultBroker) clientInfo(r *http.Request) (ClientInfo, error) {
+	var info ClientInfo
+
+	if b.clientFromContext != nil {
+		info = b.clientFromContext(r.Context())
+	}
+
+	if info.ID == "" {
+		info.ID = r.URL.Query().Get("id")
+	}
+
+	if info.Topics == nil {
+		topics, err := b.parseTopics(r)
+
+		if err != nil {
+			return info, err
+		}
+
+		info.Topics = topics
+	}
+
+	return info, nil
+}
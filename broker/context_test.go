@@ -0,0 +1,68 @@
+package broker_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	contextKey string
+)
+
+func TestBroker_WithClientFromContext(t *testing.T) {
+	tt := []struct {
+		Query          string
+		ContextUser    string
+		ExpectedID     string
+		ExpectedTopics []string
+	}{
+		{Query: "?id=query&topic=a", ContextUser: "user", ExpectedID: "user", ExpectedTopics: []string{"user"}},
+		{Query: "?id=query&topic=a", ExpectedID: "query", ExpectedTopics: []string{"a"}},
+	}
+
+	for _, tc := range tt {
+		fn := func(ctx context.Context) broker.ClientInfo {
+			user, _ := ctx.Value(contextKey("user")).(string)
+
+			if user == "" {
+				return broker.ClientInfo{}
+			}
+
+			return broker.ClientInfo{
+				ID:       user,
+				Topics:   []string{user},
+				Metadata: map[string]string{"role": "admin"},
+			}
+		}
+
+		broker := broker.New(time.Second, 3, nil, broker.WithClientFromContext(fn))
+		w := &FlushRecorder{header: http.Header{}}
+		r := httptest.NewRequest("GET", "/connect"+tc.Query, nil)
+
+		if tc.ContextUser != "" {
+			r = r.WithContext(context.WithValue(r.Context(), contextKey("user"), tc.ContextUser))
+		}
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, r)
+		<-time.Tick(time.Second)
+
+		assert.NoError(t, broker.BroadcastTo(tc.ExpectedID, []byte("hello")))
+
+		stats := broker.Stats()
+
+		for _, topic := range tc.ExpectedTopics {
+			assert.Equal(t, 1, stats.Topics[topic].Subscribers)
+		}
+
+		assert.Len(t, stats.Topics, len(tc.ExpectedTopics))
+		broker.Close()
+	}
+}
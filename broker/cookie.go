@@ -0,0 +1,113 @@
+package broker
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+type (
+	// The CookieConfig type configures the signed cookie used to give browsers a stable client
+	// identifier across reconnects. See the broker.WithClientCookie method.
+	CookieConfig struct {
+		Secret   []byte        // The key used to sign the cookie. Cookies with an invalid signature are replaced.
+		Name     string        // The name of the cookie. Defaults to 'sse_client_id'.
+		Path     string        // The path the cookie applies to. Defaults to '/'.
+		MaxAge   time.Duration // How long the cookie lasts. If zero, it lasts until the browser is closed.
+		Secure   bool          // Whether the cookie is only sent over HTTPS.
+		SameSite http.SameSite // The SameSite attribute of the cookie. Defaults to Lax.
+	}
+)
+
+const (
+	// The name of the client identifier cookie if one is not configured.
+	defaultCookieName = "sse_client_id"
+)
+
+// WithClientCookie configures the broker to identify clients using a signed cookie, so that browsers
+// keep the same client identifier when their EventSource reconnects without the application adding
+// it to the URL. Clients without a valid cookie are given a new identifier, which is set in the
+// cookie of their response. Identifiers from the request context or the 'id' query parameter take
+// precedence over the cookie. As a reconnecting browser may connect again before its previous
+// connection is noticed to have dropped, consider also using the broker.WithTakeover method. If
+// 'cfg.Secret' is empty, cookies are not used.
+func WithClientCookie(cfg CookieConfig) Option {
+	return func(b *defaultBroker) {
+		if len(cfg.Secret) == 0 {
+			b.cookie = nil
+			return
+		}
+
+		if cfg.Name == "" {
+			cfg.Name = defaultCookieName
+		}
+
+		if cfg.Path == "" {
+			cfg.Path = "/"
+		}
+
+		if cfg.SameSite == 0 {
+			cfg.SameSite = http.SameSiteLaxMode
+		}
+
+		b.cookie = &cfg
+	}
+}
+
+// clientCookie sets the client's identifier from its cookie if it does not already have one. If the
+// request has no valid cookie, a new identifier is issued & set in the response's cookie.
+func (b *defaultBroker) clientCookie(w http.ResponseWriter, r *http.Request, info *ClientInfo) {
+	if b.cookie == nil || info.ID != "" {
+		return
+	}
+
+	if cookie, err := r.Cookie(b.cookie.Name); err == nil {
+		if id, ok := b.cookie.verify(cookie.Value); ok {
+			info.ID = id
+			return
+		}
+	}
+
+	info.ID = xid.New().String()
+
+	cookie := &http.Cookie{
+		Name:     b.cookie.Name,
+		Value:    b.cookie.sign(info.ID),
+		Path:     b.cookie.Path,
+		Secure:   b.cookie.Secure,
+		HttpOnly: true,
+		SameSite: b.cookie.SameSite,
+	}
+
+	if b.cookie.MaxAge > 0 {
+		cookie.MaxAge = int(b.cookie.MaxAge.Seconds())
+	}
+
+	http.SetCookie(w, cookie)
+}
+
+// sign returns the cookie value containing the identifier & its signature.
+func (cfg *CookieConfig) sign(id string) string {
+	mac := hmac.New(sha256.New, cfg.Secret)
+	mac.Write([]byte(id))
+
+	return id + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns the identifier within the cookie value, if its signature is valid.
+func (cfg *CookieConfig) verify(value string) (string, bool) {
+	i := strings.LastIndex(value, ".")
+
+	if i <= 0 {
+		return "", false
+	}
+
+	id := value[:i]
+
+	return id, hmac.Equal([]byte(value), []byte(cfg.sign(id)))
+}
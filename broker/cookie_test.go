@@ -0,0 +1,92 @@
+package broker_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithClientCookie(t *testing.T) {
+	cfg := broker.CookieConfig{Secret: []byte("secret")}
+
+	// Connect once without a cookie to be issued one.
+	b := broker.New(time.Second, 3, nil, broker.WithClientCookie(cfg))
+	defer b.Close()
+
+	w := ssetest.NewStreamRecorder()
+	lag := connect(b, w, w.NewRequest("GET", "/connect", nil))
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+
+	if !assert.Len(t, cookies, 1) {
+		return
+	}
+
+	issued := cookies[0]
+	id := strings.SplitN(issued.Value, ".", 2)[0]
+
+	assert.Equal(t, "sse_client_id", issued.Name)
+	assert.True(t, issued.HttpOnly)
+	assert.Contains(t, lag, id)
+
+	tt := []struct {
+		Name          string
+		Cookie        string
+		ExpectsCookie bool
+		ExpectsSameID bool
+	}{
+		{
+			Name:          "It should reuse the identifier in a valid cookie",
+			Cookie:        issued.Value,
+			ExpectsSameID: true,
+		},
+		{
+			Name:          "It should issue a new identifier when the cookie is tampered with",
+			Cookie:        "other." + strings.SplitN(issued.Value, ".", 2)[1],
+			ExpectsCookie: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			w := ssetest.NewStreamRecorder()
+			r := w.NewRequest("GET", "/connect", nil)
+			r.AddCookie(&http.Cookie{Name: issued.Name, Value: tc.Cookie})
+
+			lag := connect(b, w, r)
+
+			assert.Equal(t, tc.ExpectsCookie, w.Header().Get("Set-Cookie") != "")
+
+			if tc.ExpectsSameID {
+				assert.Contains(t, lag, id)
+			} else {
+				assert.NotContains(t, lag, "other")
+			}
+		})
+	}
+}
+
+// connect runs the broker's client handler until the client has connected, returning the lag of
+// the connected clients before disconnecting it. The handler owns the recorder's headers until it
+// returns, so they are only safe to read once connect has.
+func connect(b broker.Broker, w *ssetest.StreamRecorder, r *http.Request) map[string]client.Lag {
+	done := make(chan struct{})
+
+	go func() {
+		b.ClientHandler(w, r)
+		close(done)
+	}()
+
+	<-time.Tick(time.Millisecond * 100)
+	lag := b.Stats().Lag
+
+	w.Close()
+	<-done
+
+	return lag
+}
@@ -0,0 +1,94 @@
+package broker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The deadline type is a Publisher that gives each event it broadcasts a deadline, overriding
+	// the broker's timeout.
+	deadline struct {
+		broker  *defaultBroker
+		timeout time.Duration
+	}
+)
+
+// Timeout returns a Publisher whose events are written to clients within the given timeout, rather
+// than the timeout the broker was created with, so that urgent events can fail fast & bulky events
+// can wait longer for slow clients. The timeout is measured from when each event is published, so
+// it bounds the whole broadcast. Events published using the BroadcastEvent, BroadcastWithin &
+// BroadcastSummary methods have their Deadline field replaced. Events can also be given a deadline
+// directly, see the event.Event type. For example, b.Timeout(time.Millisecond * 100).BroadcastTo(id, data)
+// gives up on the client if its queue is still full after 100ms.
+func (b *defaultBroker) Timeout(d time.Duration) Publisher {
+	return &deadline{broker: b, timeout: d}
+}
+
+// Broadcast writes the given data to all connected clients within the timeout.
+func (d *deadline) Broadcast(data []byte) error {
+	return d.BroadcastEvent(event.Event{Data: data})
+}
+
+// BroadcastTo writes the given data to the client with the given id within the timeout.
+func (d *deadline) BroadcastTo(id string, data []byte) error {
+	return d.broker.sendTo(id, event.Event{Data: data, Deadline: d.deadline()})
+}
+
+// BroadcastTopic writes the given data to the subscribers of the topic within the timeout.
+func (d *deadline) BroadcastTopic(topic string, data []byte) error {
+	return d.BroadcastEvent(event.Event{Topic: topic, Data: data})
+}
+
+// BroadcastExcept writes the given data to all connected clients other than those with the given
+// ids within the timeout.
+func (d *deadline) BroadcastExcept(excludeIDs []string, data []byte) error {
+	return d.BroadcastEvent(event.Event{Except: excludeIDs, Data: data})
+}
+
+// BroadcastGroup writes the given data to the members of the group within the timeout.
+func (d *deadline) BroadcastGroup(group string, data []byte) error {
+	return d.BroadcastEvent(event.Event{Group: group, Data: data})
+}
+
+// BroadcastEvent broadcasts the event within the timeout, replacing its Deadline field.
+func (d *deadline) BroadcastEvent(e event.Event) error {
+	e.Deadline = d.deadline()
+	return d.broker.BroadcastEvent(e)
+}
+
+// BroadcastWithin broadcasts the event within the timeout & the error budget, replacing its Deadline
+// field.
+func (d *deadline) BroadcastWithin(e event.Event, budget ErrorBudget) error {
+	e.Deadline = d.deadline()
+	return d.broker.BroadcastWithin(e, budget)
+}
+
+// BroadcastSummary broadcasts the event within the timeout, replacing its Deadline field, & returns
+// a summary of its delivery.
+func (d *deadline) BroadcastSummary(e event.Event) (Summary, error) {
+	e.Deadline = d.deadline()
+	return d.broker.BroadcastSummary(e)
+}
+
+func (d *deadline) deadline() time.Time {
+	return d.broker.clock.Now().Add(d.timeout)
+}
+
+// parseDeadline parses the timeout given when publishing an event over HTTP, such as '250ms',
+// returning the deadline it gives the event. If the timeout is blank, a zero time is returned.
+func parseDeadline(timeout string, now time.Time) (time.Time, error) {
+	if timeout == "" {
+		return time.Time{}, nil
+	}
+
+	d, err := time.ParseDuration(timeout)
+
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("invalid timeout %q", timeout)
+	}
+
+	return now.Add(d), nil
+}
@@ -0,0 +1,67 @@
+package broker_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Timeout(t *testing.T) {
+	brk := broker.New(time.Minute, 3, nil)
+	defer brk.Close()
+
+	// The client's queue is full, so writes wait for space until they time out.
+	c := client.NewWithOptions(time.Minute, 3, "full", client.WithQueueSize(1))
+	assert.NoError(t, brk.Subscribe(c))
+	assert.NoError(t, brk.BroadcastTo("full", []byte("first")))
+
+	started := time.Now()
+	err := brk.Timeout(time.Millisecond*50).BroadcastTo("full", []byte("urgent"))
+
+	assert.Error(t, err)
+	assert.True(t, time.Since(started) < time.Second)
+}
+
+func TestBroker_EventHandlerTimeout(t *testing.T) {
+	tt := []struct {
+		Name         string
+		Timeout      string
+		ExpectedCode int
+	}{
+		{
+			Name:         "It should accept a valid timeout",
+			Timeout:      "250ms",
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Name:         "It should reject a malformed timeout",
+			Timeout:      "soon",
+			ExpectedCode: http.StatusBadRequest,
+		},
+		{
+			Name:         "It should reject a negative timeout",
+			Timeout:      "-1s",
+			ExpectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil)
+			defer brk.Close()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/broadcast?timeout="+tc.Timeout, bytes.NewBufferString("hello"))
+
+			brk.EventHandler(w, r)
+
+			assert.Equal(t, tc.ExpectedCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package broker
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+)
+
+type (
+	// The DebugConfig type contains the URLs the debug page uses to connect to the broker's
+	// stream & publish events, as registered by your application.
+	DebugConfig struct {
+		ConnectURL string // The URL of the broker's ClientHandler, such as '/connect'.
+		PublishURL string // The URL of the broker's EventHandler, such as '/broadcast'.
+	}
+
+	// The debugPage type contains the values rendered into the debug page.
+	debugPage struct {
+		ConnectURL string
+		PublishURL string
+		StatsURL   string
+	}
+)
+
+var (
+	//go:embed static/debug.html
+	debugHTML string
+
+	debugTemplate = template.Must(template.New("debug").Parse(debugHTML))
+)
+
+// DebugHandler returns an http.Handler serving a small page for use during development. The page
+// connects to the broker's stream & shows live events, shows the number of connected clients &
+// subscribers of each topic, and publishes test events. The broker's statistics are served as JSON
+// from the same path using the 'stats' query parameter. The handler should not be exposed in
+// production, as it allows anybody to read the broker's statistics.
+//
+// Example using http (https://golang.org/pkg/net/http/)
+//
+// http.HandleFunc("/connect", broker.ClientHandler)
+// http.HandleFunc("/broadcast", broker.EventHandler)
+// http.Handle("/debug", broker.DebugHandler(b, broker.DebugConfig{ConnectURL: "/connect", PublishURL: "/broadcast"}))
+func DebugHandler(b Broker, cfg DebugConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["stats"]; ok {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(b.Stats())
+			return
+		}
+
+		page := debugPage{
+			ConnectURL: cfg.ConnectURL,
+			PublishURL: cfg.PublishURL,
+			StatsURL:   r.URL.Path + "?stats",
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+
+		if err := debugTemplate.Execute(w, page); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
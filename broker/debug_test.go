@@ -0,0 +1,57 @@
+package broker_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugHandler(t *testing.T) {
+	tt := []struct {
+		Name                string
+		URL                 string
+		ExpectedContentType string
+		ExpectedBody        []string
+	}{
+		{
+			Name:                "It should render the page using the configured URLs",
+			URL:                 "/debug",
+			ExpectedContentType: "text/html; charset=utf-8",
+			ExpectedBody:        []string{`"/connect"`, `"/broadcast"`, `"/debug?stats"`},
+		},
+		{
+			Name:                "It should serve the broker's statistics",
+			URL:                 "/debug?stats",
+			ExpectedContentType: "application/json",
+			ExpectedBody:        []string{`"Clients":0`},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil)
+			defer b.Close()
+
+			handler := broker.DebugHandler(b, broker.DebugConfig{ConnectURL: "/connect", PublishURL: "/broadcast"})
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", tc.URL, nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.ExpectedContentType, w.Header().Get("Content-Type"))
+
+			for _, expected := range tc.ExpectedBody {
+				assert.Contains(t, w.Body.String(), expected)
+			}
+
+			if tc.ExpectedContentType == "application/json" {
+				assert.True(t, json.Valid(w.Body.Bytes()))
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package broker
+
+import (
+	"sync"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// Comparator is a function that determines if the 'next' payload to be broadcast is
+	// a duplicate of the 'previous' one. The bytes.Equal function can be used to suppress
+	// payloads that are byte-identical.
+	Comparator func(previous, next []byte) bool
+
+	// The deduper type tracks the last payload broadcast to each topic so that duplicate
+	// payloads can be suppressed. Broadcasts to all clients are tracked under a blank topic.
+	deduper struct {
+		mux     sync.Mutex
+		compare Comparator
+		last    map[dedupeKey][]byte
+	}
+
+	// The dedupeKey type identifies the events whose payloads are compared with each other.
+	dedupeKey struct {
+		topic  string
+		typ    string
+		stream string
+	}
+)
+
+// WithDeduplication configures the broker to suppress broadcasting a payload when the
+// comparator reports that it is a duplicate of the payload previously broadcast to the same
+// topic. This prevents redundant updates from chatty upstream sources from reaching clients.
+// Payloads are only compared with those of events of the same type & stream. Events targeting only
+// some of the topic's clients, using a group, audience or exclusions, are never suppressed, as the
+// previous payload may not have reached the same clients. If 'cmp' is nil, no payloads are
+// suppressed.
+func WithDeduplication(cmp Comparator) Option {
+	return func(b *defaultBroker) {
+		if cmp == nil {
+			return
+		}
+
+		b.deduper = &deduper{
+			compare: cmp,
+			last:    make(map[dedupeKey][]byte),
+		}
+	}
+}
+
+// duplicate returns true if the event's data is a duplicate of the last payload of the same type
+// & stream broadcast to its topic. If it is not, the data becomes the payload that future
+// broadcasts are compared against. Events targeting some of the topic's clients are never
+// duplicates.
+func (d *deduper) duplicate(e event.Event) bool {
+	if e.Group != "" || e.Audience != "" || len(e.Except) > 0 {
+		return false
+	}
+
+	key := dedupeKey{topic: e.Topic, typ: e.Type, stream: e.Stream}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	last, ok := d.last[key]
+
+	if ok && d.compare(last, e.Data) {
+		return true
+	}
+
+	// Copy the data so callers can reuse their buffers.
+	d.last[key] = append(last[:0], e.Data...)
+
+	return false
+}
@@ -0,0 +1,127 @@
+package broker_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithDeduplication(t *testing.T) {
+	tt := []struct {
+		Comparator     broker.Comparator
+		Payloads       []string
+		ExpectedOutput string
+	}{
+		{
+			Payloads:       []string{"a", "a", "b"},
+			ExpectedOutput: "data: a\n\ndata: a\n\ndata: b\n\n",
+		},
+		{
+			Comparator:     bytes.Equal,
+			Payloads:       []string{"a", "a", "b", "a"},
+			ExpectedOutput: "data: a\n\ndata: b\n\ndata: a\n\n",
+		},
+		{
+			Comparator:     bytes.EqualFold,
+			Payloads:       []string{"a", "A", "b"},
+			ExpectedOutput: "data: a\n\ndata: b\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil, broker.WithDeduplication(tc.Comparator))
+		w := &FlushRecorder{header: http.Header{}}
+		r := httptest.NewRequest("GET", "/", nil)
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, r)
+		<-time.Tick(time.Second)
+
+		for _, payload := range tc.Payloads {
+			assert.NoError(t, broker.Broadcast([]byte(payload)))
+		}
+
+		<-time.After(time.Millisecond * 100)
+
+		assert.Equal(t, tc.ExpectedOutput, w.String())
+		broker.Close()
+	}
+}
+
+func TestBroker_WithDeduplicationTargeting(t *testing.T) {
+	tt := []struct {
+		Name           string
+		Events         []event.Event
+		ExpectedEvents int
+	}{
+		{
+			Name: "It should suppress duplicates of the same type & stream",
+			Events: []event.Event{
+				{Type: "price", Stream: "a", Data: []byte("1")},
+				{Type: "price", Stream: "a", Data: []byte("1")},
+			},
+			ExpectedEvents: 1,
+		},
+		{
+			Name: "It should not suppress payloads of different types",
+			Events: []event.Event{
+				{Type: "bid", Data: []byte("1")},
+				{Type: "ask", Data: []byte("1")},
+			},
+			ExpectedEvents: 2,
+		},
+		{
+			Name: "It should not suppress payloads of different streams",
+			Events: []event.Event{
+				{Stream: "a", Data: []byte("1")},
+				{Stream: "b", Data: []byte("1")},
+			},
+			ExpectedEvents: 2,
+		},
+		{
+			Name: "It should not suppress events targeting some clients",
+			Events: []event.Event{
+				{Except: []string{"other"}, Data: []byte("1")},
+				{Except: []string{"other"}, Data: []byte("1")},
+			},
+			ExpectedEvents: 2,
+		},
+		{
+			Name: "It should not suppress events after a payload that excluded the client",
+			Events: []event.Event{
+				{Except: []string{"test"}, Data: []byte("1")},
+				{Data: []byte("1")},
+			},
+			ExpectedEvents: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, broker.WithDeduplication(bytes.Equal))
+			defer brk.Close()
+
+			c := ssetest.NewClient("test", client.WithQueueSize(10))
+			defer c.Close()
+
+			assert.NoError(t, brk.Subscribe(c.Client))
+
+			for _, e := range tc.Events {
+				assert.NoError(t, brk.BroadcastEvent(e))
+			}
+
+			<-time.After(time.Millisecond * 100)
+
+			assert.Len(t, c.Events(), tc.ExpectedEvents)
+		})
+	}
+}
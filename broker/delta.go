@@ -0,0 +1,131 @@
+package broker
+
+import (
+	"sync"
+
+	"github.com/davidsbond/sse/client"
+	jsonpatch "github.com/davidsbond/sse/delta"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The DeltaConfig type describes the topics whose events are JSON documents that should be
+	// sent to clients as the changes made to them, see the broker.WithDeltaEncoding method.
+	DeltaConfig struct {
+		Topics           []string // The topics to delta encode.
+		SnapshotInterval int      // Every event at this interval is sent whole. Defaults to 10.
+	}
+
+	// The deltaEncoder type holds the last document broadcast to each delta encoded topic.
+	deltaEncoder struct {
+		interval int
+		topics   map[string]*deltaTopic
+	}
+
+	// The deltaTopic type holds the last document broadcast to a topic. Its lock is held while
+	// events are broadcast to the topic, so that clients receive patches in the order they are
+	// made.
+	deltaTopic struct {
+		mux     sync.Mutex
+		last    event.Event
+		patches int
+	}
+)
+
+// WithDeltaEncoding configures the broker to send events broadcast to the configured topics as
+// JSON patches (RFC 6902) against the previous event broadcast to the topic, which reduces the
+// bandwidth used by frequently updated documents. Patches have the delta.PatchType type. The
+// whole document is sent at the snapshot interval, when either document is not valid JSON, when
+// the patch would be no smaller than the document, & to each client when it subscribes to the
+// topic. Consumers rebuild the document using the delta.Document type. Events are stored &
+// replayed whole, and events with an audience, group or excluded clients are never delta encoded.
+// If no topics are configured, no events are delta encoded.
+func WithDeltaEncoding(cfg DeltaConfig) Option {
+	return func(b *defaultBroker) {
+		if len(cfg.Topics) == 0 {
+			return
+		}
+
+		b.deltas = &deltaEncoder{
+			interval: cfg.SnapshotInterval,
+			topics:   make(map[string]*deltaTopic, len(cfg.Topics)),
+		}
+
+		if b.deltas.interval <= 0 {
+			b.deltas.interval = 10
+		}
+
+		for _, topic := range cfg.Topics {
+			b.deltas.topics[topic] = &deltaTopic{}
+		}
+	}
+}
+
+// topic returns the delta encoded topic the event is broadcast to, or nil if it should be sent
+// whole.
+func (d *deltaEncoder) topic(e event.Event) *deltaTopic {
+	if d == nil || e.Topic == "" || e.Audience != "" || e.Group != "" || len(e.Except) > 0 {
+		return nil
+	}
+
+	return d.topics[e.Topic]
+}
+
+// subscribe writes the last document broadcast to each of the given topics to the client, so
+// that it has a document to apply later patches to. The documents are written in the background,
+// as the client may not be read from until it has been subscribed. Patches written to the client
+// before the document are already included in it, & are ignored by the delta.Document type.
+func (d *deltaEncoder) subscribe(c *client.Client, topics []string) {
+	if d == nil {
+		return
+	}
+
+	for _, topic := range topics {
+		t, ok := d.topics[topic]
+
+		if !ok {
+			continue
+		}
+
+		go t.subscribe(c)
+	}
+}
+
+func (t *deltaTopic) subscribe(c *client.Client) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.last.Data != nil {
+		c.WriteEvent(t.last)
+	}
+}
+
+// encode replaces the event's data with a patch against the previous event broadcast to the
+// topic, unless it should be sent whole. It must be called while holding the topic's lock.
+func (t *deltaTopic) encode(e event.Event, interval int) event.Event {
+	previous := t.last.Data
+
+	// Copy the data so callers can reuse their buffers.
+	t.last = e
+	t.last.Data = append([]byte{}, e.Data...)
+
+	if previous == nil || t.patches >= interval-1 {
+		t.patches = 0
+		return e
+	}
+
+	patch, err := jsonpatch.Diff(previous, e.Data)
+
+	if err != nil || len(patch) >= len(e.Data) {
+		t.patches = 0
+		return e
+	}
+
+	// Patches cannot replace each other, as each is made against the one before it.
+	t.patches++
+	e.Data = patch
+	e.Type = jsonpatch.PatchType
+	e.Key = ""
+
+	return e
+}
@@ -0,0 +1,112 @@
+package broker_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/delta"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithDeltaEncoding(t *testing.T) {
+	tt := []struct {
+		Name          string
+		Payloads      []string
+		ExpectedTypes []string
+	}{
+		{
+			Name:          "It should send patches between snapshots",
+			Payloads:      []string{document(1), document(2), document(3), document(4)},
+			ExpectedTypes: []string{"", delta.PatchType, delta.PatchType, ""},
+		},
+		{
+			Name:          "It should send documents whole if the patch is larger",
+			Payloads:      []string{`{"n":1}`, `{"n":2}`},
+			ExpectedTypes: []string{"", ""},
+		},
+		{
+			Name:          "It should send payloads that are not JSON whole",
+			Payloads:      []string{document(1), `not json`, document(2)},
+			ExpectedTypes: []string{"", "", ""},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, broker.WithDeltaEncoding(broker.DeltaConfig{
+				Topics:           []string{"doc"},
+				SnapshotInterval: 3,
+			}))
+			defer brk.Close()
+
+			c := client.NewWithOptions(time.Second, 3, "", client.WithTopics("doc"), client.WithQueueSize(10))
+			assert.NoError(t, brk.Subscribe(c))
+
+			doc := delta.NewDocument()
+
+			for i, payload := range tc.Payloads {
+				assert.NoError(t, brk.BroadcastEvent(event.Event{Topic: "doc", Data: []byte(payload)}))
+
+				e, ok := c.Next()
+				assert.True(t, ok)
+				assert.Equal(t, tc.ExpectedTypes[i], e.Type)
+
+				actual, err := doc.Update(e)
+				assert.NoError(t, err)
+
+				if e.Type == delta.PatchType {
+					assert.JSONEq(t, payload, string(actual))
+				} else {
+					assert.Equal(t, payload, string(actual))
+				}
+			}
+		})
+	}
+}
+
+func TestBroker_WithDeltaEncodingSubscribe(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithDeltaEncoding(broker.DeltaConfig{
+		Topics: []string{"doc"},
+	}))
+	defer brk.Close()
+
+	first := client.NewWithOptions(time.Second, 3, "", client.WithTopics("doc"), client.WithQueueSize(10))
+	assert.NoError(t, brk.Subscribe(first))
+
+	assert.NoError(t, brk.BroadcastTopic("doc", []byte(`{"items":[1,2,3],"title":"document"}`)))
+	assert.NoError(t, brk.BroadcastTopic("doc", []byte(`{"items":[1,2,3,4],"title":"document"}`)))
+
+	// A client subscribing later is sent the current document before any patches.
+	second := client.NewWithOptions(time.Second, 3, "", client.WithTopics("doc"), client.WithQueueSize(10))
+	assert.NoError(t, brk.Subscribe(second))
+
+	select {
+	case <-second.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("document was not written to the new client")
+	}
+
+	assert.NoError(t, brk.BroadcastTopic("doc", []byte(`{"items":[1,2,3,4,5],"title":"document"}`)))
+
+	for _, c := range []*client.Client{first, second} {
+		doc := delta.NewDocument()
+
+		for e, ok := c.Next(); ok; e, ok = c.Next() {
+			_, err := doc.Update(e)
+			assert.NoError(t, err)
+		}
+
+		assert.JSONEq(t, `{"items":[1,2,3,4,5],"title":"document"}`, string(doc.Bytes()))
+	}
+}
+
+// document returns a JSON document that is large enough for a patch changing its number to be
+// smaller than the document itself.
+func document(n int) string {
+	return fmt.Sprintf(`{"n":%v,"text":%q}`, n, strings.Repeat("a", 64))
+}
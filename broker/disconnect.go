@@ -0,0 +1,158 @@
+package broker
+
+import (
+	"encoding/json"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// DisconnectReason is a machine-readable description of why a client was removed from the broker
+	// or its stream was ended. It is passed to the broker's OnClientDisconnect listeners & sent to
+	// streaming clients in a final 'sse:close' event.
+	DisconnectReason string
+
+	// The jsonCloseEvent type is the JSON representation of the data of a close event.
+	jsonCloseEvent struct {
+		Reason DisconnectReason `json:"reason"`
+	}
+)
+
+const (
+	// CloseEventType is the type of the final event written to a stream the broker ends, whose data
+	// contains the reason it was ended, see the ParseCloseEvent function.
+	CloseEventType = "sse:close"
+)
+
+const (
+	// DisconnectConnectionLost means the client's connection closed, or was dropped by a fault injected
+	// using the broker.WithFaults method. No close event is sent.
+	DisconnectConnectionLost DisconnectReason = "connection_lost"
+
+	// DisconnectTolerance means the client exceeded its error tolerance, or was too slow under a slow
+	// policy that disconnects clients.
+	DisconnectTolerance DisconnectReason = "tolerance_exceeded"
+
+	// DisconnectKicked means the client was removed using the broker's Kick method.
+	DisconnectKicked DisconnectReason = "kicked"
+
+	// DisconnectShutdown means the broker was closed.
+	DisconnectShutdown DisconnectReason = "shutdown"
+
+	// DisconnectMaxAge means the client's stream reached its maximum age, see the
+	// broker.WithMaxConnectionAge method.
+	DisconnectMaxAge DisconnectReason = "max_age"
+
+	// DisconnectUnresponsive means the client stopped sending pings, see the broker.WithLiveness method.
+	DisconnectUnresponsive DisconnectReason = "unresponsive"
+
+	// DisconnectMemory means the client was evicted to bring the broker within its memory budget, see
+	// the broker.WithMemoryBudget method.
+	DisconnectMemory DisconnectReason = "memory_budget"
+
+	// DisconnectReplaced means another client connected with the same identifier, or another
+	// connection resumed the client's session.
+	DisconnectReplaced DisconnectReason = "replaced"
+
+	// DisconnectReconnect means the client was told to reconnect, see the broker's Reconnect method.
+	DisconnectReconnect DisconnectReason = "reconnect"
+
+	// DisconnectUnsubscribed means the client was removed using the broker's Unsubscribe method, or
+	// was a local subscriber whose subscription was cancelled.
+	DisconnectUnsubscribed DisconnectReason = "unsubscribed"
+)
+
+// OnClientDisconnect registers a function that is called with the identifier of each client removed
+// from the broker & the reason it was removed, returning a function that stops it being called. A
+// client whose session can be resumed is only removed once its session expires. Functions are called
+// in the same way as those registered using the OnSystemEvent method.
+func (b *defaultBroker) OnClientDisconnect(fn func(id string, reason DisconnectReason)) func() {
+	return b.OnSystemEvent(func(se SystemEvent) {
+		if se.Type == SystemClientDisconnected {
+			fn(se.ClientID, se.Reason)
+		}
+	})
+}
+
+// Kick removes the client with the given id from the broker, ending its stream with a close event
+// whose reason is DisconnectKicked. The client is not prevented from connecting again. If no such
+// client is connected, an error is returned.
+func (b *defaultBroker) Kick(id string) error {
+	c, err := b.client(id)
+
+	if err != nil {
+		return err
+	}
+
+	b.disconnect(c, DisconnectKicked)
+
+	return nil
+}
+
+// ParseCloseEvent returns the reason contained in an 'sse:close' event. If the event is not a close
+// event or its data is malformed, false is returned.
+func ParseCloseEvent(e event.Event) (DisconnectReason, bool) {
+	if e.Type != CloseEventType {
+		return "", false
+	}
+
+	var data jsonCloseEvent
+
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return "", false
+	}
+
+	return data.Reason, true
+}
+
+// closeEvent returns the 'sse:close' event containing the reason.
+func closeEvent(reason DisconnectReason) event.Event {
+	data, _ := json.Marshal(jsonCloseEvent{Reason: reason})
+
+	return event.Event{Type: CloseEventType, Data: data}
+}
+
+// writeClose writes a close event containing the reason to the client's stream, unless the
+// connection has already been lost.
+func (b *defaultBroker) writeClose(enc FrameWriter, reason DisconnectReason, flush func()) {
+	if reason == "" || reason == DisconnectConnectionLost {
+		return
+	}
+
+	e := closeEvent(reason)
+	e.Timestamp = b.clock.Now()
+
+	enc.Encode(e)
+	flush()
+}
+
+// trackStream records that the client has a stream open, returning a channel that receives the
+// reason the client is removed from the broker while the stream is open.
+func (b *defaultBroker) trackStream(c *client.Client) chan DisconnectReason {
+	reasons := make(chan DisconnectReason, 1)
+	b.streams.Store(c, reasons)
+
+	return reasons
+}
+
+// untrackStream records that the client's stream has ended, unless another connection has since
+// resumed the client's session.
+func (b *defaultBroker) untrackStream(c *client.Client, reasons chan DisconnectReason) {
+	b.streams.CompareAndDelete(c, reasons)
+}
+
+// noteReason tells the client's open stream, if it has one, why the client is being removed. Only
+// the first reason is kept.
+func (b *defaultBroker) noteReason(c *client.Client, reason DisconnectReason) {
+	item, ok := b.streams.Load(c)
+
+	if !ok {
+		return
+	}
+
+	select {
+	case item.(chan DisconnectReason) <- reason:
+	default:
+	}
+}
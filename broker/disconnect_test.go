@@ -0,0 +1,171 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Kick(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil)
+	defer brk.Close()
+
+	type disconnect struct {
+		ID     string
+		Reason broker.DisconnectReason
+	}
+
+	disconnects := make(chan disconnect, 10)
+
+	brk.OnClientDisconnect(func(id string, reason broker.DisconnectReason) {
+		disconnects <- disconnect{ID: id, Reason: reason}
+	})
+
+	expectDisconnect := func(expected disconnect) {
+		select {
+		case actual := <-disconnects:
+			assert.Equal(t, expected, actual)
+		case <-time.After(time.Second):
+			t.Fatalf("the disconnect of %v was not reported", expected.ID)
+		}
+	}
+
+	w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+	ended := make(chan struct{})
+
+	go func() {
+		brk.ClientHandler(w, httptest.NewRequest("GET", "/connect?id=test", nil))
+		close(ended)
+	}()
+
+	<-time.After(time.Millisecond * 100)
+
+	assert.NoError(t, brk.Subscribe(client.New(time.Second, 3, "local")))
+	assert.NoError(t, brk.Kick("test"))
+	assert.Error(t, brk.Kick("unknown"))
+	brk.Unsubscribe(client.New(time.Second, 3, "other"))
+
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not end after the client was kicked")
+	}
+
+	expectDisconnect(disconnect{ID: "test", Reason: broker.DisconnectKicked})
+	assert.Equal(t, "event: sse:close\ndata: {\"reason\":\"kicked\"}\n\n", w.String())
+	assert.Equal(t, 1, brk.Stats().Clients)
+
+	assert.NoError(t, brk.Close())
+	expectDisconnect(disconnect{ID: "local", Reason: broker.DisconnectShutdown})
+}
+
+func TestBroker_DisconnectReason(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Options  []broker.Option
+		End      func(brk broker.Broker)
+		Expected broker.DisconnectReason
+		Written  string
+	}{
+		{
+			Name: "It should close the stream when the broker is closed",
+			End: func(brk broker.Broker) {
+				brk.Close()
+			},
+			Expected: broker.DisconnectShutdown,
+			Written:  "event: sse:close\ndata: {\"reason\":\"shutdown\"}\n\n",
+		},
+		{
+			Name:    "It should close the stream when it reaches its maximum age",
+			Options: []broker.Option{broker.WithMaxConnectionAge(time.Millisecond * 200)},
+			End: func(brk broker.Broker) {
+				<-time.After(time.Millisecond * 300)
+			},
+			Expected: broker.DisconnectMaxAge,
+			Written:  "retry: 1000\n\nevent: sse:close\ndata: {\"reason\":\"max_age\"}\n\n",
+		},
+		{
+			Name:     "It should not write to a stream whose connection is lost",
+			End:      func(brk broker.Broker) {},
+			Expected: broker.DisconnectConnectionLost,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, tc.Options...)
+			defer brk.Close()
+
+			reasons := make(chan broker.DisconnectReason, 1)
+			brk.OnClientDisconnect(func(id string, reason broker.DisconnectReason) {
+				reasons <- reason
+			})
+
+			w := &FlushRecorder{header: http.Header{}, close: make(chan bool, 1)}
+			ended := make(chan struct{})
+
+			go func() {
+				brk.ClientHandler(w, httptest.NewRequest("GET", "/connect?id=test", nil))
+				close(ended)
+			}()
+
+			<-time.After(time.Millisecond * 100)
+			tc.End(brk)
+
+			select {
+			case <-ended:
+			case <-time.After(time.Millisecond * 100):
+				w.close <- true
+				<-ended
+			}
+
+			select {
+			case reason := <-reasons:
+				assert.Equal(t, tc.Expected, reason)
+			case <-time.After(time.Second):
+				t.Fatal("the disconnect was not reported")
+			}
+
+			assert.Equal(t, tc.Written, w.String())
+		})
+	}
+}
+
+func TestParseCloseEvent(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Event    event.Event
+		Expected broker.DisconnectReason
+		OK       bool
+	}{
+		{
+			Name:     "It should parse a close event",
+			Event:    event.Event{Type: broker.CloseEventType, Data: []byte(`{"reason":"kicked"}`)},
+			Expected: broker.DisconnectKicked,
+			OK:       true,
+		},
+		{
+			Name:  "It should ignore other events",
+			Event: event.Event{Type: "message", Data: []byte(`{"reason":"kicked"}`)},
+		},
+		{
+			Name:  "It should ignore malformed data",
+			Event: event.Event{Type: broker.CloseEventType, Data: []byte("kicked")},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			reason, ok := broker.ParseCloseEvent(tc.Event)
+
+			assert.Equal(t, tc.OK, ok)
+			assert.Equal(t, tc.Expected, reason)
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package broker
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// DispatchCallback is a function that is called with the outcome of an event published using
+	// the broker's Dispatch method, once it has been written to every client or failed.
+	DispatchCallback func(summary Summary, err error)
+
+	// The BroadcastResult type is the outcome of an event published using the BroadcastAsync method.
+	BroadcastResult struct {
+		Summary Summary // The summary of the event's delivery, see the BroadcastSummary method.
+		Err     error   // Any error publishing the event, such as ErrPublishQueueFull.
+	}
+
+	// The DispatchStats type contains statistics on the queues of events published using the
+	// Dispatch & BroadcastAsync methods.
+	DispatchStats struct {
+		Queued   int    // The number of events waiting to be published.
+		Rejected uint64 // The number of events rejected because their topic's queue was full.
+	}
+
+	// The dispatcher type queues events to be published by a worker goroutine per topic, so that
+	// publishing to one busy topic does not hold up publishing to others. Workers are started
+	// when an event is queued for their topic & exit once its queue is empty.
+	dispatcher struct {
+		mux     sync.Mutex
+		size    int
+		workers map[string]chan dispatched
+		publish func(event.Event) (Summary, error)
+		closed  <-chan struct{}
+		wg      sync.WaitGroup
+
+		rejected uint64
+	}
+
+	// The dispatched type is an event waiting in a topic's queue, along with the function called
+	// once it has been published.
+	dispatched struct {
+		event event.Event
+		done  DispatchCallback
+	}
+)
+
+const (
+	// The number of events that can be queued for each topic if the broker is not configured
+	// using the WithTopicWorkers method.
+	defaultDispatchQueue = 256
+)
+
+var (
+	// ErrPublishQueueFull is the error returned when an event is dispatched to a topic whose
+	// queue is full, because its subscribers cannot keep up with the rate it is published at.
+	ErrPublishQueueFull = errors.New("publish queue is full")
+
+	// ErrBrokerClosed is the error passed to the callbacks of dispatched events that had not been
+	// published when the broker was closed.
+	ErrBrokerClosed = errors.New("broker is closed")
+)
+
+// WithTopicWorkers configures the number of events that can be queued for each topic by the
+// Dispatch method, which defaults to 256. Each topic's events are published in order by a worker
+// goroutine of its own, so that slow subscribers of one topic do not hold up others.
+func WithTopicWorkers(queueSize int) Option {
+	return func(b *defaultBroker) {
+		b.dispatchQueue = queueSize
+	}
+}
+
+// WithAsyncPublishing configures the broker's EventHandler to queue broadcast events using the
+// Dispatch method & respond with a 202 status code straight away, rather than waiting until the event
+// has been written to every client, so that a single slow client does not hold up publishers. If the
+// topic's queue is full, the handler responds with a 503 status code. As the event is published after
+// the handler has responded, errors publishing it, including rejections by the broker's interceptors
+// & rate limit, are not reported to the publisher. Events sent to a single client using the 'id'
+// query parameter are still written before the handler responds.
+func WithAsyncPublishing(enabled bool) Option {
+	return func(b *defaultBroker) {
+		b.asyncPublish = enabled
+	}
+}
+
+// Dispatch queues the event to be published in the same way as the BroadcastSummary method &
+// returns immediately, calling 'fn', if set, with the outcome once it has been published. Events
+// are queued by topic, each of which is published by its own worker goroutine, so publishers
+// are not held up by slow clients & busy topics do not hold up each other. Events for the same
+// topic are published in the order they were dispatched. If the topic's queue is full,
+// ErrPublishQueueFull is returned & the event is not published. The callback is called on the
+// topic's worker goroutine, so it should return quickly. Events still queued when the broker is
+// closed are not published, & their callbacks are passed ErrBrokerClosed.
+//
+// Topics whose queue is full are reported to the broker's system event listeners as
+// SystemPublishQueueFull, & the number of events rejected is reported by the Stats method.
+func (b *defaultBroker) Dispatch(e event.Event, fn DispatchCallback) error {
+	if err := b.admit(); err != nil {
+		return err
+	}
+
+	err := b.dispatcher.enqueue(e, fn)
+
+	if err == ErrPublishQueueFull {
+		b.system.emit(SystemEvent{Type: SystemPublishQueueFull, Topics: []string{e.Topic}, Time: b.clock.Now()})
+	}
+
+	return err
+}
+
+// dispatchEvent queues the event published by the request & responds with a 202 status code, or
+// with an error if it could not be queued. It returns true if the event was queued.
+func (b *defaultBroker) dispatchEvent(w http.ResponseWriter, r *http.Request, e event.Event) bool {
+	switch err := b.Dispatch(e, nil); {
+	case err == nil:
+		w.WriteHeader(http.StatusAccepted)
+		return true
+	case err == ErrPublishQueueFull:
+		b.httpError(w, r, CodePublishQueueFull, err, http.StatusServiceUnavailable)
+	case errors.Is(err, ErrBackpressure):
+		b.httpError(w, r, CodeBackpressure, err, http.StatusServiceUnavailable)
+	case err == ErrMemoryBudget:
+		b.httpError(w, r, CodeMemoryBudget, err, http.StatusServiceUnavailable)
+	default:
+		b.httpError(w, r, CodePublishFailed, err, http.StatusServiceUnavailable)
+	}
+
+	return false
+}
+
+// BroadcastAsync queues the event to be published in the same way as the Dispatch method & returns
+// immediately, so that publishers such as HTTP handlers can respond without waiting for slow clients.
+// The returned channel receives the outcome once the event has been published & is then closed. If
+// the event's topic is saturated, the outcome is ErrPublishQueueFull & is received immediately.
+func (b *defaultBroker) BroadcastAsync(e event.Event) <-chan BroadcastResult {
+	results := make(chan BroadcastResult, 1)
+
+	done := func(summary Summary, err error) {
+		results <- BroadcastResult{Summary: summary, Err: err}
+		close(results)
+	}
+
+	if err := b.Dispatch(e, done); err != nil {
+		done(Summary{}, err)
+	}
+
+	return results
+}
+
+func newDispatcher(size int, publish func(event.Event) (Summary, error), closed <-chan struct{}) *dispatcher {
+	if size <= 0 {
+		size = defaultDispatchQueue
+	}
+
+	return &dispatcher{
+		size:    size,
+		workers: make(map[string]chan dispatched),
+		publish: publish,
+		closed:  closed,
+	}
+}
+
+// enqueue adds the event to its topic's queue, starting a worker for the topic if it does not
+// have one.
+func (d *dispatcher) enqueue(e event.Event, fn DispatchCallback) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	select {
+	case <-d.closed:
+		return ErrBrokerClosed
+	default:
+	}
+
+	queue, ok := d.workers[e.Topic]
+
+	if !ok {
+		queue = make(chan dispatched, d.size)
+		d.workers[e.Topic] = queue
+
+		d.wg.Add(1)
+		go d.work(e.Topic, queue)
+	}
+
+	select {
+	case queue <- dispatched{event: e, done: fn}:
+		return nil
+	default:
+		atomic.AddUint64(&d.rejected, 1)
+		return ErrPublishQueueFull
+	}
+}
+
+// work publishes the events queued for the topic until its queue is empty. The queue is checked
+// while holding the dispatcher's lock, so that no events can be queued once the worker exits.
+func (d *dispatcher) work(topic string, queue chan dispatched) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case item := <-queue:
+			d.run(item)
+			continue
+		default:
+		}
+
+		d.mux.Lock()
+
+		if len(queue) == 0 {
+			delete(d.workers, topic)
+			d.mux.Unlock()
+
+			return
+		}
+
+		d.mux.Unlock()
+	}
+}
+
+// run publishes the event, unless the broker has been closed, & reports the outcome.
+func (d *dispatcher) run(item dispatched) {
+	var (
+		summary Summary
+		err     error
+	)
+
+	select {
+	case <-d.closed:
+		err = ErrBrokerClosed
+	default:
+		summary, err = d.publish(item.event)
+	}
+
+	if item.done != nil {
+		item.done(summary, err)
+	}
+}
+
+// stats returns the number of events waiting to be published & the number rejected.
+func (d *dispatcher) stats() DispatchStats {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	out := DispatchStats{Rejected: atomic.LoadUint64(&d.rejected)}
+
+	for _, queue := range d.workers {
+		out.Queued += len(queue)
+	}
+
+	return out
+}
+
+// wait waits for every worker to exit. It should be called once the broker is closed, so that
+// the remaining events are discarded. The lock is taken first so that no worker can be started
+// while waiting.
+func (d *dispatcher) wait() {
+	d.mux.Lock()
+	d.mux.Unlock()
+
+	d.wg.Wait()
+}
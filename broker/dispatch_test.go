@@ -0,0 +1,180 @@
+package broker_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	dispatchResult struct {
+		Summary broker.Summary
+		Err     error
+	}
+)
+
+func TestBroker_Dispatch(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil)
+	defer brk.Close()
+
+	// Writes to the slow client wait for the broker's timeout, as nothing reads its events.
+	slow := client.NewWithOptions(time.Second, 3, "slow", client.WithTopics("slow"))
+	fast := client.NewWithOptions(time.Second, 3, "fast", client.WithTopics("fast"), client.WithQueueSize(10))
+
+	assert.NoError(t, brk.Subscribe(slow))
+	assert.NoError(t, brk.Subscribe(fast))
+
+	slowResults := make(chan dispatchResult, 1)
+	fastResults := make(chan dispatchResult, 1)
+
+	assert.NoError(t, brk.Dispatch(event.Event{Topic: "slow", Data: []byte("slow")}, func(summary broker.Summary, err error) {
+		slowResults <- dispatchResult{Summary: summary, Err: err}
+	}))
+
+	assert.NoError(t, brk.Dispatch(event.Event{Topic: "fast", Data: []byte("fast")}, func(summary broker.Summary, err error) {
+		fastResults <- dispatchResult{Summary: summary, Err: err}
+	}))
+
+	// The fast topic is published without waiting for the slow one.
+	select {
+	case result := <-fastResults:
+		assert.NoError(t, result.Err)
+		assert.Equal(t, 1, result.Summary.Delivered)
+	case <-slowResults:
+		t.Fatal("slow topic was published first")
+	case <-time.After(time.Millisecond * 500):
+		t.Fatal("fast topic was not published")
+	}
+
+	select {
+	case result := <-slowResults:
+		assert.Error(t, result.Err)
+		assert.Equal(t, 1, result.Summary.Failed)
+	case <-time.After(time.Second * 3):
+		t.Fatal("slow topic was not published")
+	}
+}
+
+func TestBroker_DispatchQueueFull(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithTopicWorkers(1))
+
+	slow := client.NewWithOptions(time.Second, 3, "slow", client.WithTopics("slow"))
+	assert.NoError(t, brk.Subscribe(slow))
+
+	closed := make(chan error, 3)
+	callback := func(summary broker.Summary, err error) {
+		closed <- err
+	}
+
+	// One event is being published & one is queued, so the queue fills up.
+	var err error
+
+	for i := 0; i < 3 && err == nil; i++ {
+		err = brk.Dispatch(event.Event{Topic: "slow", Data: []byte("slow")}, callback)
+	}
+
+	assert.Equal(t, broker.ErrPublishQueueFull, err)
+
+	// Events still queued when the broker is closed are not published.
+	assert.NoError(t, brk.Close())
+	assert.Equal(t, broker.ErrBrokerClosed, brk.Dispatch(event.Event{Topic: "slow"}, nil))
+
+	select {
+	case err := <-closed:
+		assert.Error(t, err)
+	case <-time.After(time.Second * 3):
+		t.Fatal("callback was not called")
+	}
+}
+
+func TestBroker_BroadcastAsync(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithTopicWorkers(1))
+	defer brk.Close()
+
+	fast := client.NewWithOptions(time.Second, 3, "fast", client.WithTopics("fast"), client.WithQueueSize(10))
+	slow := client.NewWithOptions(time.Second, 3, "slow", client.WithTopics("slow"))
+
+	assert.NoError(t, brk.Subscribe(fast))
+	assert.NoError(t, brk.Subscribe(slow))
+
+	select {
+	case result := <-brk.BroadcastAsync(event.Event{Topic: "fast", Data: []byte("fast")}):
+		assert.NoError(t, result.Err)
+		assert.Equal(t, 1, result.Summary.Delivered)
+	case <-time.After(time.Second):
+		t.Fatal("event was not published")
+	}
+
+	saturated := make(chan broker.SystemEvent, 10)
+	brk.OnSystemEvent(func(se broker.SystemEvent) {
+		if se.Type == broker.SystemPublishQueueFull {
+			saturated <- se
+		}
+	})
+
+	// Saturating the slow topic's queue is reported immediately.
+	var result broker.BroadcastResult
+
+	for i := 0; i < 3 && result.Err == nil; i++ {
+		results := brk.BroadcastAsync(event.Event{Topic: "slow", Data: []byte("slow")})
+
+		select {
+		case result = <-results:
+		default:
+		}
+	}
+
+	assert.Equal(t, broker.ErrPublishQueueFull, result.Err)
+	assert.Equal(t, uint64(1), brk.Stats().Dispatch.Rejected)
+
+	select {
+	case se := <-saturated:
+		assert.Equal(t, []string{"slow"}, se.Topics)
+	case <-time.After(time.Second):
+		t.Fatal("saturation was not reported")
+	}
+}
+
+func TestBroker_WithAsyncPublishing(t *testing.T) {
+	var codes []broker.ErrorCode
+
+	eh := func(w http.ResponseWriter, r *http.Request, err error) {
+		codes = append(codes, err.(*broker.Error).Code)
+		w.WriteHeader(err.(*broker.Error).Status)
+	}
+
+	brk := broker.New(time.Second, 3, eh, broker.WithAsyncPublishing(true), broker.WithTopicWorkers(1))
+	defer brk.Close()
+
+	slow := client.NewWithOptions(time.Second, 3, "slow", client.WithTopics("slow"))
+	assert.NoError(t, brk.Subscribe(slow))
+
+	publish := func() int {
+		w := httptest.NewRecorder()
+		brk.EventHandler(w, httptest.NewRequest(http.MethodPost, "/broadcast?topic=slow", bytes.NewBufferString("hello")))
+
+		return w.Code
+	}
+
+	// The handler responds without waiting for the slow client.
+	started := time.Now()
+	assert.Equal(t, http.StatusAccepted, publish())
+	assert.True(t, time.Since(started) < time.Millisecond*500)
+
+	// Once the topic's queue is full, events are rejected.
+	code := http.StatusAccepted
+
+	for i := 0; i < 2 && code == http.StatusAccepted; i++ {
+		code = publish()
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []broker.ErrorCode{broker.CodePublishQueueFull}, codes)
+}
@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"io"
+
+	"github.com/davidsbond/sse/protocol"
+)
+
+type (
+	// PayloadEncoding determines how the broker writes payloads that cannot be represented
+	// as-is in an SSE stream, such as those containing newlines or bytes that are not valid
+	// UTF-8. See the protocol.Encoding type for details.
+	PayloadEncoding = protocol.Encoding
+)
+
+const (
+	// EncodingEscape writes each line of the payload as its own data field, or writes it as
+	// base64 if it could not be joined back together exactly. This is the default encoding.
+	EncodingEscape = protocol.EncodingEscape
+
+	// EncodingBase64 writes unsafe payloads as standard base64, preceded by an 'encoding'
+	// field set to 'base64'.
+	EncodingBase64 = protocol.EncodingBase64
+)
+
+// WithPayloadEncoding configures how the broker writes payloads that contain newlines or
+// are not valid UTF-8.
+func WithPayloadEncoding(enc PayloadEncoding) Option {
+	return func(b *defaultBroker) {
+		b.encoding = enc
+	}
+}
+
+// newEncoder returns the frame writer used to write events to a stream of the given content type,
+// see the negotiateFormat function.
+func (b *defaultBroker) newEncoder(w io.Writer, contentType string) FrameWriter {
+	if contentType == contentTypeNDJSON {
+		return protocol.NewNDJSONEncoder(w)
+	}
+
+	if b.frameWriter != nil {
+		return b.frameWriter(w)
+	}
+
+	encoder := protocol.NewEncoder(w)
+	encoder.SetEncoding(b.encoding)
+
+	return encoder
+}
@@ -0,0 +1,64 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithPayloadEncoding(t *testing.T) {
+	tt := []struct {
+		Encoding       broker.PayloadEncoding
+		Payloads       [][]byte
+		ExpectedOutput string
+	}{
+		{
+			Encoding: broker.EncodingEscape,
+			Payloads: [][]byte{
+				[]byte("hello world"),
+				[]byte("hello\nworld"),
+				[]byte("hello\r\n\r\nworld"),
+				{'a', 0xff, 'b'},
+			},
+			ExpectedOutput: "data: hello world\n\n" +
+				"data: hello\ndata: world\n\n" +
+				"encoding: base64\ndata: aGVsbG8NCg0Kd29ybGQ=\n\n" +
+				"encoding: base64\ndata: Yf9i\n\n",
+		},
+		{
+			Encoding: broker.EncodingBase64,
+			Payloads: [][]byte{
+				[]byte("hello world"),
+				[]byte("hello\nworld"),
+				{'a', 0xff, 'b'},
+			},
+			ExpectedOutput: "data: hello world\n\n" +
+				"encoding: base64\ndata: aGVsbG8Kd29ybGQ=\n\n" +
+				"encoding: base64\ndata: Yf9i\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil, broker.WithPayloadEncoding(tc.Encoding))
+		w := &FlushRecorder{header: http.Header{}}
+		r := httptest.NewRequest("GET", "/", nil)
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, r)
+		<-time.Tick(time.Second)
+
+		for _, payload := range tc.Payloads {
+			assert.NoError(t, broker.Broadcast(payload))
+		}
+
+		<-time.After(time.Millisecond * 100)
+
+		assert.Equal(t, tc.ExpectedOutput, w.String())
+		broker.Close()
+	}
+}
@@ -0,0 +1,79 @@
+package broker
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// SealFunc is a function that encrypts the data of an event written to a single client.
+	SealFunc func(data []byte) ([]byte, error)
+
+	// Encrypter is a function called when a client connects, returning the function used to encrypt
+	// the data of each event written to it, such as one using a key the client sent with its request
+	// or a key exchanged when it was authorized. If it returns a nil SealFunc, events are written to
+	// the client unencrypted. If it returns an error, the connection is rejected.
+	Encrypter func(r *http.Request, c *client.Client) (SealFunc, error)
+
+	// The sealingWriter type is a FrameWriter that encrypts the data of each event before writing it.
+	sealingWriter struct {
+		FrameWriter
+		seal SealFunc
+	}
+)
+
+// WithEncryption configures a function that establishes how the data of the events written to each
+// client is encrypted, for systems where events should be end-to-end encrypted rather than relying on
+// TLS alone. Events are encrypted as they are written to each client's stream, including replayed
+// events, so the broker stores & forwards the original data. Only the data is encrypted; the id, type
+// & other fields are written as they are, as are the broker's own events, whose types begin with
+// 'sse:', such as the handshake. Publishing events with those types is rejected with ErrReservedType,
+// so they cannot be used to skip encryption. As ciphertext is rarely valid UTF-8, the SealFunc should
+// return it encoded as text, such as base64. Events that cannot be encrypted are not written & are
+// reported to the delivery hook as failed.
+func WithEncryption(fn Encrypter) Option {
+	return func(b *defaultBroker) {
+		b.encrypter = fn
+	}
+}
+
+// encryption returns the function used to encrypt the data of the client's events, or nil if they
+// are written unencrypted.
+func (b *defaultBroker) encryption(r *http.Request, c *client.Client) (SealFunc, error) {
+	if b.encrypter == nil {
+		return nil, nil
+	}
+
+	return b.encrypter(r, c)
+}
+
+// sealed returns a FrameWriter that encrypts the data of each event using the SealFunc, or the
+// FrameWriter itself if the SealFunc is nil.
+func sealed(enc FrameWriter, seal SealFunc) FrameWriter {
+	if seal == nil {
+		return enc
+	}
+
+	return &sealingWriter{FrameWriter: enc, seal: seal}
+}
+
+// Encode encrypts the event's data & writes it to the stream. The broker's own events are written
+// as they are.
+func (w *sealingWriter) Encode(e event.Event) error {
+	if strings.HasPrefix(e.Type, systemEventPrefix) {
+		return w.FrameWriter.Encode(e)
+	}
+
+	data, err := w.seal(e.Data)
+
+	if err != nil {
+		return err
+	}
+
+	e.Data = data
+
+	return w.FrameWriter.Encode(e)
+}
@@ -0,0 +1,169 @@
+package broker_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithEncryption(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// The test cipher prefixes the data with the client's key, failing for data
+	// it cannot encrypt.
+	encrypter := func(r *http.Request, c *client.Client) (broker.SealFunc, error) {
+		key := r.URL.Query().Get("key")
+
+		if key == "" {
+			return nil, nil
+		}
+
+		return func(data []byte) ([]byte, error) {
+			if string(data) == "secret" {
+				return nil, errors.New("cannot encrypt")
+			}
+
+			return []byte(key + ":" + base64.StdEncoding.EncodeToString(data)), nil
+		}, nil
+	}
+
+	tt := []struct {
+		Name           string
+		Query          string
+		Data           []string
+		ExpectedOutput string
+		ExpectedFailed int
+	}{
+		{
+			Name:           "It should encrypt events using the client's key",
+			Query:          "&key=abc",
+			Data:           []string{"a", "b"},
+			ExpectedOutput: "id: 1\ndata: abc:YQ==\n\nid: 2\ndata: abc:Yg==\n\n",
+		},
+		{
+			Name:           "It should write events unencrypted without a seal function",
+			Data:           []string{"a", "b"},
+			ExpectedOutput: "id: 1\ndata: a\n\nid: 2\ndata: b\n\n",
+		},
+		{
+			Name:           "It should not write events that cannot be encrypted",
+			Query:          "&key=abc",
+			Data:           []string{"secret", "b"},
+			ExpectedOutput: "id: 2\ndata: abc:Yg==\n\n",
+			ExpectedFailed: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			failed := make(chan string, len(tc.Data))
+
+			brk := broker.New(time.Second, 3, nil,
+				broker.WithStore(store.NewMemory(10)),
+				broker.WithEncryption(encrypter),
+				broker.WithDeliveryHook(func(clientID, eventID string, result broker.DeliveryResult, latency time.Duration) {
+					if result == broker.DeliveryFailed {
+						failed <- eventID
+					}
+				}),
+			)
+			defer brk.Close()
+
+			for i, data := range tc.Data {
+				e := event.Event{ID: strconv.Itoa(i + 1), Data: []byte(data), Timestamp: start.Add(time.Minute * time.Duration(i))}
+				assert.NoError(t, brk.BroadcastEvent(e))
+			}
+
+			w := &FlushRecorder{header: http.Header{}}
+			r := httptest.NewRequest("GET", "/connect?since=2020-01-01T00:00:00Z"+tc.Query, nil)
+
+			go brk.ClientHandler(w, r)
+			<-time.After(time.Millisecond * 500)
+
+			assert.Equal(t, tc.ExpectedOutput, w.String())
+			assert.Len(t, failed, tc.ExpectedFailed)
+		})
+	}
+}
+
+func TestBroker_WithEncryptionRejected(t *testing.T) {
+	var code broker.ErrorCode
+
+	brk := broker.New(time.Second, 3, func(w http.ResponseWriter, r *http.Request, err error) {
+		code = err.(*broker.Error).Code
+	}, broker.WithEncryption(func(r *http.Request, c *client.Client) (broker.SealFunc, error) {
+		return nil, errors.New("no key")
+	}))
+	defer brk.Close()
+
+	brk.ClientHandler(&TestRecorder{header: http.Header{}}, httptest.NewRequest("GET", "/connect", nil))
+
+	assert.Equal(t, broker.CodeEncryptionFailed, code)
+	assert.Equal(t, 0, brk.Stats().Clients)
+}
+
+func TestBroker_WithEncryptionReservedTypes(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Event   event.Event
+		Options []broker.Option
+	}{
+		{
+			Name:  "It should reject events using the broker's reserved types",
+			Event: event.Event{Type: "sse:x", Data: []byte("plain")},
+		},
+		{
+			Name:  "It should reject events given a reserved type by an interceptor",
+			Event: event.Event{Type: "x", Data: []byte("plain")},
+			Options: []broker.Option{
+				broker.WithInterceptors(func(e event.Event) (event.Event, error) {
+					if e.Type == "x" {
+						e.Type = "sse:x"
+					}
+
+					return e, nil
+				}),
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := append(tc.Options, broker.WithEncryption(func(r *http.Request, c *client.Client) (broker.SealFunc, error) {
+				return func(data []byte) ([]byte, error) {
+					return []byte(base64.StdEncoding.EncodeToString(data)), nil
+				}, nil
+			}))
+
+			brk := broker.New(time.Second, 3, nil, opts...)
+			defer brk.Close()
+
+			w := &FlushRecorder{header: http.Header{}}
+			r := httptest.NewRequest("GET", "/connect?id=test", nil)
+
+			go brk.ClientHandler(w, r)
+			<-time.After(time.Millisecond * 100)
+
+			err := brk.BroadcastEvent(tc.Event)
+			assert.True(t, errors.Is(err, broker.ErrEventRejected))
+			assert.True(t, errors.Is(err, broker.ErrReservedType))
+
+			err = brk.BroadcastTo("test", tc.Event.Data)
+			assert.NoError(t, err)
+			<-time.After(time.Millisecond * 100)
+
+			assert.NotContains(t, w.String(), "plain")
+			assert.Contains(t, w.String(), "data: cGxhaW4=\n")
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type (
+	// The EndpointProvider interface describes types that provide their HTTP handlers as
+	// http.Handler values, which compose more naturally with middleware & route builders.
+	EndpointProvider interface {
+		ClientEndpoint(opts ...EndpointOption) http.Handler
+		EventEndpoint(opts ...EndpointOption) http.Handler
+		StreamEndpoint(opts ...EndpointOption) http.Handler
+	}
+
+	// EndpointOption is a function that modifies an endpoint's optional configuration.
+	EndpointOption func(*endpoint)
+
+	// The endpoint type is an http.Handler that restricts the methods its handler is called for
+	// & wraps it in middleware.
+	endpoint struct {
+		handler    http.Handler
+		methods    []string
+		middleware []func(http.Handler) http.Handler
+		onError    func(w http.ResponseWriter, r *http.Request, err error)
+	}
+)
+
+// Endpoint returns an http.Handler that calls the given handler function, configured using the
+// provided options. By default, requests using any method are accepted.
+func Endpoint(fn http.HandlerFunc, opts ...EndpointOption) http.Handler {
+	e := &endpoint{
+		onError: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusMethodNotAllowed)
+		},
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	// Apply the middleware so that the first given is the outermost.
+	e.handler = fn
+
+	for i := len(e.middleware) - 1; i >= 0; i-- {
+		e.handler = e.middleware[i](e.handler)
+	}
+
+	return e
+}
+
+// WithMethods restricts the endpoint to requests using one of the given methods. Other requests
+// receive a 405 status code along with an 'Allow' header listing the accepted methods. If no
+// methods are given, requests using any method are accepted.
+func WithMethods(methods ...string) EndpointOption {
+	return func(e *endpoint) {
+		e.methods = methods
+	}
+}
+
+// WithMiddleware wraps the endpoint's handler in the given middleware. The first middleware
+// given is the outermost, so is called first. Middleware is only called for requests using an
+// accepted method. This option can be used more than once.
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) EndpointOption {
+	return func(e *endpoint) {
+		e.middleware = append(e.middleware, middleware...)
+	}
+}
+
+// WithMethodChecks determines whether the broker's ClientHandler & EventHandler methods check the
+// method of each request, which they do by default. The ClientHandler only accepts GET & POST requests
+// & the EventHandler only accepts POST requests, responding to others with a 405 status code & an 'Allow'
+// header, so that they can be registered using http.HandleFunc without a router. Disable the checks
+// if the handlers are registered with a router that restricts methods differently. Endpoints, such as
+// those returned by the ClientEndpoint method, check methods using their own options instead.
+func WithMethodChecks(enabled bool) Option {
+	return func(b *defaultBroker) {
+		b.methodChecks = enabled
+	}
+}
+
+// ClientEndpoint returns an http.Handler that allows a client to connect to the broker. By default,
+// only GET & POST requests are accepted. See the broker's ClientHandler method for details.
+func (b *defaultBroker) ClientEndpoint(opts ...EndpointOption) http.Handler {
+	return Endpoint(b.serveClient, b.endpointOptions(opts, http.MethodGet, http.MethodPost)...)
+}
+
+// EventEndpoint returns an http.Handler that allows a client to broadcast an event to the broker.
+// By default, only POST requests are accepted. See the broker's EventHandler method for details.
+func (b *defaultBroker) EventEndpoint(opts ...EndpointOption) http.Handler {
+	return Endpoint(b.publishEvent, b.endpointOptions(opts, http.MethodPost)...)
+}
+
+// StreamEndpoint returns an http.Handler that serves clients & publishers from a single path. GET
+// requests, & POST requests whose 'Accept' header lists 'text/event-stream' or 'application/x-ndjson'
+// as fetch clients send, connect a client, see the broker's ClientHandler method. Requests using any
+// other accepted method broadcast an event, see the broker's EventHandler method. By default, only
+// GET & POST requests are accepted.
+//
+// Example using http (https://golang.org/pkg/net/http/)
+//
+// http.Handle("/events", broker.StreamEndpoint())
+func (b *defaultBroker) StreamEndpoint(opts ...EndpointOption) http.Handler {
+	return Endpoint(dispatch(b.serveClient, b.publishEvent), b.endpointOptions(opts, http.MethodGet, http.MethodPost)...)
+}
+
+// endpointOptions returns the options for one of the broker's endpoints, which accepts the given
+// methods unless the options say otherwise & reports errors using the broker's error handler.
+func (b *defaultBroker) endpointOptions(opts []EndpointOption, methods ...string) []EndpointOption {
+	onError := func(e *endpoint) {
+		e.onError = func(w http.ResponseWriter, r *http.Request, err error) {
+			b.httpError(w, r, CodeMethodNotAllowed, err, http.StatusMethodNotAllowed)
+		}
+	}
+
+	return append([]EndpointOption{WithMethods(methods...), onError}, opts...)
+}
+
+// dispatch returns a handler function that calls 'connect' for requests that open a stream & 'publish'
+// for any others.
+func dispatch(connect, publish http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if OpensStream(r) {
+			connect(w, r)
+			return
+		}
+
+		publish(w, r)
+	}
+}
+
+// allowMethod determines if the request uses one of the given methods, responding with a 405 status
+// code if it does not. If the broker does not check methods, all requests are allowed.
+func (b *defaultBroker) allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	if !b.methodChecks {
+		return true
+	}
+
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	b.httpError(w, r, CodeMethodNotAllowed, fmt.Errorf("method %v is not allowed", r.Method), http.StatusMethodNotAllowed)
+
+	return false
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !e.allowed(r.Method) {
+		w.Header().Set("Allow", strings.Join(e.methods, ", "))
+		e.onError(w, r, fmt.Errorf("method %v is not allowed", r.Method))
+		return
+	}
+
+	e.handler.ServeHTTP(w, r)
+}
+
+// allowed determines if the endpoint accepts requests using the given method.
+func (e *endpoint) allowed(method string) bool {
+	if len(e.methods) == 0 {
+		return true
+	}
+
+	for _, m := range e.methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,166 @@
+package broker_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_EventEndpoint(t *testing.T) {
+	tt := []struct {
+		Method        string
+		Options       []broker.EndpointOption
+		ExpectedCode  int
+		ExpectedAllow string
+		ExpectedCalls int
+	}{
+		{Method: "POST", ExpectedCode: http.StatusOK, ExpectedCalls: 1},
+		{Method: "GET", ExpectedCode: http.StatusMethodNotAllowed, ExpectedAllow: "POST"},
+		{Method: "PUT", Options: []broker.EndpointOption{broker.WithMethods("POST", "PUT")}, ExpectedCode: http.StatusOK, ExpectedCalls: 1},
+		{Method: "GET", Options: []broker.EndpointOption{broker.WithMethods()}, ExpectedCode: http.StatusOK, ExpectedCalls: 1},
+	}
+
+	for _, tc := range tt {
+		var calls int
+		var codes []broker.ErrorCode
+
+		eh := func(w http.ResponseWriter, r *http.Request, err error) {
+			codes = append(codes, err.(*broker.Error).Code)
+			w.WriteHeader(err.(*broker.Error).Status)
+		}
+
+		middleware := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				next.ServeHTTP(w, r)
+			})
+		}
+
+		b := broker.New(time.Second, 3, eh)
+		endpoint := b.EventEndpoint(append(tc.Options, broker.WithMiddleware(middleware))...)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tc.Method, "/broadcast", bytes.NewBufferString("hello"))
+
+		endpoint.ServeHTTP(w, r)
+
+		assert.Equal(t, tc.ExpectedCode, w.Code)
+		assert.Equal(t, tc.ExpectedAllow, w.Header().Get("Allow"))
+		assert.Equal(t, tc.ExpectedCalls, calls)
+
+		if tc.ExpectedCode == http.StatusMethodNotAllowed {
+			assert.Equal(t, []broker.ErrorCode{broker.CodeMethodNotAllowed}, codes)
+		}
+
+		b.Close()
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	var order []string
+
+	middleware := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	endpoint := broker.Endpoint(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}, broker.WithMiddleware(middleware("first"), middleware("second")), broker.WithMiddleware(middleware("third")))
+
+	endpoint.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, []string{"first", "second", "third", "handler"}, order)
+}
+
+func TestBroker_WithMethodChecks(t *testing.T) {
+	tt := []struct {
+		Name          string
+		Handler       func(b broker.Broker) http.HandlerFunc
+		Method        string
+		Options       []broker.Option
+		ExpectedCode  int
+		ExpectedAllow string
+	}{
+		{
+			Name:          "It should reject events published using GET",
+			Handler:       func(b broker.Broker) http.HandlerFunc { return b.EventHandler },
+			Method:        http.MethodGet,
+			ExpectedCode:  http.StatusMethodNotAllowed,
+			ExpectedAllow: "POST",
+		},
+		{
+			Name:          "It should reject clients connecting using PUT",
+			Handler:       func(b broker.Broker) http.HandlerFunc { return b.ClientHandler },
+			Method:        http.MethodPut,
+			ExpectedCode:  http.StatusMethodNotAllowed,
+			ExpectedAllow: "GET, POST",
+		},
+		{
+			Name:         "It should accept events published using POST",
+			Handler:      func(b broker.Broker) http.HandlerFunc { return b.EventHandler },
+			Method:       http.MethodPost,
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Name:         "It should accept any method when disabled",
+			Handler:      func(b broker.Broker) http.HandlerFunc { return b.EventHandler },
+			Method:       http.MethodPut,
+			Options:      []broker.Option{broker.WithMethodChecks(false)},
+			ExpectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil, tc.Options...)
+			defer b.Close()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.Method, "/events", bytes.NewBufferString("hello"))
+
+			tc.Handler(b)(w, r)
+
+			assert.Equal(t, tc.ExpectedCode, w.Code)
+			assert.Equal(t, tc.ExpectedAllow, w.Header().Get("Allow"))
+		})
+	}
+}
+
+func TestBroker_StreamEndpoint(t *testing.T) {
+	b := broker.New(time.Second, 3, nil)
+	defer b.Close()
+
+	endpoint := b.StreamEndpoint()
+
+	// GET requests connect a client.
+	w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+	go endpoint.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	<-time.After(time.Millisecond * 100)
+
+	// POST requests publish an event.
+	pw := httptest.NewRecorder()
+	endpoint.ServeHTTP(pw, httptest.NewRequest(http.MethodPost, "/events", bytes.NewBufferString("hello")))
+	<-time.After(time.Millisecond * 100)
+
+	assert.Equal(t, http.StatusOK, pw.Code)
+	assert.Contains(t, w.String(), "data: hello\n\n")
+
+	// Other methods are rejected.
+	dw := httptest.NewRecorder()
+	endpoint.ServeHTTP(dw, httptest.NewRequest(http.MethodDelete, "/events", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, dw.Code)
+	assert.Equal(t, "GET, POST", dw.Header().Get("Allow"))
+
+	close(w.close)
+}
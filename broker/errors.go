@@ -0,0 +1,233 @@
+package broker
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// ErrorCode is a machine-readable code describing an error raised by one of the broker's
+	// HTTP handlers.
+	ErrorCode string
+
+	// The Error type is passed to the broker's ErrorHandler when an HTTP error occurs. It
+	// carries a machine-readable code & the locale preferred by the client, so that custom
+	// error handlers can render localized, structured errors without parsing error messages.
+	Error struct {
+		Code       ErrorCode     // A machine-readable code describing the error.
+		Status     int           // The HTTP status code the broker would use for the error.
+		Locale     string        // The client's preferred locale from the Accept-Language header, if any.
+		RetryAfter time.Duration // How long the client should wait before retrying, if set by the broker or its ErrorPolicy.
+		Err        error         // The underlying error.
+	}
+
+	// The ErrorResponse type describes how the broker should respond to an HTTP error.
+	ErrorResponse struct {
+		Status     int           // The HTTP status code to respond with. If zero, the broker's default is used.
+		RetryAfter time.Duration // If set, the Retry-After header tells the client how long to wait before retrying.
+	}
+
+	// ErrorPolicy is a function that chooses how the broker responds to an HTTP error, given the
+	// request & the error, which carries its code & the status the broker would use by default.
+	ErrorPolicy func(r *http.Request, err *Error) ErrorResponse
+)
+
+const (
+	// CodeStreamingUnsupported indicates the response writer does not support streaming.
+	CodeStreamingUnsupported ErrorCode = "streaming_unsupported"
+
+	// CodeUnauthorized indicates the request was rejected by the broker's authorizer.
+	CodeUnauthorized ErrorCode = "unauthorized"
+
+	// CodeClientConflict indicates a client with the requested identifier is already connected.
+	CodeClientConflict ErrorCode = "client_conflict"
+
+	// CodeInvalidLimits indicates the client requested a timeout or tolerance that is malformed
+	// or was rejected.
+	CodeInvalidLimits ErrorCode = "invalid_limits"
+
+	// CodeInvalidSubscription indicates a subscription change could not be read from the request.
+	CodeInvalidSubscription ErrorCode = "invalid_subscription"
+
+	// CodeUnknownClient indicates no client with the requested identifier is connected.
+	CodeUnknownClient ErrorCode = "unknown_client"
+
+	// CodeInvalidReplay indicates the time to replay stored events from could not be read from the request.
+	CodeInvalidReplay ErrorCode = "invalid_replay"
+
+	// CodeEncryptionFailed indicates the key used to encrypt events for the client could not be established.
+	CodeEncryptionFailed ErrorCode = "encryption_failed"
+
+	// CodeHistoryUnavailable indicates stored events could not be read.
+	CodeHistoryUnavailable ErrorCode = "history_unavailable"
+
+	// CodeQuotaExceeded indicates a tenant or publisher key has reached its maximum number of clients or
+	// rate of events.
+	CodeQuotaExceeded ErrorCode = "quota_exceeded"
+
+	// CodeClusterUnavailable indicates the broker is not part of a cluster.
+	CodeClusterUnavailable ErrorCode = "cluster_unavailable"
+
+	// CodeInvalidClusterRequest indicates a request from another member of the cluster was malformed.
+	CodeInvalidClusterRequest ErrorCode = "invalid_cluster_request"
+
+	// CodeMethodNotAllowed indicates the request used a method the endpoint does not accept.
+	CodeMethodNotAllowed ErrorCode = "method_not_allowed"
+
+	// CodeInvalidEvent indicates the event data could not be read from the request.
+	CodeInvalidEvent ErrorCode = "invalid_event"
+
+	// CodePublishFailed indicates the event could not be delivered to one or more clients.
+	CodePublishFailed ErrorCode = "publish_failed"
+
+	// CodeEventRejected indicates the event was rejected by one of the broker's interceptors.
+	CodeEventRejected ErrorCode = "event_rejected"
+
+	// CodePublishQueueFull indicates the event could not be queued because its topic's queue is full.
+	CodePublishQueueFull ErrorCode = "publish_queue_full"
+
+	// CodeInvalidKey indicates a publisher key could not be read from the request.
+	CodeInvalidKey ErrorCode = "invalid_key"
+
+	// CodeUnknownKey indicates the publisher key to revoke does not exist.
+	CodeUnknownKey ErrorCode = "unknown_key"
+
+	// CodeKeysUnavailable indicates publisher keys are not enabled or could not be read or recorded.
+	CodeKeysUnavailable ErrorCode = "keys_unavailable"
+
+	// CodeTopicForbidden indicates the publisher's key does not allow publishing to the event's topic,
+	// or that the client is not allowed to subscribe to the admin topic.
+	CodeTopicForbidden ErrorCode = "topic_forbidden"
+
+	// CodePayloadTooLarge indicates the event is larger than the publisher's key allows.
+	CodePayloadTooLarge ErrorCode = "payload_too_large"
+
+	// CodeBackpressure indicates the event was rejected because the broker's clients cannot keep up
+	// with the events published.
+	CodeBackpressure ErrorCode = "backpressure"
+
+	// CodeMemoryBudget indicates the event was rejected because the broker's memory budget is exceeded.
+	CodeMemoryBudget ErrorCode = "memory_budget"
+)
+
+// Error returns the message of the underlying error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithErrorPolicy configures a function that chooses the HTTP status code the broker responds with
+// when one of its handlers fails, & whether to tell the client when to retry using the Retry-After
+// header. The policy is given the error's machine-readable code, so that, for example, rejected
+// connections can be answered with a 503 & a delay rather than the broker's default status. The
+// chosen status & delay are also set on the *Error passed to the broker's ErrorHandler, if it has one.
+func WithErrorPolicy(fn ErrorPolicy) Option {
+	return func(b *defaultBroker) {
+		b.errorPolicy = fn
+	}
+}
+
+func (b *defaultBroker) httpError(w http.ResponseWriter, r *http.Request, code ErrorCode, err error, status int) {
+	e := &Error{
+		Code:   code,
+		Status: status,
+		Locale: preferredLocale(r.Header.Get("Accept-Language")),
+		Err:    err,
+	}
+
+	// Publishers rejected due to backpressure are told when to retry, unless the policy says otherwise.
+	var pressure *BackpressureError
+
+	if errors.As(err, &pressure) {
+		e.RetryAfter = pressure.RetryAfter
+	}
+
+	// Those rejected by the memory budget are told to retry once usage has next been measured.
+	if errors.Is(err, ErrMemoryBudget) && b.memory != nil {
+		e.RetryAfter = b.memory.config.Interval
+	}
+
+	if b.errorPolicy != nil {
+		resp := b.errorPolicy(r, e)
+
+		if resp.Status != 0 {
+			e.Status = resp.Status
+		}
+
+		if resp.RetryAfter != 0 {
+			e.RetryAfter = resp.RetryAfter
+		}
+	}
+
+	if e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", retryAfter(e.RetryAfter))
+	}
+
+	if b.errorHandler != nil {
+		b.errorHandler(w, r, e)
+		return
+	}
+
+	http.Error(w, err.Error(), e.Status)
+}
+
+// retryAfter formats the duration as the value of a Retry-After header, in whole seconds rounded up.
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// preferredLocale returns the language tag with the highest quality value from an
+// Accept-Language header. If multiple tags share the highest quality value, the first
+// is returned. If no tags are present, a blank string is returned.
+func preferredLocale(header string) string {
+	type tag struct {
+		name string
+		q    float64
+	}
+
+	var tags []tag
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.TrimSpace(fields[0])
+
+		if name == "" || name == "*" {
+			continue
+		}
+
+		q := 1.0
+
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if q > 0 {
+			tags = append(tags, tag{name: name, q: q})
+		}
+	}
+
+	if len(tags) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	return tags[0].name
+}
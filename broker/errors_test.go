@@ -0,0 +1,112 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_ErrorHandler(t *testing.T) {
+	tt := []struct {
+		AcceptLanguage string
+		ExpectedLocale string
+		ExpectedCode   broker.ErrorCode
+		ExpectedStatus int
+	}{
+		{
+			AcceptLanguage: "fr-CH, fr;q=0.9, en;q=0.8",
+			ExpectedLocale: "fr-CH",
+			ExpectedCode:   broker.CodeStreamingUnsupported,
+			ExpectedStatus: http.StatusInternalServerError,
+		},
+		{
+			AcceptLanguage: "en;q=0.5, de;q=0.9, *",
+			ExpectedLocale: "de",
+			ExpectedCode:   broker.CodeStreamingUnsupported,
+			ExpectedStatus: http.StatusInternalServerError,
+		},
+		{
+			ExpectedLocale: "",
+			ExpectedCode:   broker.CodeStreamingUnsupported,
+			ExpectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tt {
+		var received *broker.Error
+
+		handler := func(w http.ResponseWriter, r *http.Request, err error) {
+			received, _ = err.(*broker.Error)
+		}
+
+		broker := broker.New(time.Second, 3, handler)
+		r := httptest.NewRequest("GET", "/connect", nil)
+
+		if tc.AcceptLanguage != "" {
+			r.Header.Set("Accept-Language", tc.AcceptLanguage)
+		}
+
+		// The default recorder does not support streaming.
+		broker.ClientHandler(httptest.NewRecorder(), r)
+
+		if assert.NotNil(t, received) {
+			assert.Equal(t, tc.ExpectedCode, received.Code)
+			assert.Equal(t, tc.ExpectedStatus, received.Status)
+			assert.Equal(t, tc.ExpectedLocale, received.Locale)
+			assert.Equal(t, "client does not support streaming", received.Error())
+		}
+	}
+}
+
+func TestBroker_WithErrorPolicy(t *testing.T) {
+	policy := func(r *http.Request, err *broker.Error) broker.ErrorResponse {
+		if err.Code == broker.CodeStreamingUnsupported {
+			return broker.ErrorResponse{Status: http.StatusServiceUnavailable, RetryAfter: time.Millisecond * 1500}
+		}
+
+		return broker.ErrorResponse{}
+	}
+
+	tt := []struct {
+		Name               string
+		Handler            broker.ErrorHandler
+		ExpectedStatus     int
+		ExpectedRetryAfter string
+	}{
+		{
+			Name:               "It should use the status & delay chosen by the policy",
+			ExpectedStatus:     http.StatusServiceUnavailable,
+			ExpectedRetryAfter: "2",
+		},
+		{
+			Name: "It should pass the chosen status & delay to the error handler",
+			Handler: func(w http.ResponseWriter, r *http.Request, err error) {
+				e := err.(*broker.Error)
+
+				assert.Equal(t, broker.CodeStreamingUnsupported, e.Code)
+				assert.Equal(t, time.Millisecond*1500, e.RetryAfter)
+				w.WriteHeader(e.Status)
+			},
+			ExpectedStatus:     http.StatusServiceUnavailable,
+			ExpectedRetryAfter: "2",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, tc.Handler, broker.WithErrorPolicy(policy))
+			defer brk.Close()
+
+			// The default recorder does not support streaming.
+			w := httptest.NewRecorder()
+			brk.ClientHandler(w, httptest.NewRequest(http.MethodGet, "/connect", nil))
+
+			assert.Equal(t, tc.ExpectedStatus, w.Code)
+			assert.Equal(t, tc.ExpectedRetryAfter, w.Header().Get("Retry-After"))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package broker
+
+import (
+	"expvar"
+	"net/http"
+)
+
+type (
+	// The expvarTopic type is the representation of a topic's statistics published by the
+	// PublishExpvar function.
+	expvarTopic struct {
+		Subscribers     int     `json:"subscribers"`
+		Events          uint64  `json:"events"`
+		EventsPerSecond float64 `json:"events_per_second"`
+		PayloadBytes    float64 `json:"payload_bytes"`
+		BytesSent       uint64  `json:"bytes_sent"`
+	}
+)
+
+// PublishExpvar publishes the broker's statistics as expvar variables whose names begin with the
+// prefix, such as 'sse.', for services that read /debug/vars rather than scraping Prometheus. The
+// number of connected clients, pending events, events delivered, events that failed & bytes written
+// are published as numbers, and the statistics of each topic are published as an object keyed by
+// topic. The variables read the broker's statistics each time they are served. As with expvar.Publish,
+// publishing a variable with a name that is already in use panics, so each prefix can only be used
+// once per process.
+func PublishExpvar(b Broker, prefix string) {
+	vars := map[string]func(Stats) interface{}{
+		"clients": func(stats Stats) interface{} {
+			return stats.Clients
+		},
+		"pending_events": func(stats Stats) interface{} {
+			_, _, pending := stats.totals()
+			return pending
+		},
+		"events_delivered": func(stats Stats) interface{} {
+			delivered, _, _ := stats.totals()
+			return delivered
+		},
+		"events_failed": func(stats Stats) interface{} {
+			_, failed, _ := stats.totals()
+			return failed
+		},
+		"bytes_sent": func(stats Stats) interface{} {
+			return stats.BytesSent
+		},
+		"topics": func(stats Stats) interface{} {
+			topics := make(map[string]expvarTopic, len(stats.Topics))
+
+			for topic, ts := range stats.Topics {
+				topics[topic] = expvarTopic{
+					Subscribers:     ts.Subscribers,
+					Events:          ts.Events,
+					EventsPerSecond: ts.EventsPerSecond,
+					PayloadBytes:    ts.AveragePayload,
+					BytesSent:       ts.BytesSent,
+				}
+			}
+
+			return topics
+		},
+	}
+
+	for name, value := range vars {
+		value := value
+		expvar.Publish(prefix+name, expvar.Func(func() interface{} { return value(b.Stats()) }))
+	}
+}
+
+// ExpvarHandler publishes the broker's statistics using the PublishExpvar function & returns an
+// http.Handler serving every expvar variable as JSON, in the same way as /debug/vars, so that they
+// can be mounted alongside the broker's handlers without using the default ServeMux.
+//
+// Example using http (https://golang.org/pkg/net/http/)
+//
+// http.Handle("/vars", broker.ExpvarHandler(b, "sse."))
+func ExpvarHandler(b Broker, prefix string) http.Handler {
+	PublishExpvar(b, prefix)
+
+	return expvar.Handler()
+}
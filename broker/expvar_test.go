@@ -0,0 +1,66 @@
+package broker_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// expvarTopic is the representation of a topic's statistics served by the ExpvarHandler.
+	expvarTopic struct {
+		Subscribers  int     `json:"subscribers"`
+		Events       uint64  `json:"events"`
+		PayloadBytes float64 `json:"payload_bytes"`
+	}
+)
+
+func TestExpvarHandler(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithShards(1))
+	defer brk.Close()
+
+	assert.NoError(t, brk.Subscribe(client.NewWithOptions(time.Second, 3, "", client.WithTopics("news"), client.WithQueueSize(10))))
+	assert.NoError(t, brk.BroadcastTopic("news", []byte("hello")))
+	assert.NoError(t, brk.BroadcastTopic("news", []byte("hi")))
+
+	// Variables cannot be unpublished, so each run of the test uses its own prefix.
+	prefix := fmt.Sprintf("test%v.", time.Now().UnixNano())
+	handler := broker.ExpvarHandler(brk, prefix)
+
+	read := func() map[string]json.RawMessage {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/vars", nil))
+
+		vars := make(map[string]json.RawMessage)
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&vars))
+
+		return vars
+	}
+
+	vars := read()
+
+	assert.Equal(t, "1", string(vars[prefix+"clients"]))
+	assert.Equal(t, "2", string(vars[prefix+"pending_events"]))
+
+	var topics map[string]expvarTopic
+
+	if assert.NoError(t, json.Unmarshal(vars[prefix+"topics"], &topics)) {
+		assert.Equal(t, expvarTopic{Subscribers: 1, Events: 2, PayloadBytes: 3.5}, topics["news"])
+	}
+
+	// The variables read the broker's statistics each time they are served.
+	assert.NoError(t, brk.BroadcastTopic("news", []byte("hey")))
+
+	vars = read()
+
+	if assert.NoError(t, json.Unmarshal(vars[prefix+"topics"], &topics)) {
+		assert.Equal(t, uint64(3), topics["news"].Events)
+	}
+}
@@ -0,0 +1,304 @@
+package broker
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The fanout type is a group of clients that an event can be broadcast to. Clients are
+	// partitioned into shards that are each written to by their own goroutine, so a group
+	// with a very large number of clients does not serialize through a single loop.
+	fanout struct {
+		shards []*shard
+		rate   topicRate
+	}
+
+	// The shard type is a subset of the clients within a fanout group.
+	shard struct {
+		mux       sync.RWMutex
+		clients   map[string]*client.Client
+		clock     clock.Clock
+		delivered uint64
+		failed    uint64
+	}
+
+	// The delivery type contains the outcome of broadcasting an event to a fanout group.
+	delivery struct {
+		errors    []string
+		evicted   []*client.Client
+		delivered int  // The number of clients the event was written to.
+		excluded  int  // The number of clients skipped because the event excludes them.
+		exceeded  bool // Whether the broadcast returned early because its error budget was exceeded.
+	}
+
+	// The exclusion type contains the ids of the clients an event is not written to. Long lists
+	// are indexed once per broadcast, so that checking each client does not search the list.
+	exclusion struct {
+		ids []string
+		set map[string]struct{}
+	}
+)
+
+const (
+	// The number of excluded ids above which they are indexed rather than searched.
+	exclusionIndexSize = 16
+
+	// The parameters of the 32-bit FNV-1a hash used to choose a client's shard.
+	fnvOffset32 = 2166136261
+	fnvPrime32  = 16777619
+)
+
+// Lists of clients are reused between broadcasts, so that broadcasting to a shard does not
+// allocate.
+var clientLists = sync.Pool{New: func() interface{} { return new([]*client.Client) }}
+
+// WithShards configures the number of shards that the clients of each topic are partitioned
+// into. Each shard is written to concurrently when an event is broadcast. If 'n' is zero,
+// the number of CPUs is used.
+func WithShards(n int) Option {
+	return func(b *defaultBroker) {
+		b.shards = n
+	}
+}
+
+func newFanout(n int, clk clock.Clock) *fanout {
+	f := &fanout{shards: make([]*shard, n)}
+
+	for i := range f.shards {
+		f.shards[i] = &shard{clients: make(map[string]*client.Client), clock: clk}
+	}
+
+	return f
+}
+
+// add places the client into its shard.
+func (f *fanout) add(c *client.Client) {
+	s := f.shard(c.ID())
+
+	s.mux.Lock()
+	s.clients[c.ID()] = c
+	s.mux.Unlock()
+}
+
+// remove removes the client from its shard.
+func (f *fanout) remove(c *client.Client) {
+	s := f.shard(c.ID())
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	// Another client with the same identifier may have replaced this one.
+	if s.clients[c.ID()] == c {
+		delete(s.clients, c.ID())
+	}
+}
+
+// len returns the number of clients in the group.
+func (f *fanout) len() int {
+	var n int
+
+	for _, s := range f.shards {
+		s.mux.RLock()
+		n += len(s.clients)
+		s.mux.RUnlock()
+	}
+
+	return n
+}
+
+// broadcast writes the event to every client in the group, dispatching each shard on
+// its own goroutine and waiting for all of them to finish. If 'hook' is set, it is called
+// for each client the event could not be written to.
+func (f *fanout) broadcast(e event.Event, hook DeliveryHook) delivery {
+	except := newExclusion(e.Except)
+
+	if len(f.shards) == 1 {
+		return f.shards[0].broadcast(e, hook, except)
+	}
+
+	return f.broadcastShards(e, hook, except)
+}
+
+// broadcastShards writes the event to each shard on its own goroutine. It is kept separate from
+// the broadcast method so that the event only escapes to the heap when there are several shards.
+func (f *fanout) broadcastShards(e event.Event, hook DeliveryHook, except exclusion) delivery {
+	var wg sync.WaitGroup
+	results := make([]delivery, len(f.shards))
+
+	for i, s := range f.shards {
+		wg.Add(1)
+
+		go func(i int, s *shard) {
+			defer wg.Done()
+			results[i] = s.broadcast(e, hook, except)
+		}(i, s)
+	}
+
+	wg.Wait()
+
+	var out delivery
+
+	for _, result := range results {
+		out.errors = append(out.errors, result.errors...)
+		out.evicted = append(out.evicted, result.evicted...)
+		out.delivered += result.delivered
+		out.excluded += result.excluded
+	}
+
+	return out
+}
+
+// stats returns the statistics for each shard in the group.
+func (f *fanout) stats() []ShardStats {
+	out := make([]ShardStats, len(f.shards))
+
+	for i, s := range f.shards {
+		s.mux.RLock()
+		out[i].Clients = len(s.clients)
+		s.mux.RUnlock()
+
+		out[i].Delivered = atomic.LoadUint64(&s.delivered)
+		out[i].Failed = atomic.LoadUint64(&s.failed)
+	}
+
+	return out
+}
+
+func (f *fanout) shard(id string) *shard {
+	if len(f.shards) == 1 {
+		return f.shards[0]
+	}
+
+	// Hash the identifier using FNV-1a, inlined so that choosing a shard does not allocate.
+	h := uint32(fnvOffset32)
+
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= fnvPrime32
+	}
+
+	return f.shards[h%uint32(len(f.shards))]
+}
+
+// broadcast writes the event to each client within the shard, other than those it excludes.
+// Clients that exceed their error tolerance are reported as evicted so that the broker can
+// disconnect them.
+func (s *shard) broadcast(e event.Event, hook DeliveryHook, except exclusion) delivery {
+	var out delivery
+
+	list := clientLists.Get().(*[]*client.Client)
+	*list = s.appendClients((*list)[:0])
+	defer releaseClients(list)
+
+	for _, c := range *list {
+		if except.contains(c.ID()) {
+			out.excluded++
+			continue
+		}
+
+		if err := s.write(c, e, hook); err != nil {
+			out.errors = append(out.errors, err.Error())
+
+			// If an error occured, check if we should force
+			// disconnect the client.
+			if c.ShouldDisconnect() {
+				out.evicted = append(out.evicted, c)
+			}
+
+			continue
+		}
+
+		out.delivered++
+	}
+
+	return out
+}
+
+// list returns a copy of the clients within the shard, so that the shard isn't locked while
+// writing, which may take up to the timeout for each client.
+func (s *shard) list() []*client.Client {
+	return s.appendClients(nil)
+}
+
+// appendClients appends the clients within the shard to 'dst', returning the extended slice.
+func (s *shard) appendClients(dst []*client.Client) []*client.Client {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if dst == nil {
+		dst = make([]*client.Client, 0, len(s.clients))
+	}
+
+	for _, c := range s.clients {
+		dst = append(dst, c)
+	}
+
+	return dst
+}
+
+// releaseClients clears the list, so that it does not keep disconnected clients alive, &
+// returns it to the pool.
+func releaseClients(list *[]*client.Client) {
+	for i := range *list {
+		(*list)[i] = nil
+	}
+
+	*list = (*list)[:0]
+	clientLists.Put(list)
+}
+
+// write writes the event to a client within the shard, recording the outcome. If 'hook' is
+// set, it is called if the event could not be written.
+func (s *shard) write(c *client.Client, e event.Event, hook DeliveryHook) error {
+	started := s.clock.Now()
+
+	// Attempt to write data to the client
+	if err := c.WriteEvent(e); err != nil {
+		atomic.AddUint64(&s.failed, 1)
+
+		if hook != nil {
+			hook(c.ID(), e.ID, DeliveryRejected, clock.Since(s.clock, started))
+		}
+
+		return err
+	}
+
+	atomic.AddUint64(&s.delivered, 1)
+
+	return nil
+}
+
+func newExclusion(ids []string) exclusion {
+	except := exclusion{ids: ids}
+
+	if len(ids) > exclusionIndexSize {
+		except.set = make(map[string]struct{}, len(ids))
+
+		for _, id := range ids {
+			except.set[id] = struct{}{}
+		}
+	}
+
+	return except
+}
+
+// contains determines if the client with the given id is excluded.
+func (x exclusion) contains(id string) bool {
+	if x.set != nil {
+		_, ok := x.set[id]
+		return ok
+	}
+
+	for _, except := range x.ids {
+		if except == id {
+			return true
+		}
+	}
+
+	return false
+}
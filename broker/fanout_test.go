@@ -0,0 +1,187 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_BroadcastTopic(t *testing.T) {
+	tt := []struct {
+		Shards         int
+		Topic          string
+		Subscriptions  []string
+		ExpectedOutput []string
+	}{
+		{
+			Shards:         1,
+			Topic:          "a",
+			Subscriptions:  []string{"?topic=a", "?topic=b", "?topic=a&topic=b", ""},
+			ExpectedOutput: []string{"data: hello world\n\n", "", "data: hello world\n\n", ""},
+		},
+		{
+			Shards:         4,
+			Topic:          "c",
+			Subscriptions:  []string{"?topic=a", "?topic=b"},
+			ExpectedOutput: []string{"", ""},
+		},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil, broker.WithShards(tc.Shards))
+		recorders := make([]*FlushRecorder, len(tc.Subscriptions))
+
+		for i, query := range tc.Subscriptions {
+			recorders[i] = &FlushRecorder{header: http.Header{}}
+			go broker.ClientHandler(recorders[i], httptest.NewRequest("GET", "/connect"+query, nil))
+		}
+
+		// Give the broker 1 second to create the clients
+		<-time.Tick(time.Second)
+
+		assert.NoError(t, broker.BroadcastTopic(tc.Topic, []byte("hello world")))
+		<-time.After(time.Millisecond * 100)
+
+		for i, w := range recorders {
+			assert.Equal(t, tc.ExpectedOutput[i], w.String())
+		}
+
+		broker.Close()
+	}
+}
+
+func TestBroker_Stats(t *testing.T) {
+	tt := []struct {
+		Shards  int
+		Clients int
+		Topic   string
+	}{
+		{Shards: 1, Clients: 3, Topic: "a"},
+		{Shards: 4, Clients: 20, Topic: "b"},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil, broker.WithShards(tc.Shards))
+
+		for i := 0; i < tc.Clients; i++ {
+			w := &FlushRecorder{header: http.Header{}}
+			go broker.ClientHandler(w, httptest.NewRequest("GET", "/connect?topic="+tc.Topic, nil))
+		}
+
+		// Give the broker 1 second to create the clients
+		<-time.Tick(time.Second)
+
+		assert.NoError(t, broker.Broadcast([]byte("hello world")))
+
+		stats := broker.Stats()
+
+		assert.Equal(t, tc.Clients, stats.Clients)
+		assert.Len(t, stats.Lag, tc.Clients)
+		assert.Len(t, stats.Shards, tc.Shards)
+		assert.Equal(t, tc.Clients, stats.Topics[tc.Topic].Subscribers)
+		assert.Len(t, stats.Topics[tc.Topic].Shards, tc.Shards)
+
+		var clients int
+		var delivered uint64
+
+		for _, shard := range stats.Shards {
+			clients += shard.Clients
+			delivered += shard.Delivered
+		}
+
+		assert.Equal(t, tc.Clients, clients)
+		assert.Equal(t, uint64(tc.Clients), delivered)
+
+		broker.Close()
+		assert.Equal(t, 0, broker.Stats().Clients)
+		assert.Empty(t, broker.Stats().Topics)
+	}
+}
+
+func TestBroker_BroadcastExcept(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Shards   int
+		Except   []string
+		Budget   bool
+		Expected []string
+	}{
+		{
+			Name:     "It should not write the event to excluded clients",
+			Shards:   1,
+			Except:   []string{"a"},
+			Expected: []string{"b", "c"},
+		},
+		{
+			Name:     "It should exclude clients across shards",
+			Shards:   4,
+			Except:   []string{"a", "c"},
+			Expected: []string{"b"},
+		},
+		{
+			Name:     "It should index long lists of excluded clients",
+			Shards:   2,
+			Except:   append([]string{"b"}, strings.Split("0 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16", " ")...),
+			Expected: []string{"a", "c"},
+		},
+		{
+			Name:     "It should exclude clients when broadcasting within a budget",
+			Shards:   2,
+			Except:   []string{"b"},
+			Budget:   true,
+			Expected: []string{"a", "c"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, broker.WithShards(tc.Shards))
+			defer brk.Close()
+
+			clients := make(map[string]*client.Client)
+
+			for _, id := range []string{"a", "b", "c"} {
+				clients[id] = client.NewWithOptions(time.Second, 3, id, client.WithQueueSize(10))
+				assert.NoError(t, brk.Subscribe(clients[id]))
+			}
+
+			if tc.Budget {
+				e := event.Event{Data: []byte("hello"), Except: tc.Except}
+				assert.NoError(t, brk.BroadcastWithin(e, broker.ErrorBudget{Concurrency: 1}))
+			} else {
+				assert.NoError(t, brk.BroadcastExcept(tc.Except, []byte("hello")))
+			}
+
+			var received []string
+
+			for _, id := range []string{"a", "b", "c"} {
+				if clients[id].Lag().Pending > 0 {
+					received = append(received, id)
+				}
+			}
+
+			assert.Equal(t, tc.Expected, received)
+		})
+	}
+}
+
+func TestBroker_BroadcastSummaryExcept(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithShards(2))
+	defer brk.Close()
+
+	for _, id := range []string{"a", "b", "c"} {
+		assert.NoError(t, brk.Subscribe(client.NewWithOptions(time.Second, 3, id, client.WithQueueSize(10))))
+	}
+
+	summary, err := brk.BroadcastSummary(event.Event{Data: []byte("hello"), Except: []string{"a", "b"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Delivered)
+	assert.Equal(t, 2, summary.Skipped)
+}
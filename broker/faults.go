@@ -0,0 +1,150 @@
+package broker
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The FaultConfig type describes the faults injected into client streams, see the broker.WithFaults
+	// method. The zero value injects no faults.
+	FaultConfig struct {
+		DropEvery      int           // Silently drop every Nth event written to each stream. Zero drops none.
+		Latency        time.Duration // Delay each event written to a stream by this duration.
+		Jitter         time.Duration // Delay each event by up to this duration more, chosen at random.
+		DisconnectRate float64       // The probability, from 0 to 1, that writing an event drops the stream.
+		Seed           int64         // Seeds the random faults so that a test run can be repeated. Zero uses a random seed.
+	}
+
+	// The faults type injects the configured faults into client streams, sharing a single source of
+	// randomness between them.
+	faults struct {
+		config FaultConfig
+		mux    sync.Mutex
+		rand   *rand.Rand
+	}
+
+	// The faultyWriter type is a FrameWriter that drops, delays or disconnects the events it writes.
+	faultyWriter struct {
+		FrameWriter
+		broker *defaultBroker
+		writes int
+		cut    chan struct{}
+	}
+)
+
+var (
+	// errStreamCut is the error returned when writing to a stream that an injected fault has dropped.
+	errStreamCut = errors.New("the stream was dropped by an injected fault")
+)
+
+// WithFaults configures the broker to misbehave while writing events to client streams, so that
+// applications can verify their reconnect & replay logic against a broker that drops events, is slow
+// or loses connections. Faults only apply to events written to streams opened using the ClientHandler,
+// heartbeats & retry instructions are written as normal. Dropped events are reported as written, in
+// the same way as events lost by the network, and a dropped stream ends without telling the client
+// to reconnect. This is intended for tests & staging environments, it should not be enabled in
+// production.
+func WithFaults(cfg FaultConfig) Option {
+	return func(b *defaultBroker) {
+		if cfg == (FaultConfig{}) {
+			b.faults = nil
+			return
+		}
+
+		seed := cfg.Seed
+
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+
+		b.faults = &faults{config: cfg, rand: rand.New(rand.NewSource(seed))}
+	}
+}
+
+// inject returns a FrameWriter that injects the broker's faults into the client's stream, along with
+// a channel that is closed once a fault drops the stream. If the broker has no faults, the FrameWriter
+// itself is returned with a channel that is never closed.
+func (b *defaultBroker) inject(enc FrameWriter) (FrameWriter, <-chan struct{}) {
+	if b.faults == nil {
+		return enc, nil
+	}
+
+	w := &faultyWriter{FrameWriter: enc, broker: b, cut: make(chan struct{})}
+
+	return w, w.cut
+}
+
+// Encode writes the event to the stream, unless it is dropped or the stream has been cut. Writes are
+// delayed by the configured latency first.
+func (w *faultyWriter) Encode(e event.Event) error {
+	select {
+	case <-w.cut:
+		return errStreamCut
+	default:
+	}
+
+	f := w.broker.faults
+
+	if !w.broker.sleep(f.delay()) {
+		return errStreamCut
+	}
+
+	if f.disconnect() {
+		close(w.cut)
+		return errStreamCut
+	}
+
+	w.writes++
+
+	if f.config.DropEvery > 0 && w.writes%f.config.DropEvery == 0 {
+		return nil
+	}
+
+	return w.FrameWriter.Encode(e)
+}
+
+// sleep waits for the duration using the broker's clock, returning false if the broker closes first.
+func (b *defaultBroker) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	t := b.clock.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C():
+		return true
+	case <-b.closed:
+		return false
+	}
+}
+
+// delay returns how long to delay the next write by.
+func (f *faults) delay() time.Duration {
+	if f.config.Jitter <= 0 {
+		return f.config.Latency
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return f.config.Latency + time.Duration(f.rand.Int63n(int64(f.config.Jitter)))
+}
+
+// disconnect determines if the next write should drop the stream.
+func (f *faults) disconnect() bool {
+	if f.config.DisconnectRate <= 0 {
+		return false
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return f.rand.Float64() < f.config.DisconnectRate
+}
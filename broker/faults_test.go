@@ -0,0 +1,98 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithFaults(t *testing.T) {
+	tt := []struct {
+		Name             string
+		Faults           broker.FaultConfig
+		ExpectedWritten  []int
+		ExpectsConnected bool
+	}{
+		{
+			Name:             "It should write every event without faults",
+			ExpectedWritten:  []int{1, 2, 3, 4, 5, 6},
+			ExpectsConnected: true,
+		},
+		{
+			Name:             "It should drop every Nth event",
+			Faults:           broker.FaultConfig{DropEvery: 3},
+			ExpectedWritten:  []int{1, 2, 4, 5},
+			ExpectsConnected: true,
+		},
+		{
+			Name:   "It should drop the stream",
+			Faults: broker.FaultConfig{DisconnectRate: 1},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil, broker.WithFaults(tc.Faults))
+			defer b.Close()
+
+			w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+			defer close(w.close)
+
+			go b.ClientHandler(w, httptest.NewRequest(http.MethodGet, "/connect?id=test", nil))
+			<-time.After(time.Millisecond * 100)
+
+			for i := 1; i <= 6; i++ {
+				assert.NoError(t, b.Broadcast([]byte(strconv.Itoa(i))))
+				<-time.After(time.Millisecond * 10)
+			}
+
+			<-time.After(time.Millisecond * 100)
+
+			var written []int
+
+			for i := 1; i <= 6; i++ {
+				if strings.Contains(w.String(), "data: "+strconv.Itoa(i)+"\n\n") {
+					written = append(written, i)
+				}
+			}
+
+			assert.Equal(t, tc.ExpectedWritten, written)
+
+			assert.Equal(t, tc.ExpectsConnected, b.Stats().Clients == 1)
+		})
+	}
+}
+
+func TestBroker_WithFaultsLatency(t *testing.T) {
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	b := broker.New(time.Minute, 3, nil,
+		broker.WithClock(clk),
+		broker.WithFaults(broker.FaultConfig{Latency: time.Second}),
+	)
+	defer b.Close()
+
+	w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+	defer close(w.close)
+
+	go b.ClientHandler(w, httptest.NewRequest(http.MethodGet, "/connect?id=test", nil))
+	<-time.After(time.Millisecond * 100)
+
+	assert.NoError(t, b.Broadcast([]byte("hello")))
+	<-time.After(time.Millisecond * 100)
+
+	// The event is held back until the latency has elapsed.
+	assert.NotContains(t, w.String(), "data: hello")
+
+	clk.Advance(time.Second)
+	<-time.After(time.Millisecond * 100)
+
+	assert.Contains(t, w.String(), "data: hello\n\n")
+}
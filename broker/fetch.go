@@ -0,0 +1,88 @@
+package broker
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type (
+	// The StreamRequest type is the JSON body of a POST request that opens a stream, for clients
+	// that read the stream using fetch rather than EventSource, which cannot send a body. Each
+	// field is applied to the request as the query parameter or header a GET request would use,
+	// replacing any it already has, so that the stream is served in the same way.
+	StreamRequest struct {
+		ID          string            `json:"id,omitempty"`            // The client's identifier, as the 'id' query parameter.
+		Topics      []string          `json:"topics,omitempty"`        // The topics to subscribe to, as repeated 'topic' query parameters.
+		LastEventID string            `json:"last_event_id,omitempty"` // The last event the client received, as the 'Last-Event-ID' header.
+		Session     string            `json:"session,omitempty"`       // The session to resume, as the 'session' query parameter.
+		Token       string            `json:"token,omitempty"`         // A token for the broker's Authorizer, as a bearer token in the 'Authorization' header.
+		Params      map[string]string `json:"params,omitempty"`        // Any other query parameters, such as 'since' or 'timeout'.
+	}
+)
+
+// ParseStreamRequest returns the request to serve a stream for. POST requests are converted to GET
+// requests by applying the JSON encoded StreamRequest in their body, if they have one. Other requests
+// are returned as they are. If the body cannot be decoded, an error is returned.
+func ParseStreamRequest(r *http.Request) (*http.Request, error) {
+	if r.Method != http.MethodPost {
+		return r, nil
+	}
+
+	var body StreamRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	out := r.Clone(r.Context())
+	out.Method = http.MethodGet
+	out.Body = http.NoBody
+	out.ContentLength = 0
+
+	query := out.URL.Query()
+
+	for name, value := range body.Params {
+		query.Set(name, value)
+	}
+
+	if body.ID != "" {
+		query.Set("id", body.ID)
+	}
+
+	if body.Session != "" {
+		query.Set("session", body.Session)
+	}
+
+	if len(body.Topics) > 0 {
+		query.Del("topics")
+		query["topic"] = body.Topics
+		out.Header.Del(topicsHeader)
+	}
+
+	out.URL.RawQuery = query.Encode()
+
+	if body.LastEventID != "" {
+		out.Header.Set("Last-Event-ID", body.LastEventID)
+	}
+
+	if body.Token != "" {
+		out.Header.Set("Authorization", "Bearer "+body.Token)
+	}
+
+	return out, nil
+}
+
+// OpensStream determines if a request to a StreamEndpoint opens a stream rather than publishing an
+// event. GET requests always do, while POST requests do if their 'Accept' header lists one of the
+// stream's formats, as fetch clients should.
+func OpensStream(r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return true
+	}
+
+	accept := strings.ToLower(r.Header.Get("Accept"))
+
+	return r.Method == http.MethodPost && (strings.Contains(accept, contentTypeSSE) || strings.Contains(accept, contentTypeNDJSON))
+}
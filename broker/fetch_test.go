@@ -0,0 +1,146 @@
+package broker_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStreamRequest(t *testing.T) {
+	tt := []struct {
+		Name            string
+		Method          string
+		URL             string
+		Body            string
+		ExpectedQuery   string
+		ExpectedHeaders map[string]string
+		ExpectError     bool
+	}{
+		{
+			Name:          "It should return GET requests as they are",
+			Method:        http.MethodGet,
+			URL:           "/connect?topic=news",
+			ExpectedQuery: "topic=news",
+		},
+		{
+			Name:          "It should apply the body of POST requests",
+			Method:        http.MethodPost,
+			URL:           "/connect?topics=sport&id=old",
+			Body:          `{"id": "client", "topics": ["news", "weather"], "session": "abc", "params": {"since": "2020-01-01T00:00:00Z"}}`,
+			ExpectedQuery: "id=client&session=abc&since=2020-01-01T00%3A00%3A00Z&topic=news&topic=weather",
+		},
+		{
+			Name:          "It should set headers from the body",
+			Method:        http.MethodPost,
+			URL:           "/connect",
+			Body:          `{"last_event_id": "10", "token": "secret"}`,
+			ExpectedQuery: "",
+			ExpectedHeaders: map[string]string{
+				"Last-Event-ID": "10",
+				"Authorization": "Bearer secret",
+			},
+		},
+		{
+			Name:          "It should accept POST requests without a body",
+			Method:        http.MethodPost,
+			URL:           "/connect?topic=news",
+			ExpectedQuery: "topic=news",
+		},
+		{
+			Name:        "It should reject bodies that are not JSON",
+			Method:      http.MethodPost,
+			URL:         "/connect",
+			Body:        "topics=news",
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			r, err := broker.ParseStreamRequest(httptest.NewRequest(tc.Method, tc.URL, strings.NewReader(tc.Body)))
+
+			if tc.ExpectError {
+				assert.Error(t, err)
+				return
+			}
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.Equal(t, tc.ExpectedQuery, r.URL.RawQuery)
+
+			for name, value := range tc.ExpectedHeaders {
+				assert.Equal(t, value, r.Header.Get(name))
+			}
+		})
+	}
+}
+
+func TestBroker_ClientHandlerPost(t *testing.T) {
+	authorizer := func(r *http.Request) error {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			return errors.New("invalid token")
+		}
+
+		return nil
+	}
+
+	b := broker.New(time.Second, 3, nil, broker.WithAuthorizer(authorizer))
+	defer b.Close()
+
+	// Cross-origin fetch clients send the body as text to avoid a preflight request.
+	r := httptest.NewRequest(http.MethodPost, "/connect", strings.NewReader(`{"topics": ["news"], "token": "secret"}`))
+	r.Header.Set("Content-Type", "text/plain")
+
+	w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+	go b.ClientHandler(w, r)
+	<-time.After(time.Millisecond * 100)
+
+	assert.NoError(t, b.BroadcastTopic("news", []byte("hello")))
+	assert.NoError(t, b.BroadcastTopic("sport", []byte("goal")))
+	<-time.After(time.Millisecond * 100)
+
+	assert.Contains(t, w.String(), "data: hello\n\n")
+	assert.NotContains(t, w.String(), "goal")
+
+	close(w.close)
+
+	// Requests without the token are rejected.
+	uw := httptest.NewRecorder()
+	b.ClientHandler(uw, httptest.NewRequest(http.MethodPost, "/connect", strings.NewReader(`{"topics": ["news"]}`)))
+
+	assert.Equal(t, http.StatusUnauthorized, uw.Code)
+}
+
+func TestBroker_StreamEndpointPost(t *testing.T) {
+	b := broker.New(time.Second, 3, nil)
+	defer b.Close()
+
+	endpoint := b.StreamEndpoint()
+
+	// POST requests that accept a stream connect a client.
+	r := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"topics": ["news"]}`))
+	r.Header.Set("Accept", "text/event-stream")
+
+	w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+	go endpoint.ServeHTTP(w, r)
+	<-time.After(time.Millisecond * 100)
+
+	// Other POST requests publish an event.
+	pw := httptest.NewRecorder()
+	endpoint.ServeHTTP(pw, httptest.NewRequest(http.MethodPost, "/events?topic=news", strings.NewReader("hello")))
+	<-time.After(time.Millisecond * 100)
+
+	assert.Equal(t, http.StatusOK, pw.Code)
+	assert.Contains(t, w.String(), "data: hello\n\n")
+
+	close(w.close)
+}
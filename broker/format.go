@@ -0,0 +1,32 @@
+package broker
+
+import (
+	"strings"
+)
+
+const (
+	// The content type of streams written using the SSE wire format.
+	contentTypeSSE = "text/event-stream"
+
+	// The content type of streams written as newline-delimited JSON.
+	contentTypeNDJSON = "application/x-ndjson"
+)
+
+// negotiateFormat returns the content type that a client's stream should be written in, based
+// on the request's Accept header. Streams are written as newline-delimited JSON if the client
+// accepts it & does not list the SSE content type before it. Otherwise, the SSE wire format is
+// used.
+func negotiateFormat(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.Split(part, ";")[0])
+
+		switch strings.ToLower(mediaType) {
+		case contentTypeSSE:
+			return contentTypeSSE
+		case contentTypeNDJSON:
+			return contentTypeNDJSON
+		}
+	}
+
+	return contentTypeSSE
+}
@@ -0,0 +1,45 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_ContentNegotiation(t *testing.T) {
+	tt := []struct {
+		Accept              string
+		ExpectedContentType string
+		ExpectedOutput      string // The expected start of the stream, as events written as JSON include their timestamp.
+	}{
+		{Accept: "", ExpectedContentType: "text/event-stream", ExpectedOutput: "data: hello\n\n"},
+		{Accept: "text/event-stream", ExpectedContentType: "text/event-stream", ExpectedOutput: "data: hello\n\n"},
+		{Accept: "application/x-ndjson", ExpectedContentType: "application/x-ndjson", ExpectedOutput: "{\"data\":\"hello\",\"timestamp\":"},
+		{Accept: "text/event-stream, application/x-ndjson", ExpectedContentType: "text/event-stream", ExpectedOutput: "data: hello\n\n"},
+		{Accept: "application/x-ndjson; q=0.9, */*", ExpectedContentType: "application/x-ndjson", ExpectedOutput: "{\"data\":\"hello\",\"timestamp\":"},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil)
+		w := &FlushRecorder{header: http.Header{}}
+		r := httptest.NewRequest("GET", "/connect?id=test", nil)
+		r.Header.Set("Accept", tc.Accept)
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, r)
+		<-time.Tick(time.Second)
+
+		assert.NoError(t, broker.BroadcastTo("test", []byte("hello")))
+		<-time.Tick(time.Millisecond * 100)
+
+		assert.Equal(t, tc.ExpectedContentType, w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(w.String(), tc.ExpectedOutput), w.String())
+		broker.Close()
+	}
+}
@@ -0,0 +1,36 @@
+package broker
+
+import (
+	"io"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The FrameWriter interface describes types that write frames to a client's stream. The
+	// protocol.Encoder type is the default implementation, writing spec-compliant SSE. A custom
+	// implementation can add fields, write comments between events or change the order in which
+	// fields are written.
+	FrameWriter interface {
+		// Encode writes the event to the stream.
+		Encode(e event.Event) error
+		// Retry informs the client how long to wait before reconnecting.
+		Retry(retry time.Duration) error
+		// Comment writes text that clients ignore, used to keep idle connections open.
+		Comment(text string) error
+	}
+
+	// FrameWriterFunc is a function that creates the FrameWriter used to write to a client's
+	// stream.
+	FrameWriterFunc func(w io.Writer) FrameWriter
+)
+
+// WithFrameWriter configures the function used to create the FrameWriter for each client's SSE
+// stream, replacing the default protocol.Encoder. It is not used for streams written as
+// newline-delimited JSON. If 'fn' is nil, the default is used.
+func WithFrameWriter(fn FrameWriterFunc) Option {
+	return func(b *defaultBroker) {
+		b.frameWriter = fn
+	}
+}
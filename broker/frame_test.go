@@ -0,0 +1,72 @@
+package broker_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// The orderedWriter type writes each event's data before its identifier, with a comment
+	// between events.
+	orderedWriter struct {
+		*protocol.Encoder
+		w io.Writer
+	}
+)
+
+func (o *orderedWriter) Encode(e event.Event) error {
+	_, err := fmt.Fprintf(o.w, "data: %s\nid: %s\n\n:next\n", e.Data, e.ID)
+	return err
+}
+
+func TestBroker_FrameWriter(t *testing.T) {
+	tt := []struct {
+		Name           string
+		Accept         string
+		ExpectedOutput string
+	}{
+		{
+			Name:           "It should write events using the frame writer",
+			ExpectedOutput: "data: hello\nid: 1\n\n:next\n",
+		},
+		{
+			Name:           "It should not use the frame writer for newline-delimited JSON",
+			Accept:         "application/x-ndjson",
+			ExpectedOutput: "{\"id\":\"1\",\"data\":\"hello\",\"timestamp\":",
+		},
+	}
+
+	fn := func(w io.Writer) broker.FrameWriter {
+		return &orderedWriter{Encoder: protocol.NewEncoder(w), w: w}
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil, broker.WithFrameWriter(fn))
+			defer b.Close()
+
+			w := &FlushRecorder{header: http.Header{}}
+			r := httptest.NewRequest("GET", "/connect?id=test", nil)
+			r.Header.Set("Accept", tc.Accept)
+
+			// Connect to the broker, give it 1 second to create the
+			// client
+			go b.ClientHandler(w, r)
+			<-time.Tick(time.Second)
+
+			assert.NoError(t, b.BroadcastEvent(event.Event{ID: "1", Data: []byte("hello")}))
+			<-time.Tick(time.Millisecond * 100)
+
+			assert.Contains(t, w.String(), tc.ExpectedOutput)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package broker
+
+import (
+	"sync"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The groupIndex type holds the members of each client group. Clients join the groups they
+	// connect with & leave them when they disconnect, see the ClientInfo type.
+	groupIndex struct {
+		mux    sync.RWMutex
+		groups map[string]*fanout
+	}
+)
+
+// BroadcastGroup writes the given data to all connected clients that are members of the given group,
+// such as the groups in the claims of the tokens they connected with, see the ClientInfo type. Errors
+// are handled in the same way as the Broadcast method.
+func (b *defaultBroker) BroadcastGroup(group string, data []byte) error {
+	return b.BroadcastEvent(event.Event{Group: group, Data: data})
+}
+
+// members returns the group of clients that are members of the event's group, subscribed to its
+// topic & within its audience.
+func (b *defaultBroker) members(e event.Event) (*fanout, error) {
+	selector, err := ParseSelector(e.Audience)
+
+	if err != nil {
+		return nil, err
+	}
+
+	members, ok := b.groups.get(e.Group)
+
+	// If the group has no members, we still store the event and forward it
+	// upstream in the same way as topics without subscribers.
+	if !ok {
+		return b.empty, nil
+	}
+
+	if e.Topic == "" && e.Audience == "" {
+		return members, nil
+	}
+
+	group := newFanout(b.shards, b.clock)
+
+	for _, s := range members.shards {
+		for _, c := range s.list() {
+			if e.Matches(c.Topics()) && selector.Matches(c.Metadata()) {
+				group.add(c)
+			}
+		}
+	}
+
+	return group, nil
+}
+
+// inGroup determines if the client is a member of the event's group. Events without a group are
+// delivered to every client.
+func inGroup(e event.Event, c *client.Client) bool {
+	if e.Group == "" {
+		return true
+	}
+
+	for _, group := range c.Groups() {
+		if group == e.Group {
+			return true
+		}
+	}
+
+	return false
+}
+
+// add adds the client to each of its groups, creating them if it is their first member.
+func (idx *groupIndex) add(c *client.Client, shards int, clk clock.Clock) {
+	if len(c.Groups()) == 0 {
+		return
+	}
+
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	if idx.groups == nil {
+		idx.groups = make(map[string]*fanout)
+	}
+
+	for _, name := range c.Groups() {
+		group, ok := idx.groups[name]
+
+		if !ok {
+			group = newFanout(shards, clk)
+			idx.groups[name] = group
+		}
+
+		group.add(c)
+	}
+}
+
+// remove removes the client from each of its groups, discarding any groups left without members.
+func (idx *groupIndex) remove(c *client.Client) {
+	if len(c.Groups()) == 0 {
+		return
+	}
+
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	for _, name := range c.Groups() {
+		group, ok := idx.groups[name]
+
+		if !ok {
+			continue
+		}
+
+		group.remove(c)
+
+		if group.len() == 0 {
+			delete(idx.groups, name)
+		}
+	}
+}
+
+// get returns the members of the group, if it has any.
+func (idx *groupIndex) get(name string) (*fanout, bool) {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	group, ok := idx.groups[name]
+
+	return group, ok
+}
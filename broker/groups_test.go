@@ -0,0 +1,109 @@
+package broker_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_BroadcastGroup(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Event    event.Event
+		Expected []string
+	}{
+		{
+			Name:     "It should write the event to members of the group",
+			Event:    event.Event{Group: "team:1"},
+			Expected: []string{"a", "b"},
+		},
+		{
+			Name:     "It should only write to members subscribed to the event's topic",
+			Event:    event.Event{Group: "team:1", Topic: "news"},
+			Expected: []string{"b"},
+		},
+		{
+			Name:     "It should only write to members within the event's audience",
+			Event:    event.Event{Group: "team:1", Audience: "role=admin"},
+			Expected: []string{"a"},
+		},
+		{
+			Name:  "It should not write to anybody if the group has no members",
+			Event: event.Event{Group: "team:3"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, broker.WithShards(2))
+			defer brk.Close()
+
+			clients := map[string]*client.Client{
+				"a": client.NewWithOptions(time.Second, 3, "a", client.WithQueueSize(10), client.WithGroups("team:1"), client.WithMetadata(map[string]string{"role": "admin"})),
+				"b": client.NewWithOptions(time.Second, 3, "b", client.WithQueueSize(10), client.WithGroups("team:1", "team:2"), client.WithTopics("news")),
+				"c": client.NewWithOptions(time.Second, 3, "c", client.WithQueueSize(10), client.WithGroups("team:2"), client.WithTopics("news")),
+			}
+
+			for _, id := range []string{"a", "b", "c"} {
+				assert.NoError(t, brk.Subscribe(clients[id]))
+			}
+
+			tc.Event.Data = []byte("hello")
+
+			summary, err := brk.BroadcastSummary(tc.Event)
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.Expected), summary.Delivered)
+
+			var received []string
+
+			for _, id := range []string{"a", "b", "c"} {
+				if clients[id].Lag().Pending > 0 {
+					received = append(received, id)
+				}
+			}
+
+			assert.Equal(t, tc.Expected, received)
+		})
+	}
+}
+
+func TestBroker_GroupsFromContext(t *testing.T) {
+	fn := func(ctx context.Context) broker.ClientInfo {
+		return broker.ClientInfo{ID: "user", Groups: []string{"team:1"}}
+	}
+
+	brk := broker.New(time.Second, 3, nil, broker.WithClientFromContext(fn))
+	defer brk.Close()
+
+	w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+	done := make(chan struct{})
+
+	// Groups are never read from the request itself.
+	go func() {
+		defer close(done)
+		brk.ClientHandler(w, httptest.NewRequest("GET", "/connect?group=team:2", nil))
+	}()
+
+	<-time.After(time.Millisecond * 500)
+
+	assert.NoError(t, brk.BroadcastGroup("team:1", []byte("hello")))
+	assert.NoError(t, brk.BroadcastGroup("team:2", []byte("other")))
+	<-time.After(time.Millisecond * 100)
+
+	assert.Equal(t, "data: hello\n\n", w.String())
+
+	// Disconnecting removes the client from its groups.
+	close(w.close)
+	<-done
+
+	summary, err := brk.BroadcastSummary(event.Event{Group: "team:1", Data: []byte("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, summary.Delivered)
+}
@@ -0,0 +1,112 @@
+package broker
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+type (
+	// The ConnectionLimit type limits the number of streams open at once for each source of
+	// connections, such as an IP address or the subject of an access token, so that a single
+	// misbehaving client cannot use all of the broker's connections.
+	ConnectionLimit struct {
+		Max int           // The number of streams each source can have open at once. Zero means no limit.
+		Key ConnectionKey // Identifies the source of each connection. Defaults to the RemoteIP function.
+	}
+
+	// ConnectionKey is a function that identifies the source of a connection, returning the key
+	// its streams are counted against. Connections with a blank key are not limited.
+	ConnectionKey func(r *http.Request, info ClientInfo) string
+
+	// The connectionGuard type counts the streams open for each source of connections.
+	connectionGuard struct {
+		mux   sync.Mutex
+		limit ConnectionLimit
+		open  map[string]int
+	}
+)
+
+var (
+	// ErrTooManyConnections is returned when a client connects from a source that already has
+	// the maximum number of streams open, see the broker.WithConnectionLimit method.
+	ErrTooManyConnections = errors.New("the maximum number of connections from this source are open")
+)
+
+// WithConnectionLimit configures the maximum number of streams that can be open at once from each
+// source of connections, identified using the limit's key function. Connections beyond the limit
+// are rejected with a 429 status code & the quota_exceeded error code. Sessions resumed by a new
+// connection count against the new connection's source. If the maximum is zero, connections are
+// not limited.
+func WithConnectionLimit(limit ConnectionLimit) Option {
+	return func(b *defaultBroker) {
+		if limit.Max <= 0 {
+			b.guard = nil
+			return
+		}
+
+		if limit.Key == nil {
+			limit.Key = RemoteIP
+		}
+
+		b.guard = &connectionGuard{limit: limit, open: make(map[string]int)}
+	}
+}
+
+// RemoteIP is a ConnectionKey that identifies connections by the IP address they were made from.
+// Brokers behind a proxy should use a ConnectionKey that reads the address the proxy forwards,
+// such as from the X-Forwarded-For header, as every connection would otherwise share the proxy's
+// address.
+func RemoteIP(r *http.Request, info ClientInfo) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// ClientSubject is a ConnectionKey that identifies connections by the client identifier they
+// connected with, such as the subject of an access token returned by the function given to the
+// broker.WithClientFromContext method.
+func ClientSubject(r *http.Request, info ClientInfo) string {
+	return info.ID
+}
+
+// acquire counts a stream against the source of the connection, returning the function that
+// releases it. If the source already has the maximum number of streams open, false is returned.
+func (g *connectionGuard) acquire(r *http.Request, info ClientInfo) (func(), bool) {
+	if g == nil {
+		return func() {}, true
+	}
+
+	key := g.limit.Key(r, info)
+
+	if key == "" {
+		return func() {}, true
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if g.open[key] >= g.limit.Max {
+		return nil, false
+	}
+
+	g.open[key]++
+
+	return func() { g.release(key) }, true
+}
+
+func (g *connectionGuard) release(key string) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	// Remove sources without open streams so that the map does not grow with
+	// every address that has ever connected.
+	if g.open[key]--; g.open[key] <= 0 {
+		delete(g.open, key)
+	}
+}
@@ -0,0 +1,107 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithConnectionLimit(t *testing.T) {
+	var (
+		mux   sync.Mutex
+		codes []broker.ErrorCode
+	)
+
+	brk := broker.New(time.Second, 3, func(w http.ResponseWriter, r *http.Request, err error) {
+		mux.Lock()
+		codes = append(codes, err.(*broker.Error).Code)
+		mux.Unlock()
+	}, broker.WithConnectionLimit(broker.ConnectionLimit{Max: 1}))
+	defer brk.Close()
+
+	rejected := func() []broker.ErrorCode {
+		mux.Lock()
+		defer mux.Unlock()
+
+		return append([]broker.ErrorCode(nil), codes...)
+	}
+
+	connect := func(addr string) *FlushRecorder {
+		w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+		r := httptest.NewRequest("GET", "/connect", nil)
+		r.RemoteAddr = addr
+
+		go brk.ClientHandler(w, r)
+		<-time.After(time.Millisecond * 100)
+
+		return w
+	}
+
+	first := connect("10.0.0.1:1000")
+	connect("10.0.0.2:1000")
+	assert.Equal(t, 2, brk.Stats().Clients)
+	assert.Empty(t, rejected())
+
+	// A second stream from the same address is rejected.
+	connect("10.0.0.1:2000")
+	assert.Equal(t, []broker.ErrorCode{broker.CodeQuotaExceeded}, rejected())
+	assert.Equal(t, 2, brk.Stats().Clients)
+
+	// Once the first stream ends, the address can connect again.
+	close(first.close)
+	<-time.After(time.Millisecond * 100)
+
+	connect("10.0.0.1:3000")
+	assert.Len(t, rejected(), 1)
+	assert.Equal(t, 2, brk.Stats().Clients)
+}
+
+func TestConnectionKeys(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Key      broker.ConnectionKey
+		Addr     string
+		Info     broker.ClientInfo
+		Expected string
+	}{
+		{
+			Name:     "It should identify connections by IP address",
+			Key:      broker.RemoteIP,
+			Addr:     "10.0.0.1:1000",
+			Expected: "10.0.0.1",
+		},
+		{
+			Name:     "It should identify connections by IPv6 address",
+			Key:      broker.RemoteIP,
+			Addr:     "[::1]:1000",
+			Expected: "::1",
+		},
+		{
+			Name:     "It should use addresses without a port as they are",
+			Key:      broker.RemoteIP,
+			Addr:     "10.0.0.1",
+			Expected: "10.0.0.1",
+		},
+		{
+			Name:     "It should identify connections by client id",
+			Key:      broker.ClientSubject,
+			Addr:     "10.0.0.1:1000",
+			Info:     broker.ClientInfo{ID: "user-1"},
+			Expected: "user-1",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/connect", nil)
+			r.RemoteAddr = tc.Addr
+
+			assert.Equal(t, tc.Expected, tc.Key(r, tc.Info))
+		})
+	}
+}
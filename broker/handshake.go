@@ -0,0 +1,57 @@
+package broker
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Handshake type describes the capabilities of the broker to a client that has just
+	// connected, so that browser clients can configure themselves. It is sent as the data of the
+	// first event of each stream, encoded as JSON, see the broker.WithHandshake method.
+	Handshake struct {
+		ClientID   string    `json:"client_id"`   // The identifier assigned to the client.
+		ServerTime time.Time `json:"server_time"` // The broker's current time, used to detect clock skew.
+		Heartbeat  int64     `json:"heartbeat"`   // How often, in milliseconds, comments are written to idle streams. Zero if they are not.
+		Ping       int64     `json:"ping"`        // How often, in milliseconds, the client must send a ping, see the broker.WithLiveness method. Zero if it need not.
+		Replay     bool      `json:"replay"`      // Whether missed events are replayed to clients that reconnect with a 'Last-Event-ID'.
+		Sessions   bool      `json:"sessions"`    // Whether the client can resume its session after a dropped connection.
+		Topics     []string  `json:"topics"`      // The topics the client is subscribed to.
+	}
+)
+
+const (
+	// The type of the event that describes the broker's capabilities to a client.
+	handshakeEventType = "sse:hello"
+)
+
+// WithHandshake configures the broker to send an event with the 'sse:hello' type when each client
+// connects, before any other events. Its data is a JSON encoded Handshake, containing the client's
+// identifier, the broker's current time & whether heartbeats, replays & sessions are enabled. This
+// allows browser clients to configure themselves, and developers to confirm that clients are
+// connected.
+func WithHandshake(enabled bool) Option {
+	return func(b *defaultBroker) {
+		b.handshake = enabled
+	}
+}
+
+// handshakeEvent returns the event describing the broker's capabilities to the client.
+func (b *defaultBroker) handshakeEvent(c *client.Client) event.Event {
+	hs := Handshake{
+		ClientID:   c.ID(),
+		ServerTime: b.clock.Now().UTC(),
+		Replay:     b.store != nil,
+		Sessions:   b.sessions != nil,
+		Topics:     c.Topics(),
+		Heartbeat:  b.heartbeatInterval().Milliseconds(),
+		Ping:       b.liveness.interval().Milliseconds(),
+	}
+
+	data, _ := json.Marshal(hs)
+
+	return event.Event{Type: handshakeEventType, Data: data, Timestamp: hs.ServerTime}
+}
@@ -0,0 +1,68 @@
+package broker_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithHandshake(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Options  []broker.Option
+		Expected broker.Handshake
+	}{
+		{
+			Name:     "It should describe a broker without optional capabilities",
+			Expected: broker.Handshake{ClientID: "test", Topics: []string{"news"}},
+		},
+		{
+			Name: "It should describe the broker's optional capabilities",
+			Options: []broker.Option{
+				broker.WithStore(store.NewMemory(10)),
+				broker.WithSessions(time.Minute),
+				broker.WithPolyfillSupport(true),
+			},
+			Expected: broker.Handshake{
+				ClientID:  "test",
+				Heartbeat: 15000,
+				Replay:    true,
+				Sessions:  true,
+				Topics:    []string{"news"},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil, append(tc.Options, broker.WithHandshake(true))...)
+			defer b.Close()
+
+			w := ssetest.NewStreamRecorder()
+			defer w.Close()
+
+			go b.ClientHandler(w, w.NewRequest("GET", "/connect?id=test&topic=news", nil))
+
+			events, err := w.WaitForEvents(1, time.Second)
+
+			if !assert.NoError(t, err) || !assert.NotEmpty(t, events) {
+				return
+			}
+
+			assert.Equal(t, "sse:hello", events[0].Type)
+
+			var actual broker.Handshake
+
+			assert.NoError(t, json.Unmarshal(events[0].Data, &actual))
+			assert.WithinDuration(t, time.Now(), actual.ServerTime, time.Second)
+
+			actual.ServerTime = time.Time{}
+			assert.Equal(t, tc.Expected, actual)
+		})
+	}
+}
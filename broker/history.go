@@ -0,0 +1,79 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+)
+
+// HistoryHandler is an HTTP handler that returns stored events as a JSON array, so that clients
+// can backfill missed events using a normal request before opening the stream. The 'since' query
+// parameter may be the identifier of the last event the client received, or an RFC 3339 timestamp
+// after which events are returned. If it is not provided, all stored events are returned. Like the
+// ClientHandler, only events for the topics requested by the client & events without a topic are
+// returned, see the broker.WithSubscriptionParser method. Events are filtered in the same way as
+// when they are replayed, see the broker.WithReplayTTL method. The broker must have a store, see
+// the broker.WithStore method.
+//
+// Example using Mux (https://github.com/gorilla/mux)
+//
+// r := mux.NewRouter()
+// r.HandleFunc("/history", broker.HistoryHandler).Methods("GET")
+//
+// http.ListenAndServe(":8080", r)
+func (b *defaultBroker) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if !b.authorize(w, r) {
+		return
+	}
+
+	if b.store == nil {
+		err := errors.New("the broker does not store events")
+
+		b.httpError(w, r, CodeHistoryUnavailable, err, http.StatusNotImplemented)
+		return
+	}
+
+	topics, err := b.parseTopics(r)
+
+	if err != nil {
+		b.subscriptionError(w, r, err)
+		return
+	}
+
+	since := r.URL.Query().Get("since")
+	after, err := time.Parse(time.RFC3339Nano, since)
+
+	// If the parameter is a timestamp, all events are read & filtered by their
+	// timestamp instead.
+	if err == nil {
+		since = ""
+	}
+
+	events, err := b.store.Since(since)
+
+	if err != nil {
+		b.httpError(w, r, CodeHistoryUnavailable, err, http.StatusInternalServerError)
+		return
+	}
+
+	now := b.clock.Now()
+	out := make([]event.Event, 0, len(events))
+
+	for _, e := range events {
+		if !after.IsZero() && !e.Timestamp.After(after) {
+			continue
+		}
+
+		if !e.Matches(topics) || b.stale(e, now) {
+			continue
+		}
+
+		out = append(out, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
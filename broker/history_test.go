@@ -0,0 +1,77 @@
+package broker_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_HistoryHandler(t *testing.T) {
+	start := time.Now()
+
+	events := []event.Event{
+		{ID: "1", Data: []byte("a"), Timestamp: start.Add(-time.Minute * 3)},
+		{ID: "2", Topic: "news", Data: []byte("b"), Timestamp: start.Add(-time.Minute * 2)},
+		{ID: "3", Data: []byte("c"), Timestamp: start.Add(-time.Minute), Expires: start},
+		{ID: "4", Topic: "sports", Data: []byte("d"), Timestamp: start.Add(-time.Minute)},
+	}
+
+	tt := []struct {
+		Query       string
+		ExpectedIDs []string
+	}{
+		{Query: "?since=1&topic=news&topic=sports", ExpectedIDs: []string{"2", "4"}},
+		{Query: "?topic=news", ExpectedIDs: []string{"1", "2"}},
+		{Query: "?since=" + url.QueryEscape(start.Add(-time.Minute*2).Format(time.RFC3339Nano)) + "&topic=sports", ExpectedIDs: []string{"4"}},
+		{Query: "?since=4", ExpectedIDs: []string{}},
+	}
+
+	for _, tc := range tt {
+		s := store.NewMemory(10)
+
+		for _, e := range events {
+			assert.NoError(t, s.Append(e))
+		}
+
+		broker := broker.New(time.Second, 3, nil, broker.WithStore(s))
+		w := httptest.NewRecorder()
+
+		broker.HistoryHandler(w, httptest.NewRequest("GET", "/history"+tc.Query, nil))
+
+		var history []event.Event
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&history))
+
+		ids := []string{}
+
+		for _, e := range history {
+			ids = append(ids, e.ID)
+		}
+
+		assert.Equal(t, tc.ExpectedIDs, ids)
+	}
+}
+
+func TestBroker_HistoryHandlerWithoutStore(t *testing.T) {
+	var received *broker.Error
+
+	handler := func(w http.ResponseWriter, r *http.Request, err error) {
+		received, _ = err.(*broker.Error)
+	}
+
+	broker.New(time.Second, 3, handler).HistoryHandler(httptest.NewRecorder(), httptest.NewRequest("GET", "/history", nil))
+
+	if assert.NotNil(t, received) {
+		assert.Equal(t, broker.CodeHistoryUnavailable, received.Code)
+		assert.Equal(t, http.StatusNotImplemented, received.Status)
+	}
+}
@@ -0,0 +1,84 @@
+package broker
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// The idempotencyFilter type tracks the idempotency keys of recently published events, so
+	// that retried publishes can be recognised within a window.
+	idempotencyFilter struct {
+		mux    sync.Mutex
+		window time.Duration
+		seen   map[string]time.Time
+		order  []idempotencyKey
+	}
+
+	// The idempotencyKey type is a key tracked by the idempotency filter.
+	idempotencyKey struct {
+		key  string
+		seen time.Time
+	}
+)
+
+// WithIdempotencyWindow configures the broker to discard events that are published more than
+// once within the given window, so that producers retrying a failed request do not cause
+// duplicate events to reach clients. An event is identified by the 'Idempotency-Key' header of
+// the request made to the EventHandler, or otherwise by its identifier. Discarded events are
+// reported as having been published successfully. Requests to the EventHandler that fail do not
+// keep their key, so that they can be retried. If 'window' is zero, events are not checked.
+func WithIdempotencyWindow(window time.Duration) Option {
+	return func(b *defaultBroker) {
+		if window <= 0 {
+			return
+		}
+
+		b.idempotency = &idempotencyFilter{
+			window: window,
+			seen:   make(map[string]time.Time),
+		}
+	}
+}
+
+// duplicate returns true if the key has already been seen within the window. Otherwise, the
+// key is recorded until it leaves the window or is released. Blank keys are never duplicates.
+func (f *idempotencyFilter) duplicate(key string, now time.Time) bool {
+	if f == nil || key == "" {
+		return false
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	// Forget keys that have left the window, oldest first.
+	for len(f.order) > 0 && now.Sub(f.order[0].seen) >= f.window {
+		if f.seen[f.order[0].key] == f.order[0].seen {
+			delete(f.seen, f.order[0].key)
+		}
+
+		f.order[0] = idempotencyKey{}
+		f.order = f.order[1:]
+	}
+
+	if _, ok := f.seen[key]; ok {
+		return true
+	}
+
+	f.seen[key] = now
+	f.order = append(f.order, idempotencyKey{key: key, seen: now})
+
+	return false
+}
+
+// release forgets the key, so that an event whose publish failed can be published again using it.
+func (f *idempotencyFilter) release(key string) {
+	if f == nil || key == "" {
+		return
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	delete(f.seen, key)
+}
@@ -0,0 +1,119 @@
+package broker_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithIdempotencyWindow(t *testing.T) {
+	tt := []struct {
+		Window         time.Duration
+		Keys           []string
+		Wait           time.Duration
+		ExpectedEvents int
+	}{
+		{Window: time.Minute, Keys: []string{"a", "a", "b", ""}, ExpectedEvents: 3},
+		{Window: time.Minute, Keys: []string{"", ""}, ExpectedEvents: 2},
+		{Window: time.Millisecond * 100, Keys: []string{"a", "a"}, Wait: time.Millisecond * 200, ExpectedEvents: 2},
+		{Keys: []string{"a", "a"}, ExpectedEvents: 2},
+	}
+
+	for _, tc := range tt {
+		b := broker.New(time.Second, 3, nil, broker.WithIdempotencyWindow(tc.Window))
+		c := ssetest.NewClient("test", client.WithQueueSize(10))
+
+		assert.NoError(t, b.Subscribe(c.Client))
+
+		for _, key := range tc.Keys {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/broadcast?id=test", bytes.NewBufferString("hello"))
+
+			if key != "" {
+				r.Header.Set("Idempotency-Key", key)
+			}
+
+			b.EventHandler(w, r)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			<-time.After(tc.Wait)
+		}
+
+		<-time.After(time.Millisecond * 100)
+		assert.Len(t, c.Events(), tc.ExpectedEvents)
+
+		c.Close()
+		b.Close()
+	}
+}
+
+func TestBroker_IdempotentEventID(t *testing.T) {
+	b := broker.New(time.Second, 3, nil, broker.WithIdempotencyWindow(time.Minute))
+	c := ssetest.NewClient("test", client.WithQueueSize(10))
+	defer c.Close()
+	defer b.Close()
+
+	assert.NoError(t, b.Subscribe(c.Client))
+
+	for _, id := range []string{"1", "1", "2"} {
+		assert.NoError(t, b.BroadcastEvent(event.Event{ID: id, Data: []byte("hello")}))
+	}
+
+	events, err := c.Wait(2, time.Second)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1", events[0].ID)
+	assert.Equal(t, "2", events[1].ID)
+}
+
+func TestBroker_IdempotentRetry(t *testing.T) {
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	b := broker.New(time.Second, 3, nil,
+		broker.WithClock(clk),
+		broker.WithIdempotencyWindow(time.Minute),
+		broker.WithBackpressure(broker.BackpressureLimit{MaxPending: 1, Resume: 1, Interval: time.Second}),
+	)
+	defer b.Close()
+
+	c := client.NewWithOptions(time.Second, 3, "test", client.WithQueueSize(10))
+	assert.NoError(t, b.Subscribe(c))
+
+	publish := func() int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/broadcast", bytes.NewBufferString("retried"))
+		r.Header.Set("Idempotency-Key", "a")
+
+		b.EventHandler(w, r)
+		return w.Code
+	}
+
+	// Saturate the client's queue, so that the first attempt is rejected.
+	assert.NoError(t, b.Broadcast([]byte("hello")))
+	clk.Advance(time.Second)
+
+	assert.Equal(t, http.StatusServiceUnavailable, publish())
+
+	c.Next()
+	clk.Advance(time.Second)
+
+	// The rejected attempt should not use up the key, so the retry is delivered once.
+	assert.Equal(t, http.StatusOK, publish())
+	assert.Equal(t, http.StatusOK, publish())
+
+	e, ok := c.Next()
+
+	assert.True(t, ok)
+	assert.Equal(t, "retried", string(e.Data))
+
+	_, ok = c.Next()
+	assert.False(t, ok)
+}
@@ -0,0 +1,63 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// Interceptor is a function that is called with each event before it is written to clients,
+	// returning the event to publish in its place. It can enrich events, such as by setting a
+	// timestamp or tagging them with a tenant, or veto them by returning an error.
+	Interceptor func(e event.Event) (event.Event, error)
+)
+
+var (
+	// ErrDropEvent can be returned by an Interceptor to discard an event without reporting an error
+	// to its publisher.
+	ErrDropEvent = errors.New("drop event")
+
+	// ErrEventRejected is returned when an Interceptor returns an error other than ErrDropEvent.
+	// The interceptor's error is appended.
+	ErrEventRejected = errors.New("event rejected")
+
+	// ErrReservedType is the error appended to ErrEventRejected when an event's type begins with
+	// 'sse:', which is reserved for the broker's own events.
+	ErrReservedType = errors.New("event types beginning with 'sse:' are reserved for the broker")
+)
+
+// WithInterceptors configures functions that are called in order with each event published to the
+// broker, including those sent to a single client, before it is stored or written to clients. Each
+// interceptor is given the event returned by the one before it. If an interceptor returns an error,
+// the event is not published & the remaining interceptors are not called. The EventHandler rejects
+// such events with a 422 status code & the event_rejected error code. Interceptors are called on the
+// publisher's goroutine, so they should return quickly. This option can be given more than once.
+func WithInterceptors(fns ...Interceptor) Option {
+	return func(b *defaultBroker) {
+		b.interceptors = append(b.interceptors, fns...)
+	}
+}
+
+// intercept returns the event after it has been passed through each of the broker's interceptors.
+// Events whose type is reserved for the broker's own events are rejected, so that they cannot be
+// mistaken for them, such as to skip their encryption.
+func (b *defaultBroker) intercept(e event.Event) (event.Event, error) {
+	for _, fn := range b.interceptors {
+		var err error
+
+		if e, err = fn(e); err == ErrDropEvent {
+			return e, err
+		} else if err != nil {
+			return e, fmt.Errorf("%w: %v", ErrEventRejected, err)
+		}
+	}
+
+	if strings.HasPrefix(e.Type, systemEventPrefix) {
+		return e, fmt.Errorf("%w: %w", ErrEventRejected, ErrReservedType)
+	}
+
+	return e, nil
+}
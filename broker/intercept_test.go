@@ -0,0 +1,117 @@
+package broker_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithInterceptors(t *testing.T) {
+	// The interceptors tag each event with a tenant, then drop or veto events
+	// based on their data.
+	tag := func(e event.Event) (event.Event, error) {
+		e.Data = append([]byte("acme:"), e.Data...)
+		return e, nil
+	}
+
+	policy := func(e event.Event) (event.Event, error) {
+		switch string(e.Data) {
+		case "acme:drop":
+			return e, broker.ErrDropEvent
+		case "acme:forbidden":
+			return e, errors.New("forbidden content")
+		default:
+			return e, nil
+		}
+	}
+
+	tt := []struct {
+		Name         string
+		Query        string
+		Data         string
+		ExpectedCode int
+		ExpectedData []string
+	}{
+		{
+			Name:         "It should publish the intercepted event",
+			Data:         "hello",
+			ExpectedCode: http.StatusOK,
+			ExpectedData: []string{"acme:hello"},
+		},
+		{
+			Name:         "It should intercept events sent to a single client",
+			Query:        "?id=1234",
+			Data:         "hello",
+			ExpectedCode: http.StatusOK,
+			ExpectedData: []string{"acme:hello"},
+		},
+		{
+			Name:         "It should discard dropped events without an error",
+			Data:         "drop",
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Name:         "It should reject vetoed events",
+			Data:         "forbidden",
+			ExpectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:         "It should reject vetoed events sent to a single client",
+			Query:        "?id=1234",
+			Data:         "forbidden",
+			ExpectedCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, broker.WithInterceptors(tag, policy))
+			defer brk.Close()
+
+			c := client.NewWithOptions(time.Second, 3, "1234", client.WithQueueSize(10))
+			assert.NoError(t, brk.Subscribe(c))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/publish"+tc.Query, strings.NewReader(tc.Data))
+
+			brk.EventHandler(w, r)
+			assert.Equal(t, tc.ExpectedCode, w.Code)
+
+			var data []string
+
+			for _, e := range c.Pending(true) {
+				data = append(data, string(e.Data))
+			}
+
+			assert.Equal(t, tc.ExpectedData, data)
+		})
+	}
+}
+
+func TestBroker_InterceptorErrors(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithInterceptors(func(e event.Event) (event.Event, error) {
+		return e, errors.New("forbidden content")
+	}))
+	defer brk.Close()
+
+	err := brk.BroadcastEvent(event.Event{Data: []byte("hello")})
+
+	if assert.Error(t, err) {
+		assert.True(t, errors.Is(err, broker.ErrEventRejected))
+		assert.Contains(t, err.Error(), "forbidden content")
+	}
+
+	_, err = brk.BroadcastSummary(event.Event{Data: []byte("hello")})
+	assert.True(t, errors.Is(err, broker.ErrEventRejected))
+
+	err = brk.BroadcastWithin(event.Event{Data: []byte("hello")}, broker.ErrorBudget{})
+	assert.True(t, errors.Is(err, broker.ErrEventRejected))
+}
@@ -0,0 +1,306 @@
+package broker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/store"
+)
+
+type (
+	// The keyring interface describes where publisher keys are recorded, see the store.KeyStore
+	// interface.
+	keyring interface {
+		SetKey(key store.Key) error
+		Key(id string) (store.Key, error)
+		DeleteKey(id string) error
+	}
+
+	// The publisherKeys type holds the keys publishers use when the broker's store does not record
+	// them, along with the rate limiter of each key.
+	publisherKeys struct {
+		mux      sync.Mutex
+		memory   map[string]store.Key
+		limiters map[string]*rateLimiter
+	}
+)
+
+const (
+	// The header publishers present their key in.
+	publisherKeyHeader = "X-SSE-Key"
+
+	// The number of random bytes in each key created by the broker.
+	publisherKeySize = 24
+)
+
+var (
+	// ErrKeysDisabled is the error returned when managing publisher keys on a broker that was not
+	// configured using the WithPublisherKeys method.
+	ErrKeysDisabled = errors.New("publisher keys are not enabled")
+
+	// ErrUnknownKey is the error returned when the key presented by a publisher, or the key to
+	// revoke, does not exist.
+	ErrUnknownKey = errors.New("unknown publisher key")
+
+	// ErrTopicForbidden is the error returned when a publisher's key does not allow publishing to
+	// the event's topic.
+	ErrTopicForbidden = errors.New("the key does not allow publishing to the topic")
+
+	// ErrPayloadTooLarge is the error returned when an event is larger than the publisher's key
+	// allows.
+	ErrPayloadTooLarge = errors.New("the event is larger than the key allows")
+)
+
+// WithPublisherKeys configures the broker's EventHandler to require an API key in the 'X-SSE-Key'
+// header of each request, so that individual publishers can be given their own limits & revoked
+// without affecting others. Each key can restrict the topics events are published to, the rate they
+// are published at & the size of their data, see the store.Key type. Keys restricted to topics cannot
+// publish events without a topic, such as those sent to a single client or group. Keys are created
+// & revoked using the CreateKey & RevokeKey methods, or the KeyHandler. They are recorded in the
+// broker's store if it implements store.KeyStore, so that they are kept when the broker restarts,
+// & are otherwise held in memory. Keys are checked after the request is authorized, see the
+// broker.WithAuthorizer method.
+func WithPublisherKeys(enabled bool) Option {
+	return func(b *defaultBroker) {
+		if !enabled {
+			b.keys = nil
+			return
+		}
+
+		b.keys = &publisherKeys{
+			memory:   make(map[string]store.Key),
+			limiters: make(map[string]*rateLimiter),
+		}
+	}
+}
+
+// CreateKey records a publisher key with the topics & limits of the given key & a randomly generated
+// identifier, which is returned in its ID field. Any identifier set on the given key is replaced. If
+// the broker was not configured using the WithPublisherKeys method, ErrKeysDisabled is returned.
+func (b *defaultBroker) CreateKey(key store.Key) (store.Key, error) {
+	ring, ok := b.keyring()
+
+	if !ok {
+		return store.Key{}, ErrKeysDisabled
+	}
+
+	id := make([]byte, publisherKeySize)
+
+	if _, err := rand.Read(id); err != nil {
+		return store.Key{}, err
+	}
+
+	key.ID = hex.EncodeToString(id)
+
+	if err := ring.SetKey(key); err != nil {
+		return store.Key{}, err
+	}
+
+	return key, nil
+}
+
+// RevokeKey removes the publisher key with the given identifier, so that it can no longer be used to
+// publish events. If the key does not exist, ErrUnknownKey is returned. If the broker was not
+// configured using the WithPublisherKeys method, ErrKeysDisabled is returned.
+func (b *defaultBroker) RevokeKey(id string) error {
+	ring, ok := b.keyring()
+
+	if !ok {
+		return ErrKeysDisabled
+	}
+
+	key, err := ring.Key(id)
+
+	if err != nil {
+		return err
+	}
+
+	if key.ID == "" {
+		return ErrUnknownKey
+	}
+
+	if err = ring.DeleteKey(id); err != nil {
+		return err
+	}
+
+	b.keys.forget(id)
+
+	return nil
+}
+
+// KeyHandler is an HTTP handler that allows operators to manage publisher keys, see the
+// broker.WithPublisherKeys method. A POST request creates a key with the topics & limits of the JSON
+// encoded store.Key in its body, responding with the created key, including its identifier, as JSON.
+// A DELETE request revokes the key given by the 'key' query parameter. Requests are authorized using
+// the broker's Authorizer rather than publisher keys, & are refused if the broker has no Authorizer,
+// see the broker.WithAuthorizer method. This method should still be registered to an endpoint only
+// operators can reach.
+//
+// Example using Mux (https://github.com/gorilla/mux)
+//
+// r := mux.NewRouter()
+// r.HandleFunc("/keys", broker.KeyHandler).Methods("POST", "DELETE")
+//
+// http.ListenAndServe(":8080", r)
+func (b *defaultBroker) KeyHandler(w http.ResponseWriter, r *http.Request) {
+	// Anyone able to create keys could publish anything, so keys are never managed without
+	// an authorizer.
+	if b.authorizer == nil {
+		b.httpError(w, r, CodeUnauthorized, errors.New("the broker has no authorizer"), http.StatusUnauthorized)
+		return
+	}
+
+	if !b.authorize(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var key store.Key
+
+		if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+			b.httpError(w, r, CodeInvalidKey, err, http.StatusBadRequest)
+			return
+		}
+
+		key, err := b.CreateKey(key)
+
+		if err != nil {
+			b.httpError(w, r, CodeKeysUnavailable, err, http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(key)
+	case http.MethodDelete:
+		switch err := b.RevokeKey(r.URL.Query().Get("key")); err {
+		case nil:
+			w.WriteHeader(http.StatusNoContent)
+		case ErrUnknownKey:
+			b.httpError(w, r, CodeUnknownKey, err, http.StatusNotFound)
+		default:
+			b.httpError(w, r, CodeKeysUnavailable, err, http.StatusServiceUnavailable)
+		}
+	default:
+		w.Header().Set("Allow", http.MethodPost+", "+http.MethodDelete)
+		b.httpError(w, r, CodeMethodNotAllowed, fmt.Errorf("method %v is not allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// keyring returns where publisher keys are recorded. If publisher keys are not enabled, false is
+// returned.
+func (b *defaultBroker) keyring() (keyring, bool) {
+	if b.keys == nil {
+		return nil, false
+	}
+
+	if ks, ok := b.store.(store.KeyStore); ok {
+		return ks, true
+	}
+
+	return b.keys, true
+}
+
+// checkKey determines if the key presented by the request allows publishing to the topic,
+// responding with an error if it does not. Events sent to a single client have no topic. The
+// key is returned so that the size of the event can be checked as it is read. If publisher
+// keys are not enabled, the zero key is returned.
+func (b *defaultBroker) checkKey(w http.ResponseWriter, r *http.Request, topic string) (store.Key, bool) {
+	ring, ok := b.keyring()
+
+	if !ok {
+		return store.Key{}, true
+	}
+
+	key, err := ring.Key(r.Header.Get(publisherKeyHeader))
+
+	switch {
+	case err != nil:
+		b.httpError(w, r, CodeKeysUnavailable, err, http.StatusServiceUnavailable)
+		return key, false
+	case key.ID == "":
+		b.httpError(w, r, CodeUnauthorized, ErrUnknownKey, http.StatusUnauthorized)
+		return key, false
+	case len(key.Topics) > 0 && (topic == "" || !contains(key.Topics, topic)):
+		b.httpError(w, r, CodeTopicForbidden, ErrTopicForbidden, http.StatusForbidden)
+		return key, false
+	}
+
+	return key, true
+}
+
+// allowKey determines if the key has not exceeded its maximum rate of events, responding with an
+// error if it has. If publisher keys are not enabled, the key is blank & every event is allowed.
+func (b *defaultBroker) allowKey(w http.ResponseWriter, r *http.Request, key store.Key) bool {
+	if key.ID == "" {
+		return true
+	}
+
+	if !b.keys.limiter(key, b.clock.Now()).allow(b.clock.Now()) {
+		b.httpError(w, r, CodeQuotaExceeded, ErrRateLimited, http.StatusTooManyRequests)
+		return false
+	}
+
+	return true
+}
+
+func (k *publisherKeys) SetKey(key store.Key) error {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+
+	key.Topics = append([]string(nil), key.Topics...)
+	k.memory[key.ID] = key
+
+	return nil
+}
+
+func (k *publisherKeys) Key(id string) (store.Key, error) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+
+	return k.memory[id], nil
+}
+
+func (k *publisherKeys) DeleteKey(id string) error {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+
+	delete(k.memory, id)
+
+	return nil
+}
+
+// limiter returns the rate limiter of the key, creating it if this is its first use. Keys without
+// a maximum rate have no limiter, which allows every event.
+func (k *publisherKeys) limiter(key store.Key, now time.Time) *rateLimiter {
+	if key.MaxEventsPerSecond <= 0 {
+		return nil
+	}
+
+	k.mux.Lock()
+	defer k.mux.Unlock()
+
+	l, ok := k.limiters[key.ID]
+
+	if !ok {
+		l = newRateLimiter(key.MaxEventsPerSecond, key.Burst, now)
+		k.limiters[key.ID] = l
+	}
+
+	return l
+}
+
+// forget discards the rate limiter of a revoked key.
+func (k *publisherKeys) forget(id string) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+
+	delete(k.limiters, id)
+}
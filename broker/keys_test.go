@@ -0,0 +1,243 @@
+package broker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithPublisherKeys(t *testing.T) {
+	tt := []struct {
+		Name           string
+		Key            store.Key
+		Presented      string
+		Query          string
+		Body           string
+		ExpectedStatus []int
+		ExpectedCode   broker.ErrorCode
+	}{
+		{
+			Name:           "It should publish events with a valid key",
+			Query:          "?topic=news",
+			Body:           "hello",
+			ExpectedStatus: []int{http.StatusOK},
+		},
+		{
+			Name:           "It should reject requests without a key",
+			Presented:      "-",
+			Query:          "?topic=news",
+			Body:           "hello",
+			ExpectedStatus: []int{http.StatusUnauthorized},
+			ExpectedCode:   broker.CodeUnauthorized,
+		},
+		{
+			Name:           "It should reject events to topics the key does not allow",
+			Key:            store.Key{Topics: []string{"news"}},
+			Query:          "?topic=sport",
+			Body:           "hello",
+			ExpectedStatus: []int{http.StatusForbidden},
+			ExpectedCode:   broker.CodeTopicForbidden,
+		},
+		{
+			Name:           "It should reject events without a topic if the key is restricted to topics",
+			Key:            store.Key{Topics: []string{"news"}},
+			Query:          "?id=client",
+			Body:           "hello",
+			ExpectedStatus: []int{http.StatusForbidden},
+			ExpectedCode:   broker.CodeTopicForbidden,
+		},
+		{
+			Name:           "It should reject events larger than the key allows",
+			Key:            store.Key{MaxPayload: 4},
+			Query:          "?topic=news",
+			Body:           "hello",
+			ExpectedStatus: []int{http.StatusRequestEntityTooLarge},
+			ExpectedCode:   broker.CodePayloadTooLarge,
+		},
+		{
+			Name:           "It should reject events above the key's rate",
+			Key:            store.Key{MaxEventsPerSecond: 1, Burst: 1},
+			Query:          "?topic=news",
+			Body:           "hello",
+			ExpectedStatus: []int{http.StatusOK, http.StatusTooManyRequests},
+			ExpectedCode:   broker.CodeQuotaExceeded,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var code broker.ErrorCode
+
+			eh := func(w http.ResponseWriter, r *http.Request, err error) {
+				e := err.(*broker.Error)
+				code = e.Code
+				w.WriteHeader(e.Status)
+			}
+
+			brk := broker.New(time.Second, 3, eh, broker.WithPublisherKeys(true), broker.WithClock(ssetest.NewClock(time.Now())))
+			defer brk.Close()
+
+			key, err := brk.CreateKey(tc.Key)
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Len(t, key.ID, 48)
+
+			presented := key.ID
+
+			if tc.Presented != "" {
+				presented = tc.Presented
+			}
+
+			for _, status := range tc.ExpectedStatus {
+				r := httptest.NewRequest(http.MethodPost, "/"+tc.Query, strings.NewReader(tc.Body))
+				r.Header.Set("X-SSE-Key", presented)
+				w := httptest.NewRecorder()
+
+				brk.EventHandler(w, r)
+
+				assert.Equal(t, status, w.Code)
+			}
+
+			assert.Equal(t, tc.ExpectedCode, code)
+		})
+	}
+}
+
+func TestBroker_KeyHandler(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Options []broker.Option
+	}{
+		{
+			Name:    "It should manage keys held in memory",
+			Options: []broker.Option{broker.WithPublisherKeys(true)},
+		},
+		{
+			Name:    "It should manage keys recorded in the store",
+			Options: []broker.Option{broker.WithPublisherKeys(true), broker.WithStore(store.NewMemory(10))},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, append(tc.Options, broker.WithAuthorizer(func(r *http.Request) error {
+				return nil
+			}))...)
+			defer brk.Close()
+
+			publish := func(key string) int {
+				r := httptest.NewRequest(http.MethodPost, "/?topic=news", strings.NewReader("hello"))
+				r.Header.Set("X-SSE-Key", key)
+				w := httptest.NewRecorder()
+
+				brk.EventHandler(w, r)
+
+				return w.Code
+			}
+
+			// Create a key restricted to a topic.
+			w := httptest.NewRecorder()
+			brk.KeyHandler(w, httptest.NewRequest(http.MethodPost, "/keys", bytes.NewBufferString(`{"topics": ["news"]}`)))
+
+			if !assert.Equal(t, http.StatusCreated, w.Code) {
+				return
+			}
+
+			var key store.Key
+
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&key))
+			assert.NotEmpty(t, key.ID)
+			assert.Equal(t, []string{"news"}, key.Topics)
+			assert.Equal(t, http.StatusOK, publish(key.ID))
+
+			// Once revoked, the key can no longer be used.
+			w = httptest.NewRecorder()
+			brk.KeyHandler(w, httptest.NewRequest(http.MethodDelete, "/keys?key="+key.ID, nil))
+
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, http.StatusUnauthorized, publish(key.ID))
+
+			w = httptest.NewRecorder()
+			brk.KeyHandler(w, httptest.NewRequest(http.MethodDelete, "/keys?key="+key.ID, nil))
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			w = httptest.NewRecorder()
+			brk.KeyHandler(w, httptest.NewRequest(http.MethodGet, "/keys", nil))
+
+			assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+			assert.Equal(t, "POST, DELETE", w.Header().Get("Allow"))
+		})
+	}
+}
+
+func TestBroker_WithPublisherKeysBodyLimit(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithPublisherKeys(true))
+	defer brk.Close()
+
+	key, err := brk.CreateKey(store.Key{MaxPayload: 4})
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	body := &countingReader{r: strings.NewReader(strings.Repeat("a", 1<<20))}
+	r := httptest.NewRequest(http.MethodPost, "/?topic=news", body)
+	r.Header.Set("X-SSE-Key", key.ID)
+	w := httptest.NewRecorder()
+
+	brk.EventHandler(w, r)
+
+	// The body is no longer read once it exceeds the key's limit.
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.True(t, body.n < 1<<20)
+}
+
+type countingReader struct {
+	r *strings.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+
+	return n, err
+}
+
+func TestBroker_KeyHandlerWithoutAuthorizer(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithPublisherKeys(true))
+	defer brk.Close()
+
+	w := httptest.NewRecorder()
+	brk.KeyHandler(w, httptest.NewRequest(http.MethodPost, "/keys", bytes.NewBufferString(`{"topics": ["news"]}`)))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestBroker_CreateKeyDisabled(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil)
+	defer brk.Close()
+
+	_, err := brk.CreateKey(store.Key{})
+	assert.Equal(t, broker.ErrKeysDisabled, err)
+	assert.Equal(t, broker.ErrKeysDisabled, brk.RevokeKey("key"))
+
+	// Without keys, events are published as normal.
+	w := httptest.NewRecorder()
+	brk.EventHandler(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
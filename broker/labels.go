@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"context"
+	"runtime/pprof"
+	"strconv"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+// WithProfilerLabels configures the broker to label its goroutines for CPU & goroutine profiles, so that
+// profiles of large deployments can be attributed to specific clients & topics. The goroutine serving
+// each client, & any it starts, is labelled with the client's identifier as 'sse_client' & the number of
+// topics it subscribed to as 'sse_topics'. While an event is written to its subscribers, the goroutine
+// publishing it, & the goroutines it is fanned out on, are labelled with its topic as 'sse_topic'. Labels
+// the publishing goroutine already had are replaced until the event has been written.
+func WithProfilerLabels(enabled bool) Option {
+	return func(b *defaultBroker) {
+		b.profilerLabels = enabled
+	}
+}
+
+// labelClient labels the calling goroutine with the client's identifier & number of topics, returning
+// a function that restores the labels of the request's context.
+func (b *defaultBroker) labelClient(ctx context.Context, c *client.Client) func() {
+	if !b.profilerLabels {
+		return func() {}
+	}
+
+	labels := pprof.Labels("sse_client", c.ID(), "sse_topics", strconv.Itoa(len(c.Topics())))
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, labels))
+
+	return func() {
+		pprof.SetGoroutineLabels(ctx)
+	}
+}
+
+// labelTopic calls 'fn' with the calling goroutine labelled with the topic of the event.
+func (b *defaultBroker) labelTopic(e event.Event, fn func()) {
+	if !b.profilerLabels {
+		fn()
+		return
+	}
+
+	pprof.Do(context.Background(), pprof.Labels("sse_topic", e.Topic), func(context.Context) {
+		fn()
+	})
+}
@@ -0,0 +1,54 @@
+package broker_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithProfilerLabels(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Enabled  bool
+		Expected bool
+	}{
+		{
+			Name:     "It should label the goroutines serving the client",
+			Enabled:  true,
+			Expected: true,
+		},
+		{
+			Name:     "It should not label goroutines when disabled",
+			Enabled:  false,
+			Expected: false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, broker.WithProfilerLabels(tc.Enabled))
+			defer brk.Close()
+
+			w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+			r := httptest.NewRequest(http.MethodGet, "/connect?id=profiled&topic=news&topic=sports", nil)
+
+			go brk.ClientHandler(w, r)
+			<-time.After(time.Millisecond * 100)
+
+			var buf bytes.Buffer
+			assert.NoError(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+
+			assert.Equal(t, tc.Expected, bytes.Contains(buf.Bytes(), []byte(`"sse_client":"profiled"`)))
+			assert.Equal(t, tc.Expected, bytes.Contains(buf.Bytes(), []byte(`"sse_topics":"2"`)))
+
+			close(w.close)
+			<-time.After(time.Millisecond * 100)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type (
+	// LimitsValidator is a function that validates the timeout & tolerance requested by a
+	// client using the 'timeout' & 'tolerance' query parameters. Values that were not
+	// requested are set to the broker's defaults. Returning an error rejects the client.
+	LimitsValidator func(r *http.Request, timeout time.Duration, tolerance int) error
+)
+
+// WithClientLimits configures the broker to allow clients to override the timeout & tolerance
+// used when writing to them, using the 'timeout' (such as '10s') & 'tolerance' query parameters.
+// The requested values are only used if 'fn' accepts them, allowing mobile clients and server to
+// server consumers to use very different settings on the same broker. Values provided in the
+// ClientInfo derived from the request context are always used, see the broker.WithClientFromContext
+// method. If 'fn' is nil, the query parameters are ignored.
+func WithClientLimits(fn LimitsValidator) Option {
+	return func(b *defaultBroker) {
+		b.limitsValidator = fn
+	}
+}
+
+// clientLimits returns the timeout & tolerance to use for the client making the request.
+func (b *defaultBroker) clientLimits(r *http.Request, info ClientInfo) (time.Duration, int, error) {
+	timeout, tolerance := b.timeout, b.tolerance
+
+	if b.limitsValidator != nil {
+		var err error
+
+		if timeout, tolerance, err = requestedLimits(r, timeout, tolerance); err != nil {
+			return 0, 0, err
+		}
+
+		if err = b.limitsValidator(r, timeout, tolerance); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if info.Timeout > 0 {
+		timeout = info.Timeout
+	}
+
+	if info.Tolerance > 0 {
+		tolerance = info.Tolerance
+	}
+
+	return timeout, tolerance, nil
+}
+
+// requestedLimits parses the timeout & tolerance query parameters of the request, using the
+// given defaults for those that are not provided.
+func requestedLimits(r *http.Request, timeout time.Duration, tolerance int) (time.Duration, int, error) {
+	query := r.URL.Query()
+
+	if value := query.Get("timeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+
+		if err != nil || parsed <= 0 {
+			return 0, 0, fmt.Errorf("invalid timeout %q, must be a positive duration", value)
+		}
+
+		timeout = parsed
+	}
+
+	if value := query.Get("tolerance"); value != "" {
+		parsed, err := strconv.Atoi(value)
+
+		if err != nil || parsed <= 0 {
+			return 0, 0, fmt.Errorf("invalid tolerance %q, must be a positive integer", value)
+		}
+
+		tolerance = parsed
+	}
+
+	return timeout, tolerance, nil
+}
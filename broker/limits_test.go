@@ -0,0 +1,131 @@
+package broker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithClientLimits(t *testing.T) {
+	tt := []struct {
+		Query             string
+		Info              broker.ClientInfo
+		ExpectedTimeout   time.Duration
+		ExpectedTolerance int
+		ExpectedCode      broker.ErrorCode
+	}{
+		{Query: "?id=test&timeout=5s&tolerance=10", ExpectedTimeout: time.Second * 5, ExpectedTolerance: 10},
+		{Query: "?id=test&tolerance=1", ExpectedTimeout: time.Second, ExpectedTolerance: 1},
+		{Query: "?id=test&timeout=1m", ExpectedTimeout: time.Minute, ExpectedTolerance: 3, ExpectedCode: broker.CodeInvalidLimits},
+		{Query: "?id=test&timeout=soon", ExpectedCode: broker.CodeInvalidLimits},
+		{Query: "?id=test&tolerance=-1", ExpectedCode: broker.CodeInvalidLimits},
+		{
+			Query:             "?id=test&tolerance=1",
+			Info:              broker.ClientInfo{Tolerance: 20},
+			ExpectedTimeout:   time.Second,
+			ExpectedTolerance: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		var mux sync.Mutex
+		var timeout time.Duration
+		var tolerance int
+		var received *broker.Error
+
+		validator := func(r *http.Request, to time.Duration, tol int) error {
+			mux.Lock()
+			timeout, tolerance = to, tol
+			mux.Unlock()
+
+			if to > time.Second*10 {
+				return errors.New("timeout is too long")
+			}
+
+			return nil
+		}
+
+		handler := func(w http.ResponseWriter, r *http.Request, err error) {
+			mux.Lock()
+			received, _ = err.(*broker.Error)
+			mux.Unlock()
+		}
+
+		info := tc.Info
+		fn := func(ctx context.Context) broker.ClientInfo { return info }
+
+		broker := broker.New(time.Second, 3, handler,
+			broker.WithClientLimits(validator),
+			broker.WithClientFromContext(fn),
+		)
+
+		w := &FlushRecorder{header: http.Header{}}
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, httptest.NewRequest("GET", "/connect"+tc.Query, nil))
+		<-time.Tick(time.Second)
+
+		mux.Lock()
+		assert.Equal(t, tc.ExpectedTimeout, timeout)
+		assert.Equal(t, tc.ExpectedTolerance, tolerance)
+		err := received
+		mux.Unlock()
+
+		if tc.ExpectedCode != "" {
+			if assert.NotNil(t, err) {
+				assert.Equal(t, tc.ExpectedCode, err.Code)
+				assert.Equal(t, http.StatusBadRequest, err.Status)
+			}
+		} else {
+			assert.Nil(t, err)
+			assert.NoError(t, broker.BroadcastTo("test", []byte("hello")))
+		}
+
+		broker.Close()
+	}
+}
+
+func TestBroker_ClientTolerance(t *testing.T) {
+	tt := []struct {
+		Query           string
+		ExpectedClients int
+	}{
+		{Query: "?id=test&timeout=100ms&tolerance=1", ExpectedClients: 0},
+		{Query: "?id=test&timeout=100ms", ExpectedClients: 1},
+	}
+
+	for _, tc := range tt {
+		validator := func(r *http.Request, timeout time.Duration, tolerance int) error {
+			return nil
+		}
+
+		broker := broker.New(time.Second, 3, nil, broker.WithClientLimits(validator))
+		w := &BlockingRecorder{
+			FlushRecorder: FlushRecorder{header: http.Header{}},
+			unblock:       make(chan struct{}),
+		}
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, httptest.NewRequest("GET", "/connect"+tc.Query, nil))
+		<-time.Tick(time.Second)
+
+		// The first event blocks while being written, so the second cannot be
+		// delivered within the client's timeout.
+		assert.NoError(t, broker.Broadcast([]byte("a")))
+		assert.Error(t, broker.Broadcast([]byte("b")))
+
+		assert.Equal(t, tc.ExpectedClients, broker.Stats().Clients)
+
+		close(w.unblock)
+		broker.Close()
+	}
+}
@@ -0,0 +1,212 @@
+package broker
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+)
+
+type (
+	// The LivenessConfig type configures how often clients must acknowledge that they are still
+	// reading their stream, see the broker.WithLiveness method.
+	LivenessConfig struct {
+		Interval time.Duration // How often each client must send a ping. Zero disables liveness checks.
+		Misses   int           // The number of intervals a client can miss before it is evicted. Defaults to 3.
+	}
+
+	// The liveness type records when each streaming client last acknowledged that it is reading
+	// its stream.
+	liveness struct {
+		mux    sync.Mutex
+		config LivenessConfig
+		acks   map[string]livenessAck
+	}
+
+	// The livenessAck type records when a streaming client last pinged.
+	livenessAck struct {
+		client *client.Client
+		last   time.Time
+	}
+)
+
+const (
+	// The number of intervals a client can miss if the broker is not configured with a number.
+	defaultLivenessMisses = 3
+)
+
+var (
+	// ErrNotStreaming is the error returned when a ping is received for a client that does not
+	// have a stream open with the broker.
+	ErrNotStreaming = errors.New("the client does not have a stream open")
+)
+
+// WithLiveness configures the broker to require each client with an open stream to send a ping to the
+// broker's PingHandler at least once per interval, identifying itself using the 'client' query parameter.
+// Writes to a client whose connection has silently dropped can appear to succeed for a long time, as
+// they only fill the kernel's buffers, so clients that miss the configured number of pings in a row
+// are evicted regardless. Each client's liveness score, from 1 when it has pinged within the last
+// interval down to 0 when it is evicted, is reported by the Stats method. The interval is included in
+// the handshake event, see the broker.WithHandshake method. Clients subscribed without a stream, such
+// as those added using the Subscribe method, are not checked. If 'cfg.Interval' is zero, clients are
+// not required to ping.
+func WithLiveness(cfg LivenessConfig) Option {
+	return func(b *defaultBroker) {
+		if cfg.Misses <= 0 {
+			cfg.Misses = defaultLivenessMisses
+		}
+
+		b.liveness = newLiveness(cfg)
+	}
+}
+
+// PingHandler is an HTTP handler that records that the client identified by the 'client' query
+// parameter is still reading its stream, see the broker.WithLiveness method. It responds with a 204
+// status code, or a 404 status code if the client does not have a stream open. This method should
+// be registered to an endpoint of your choosing, protected in the same way as the ClientHandler.
+//
+// Example using http (https://golang.org/pkg/net/http/)
+//
+// http.HandleFunc("/ping", broker.PingHandler)
+func (b *defaultBroker) PingHandler(w http.ResponseWriter, r *http.Request) {
+	if !b.authorize(w, r) {
+		return
+	}
+
+	if !b.liveness.ack(r.URL.Query().Get("client"), b.clock.Now()) {
+		b.httpError(w, r, CodeUnknownClient, ErrNotStreaming, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// livenessCheck returns a channel that receives each time the client's liveness should be checked,
+// along with a function that stops it. If liveness checks are not enabled, the channel never
+// receives.
+func (b *defaultBroker) livenessCheck() (<-chan time.Time, func()) {
+	if b.liveness.interval() <= 0 {
+		return nil, func() {}
+	}
+
+	ticker := b.clock.NewTicker(b.liveness.interval())
+
+	return ticker.C(), ticker.Stop
+}
+
+func newLiveness(cfg LivenessConfig) *liveness {
+	return &liveness{
+		config: cfg,
+		acks:   make(map[string]livenessAck),
+	}
+}
+
+// interval returns how often clients must ping, or zero if they do not need to.
+func (l *liveness) interval() time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	return l.config.Interval
+}
+
+// track starts checking the liveness of the client, as if it had just pinged.
+func (l *liveness) track(c *client.Client, now time.Time) {
+	if l.interval() <= 0 {
+		return
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.acks[c.ID()] = livenessAck{client: c, last: now}
+}
+
+// untrack stops checking the liveness of the client, unless another client has since taken over
+// its identifier.
+func (l *liveness) untrack(c *client.Client) {
+	if l.interval() <= 0 {
+		return
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.acks[c.ID()].client == c {
+		delete(l.acks, c.ID())
+	}
+}
+
+// ack records a ping from the client with the given identifier, returning false if its liveness is
+// not being checked.
+func (l *liveness) ack(id string, now time.Time) bool {
+	if l.interval() <= 0 {
+		return false
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	ack, ok := l.acks[id]
+
+	if !ok {
+		return false
+	}
+
+	ack.last = now
+	l.acks[id] = ack
+
+	return true
+}
+
+// score returns the liveness score of a client that last pinged at the given time. Clients that
+// pinged within the last interval score 1, falling to 0 once they have missed every allowed ping.
+func (l *liveness) score(last, now time.Time) float64 {
+	late := now.Sub(last) - l.config.Interval
+
+	if late <= 0 {
+		return 1
+	}
+
+	allowed := l.config.Interval * time.Duration(l.config.Misses-1)
+
+	if allowed <= 0 || late >= allowed {
+		return 0
+	}
+
+	return 1 - float64(late)/float64(allowed)
+}
+
+// alive determines if the client has pinged recently enough to remain connected.
+func (l *liveness) alive(c *client.Client, now time.Time) bool {
+	if l.interval() <= 0 {
+		return true
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	ack, ok := l.acks[c.ID()]
+
+	return !ok || ack.client != c || now.Sub(ack.last) < l.config.Interval*time.Duration(l.config.Misses)
+}
+
+// stats returns the liveness score of each client whose liveness is being checked, by client id.
+func (l *liveness) stats(now time.Time) map[string]float64 {
+	out := make(map[string]float64)
+
+	if l.interval() <= 0 {
+		return out
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	for id, ack := range l.acks {
+		out[id] = l.score(ack.last, now)
+	}
+
+	return out
+}
@@ -0,0 +1,62 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithLiveness(t *testing.T) {
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	b := broker.New(time.Minute, 3, nil,
+		broker.WithClock(clk),
+		broker.WithLiveness(broker.LivenessConfig{Interval: time.Second, Misses: 2}),
+	)
+	defer b.Close()
+
+	ping := func(id string) int {
+		w := httptest.NewRecorder()
+		b.PingHandler(w, httptest.NewRequest(http.MethodPost, "/ping?client="+id, nil))
+
+		return w.Code
+	}
+
+	w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+	defer close(w.close)
+
+	go b.ClientHandler(w, httptest.NewRequest(http.MethodGet, "/connect?id=test", nil))
+
+	for i := 0; i < 100 && b.Stats().Liveness["test"] == 0; i++ {
+		<-time.After(time.Millisecond * 10)
+	}
+
+	<-time.After(time.Millisecond * 50)
+
+	assert.Equal(t, 1.0, b.Stats().Liveness["test"])
+	assert.Equal(t, http.StatusNoContent, ping("test"))
+	assert.Equal(t, http.StatusNotFound, ping("unknown"))
+
+	// Clients that are late to ping lose score, but are only evicted once they miss every allowed ping.
+	clk.Advance(time.Millisecond * 1500)
+	<-time.After(time.Millisecond * 50)
+
+	assert.Equal(t, 0.5, b.Stats().Liveness["test"])
+	assert.Equal(t, 1, b.Stats().Clients)
+	assert.Equal(t, http.StatusNoContent, ping("test"))
+	assert.Equal(t, 1.0, b.Stats().Liveness["test"])
+
+	for i := 0; i < 3; i++ {
+		clk.Advance(time.Second)
+		<-time.After(time.Millisecond * 50)
+	}
+
+	assert.Equal(t, 0, b.Stats().Clients)
+	assert.NotContains(t, b.Stats().Liveness, "test")
+	assert.Equal(t, http.StatusNotFound, ping("test"))
+}
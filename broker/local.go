@@ -0,0 +1,78 @@
+package broker
+
+import (
+	"sync"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+const (
+	// The number of events queued for each local subscriber before new events are skipped.
+	localQueueSize = 1024
+)
+
+// SubscribeLocal subscribes a goroutine in the same process to the broker without an HTTP connection,
+// returning a channel of the events an SSE client subscribed to the topic would receive, along with
+// a function that cancels the subscription & closes the channel. The channel is also closed when the
+// broker is closed. If 'topic' is blank, only events broadcast without a topic are received. Local
+// subscribers are counted as clients in the broker's statistics, but are not limited by its quotas.
+// If a local subscriber falls behind by more than 1024 events, new events are skipped until it
+// catches up, so that it cannot slow down broadcasts to other clients.
+func (b *defaultBroker) SubscribeLocal(topic string) (<-chan event.Event, func()) {
+	var topics []string
+
+	if topic != "" {
+		topics = append(topics, topic)
+	}
+
+	c := client.NewWithOptions(b.timeout, b.tolerance, "",
+		client.WithTopics(topics...),
+		client.WithQueueSize(localQueueSize),
+		client.WithSlowPolicy(client.SlowPolicy{MaxDepth: localQueueSize, Action: client.ActionSkip}),
+		client.WithClock(b.clock),
+	)
+
+	b.addClient(c)
+
+	return consume(c, b.closed, func() { b.disconnect(c, DisconnectUnsubscribed) })
+}
+
+// consume returns a channel of the events taken from the client's queue & a function that calls
+// 'unsubscribe' before closing the channel. The channel is also closed once 'closed' is.
+func consume(c *client.Client, closed <-chan struct{}, unsubscribe func()) (<-chan event.Event, func()) {
+	out := make(chan event.Event)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case <-c.Ready():
+				for e, ok := c.Next(); ok; e, ok = c.Next() {
+					select {
+					case out <- e:
+					case <-done:
+						return
+					case <-closed:
+						return
+					}
+				}
+			case <-done:
+				return
+			case <-closed:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return out, func() {
+		once.Do(func() {
+			unsubscribe()
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package broker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_SubscribeLocal(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Topic    string
+		Events   []event.Event
+		Expected []string
+	}{
+		{
+			Name:     "It should receive events on the topic & events without a topic",
+			Topic:    "news",
+			Events:   []event.Event{{Topic: "news", Data: []byte("headline")}, {Topic: "sport", Data: []byte("goal")}, {Data: []byte("all")}},
+			Expected: []string{"headline", "all"},
+		},
+		{
+			Name:     "It should only receive events without a topic when no topic is given",
+			Events:   []event.Event{{Topic: "news", Data: []byte("headline")}, {Data: []byte("all")}},
+			Expected: []string{"all"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil)
+			defer b.Close()
+
+			events, cancel := b.SubscribeLocal(tc.Topic)
+			defer cancel()
+
+			assert.Equal(t, 1, b.Stats().Clients)
+
+			for _, e := range tc.Events {
+				assert.NoError(t, b.BroadcastEvent(e))
+			}
+
+			var actual []string
+
+			for len(actual) < len(tc.Expected) {
+				select {
+				case e := <-events:
+					actual = append(actual, string(e.Data))
+				case <-time.After(time.Second):
+					t.Fatal("timed out waiting for events")
+				}
+			}
+
+			assert.Equal(t, tc.Expected, actual)
+
+			cancel()
+
+			_, open := <-events
+			assert.False(t, open)
+			assert.Equal(t, 0, b.Stats().Clients)
+		})
+	}
+}
+
+func TestBroker_SubscribeLocal_Close(t *testing.T) {
+	b := broker.New(time.Second, 3, nil)
+	events, cancel := b.SubscribeLocal("news")
+	defer cancel()
+
+	assert.NoError(t, b.Close())
+
+	select {
+	case _, open := <-events:
+		assert.False(t, open)
+	case <-time.After(time.Second):
+		t.Fatal("channel was not closed")
+	}
+}
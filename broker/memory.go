@@ -0,0 +1,257 @@
+package broker
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/store"
+)
+
+type (
+	// The MemoryBudget type limits the memory used by the events the broker buffers, see the
+	// broker.WithMemoryBudget method.
+	MemoryBudget struct {
+		MaxBytes  int           // The bytes of event data that can be buffered in client queues & the store. Zero disables the budget.
+		HighWater float64       // The fraction of the budget, from 0 to 1, at which a SystemMemoryHigh event is emitted. Defaults to 0.8.
+		Action    MemoryAction  // How memory is freed once the budget is exceeded.
+		Interval  time.Duration // How often memory usage is measured. Defaults to one second.
+	}
+
+	// MemoryAction determines how the broker frees memory once its memory budget is exceeded.
+	MemoryAction int
+
+	// The MemoryStats type describes the memory used by the events the broker buffers, see the
+	// broker.WithMemoryBudget method.
+	MemoryStats struct {
+		Budget   int    // The number of bytes that can be buffered, or zero if the broker has no budget.
+		Queued   int    // The bytes of event data queued for clients or held while they are paused.
+		Stored   int    // The bytes of event data held by the broker's store, if it implements store.SizedStore.
+		Exceeded uint64 // The number of times the budget has been exceeded.
+		Freed    uint64 // The bytes of event data discarded, or held by evicted clients, to bring usage within the budget.
+	}
+
+	// The memoryBudget type records the memory used by buffered events when it was last measured.
+	memoryBudget struct {
+		config    MemoryBudget
+		mux       sync.Mutex
+		high      bool
+		over      bool
+		rejecting int32
+		exceeded  uint64
+		freed     uint64
+	}
+
+	// The bufferedClient type is a client & the bytes of event data buffered for it.
+	bufferedClient struct {
+		client *client.Client
+		bytes  int
+	}
+)
+
+const (
+	// MemoryDropOldest discards the oldest events held by the broker's store, followed by the oldest
+	// events with the lowest priority queued for the clients using the most memory, see the
+	// client.Client's Shed method.
+	MemoryDropOldest MemoryAction = iota
+
+	// MemoryEvict disconnects the clients using the most memory, discarding their queues. Events held
+	// by the store are kept.
+	MemoryEvict
+
+	// MemoryReject rejects broadcasts with ErrMemoryBudget until usage falls within the budget. Events
+	// that have already been buffered are kept.
+	MemoryReject
+)
+
+const (
+	// The fraction of the budget at which a warning is emitted if the broker is not configured with one.
+	defaultMemoryHighWater = 0.8
+
+	// How often memory usage is measured if the broker is not configured with an interval.
+	defaultMemoryInterval = time.Second
+)
+
+var (
+	// ErrMemoryBudget is the error returned when an event is broadcast while the broker's memory
+	// budget is exceeded & its action is MemoryReject.
+	ErrMemoryBudget = errors.New("the broker's memory budget has been exceeded")
+)
+
+// WithMemoryBudget configures the maximum number of bytes of event data the broker buffers across the
+// queues of its clients & its store, so that a burst of events or many slow clients cannot exhaust the
+// host's memory. Usage is measured once per interval. Once it reaches the high-water mark, a
+// SystemMemoryHigh event is emitted, & once it exceeds the budget, a SystemMemoryExceeded event is
+// emitted & the budget's action is applied until usage falls within it. Only stores that implement the
+// store.SizedStore interface are measured. Usage is reported by the Stats method & the MetricsHandler.
+// Only the data of each event is counted, so the memory actually used is somewhat higher.
+func WithMemoryBudget(cfg MemoryBudget) Option {
+	return func(b *defaultBroker) {
+		if cfg.MaxBytes <= 0 {
+			b.memory = nil
+			return
+		}
+
+		if cfg.HighWater <= 0 || cfg.HighWater > 1 {
+			cfg.HighWater = defaultMemoryHighWater
+		}
+
+		if cfg.Interval <= 0 {
+			cfg.Interval = defaultMemoryInterval
+		}
+
+		b.memory = &memoryBudget{config: cfg}
+	}
+}
+
+// enforceMemory starts measuring the memory used by buffered events once per interval, if the broker
+// has a memory budget, until the broker is closed.
+func (b *defaultBroker) enforceMemory() {
+	if b.memory == nil {
+		return
+	}
+
+	go func() {
+		ticker := b.clock.NewTicker(b.memory.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				b.checkMemory()
+			case <-b.closed:
+				return
+			}
+		}
+	}()
+}
+
+// checkMemory measures the memory used by buffered events, emitting system events as it crosses the
+// high-water mark & the budget, & applies the budget's action if it is exceeded.
+func (b *defaultBroker) checkMemory() {
+	m := b.memory
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	clients, queued := b.buffered()
+	sized, _ := b.store.(store.SizedStore)
+
+	used := queued
+
+	if sized != nil {
+		used += sized.Size()
+	}
+
+	high := float64(used) >= m.config.HighWater*float64(m.config.MaxBytes)
+	over := used > m.config.MaxBytes
+
+	if high && !m.high {
+		b.system.emit(SystemEvent{Type: SystemMemoryHigh, Time: b.clock.Now()})
+	}
+
+	if over && !m.over {
+		atomic.AddUint64(&m.exceeded, 1)
+		b.system.emit(SystemEvent{Type: SystemMemoryExceeded, Time: b.clock.Now()})
+	}
+
+	m.high, m.over = high, over
+
+	if m.config.Action == MemoryReject {
+		var rejecting int32
+
+		if over {
+			rejecting = 1
+		}
+
+		atomic.StoreInt32(&m.rejecting, rejecting)
+		return
+	}
+
+	if !over {
+		return
+	}
+
+	excess := used - m.config.MaxBytes
+	freed := 0
+
+	// The largest queues are reduced first, as they belong to the clients furthest behind.
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].bytes > clients[j].bytes
+	})
+
+	switch m.config.Action {
+	case MemoryEvict:
+		for _, bc := range clients {
+			if freed >= excess || bc.bytes == 0 {
+				break
+			}
+
+			b.evict(bc.client, DisconnectMemory)
+			freed += bc.bytes
+		}
+	default:
+		if sized != nil {
+			freed += sized.Shrink(excess)
+		}
+
+		for _, bc := range clients {
+			if freed >= excess || bc.bytes == 0 {
+				break
+			}
+
+			freed += bc.client.Shed(excess - freed)
+		}
+	}
+
+	atomic.AddUint64(&m.freed, uint64(freed))
+}
+
+// buffered returns each of the broker's clients with the bytes of event data buffered for it, along
+// with the total.
+func (b *defaultBroker) buffered() ([]bufferedClient, int) {
+	var (
+		out   []bufferedClient
+		total int
+	)
+
+	b.clients.Range(func(key, value interface{}) bool {
+		if c, ok := value.(*client.Client); ok {
+			n := c.Buffered()
+			out = append(out, bufferedClient{client: c, bytes: n})
+			total += n
+		}
+
+		return true
+	})
+
+	return out, total
+}
+
+// memoryStats returns the memory used by buffered events, given the bytes queued for clients.
+func (b *defaultBroker) memoryStats(queued int) MemoryStats {
+	if b.memory == nil {
+		return MemoryStats{}
+	}
+
+	out := MemoryStats{
+		Budget:   b.memory.config.MaxBytes,
+		Queued:   queued,
+		Exceeded: atomic.LoadUint64(&b.memory.exceeded),
+		Freed:    atomic.LoadUint64(&b.memory.freed),
+	}
+
+	if sized, ok := b.store.(store.SizedStore); ok {
+		out.Stored = sized.Size()
+	}
+
+	return out
+}
+
+// reject determines if broadcasts should be rejected because the memory budget is exceeded.
+func (m *memoryBudget) reject() bool {
+	return m != nil && atomic.LoadInt32(&m.rejecting) == 1
+}
@@ -0,0 +1,102 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithMemoryBudget(t *testing.T) {
+	tt := []struct {
+		Name            string
+		Action          broker.MemoryAction
+		ExpectedMemory  broker.MemoryStats
+		ExpectedClients int
+		ExpectsRejected bool
+	}{
+		{
+			Name:            "It should discard stored events, then the oldest queued events",
+			Action:          broker.MemoryDropOldest,
+			ExpectedMemory:  broker.MemoryStats{Budget: 20, Queued: 20, Stored: 0, Exceeded: 1, Freed: 16},
+			ExpectedClients: 2,
+		},
+		{
+			Name:           "It should evict the clients using the most memory",
+			Action:         broker.MemoryEvict,
+			ExpectedMemory: broker.MemoryStats{Budget: 20, Queued: 0, Stored: 12, Exceeded: 1, Freed: 24},
+		},
+		{
+			Name:            "It should reject broadcasts",
+			Action:          broker.MemoryReject,
+			ExpectedMemory:  broker.MemoryStats{Budget: 20, Queued: 24, Stored: 12, Exceeded: 1},
+			ExpectedClients: 2,
+			ExpectsRejected: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+			b := broker.New(time.Second, 3, nil,
+				broker.WithClock(clk),
+				broker.WithStore(store.NewMemory(10)),
+				broker.WithMemoryBudget(broker.MemoryBudget{MaxBytes: 20, HighWater: 0.5, Action: tc.Action, Interval: time.Second}),
+			)
+			defer b.Close()
+
+			var (
+				mux    sync.Mutex
+				system []broker.SystemEventType
+			)
+
+			b.OnSystemEvent(func(se broker.SystemEvent) {
+				mux.Lock()
+				defer mux.Unlock()
+
+				system = append(system, se.Type)
+			})
+
+			assert.NoError(t, b.Subscribe(client.NewWithOptions(time.Second, 3, "a", client.WithQueueSize(10))))
+			assert.NoError(t, b.Subscribe(client.NewWithOptions(time.Second, 3, "b", client.WithQueueSize(10))))
+
+			for i := 0; i < 3; i++ {
+				assert.NoError(t, b.Broadcast([]byte("abcd")))
+			}
+
+			<-time.After(time.Millisecond * 50)
+			clk.Advance(time.Second)
+			<-time.After(time.Millisecond * 50)
+
+			stats := b.Stats()
+			assert.Equal(t, tc.ExpectedMemory, stats.Memory)
+			assert.Equal(t, tc.ExpectedClients, stats.Clients)
+
+			err := b.Broadcast([]byte("abcd"))
+			assert.Equal(t, tc.ExpectsRejected, err == broker.ErrMemoryBudget)
+
+			if tc.ExpectsRejected {
+				w := httptest.NewRecorder()
+				b.EventHandler(w, httptest.NewRequest(http.MethodPost, "/broadcast", strings.NewReader("abcd")))
+
+				assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+				assert.Equal(t, "1", w.Header().Get("Retry-After"))
+			}
+
+			mux.Lock()
+			defer mux.Unlock()
+
+			assert.Contains(t, system, broker.SystemMemoryHigh)
+			assert.Contains(t, system, broker.SystemMemoryExceeded)
+		})
+	}
+}
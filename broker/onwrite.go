@@ -0,0 +1,54 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// WriteHook is a function that is called after each event is written to a client's stream, with
+	// the number of bytes written, the time since the event was broadcast & any error writing it.
+	WriteHook func(clientID string, bytes int, latency time.Duration, err error)
+
+	// The instrumentedWriter type is a FrameWriter that reports each event it writes to a WriteHook.
+	instrumentedWriter struct {
+		FrameWriter
+		broker *defaultBroker
+		out    *countingWriter
+		client string
+	}
+)
+
+// WithWriteHook configures a function that is called after each event is written to a client's
+// stream, including events that are replayed or sent when the client subscribes, so that applications
+// can track their own delivery objectives. The number of bytes is measured after the event has been
+// encoded & encrypted, but before the stream is compressed. Events without a timestamp are reported
+// with no latency. Writes are buffered until the stream is flushed, so an error may not be reported
+// until a later event is written. The hook is called synchronously, so it should return quickly.
+func WithWriteHook(fn WriteHook) Option {
+	return func(b *defaultBroker) {
+		b.onWrite = fn
+	}
+}
+
+// instrument returns a FrameWriter that reports each event written to the client's stream to the
+// broker's write hook, or the FrameWriter itself if the broker has no write hook.
+func (b *defaultBroker) instrument(enc FrameWriter, out *countingWriter, c *client.Client) FrameWriter {
+	if b.onWrite == nil {
+		return enc
+	}
+
+	return &instrumentedWriter{FrameWriter: enc, broker: b, out: out, client: c.ID()}
+}
+
+// Encode writes the event to the stream & reports the write to the broker's write hook.
+func (w *instrumentedWriter) Encode(e event.Event) error {
+	written := w.out.n
+	err := w.FrameWriter.Encode(e)
+
+	w.broker.onWrite(w.client, int(w.out.n-written), w.broker.latency(e), err)
+
+	return err
+}
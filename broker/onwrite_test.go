@@ -0,0 +1,47 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	write struct {
+		ClientID string
+		Bytes    int
+		Err      error
+	}
+)
+
+func TestBroker_WithWriteHook(t *testing.T) {
+	writes := make(chan write, 10)
+	hook := func(clientID string, bytes int, latency time.Duration, err error) {
+		writes <- write{ClientID: clientID, Bytes: bytes, Err: err}
+	}
+
+	brk := broker.New(time.Second, 3, nil, broker.WithWriteHook(hook))
+	defer brk.Close()
+
+	w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+	r := httptest.NewRequest(http.MethodGet, "/connect?id=alice", nil)
+
+	go brk.ClientHandler(w, r)
+	<-time.After(time.Millisecond * 100)
+
+	assert.NoError(t, brk.BroadcastEvent(event.Event{ID: "1", Data: []byte("hello")}))
+
+	select {
+	case wr := <-writes:
+		assert.Equal(t, write{ClientID: "alice", Bytes: len("id: 1\ndata: hello\n\n")}, wr)
+	case <-time.After(time.Second):
+		t.Fatal("write was not reported")
+	}
+
+	close(w.close)
+}
@@ -0,0 +1,76 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/davidsbond/sse/client"
+)
+
+const (
+	// The number of events held for a paused client when no limit is configured.
+	defaultPauseLimit = 1024
+)
+
+// WithPauseLimit configures the number of events held for each paused client, see the Pause
+// method. Once a paused client's limit is reached, further events for it are discarded. If
+// 'limit' is zero, up to 1024 events are held.
+func WithPauseLimit(limit int) Option {
+	return func(b *defaultBroker) {
+		b.pauseLimit = limit
+	}
+}
+
+// Pause stops delivering events to the client with the given id, holding them until the client is
+// resumed using the Resume method. This is useful when the application knows a client is not yet
+// ready for new events, for example while it is synchronizing its state. Events already queued
+// for the client are still delivered. Only clients connected to this broker can be paused.
+func (b *defaultBroker) Pause(id string) error {
+	client, err := b.client(id)
+
+	if err != nil {
+		return err
+	}
+
+	limit := b.pauseLimit
+
+	if limit <= 0 {
+		limit = defaultPauseLimit
+	}
+
+	client.Pause(limit)
+
+	return nil
+}
+
+// Resume delivers the events held for the client with the given id since it was paused, followed
+// by new events as normal. If the client is not paused, this method does nothing.
+func (b *defaultBroker) Resume(id string) error {
+	client, err := b.client(id)
+
+	if err != nil {
+		return err
+	}
+
+	client.Resume()
+
+	return nil
+}
+
+// client returns the client connected to this broker with the given id.
+func (b *defaultBroker) client(id string) (*client.Client, error) {
+	item, ok := b.clients.Load(id)
+
+	if !ok {
+		return nil, fmt.Errorf("no client with id %v exists", id)
+	}
+
+	client, ok := item.(*client.Client)
+
+	if !ok {
+		b.removeClient(id, "")
+		return nil, errors.New("client is malformed, disconnecting")
+	}
+
+	return client, nil
+}
@@ -0,0 +1,55 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Pause(t *testing.T) {
+	tt := []struct {
+		ClientID      string
+		ExpectedError string
+	}{
+		{ClientID: "test"},
+		{ClientID: "unknown", ExpectedError: "no client with id unknown exists"},
+	}
+
+	for _, tc := range tt {
+		b := broker.New(time.Second, 3, nil, broker.WithQueueSize(10))
+		w := &FlushRecorder{header: http.Header{}}
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go b.ClientHandler(w, httptest.NewRequest("GET", "/connect?id=test", nil))
+		<-time.Tick(time.Second)
+
+		err := b.Pause(tc.ClientID)
+
+		if tc.ExpectedError != "" {
+			assert.EqualError(t, err, tc.ExpectedError)
+			assert.EqualError(t, b.Resume(tc.ClientID), tc.ExpectedError)
+			b.Close()
+			continue
+		}
+
+		assert.NoError(t, err)
+
+		// Events are held while the client is paused.
+		assert.NoError(t, b.Broadcast([]byte("delta")))
+		<-time.Tick(time.Millisecond * 100)
+		assert.False(t, strings.Contains(w.String(), "delta"))
+
+		// And delivered once it is resumed.
+		assert.NoError(t, b.Resume(tc.ClientID))
+		<-time.Tick(time.Millisecond * 100)
+		assert.True(t, strings.Contains(w.String(), "data: delta"))
+
+		b.Close()
+	}
+}
@@ -0,0 +1,45 @@
+package broker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// EventSource is a function that produces an event to be broadcast periodically, such as
+	// a heartbeat or the latest value of a metric. If it returns an error, nothing is broadcast
+	// for that interval.
+	EventSource func() (event.Event, error)
+)
+
+// Every calls 'fn' once per interval & broadcasts the event it returns, in the same way as the
+// BroadcastEvent method. This continues until the returned function is called or the broker is
+// closed. Errors from 'fn' or from broadcasting the event are ignored.
+func (b *defaultBroker) Every(interval time.Duration, fn EventSource) func() {
+	stop := make(chan struct{})
+	once := &sync.Once{}
+
+	go func() {
+		ticker := b.clock.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				if e, err := fn(); err == nil {
+					b.BroadcastEvent(e)
+				}
+			case <-stop:
+				return
+			case <-b.closed:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(stop) })
+	}
+}
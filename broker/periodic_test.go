@@ -0,0 +1,76 @@
+package broker_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Every(t *testing.T) {
+	tt := []struct {
+		Fail        bool
+		Stop        bool
+		ExpectEvent bool
+	}{
+		{ExpectEvent: true},
+		{Fail: true},
+		{Stop: true},
+	}
+
+	for _, tc := range tt {
+		b := broker.New(time.Second, 3, nil)
+		c := ssetest.NewClient("test", client.WithQueueSize(10))
+
+		assert.NoError(t, b.Subscribe(c.Client))
+
+		var calls int32
+
+		stop := b.Every(time.Millisecond*100, func() (event.Event, error) {
+			atomic.AddInt32(&calls, 1)
+
+			if tc.Fail {
+				return event.Event{}, errors.New("failed")
+			}
+
+			return event.Event{Type: "tick"}, nil
+		})
+
+		if tc.Stop {
+			stop()
+		}
+
+		events, err := c.Wait(2, time.Second)
+
+		if tc.ExpectEvent {
+			assert.NoError(t, err)
+			assert.Equal(t, "tick", events[0].Type)
+		} else {
+			assert.Error(t, err)
+			assert.Empty(t, events)
+		}
+
+		if tc.Stop {
+			assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+		}
+
+		// Once the broker is closed, nothing more should be produced or broadcast.
+		b.Close()
+		<-time.After(time.Millisecond * 50)
+
+		count, called := len(c.Events()), atomic.LoadInt32(&calls)
+		<-time.After(time.Millisecond * 300)
+
+		assert.Len(t, c.Events(), count)
+		assert.Equal(t, called, atomic.LoadInt32(&calls))
+
+		stop()
+		c.Close()
+	}
+}
@@ -0,0 +1,112 @@
+package broker
+
+import (
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/store"
+)
+
+// WithPersistentSubscriptions configures the broker to record the topics & groups each client is
+// subscribed to in its store, which must implement store.SubscriptionStore. A client that reconnects
+// without listing any topics, possibly after the broker has restarted, is subscribed to the topics
+// & groups recorded for it. Clients are identified by the identifier they connected with, or by the
+// session given in the 'session' query parameter if it can no longer be resumed, see the
+// broker.WithSessions method. Changes made using the UpdateSubscriptions method are also recorded.
+// As groups are restored, identifiers should be authenticated, see the broker.WithClientFromContext
+// method.
+func WithPersistentSubscriptions(enabled bool) Option {
+	return func(b *defaultBroker) {
+		b.persistSubs = enabled
+	}
+}
+
+// subscriptionStore returns the store that subscriptions are recorded in. If subscriptions are not
+// persisted, false is returned.
+func (b *defaultBroker) subscriptionStore() (store.SubscriptionStore, bool) {
+	if !b.persistSubs {
+		return nil, false
+	}
+
+	subs, ok := b.store.(store.SubscriptionStore)
+
+	return subs, ok
+}
+
+// subscriptionKeys returns the keys the subscription of a client is recorded under: its identifier,
+// if it connected with one, & its session, if it has one.
+func subscriptionKeys(info ClientInfo, session string) []string {
+	var keys []string
+
+	if info.ID != "" {
+		keys = append(keys, "id:"+info.ID)
+	}
+
+	if session != "" {
+		keys = append(keys, "session:"+session)
+	}
+
+	return keys
+}
+
+// restoreSubscription sets the topics & groups of the client to those recorded for it, unless the
+// client has listed its own topics.
+func (b *defaultBroker) restoreSubscription(info *ClientInfo, session string) {
+	subs, ok := b.subscriptionStore()
+
+	if !ok || len(info.Topics) > 0 {
+		return
+	}
+
+	for _, key := range subscriptionKeys(*info, session) {
+		sub, err := subs.Subscription(key)
+
+		if err != nil || (sub.Topics == nil && sub.Groups == nil) {
+			continue
+		}
+
+		info.Topics = sub.Topics
+
+		if info.Groups == nil {
+			info.Groups = sub.Groups
+		}
+
+		return
+	}
+}
+
+// persistSubscription records the subscription of the client under each of its keys, remembering
+// them so that later changes are also recorded.
+func (b *defaultBroker) persistSubscription(c *client.Client, info ClientInfo, session string) {
+	if _, ok := b.subscriptionStore(); !ok {
+		return
+	}
+
+	keys := subscriptionKeys(info, session)
+
+	if len(keys) == 0 {
+		return
+	}
+
+	b.subscriptionKeys.Store(c, keys)
+	b.saveSubscription(c)
+}
+
+// saveSubscription records the current subscription of the client under its keys, if it has any.
+func (b *defaultBroker) saveSubscription(c *client.Client) {
+	subs, ok := b.subscriptionStore()
+
+	if !ok {
+		return
+	}
+
+	item, ok := b.subscriptionKeys.Load(c)
+
+	if !ok {
+		return
+	}
+
+	sub := store.Subscription{Topics: c.Topics(), Groups: c.Groups()}
+
+	for _, key := range item.([]string) {
+		subs.SetSubscription(key, sub)
+	}
+}
@@ -0,0 +1,113 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithPersistentSubscriptions(t *testing.T) {
+	tt := []struct {
+		Name           string
+		Enabled        bool
+		Query          string
+		ExpectedOutput string
+	}{
+		{
+			Name:           "It should restore the topics & groups recorded for the client's identifier",
+			Enabled:        true,
+			Query:          "?id=alice",
+			ExpectedOutput: "id: 1\ndata: news\n\nid: 3\ndata: team\n\n",
+		},
+		{
+			Name:           "It should restore the subscription recorded for the client's session",
+			Enabled:        true,
+			Query:          "?session=expired",
+			ExpectedOutput: "id: 1\ndata: news\n\n",
+		},
+		{
+			Name:           "It should not restore subscriptions for clients listing their own topics",
+			Enabled:        true,
+			Query:          "?id=alice&topic=sports",
+			ExpectedOutput: "id: 2\ndata: sports\n\n",
+		},
+		{
+			Name:    "It should not restore subscriptions when disabled",
+			Query:   "?id=alice",
+			Enabled: false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			s := store.NewMemory(10)
+			subs := s.(store.SubscriptionStore)
+
+			assert.NoError(t, subs.SetSubscription("id:alice", store.Subscription{Topics: []string{"news"}, Groups: []string{"team:1"}}))
+			assert.NoError(t, subs.SetSubscription("session:expired", store.Subscription{Topics: []string{"news"}}))
+
+			brk := broker.New(time.Second, 3, nil,
+				broker.WithStore(s),
+				broker.WithSessions(time.Minute),
+				broker.WithPersistentSubscriptions(tc.Enabled))
+			defer brk.Close()
+
+			w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+			r := httptest.NewRequest(http.MethodGet, "/connect"+tc.Query, nil)
+
+			go brk.ClientHandler(w, r)
+			<-time.After(time.Millisecond * 100)
+
+			assert.NoError(t, brk.BroadcastEvent(event.Event{ID: "1", Topic: "news", Data: []byte("news")}))
+			assert.NoError(t, brk.BroadcastEvent(event.Event{ID: "2", Topic: "sports", Data: []byte("sports")}))
+			assert.NoError(t, brk.BroadcastEvent(event.Event{ID: "3", Group: "team:1", Data: []byte("team")}))
+			<-time.After(time.Millisecond * 100)
+
+			// Skip the event informing the client of its new session.
+			output := w.String()
+			output = output[strings.Index(output, "\n\n")+2:]
+
+			assert.Equal(t, tc.ExpectedOutput, output)
+		})
+	}
+}
+
+func TestBroker_PersistentSubscriptionsRecorded(t *testing.T) {
+	s := store.NewMemory(10)
+	subs := s.(store.SubscriptionStore)
+
+	brk := broker.New(time.Second, 3, nil, broker.WithStore(s), broker.WithPersistentSubscriptions(true))
+	defer brk.Close()
+
+	w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+	r := httptest.NewRequest(http.MethodGet, "/connect?id=alice&topic=news", nil)
+
+	go brk.ClientHandler(w, r)
+	<-time.After(time.Millisecond * 100)
+
+	sub, err := subs.Subscription("id:alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"news"}, sub.Topics)
+
+	_, err = brk.UpdateSubscriptions("alice", broker.SubscriptionChange{Subscribe: []string{"sports"}, Unsubscribe: []string{"news"}})
+	assert.NoError(t, err)
+
+	sub, err = subs.Subscription("id:alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sports"}, sub.Topics)
+
+	// The subscription is kept once the client disconnects, so that it can be restored.
+	close(w.close)
+	<-time.After(time.Millisecond * 100)
+
+	sub, err = subs.Subscription("id:alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sports"}, sub.Topics)
+}
@@ -0,0 +1,76 @@
+package broker
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// The size of the comment written when a stream opens. Older browsers that use an
+	// EventSource polyfill, such as Internet Explorer & legacy Edge, buffer the first
+	// 2KB of a response before making any of it available.
+	polyfillPadding = 2048
+
+	// How often a comment is written to idle streams. Polyfills treat a stream that has
+	// been silent for 45 seconds as disconnected.
+	polyfillHeartbeat = time.Second * 15
+)
+
+// WithPolyfillSupport configures the broker to work around the limitations of the EventSource
+// polyfills used by older browsers. When a stream opens, a 2KB comment is written so that the
+// browser makes events available immediately, and a comment is written every 15 seconds so that
+// idle streams are not considered disconnected. Proxies are also asked not to buffer the stream.
+// Polyfills that cannot set headers send the last event identifier using the 'lastEventId' query
+// parameter, which the broker always accepts.
+func WithPolyfillSupport(enabled bool) Option {
+	return func(b *defaultBroker) {
+		b.polyfill = enabled
+	}
+}
+
+// polyfillHeaders sets the headers used by polyfills, if polyfill support is enabled.
+func (b *defaultBroker) polyfillHeaders(h http.Header) {
+	if b.polyfill {
+		h.Set("X-Accel-Buffering", "no")
+	}
+}
+
+// writePadding writes the comment that fills the buffer of older browsers & proxies, if polyfill
+// support is enabled or streams are adapted for proxies. Returns true if anything was written.
+func (b *defaultBroker) writePadding(enc FrameWriter) bool {
+	if !b.polyfill && !b.proxied() {
+		return false
+	}
+
+	enc.Comment(strings.Repeat(" ", polyfillPadding))
+
+	return true
+}
+
+// heartbeat returns a channel that is signalled each time a comment should be written to keep
+// the stream open, and a function to release its resources. If polyfill support is disabled &
+// streams are not adapted for proxies, the channel is nil.
+func (b *defaultBroker) heartbeat() (<-chan time.Time, func()) {
+	interval := b.heartbeatInterval()
+
+	if interval <= 0 {
+		return nil, func() {}
+	}
+
+	ticker := b.clock.NewTicker(interval)
+
+	return ticker.C(), ticker.Stop
+}
+
+// heartbeatInterval returns how often a comment is written to idle streams, or zero if it is not.
+func (b *defaultBroker) heartbeatInterval() time.Duration {
+	switch {
+	case b.proxied():
+		return proxyHeartbeat
+	case b.polyfill:
+		return polyfillHeartbeat
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,42 @@
+package broker_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithPolyfillSupport(t *testing.T) {
+	tt := []struct {
+		Enabled        bool
+		ExpectedPrefix string
+		ExpectedHeader string
+	}{
+		{Enabled: true, ExpectedPrefix: ":" + strings.Repeat(" ", 2048) + "\n", ExpectedHeader: "no"},
+		{Enabled: false, ExpectedPrefix: "data: hello\n\n"},
+	}
+
+	for _, tc := range tt {
+		b := broker.New(time.Second, 3, nil, broker.WithPolyfillSupport(tc.Enabled))
+		w := ssetest.NewStreamRecorder()
+
+		go b.ClientHandler(w, w.NewRequest("GET", "/connect?id=test", nil))
+		<-time.Tick(time.Second)
+
+		assert.NoError(t, b.BroadcastTo("test", []byte("hello")))
+
+		events, err := w.WaitForEvents(1, time.Second)
+
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+		assert.True(t, strings.HasPrefix(w.Body(), tc.ExpectedPrefix))
+		assert.Equal(t, tc.ExpectedHeader, w.Header().Get("X-Accel-Buffering"))
+
+		w.Close()
+		b.Close()
+	}
+}
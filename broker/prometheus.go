@@ -0,0 +1,187 @@
+package broker
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// The prometheusMetric type describes a single metric written by the MetricsHandler.
+	prometheusMetric struct {
+		name    string
+		help    string
+		kind    string
+		samples []prometheusSample
+	}
+
+	// The prometheusSample type is a single value of a metric, with an optional topic label.
+	prometheusSample struct {
+		topic string
+		value float64
+	}
+)
+
+var (
+	prometheusReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// MetricsHandler returns an http.Handler serving the broker's statistics in the Prometheus text
+// exposition format, so that they can be scraped without a StatsD server. The number of connected
+// clients & pending events are served as gauges, as are the subscribers, event rate & average payload
+// size of each topic, which are labelled with the topic's name. The number of events delivered, events
+// that failed, bytes written & events broadcast to each topic are served as counters. If the broker
+// has a memory budget, the budget & the bytes buffered for clients & by the store are served as gauges.
+//
+// Example using http (https://golang.org/pkg/net/http/)
+//
+// http.Handle("/metrics", broker.MetricsHandler(b))
+func MetricsHandler(b Broker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := &bytes.Buffer{}
+
+		for _, metric := range prometheusMetrics(b.Stats()) {
+			metric.write(buf)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
+
+// prometheusMetrics returns the metrics for the statistics, with the samples of each topic
+// metric sorted by topic.
+func prometheusMetrics(stats Stats) []prometheusMetric {
+	delivered, failed, pending := stats.totals()
+
+	topics := make([]string, 0, len(stats.Topics))
+
+	for topic := range stats.Topics {
+		topics = append(topics, topic)
+	}
+
+	sort.Strings(topics)
+
+	perTopic := func(value func(TopicStats) float64) []prometheusSample {
+		samples := make([]prometheusSample, len(topics))
+
+		for i, topic := range topics {
+			samples[i] = prometheusSample{topic: topic, value: value(stats.Topics[topic])}
+		}
+
+		return samples
+	}
+
+	// Memory is only reported by brokers with a memory budget.
+	memory := func(value int) []prometheusSample {
+		if stats.Memory.Budget == 0 {
+			return nil
+		}
+
+		return []prometheusSample{{value: float64(value)}}
+	}
+
+	return []prometheusMetric{
+		{
+			name:    "sse_clients",
+			help:    "The number of connected clients.",
+			kind:    "gauge",
+			samples: []prometheusSample{{value: float64(stats.Clients)}},
+		},
+		{
+			name:    "sse_pending_events",
+			help:    "The number of events queued for clients that have not yet been written.",
+			kind:    "gauge",
+			samples: []prometheusSample{{value: float64(pending)}},
+		},
+		{
+			name:    "sse_events_delivered_total",
+			help:    "The number of events written to clients.",
+			kind:    "counter",
+			samples: []prometheusSample{{value: float64(delivered)}},
+		},
+		{
+			name:    "sse_events_failed_total",
+			help:    "The number of events that could not be written to clients.",
+			kind:    "counter",
+			samples: []prometheusSample{{value: float64(failed)}},
+		},
+		{
+			name:    "sse_bytes_sent_total",
+			help:    "The number of bytes written to clients.",
+			kind:    "counter",
+			samples: []prometheusSample{{value: float64(stats.BytesSent)}},
+		},
+		{
+			name:    "sse_topic_subscribers",
+			help:    "The number of clients subscribed to the topic.",
+			kind:    "gauge",
+			samples: perTopic(func(ts TopicStats) float64 { return float64(ts.Subscribers) }),
+		},
+		{
+			name:    "sse_topic_events_total",
+			help:    "The number of events broadcast to the topic.",
+			kind:    "counter",
+			samples: perTopic(func(ts TopicStats) float64 { return float64(ts.Events) }),
+		},
+		{
+			name:    "sse_topic_events_per_second",
+			help:    "The rate events were broadcast to the topic, averaged over the last ten seconds.",
+			kind:    "gauge",
+			samples: perTopic(func(ts TopicStats) float64 { return ts.EventsPerSecond }),
+		},
+		{
+			name:    "sse_topic_payload_bytes",
+			help:    "The average size of the data of events broadcast to the topic.",
+			kind:    "gauge",
+			samples: perTopic(func(ts TopicStats) float64 { return ts.AveragePayload }),
+		},
+		{
+			name:    "sse_topic_bytes_sent_total",
+			help:    "The number of bytes written to clients for events on the topic.",
+			kind:    "counter",
+			samples: perTopic(func(ts TopicStats) float64 { return float64(ts.BytesSent) }),
+		},
+		{
+			name:    "sse_memory_budget_bytes",
+			help:    "The bytes of event data the broker can buffer.",
+			kind:    "gauge",
+			samples: memory(stats.Memory.Budget),
+		},
+		{
+			name:    "sse_memory_queued_bytes",
+			help:    "The bytes of event data queued for clients.",
+			kind:    "gauge",
+			samples: memory(stats.Memory.Queued),
+		},
+		{
+			name:    "sse_memory_stored_bytes",
+			help:    "The bytes of event data held by the broker's store.",
+			kind:    "gauge",
+			samples: memory(stats.Memory.Stored),
+		},
+	}
+}
+
+// write writes the metric in the text exposition format. Metrics without samples, such as the
+// topic metrics of a broker without topics, are omitted.
+func (m prometheusMetric) write(buf *bytes.Buffer) {
+	if len(m.samples) == 0 {
+		return
+	}
+
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+
+	for _, sample := range m.samples {
+		buf.WriteString(m.name)
+
+		if sample.topic != "" {
+			fmt.Fprintf(buf, `{topic="%s"}`, prometheusReplacer.Replace(sample.topic))
+		}
+
+		buf.WriteString(" " + strconv.FormatFloat(sample.value, 'g', -1, 64) + "\n")
+	}
+}
@@ -0,0 +1,49 @@
+package broker_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithShards(1))
+	defer brk.Close()
+
+	assert.NoError(t, brk.Subscribe(client.NewWithOptions(time.Second, 3, "", client.WithTopics("news", `say "hi"`), client.WithQueueSize(10))))
+	assert.NoError(t, brk.BroadcastTopic("news", []byte("hello")))
+	assert.NoError(t, brk.BroadcastTopic("news", []byte("hi")))
+
+	w := httptest.NewRecorder()
+	broker.MetricsHandler(brk).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body, err := ioutil.ReadAll(w.Result().Body)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+
+	lines := strings.Split(string(body), "\n")
+
+	for _, expected := range []string{
+		"# TYPE sse_clients gauge",
+		"sse_clients 1",
+		"# TYPE sse_events_delivered_total counter",
+		"# TYPE sse_topic_events_total counter",
+		`sse_topic_subscribers{topic="news"} 1`,
+		`sse_topic_subscribers{topic="say \"hi\""} 1`,
+		`sse_topic_events_total{topic="news"} 2`,
+		`sse_topic_payload_bytes{topic="news"} 3.5`,
+	} {
+		assert.Contains(t, lines, expected)
+	}
+}
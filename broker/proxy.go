@@ -0,0 +1,91 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type (
+	// ProxyProfile determines how the broker adapts event streams to the proxies & load balancers
+	// between it & its clients.
+	ProxyProfile int
+)
+
+const (
+	// ProxyDirect writes streams as configured, for clients that connect to the broker directly or
+	// through proxies that do not buffer responses. This is the default profile.
+	ProxyDirect ProxyProfile = iota
+
+	// ProxyCompatible applies the workarounds needed for streams to work through proxies such as
+	// nginx, Cloudflare & AWS load balancers. Proxies are asked not to buffer (X-Accel-Buffering: no)
+	// or transform (Cache-Control: no-transform) the stream, a 2KB comment is written when it opens
+	// so that proxies that buffer the start of a response forward it, events are flushed as soon
+	// as they are written & a comment is written every 10 seconds so that idle streams are not
+	// closed by the proxy's idle timeout.
+	ProxyCompatible
+)
+
+const (
+	// How often a comment is written to idle streams under the ProxyCompatible profile. This is
+	// well within the default idle timeouts of common proxies, the shortest being 60 seconds.
+	proxyHeartbeat = time.Second * 10
+)
+
+// WithProxyProfile configures how the broker adapts event streams to the proxies between it & its
+// clients, see the ProxyProfile type. The ProxyCompatible profile overrides the broker's coalescing
+// window, see the broker.WithCoalesceWindow method.
+func WithProxyProfile(profile ProxyProfile) Option {
+	return func(b *defaultBroker) {
+		b.proxyProfile = profile
+	}
+}
+
+// ParseProxyProfile parses the name of a proxy profile, either 'direct' or 'compatible'. A blank
+// name is parsed as the ProxyDirect profile.
+func ParseProxyProfile(name string) (ProxyProfile, error) {
+	switch name {
+	case "", "direct":
+		return ProxyDirect, nil
+	case "compatible":
+		return ProxyCompatible, nil
+	default:
+		return ProxyDirect, fmt.Errorf("unknown proxy profile %q", name)
+	}
+}
+
+// String returns the name of the profile.
+func (p ProxyProfile) String() string {
+	switch p {
+	case ProxyDirect:
+		return "direct"
+	case ProxyCompatible:
+		return "compatible"
+	default:
+		return "unknown"
+	}
+}
+
+// proxied determines if streams are adapted for proxies.
+func (b *defaultBroker) proxied() bool {
+	return b.proxyProfile == ProxyCompatible
+}
+
+// proxyHeaders sets the headers that stop proxies buffering or transforming the stream, if
+// streams are adapted for proxies.
+func (b *defaultBroker) proxyHeaders(h http.Header) {
+	if b.proxied() {
+		h.Set("Cache-Control", "no-cache, no-transform")
+		h.Set("X-Accel-Buffering", "no")
+	}
+}
+
+// coalescing returns the window within which events written to a client are flushed together.
+// Streams adapted for proxies are flushed after every event.
+func (b *defaultBroker) coalescing() time.Duration {
+	if b.proxied() {
+		return 0
+	}
+
+	return b.coalesceWindow
+}
@@ -0,0 +1,84 @@
+package broker_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithProxyProfile(t *testing.T) {
+	tt := []struct {
+		Name                 string
+		Profile              broker.ProxyProfile
+		ExpectedBody         string
+		ExpectedBuffering    string
+		ExpectedCacheControl string
+	}{
+		{
+			Name:                 "It should pad streams & ask proxies not to buffer them",
+			Profile:              broker.ProxyCompatible,
+			ExpectedBody:         ":" + strings.Repeat(" ", 2048) + "\ndata: hello\n\n",
+			ExpectedBuffering:    "no",
+			ExpectedCacheControl: "no-cache, no-transform",
+		},
+		{
+			Name:                 "It should write streams as configured by default",
+			Profile:              broker.ProxyDirect,
+			ExpectedBody:         "",
+			ExpectedCacheControl: "no-cache",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Events would be held for an hour if the profile did not disable coalescing.
+			b := broker.New(time.Second, 3, nil, broker.WithProxyProfile(tc.Profile), broker.WithCoalesceWindow(time.Hour))
+			defer b.Close()
+
+			w := ssetest.NewStreamRecorder()
+			defer w.Close()
+
+			go b.ClientHandler(w, w.NewRequest("GET", "/connect?id=test", nil))
+			<-time.After(time.Millisecond * 100)
+
+			assert.NoError(t, b.BroadcastTo("test", []byte("hello")))
+
+			<-time.After(time.Millisecond * 100)
+
+			assert.Equal(t, tc.ExpectedBody, w.Body())
+			assert.Equal(t, tc.ExpectedBuffering, w.Header().Get("X-Accel-Buffering"))
+			assert.Equal(t, tc.ExpectedCacheControl, w.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestParseProxyProfile(t *testing.T) {
+	tt := []struct {
+		Name            string
+		Expected        broker.ProxyProfile
+		ExpectsError    bool
+		ExpectedProfile string
+	}{
+		{Name: "", Expected: broker.ProxyDirect, ExpectedProfile: "direct"},
+		{Name: "direct", Expected: broker.ProxyDirect, ExpectedProfile: "direct"},
+		{Name: "compatible", Expected: broker.ProxyCompatible, ExpectedProfile: "compatible"},
+		{Name: "nginx", ExpectsError: true},
+	}
+
+	for _, tc := range tt {
+		profile, err := broker.ParseProxyProfile(tc.Name)
+
+		if tc.ExpectsError {
+			assert.Error(t, err)
+			continue
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, tc.Expected, profile)
+		assert.Equal(t, tc.ExpectedProfile, profile.String())
+	}
+}
@@ -0,0 +1,47 @@
+package broker
+
+import (
+	"github.com/davidsbond/sse/client"
+)
+
+// WithQueueSize configures the number of events that can be queued for each client before
+// writes to it block. Queued events are written to the client as soon as it is able to
+// receive them. If 'size' is zero, events are not queued and each write waits until the
+// client has received the event.
+func WithQueueSize(size int) Option {
+	return func(b *defaultBroker) {
+		b.queueSize = size
+	}
+}
+
+// WithSlowPolicy configures how the broker detects clients that cannot keep up with the
+// events broadcast to them, and what it does with new events for those clients. See the
+// client.SlowPolicy type for details.
+func WithSlowPolicy(p client.SlowPolicy) Option {
+	return func(b *defaultBroker) {
+		b.slowPolicy = p
+	}
+}
+
+// WithWriteRetry configures how writes to clients that exceed the broker's timeout are retried
+// before they count towards the client's tolerance, so that short pauses in a consumer do not
+// cause it to be disconnected. See the client.RetryPolicy type for details.
+func WithWriteRetry(p client.RetryPolicy) Option {
+	return func(b *defaultBroker) {
+		b.retryPolicy = p
+	}
+}
+
+// Pending returns the events that have been written to the client with the given id but
+// have not yet been delivered to it, in the order they will be delivered. This is useful
+// when debugging why a specific client is falling behind. If 'payloads' is true, a copy
+// of each event's data is included.
+func (b *defaultBroker) Pending(id string, payloads bool) ([]client.Pending, error) {
+	client, err := b.client(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Pending(payloads), nil
+}
@@ -0,0 +1,164 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	BlockingRecorder struct {
+		FlushRecorder
+		unblock chan struct{}
+	}
+)
+
+func (br *BlockingRecorder) Write(data []byte) (int, error) {
+	<-br.unblock
+
+	return br.FlushRecorder.Write(data)
+}
+
+func TestBroker_Pending(t *testing.T) {
+	tt := []struct {
+		QueueSize       int
+		ClientID        string
+		Events          []string
+		Payloads        bool
+		ExpectedPending []string
+		ExpectedError   string
+	}{
+		{
+			QueueSize:       5,
+			ClientID:        "1234",
+			Events:          []string{"a", "bb", "ccc"},
+			Payloads:        true,
+			ExpectedPending: []string{"bb", "ccc"},
+		},
+		{
+			QueueSize:       5,
+			ClientID:        "1234",
+			Events:          []string{"a", "bb"},
+			ExpectedPending: []string{"bb"},
+		},
+		{
+			QueueSize:     5,
+			ExpectedError: "no client with id",
+		},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil, broker.WithQueueSize(tc.QueueSize))
+		w := &BlockingRecorder{
+			FlushRecorder: FlushRecorder{header: http.Header{}},
+			unblock:       make(chan struct{}),
+		}
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, httptest.NewRequest("GET", "/connect?id=1234", nil))
+		<-time.Tick(time.Second)
+
+		// The first event will be taken from the queue and block while being
+		// written, the rest remain pending.
+		for _, event := range tc.Events {
+			assert.NoError(t, broker.Broadcast([]byte(event)))
+			<-time.After(time.Millisecond * 50)
+		}
+
+		pending, err := broker.Pending(tc.ClientID, tc.Payloads)
+
+		if tc.ExpectedError != "" {
+			assert.Contains(t, err.Error(), tc.ExpectedError)
+		} else if assert.NoError(t, err) && assert.Len(t, pending, len(tc.ExpectedPending)) {
+			for i, p := range pending {
+				assert.Equal(t, len(tc.ExpectedPending[i]), p.Size)
+
+				if tc.Payloads {
+					assert.Equal(t, tc.ExpectedPending[i], string(p.Data))
+				}
+			}
+		}
+
+		close(w.unblock)
+		broker.Close()
+	}
+}
+
+func TestBroker_WithWriteRetry(t *testing.T) {
+	tt := []struct {
+		Name        string
+		Policy      client.RetryPolicy
+		ExpectError bool
+	}{
+		{
+			Name:        "It should fail writes to a blocked client without a policy",
+			ExpectError: true,
+		},
+		{
+			Name:   "It should retry writes until the client unblocks",
+			Policy: client.RetryPolicy{Attempts: 5, Backoff: time.Millisecond * 10},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Millisecond*50, 1, nil, broker.WithWriteRetry(tc.Policy))
+			defer b.Close()
+
+			w := &BlockingRecorder{FlushRecorder: FlushRecorder{header: http.Header{}}, unblock: make(chan struct{})}
+			go b.ClientHandler(w, httptest.NewRequest("GET", "/?id=test", nil))
+
+			for b.Stats().Clients == 0 {
+				<-time.After(time.Millisecond * 10)
+			}
+
+			// The first event is taken from the queue, then blocks the stream while
+			// it is written.
+			assert.NoError(t, b.Broadcast([]byte("first")))
+
+			time.AfterFunc(time.Millisecond*100, func() { close(w.unblock) })
+
+			err := b.Broadcast([]byte("second"))
+
+			if tc.ExpectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, b.Stats().Lag["test"].Retried > 0)
+		})
+	}
+}
+
+func TestBroker_EventHandlerKey(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithQueueSize(10))
+	defer brk.Close()
+
+	c := client.NewWithOptions(time.Second, 3, "1234", client.WithQueueSize(10))
+	assert.NoError(t, brk.Subscribe(c))
+
+	for _, price := range []string{"100", "101", "102"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/publish?key=price:AAPL", strings.NewReader(price))
+
+		brk.EventHandler(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// Only the latest price is still queued for the client.
+	pending := c.Pending(true)
+
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "102", string(pending[0].Data))
+	}
+
+	assert.Equal(t, uint64(2), c.Lag().Replaced)
+}
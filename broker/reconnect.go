@@ -0,0 +1,120 @@
+package broker
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The ReconnectHint type tells clients where & when to reconnect when the broker is closing or
+	// moving them to another broker, such as during a rolling deploy. It is sent as the data of an
+	// event with the 'sse:reconnect' type, encoded as JSON with the delay in milliseconds. The stream
+	// ends once the event has been written.
+	ReconnectHint struct {
+		URL   string        // Where the client should reconnect. If blank, the client reconnects to the same URL.
+		Delay time.Duration // How long the client should wait before reconnecting.
+	}
+
+	// The jsonReconnectHint type is the JSON representation of a reconnect hint.
+	jsonReconnectHint struct {
+		URL   string `json:"url,omitempty"`
+		Delay int64  `json:"delay"`
+	}
+)
+
+const (
+	// ReconnectEventType is the type of the event that tells a client where & when to reconnect,
+	// see the ReconnectHint type.
+	ReconnectEventType = "sse:reconnect"
+)
+
+// WithShutdownHint configures the broker to send each connected client an 'sse:reconnect' event
+// containing the hint when the broker is closed, before its stream ends. This allows clients to
+// reconnect to another instance, or to wait for a new one to start, rather than reconnecting
+// immediately to a broker that is going away. The delay is also sent in the 'retry' field, so that
+// browsers wait for it before reconnecting on their own. If 'hint' is nil, no event is sent.
+func WithShutdownHint(hint *ReconnectHint) Option {
+	return func(b *defaultBroker) {
+		b.shutdownHint = hint
+	}
+}
+
+// Reconnect sends an 'sse:reconnect' event containing the hint to the connected clients with the
+// given ids, or to all connected clients if no ids are given, ending their streams once it has been
+// written. This allows clients to be moved to other brokers when rebalancing connections. If any of
+// the clients cannot be sent the event, the first error is returned.
+func (b *defaultBroker) Reconnect(hint ReconnectHint, ids ...string) error {
+	e := hint.Event()
+	e.Timestamp = b.clock.Now()
+
+	if len(ids) == 0 {
+		b.clients.Range(func(key, value interface{}) bool {
+			ids = append(ids, key.(string))
+			return true
+		})
+	}
+
+	var first error
+
+	for _, id := range ids {
+		if err := b.sendLocal(id, e); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// ParseReconnectHint returns the hint contained in an 'sse:reconnect' event. If the event is not
+// a reconnect event or its data is malformed, false is returned.
+func ParseReconnectHint(e event.Event) (ReconnectHint, bool) {
+	if e.Type != ReconnectEventType {
+		return ReconnectHint{}, false
+	}
+
+	var hint jsonReconnectHint
+
+	if err := json.Unmarshal(e.Data, &hint); err != nil {
+		return ReconnectHint{}, false
+	}
+
+	return ReconnectHint{URL: hint.URL, Delay: time.Duration(hint.Delay) * time.Millisecond}, true
+}
+
+// Event returns the 'sse:reconnect' event containing the hint.
+func (h ReconnectHint) Event() event.Event {
+	data, _ := json.Marshal(jsonReconnectHint{URL: h.URL, Delay: h.Delay.Milliseconds()})
+
+	return event.Event{Type: ReconnectEventType, Data: data}
+}
+
+// writeReconnect writes the reconnect event to the client's stream, preceded by its delay in
+// the 'retry' field.
+func (b *defaultBroker) writeReconnect(enc FrameWriter, c *client.Client, e event.Event) {
+	if hint, ok := ParseReconnectHint(e); ok && hint.Delay > 0 {
+		enc.Retry(hint.Delay)
+	}
+
+	b.write(enc, c, e)
+}
+
+// reconnectOnClose writes the broker's shutdown hint to the client's stream if the broker has
+// been closed.
+func (b *defaultBroker) reconnectOnClose(enc FrameWriter, c *client.Client, flush func()) {
+	if b.shutdownHint == nil {
+		return
+	}
+
+	select {
+	case <-b.closed:
+		e := b.shutdownHint.Event()
+		e.Timestamp = b.clock.Now()
+
+		b.writeReconnect(enc, c, e)
+		flush()
+	default:
+	}
+}
@@ -0,0 +1,100 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Reconnect(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil)
+	defer brk.Close()
+
+	w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+	ended := make(chan struct{})
+
+	go func() {
+		brk.ClientHandler(w, httptest.NewRequest("GET", "/connect?id=test", nil))
+		close(ended)
+	}()
+
+	<-time.After(time.Millisecond * 100)
+
+	hint := broker.ReconnectHint{URL: "https://other.example.com/connect", Delay: time.Second * 2}
+	assert.NoError(t, brk.Reconnect(hint, "test"))
+	assert.Error(t, brk.Reconnect(hint, "unknown"))
+
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not end after the reconnect event")
+	}
+
+	expected := "retry: 2000\n\nevent: sse:reconnect\ndata: {\"url\":\"https://other.example.com/connect\",\"delay\":2000}\n\n" +
+		"event: sse:close\ndata: {\"reason\":\"reconnect\"}\n\n"
+	assert.Equal(t, expected, w.String())
+	assert.Equal(t, 0, brk.Stats().Clients)
+}
+
+func TestBroker_WithShutdownHint(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithShutdownHint(&broker.ReconnectHint{Delay: time.Millisecond * 500}))
+
+	w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+	ended := make(chan struct{})
+
+	go func() {
+		brk.ClientHandler(w, httptest.NewRequest("GET", "/connect", nil))
+		close(ended)
+	}()
+
+	<-time.After(time.Millisecond * 100)
+	assert.NoError(t, brk.Close())
+
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not end when the broker closed")
+	}
+
+	expected := "retry: 500\n\nevent: sse:reconnect\ndata: {\"delay\":500}\n\n" +
+		"event: sse:close\ndata: {\"reason\":\"shutdown\"}\n\n"
+	assert.Equal(t, expected, w.String())
+}
+
+func TestParseReconnectHint(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Event    event.Event
+		Expected broker.ReconnectHint
+		OK       bool
+	}{
+		{
+			Name:     "It should parse a reconnect event",
+			Event:    broker.ReconnectHint{URL: "https://example.com", Delay: time.Second}.Event(),
+			Expected: broker.ReconnectHint{URL: "https://example.com", Delay: time.Second},
+			OK:       true,
+		},
+		{
+			Name:  "It should ignore other events",
+			Event: event.Event{Type: "message", Data: []byte(`{"delay":1000}`)},
+		},
+		{
+			Name:  "It should ignore malformed data",
+			Event: event.Event{Type: broker.ReconnectEventType, Data: []byte("soon")},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			hint, ok := broker.ParseReconnectHint(tc.Event)
+
+			assert.Equal(t, tc.OK, ok)
+			assert.Equal(t, tc.Expected, hint)
+		})
+	}
+}
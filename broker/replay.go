@@ -0,0 +1,163 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/store"
+)
+
+// WithStore configures the broker to persist broadcast events in the given store. Clients
+// that reconnect & provide the identifier of the last event they received have the events
+// they missed replayed before receiving live events. Events sent to individual clients are
+// not stored. If 's' is nil, events are not stored.
+func WithStore(s store.Store) Option {
+	return func(b *defaultBroker) {
+		b.store = s
+	}
+}
+
+// WithReplayTTL configures the broker to skip stored events older than the given duration
+// when replaying events to reconnecting clients, so that clients which were disconnected
+// for a long time do not receive a flood of stale events. Events with their own expiry time
+// are skipped once it has passed, regardless of this option. If 'ttl' is zero, events are
+// replayed regardless of their age.
+func WithReplayTTL(ttl time.Duration) Option {
+	return func(b *defaultBroker) {
+		b.replayTTL = ttl
+	}
+}
+
+// lastEventID returns the identifier of the last event received by the client making the
+// request. Browsers send this in the Last-Event-ID header when reconnecting, but some
+// EventSource polyfills can only provide it using the 'lastEventId' query parameter. The
+// header takes precedence, as browsers update it on each reconnection while the query
+// parameter may still hold the value from when the stream was first opened.
+func lastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+
+	return r.URL.Query().Get("lastEventId")
+}
+
+// replaySince returns the time given by the 'since' query parameter, for clients that track
+// the time of the last event they received rather than its identifier. A zero time is returned
+// if the parameter is not set.
+func replaySince(r *http.Request) (time.Time, error) {
+	// A '+' in the timezone offset is decoded as a space when it has not been
+	// escaped, so put it back.
+	since := strings.Replace(r.URL.Query().Get("since"), " ", "+", -1)
+
+	if since == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, since)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("since must be an RFC 3339 timestamp, got %q", since)
+	}
+
+	return t, nil
+}
+
+// replay writes the stored events that the client has missed to 'w' and returns the set
+// of event identifiers that were written. If the request does not provide the last event
+// the client received, events newer than the time given by the 'since' query parameter are
+// written. Otherwise, if the client has a fixed identifier, the offset recorded for the
+// client is used instead.
+func (b *defaultBroker) replay(enc FrameWriter, r *http.Request, c *client.Client, sticky bool) map[string]struct{} {
+	if b.store == nil {
+		return nil
+	}
+
+	id := lastEventID(r)
+
+	// The event identifier takes precedence, as browsers send it when they reconnect
+	// to the same URL.
+	var since time.Time
+
+	if id == "" {
+		since, _ = replaySince(r)
+	}
+
+	if offsets, ok := b.store.(store.OffsetStore); ok && id == "" && since.IsZero() && sticky {
+		id, _ = offsets.Offset(c.ID())
+	}
+
+	if id == "" && since.IsZero() {
+		return nil
+	}
+
+	events, err := b.missed(id)
+
+	if err != nil {
+		return nil
+	}
+
+	now := b.clock.Now()
+	replayed := make(map[string]struct{}, len(events))
+
+	for _, e := range events {
+		if !since.IsZero() && !e.Timestamp.After(since) {
+			continue
+		}
+
+		if !e.Matches(c.Topics()) || !inAudience(e, c) || !inGroup(e, c) || e.Excludes(c.ID()) || b.stale(e, now) {
+			continue
+		}
+
+		b.write(enc, c, e)
+		b.acknowledge(c, e)
+		replayed[e.ID] = struct{}{}
+	}
+
+	return replayed
+}
+
+// missed returns the stored events after the one with the given identifier. If the client's
+// connection carried several logical streams, the identifier holds the cursor of each stream &
+// the events missed on every stream are returned, see the broker.Stream method.
+func (b *defaultBroker) missed(id string) ([]event.Event, error) {
+	if cursors, ok := streamCursors(id); ok {
+		return b.streamEvents(cursors)
+	}
+
+	return b.store.Since(id)
+}
+
+// acknowledge records that the event has been delivered to the client, if the broker's
+// store supports recording client offsets.
+func (b *defaultBroker) acknowledge(c *client.Client, e event.Event) {
+	if e.ID == "" {
+		return
+	}
+
+	if offsets, ok := b.store.(store.OffsetStore); ok {
+		offsets.SetOffset(c.ID(), e.ID)
+	}
+}
+
+// WithTakeover configures the broker to allow a client connecting with the identifier of
+// an already connected client to take over from the existing connection, which is ended.
+// This allows a client to reconnect before the broker has noticed that its previous
+// connection was lost. Otherwise, such connections are rejected.
+func WithTakeover(enabled bool) Option {
+	return func(b *defaultBroker) {
+		b.takeover = enabled
+	}
+}
+
+// stale determines if the event is too old to be replayed.
+func (b *defaultBroker) stale(e event.Event, now time.Time) bool {
+	if e.Expired(now) {
+		return true
+	}
+
+	return b.replayTTL > 0 && now.Sub(e.Timestamp) > b.replayTTL
+}
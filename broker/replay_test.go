@@ -0,0 +1,221 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Replay(t *testing.T) {
+	tt := []struct {
+		Query          string
+		Header         string
+		ExpectedOutput string
+	}{
+		{
+			Query:          "",
+			ExpectedOutput: "",
+		},
+		{
+			Header:         "1",
+			ExpectedOutput: "id: 2\ndata: b\n\nid: 3\ndata: c\n\n",
+		},
+		{
+			Query:          "?lastEventId=2",
+			ExpectedOutput: "id: 3\ndata: c\n\n",
+		},
+		{
+			Query:          "?lastEventId=1",
+			Header:         "2",
+			ExpectedOutput: "id: 3\ndata: c\n\n",
+		},
+		{
+			Query:          "?lastEventId=1&topic=a",
+			ExpectedOutput: "id: 2\ndata: b\n\nid: 3\ndata: c\n\nid: 4\ndata: d\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil, broker.WithStore(store.NewMemory(10)))
+
+		// Broadcast events before the client connects.
+		assert.NoError(t, broker.BroadcastEvent(event.Event{ID: "1", Data: []byte("a")}))
+		assert.NoError(t, broker.BroadcastEvent(event.Event{ID: "2", Data: []byte("b")}))
+		assert.NoError(t, broker.BroadcastEvent(event.Event{ID: "3", Data: []byte("c")}))
+		assert.NoError(t, broker.BroadcastEvent(event.Event{ID: "4", Topic: "a", Data: []byte("d")}))
+
+		w := &FlushRecorder{header: http.Header{}}
+		r := httptest.NewRequest("GET", "/connect"+tc.Query, nil)
+
+		if tc.Header != "" {
+			r.Header.Set("Last-Event-ID", tc.Header)
+		}
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, r)
+		<-time.Tick(time.Second)
+
+		assert.Equal(t, tc.ExpectedOutput, w.String())
+		broker.Close()
+	}
+}
+
+func TestBroker_WithReplayTTL(t *testing.T) {
+	now := time.Now()
+
+	tt := []struct {
+		TTL            time.Duration
+		Events         []event.Event
+		ExpectedOutput string
+	}{
+		{
+			Events: []event.Event{
+				{ID: "1", Data: []byte("a")},
+				{ID: "2", Data: []byte("b"), Timestamp: now.Add(-time.Hour)},
+				{ID: "3", Data: []byte("c"), Expires: now.Add(-time.Minute)},
+				{ID: "4", Data: []byte("d"), Expires: now.Add(time.Minute)},
+			},
+			ExpectedOutput: "id: 2\ndata: b\n\nid: 4\ndata: d\n\n",
+		},
+		{
+			TTL: time.Minute,
+			Events: []event.Event{
+				{ID: "1", Data: []byte("a")},
+				{ID: "2", Data: []byte("b"), Timestamp: now.Add(-time.Hour)},
+				{ID: "3", Data: []byte("c")},
+			},
+			ExpectedOutput: "id: 3\ndata: c\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil,
+			broker.WithStore(store.NewMemory(10)),
+			broker.WithReplayTTL(tc.TTL),
+		)
+
+		for _, e := range tc.Events {
+			assert.NoError(t, broker.BroadcastEvent(e))
+		}
+
+		w := &FlushRecorder{header: http.Header{}}
+		r := httptest.NewRequest("GET", "/connect?lastEventId=1", nil)
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, r)
+		<-time.Tick(time.Second)
+
+		assert.Equal(t, tc.ExpectedOutput, w.String())
+		broker.Close()
+	}
+}
+
+func TestBroker_BroadcastEvent(t *testing.T) {
+	tt := []struct {
+		Event          event.Event
+		ExpectedOutput string
+	}{
+		{
+			Event:          event.Event{ID: "1", Type: "greeting", Data: []byte("hello")},
+			ExpectedOutput: "id: 1\nevent: greeting\ndata: hello\n\n",
+		},
+		{
+			Event:          event.Event{ID: "1\n2", Type: "greet\ning", Data: []byte("hello")},
+			ExpectedOutput: "id: 12\nevent: greeting\ndata: hello\n\n",
+		},
+		{
+			Event:          event.Event{Data: []byte("hello")},
+			ExpectedOutput: "data: hello\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil)
+		w := &FlushRecorder{header: http.Header{}}
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, httptest.NewRequest("GET", "/connect", nil))
+		<-time.Tick(time.Second)
+
+		assert.NoError(t, broker.BroadcastEvent(tc.Event))
+		<-time.After(time.Millisecond * 100)
+
+		assert.Equal(t, tc.ExpectedOutput, w.String())
+		broker.Close()
+	}
+}
+
+func TestBroker_ReplaySince(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		Name           string
+		Query          string
+		Header         string
+		ExpectedOutput string
+	}{
+		{
+			Name:           "It should replay events newer than the timestamp",
+			Query:          "?since=2020-01-01T12:01:00Z",
+			ExpectedOutput: "id: 3\ndata: c\n\n",
+		},
+		{
+			Name:           "It should accept timestamps with an unescaped offset",
+			Query:          "?since=2020-01-01T13:00:30+01:00",
+			ExpectedOutput: "id: 2\ndata: b\n\nid: 3\ndata: c\n\n",
+		},
+		{
+			Name:           "It should prefer the last event identifier",
+			Query:          "?since=2020-01-01T12:01:00Z",
+			Header:         "1",
+			ExpectedOutput: "id: 2\ndata: b\n\nid: 3\ndata: c\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, broker.WithStore(store.NewMemory(10)))
+			defer brk.Close()
+
+			for i, data := range []string{"a", "b", "c"} {
+				e := event.Event{ID: strconv.Itoa(i + 1), Data: []byte(data), Timestamp: start.Add(time.Minute * time.Duration(i))}
+				assert.NoError(t, brk.BroadcastEvent(e))
+			}
+
+			w := &FlushRecorder{header: http.Header{}}
+			r := httptest.NewRequest("GET", "/connect"+tc.Query, nil)
+
+			if tc.Header != "" {
+				r.Header.Set("Last-Event-ID", tc.Header)
+			}
+
+			go brk.ClientHandler(w, r)
+			<-time.After(time.Millisecond * 500)
+
+			assert.Equal(t, tc.ExpectedOutput, w.String())
+		})
+	}
+}
+
+func TestBroker_ReplaySinceInvalid(t *testing.T) {
+	var code broker.ErrorCode
+
+	brk := broker.New(time.Second, 3, func(w http.ResponseWriter, r *http.Request, err error) {
+		code = err.(*broker.Error).Code
+	}, broker.WithStore(store.NewMemory(10)))
+	defer brk.Close()
+
+	brk.ClientHandler(&TestRecorder{header: http.Header{}}, httptest.NewRequest("GET", "/connect?since=yesterday", nil))
+
+	assert.Equal(t, broker.CodeInvalidReplay, code)
+}
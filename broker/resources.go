@@ -0,0 +1,173 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/davidsbond/sse/clock"
+)
+
+type (
+	// The Resources type counts the goroutines & timers the broker has started to serve client
+	// connections that have not yet been released. Once every client has disconnected, each count
+	// should return to zero, so a count that keeps growing during a soak test indicates a leak.
+	Resources struct {
+		Connections int64 // The number of streams being written by the ClientHandler.
+		Goroutines  int64 // The number of goroutines started for those streams & their clients, such as to register them with the cluster.
+		Timers      int64 // The number of timers & tickers started for those streams.
+	}
+
+	// The accounting type holds the broker-wide resource counts.
+	accounting struct {
+		connections int64
+		goroutines  int64
+		timers      int64
+	}
+
+	// The connResources type counts the resources started for a single connection, so that
+	// they can be checked once the connection has ended.
+	connResources struct {
+		parent     *accounting
+		goroutines int64
+		timers     int64
+		wg         sync.WaitGroup
+	}
+)
+
+// ErrResourceLeak is the error reported when a connection ends without releasing every goroutine &
+// timer started for it, see the broker.WithResourceChecks method.
+var ErrResourceLeak = errors.New("connection leaked resources")
+
+// WithResourceChecks configures the broker to check that every goroutine & timer started for a
+// client connection has been released once the connection ends, calling 'report' with an error
+// wrapping ErrResourceLeak for each connection that has not. Goroutines are given the broker's
+// timeout to exit. This is intended for tests, such as passing a function that calls t.Error, as
+// checking delays the end of each connection. If 'report' is nil, connections are not checked.
+func WithResourceChecks(report func(err error)) Option {
+	return func(b *defaultBroker) {
+		b.leakReport = report
+	}
+}
+
+// stats returns the current resource counts.
+func (a *accounting) stats() Resources {
+	return Resources{
+		Connections: atomic.LoadInt64(&a.connections),
+		Goroutines:  atomic.LoadInt64(&a.goroutines),
+		Timers:      atomic.LoadInt64(&a.timers),
+	}
+}
+
+// open starts counting the resources of a new connection.
+func (a *accounting) open() *connResources {
+	atomic.AddInt64(&a.connections, 1)
+
+	return &connResources{parent: a}
+}
+
+// releaseResources stops counting the connection. If resource checks are enabled, the connection's
+// goroutines are given the broker's timeout to exit, & any resources it still holds are reported.
+func (b *defaultBroker) releaseResources(res *connResources) {
+	defer atomic.AddInt64(&res.parent.connections, -1)
+
+	if b.leakReport == nil {
+		return
+	}
+
+	if err := res.wait(b.timeout, b.clock); err != nil {
+		b.leakReport(err)
+	}
+}
+
+// goroutine runs the function on a new goroutine that serves the broker's clients rather than a
+// single connection, counting it until it returns.
+func (a *accounting) goroutine(fn func()) {
+	atomic.AddInt64(&a.goroutines, 1)
+
+	go func() {
+		defer atomic.AddInt64(&a.goroutines, -1)
+
+		fn()
+	}()
+}
+
+// goroutine runs the function on a new goroutine, counting it until it returns.
+func (r *connResources) goroutine(fn func()) {
+	r.add(&r.goroutines, &r.parent.goroutines, 1)
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+		defer r.add(&r.goroutines, &r.parent.goroutines, -1)
+
+		fn()
+	}()
+}
+
+// timer counts a timer or ticker given as its channel & the function that stops it, returning
+// them so that stopping the timer also stops counting it. Nil channels, which never receive,
+// are not counted.
+func (r *connResources) timer(c <-chan time.Time, stop func()) (<-chan time.Time, func()) {
+	if c == nil {
+		return c, stop
+	}
+
+	r.timerStarted()
+
+	var once sync.Once
+
+	return c, func() {
+		stop()
+		once.Do(r.timerStopped)
+	}
+}
+
+// timerStarted & timerStopped count timers whose lifetime is managed elsewhere. They do nothing
+// if called on a nil value, so that types can be used without accounting.
+func (r *connResources) timerStarted() {
+	if r != nil {
+		r.add(&r.timers, &r.parent.timers, 1)
+	}
+}
+
+func (r *connResources) timerStopped() {
+	if r != nil {
+		r.add(&r.timers, &r.parent.timers, -1)
+	}
+}
+
+func (r *connResources) add(local, global *int64, delta int64) {
+	atomic.AddInt64(local, delta)
+	atomic.AddInt64(global, delta)
+}
+
+// wait waits up to 'timeout', measured by the clock, for the connection's goroutines to exit,
+// returning an error if it still holds any goroutines or timers.
+func (r *connResources) wait(timeout time.Duration, clk clock.Clock) error {
+	done := make(chan struct{})
+
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	deadline := clk.NewTimer(timeout)
+	defer deadline.Stop()
+
+	select {
+	case <-done:
+	case <-deadline.C():
+	}
+
+	goroutines := atomic.LoadInt64(&r.goroutines)
+	timers := atomic.LoadInt64(&r.timers)
+
+	if goroutines != 0 || timers != 0 {
+		return fmt.Errorf("%w: %v goroutines & %v timers still running", ErrResourceLeak, goroutines, timers)
+	}
+
+	return nil
+}
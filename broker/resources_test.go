@@ -0,0 +1,85 @@
+package broker_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithResourceChecks(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Options []broker.Option
+		End     func(rec *ssetest.StreamRecorder)
+	}{
+		{
+			// The client has not disconnected, so the goroutine listening for it to do
+			// so must stop when the stream ends.
+			Name:    "It should release resources when the stream reaches its maximum age",
+			Options: []broker.Option{broker.WithMaxConnectionAge(time.Millisecond * 200)},
+		},
+		{
+			Name:    "It should release resources when the client disconnects",
+			Options: []broker.Option{broker.WithPolyfillSupport(true), broker.WithCoalesceWindow(time.Millisecond)},
+			End:     func(rec *ssetest.StreamRecorder) { rec.Close() },
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var (
+				mux  sync.Mutex
+				errs []error
+			)
+
+			opts := append(tc.Options, broker.WithResourceChecks(func(err error) {
+				mux.Lock()
+				errs = append(errs, err)
+				mux.Unlock()
+			}))
+
+			b := broker.New(time.Millisecond*100, 3, nil, opts...)
+			defer b.Close()
+
+			rec := ssetest.NewStreamRecorder()
+			done := make(chan struct{})
+
+			go func() {
+				b.ClientHandler(rec, rec.NewRequest(http.MethodGet, "/", nil))
+				close(done)
+			}()
+
+			for b.Stats().Clients == 0 {
+				<-time.After(time.Millisecond * 10)
+			}
+
+			resources := b.Stats().Resources
+			assert.Equal(t, int64(1), resources.Connections)
+			assert.Equal(t, int64(1), resources.Goroutines)
+			assert.True(t, resources.Timers > 0)
+
+			assert.NoError(t, b.Broadcast([]byte("hello")))
+
+			if tc.End != nil {
+				tc.End(rec)
+			}
+
+			select {
+			case <-done:
+			case <-time.After(time.Second * 2):
+				t.Fatal("timed out waiting for the stream to end")
+			}
+
+			mux.Lock()
+			assert.Empty(t, errs)
+			mux.Unlock()
+
+			assert.Equal(t, broker.Resources{}, b.Stats().Resources)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package broker_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// openStream connects to the SSE endpoint at the given URL. The response headers are
+// not sent until the first event is written, so the response is returned on a channel.
+func openStream(t *testing.T, url, lastEventID string) <-chan *http.Response {
+	out := make(chan *http.Response, 1)
+
+	go func() {
+		req, _ := http.NewRequest("GET", url, nil)
+
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		out <- resp
+	}()
+
+	return out
+}
+
+// readEvent reads a single event from the stream, returning its fields without the
+// terminating blank line.
+func readEvent(r *bufio.Reader) (string, error) {
+	var lines []string
+
+	for {
+		line, err := r.ReadString('\n')
+
+		if err != nil {
+			return "", err
+		}
+
+		if line == "\n" {
+			return strings.Join(lines, ""), nil
+		}
+
+		lines = append(lines, line)
+	}
+}
+
+func TestBroker_RollingDeploy(t *testing.T) {
+	s := store.NewMemory(100)
+
+	// Start the broker that will be replaced.
+	old := broker.New(time.Second, 3, nil, broker.WithStore(s), broker.WithTakeover(true))
+	oldServer := httptest.NewServer(http.HandlerFunc(old.ClientHandler))
+
+	responses := openStream(t, oldServer.URL+"?id=browser", "")
+	<-time.Tick(time.Second)
+
+	assert.NoError(t, old.BroadcastEvent(event.Event{ID: "1", Data: []byte("a")}))
+	assert.NoError(t, old.BroadcastEvent(event.Event{ID: "2", Data: []byte("b")}))
+
+	resp := <-responses
+	stream := bufio.NewReader(resp.Body)
+
+	for _, expected := range []string{"id: 1\ndata: a\n", "id: 2\ndata: b\n"} {
+		e, err := readEvent(stream)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, e)
+	}
+
+	// Shut down the old broker, and start a new one using the same store. An event
+	// is broadcast before the client has reconnected.
+	old.Close()
+	resp.Body.Close()
+	oldServer.Close()
+
+	replacement := broker.New(time.Second, 3, nil, broker.WithStore(s), broker.WithTakeover(true))
+	server := httptest.NewServer(http.HandlerFunc(replacement.ClientHandler))
+	defer server.Close()
+	defer replacement.Close()
+
+	assert.NoError(t, replacement.BroadcastEvent(event.Event{ID: "3", Data: []byte("c")}))
+
+	// Reconnect without the last event id, the client's offset should be used to
+	// replay the event it missed before live events are sent.
+	responses = openStream(t, server.URL+"?id=browser", "")
+	<-time.Tick(time.Second)
+
+	assert.NoError(t, replacement.BroadcastEvent(event.Event{ID: "4", Data: []byte("d")}))
+
+	resp = <-responses
+	stream = bufio.NewReader(resp.Body)
+
+	for _, expected := range []string{"id: 3\ndata: c\n", "id: 4\ndata: d\n"} {
+		e, err := readEvent(stream)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, e)
+	}
+
+	// Connecting again with the same id should take over from the existing
+	// connection, which is told why it was ended.
+	responses = openStream(t, server.URL+"?id=browser", "4")
+	<-time.Tick(time.Second)
+
+	assert.NoError(t, replacement.BroadcastEvent(event.Event{ID: "5", Data: []byte("e")}))
+
+	e, err := readEvent(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, "event: sse:close\ndata: {\"reason\":\"replaced\"}\n", e)
+
+	_, err = readEvent(stream)
+	assert.Error(t, err)
+	resp.Body.Close()
+
+	resp = <-responses
+	defer resp.Body.Close()
+
+	e, err = readEvent(bufio.NewReader(resp.Body))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "id: 5\ndata: e\n", e)
+}
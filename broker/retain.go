@@ -0,0 +1,112 @@
+package broker
+
+import (
+	"sync"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The retainer type holds the retained event of each topic, which is delivered to clients
+	// when they subscribe to the topic.
+	retainer struct {
+		mux    sync.Mutex
+		always map[string]bool
+		topics map[string]*retainedTopic
+	}
+
+	// The retainedTopic type holds the retained event of a single topic. Its lock is held while
+	// retained events are broadcast to the topic, so that subscribing clients are not sent a
+	// retained event older than the live events they receive.
+	retainedTopic struct {
+		mux   sync.Mutex
+		event event.Event
+	}
+)
+
+// WithRetainedTopics configures the broker to retain the most recent event broadcast to each of
+// the given topics. When a client subscribes to one of the topics, either when it connects or by
+// changing its subscriptions, it is sent the retained event before any live events, in the same way
+// as MQTT's retained messages. Events broadcast to other topics are retained if their Retain field
+// is set. Broadcasting a retained event without data clears the topic's retained event. Retained
+// events are only sent to clients within their audience & group, and not to clients they exclude.
+func WithRetainedTopics(topics ...string) Option {
+	return func(b *defaultBroker) {
+		b.retained.always = make(map[string]bool, len(topics))
+
+		for _, topic := range topics {
+			b.retained.always[topic] = true
+		}
+	}
+}
+
+// topic returns the topic whose retained event should be replaced by the event, or nil if the
+// event should not be retained.
+func (r *retainer) topic(e event.Event) *retainedTopic {
+	if e.Topic == "" || (!e.Retain && !r.always[e.Topic]) {
+		return nil
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.topics == nil {
+		r.topics = make(map[string]*retainedTopic)
+	}
+
+	t, ok := r.topics[e.Topic]
+
+	if !ok {
+		t = &retainedTopic{}
+		r.topics[e.Topic] = t
+	}
+
+	return t
+}
+
+// retain replaces the topic's retained event, or clears it if the event has no data. It must be
+// called while holding the topic's lock.
+func (t *retainedTopic) retain(e event.Event) {
+	if len(e.Data) == 0 {
+		t.event = event.Event{}
+		return
+	}
+
+	// Copy the data so callers can reuse their buffers.
+	t.event = e
+	t.event.Data = append([]byte{}, e.Data...)
+}
+
+// subscribeRetained sends the retained event of each of the topics to the client, if they have
+// one. Events are written on their own goroutines, as each topic stays locked while a retained
+// event is broadcast to it.
+func (b *defaultBroker) subscribeRetained(c *client.Client, topics []string) {
+	b.retained.mux.Lock()
+	defer b.retained.mux.Unlock()
+
+	for _, topic := range topics {
+		if t, ok := b.retained.topics[topic]; ok {
+			go b.writeRetained(c, t)
+		}
+	}
+}
+
+// writeRetained writes the topic's retained event to the client, if it has one & the client
+// is allowed to receive it.
+func (b *defaultBroker) writeRetained(c *client.Client, t *retainedTopic) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	e := t.event
+
+	if e.Data == nil || !inAudience(e, c) || !inGroup(e, c) || e.Excludes(c.ID()) {
+		return
+	}
+
+	for _, chunk := range b.chunk(nil, e) {
+		if err := c.WriteEvent(chunk); err != nil {
+			return
+		}
+	}
+}
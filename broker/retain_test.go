@@ -0,0 +1,115 @@
+package broker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithRetainedTopics(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Topic    string
+		Events   []event.Event
+		Metadata map[string]string
+		Expected string
+	}{
+		{
+			Name:  "It should send the latest event of a retained topic",
+			Topic: "status",
+			Events: []event.Event{
+				{Topic: "status", Data: []byte("starting")},
+				{Topic: "status", Data: []byte("ready")},
+			},
+			Expected: "ready",
+		},
+		{
+			Name:  "It should send events flagged as retained",
+			Topic: "price",
+			Events: []event.Event{
+				{Topic: "price", Data: []byte("100"), Retain: true},
+				{Topic: "price", Data: []byte("101")},
+			},
+			Expected: "100",
+		},
+		{
+			Name:  "It should clear the retained event when broadcast without data",
+			Topic: "status",
+			Events: []event.Event{
+				{Topic: "status", Data: []byte("ready")},
+				{Topic: "status"},
+			},
+		},
+		{
+			Name:  "It should not send events outside the client's audience",
+			Topic: "status",
+			Events: []event.Event{
+				{Topic: "status", Data: []byte("ready"), Audience: "role=admin"},
+			},
+			Metadata: map[string]string{"role": "user"},
+		},
+		{
+			Name:  "It should not retain other topics",
+			Topic: "news",
+			Events: []event.Event{
+				{Topic: "news", Data: []byte("hello")},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, broker.WithRetainedTopics("status"))
+			defer brk.Close()
+
+			for _, e := range tc.Events {
+				assert.NoError(t, brk.BroadcastEvent(e))
+			}
+
+			c := client.NewWithOptions(time.Second, 3, "", client.WithTopics(tc.Topic), client.WithQueueSize(10), client.WithMetadata(tc.Metadata))
+			assert.NoError(t, brk.Subscribe(c))
+
+			select {
+			case <-c.Ready():
+			case <-time.After(time.Millisecond * 100):
+			}
+
+			e, ok := c.Next()
+
+			if tc.Expected == "" {
+				assert.False(t, ok)
+				return
+			}
+
+			assert.True(t, ok)
+			assert.Equal(t, tc.Expected, string(e.Data))
+		})
+	}
+}
+
+func TestBroker_WithRetainedTopicsUpdateSubscriptions(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithRetainedTopics("status"))
+	defer brk.Close()
+
+	assert.NoError(t, brk.BroadcastTopic("status", []byte("ready")))
+
+	c := client.NewWithOptions(time.Second, 3, "test", client.WithQueueSize(10))
+	assert.NoError(t, brk.Subscribe(c))
+
+	_, err := brk.UpdateSubscriptions("test", broker.SubscriptionChange{Subscribe: []string{"status"}})
+	assert.NoError(t, err)
+
+	select {
+	case <-c.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("retained event was not written to the client")
+	}
+
+	e, ok := c.Next()
+	assert.True(t, ok)
+	assert.Equal(t, "ready", string(e.Data))
+}
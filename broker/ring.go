@@ -0,0 +1,63 @@
+package broker
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+type (
+	// The hashRing type assigns keys to members using consistent hashing, so that adding or
+	// removing a member only moves the keys assigned to that member.
+	hashRing struct {
+		hashes  []uint32
+		members map[uint32]string
+	}
+)
+
+const (
+	// The number of points each member occupies on the ring, which spreads keys more evenly
+	// between members.
+	ringReplicas = 64
+)
+
+func newHashRing(members []string) *hashRing {
+	ring := &hashRing{members: make(map[uint32]string, len(members)*ringReplicas)}
+
+	for _, member := range members {
+		for i := 0; i < ringReplicas; i++ {
+			hash := ringHash(member + "#" + strconv.Itoa(i))
+			ring.hashes = append(ring.hashes, hash)
+			ring.members[hash] = member
+		}
+	}
+
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+
+	return ring
+}
+
+// owner returns the member that the key is assigned to, or a blank string if the ring has
+// no members.
+func (r *hashRing) owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	hash := ringHash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= hash })
+
+	// Keys beyond the last point wrap around to the first.
+	if i == len(r.hashes) {
+		i = 0
+	}
+
+	return r.members[r.hashes[i]]
+}
+
+func ringHash(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	return h.Sum32()
+}
@@ -0,0 +1,42 @@
+package broker
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	// The reconnection delay sent to clients whose connection has reached its maximum age.
+	rotationRetry = time.Second
+
+	// The maximum fraction of the connection age that is randomly subtracted from
+	// each connection, so that clients which connected together do not all reconnect
+	// at the same moment.
+	rotationJitter = 0.1
+)
+
+// WithMaxConnectionAge configures the broker to gracefully end client streams once they
+// have been open for the given duration. Before the stream ends, the client is sent a
+// 'retry' hint so that it reconnects promptly, allowing long-lived connections to be
+// rebalanced across instances behind a load balancer. Each connection's age is reduced
+// by a small random amount to spread reconnections out. If 'age' is zero, connections
+// are never ended.
+func WithMaxConnectionAge(age time.Duration) Option {
+	return func(b *defaultBroker) {
+		b.maxConnectionAge = age
+	}
+}
+
+// connectionExpiry returns a channel that is signalled once a new connection has reached
+// its maximum age, and a function to release its resources. If connections have no
+// maximum age, the channel is nil.
+func (b *defaultBroker) connectionExpiry() (<-chan time.Time, func()) {
+	if b.maxConnectionAge <= 0 {
+		return nil, func() {}
+	}
+
+	age := b.maxConnectionAge - time.Duration(rand.Float64()*rotationJitter*float64(b.maxConnectionAge))
+	timer := b.clock.NewTimer(age)
+
+	return timer.C(), func() { timer.Stop() }
+}
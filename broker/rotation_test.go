@@ -0,0 +1,45 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithMaxConnectionAge(t *testing.T) {
+	tt := []struct {
+		MaxAge         time.Duration
+		ExpectRotation bool
+	}{
+		{MaxAge: time.Millisecond * 500, ExpectRotation: true},
+		{MaxAge: 0},
+	}
+
+	for _, tc := range tt {
+		broker := broker.New(time.Second, 3, nil, broker.WithMaxConnectionAge(tc.MaxAge))
+		w := &FlushRecorder{header: http.Header{}}
+		r := httptest.NewRequest("GET", "/", nil)
+		done := make(chan struct{})
+
+		go func() {
+			broker.ClientHandler(w, r)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			assert.True(t, tc.ExpectRotation)
+			assert.Equal(t, "retry: 1000\n\nevent: sse:close\ndata: {\"reason\":\"max_age\"}\n\n", w.String())
+			assert.Equal(t, 0, broker.Stats().Clients)
+		case <-time.After(time.Second * 2):
+			assert.False(t, tc.ExpectRotation)
+			assert.Equal(t, 1, broker.Stats().Clients)
+		}
+
+		broker.Close()
+	}
+}
@@ -0,0 +1,259 @@
+package broker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Scheduled interface describes an event that has been scheduled to be broadcast in
+	// the future.
+	Scheduled interface {
+		// Cancel prevents the event from being broadcast. It returns false if the event has
+		// already been broadcast or cancelled.
+		Cancel() bool
+
+		// Done returns a channel that is closed once the event has been broadcast or cancelled.
+		Done() <-chan struct{}
+
+		// Err returns the error from broadcasting the event, or ErrCancelled if the event was
+		// cancelled. It returns nil until the channel returned by Done is closed.
+		Err() error
+	}
+
+	// The scheduledEvent type is an event held in the broker's timer wheel.
+	scheduledEvent struct {
+		event  event.Event
+		rounds int
+
+		mux    sync.Mutex
+		firing bool
+		done   chan struct{}
+		err    error
+	}
+
+	// The timerWheel type holds scheduled events in a fixed number of slots that are visited
+	// in turn, one per tick. Events scheduled further ahead than a full turn of the wheel wait
+	// for the number of rounds required. This keeps scheduling & cancellation cheap regardless
+	// of how many events are waiting.
+	timerWheel struct {
+		mux      sync.Mutex
+		slots    [][]*scheduledEvent
+		position int
+		visited  int
+		started  time.Time
+		clock    clock.Clock
+		fire     func(event.Event) error
+		done     chan struct{}
+		once     sync.Once
+		closed   bool
+	}
+)
+
+const (
+	// The duration of a single tick of the timer wheel, which is the precision that scheduled
+	// events are broadcast with.
+	wheelTick = time.Millisecond * 10
+
+	// The number of slots in the timer wheel.
+	wheelSlots = 512
+)
+
+var (
+	// ErrCancelled is the error returned by Scheduled.Err when the event was cancelled before it
+	// was broadcast, including when the broker was closed.
+	ErrCancelled = errors.New("scheduled event was cancelled")
+)
+
+// BroadcastAt schedules the event to be broadcast at the given time, in the same way as the
+// BroadcastEvent method. If the time has already passed, the event is broadcast as soon as
+// possible. The returned handle can be used to cancel the event or wait for its result.
+func (b *defaultBroker) BroadcastAt(t time.Time, e event.Event) Scheduled {
+	return b.BroadcastAfter(t.Sub(b.clock.Now()), e)
+}
+
+// BroadcastAfter schedules the event to be broadcast once the given duration has elapsed, in
+// the same way as the BroadcastEvent method. The returned handle can be used to cancel the
+// event or wait for its result.
+func (b *defaultBroker) BroadcastAfter(d time.Duration, e event.Event) Scheduled {
+	return b.wheel.schedule(d, e)
+}
+
+func newTimerWheel(fire func(event.Event) error, clk clock.Clock) *timerWheel {
+	return &timerWheel{
+		slots: make([][]*scheduledEvent, wheelSlots),
+		clock: clk,
+		fire:  fire,
+		done:  make(chan struct{}),
+	}
+}
+
+// schedule places the event in the slot that is visited once 'd' has elapsed, starting the
+// wheel if this is the first event to be scheduled.
+func (w *timerWheel) schedule(d time.Duration, e event.Event) Scheduled {
+	s := &scheduledEvent{event: e, done: make(chan struct{})}
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	if w.closed {
+		s.Cancel()
+		return s
+	}
+
+	w.once.Do(func() {
+		w.started = w.clock.Now()
+		go w.run()
+	})
+
+	// Find the first tick at or after the time the event is due, so that
+	// events are never broadcast early.
+	due := clock.Since(w.clock, w.started) + d
+	ticks := int((due+wheelTick-1)/wheelTick) - w.visited
+
+	if ticks < 1 {
+		ticks = 1
+	}
+
+	s.rounds = (ticks - 1) / wheelSlots
+	slot := (w.position + ticks) % wheelSlots
+	w.slots[slot] = append(w.slots[slot], s)
+
+	return s
+}
+
+func (w *timerWheel) run() {
+	ticker := w.clock.NewTicker(wheelTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			for _, s := range w.advance() {
+				if s.claim() {
+					s.complete(w.fire(s.event))
+				}
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// advance visits every slot that is due since the wheel was last advanced, returning the
+// events that should be broadcast in the order they were scheduled. Slots are visited based
+// on the time elapsed, so that slow broadcasts do not cause the wheel to drift.
+func (w *timerWheel) advance() []*scheduledEvent {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	var due []*scheduledEvent
+
+	for target := int(clock.Since(w.clock, w.started) / wheelTick); w.visited < target; w.visited++ {
+		w.position = (w.position + 1) % wheelSlots
+
+		var waiting []*scheduledEvent
+
+		for _, s := range w.slots[w.position] {
+			switch {
+			case s.finished():
+				continue
+			case s.rounds > 0:
+				s.rounds--
+				waiting = append(waiting, s)
+			default:
+				due = append(due, s)
+			}
+		}
+
+		w.slots[w.position] = waiting
+	}
+
+	return due
+}
+
+// close stops the wheel, cancelling any events that have not yet been broadcast.
+func (w *timerWheel) close() {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	w.closed = true
+	close(w.done)
+
+	for i, slot := range w.slots {
+		for _, s := range slot {
+			s.Cancel()
+		}
+
+		w.slots[i] = nil
+	}
+}
+
+// Cancel prevents the event from being broadcast.
+func (s *scheduledEvent) Cancel() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.firing || s.finished() {
+		return false
+	}
+
+	s.err = ErrCancelled
+	close(s.done)
+
+	return true
+}
+
+// Done returns a channel that is closed once the event has been broadcast or cancelled.
+func (s *scheduledEvent) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the outcome of the scheduled event.
+func (s *scheduledEvent) Err() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.err
+}
+
+// claim marks the event as being broadcast so that it can no longer be cancelled, returning
+// false if it has already been cancelled.
+func (s *scheduledEvent) claim() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.finished() {
+		return false
+	}
+
+	s.firing = true
+
+	return true
+}
+
+// complete records the outcome of broadcasting the event.
+func (s *scheduledEvent) complete(err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.err = err
+	close(s.done)
+}
+
+func (s *scheduledEvent) finished() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
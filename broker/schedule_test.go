@@ -0,0 +1,97 @@
+package broker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_BroadcastAfter(t *testing.T) {
+	tt := []struct {
+		Delays         []time.Duration
+		Cancel         []bool
+		ExpectedEvents []string
+	}{
+		{
+			Delays:         []time.Duration{time.Millisecond * 300, time.Millisecond * 100, time.Millisecond * 200},
+			Cancel:         []bool{false, false, false},
+			ExpectedEvents: []string{"1", "2", "0"},
+		},
+		{
+			Delays:         []time.Duration{time.Millisecond * 100, time.Millisecond * 200},
+			Cancel:         []bool{true, false},
+			ExpectedEvents: []string{"1"},
+		},
+		{
+			Delays:         []time.Duration{-time.Second},
+			Cancel:         []bool{false},
+			ExpectedEvents: []string{"0"},
+		},
+	}
+
+	for _, tc := range tt {
+		b := broker.New(time.Second, 3, nil)
+		c := ssetest.NewClient("test", client.WithQueueSize(10))
+
+		assert.NoError(t, b.Subscribe(c.Client))
+
+		var handles []broker.Scheduled
+
+		for i, delay := range tc.Delays {
+			handles = append(handles, b.BroadcastAfter(delay, event.Event{Data: []byte{byte('0' + i)}}))
+		}
+
+		for i, cancel := range tc.Cancel {
+			if cancel {
+				assert.True(t, handles[i].Cancel())
+			}
+		}
+
+		for _, handle := range handles {
+			<-handle.Done()
+		}
+
+		events, err := c.Wait(len(tc.ExpectedEvents), time.Second)
+
+		if assert.NoError(t, err) {
+			for i, e := range events {
+				assert.Equal(t, tc.ExpectedEvents[i], string(e.Data))
+			}
+		}
+
+		for i, cancel := range tc.Cancel {
+			if cancel {
+				assert.Equal(t, broker.ErrCancelled, handles[i].Err())
+			} else {
+				assert.NoError(t, handles[i].Err())
+				assert.False(t, handles[i].Cancel())
+			}
+		}
+
+		c.Close()
+		b.Close()
+	}
+}
+
+func TestBroker_BroadcastAt(t *testing.T) {
+	b := broker.New(time.Second, 3, nil)
+	scheduled := time.Now().Add(time.Millisecond * 200)
+	handle := b.BroadcastAt(scheduled, event.Event{Data: []byte("hello")})
+
+	<-handle.Done()
+
+	assert.False(t, time.Now().Before(scheduled))
+	assert.NoError(t, handle.Err())
+
+	// Closing the broker should cancel any pending events.
+	handle = b.BroadcastAt(time.Now().Add(time.Hour), event.Event{Data: []byte("hello")})
+	b.Close()
+
+	<-handle.Done()
+	assert.Equal(t, broker.ErrCancelled, handle.Err())
+}
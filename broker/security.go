@@ -0,0 +1,145 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/davidsbond/sse/clock"
+)
+
+type (
+	// The SelfCheck type describes how a request reached the broker, along with warnings about
+	// deployment issues that commonly break event streams. See the SelfCheckHandler function.
+	SelfCheck struct {
+		Protocol string   `json:"protocol"` // The protocol the request was made using, such as 'HTTP/2.0'.
+		TLS      bool     `json:"tls"`      // Whether the request was made over TLS, either directly or via a proxy.
+		Proxied  bool     `json:"proxied"`  // Whether the request passed through a proxy.
+		Warnings []string `json:"warnings"` // Issues that may cause events to be buffered or delayed.
+	}
+)
+
+const (
+	// The number of events written by the self-check stream, and the interval between them.
+	selfCheckTicks    = 5
+	selfCheckInterval = time.Millisecond * 250
+)
+
+var (
+	// Headers that are added by proxies & load balancers.
+	proxyHeaders = []string{"Via", "X-Forwarded-For", "X-Forwarded-Host", "X-Real-Ip", "Forwarded", "Cf-Ray"}
+)
+
+// WithSecurityHeaders configures the broker to set security related headers on event streams. Proxies
+// are asked not to buffer (X-Accel-Buffering: no) or transform (Cache-Control: no-transform) the
+// stream, and browsers are asked not to sniff its content type (X-Content-Type-Options: nosniff).
+// Streams served over TLS also set the Strict-Transport-Security header, so that browsers do not
+// connect to the broker over plain HTTP. Use the SelfCheckHandler function to validate a deployment.
+func WithSecurityHeaders(enabled bool) Option {
+	return func(b *defaultBroker) {
+		b.securityHeaders = enabled
+	}
+}
+
+// setSecurityHeaders sets the security related headers for the request, if enabled.
+func (b *defaultBroker) setSecurityHeaders(h http.Header, r *http.Request) {
+	if !b.securityHeaders {
+		return
+	}
+
+	h.Set("Cache-Control", "no-cache, no-transform")
+	h.Set("X-Accel-Buffering", "no")
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	if r.TLS != nil {
+		h.Set("Strict-Transport-Security", "max-age=31536000")
+	}
+}
+
+// SelfCheckHandler is an HTTP handler that reports how requests reach the broker, warning about
+// deployment issues such as proxies that may buffer event streams. Requests that accept
+// 'text/event-stream' receive the report as an event named 'self-check', followed by five events
+// named 'tick' a quarter of a second apart. If the ticks arrive together, something between the
+// client & the broker is buffering the stream. Other requests receive the report as JSON.
+//
+// Example using http (https://golang.org/pkg/net/http/)
+//
+// http.HandleFunc("/self-check", broker.SelfCheckHandler)
+// http.ListenAndServe(":8080")
+func SelfCheckHandler(w http.ResponseWriter, r *http.Request) {
+	NewSelfCheckHandler(clock.Real())(w, r)
+}
+
+// NewSelfCheckHandler returns a handler that behaves in the same way as the SelfCheckHandler
+// function, spacing its 'tick' events using the given clock.
+func NewSelfCheckHandler(clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		selfCheck(w, r, clk)
+	}
+}
+
+// selfCheck writes the report for the request, streaming it if the client accepts event streams.
+func selfCheck(w http.ResponseWriter, r *http.Request, clk clock.Clock) {
+	report := CheckRequest(r)
+	flusher, ok := w.(http.Flusher)
+
+	if !ok || !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache, no-transform")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	data, _ := json.Marshal(report)
+	fmt.Fprintf(w, "event: self-check\ndata: %s\n\n", data)
+	flusher.Flush()
+
+	ticker := clk.NewTicker(selfCheckInterval)
+	defer ticker.Stop()
+
+	for i := 1; i <= selfCheckTicks; i++ {
+		select {
+		case <-r.Context().Done():
+			return
+		case t := <-ticker.C():
+			fmt.Fprintf(w, "event: tick\ndata: %v %v\n\n", i, t.UTC().Format(time.RFC3339Nano))
+			flusher.Flush()
+		}
+	}
+}
+
+// CheckRequest inspects how the request reached the broker, returning warnings about deployment
+// issues that commonly cause event streams to be buffered or delayed.
+func CheckRequest(r *http.Request) SelfCheck {
+	check := SelfCheck{
+		Protocol: r.Proto,
+		TLS:      r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https"),
+		Warnings: []string{},
+	}
+
+	for _, header := range proxyHeaders {
+		if r.Header.Get(header) != "" {
+			check.Proxied = true
+			break
+		}
+	}
+
+	if check.Proxied {
+		check.Warnings = append(check.Warnings, "the request passed through a proxy, make sure it does not buffer responses, such as using 'proxy_buffering off' for nginx")
+	}
+
+	if !check.TLS {
+		check.Warnings = append(check.Warnings, "the request was not made over TLS, proxies & antivirus software are more likely to buffer unencrypted streams")
+	}
+
+	if r.ProtoMajor < 2 {
+		check.Warnings = append(check.Warnings, "the request was made using HTTP/1.x, browsers limit each origin to six HTTP/1.x connections, so serve streams over HTTP/2")
+	}
+
+	return check
+}
@@ -0,0 +1,182 @@
+package broker_test
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithSecurityHeaders(t *testing.T) {
+	tt := []struct {
+		Name            string
+		Enabled         bool
+		TLS             bool
+		ExpectedHeaders map[string]string
+	}{
+		{
+			Name: "It should not set security headers by default",
+			ExpectedHeaders: map[string]string{
+				"Cache-Control":          "no-cache",
+				"X-Content-Type-Options": "",
+			},
+		},
+		{
+			Name:    "It should set security headers when enabled",
+			Enabled: true,
+			ExpectedHeaders: map[string]string{
+				"Cache-Control":             "no-cache, no-transform",
+				"X-Accel-Buffering":         "no",
+				"X-Content-Type-Options":    "nosniff",
+				"Strict-Transport-Security": "",
+			},
+		},
+		{
+			Name:    "It should set strict transport security over TLS",
+			Enabled: true,
+			TLS:     true,
+			ExpectedHeaders: map[string]string{
+				"Strict-Transport-Security": "max-age=31536000",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil, broker.WithSecurityHeaders(tc.Enabled))
+			defer b.Close()
+
+			w := ssetest.NewStreamRecorder()
+			r := w.NewRequest("GET", "/connect?id=test", nil)
+			done := make(chan struct{})
+
+			if tc.TLS {
+				r.TLS = &tls.ConnectionState{}
+			}
+
+			go func() {
+				b.ClientHandler(w, r)
+				close(done)
+			}()
+
+			<-time.Tick(time.Millisecond * 100)
+
+			// The handler owns the headers until it returns.
+			w.Close()
+			<-done
+
+			for name, value := range tc.ExpectedHeaders {
+				assert.Equal(t, value, w.Header().Get(name), name)
+			}
+		})
+	}
+}
+
+func TestSelfCheckHandler(t *testing.T) {
+	tt := []struct {
+		Name             string
+		Headers          map[string]string
+		Proto            int
+		ExpectedCheck    broker.SelfCheck
+		ExpectedWarnings int
+	}{
+		{
+			Name:    "It should warn about unencrypted HTTP/1.x requests via proxies",
+			Headers: map[string]string{"Via": "1.1 nginx"},
+			Proto:   1,
+			ExpectedCheck: broker.SelfCheck{
+				Protocol: "HTTP/1.1",
+				Proxied:  true,
+			},
+			ExpectedWarnings: 3,
+		},
+		{
+			Name:    "It should not warn about direct HTTP/2 requests over TLS",
+			Headers: map[string]string{"X-Forwarded-Proto": "https"},
+			Proto:   2,
+			ExpectedCheck: broker.SelfCheck{
+				Protocol: "HTTP/2.0",
+				TLS:      true,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/self-check", nil)
+			r.Proto = tc.ExpectedCheck.Protocol
+			r.ProtoMajor = tc.Proto
+
+			for name, value := range tc.Headers {
+				r.Header.Set(name, value)
+			}
+
+			w := httptest.NewRecorder()
+			broker.SelfCheckHandler(w, r)
+
+			var check broker.SelfCheck
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&check))
+			assert.Len(t, check.Warnings, tc.ExpectedWarnings)
+
+			check.Warnings = nil
+			assert.Equal(t, tc.ExpectedCheck, check)
+		})
+	}
+}
+
+func TestSelfCheckHandler_Stream(t *testing.T) {
+	w := ssetest.NewStreamRecorder()
+	defer w.Close()
+
+	r := w.NewRequest("GET", "/self-check", nil)
+	r.Header.Set("Accept", "text/event-stream")
+
+	broker.SelfCheckHandler(w, r)
+
+	events, err := w.Events()
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, events, 6) {
+		assert.Equal(t, "self-check", events[0].Type)
+		assert.Equal(t, "tick", events[5].Type)
+	}
+}
+
+func TestNewSelfCheckHandler(t *testing.T) {
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := ssetest.NewStreamRecorder()
+	defer w.Close()
+
+	r := w.NewRequest("GET", "/self-check", nil)
+	r.Header.Set("Accept", "text/event-stream")
+
+	go broker.NewSelfCheckHandler(clk)(w, r)
+
+	// Ticks are only written as the clock is advanced.
+	assert.True(t, clk.WaitForTimers(1, time.Second))
+
+	for i := 1; i <= 5; i++ {
+		clk.Advance(time.Millisecond * 250)
+
+		_, err := w.WaitForEvents(i+1, time.Second)
+		assert.NoError(t, err)
+	}
+
+	events, err := w.Events()
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, events, 6) {
+		assert.Equal(t, "2020-01-01T00:00:01.25Z", strings.SplitN(string(events[5].Data), " ", 2)[1])
+	}
+}
@@ -0,0 +1,65 @@
+package broker
+
+import (
+	"sync"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The sequencer type numbers the events broadcast to each topic, so that clients can detect
+	// events they have missed. Events broadcast to all clients are numbered under a blank topic.
+	sequencer struct {
+		mux    sync.Mutex
+		topics map[string]*sequencedTopic
+	}
+
+	// The sequencedTopic type holds the number of the last event broadcast to a topic.
+	sequencedTopic struct {
+		mux  sync.Mutex
+		last uint64
+	}
+)
+
+// WithSequencing configures the broker to number the events broadcast to each topic, starting from
+// one, & send the number to clients in the 'seq' field alongside the topic. Clients that receive a
+// number more than one greater than the last they received for the topic have missed events, & can
+// reconnect with their 'Last-Event-ID' to have them replayed, as the consumer package does. Numbers
+// start again from one when the broker restarts. Events that are not sent to every subscriber of
+// their topic, such as those with an audience, group, excluded clients or a key, are not numbered.
+// Numbered events are written to a topic one at a time, so that clients receive them in order.
+func WithSequencing(enabled bool) Option {
+	return func(b *defaultBroker) {
+		if !enabled {
+			b.sequences = nil
+			return
+		}
+
+		b.sequences = &sequencer{topics: make(map[string]*sequencedTopic)}
+	}
+}
+
+// topic returns the sequence of the event's topic, or nil if the event is not numbered.
+func (s *sequencer) topic(e event.Event) *sequencedTopic {
+	if s == nil || e.Audience != "" || e.Group != "" || len(e.Except) > 0 || e.Key != "" {
+		return nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	topic, ok := s.topics[e.Topic]
+
+	if !ok {
+		topic = &sequencedTopic{}
+		s.topics[e.Topic] = topic
+	}
+
+	return topic
+}
+
+// next returns the number of the next event broadcast to the topic.
+func (t *sequencedTopic) next() uint64 {
+	t.last++
+	return t.last
+}
@@ -0,0 +1,103 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithSequencing(t *testing.T) {
+	tt := []struct {
+		Name              string
+		Enabled           bool
+		Events            []event.Event
+		ExpectedSequences []uint64
+	}{
+		{
+			Name:    "It should number events within each topic",
+			Enabled: true,
+			Events: []event.Event{
+				{Topic: "a", Data: []byte("1")},
+				{Topic: "b", Data: []byte("2")},
+				{Topic: "a", Data: []byte("3")},
+				{Data: []byte("4")},
+			},
+			ExpectedSequences: []uint64{1, 1, 2, 1},
+		},
+		{
+			Name:    "It should not number events that are not sent to every subscriber",
+			Enabled: true,
+			Events: []event.Event{
+				{Topic: "a", Data: []byte("1")},
+				{Topic: "a", Data: []byte("2"), Audience: "role=admin"},
+				{Topic: "a", Data: []byte("3"), Group: "team:1"},
+				{Topic: "a", Data: []byte("4"), Except: []string{"other"}},
+				{Topic: "a", Data: []byte("5"), Key: "price:AAPL"},
+				{Topic: "a", Data: []byte("6")},
+			},
+			ExpectedSequences: []uint64{1, 0, 0, 0, 0, 2},
+		},
+		{
+			Name: "It should not number events when disabled",
+			Events: []event.Event{
+				{Topic: "a", Data: []byte("1")},
+				{Topic: "a", Data: []byte("2")},
+			},
+			ExpectedSequences: []uint64{0, 0},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, broker.WithSequencing(tc.Enabled))
+			defer brk.Close()
+
+			c := client.NewWithOptions(time.Second, 3, "1234",
+				client.WithQueueSize(10),
+				client.WithTopics("a", "b"),
+				client.WithMetadata(map[string]string{"role": "admin"}),
+				client.WithGroups("team:1"))
+
+			assert.NoError(t, brk.Subscribe(c))
+
+			for _, e := range tc.Events {
+				assert.NoError(t, brk.BroadcastEvent(e))
+			}
+
+			var sequences []uint64
+
+			for e, ok := c.Next(); ok; e, ok = c.Next() {
+				sequences = append(sequences, e.Sequence)
+			}
+
+			assert.Equal(t, tc.ExpectedSequences, sequences)
+		})
+	}
+}
+
+func TestBroker_SequenceField(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil, broker.WithSequencing(true), broker.WithStore(store.NewMemory(10)))
+	defer brk.Close()
+
+	for i, data := range []string{"100", "101"} {
+		assert.NoError(t, brk.BroadcastEvent(event.Event{ID: strconv.Itoa(i + 1), Topic: "prices", Data: []byte(data)}))
+	}
+
+	// Replayed events keep the numbers they were given when they were broadcast.
+	w := &FlushRecorder{header: http.Header{}}
+	r := httptest.NewRequest(http.MethodGet, "/connect?topic=prices", nil)
+	r.Header.Set("Last-Event-ID", "1")
+
+	go brk.ClientHandler(w, r)
+	<-time.After(time.Millisecond * 500)
+
+	assert.Equal(t, "id: 2\nseq: 2 prices\ndata: 101\n\n", w.String())
+}
@@ -0,0 +1,246 @@
+package broker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The sessions type contains the sessions issued to clients by the broker, allowing a client
+	// that reconnects shortly after its connection drops to resume where it left off.
+	sessions struct {
+		grace time.Duration
+		clock clock.Clock
+
+		mux  sync.Mutex
+		byID map[string]*session
+	}
+
+	// The session type contains a client that can be resumed by reconnecting with the session's
+	// identifier.
+	session struct {
+		id       string
+		client   *client.Client
+		attached bool
+		done     chan struct{} // Closed when the current connection is detached.
+		expiry   clock.Timer   // Set while no connection is attached.
+	}
+)
+
+const (
+	// The type of the event that informs a client of its session identifier.
+	sessionEventType = "session"
+)
+
+// WithSessions configures the broker to issue a session to each client when it connects. The
+// session's identifier is sent as the first event of the stream, with the 'session' type. If the
+// client's connection drops, it remains subscribed & its events continue to be queued for the
+// grace period. A client that reconnects within the grace period with the identifier in the
+// 'session' query parameter resumes the same queue, subscriptions & metadata. A client connecting
+// with an identifier, such as one derived from its credentials, can only resume the sessions issued
+// to that identifier; otherwise it is issued a new session. If 'grace' is zero, sessions are not
+// issued.
+func WithSessions(grace time.Duration) Option {
+	return func(b *defaultBroker) {
+		if grace <= 0 {
+			b.sessions = nil
+			return
+		}
+
+		b.sessions = &sessions{grace: grace, byID: make(map[string]*session)}
+	}
+}
+
+// useClock sets the clock used to expire sessions. It does nothing if sessions are disabled.
+func (s *sessions) useClock(c clock.Clock) {
+	if s != nil {
+		s.clock = c
+	}
+}
+
+// open issues a new session for the client, returning the session & a channel that is closed
+// when the connection is detached from it. If sessions are disabled, both are nil.
+func (s *sessions) open(c *client.Client) (*session, <-chan struct{}) {
+	if s == nil {
+		return nil, nil
+	}
+
+	sess := &session{id: newSessionID(), client: c, attached: true, done: make(chan struct{})}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.byID[sess.id] = sess
+
+	return sess, sess.done
+}
+
+// resume attaches a new connection to the session with the given identifier. If the session is
+// still attached to another connection, that connection is detached. If there is no such session,
+// both return values are nil.
+func (s *sessions) resume(id string) (*session, <-chan struct{}) {
+	if s == nil || id == "" {
+		return nil, nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	sess, ok := s.byID[id]
+
+	if !ok {
+		return nil, nil
+	}
+
+	if sess.attached {
+		close(sess.done)
+	}
+
+	if sess.expiry != nil {
+		sess.expiry.Stop()
+		sess.expiry = nil
+	}
+
+	sess.attached = true
+	sess.done = make(chan struct{})
+
+	return sess, sess.done
+}
+
+// owned returns false if the session with the given identifier was issued to a client with an
+// identifier other than 'owner'. Sessions are owned by any client if 'owner' is blank.
+func (s *sessions) owned(id, owner string) bool {
+	if s == nil || id == "" || owner == "" {
+		return true
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	sess, ok := s.byID[id]
+
+	return !ok || sess.client.ID() == owner
+}
+
+// detach detaches the connection from the session, unless another connection has already been
+// attached to it. If nothing resumes the session within the grace period, it is removed &
+// 'expire' is called.
+func (s *sessions) detach(sess *session, done <-chan struct{}, expire func()) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if !sess.attached || sess.done != done {
+		return
+	}
+
+	close(sess.done)
+	sess.attached = false
+	sess.expiry = s.clock.AfterFunc(s.grace, func() {
+		s.mux.Lock()
+
+		// The session may have been resumed while the timer was firing.
+		if sess.attached || s.byID[sess.id] != sess {
+			s.mux.Unlock()
+			return
+		}
+
+		delete(s.byID, sess.id)
+		s.mux.Unlock()
+
+		expire()
+	})
+}
+
+// remove discards the session, if it exists.
+func (s *sessions) remove(id string) {
+	if s == nil {
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if sess, ok := s.byID[id]; ok {
+		if sess.expiry != nil {
+			sess.expiry.Stop()
+		}
+
+		delete(s.byID, id)
+	}
+}
+
+// close discards all sessions.
+func (s *sessions) close() {
+	if s == nil {
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for id, sess := range s.byID {
+		if sess.expiry != nil {
+			sess.expiry.Stop()
+		}
+
+		delete(s.byID, id)
+	}
+}
+
+// resumeSession returns the session the request resumes, if its client is still connected to
+// the broker.
+func (b *defaultBroker) resumeSession(id string) (*session, <-chan struct{}) {
+	sess, done := b.sessions.resume(id)
+
+	if sess == nil {
+		return nil, nil
+	}
+
+	// The client may have been disconnected while detached, for example by exceeding its
+	// error tolerance.
+	if !b.connected(sess.client) {
+		b.sessions.remove(sess.id)
+		return nil, nil
+	}
+
+	return sess, done
+}
+
+// release ends the client's connection for the given reason. If the client has a session, it is
+// detached so that it can be resumed, otherwise the client is disconnected.
+func (b *defaultBroker) release(c *client.Client, sess *session, done <-chan struct{}, reason DisconnectReason) {
+	if sess == nil {
+		b.disconnect(c, reason)
+		return
+	}
+
+	b.sessions.detach(sess, done, func() { b.disconnect(c, reason) })
+}
+
+// key returns the session's identifier, or a blank string if there is no session.
+func (sess *session) key() string {
+	if sess == nil {
+		return ""
+	}
+
+	return sess.id
+}
+
+// sessionEvent returns the event that informs a client of its session identifier.
+func sessionEvent(sess *session, now time.Time) event.Event {
+	return event.Event{Type: sessionEventType, Data: []byte(sess.id), Timestamp: now}
+}
+
+// newSessionID returns a random session identifier that cannot be guessed by other clients.
+func newSessionID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,139 @@
+package broker_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Sessions(t *testing.T) {
+	tt := []struct {
+		Name            string
+		Wait            time.Duration
+		ExpectedResumed bool
+	}{
+		{
+			Name:            "It should resume a session within the grace period",
+			Wait:            time.Millisecond * 100,
+			ExpectedResumed: true,
+		},
+		{
+			Name:            "It should not resume a session after the grace period",
+			Wait:            time.Second * 2,
+			ExpectedResumed: false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil, broker.WithSessions(time.Second), broker.WithQueueSize(10))
+			defer b.Close()
+
+			w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+
+			// Connect to the broker, give it 1 second to create the
+			// client
+			go b.ClientHandler(w, httptest.NewRequest("GET", "/connect?topic=news", nil))
+			<-time.Tick(time.Second)
+
+			// The first event of the stream contains the session identifier.
+			e, err := protocol.NewDecoder(strings.NewReader(w.String())).Decode()
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, "session", e.Type)
+
+			// Drop the connection & publish an event while the client is away.
+			close(w.close)
+			<-time.Tick(tc.Wait)
+
+			assert.NoError(t, b.BroadcastTopic("news", []byte("missed")))
+
+			w = &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+			go b.ClientHandler(w, httptest.NewRequest("GET", "/connect?topic=news&session="+string(e.Data), nil))
+			<-time.Tick(time.Millisecond * 500)
+
+			assert.Equal(t, tc.ExpectedResumed, strings.Contains(w.String(), "data: missed"), w.String())
+			assert.Equal(t, !tc.ExpectedResumed, strings.Contains(w.String(), "event: session"))
+			assert.Equal(t, 1, b.Stats().Clients)
+		})
+	}
+}
+
+func TestBroker_SessionsIdentity(t *testing.T) {
+	tt := []struct {
+		Name            string
+		User            string
+		ExpectedResumed bool
+		ExpectedClients int
+	}{
+		{
+			Name:            "It should resume a session issued to the same identity",
+			User:            "alice",
+			ExpectedResumed: true,
+			ExpectedClients: 1,
+		},
+		{
+			Name:            "It should start a new session for another identity",
+			User:            "bob",
+			ExpectedResumed: false,
+			ExpectedClients: 2,
+		},
+	}
+
+	fn := func(ctx context.Context) broker.ClientInfo {
+		user, _ := ctx.Value(contextKey("user")).(string)
+
+		return broker.ClientInfo{ID: user}
+	}
+
+	connect := func(b broker.Broker, user, query string) *FlushRecorder {
+		w := &FlushRecorder{header: http.Header{}, close: make(chan bool)}
+		r := httptest.NewRequest("GET", "/connect?topic=news"+query, nil)
+
+		go b.ClientHandler(w, r.WithContext(context.WithValue(r.Context(), contextKey("user"), user)))
+		<-time.After(time.Millisecond * 500)
+
+		return w
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil,
+				broker.WithSessions(time.Second),
+				broker.WithQueueSize(10),
+				broker.WithClientFromContext(fn),
+			)
+			defer b.Close()
+
+			w := connect(b, "alice", "")
+
+			e, err := protocol.NewDecoder(strings.NewReader(w.String())).Decode()
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			// Drop the connection & publish an event while the client is away.
+			close(w.close)
+			<-time.After(time.Millisecond * 100)
+
+			assert.NoError(t, b.BroadcastTo("alice", []byte("missed")))
+
+			w = connect(b, tc.User, "&session="+string(e.Data))
+
+			assert.Equal(t, tc.ExpectedResumed, strings.Contains(w.String(), "data: missed"), w.String())
+			assert.Equal(t, !tc.ExpectedResumed, strings.Contains(w.String(), "event: session"))
+			assert.Equal(t, tc.ExpectedClients, b.Stats().Clients)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// SubscribeHook is a function that produces the events describing the current state of
+	// a topic, which are sent to a client when it subscribes before any live events. The
+	// topic is blank for clients that are not subscribed to any topics.
+	SubscribeHook func(clientID, topic string) []event.Event
+)
+
+// WithOnSubscribe configures the broker to call 'fn' for each topic a client subscribes to
+// when it connects, and to write the events it returns to the client before live events.
+// The client is subscribed before 'fn' is called, so events broadcast while the snapshot is
+// produced are delivered after it rather than being lost. If 'fn' is nil, clients only
+// receive live events.
+func WithOnSubscribe(fn SubscribeHook) Option {
+	return func(b *defaultBroker) {
+		b.onSubscribe = fn
+	}
+}
+
+// snapshot writes the events produced by the subscribe hook for each of the client's topics
+// to 'w', returning the number of events written.
+func (b *defaultBroker) snapshot(enc FrameWriter, c *client.Client) int {
+	if b.onSubscribe == nil {
+		return 0
+	}
+
+	topics := c.Topics()
+
+	if len(topics) == 0 {
+		topics = []string{""}
+	}
+
+	var n int
+
+	for _, topic := range topics {
+		for _, e := range b.onSubscribe(c.ID(), topic) {
+			b.write(enc, c, e)
+			n++
+		}
+	}
+
+	return n
+}
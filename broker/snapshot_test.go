@@ -0,0 +1,44 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithOnSubscribe(t *testing.T) {
+	tt := []struct {
+		Query          string
+		ExpectedOutput string
+	}{
+		{Query: "?id=test&topic=prices", ExpectedOutput: "data: test:prices\n\ndata: delta\n\n"},
+		{Query: "?id=test&topic=prices&topic=news", ExpectedOutput: "data: test:prices\n\ndata: test:news\n\ndata: delta\n\n"},
+		{Query: "?id=test", ExpectedOutput: "data: test:\n\ndata: delta\n\n"},
+	}
+
+	for _, tc := range tt {
+		fn := func(clientID, topic string) []event.Event {
+			return []event.Event{{Data: []byte(clientID + ":" + topic)}}
+		}
+
+		broker := broker.New(time.Second, 3, nil, broker.WithOnSubscribe(fn))
+		w := &FlushRecorder{header: http.Header{}}
+		r := httptest.NewRequest("GET", "/connect"+tc.Query, nil)
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go broker.ClientHandler(w, r)
+		<-time.Tick(time.Second)
+
+		assert.NoError(t, broker.BroadcastTo("test", []byte("delta")))
+		<-time.Tick(time.Second)
+
+		assert.Equal(t, tc.ExpectedOutput, w.String())
+		broker.Close()
+	}
+}
@@ -0,0 +1,205 @@
+package broker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Stats type contains statistics on the current state of the broker.
+	Stats struct {
+		Clients int                   // The number of clients connected to the broker.
+		Shards  []ShardStats          // Statistics for each shard of the group containing every client.
+		Topics  map[string]TopicStats // Statistics for each topic that has at least one subscriber.
+		Lag     map[string]client.Lag // How far behind each connected client is, by client id.
+
+		Protocols map[string]int // The number of clients connected using each protocol, such as 'HTTP/2.0'.
+
+		BytesSent uint64               // The number of bytes written to all clients.
+		Bandwidth map[string]Bandwidth // The number of bytes written to each connected client, by client id.
+		Members   []string             // The members of the cluster the broker belongs to, if any.
+
+		Resources Resources // The goroutines & timers held by client connections, see the Resources type.
+
+		Dispatch DispatchStats      // The events waiting to be published by the Dispatch & BroadcastAsync methods.
+		Liveness map[string]float64 // The liveness score of each streaming client, by client id, see the broker.WithLiveness method.
+
+		Saturation float64     // The events queued across all clients as a fraction of the maximum, see the broker.WithBackpressure method.
+		Memory     MemoryStats // The memory used by buffered events, see the broker.WithMemoryBudget method.
+	}
+
+	// The TopicStats type contains statistics on a single topic.
+	TopicStats struct {
+		Subscribers int          // The number of clients subscribed to the topic.
+		Shards      []ShardStats // Statistics for each shard of the topic's subscribers.
+		BytesSent   uint64       // The number of bytes written to clients for events on the topic.
+
+		Events          uint64  // The number of events broadcast to the topic since it gained its first subscriber.
+		EventsPerSecond float64 // The rate events were broadcast to the topic, averaged over the last ten seconds.
+		AveragePayload  float64 // The average size of the data of events broadcast to the topic, in bytes.
+	}
+
+	// The ShardStats type contains statistics on a single shard of clients.
+	ShardStats struct {
+		Clients   int    // The number of clients within the shard.
+		Delivered uint64 // The number of events successfully written to clients in the shard.
+		Failed    uint64 // The number of events that could not be written to clients in the shard.
+	}
+
+	// The topicRate type records the number & size of the events broadcast to a topic. Recent
+	// events are counted in one second buckets, which are reused as the window moves on.
+	topicRate struct {
+		mux     sync.Mutex
+		events  uint64
+		payload uint64
+		counts  [topicRateWindow]uint64
+		seconds [topicRateWindow]int64
+	}
+)
+
+const (
+	// The number of seconds the rate of events broadcast to each topic is averaged over.
+	topicRateWindow = 10
+)
+
+// Stats returns statistics on the clients & topics currently held by the broker, including the
+// number of bytes written to them, see the broker.WithBandwidthQuota method, and the rate & size
+// of the events broadcast to each topic.
+func (b *defaultBroker) Stats() Stats {
+	out := Stats{
+		Clients: b.all.len(),
+		Shards:  b.all.stats(),
+		Topics:  make(map[string]TopicStats),
+		Lag:     make(map[string]client.Lag),
+
+		Protocols: make(map[string]int),
+		Resources: b.resources.stats(),
+		Dispatch:  b.dispatcher.stats(),
+		Liveness:  b.liveness.stats(b.clock.Now()),
+	}
+
+	bandwidth, topics, sent := b.bandwidth.stats(b.clock.Now())
+	out.Bandwidth = bandwidth
+	out.BytesSent = sent
+
+	if b.cluster != nil {
+		out.Members = b.cluster.memberList()
+	}
+
+	var pending, queued int
+
+	b.clients.Range(func(key, value interface{}) bool {
+		if c, ok := value.(*client.Client); ok {
+			lag := c.Lag()
+			out.Lag[c.ID()] = lag
+			pending += lag.Pending + lag.Held
+
+			if b.memory != nil {
+				queued += c.Buffered()
+			}
+
+			if proto := c.Protocol(); proto != "" {
+				out.Protocols[proto]++
+			}
+		}
+
+		return true
+	})
+
+	out.Saturation = b.backpressure.saturation(pending)
+	out.Memory = b.memoryStats(queued)
+
+	b.topicsMux.RLock()
+	defer b.topicsMux.RUnlock()
+
+	now := b.clock.Now()
+
+	for name, topic := range b.topics {
+		stats := topic.rate.stats(now)
+		stats.Subscribers = topic.len()
+		stats.Shards = topic.stats()
+		stats.BytesSent = topics[name]
+
+		out.Topics[name] = stats
+	}
+
+	return out
+}
+
+// recordTopic counts the event towards the statistics of its topic, if the topic has any
+// subscribers.
+func (b *defaultBroker) recordTopic(e event.Event) {
+	if e.Topic == "" {
+		return
+	}
+
+	b.topicsMux.RLock()
+	topic, ok := b.topics[e.Topic]
+	b.topicsMux.RUnlock()
+
+	if ok {
+		topic.rate.record(len(e.Data), b.clock.Now())
+	}
+}
+
+// totals returns the number of events delivered & failed across every shard, and the number of
+// events pending for all clients.
+func (s Stats) totals() (delivered, failed uint64, pending int) {
+	for _, shard := range s.Shards {
+		delivered += shard.Delivered
+		failed += shard.Failed
+	}
+
+	for _, lag := range s.Lag {
+		pending += lag.Pending
+	}
+
+	return delivered, failed, pending
+}
+
+// record counts an event whose data is 'size' bytes long.
+func (r *topicRate) record(size int, now time.Time) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	sec := now.Unix()
+	i := sec % topicRateWindow
+
+	if r.seconds[i] != sec {
+		r.seconds[i] = sec
+		r.counts[i] = 0
+	}
+
+	r.counts[i]++
+	r.events++
+	r.payload += uint64(size)
+}
+
+// stats returns the number, rate & average size of the events that have been recorded.
+func (r *topicRate) stats(now time.Time) TopicStats {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var out TopicStats
+	var recent uint64
+
+	sec := now.Unix()
+
+	for i, counted := range r.seconds {
+		if age := sec - counted; age >= 0 && age < topicRateWindow {
+			recent += r.counts[i]
+		}
+	}
+
+	out.Events = r.events
+	out.EventsPerSecond = float64(recent) / topicRateWindow
+
+	if r.events > 0 {
+		out.AveragePayload = float64(r.payload) / float64(r.events)
+	}
+
+	return out
+}
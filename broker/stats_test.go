@@ -0,0 +1,42 @@
+package broker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_TopicStats(t *testing.T) {
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	brk := broker.New(time.Second, 3, nil, broker.WithClock(clk))
+	defer brk.Close()
+
+	assert.NoError(t, brk.Subscribe(client.NewWithOptions(time.Second, 3, "", client.WithTopics("news"), client.WithQueueSize(10))))
+
+	for _, data := range []string{"a", "bbb", "ccccc", "ddddddd"} {
+		assert.NoError(t, brk.BroadcastTopic("news", []byte(data)))
+	}
+
+	clk.Advance(time.Second)
+	assert.NoError(t, brk.BroadcastTopic("empty", []byte("hello")))
+
+	stats := brk.Stats().Topics
+	assert.Equal(t, 1, stats["news"].Subscribers)
+	assert.Equal(t, uint64(4), stats["news"].Events)
+	assert.Equal(t, 0.4, stats["news"].EventsPerSecond)
+	assert.Equal(t, 4.0, stats["news"].AveragePayload)
+
+	assert.NotContains(t, stats, "empty")
+
+	// Events leave the rate once they are older than the window.
+	clk.Advance(time.Second * 10)
+
+	stats = brk.Stats().Topics
+	assert.Equal(t, uint64(4), stats["news"].Events)
+	assert.Equal(t, 0.0, stats["news"].EventsPerSecond)
+}
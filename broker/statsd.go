@@ -0,0 +1,226 @@
+package broker
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/clock"
+)
+
+type (
+	// The StatsDConfig type configures how the broker pushes its statistics to a StatsD server,
+	// such as a Datadog agent. See the broker.WithStatsD method.
+	StatsDConfig struct {
+		Address  string        // The host & port of the StatsD server, such as 'localhost:8125'.
+		Prefix   string        // Prepended to the name of each metric, such as 'sse.'.
+		Tags     []string      // DogStatsD tags added to each metric, such as 'env:prod'.
+		Interval time.Duration // How often statistics are pushed. Defaults to ten seconds.
+	}
+
+	// The statsd type periodically pushes the broker's statistics to a StatsD server.
+	statsd struct {
+		cfg   StatsDConfig
+		stats func() Stats
+		clock clock.Clock
+		conn  net.Conn
+
+		// The totals from the previous push, used to report counters as the change since.
+		delivered uint64
+		failed    uint64
+		sent      uint64
+		events    map[string]uint64
+
+		done   chan struct{}
+		closed chan struct{}
+		once   sync.Once
+	}
+)
+
+const (
+	// The maximum size of each packet sent to the StatsD server, chosen so that packets fit
+	// within the MTU of most networks.
+	statsdPacketSize = 1432
+)
+
+var (
+	statsdReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", "\n", "_")
+)
+
+// WithStatsD configures the broker to periodically push its statistics to a StatsD server over
+// UDP. The number of connected clients, pending events, cluster members & subscribers of each topic
+// are sent as gauges, as are the rate & average size of the events broadcast to each topic. The
+// number of events delivered, events that failed, bytes written & events broadcast to each topic are
+// sent as counters of the change since the previous push. If 'cfg.Tags' is set, metrics are written
+// using the DogStatsD tag extension. If 'cfg.Address' is blank, this option does nothing.
+func WithStatsD(cfg StatsDConfig) Option {
+	return func(b *defaultBroker) {
+		b.statsdConfig = cfg
+	}
+}
+
+func newStatsD(cfg StatsDConfig, stats func() Stats, clk clock.Clock) *statsd {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second * 10
+	}
+
+	s := &statsd{
+		cfg:    cfg,
+		stats:  stats,
+		clock:  clk,
+		done:   make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s
+}
+
+// withTag returns a copy of the configuration with an additional tag.
+func (cfg StatsDConfig) withTag(tag string) StatsDConfig {
+	cfg.Tags = append(append([]string(nil), cfg.Tags...), tag)
+
+	return cfg
+}
+
+func (s *statsd) run() {
+	defer close(s.closed)
+
+	ticker := s.clock.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			s.push()
+		case <-s.done:
+			if s.conn != nil {
+				s.conn.Close()
+			}
+
+			return
+		}
+	}
+}
+
+// push sends the broker's current statistics to the StatsD server. If the server cannot be
+// reached, the statistics are discarded & sending is attempted again on the next push.
+func (s *statsd) push() {
+	if s.conn == nil {
+		conn, err := net.Dial("udp", s.cfg.Address)
+
+		if err != nil {
+			return
+		}
+
+		s.conn = conn
+	}
+
+	for _, packet := range s.packets(s.stats()) {
+		s.conn.Write(packet)
+	}
+}
+
+// packets returns the metrics for the statistics, batched into packets no larger than the
+// maximum packet size.
+func (s *statsd) packets(stats Stats) [][]byte {
+	delivered, failed, pending := stats.totals()
+
+	var metrics []string
+
+	metrics = append(metrics,
+		s.metric("clients", stats.Clients, "g"),
+		s.metric("pending", pending, "g"),
+		s.metric("members", len(stats.Members), "g"),
+		s.metric("events.delivered", delta(delivered, s.delivered), "c"),
+		s.metric("events.failed", delta(failed, s.failed), "c"),
+		s.metric("bytes_sent", delta(stats.BytesSent, s.sent), "c"),
+	)
+
+	s.delivered, s.failed, s.sent = delivered, failed, stats.BytesSent
+
+	topics := make([]string, 0, len(stats.Topics))
+
+	for topic := range stats.Topics {
+		topics = append(topics, topic)
+	}
+
+	sort.Strings(topics)
+
+	events := make(map[string]uint64, len(topics))
+
+	for _, topic := range topics {
+		name := "topics." + statsdReplacer.Replace(topic) + "."
+		ts := stats.Topics[topic]
+
+		metrics = append(metrics,
+			s.metric(name+"subscribers", ts.Subscribers, "g"),
+			s.metric(name+"events", delta(ts.Events, s.events[topic]), "c"),
+			s.metric(name+"events_per_second", ts.EventsPerSecond, "g"),
+			s.metric(name+"payload_bytes", ts.AveragePayload, "g"),
+		)
+
+		events[topic] = ts.Events
+	}
+
+	s.events = events
+
+	var out [][]byte
+	buf := &bytes.Buffer{}
+
+	for _, metric := range metrics {
+		if buf.Len() > 0 && buf.Len()+len(metric)+1 > statsdPacketSize {
+			out = append(out, buf.Bytes())
+			buf = &bytes.Buffer{}
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+
+		buf.WriteString(metric)
+	}
+
+	if buf.Len() > 0 {
+		out = append(out, buf.Bytes())
+	}
+
+	return out
+}
+
+// metric formats a single metric, including any configured tags. Floating point values are
+// never written using exponents, which StatsD servers do not accept.
+func (s *statsd) metric(name string, value interface{}, kind string) string {
+	if f, ok := value.(float64); ok {
+		value = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	out := fmt.Sprintf("%s%s:%v|%s", s.cfg.Prefix, name, value, kind)
+
+	if len(s.cfg.Tags) > 0 {
+		out += "|#" + strings.Join(s.cfg.Tags, ",")
+	}
+
+	return out
+}
+
+// delta returns the change in a total since the previous push. If the total has been reset, it
+// is returned as-is.
+func delta(total, previous uint64) uint64 {
+	if total < previous {
+		return total
+	}
+
+	return total - previous
+}
+
+func (s *statsd) close() {
+	s.once.Do(func() { close(s.done) })
+	<-s.closed
+}
@@ -0,0 +1,83 @@
+package broker_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_StatsD(t *testing.T) {
+	tt := []struct {
+		Name            string
+		Tags            []string
+		ExpectedMetrics []string
+	}{
+		{
+			Name: "It should push statistics to the StatsD server",
+			ExpectedMetrics: []string{
+				"sse.clients:1|g",
+				"sse.pending:2|g",
+				"sse.events.delivered:1|c",
+				"sse.topics.news.subscribers:1|g",
+				"sse.topics.news.events:1|c",
+				"sse.topics.news.payload_bytes:5|g",
+			},
+		},
+		{
+			Name: "It should tag metrics using the DogStatsD extension",
+			Tags: []string{"env:test", "region:eu"},
+			ExpectedMetrics: []string{
+				"sse.clients:1|g|#env:test,region:eu",
+				"sse.pending:2|g|#env:test,region:eu",
+				"sse.events.delivered:1|c|#env:test,region:eu",
+				"sse.topics.news.subscribers:1|g|#env:test,region:eu",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			defer conn.Close()
+
+			b := broker.New(time.Second, 3, nil,
+				broker.WithShards(1),
+				broker.WithStatsD(broker.StatsDConfig{
+					Address:  conn.LocalAddr().String(),
+					Prefix:   "sse.",
+					Tags:     tc.Tags,
+					Interval: time.Millisecond * 500,
+				}),
+			)
+			defer b.Close()
+
+			assert.NoError(t, b.Subscribe(client.NewWithOptions(time.Second, 3, "test", client.WithTopics("news"), client.WithQueueSize(10))))
+			assert.NoError(t, b.Broadcast([]byte("hello")))
+			assert.NoError(t, b.BroadcastTopic("news", []byte("hello")))
+
+			buf := make([]byte, 1432)
+			conn.SetReadDeadline(time.Now().Add(time.Second * 2))
+			n, _, err := conn.ReadFrom(buf)
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			metrics := strings.Split(string(buf[:n]), "\n")
+
+			for _, expected := range tc.ExpectedMetrics {
+				assert.Contains(t, metrics, expected)
+			}
+		})
+	}
+}
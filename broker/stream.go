@@ -0,0 +1,204 @@
+package broker
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The stream type is a Publisher that broadcasts events to one of the broker's logical
+	// streams.
+	stream struct {
+		broker *defaultBroker
+		name   string
+	}
+
+	// The multiplexer type is a FrameWriter for connections that carry several logical streams.
+	// Events belonging to a stream have the stream's name prefixed to their type, & are identified
+	// by the cursor of each stream written to the connection, so that browsers send every cursor
+	// in the Last-Event-ID header when they reconnect.
+	multiplexer struct {
+		FrameWriter
+		cursors     url.Values
+		multiplexed bool
+	}
+)
+
+const (
+	// streamCursorPrefix begins the identifiers of events written to connections that carry
+	// several logical streams.
+	streamCursorPrefix = "streams:"
+)
+
+// Stream returns a Publisher that broadcasts events to the logical stream with the given name,
+// such as 'notifications'. A single connection carries events from all streams, reducing the
+// number of connections browsers need to open to the broker. Events are sent to clients with the
+// stream's name prefixed to their type, such as 'notifications:message' for events without a type,
+// and can be listened for using EventSource.addEventListener. Each stream has its own replay cursor;
+// the identifiers of events written to such connections hold the last event identifier of every stream
+// the connection has received, such as 'streams:chat=17&notifications=42', so that when a browser
+// reconnects each stream is replayed from where it left off. Streams the client has not yet received
+// events from are replayed from its oldest cursor. Events written as newline-delimited JSON have their
+// 'stream' field set instead.
+func (b *defaultBroker) Stream(name string) Publisher {
+	return &stream{broker: b, name: name}
+}
+
+// Broadcast writes the given data to all connected clients as an event of the stream.
+func (s *stream) Broadcast(data []byte) error {
+	return s.BroadcastEvent(event.Event{Data: data})
+}
+
+// BroadcastTo writes the given data to the client with the given id as an event of the stream.
+func (s *stream) BroadcastTo(id string, data []byte) error {
+	return s.broker.sendTo(id, event.Event{Stream: s.name, Data: data})
+}
+
+// BroadcastTopic writes the given data to the subscribers of the topic as an event of the stream.
+func (s *stream) BroadcastTopic(topic string, data []byte) error {
+	return s.BroadcastEvent(event.Event{Topic: topic, Data: data})
+}
+
+// BroadcastExcept writes the given data to all connected clients other than those with the given
+// ids as an event of the stream.
+func (s *stream) BroadcastExcept(excludeIDs []string, data []byte) error {
+	return s.BroadcastEvent(event.Event{Except: excludeIDs, Data: data})
+}
+
+// BroadcastGroup writes the given data to the members of the group as an event of the stream.
+func (s *stream) BroadcastGroup(group string, data []byte) error {
+	return s.BroadcastEvent(event.Event{Group: group, Data: data})
+}
+
+// BroadcastEvent broadcasts the event to the stream, replacing its Stream field.
+func (s *stream) BroadcastEvent(e event.Event) error {
+	e.Stream = s.name
+	return s.broker.BroadcastEvent(e)
+}
+
+// BroadcastWithin broadcasts the event to the stream within the error budget, replacing its Stream
+// field.
+func (s *stream) BroadcastWithin(e event.Event, budget ErrorBudget) error {
+	e.Stream = s.name
+	return s.broker.BroadcastWithin(e, budget)
+}
+
+// BroadcastSummary broadcasts the event to the stream, replacing its Stream field, & returns a
+// summary of its delivery.
+func (s *stream) BroadcastSummary(e event.Event) (Summary, error) {
+	e.Stream = s.name
+	return s.broker.BroadcastSummary(e)
+}
+
+// multiplex returns the FrameWriter used to write the events of logical streams to the client
+// making the request, starting from the stream cursors it reconnected with. Streams written as
+// newline-delimited JSON are not multiplexed, as each event includes its stream.
+func multiplex(enc FrameWriter, r *http.Request, contentType string) FrameWriter {
+	if contentType == contentTypeNDJSON {
+		return enc
+	}
+
+	cursors, ok := streamCursors(lastEventID(r))
+
+	if !ok {
+		cursors = url.Values{}
+	}
+
+	return &multiplexer{FrameWriter: enc, cursors: cursors, multiplexed: ok}
+}
+
+// streamCursors parses the identifier of the last event received by a client whose connection
+// carried several logical streams, returning the last event identifier of each stream.
+func streamCursors(id string) (url.Values, bool) {
+	if !strings.HasPrefix(id, streamCursorPrefix) {
+		return nil, false
+	}
+
+	cursors, err := url.ParseQuery(strings.TrimPrefix(id, streamCursorPrefix))
+
+	if err != nil || len(cursors) == 0 {
+		return nil, false
+	}
+
+	return cursors, true
+}
+
+// Encode writes the event to the stream, prefixing its type with the name of its logical stream &
+// replacing its identifier with the cursors of every stream once the connection has carried one.
+func (m *multiplexer) Encode(e event.Event) error {
+	if e.Stream != "" {
+		m.multiplexed = true
+
+		if e.Type == "" {
+			e.Type = "message"
+		}
+
+		e.Type = e.Stream + ":" + e.Type
+	}
+
+	if e.ID == "" {
+		return m.FrameWriter.Encode(e)
+	}
+
+	previous := m.cursors[e.Stream]
+	m.cursors.Set(e.Stream, e.ID)
+
+	if m.multiplexed {
+		e.ID = streamCursorPrefix + m.cursors.Encode()
+	}
+
+	err := m.FrameWriter.Encode(e)
+
+	// Leave the cursor where it was if the event was not written.
+	if err != nil && previous == nil {
+		m.cursors.Del(e.Stream)
+	} else if err != nil {
+		m.cursors[e.Stream] = previous
+	}
+
+	return err
+}
+
+// streamEvents returns the stored events that a client whose connection carried several logical
+// streams has missed, given the last event identifier of each stream. Events are read from the oldest
+// cursor, skipping the events of each stream up to & including its own cursor.
+func (b *defaultBroker) streamEvents(cursors url.Values) ([]event.Event, error) {
+	var events []event.Event
+
+	// Stores return the events after a cursor oldest first, so the longest result
+	// contains the events missed on every stream.
+	for name := range cursors {
+		since, err := b.store.Since(cursors.Get(name))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(since) > len(events) {
+			events = since
+		}
+	}
+
+	last := make(map[string]int, len(cursors))
+
+	for i, e := range events {
+		if id, ok := cursors[e.Stream]; ok && len(id) > 0 && id[0] == e.ID {
+			last[e.Stream] = i
+		}
+	}
+
+	missed := make([]event.Event, 0, len(events))
+
+	for i, e := range events {
+		if cursor, ok := last[e.Stream]; ok && i <= cursor {
+			continue
+		}
+
+		missed = append(missed, e)
+	}
+
+	return missed, nil
+}
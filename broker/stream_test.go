@@ -0,0 +1,113 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Stream(t *testing.T) {
+	tt := []struct {
+		Name           string
+		Query          string
+		Header         string
+		ExpectedOutput string
+	}{
+		{
+			Name:  "It should identify events by the cursor of each stream",
+			Query: "?since=2020-01-01T00:00:00Z",
+			ExpectedOutput: "id: 1\ndata: a\n\n" +
+				"id: streams:=1&notifications=2\nevent: notifications:message\ndata: b\n\n" +
+				"id: streams:=1&chat=3&notifications=2\nevent: chat:message\ndata: c\n\n" +
+				"id: streams:=1&chat=3&notifications=4\nevent: notifications:message\ndata: d\n\n" +
+				"id: streams:=1&chat=5&notifications=4\nevent: chat:message\ndata: e\n\n",
+		},
+		{
+			Name:   "It should replay each stream from its own cursor",
+			Header: "streams:chat=3&notifications=2",
+			ExpectedOutput: "id: streams:chat=3&notifications=4\nevent: notifications:message\ndata: d\n\n" +
+				"id: streams:chat=5&notifications=4\nevent: chat:message\ndata: e\n\n",
+		},
+		{
+			Name:           "It should replay streams without a cursor from the oldest cursor",
+			Header:         "streams:notifications=4",
+			ExpectedOutput: "id: streams:chat=5&notifications=4\nevent: chat:message\ndata: e\n\n",
+		},
+		{
+			Name:   "It should replay from a single event identifier",
+			Header: "3",
+			ExpectedOutput: "id: streams:notifications=4\nevent: notifications:message\ndata: d\n\n" +
+				"id: streams:chat=5&notifications=4\nevent: chat:message\ndata: e\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil, broker.WithStore(store.NewMemory(10)))
+			defer brk.Close()
+
+			start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+			events := []event.Event{
+				{Data: []byte("a")},
+				{Stream: "notifications", Data: []byte("b")},
+				{Stream: "chat", Data: []byte("c")},
+				{Stream: "notifications", Data: []byte("d")},
+				{Stream: "chat", Data: []byte("e")},
+			}
+
+			for i, e := range events {
+				e.ID = strconv.Itoa(i + 1)
+				e.Timestamp = start.Add(time.Minute * time.Duration(i))
+				assert.NoError(t, brk.BroadcastEvent(e))
+			}
+
+			w := &FlushRecorder{header: http.Header{}}
+			r := httptest.NewRequest("GET", "/connect"+tc.Query, nil)
+
+			if tc.Header != "" {
+				r.Header.Set("Last-Event-ID", tc.Header)
+			}
+
+			go brk.ClientHandler(w, r)
+			<-time.After(time.Millisecond * 500)
+
+			assert.Equal(t, tc.ExpectedOutput, w.String())
+		})
+	}
+}
+
+func TestBroker_StreamPublisher(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil)
+	defer brk.Close()
+
+	c := client.NewWithOptions(time.Second, 3, "test", client.WithQueueSize(10))
+	assert.NoError(t, brk.Subscribe(c))
+
+	notifications := brk.Stream("notifications")
+
+	assert.NoError(t, notifications.Broadcast([]byte("hello")))
+	assert.NoError(t, notifications.BroadcastTo("test", []byte("hi")))
+	assert.NoError(t, notifications.BroadcastEvent(event.Event{Stream: "chat", Data: []byte("replaced")}))
+
+	for _, expected := range []string{"hello", "hi", "replaced"} {
+		select {
+		case <-c.Ready():
+		case <-time.After(time.Second):
+		}
+
+		e, ok := c.Next()
+
+		if assert.True(t, ok) {
+			assert.Equal(t, expected, string(e.Data))
+			assert.Equal(t, "notifications", e.Stream)
+		}
+	}
+}
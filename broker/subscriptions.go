@@ -0,0 +1,168 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/davidsbond/sse/client"
+)
+
+type (
+	// The SubscriptionChange type describes topics to add to & remove from a client's
+	// subscriptions. It is the body of requests to the broker's SubscriptionHandler.
+	SubscriptionChange struct {
+		Subscribe   []string `json:"subscribe,omitempty"`   // The topics to subscribe the client to.
+		Unsubscribe []string `json:"unsubscribe,omitempty"` // The topics to unsubscribe the client from.
+	}
+
+	// The Subscriptions type describes the topics a client is subscribed to. It is the body
+	// of responses from the broker's SubscriptionHandler.
+	Subscriptions struct {
+		Topics []string `json:"topics"` // The topics the client is subscribed to.
+	}
+)
+
+// UpdateSubscriptions changes the topics the connected client with the given id is subscribed
+// to, returning its new topics. The change is applied atomically, so no event broadcast to a
+// topic the client remains subscribed to is missed while the change is made. If subscriptions are
+// persisted, the new topics are recorded, see the broker.WithPersistentSubscriptions method. If no
+// such client is connected, an error is returned.
+func (b *defaultBroker) UpdateSubscriptions(id string, change SubscriptionChange) ([]string, error) {
+	item, ok := b.clients.Load(id)
+
+	if !ok {
+		return nil, fmt.Errorf("no client with id %v exists", id)
+	}
+
+	c, ok := item.(*client.Client)
+
+	if !ok {
+		b.removeClient(id, "")
+		return nil, errors.New("client is malformed, disconnecting")
+	}
+
+	var added []string
+
+	// Newly subscribed topics may be delta encoded or have retained events, in which case the client
+	// needs their current documents. These are written once the lock is released, see the
+	// deltaEncoder.subscribe method.
+	defer func() {
+		b.deltas.subscribe(c, added)
+		b.subscribeRetained(c, added)
+		b.saveSubscription(c)
+	}()
+
+	b.topicsMux.Lock()
+	defer b.topicsMux.Unlock()
+
+	// The client may have disconnected before we acquired the lock, in which case
+	// its topics have already been removed.
+	if !b.connected(c) {
+		return nil, fmt.Errorf("no client with id %v exists", id)
+	}
+
+	current := c.Topics()
+	topics := change.Apply(current)
+
+	for _, topic := range topics {
+		if contains(current, topic) {
+			continue
+		}
+
+		added = append(added, topic)
+		group, ok := b.topics[topic]
+
+		if !ok {
+			group = newFanout(b.shards, b.clock)
+			b.topics[topic] = group
+		}
+
+		group.add(c)
+	}
+
+	for _, topic := range current {
+		group, ok := b.topics[topic]
+
+		if !ok || contains(topics, topic) {
+			continue
+		}
+
+		group.remove(c)
+
+		if group.len() == 0 {
+			delete(b.topics, topic)
+		}
+	}
+
+	c.SetTopics(topics...)
+
+	return topics, nil
+}
+
+// SubscriptionHandler is an HTTP handler that allows a connected client to change the topics it
+// is subscribed to without reconnecting. The client is identified using the 'id' query parameter,
+// and the request body is a JSON encoded SubscriptionChange. The response body is a JSON encoded
+// Subscriptions describing the client's new topics. This method should be registered to an endpoint
+// of your choosing, protected in the same way as the ClientHandler.
+//
+// Example using Mux (https://github.com/gorilla/mux)
+//
+// r := mux.NewRouter()
+// r.HandleFunc("/subscriptions", broker.SubscriptionHandler).Methods("PATCH")
+//
+// http.ListenAndServe(":8080", r)
+func (b *defaultBroker) SubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	if !b.authorize(w, r) {
+		return
+	}
+
+	var change SubscriptionChange
+
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		b.httpError(w, r, CodeInvalidSubscription, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := b.checkAdmin(r, change.Subscribe); err != nil {
+		b.subscriptionError(w, r, err)
+		return
+	}
+
+	topics, err := b.UpdateSubscriptions(r.URL.Query().Get("id"), change)
+
+	if err != nil {
+		b.httpError(w, r, CodeUnknownClient, err, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Subscriptions{Topics: topics})
+}
+
+// Apply returns the topics that result from making the change to the given topics, in the
+// order they were first subscribed to.
+func (change SubscriptionChange) Apply(topics []string) []string {
+	out := make([]string, 0, len(topics)+len(change.Subscribe))
+
+	for _, topic := range append(append([]string(nil), topics...), change.Subscribe...) {
+		if topic == "" || contains(out, topic) || contains(change.Unsubscribe, topic) {
+			continue
+		}
+
+		out = append(out, topic)
+	}
+
+	return out
+}
+
+func contains(topics []string, topic string) bool {
+	for _, t := range topics {
+		if t == topic {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package broker_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_SubscriptionHandler(t *testing.T) {
+	tt := []struct {
+		Query          string
+		Body           string
+		ExpectedCode   broker.ErrorCode
+		ExpectedTopics []string
+		ExpectedEvents []string
+	}{
+		{
+			Query:          "?id=test",
+			Body:           `{"subscribe": ["b", "c"], "unsubscribe": ["a"]}`,
+			ExpectedTopics: []string{"b", "c"},
+			ExpectedEvents: []string{"b", "c"},
+		},
+		{
+			Query:          "?id=test",
+			Body:           `{"subscribe": ["a"]}`,
+			ExpectedTopics: []string{"a"},
+			ExpectedEvents: []string{"a"},
+		},
+		{
+			Query:          "?id=test",
+			Body:           `{"unsubscribe": ["a"]}`,
+			ExpectedTopics: []string{},
+		},
+		{
+			Query:          "?id=unknown",
+			Body:           `{"subscribe": ["b"]}`,
+			ExpectedCode:   broker.CodeUnknownClient,
+			ExpectedEvents: []string{"a"},
+		},
+		{
+			Query:          "?id=test",
+			Body:           `{`,
+			ExpectedCode:   broker.CodeInvalidSubscription,
+			ExpectedEvents: []string{"a"},
+		},
+	}
+
+	for _, tc := range tt {
+		var received *broker.Error
+
+		handler := func(w http.ResponseWriter, r *http.Request, err error) {
+			received, _ = err.(*broker.Error)
+		}
+
+		b := broker.New(time.Second, 3, handler)
+		stream := ssetest.NewStreamRecorder()
+
+		// Connect to the broker, give it 1 second to create the
+		// client
+		go b.ClientHandler(stream, stream.NewRequest("GET", "/connect?id=test&topic=a", nil))
+		<-time.Tick(time.Second)
+
+		w := httptest.NewRecorder()
+		b.SubscriptionHandler(w, httptest.NewRequest("PATCH", "/subscriptions"+tc.Query, strings.NewReader(tc.Body)))
+
+		if tc.ExpectedCode != "" {
+			if assert.NotNil(t, received) {
+				assert.Equal(t, tc.ExpectedCode, received.Code)
+			}
+		} else {
+			var subscriptions broker.Subscriptions
+
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&subscriptions))
+			assert.Equal(t, tc.ExpectedTopics, subscriptions.Topics)
+		}
+
+		// Only events for the client's new topics should be received.
+		for _, topic := range []string{"a", "b", "c"} {
+			b.BroadcastTopic(topic, []byte(topic))
+		}
+
+		<-time.After(time.Millisecond * 100)
+		events, err := stream.Events()
+
+		if assert.NoError(t, err) && assert.Len(t, events, len(tc.ExpectedEvents)) {
+			for i, e := range events {
+				assert.Equal(t, tc.ExpectedEvents[i], string(e.Data))
+			}
+		}
+
+		assert.Len(t, b.Stats().Topics, len(tc.ExpectedEvents))
+
+		stream.Close()
+		b.Close()
+	}
+}
@@ -0,0 +1,75 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Summary type describes the outcome of broadcasting an event, so that callers can log how
+	// effective a broadcast was without parsing errors. See the BroadcastSummary method.
+	Summary struct {
+		Delivered int           // The number of clients the event was written to.
+		Failed    int           // The number of clients the event could not be written to.
+		Skipped   int           // The number of clients subscribed to the event's topic that were outside its audience or group, or excluded from it.
+		Duplicate bool          // Whether the event was discarded as a duplicate, see the broker.WithDeduplication & broker.WithIdempotencyWindow methods.
+		Duration  time.Duration // How long the broadcast took.
+	}
+)
+
+// BroadcastSummary writes the given event in the same way as the BroadcastEvent method, returning a
+// summary of its delivery alongside any error. For events that are split into chunks, the number of
+// clients delivered to & failed are those of the final chunk written.
+func (b *defaultBroker) BroadcastSummary(e event.Event) (Summary, error) {
+	if !b.limiter.allow(b.clock.Now()) {
+		return Summary{}, ErrRateLimited
+	}
+
+	if err := b.admit(); err != nil {
+		return Summary{}, err
+	}
+
+	e, err := b.intercept(e)
+
+	if err == ErrDropEvent {
+		return Summary{}, nil
+	} else if err != nil {
+		return Summary{}, err
+	}
+
+	group, err := b.group(e)
+
+	if err != nil {
+		return Summary{}, err
+	}
+
+	summary, err := b.broadcastSummary(group, e, nil)
+
+	// Clients outside of the event's audience or group are those subscribed to its topic that
+	// were not selected.
+	if e.Audience != "" || e.Group != "" {
+		if skipped := b.subscribers(e.Topic) - group.len(); skipped > 0 {
+			summary.Skipped += skipped
+		}
+	}
+
+	return summary, err
+}
+
+// subscribers returns the number of clients subscribed to the topic, or the number of connected
+// clients if 'topic' is blank.
+func (b *defaultBroker) subscribers(topic string) int {
+	if topic == "" {
+		return b.all.len()
+	}
+
+	b.topicsMux.RLock()
+	defer b.topicsMux.RUnlock()
+
+	if group, ok := b.topics[topic]; ok {
+		return group.len()
+	}
+
+	return 0
+}
@@ -0,0 +1,68 @@
+package broker_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_BroadcastSummary(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Events   []event.Event
+		Expected broker.Summary
+	}{
+		{
+			Name:     "It should count the clients an event was delivered to",
+			Events:   []event.Event{{Topic: "news", Data: []byte("hello")}},
+			Expected: broker.Summary{Delivered: 2},
+		},
+		{
+			Name:     "It should count the subscribers outside of an event's audience",
+			Events:   []event.Event{{Topic: "news", Audience: "role=admin", Data: []byte("hello")}},
+			Expected: broker.Summary{Delivered: 1, Skipped: 1},
+		},
+		{
+			Name:     "It should report duplicate events",
+			Events:   []event.Event{{Topic: "news", Data: []byte("hello")}, {Topic: "news", Data: []byte("hello")}},
+			Expected: broker.Summary{Duplicate: true},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil, broker.WithDeduplication(bytes.Equal))
+			defer b.Close()
+
+			admin := ssetest.NewClient("admin", client.WithTopics("news"), client.WithQueueSize(10), client.WithMetadata(map[string]string{"role": "admin"}))
+			defer admin.Close()
+
+			user := ssetest.NewClient("user", client.WithTopics("news"), client.WithQueueSize(10), client.WithMetadata(map[string]string{"role": "user"}))
+			defer user.Close()
+
+			assert.NoError(t, b.Subscribe(admin.Client))
+			assert.NoError(t, b.Subscribe(user.Client))
+
+			var (
+				summary broker.Summary
+				err     error
+			)
+
+			for _, e := range tc.Events {
+				summary, err = b.BroadcastSummary(e)
+				assert.NoError(t, err)
+			}
+
+			assert.True(t, summary.Duration > 0)
+
+			summary.Duration = 0
+			assert.Equal(t, tc.Expected, summary)
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package broker
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/store"
+)
+
+type (
+	// SystemEventType describes a change in the lifecycle of the broker or its clients.
+	SystemEventType string
+
+	// The SystemEvent type describes a change in the lifecycle of the broker or its clients, so that
+	// operators can forward them to logs or metrics. See the broker's OnSystemEvent method.
+	SystemEvent struct {
+		Type     SystemEventType  `json:"type"`                // What happened.
+		ClientID string           `json:"client_id,omitempty"` // The client the event concerns, if any.
+		Topics   []string         `json:"topics,omitempty"`    // The topics of the client the event concerns, if any.
+		EventID  string           `json:"event_id,omitempty"`  // The stored event the system event concerns, if any.
+		Reason   DisconnectReason `json:"reason,omitempty"`    // Why the client was disconnected or evicted, if it was.
+		Time     time.Time        `json:"time"`                // When it happened.
+	}
+
+	// The systemBus type dispatches system events to the functions listening for them. Events are
+	// dispatched in order on a single goroutine, so that emitting them never blocks the broker.
+	systemBus struct {
+		mux       sync.RWMutex
+		listeners map[int]func(SystemEvent)
+		next      int
+		queue     chan SystemEvent
+		clock     clock.Clock
+		start     sync.Once
+		done      chan struct{}
+		closed    chan struct{}
+		closeOnce sync.Once
+	}
+)
+
+const (
+	// SystemClientConnected is emitted when a client is subscribed to the broker.
+	SystemClientConnected SystemEventType = "client_connected"
+
+	// SystemClientDisconnected is emitted when a client is unsubscribed from the broker. Its reason
+	// describes why, see the broker's OnClientDisconnect method.
+	SystemClientDisconnected SystemEventType = "client_disconnected"
+
+	// SystemClientEvicted is emitted when a client is disconnected because it exceeded its error
+	// tolerance, stopped sending pings or used too much of the broker's memory budget. It is followed
+	// by SystemClientDisconnected.
+	SystemClientEvicted SystemEventType = "client_evicted"
+
+	// SystemStoreTrimmed is emitted when the broker's store discards an event to make space for new
+	// ones. Only stores that implement the store.TrimNotifier interface report this.
+	SystemStoreTrimmed SystemEventType = "store_trimmed"
+
+	// SystemUpstreamReconnected is emitted when the broker publishes to its collector successfully
+	// after failing to, see the broker.WithCollector method.
+	SystemUpstreamReconnected SystemEventType = "upstream_reconnected"
+
+	// SystemUpstreamRejected is emitted when the broker's collector rejects an event with a 4xx status
+	// code other than 429, so the event is dropped rather than retried, see the broker.WithCollector
+	// method.
+	SystemUpstreamRejected SystemEventType = "upstream_rejected"
+
+	// SystemPublishQueueFull is emitted when an event is dispatched to a topic whose queue is full,
+	// see the broker's Dispatch method.
+	SystemPublishQueueFull SystemEventType = "publish_queue_full"
+
+	// SystemMemoryHigh is emitted when the memory used by buffered events reaches the high-water mark
+	// of the broker's memory budget, see the broker.WithMemoryBudget method.
+	SystemMemoryHigh SystemEventType = "memory_high"
+
+	// SystemMemoryExceeded is emitted when the memory used by buffered events exceeds the broker's
+	// memory budget, before the budget's action is applied.
+	SystemMemoryExceeded SystemEventType = "memory_exceeded"
+
+	// The number of system events waiting to be dispatched before new ones are dropped.
+	systemQueueSize = 1024
+
+	// The prefix of the type of events broadcast to the admin topic.
+	systemEventPrefix = "sse:"
+)
+
+// WithAdminTopic configures the broker to broadcast each of its system events to the given topic,
+// so that dashboards can follow the broker's activity using a normal client. Each event's type is
+// the system event's type prefixed with 'sse:', such as 'sse:client_connected', and its data is the
+// SystemEvent encoded as JSON. The events are written directly to the topic's subscribers, so they
+// are not stored or forwarded to a collector, & are dropped if the subscribers cannot keep up. The
+// connections of the topic's own subscribers are not reported to it. Clients can only subscribe to
+// the topic over HTTP if allowed, see the broker.WithAdminAuthorizer method. If 'topic' is blank,
+// system events are not broadcast.
+func WithAdminTopic(topic string) Option {
+	return func(b *defaultBroker) {
+		b.adminTopic = topic
+	}
+}
+
+// OnSystemEvent registers a function that is called with each of the broker's system events, such as
+// clients connecting & disconnecting, returning a function that stops it being called. Functions are
+// called in order on a single goroutine, so should not block. If system events are emitted faster
+// than they are handled, new events are dropped.
+func (b *defaultBroker) OnSystemEvent(fn func(SystemEvent)) func() {
+	return b.system.listen(fn)
+}
+
+// listenSystem registers the listeners configured by the broker's options, once they have all been
+// applied.
+func (b *defaultBroker) listenSystem() {
+	if b.adminTopic != "" {
+		b.admin = make(chan event.Event, systemQueueSize)
+		go b.writeAdmin()
+
+		b.OnSystemEvent(func(se SystemEvent) {
+			// The connections of the admin topic's own subscribers are not reported to it, so
+			// that following the topic does not feed it.
+			if contains(se.Topics, b.adminTopic) {
+				return
+			}
+
+			data, _ := json.Marshal(se)
+			e := event.Event{
+				Type:      systemEventPrefix + string(se.Type),
+				Topic:     b.adminTopic,
+				Data:      data,
+				Timestamp: se.Time,
+			}
+
+			b.sendAdmin(e)
+		})
+	}
+
+	if b.upstream != nil {
+		b.upstream.reconnect = func() {
+			b.system.emit(SystemEvent{Type: SystemUpstreamReconnected, Time: b.clock.Now()})
+		}
+
+		b.upstream.rejected = func(e event.Event) {
+			b.system.emit(SystemEvent{Type: SystemUpstreamRejected, EventID: e.ID, Time: b.clock.Now()})
+		}
+	}
+
+	if n, ok := b.store.(store.TrimNotifier); ok {
+		n.OnTrim(func(e event.Event) {
+			b.system.emit(SystemEvent{Type: SystemStoreTrimmed, EventID: e.ID, Time: b.clock.Now()})
+		})
+	}
+}
+
+// sendAdmin queues the event to be written to the clients subscribed to the admin topic. The event
+// is dropped rather than blocking the caller, such as the system event bus, if the topic's
+// subscribers cannot keep up.
+func (b *defaultBroker) sendAdmin(e event.Event) {
+	select {
+	case b.admin <- e:
+	default:
+	}
+}
+
+// writeAdmin writes each queued event to the clients subscribed to the admin topic until the broker
+// is closed. Events are written directly to the topic's subscribers, so they are not stored,
+// forwarded to a collector or counted against the broker's limits like published events.
+func (b *defaultBroker) writeAdmin() {
+	for {
+		select {
+		case e := <-b.admin:
+			b.topicsMux.RLock()
+			group, ok := b.topics[b.adminTopic]
+			b.topicsMux.RUnlock()
+
+			if ok {
+				group.broadcast(e, nil)
+			}
+		case <-b.closed:
+			return
+		}
+	}
+}
+
+// emitClient emits a system event concerning the client.
+func (b *defaultBroker) emitClient(t SystemEventType, c *client.Client) {
+	b.system.emit(SystemEvent{Type: t, ClientID: c.ID(), Topics: c.Topics(), Time: b.clock.Now()})
+}
+
+// emitDisconnect emits a SystemClientDisconnected event for the client, carrying the reason it
+// was removed.
+func (b *defaultBroker) emitDisconnect(c *client.Client, reason DisconnectReason) {
+	b.system.emit(SystemEvent{
+		Type:     SystemClientDisconnected,
+		ClientID: c.ID(),
+		Topics:   c.Topics(),
+		Reason:   reason,
+		Time:     b.clock.Now(),
+	})
+}
+
+// evict forcibly disconnects a client for the given reason, such as exceeding its error tolerance.
+func (b *defaultBroker) evict(c *client.Client, reason DisconnectReason) {
+	if b.connected(c) {
+		b.system.emit(SystemEvent{
+			Type:     SystemClientEvicted,
+			ClientID: c.ID(),
+			Topics:   c.Topics(),
+			Reason:   reason,
+			Time:     b.clock.Now(),
+		})
+	}
+
+	b.disconnect(c, reason)
+}
+
+func newSystemBus(clk clock.Clock) *systemBus {
+	return &systemBus{
+		listeners: make(map[int]func(SystemEvent)),
+		queue:     make(chan SystemEvent, systemQueueSize),
+		clock:     clk,
+		done:      make(chan struct{}),
+		closed:    make(chan struct{}),
+	}
+}
+
+// listen registers the function, starting the dispatching goroutine if this is the first listener.
+func (s *systemBus) listen(fn func(SystemEvent)) func() {
+	s.mux.Lock()
+	id := s.next
+	s.next++
+	s.listeners[id] = fn
+	s.mux.Unlock()
+
+	s.start.Do(func() { go s.run() })
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			s.mux.Lock()
+			delete(s.listeners, id)
+			s.mux.Unlock()
+		})
+	}
+}
+
+// emit queues the event to be dispatched, unless nothing is listening or the queue is full.
+func (s *systemBus) emit(se SystemEvent) {
+	s.mux.RLock()
+	listening := len(s.listeners) > 0
+	s.mux.RUnlock()
+
+	if !listening {
+		return
+	}
+
+	if se.Time.IsZero() {
+		se.Time = s.clock.Now()
+	}
+
+	select {
+	case s.queue <- se:
+	default:
+	}
+}
+
+func (s *systemBus) run() {
+	defer close(s.closed)
+
+	for {
+		select {
+		case se := <-s.queue:
+			s.dispatch(se)
+		case <-s.done:
+			s.drain()
+			return
+		}
+	}
+}
+
+// drain dispatches the events that were queued before the bus was closed, such as the
+// SystemClientDisconnected events emitted as the broker closes.
+func (s *systemBus) drain() {
+	for {
+		select {
+		case se := <-s.queue:
+			s.dispatch(se)
+		default:
+			return
+		}
+	}
+}
+
+func (s *systemBus) dispatch(se SystemEvent) {
+	s.mux.RLock()
+	ids := make([]int, 0, len(s.listeners))
+
+	for id := range s.listeners {
+		ids = append(ids, id)
+	}
+
+	s.mux.RUnlock()
+
+	// Call listeners in the order they were registered.
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		s.mux.RLock()
+		fn, ok := s.listeners[id]
+		s.mux.RUnlock()
+
+		if ok {
+			fn(se)
+		}
+	}
+}
+
+// close stops dispatching events, waiting for those already queued to be dispatched.
+func (s *systemBus) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+
+	// If nothing ever listened, the dispatching goroutine was never started.
+	started := true
+	s.start.Do(func() { started = false })
+
+	if started {
+		<-s.closed
+	}
+}
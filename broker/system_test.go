@@ -0,0 +1,174 @@
+package broker_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_OnSystemEvent(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Options  []broker.Option
+		Action   func(b broker.Broker)
+		Expected []broker.SystemEventType
+	}{
+		{
+			Name: "It should report clients connecting & disconnecting",
+			Action: func(b broker.Broker) {
+				c := client.NewWithOptions(time.Second, 3, "test", client.WithTopics("news"))
+
+				b.Subscribe(c)
+				b.Unsubscribe(c)
+			},
+			Expected: []broker.SystemEventType{broker.SystemClientConnected, broker.SystemClientDisconnected},
+		},
+		{
+			Name:    "It should report events trimmed from the store",
+			Options: []broker.Option{broker.WithStore(store.NewMemory(1))},
+			Action: func(b broker.Broker) {
+				b.BroadcastEvent(event.Event{ID: "1", Data: []byte("one")})
+				b.BroadcastEvent(event.Event{ID: "2", Data: []byte("two")})
+			},
+			Expected: []broker.SystemEventType{broker.SystemStoreTrimmed},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil, tc.Options...)
+			defer b.Close()
+
+			events := make(chan broker.SystemEvent, 10)
+			stop := b.OnSystemEvent(func(se broker.SystemEvent) { events <- se })
+			defer stop()
+
+			tc.Action(b)
+
+			for _, expected := range tc.Expected {
+				select {
+				case se := <-events:
+					assert.Equal(t, expected, se.Type)
+					assert.False(t, se.Time.IsZero())
+				case <-time.After(time.Second):
+					t.Fatalf("timed out waiting for %v", expected)
+				}
+			}
+		})
+	}
+}
+
+func TestBroker_OnSystemEventStop(t *testing.T) {
+	b := broker.New(time.Second, 3, nil)
+	defer b.Close()
+
+	events := make(chan broker.SystemEvent, 10)
+	stop := b.OnSystemEvent(func(se broker.SystemEvent) { events <- se })
+	stop()
+
+	b.Subscribe(client.New(time.Second, 3, "test"))
+
+	select {
+	case se := <-events:
+		t.Fatalf("unexpected system event %v", se.Type)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestBroker_AdminTopic(t *testing.T) {
+	b := broker.New(time.Second, 3, nil, broker.WithAdminTopic("admin"))
+	defer b.Close()
+
+	events, cancel := b.SubscribeLocal("admin")
+	defer cancel()
+
+	b.Subscribe(client.NewWithOptions(time.Second, 3, "test", client.WithTopics("news")))
+
+	for {
+		select {
+		case e := <-events:
+			var se broker.SystemEvent
+
+			assert.NoError(t, json.Unmarshal(e.Data, &se))
+			assert.Equal(t, "sse:"+string(se.Type), e.Type)
+
+			// The local subscriber's own connection is not reported to the admin topic.
+			assert.Equal(t, "test", se.ClientID)
+			assert.Equal(t, broker.SystemClientConnected, se.Type)
+			assert.Equal(t, []string{"news"}, se.Topics)
+			return
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for system events")
+		}
+	}
+}
+
+func TestBroker_AdminTopicBypassesPublishing(t *testing.T) {
+	memory := store.NewMemory(10)
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	b := broker.New(time.Second, 3, nil,
+		broker.WithClock(clk),
+		broker.WithAdminTopic("admin"),
+		broker.WithStore(memory),
+		broker.WithBackpressure(broker.BackpressureLimit{MaxPending: 1, Interval: time.Second}),
+	)
+	defer b.Close()
+
+	admin := ssetest.NewClient("dashboard", client.WithQueueSize(10), client.WithTopics("admin"))
+	defer admin.Close()
+
+	assert.NoError(t, b.Subscribe(admin.Client))
+
+	// Saturate the broker, so that published events are rejected under backpressure.
+	slow := client.NewWithOptions(time.Second, 3, "slow", client.WithQueueSize(10))
+	assert.NoError(t, b.Subscribe(slow))
+	assert.NoError(t, b.Broadcast([]byte("hello")))
+	clk.Advance(time.Second)
+
+	assert.True(t, errors.Is(b.Broadcast([]byte("hello")), broker.ErrBackpressure))
+	assert.NoError(t, b.Subscribe(client.NewWithOptions(time.Second, 3, "test")))
+
+	events, err := admin.Wait(3, time.Second)
+	assert.NoError(t, err)
+
+	// The dashboard's own connection is not reported, while system events are still delivered
+	// under backpressure & are not stored.
+	var connected []string
+
+	for _, e := range events {
+		var se broker.SystemEvent
+
+		if e.Type == "sse:client_connected" && assert.NoError(t, json.Unmarshal(e.Data, &se)) {
+			connected = append(connected, se.ClientID)
+		}
+	}
+
+	assert.Equal(t, []string{"slow", "test"}, connected)
+
+	stored, err := memory.Since("")
+
+	assert.NoError(t, err)
+	assert.Len(t, stored, 1)
+}
+
+func TestBroker_AdminTopicAuthorization(t *testing.T) {
+	b := broker.New(time.Second, 3, nil, broker.WithAdminTopic("ops"))
+	defer b.Close()
+
+	// System events carry client ids, so clients cannot follow them without being allowed to.
+	w := ssetest.NewStreamRecorder()
+	b.ClientHandler(w, w.NewRequest(http.MethodGet, "/connect?id=test&topic=ops", nil))
+
+	assert.Equal(t, http.StatusForbidden, w.Code())
+	assert.Equal(t, 0, b.Stats().Clients)
+}
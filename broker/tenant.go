@@ -0,0 +1,161 @@
+package broker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/store"
+)
+
+type (
+	// The TenantQuota type contains the limits applied to each tenant of the broker.
+	TenantQuota struct {
+		MaxClients         int     // If non-zero, the number of clients that can be connected to each tenant at once.
+		MaxEventsPerSecond float64 // If non-zero, the rate at which events can be published to each tenant.
+		Burst              int     // The number of events that can be published at once before the rate applies. Defaults to one.
+	}
+
+	// TenantStore is a function that returns the store to use for the tenant with the given name.
+	TenantStore func(tenant string) store.Store
+
+	// The tenants type holds the tenants of a broker, which are created when first requested.
+	tenants struct {
+		mux     sync.Mutex
+		brokers map[string]*defaultBroker
+		quota   TenantQuota
+		store   TenantStore
+	}
+
+	// The rateLimiter type is a token bucket that limits the rate of publishing events.
+	rateLimiter struct {
+		mux    sync.Mutex
+		rate   float64
+		burst  float64
+		tokens float64
+		last   time.Time
+	}
+)
+
+var (
+	// ErrTooManyClients is returned when subscribing a client to a tenant that has reached its
+	// maximum number of clients.
+	ErrTooManyClients = errors.New("the maximum number of clients are connected")
+
+	// ErrRateLimited is returned when publishing an event to a tenant that has exceeded its
+	// maximum rate of events.
+	ErrRateLimited = errors.New("the maximum rate of events has been exceeded")
+)
+
+// WithTenantQuota configures the limits applied to each tenant of the broker. See the
+// broker.Tenant method for details.
+func WithTenantQuota(q TenantQuota) Option {
+	return func(b *defaultBroker) {
+		b.tenants.quota = q
+	}
+}
+
+// WithTenantStore configures the store used by each tenant of the broker. Tenants do not share
+// the broker's store, as replaying events to clients of another tenant would break isolation. If
+// 'fn' is nil, or returns nil, events published to tenants are not stored.
+func WithTenantStore(fn TenantStore) Option {
+	return func(b *defaultBroker) {
+		b.tenants.store = fn
+	}
+}
+
+// Tenant returns a broker scoped to the tenant with the given name, creating it if this is its
+// first use. Each tenant has its own clients, topics & statistics, and is configured using the
+// same options as the broker, except that it does not publish to a collector or join a cluster,
+// and uses the store returned by the TenantStore, see the broker.WithTenantStore method.
+// Connections & publishes to each tenant are limited by the TenantQuota, see the
+// broker.WithTenantQuota method. Tenants are closed when the broker is closed.
+func (b *defaultBroker) Tenant(name string) Broker {
+	b.tenants.mux.Lock()
+	defer b.tenants.mux.Unlock()
+
+	if tenant, ok := b.tenants.brokers[name]; ok {
+		return tenant
+	}
+
+	// Tenants are configured without starting the broker's background work, as they must not
+	// publish to its collector, join its cluster or share its store. Tenants are only reachable
+	// through the broker, so cannot be members of its cluster.
+	tenant := configure(b.timeout, b.tolerance, b.errorHandler, b.opts...)
+	tenant.store = nil
+
+	// Tenants push their statistics tagged with their name, so they can be told apart.
+	if tenant.statsdConfig.Address != "" {
+		tenant.statsdConfig = tenant.statsdConfig.withTag("tenant:" + name)
+	}
+
+	if b.tenants.store != nil {
+		tenant.store = b.tenants.store(name)
+	}
+
+	tenant.maxClients = b.tenants.quota.MaxClients
+
+	if b.tenants.quota.MaxEventsPerSecond > 0 {
+		tenant.limiter = newRateLimiter(b.tenants.quota.MaxEventsPerSecond, b.tenants.quota.Burst, tenant.clock.Now())
+	}
+
+	tenant.start()
+
+	if b.tenants.brokers == nil {
+		b.tenants.brokers = make(map[string]*defaultBroker)
+	}
+
+	b.tenants.brokers[name] = tenant
+
+	return tenant
+}
+
+// close closes every tenant.
+func (t *tenants) close() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	for name, tenant := range t.brokers {
+		tenant.Close()
+		delete(t.brokers, name)
+	}
+}
+
+func newRateLimiter(rate float64, burst int, now time.Time) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// allow returns true if an event can be published now, consuming a token.
+func (l *rateLimiter) allow(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	// Refill the bucket based on the time since it was last used.
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	l.last = now
+
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+
+	return true
+}
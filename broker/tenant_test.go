@@ -0,0 +1,134 @@
+package broker_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_Tenant(t *testing.T) {
+	b := broker.New(time.Second, 3, nil)
+	defer b.Close()
+
+	acme := b.Tenant("acme")
+	other := b.Tenant("other")
+
+	assert.True(t, acme == b.Tenant("acme"))
+
+	// Clients with the same identifier can connect to different tenants.
+	c1 := ssetest.NewClient("test", client.WithQueueSize(10))
+	c2 := ssetest.NewClient("test", client.WithQueueSize(10))
+	defer c1.Close()
+	defer c2.Close()
+
+	assert.NoError(t, acme.Subscribe(c1.Client))
+	assert.NoError(t, other.Subscribe(c2.Client))
+
+	assert.NoError(t, acme.Broadcast([]byte("hello acme")))
+	<-time.After(time.Millisecond * 100)
+
+	assert.Len(t, c1.Events(), 1)
+	assert.Len(t, c2.Events(), 0)
+	assert.Equal(t, 1, acme.Stats().Clients)
+	assert.Equal(t, 0, b.Stats().Clients)
+}
+
+func TestBroker_TenantBackgroundWork(t *testing.T) {
+	var (
+		mux      sync.Mutex
+		requests []string
+	)
+
+	// Record the requests made to the broker's collector & the peers of its cluster.
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+
+		requests = append(requests, r.URL.Query().Get("op"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer peer.Close()
+
+	b := broker.New(time.Second, 3, nil,
+		broker.WithCollector(peer.URL),
+		broker.WithCluster(broker.ClusterConfig{
+			Advertise: "http://localhost:0",
+			Peers:     []string{peer.URL},
+			Interval:  time.Hour,
+			Secret:    "secret",
+		}),
+	)
+	defer b.Close()
+
+	<-time.After(time.Millisecond * 100)
+
+	// Creating tenants & publishing to them should not contact the collector or the cluster.
+	for _, name := range []string{"acme", "other", "third"} {
+		assert.NoError(t, b.Tenant(name).Broadcast([]byte("hello")))
+	}
+
+	<-time.After(time.Millisecond * 100)
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	assert.Equal(t, []string{"gossip"}, requests)
+}
+
+func TestBroker_WithTenantQuota(t *testing.T) {
+	tt := []struct {
+		Quota          broker.TenantQuota
+		Clients        int
+		Events         int
+		ExpectedCode   int
+		ExpectedClient error
+	}{
+		{Quota: broker.TenantQuota{MaxClients: 1}, Clients: 2, Events: 1, ExpectedCode: http.StatusOK, ExpectedClient: broker.ErrTooManyClients},
+		{Quota: broker.TenantQuota{MaxEventsPerSecond: 1, Burst: 2}, Clients: 1, Events: 3, ExpectedCode: http.StatusTooManyRequests},
+		{Quota: broker.TenantQuota{MaxEventsPerSecond: 1}, Clients: 1, Events: 1, ExpectedCode: http.StatusOK},
+		{Clients: 2, Events: 10, ExpectedCode: http.StatusOK},
+	}
+
+	for _, tc := range tt {
+		b := broker.New(time.Second, 3, nil, broker.WithTenantQuota(tc.Quota))
+		tenant := b.Tenant("acme")
+
+		var err error
+
+		for i := 0; i < tc.Clients; i++ {
+			c := ssetest.NewClient("", client.WithQueueSize(10))
+			defer c.Close()
+
+			err = tenant.Subscribe(c.Client)
+		}
+
+		assert.Equal(t, tc.ExpectedClient, err)
+
+		var code int
+
+		for i := 0; i < tc.Events; i++ {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/broadcast", bytes.NewBufferString("hello"))
+
+			tenant.EventHandler(w, r)
+			code = w.Code
+		}
+
+		assert.Equal(t, tc.ExpectedCode, code)
+
+		// Quotas only apply to tenants.
+		for i := 0; i < tc.Events; i++ {
+			assert.NoError(t, b.Broadcast([]byte("hello")))
+		}
+
+		b.Close()
+	}
+}
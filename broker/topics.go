@@ -0,0 +1,82 @@
+package broker
+
+import (
+	"net/http"
+	"strings"
+)
+
+type (
+	// SubscriptionParser is a function that returns the topics a client requests to subscribe to
+	// when it connects. If it returns an error, the client is rejected with a 400 status code & the
+	// CodeInvalidSubscription error code.
+	SubscriptionParser func(r *http.Request) ([]string, error)
+)
+
+const (
+	// The header that clients can list the topics they subscribe to in, separated by commas.
+	topicsHeader = "X-SSE-Topics"
+)
+
+// WithSubscriptionParser configures the function used to determine the topics that connecting
+// clients subscribe to, and the topics that history is returned for. Topics derived from the
+// request context take precedence, see the broker.WithClientFromContext method. If 'fn' is nil,
+// the broker.ParseTopics function is used.
+func WithSubscriptionParser(fn SubscriptionParser) Option {
+	return func(b *defaultBroker) {
+		b.topicParser = fn
+	}
+}
+
+// ParseTopics returns the topics requested by the client, combining those given using repeated
+// 'topic' query parameters, a comma separated 'topics' query parameter & a comma separated
+// 'X-SSE-Topics' header. Blank & repeated topics are discarded. This is the broker's default
+// SubscriptionParser, which never returns an error.
+func ParseTopics(r *http.Request) ([]string, error) {
+	query := r.URL.Query()
+	seen := make(map[string]bool)
+
+	var out []string
+
+	add := func(topics ...string) {
+		for _, topic := range topics {
+			topic = strings.TrimSpace(topic)
+
+			if topic == "" || seen[topic] {
+				continue
+			}
+
+			seen[topic] = true
+			out = append(out, topic)
+		}
+	}
+
+	add(query["topic"]...)
+
+	for _, list := range query["topics"] {
+		add(strings.Split(list, ",")...)
+	}
+
+	for _, list := range r.Header.Values(topicsHeader) {
+		add(strings.Split(list, ",")...)
+	}
+
+	return out, nil
+}
+
+// parseTopics returns the topics requested by the client using the configured parser. An error
+// wrapping ErrAdminTopic is returned if the client is not allowed to subscribe to the admin topic.
+func (b *defaultBroker) parseTopics(r *http.Request) ([]string, error) {
+	parse := ParseTopics
+
+	if b.topicParser != nil {
+		parse = b.topicParser
+	}
+
+	topics, err := parse(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return topics, b.checkAdmin(r, topics)
+}
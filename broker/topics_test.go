@@ -0,0 +1,108 @@
+package broker_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTopics(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Query    string
+		Header   string
+		Expected []string
+	}{
+		{
+			Name:     "It should parse repeated topic parameters",
+			Query:    "?topic=a&topic=b",
+			Expected: []string{"a", "b"},
+		},
+		{
+			Name:     "It should parse a comma separated topics parameter",
+			Query:    "?topics=a,%20b,,c",
+			Expected: []string{"a", "b", "c"},
+		},
+		{
+			Name:     "It should parse the topics header",
+			Header:   "a, b",
+			Expected: []string{"a", "b"},
+		},
+		{
+			Name:     "It should combine every source without repeating topics",
+			Query:    "?topic=a&topics=a,b",
+			Header:   "b,c",
+			Expected: []string{"a", "b", "c"},
+		},
+		{
+			Name: "It should return no topics when none are requested",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/connect"+tc.Query, nil)
+
+			if tc.Header != "" {
+				r.Header.Set("X-SSE-Topics", tc.Header)
+			}
+
+			topics, err := broker.ParseTopics(r)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Expected, topics)
+		})
+	}
+}
+
+func TestBroker_WithSubscriptionParser(t *testing.T) {
+	parser := func(r *http.Request) ([]string, error) {
+		if r.URL.Query().Get("channel") == "" {
+			return nil, errors.New("missing channel")
+		}
+
+		return []string{"channel:" + r.URL.Query().Get("channel")}, nil
+	}
+
+	tt := []struct {
+		Name          string
+		Query         string
+		ExpectsReject bool
+	}{
+		{
+			Name:  "It should subscribe clients to the parsed topics",
+			Query: "?channel=news",
+		},
+		{
+			Name:          "It should reject clients whose topics cannot be parsed",
+			ExpectsReject: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := broker.New(time.Second, 3, nil, broker.WithSubscriptionParser(parser))
+			defer b.Close()
+
+			w := ssetest.NewStreamRecorder()
+			defer w.Close()
+
+			go b.ClientHandler(w, w.NewRequest("GET", "/connect"+tc.Query, nil))
+			<-time.Tick(time.Millisecond * 100)
+
+			if tc.ExpectsReject {
+				assert.Equal(t, http.StatusBadRequest, w.Code())
+				assert.Equal(t, 0, b.Stats().Clients)
+				return
+			}
+
+			assert.Contains(t, b.Stats().Topics, "channel:news")
+		})
+	}
+}
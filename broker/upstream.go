@@ -0,0 +1,220 @@
+package broker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The upstream type forwards events from the broker to a central collector. This
+	// allows brokers that cannot accept inbound connections (such as those behind NAT
+	// or running on edge devices) to publish their events by dialing out instead.
+	upstream struct {
+		url       string
+		client    *http.Client
+		queue     chan event.Event
+		clock     clock.Clock
+		done      chan struct{}
+		closed    chan struct{}
+		once      sync.Once
+		reconnect func()            // Called when an event is sent after the collector was unreachable.
+		rejected  func(event.Event) // Called when the collector rejects an event, which is then dropped.
+	}
+)
+
+const (
+	// The number of events that can be waiting to be sent to the collector before new
+	// events are dropped.
+	upstreamQueueSize = 1024
+
+	// The minimum & maximum amount of time to wait between attempts to send an event
+	// to an unreachable collector.
+	upstreamMinBackoff = time.Millisecond * 100
+	upstreamMaxBackoff = time.Second * 30
+)
+
+var (
+	// errUpstreamRejected is the error returned when the collector responds with a status code
+	// indicating the event will never be accepted, so it is not retried.
+	errUpstreamRejected = errors.New("the collector rejected the event")
+)
+
+// WithCollector configures the broker to publish every broadcast event to the collector
+// at the given URL. The collector is expected to accept events in the same way as the
+// broker's EventHandler, so it can be another broker. Events are sent with the query
+// parameters & headers the EventHandler reads, such as 'topic', 'key' & 'priority', with
+// the event's ID as its 'Idempotency-Key' header. The EventHandler cannot set an event's
+// type or ID, so these are not forwarded. Events are sent in the background & are retried
+// while the collector is unreachable, responds with a 5xx status code or rate limits the
+// broker, until they are accepted or the broker is closed. Events rejected with any other
+// 4xx status code are dropped & a SystemUpstreamRejected event is emitted. If 'url' is
+// blank, this option does nothing.
+func WithCollector(url string) Option {
+	return func(b *defaultBroker) {
+		if url == "" {
+			return
+		}
+
+		b.collectorURL = url
+	}
+}
+
+func newUpstream(url string, client *http.Client, clk clock.Clock) *upstream {
+	u := &upstream{
+		url:    url,
+		client: client,
+		queue:  make(chan event.Event, upstreamQueueSize),
+		clock:  clk,
+		done:   make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+
+	go u.run()
+
+	return u
+}
+
+// publish queues the given event to be sent to the collector. If the queue is full,
+// the event is dropped and an error is returned.
+func (u *upstream) publish(e event.Event) error {
+	select {
+	case u.queue <- e:
+		return nil
+	default:
+		return fmt.Errorf("failed to publish to collector %v, queue is full", u.url)
+	}
+}
+
+func (u *upstream) run() {
+	defer close(u.closed)
+
+	for {
+		select {
+		case e := <-u.queue:
+			u.send(e)
+		case <-u.done:
+			return
+		}
+	}
+}
+
+// send posts the event to the collector, backing off exponentially between failed attempts
+// until the collector accepts or rejects it, or the upstream is closed.
+func (u *upstream) send(e event.Event) {
+	backoff := upstreamMinBackoff
+
+	for failed := false; ; failed = true {
+		err := u.post(e)
+
+		if err == nil {
+			if failed && u.reconnect != nil {
+				u.reconnect()
+			}
+
+			return
+		}
+
+		// Retrying an event the collector has rejected would block every event behind it.
+		if errors.Is(err, errUpstreamRejected) {
+			if u.rejected != nil {
+				u.rejected(e)
+			}
+
+			return
+		}
+
+		timer := u.clock.NewTimer(backoff)
+
+		select {
+		case <-timer.C():
+		case <-u.done:
+			timer.Stop()
+			return
+		}
+
+		if backoff *= 2; backoff > upstreamMaxBackoff {
+			backoff = upstreamMaxBackoff
+		}
+	}
+}
+
+func (u *upstream) post(e event.Event) error {
+	target, err := url.Parse(u.url)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUpstreamRejected, err)
+	}
+
+	target.RawQuery = upstreamQuery(target.Query(), e).Encode()
+
+	req, err := http.NewRequest(http.MethodPost, target.String(), bytes.NewReader(e.Data))
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUpstreamRejected, err)
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	if e.ID != "" {
+		req.Header.Set("Idempotency-Key", e.ID)
+	}
+
+	resp, err := u.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError, resp.StatusCode == http.StatusTooManyRequests:
+		return errors.New(resp.Status)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("%w: %v", errUpstreamRejected, resp.Status)
+	default:
+		return nil
+	}
+}
+
+// upstreamQuery adds the query parameters the EventHandler reads for the event to the query.
+func upstreamQuery(query url.Values, e event.Event) url.Values {
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	set("topic", e.Topic)
+	set("key", e.Key)
+	set("stream", e.Stream)
+	set("audience", e.Audience)
+	set("group", e.Group)
+
+	if e.Priority != event.PriorityNormal {
+		set("priority", e.Priority.String())
+	}
+
+	if e.Retain {
+		set("retain", "true")
+	}
+
+	for _, id := range e.Except {
+		query.Add("except", id)
+	}
+
+	return query
+}
+
+func (u *upstream) close() {
+	u.once.Do(func() { close(u.done) })
+	<-u.closed
+}
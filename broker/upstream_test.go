@@ -0,0 +1,259 @@
+package broker_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_WithCollector(t *testing.T) {
+	tt := []struct {
+		Data           []byte
+		CollectorCode  int
+		ExpectReceived bool
+	}{
+		{Data: []byte("hello world"), CollectorCode: http.StatusOK, ExpectReceived: true},
+		{Data: []byte("hello world"), CollectorCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tt {
+		received := make(chan []byte, 1)
+
+		// Create a collector that records the events it is sent.
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := ioutil.ReadAll(r.Body)
+			w.WriteHeader(tc.CollectorCode)
+
+			if tc.CollectorCode == http.StatusOK {
+				received <- data
+			}
+		}))
+
+		broker := broker.New(time.Second, 3, nil, broker.WithCollector(collector.URL))
+
+		assert.NoError(t, broker.Broadcast(tc.Data))
+
+		select {
+		case data := <-received:
+			assert.True(t, tc.ExpectReceived)
+			assert.Equal(t, tc.Data, data)
+		case <-time.After(time.Second):
+			assert.False(t, tc.ExpectReceived)
+		}
+
+		assert.NoError(t, broker.Close())
+		collector.Close()
+	}
+}
+
+func TestBroker_WithCollectorRetries(t *testing.T) {
+	tt := []struct {
+		Name          string
+		FirstCode     int
+		ExpectedCalls int32
+		ExpectRetried bool
+	}{
+		{
+			Name:          "It should drop events the collector rejects",
+			FirstCode:     http.StatusUnauthorized,
+			ExpectedCalls: 2,
+		},
+		{
+			Name:          "It should retry events while the collector fails",
+			FirstCode:     http.StatusServiceUnavailable,
+			ExpectedCalls: 3,
+			ExpectRetried: true,
+		},
+		{
+			Name:          "It should retry events while the collector rate limits the broker",
+			FirstCode:     http.StatusTooManyRequests,
+			ExpectedCalls: 3,
+			ExpectRetried: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var calls int32
+			received := make(chan string, 2)
+
+			collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := ioutil.ReadAll(r.Body)
+
+				if atomic.AddInt32(&calls, 1) == 1 {
+					w.WriteHeader(tc.FirstCode)
+					return
+				}
+
+				received <- string(data)
+			}))
+			defer collector.Close()
+
+			brk := broker.New(time.Second, 3, nil, broker.WithCollector(collector.URL))
+
+			rejected := make(chan broker.SystemEvent, 1)
+			brk.OnSystemEvent(func(se broker.SystemEvent) {
+				if se.Type == broker.SystemUpstreamRejected {
+					rejected <- se
+				}
+			})
+
+			assert.NoError(t, brk.BroadcastEvent(event.Event{ID: "1", Data: []byte("a")}))
+			assert.NoError(t, brk.BroadcastEvent(event.Event{ID: "2", Data: []byte("b")}))
+
+			expected := []string{"b"}
+
+			if tc.ExpectRetried {
+				expected = []string{"a", "b"}
+			} else {
+				select {
+				case se := <-rejected:
+					assert.Equal(t, "1", se.EventID)
+				case <-time.After(time.Second):
+					t.Fatal("the rejected event was not reported")
+				}
+			}
+
+			for _, data := range expected {
+				select {
+				case actual := <-received:
+					assert.Equal(t, data, actual)
+				case <-time.After(time.Second):
+					t.Fatalf("the collector did not receive %v", data)
+				}
+			}
+
+			assert.NoError(t, brk.Close())
+			assert.Equal(t, tc.ExpectedCalls, atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestBroker_WithCollectorAttributes(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer collector.Close()
+
+	brk := broker.New(time.Second, 3, nil, broker.WithCollector(collector.URL))
+	defer brk.Close()
+
+	assert.NoError(t, brk.BroadcastEvent(event.Event{
+		ID:       "1",
+		Topic:    "prices",
+		Key:      "price:AAPL",
+		Priority: event.PriorityHigh,
+		Retain:   true,
+		Except:   []string{"a", "b"},
+		Data:     []byte("100"),
+	}))
+
+	select {
+	case r := <-requests:
+		assert.Equal(t, "prices", r.URL.Query().Get("topic"))
+		assert.Equal(t, "price:AAPL", r.URL.Query().Get("key"))
+		assert.Equal(t, "high", r.URL.Query().Get("priority"))
+		assert.Equal(t, "true", r.URL.Query().Get("retain"))
+		assert.Equal(t, []string{"a", "b"}, r.URL.Query()["except"])
+		assert.Equal(t, "1", r.Header.Get("Idempotency-Key"))
+	case <-time.After(time.Second):
+		t.Fatal("the collector did not receive the event")
+	}
+}
+
+func TestBroker_WithCollectorIdempotency(t *testing.T) {
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// The collector sees the event's idempotency key before failing the first attempt under
+	// backpressure.
+	collector := broker.New(time.Second, 3, nil,
+		broker.WithClock(clk),
+		broker.WithIdempotencyWindow(time.Minute),
+		broker.WithBackpressure(broker.BackpressureLimit{MaxPending: 1, Resume: 1, Interval: time.Second}),
+	)
+	defer collector.Close()
+
+	c := client.NewWithOptions(time.Second, 3, "test", client.WithQueueSize(10))
+
+	assert.NoError(t, collector.Subscribe(c))
+	assert.NoError(t, collector.Broadcast([]byte("filler")))
+	clk.Advance(time.Second)
+
+	var attempts int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		collector.EventHandler(w, r)
+	}))
+	defer srv.Close()
+
+	brk := broker.New(time.Second, 3, nil, broker.WithCollector(srv.URL))
+	defer brk.Close()
+
+	assert.NoError(t, brk.BroadcastEvent(event.Event{ID: "1", Data: []byte("hello")}))
+
+	for atomic.LoadInt32(&attempts) == 0 {
+		<-time.After(time.Millisecond * 10)
+	}
+
+	// Once the backpressure is relieved, the retry should be published rather than
+	// acknowledged as a duplicate.
+	c.Next()
+	clk.Advance(time.Second)
+
+	e, ok := c.Next()
+
+	for i := 0; i < 40 && !ok; i++ {
+		<-time.After(time.Millisecond * 50)
+		e, ok = c.Next()
+	}
+
+	assert.True(t, ok)
+	assert.Equal(t, "hello", string(e.Data))
+	assert.True(t, atomic.LoadInt32(&attempts) >= 2)
+}
+
+func TestBroker_WithCollectorBackoff(t *testing.T) {
+	var calls int32
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer collector.Close()
+
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	brk := broker.New(time.Second, 3, nil, broker.WithClock(clk), broker.WithCollector(collector.URL))
+	defer brk.Close()
+
+	timers := clk.Timers()
+
+	assert.NoError(t, brk.BroadcastEvent(event.Event{Data: []byte("a")}))
+
+	// The event is retried once the backoff has elapsed on the broker's clock.
+	if !assert.True(t, clk.WaitForTimers(timers+1, time.Second), "the retry was not scheduled") {
+		return
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	clk.Advance(time.Millisecond * 100)
+
+	for deadline := time.Now().Add(time.Second); atomic.LoadInt32(&calls) < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,236 @@
+package broker
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The WebhookConfig type configures a webhook that events are posted to as if it were a
+	// connected client. See the broker.SubscribeWebhook function.
+	WebhookConfig struct {
+		URL      string            // The URL each event is posted to.
+		ID       string            // The identifier of the webhook's client. If blank, a random identifier is used.
+		Topics   []string          // The topics the webhook is subscribed to.
+		Metadata map[string]string // Metadata used to match the webhook against audience selectors.
+		Secret   []byte            // If set, each request is signed with an HMAC of its body in the 'X-Signature-256' header.
+
+		QueueSize   int                            // The number of events waiting to be posted before new events are skipped. Defaults to 1024.
+		Timeout     time.Duration                  // How long each request can take. Defaults to ten seconds.
+		MaxAttempts int                            // How many times each event is posted before it is discarded. Defaults to five.
+		MinBackoff  time.Duration                  // The time to wait before retrying a failed request. Defaults to 100 milliseconds.
+		MaxBackoff  time.Duration                  // The longest time to wait between retries. Defaults to 30 seconds.
+		OnFailure   func(e event.Event, err error) // If set, called when an event is discarded after its final attempt.
+	}
+
+	// The Webhook type is a client of the broker that posts the events it receives to a URL, with
+	// retries & exponential backoff.
+	Webhook struct {
+		cfg    WebhookConfig
+		sub    Subscriber
+		client *client.Client
+		http   *http.Client
+		clock  clock.Clock
+
+		done   chan struct{}
+		closed chan struct{}
+		once   sync.Once
+	}
+)
+
+// SubscribeWebhook subscribes a webhook to the broker as a client, so that it receives the same
+// events an SSE client with the same topics & metadata would. Each event is posted to the URL as
+// JSON, see the event.Event type's MarshalJSON method. Failed requests are retried with exponential
+// backoff, and events are posted in order, one at a time. If the webhook falls behind by more
+// than its queue size, new events are skipped until it catches up. The webhook stops posting events
+// once its client is removed from the broker, such as by being kicked.
+func SubscribeWebhook(sub Subscriber, cfg WebhookConfig) (*Webhook, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("a webhook must have a URL")
+	}
+
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second * 10
+	}
+
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = upstreamMinBackoff
+	}
+
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = upstreamMaxBackoff
+	}
+
+	timeout, tolerance, clk := limitsOf(sub)
+
+	// Events are skipped rather than blocking broadcasts once the queue is full.
+	c := client.NewWithOptions(timeout, tolerance, cfg.ID,
+		client.WithTopics(cfg.Topics...),
+		client.WithMetadata(cfg.Metadata),
+		client.WithQueueSize(cfg.QueueSize),
+		client.WithSlowPolicy(client.SlowPolicy{MaxDepth: cfg.QueueSize, Action: client.ActionSkip}),
+		client.WithClock(clk),
+	)
+
+	if err := sub.Subscribe(c); err != nil {
+		return nil, err
+	}
+
+	wh := &Webhook{
+		cfg:    cfg,
+		sub:    sub,
+		client: c,
+		http:   &http.Client{Timeout: cfg.Timeout},
+		clock:  clk,
+		done:   make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+
+	go wh.run()
+
+	return wh, nil
+}
+
+// limitsOf returns the timeout, tolerance & clock used by the subscriber's clients, so that webhooks
+// subscribed to the broker are treated like any other client & time their retries using the
+// broker's clock. Other subscribers use a one second timeout, a tolerance of three & the system time.
+func limitsOf(sub Subscriber) (time.Duration, int, clock.Clock) {
+	if b, ok := sub.(*defaultBroker); ok {
+		return b.timeout, b.tolerance, b.clock
+	}
+
+	return time.Second, 3, clock.Real()
+}
+
+// ID returns the identifier of the webhook's client, which can be used to send events to the
+// webhook alone.
+func (wh *Webhook) ID() string {
+	return wh.client.ID()
+}
+
+// Close unsubscribes the webhook from the broker. Events that have not yet been posted are
+// discarded.
+func (wh *Webhook) Close() error {
+	wh.once.Do(func() {
+		wh.sub.Unsubscribe(wh.client)
+		close(wh.done)
+	})
+
+	<-wh.closed
+
+	return nil
+}
+
+func (wh *Webhook) run() {
+	defer close(wh.closed)
+
+	for {
+		select {
+		case <-wh.client.Ready():
+			for e, ok := wh.client.Next(); ok; e, ok = wh.client.Next() {
+				if !wh.send(e) {
+					return
+				}
+			}
+		case <-wh.client.Done():
+			// The client was removed from the broker, such as by being kicked.
+			return
+		case <-wh.done:
+			return
+		}
+	}
+}
+
+// send posts the event to the URL, backing off exponentially between failed attempts. Returns
+// false if the webhook or its client was closed while sending.
+func (wh *Webhook) send(e event.Event) bool {
+	backoff := wh.cfg.MinBackoff
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		if err = wh.post(e); err == nil {
+			return true
+		}
+
+		if attempt >= wh.cfg.MaxAttempts {
+			break
+		}
+
+		timer := wh.clock.NewTimer(backoff)
+
+		select {
+		case <-timer.C():
+		case <-wh.client.Done():
+			timer.Stop()
+			return false
+		case <-wh.done:
+			timer.Stop()
+			return false
+		}
+
+		if backoff *= 2; backoff > wh.cfg.MaxBackoff {
+			backoff = wh.cfg.MaxBackoff
+		}
+	}
+
+	if wh.cfg.OnFailure != nil {
+		wh.cfg.OnFailure(e, err)
+	}
+
+	return true
+}
+
+func (wh *Webhook) post(e event.Event) error {
+	body, err := json.Marshal(e)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, wh.cfg.URL, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(wh.cfg.Secret) > 0 {
+		mac := hmac.New(sha256.New, wh.cfg.Secret)
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := wh.http.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.New(resp.Status)
+	}
+
+	return nil
+}
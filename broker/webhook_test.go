@@ -0,0 +1,213 @@
+package broker_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribeWebhook(t *testing.T) {
+	tt := []struct {
+		Name             string
+		Failures         int
+		MaxAttempts      int
+		Topic            string
+		ExpectedRequests int
+		ExpectedData     []string
+		ExpectedFailed   int
+	}{
+		{
+			Name:             "It should post events on the webhook's topics",
+			Topic:            "orders",
+			ExpectedRequests: 1,
+			ExpectedData:     []string{"hello"},
+		},
+		{
+			Name:             "It should not post events on other topics",
+			Topic:            "payments",
+			ExpectedRequests: 0,
+		},
+		{
+			Name:             "It should retry failed requests",
+			Topic:            "orders",
+			Failures:         2,
+			MaxAttempts:      3,
+			ExpectedRequests: 3,
+			ExpectedData:     []string{"hello"},
+		},
+		{
+			Name:             "It should discard events after the final attempt",
+			Topic:            "orders",
+			Failures:         5,
+			MaxAttempts:      2,
+			ExpectedRequests: 2,
+			ExpectedFailed:   1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var (
+				mux      sync.Mutex
+				requests int
+				data     []string
+				failed   int
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mux.Lock()
+				defer mux.Unlock()
+
+				requests++
+
+				if requests <= tc.Failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				body, _ := ioutil.ReadAll(r.Body)
+
+				mac := hmac.New(sha256.New, []byte("secret"))
+				mac.Write(body)
+				assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get("X-Signature-256"))
+
+				var e event.Event
+
+				if assert.NoError(t, json.Unmarshal(body, &e)) {
+					data = append(data, string(e.Data))
+				}
+			}))
+			defer server.Close()
+
+			b := broker.New(time.Second, 3, nil)
+			defer b.Close()
+
+			wh, err := broker.SubscribeWebhook(b, broker.WebhookConfig{
+				URL:         server.URL,
+				Topics:      []string{"orders"},
+				Secret:      []byte("secret"),
+				MaxAttempts: tc.MaxAttempts,
+				MinBackoff:  time.Millisecond * 10,
+				OnFailure: func(e event.Event, err error) {
+					mux.Lock()
+					defer mux.Unlock()
+
+					failed++
+				},
+			})
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.NoError(t, b.BroadcastTopic(tc.Topic, []byte("hello")))
+			<-time.Tick(time.Millisecond * 500)
+			assert.NoError(t, wh.Close())
+
+			mux.Lock()
+			defer mux.Unlock()
+
+			assert.Equal(t, tc.ExpectedRequests, requests)
+			assert.Equal(t, tc.ExpectedData, data)
+			assert.Equal(t, tc.ExpectedFailed, failed)
+			assert.Equal(t, 0, b.Stats().Clients)
+		})
+	}
+}
+
+func TestSubscribeWebhookBackoff(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := broker.New(time.Second, 3, nil, broker.WithClock(clk))
+	defer b.Close()
+
+	wh, err := broker.SubscribeWebhook(b, broker.WebhookConfig{URL: server.URL, MinBackoff: time.Minute})
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer wh.Close()
+
+	timers := clk.Timers()
+
+	assert.NoError(t, b.Broadcast([]byte("hello")))
+
+	// The request is retried once the backoff has elapsed on the broker's clock.
+	if !assert.True(t, clk.WaitForTimers(timers+1, time.Second), "the retry was not scheduled") {
+		return
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	clk.Advance(time.Minute)
+
+	for deadline := time.Now().Add(time.Second); atomic.LoadInt32(&requests) < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestSubscribeWebhookKicked(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := broker.New(time.Second, 3, nil, broker.WithClock(clk))
+	defer b.Close()
+
+	wh, err := broker.SubscribeWebhook(b, broker.WebhookConfig{URL: server.URL, MinBackoff: time.Minute})
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer wh.Close()
+
+	timers := clk.Timers()
+
+	assert.NoError(t, b.Broadcast([]byte("hello")))
+
+	if !assert.True(t, clk.WaitForTimers(timers+1, time.Second), "the retry was not scheduled") {
+		return
+	}
+
+	assert.NoError(t, b.Kick(wh.ID()))
+
+	// Once its client is removed, the webhook stops waiting to retry without being closed.
+	for deadline := time.Now().Add(time.Second); clk.Timers() > timers && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal(t, timers, clk.Timers())
+
+	clk.Advance(time.Minute)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
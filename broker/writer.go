@@ -0,0 +1,81 @@
+package broker
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The lineWriter type broadcasts each line written to it as an event.
+	lineWriter struct {
+		mux       sync.Mutex
+		publisher Publisher
+		eventType string
+		buf       []byte
+	}
+)
+
+// NewWriter returns a writer that broadcasts each line written to it as an event of the given
+// type using the publisher, without the trailing line break. Data that does not end with a line
+// break is buffered until the rest of the line is written, or the writer is closed. This allows
+// application logs or command output to be streamed to clients, such as by using the writer with
+// log.SetOutput or as the Stdout of an exec.Cmd. Writes never fail, errors from broadcasting each
+// event are ignored.
+func NewWriter(p Publisher, eventType string) io.WriteCloser {
+	return &lineWriter{publisher: p, eventType: eventType}
+}
+
+// Writer returns a writer that broadcasts each line written to it as an event of the given type.
+// See the broker.NewWriter function for details.
+func (b *defaultBroker) Writer(eventType string) io.WriteCloser {
+	return NewWriter(b, eventType)
+}
+
+// Write broadcasts each complete line in the data, buffering any remaining data.
+func (lw *lineWriter) Write(data []byte) (int, error) {
+	lw.mux.Lock()
+	defer lw.mux.Unlock()
+
+	lw.buf = append(lw.buf, data...)
+
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+
+		if i < 0 {
+			break
+		}
+
+		lw.broadcast(bytes.TrimSuffix(lw.buf[:i], []byte("\r")))
+		lw.buf = lw.buf[i+1:]
+	}
+
+	// Avoid holding on to the memory of previously written lines.
+	if len(lw.buf) == 0 {
+		lw.buf = nil
+	}
+
+	return len(data), nil
+}
+
+// Close broadcasts any buffered data that does not end with a line break.
+func (lw *lineWriter) Close() error {
+	lw.mux.Lock()
+	defer lw.mux.Unlock()
+
+	if len(lw.buf) > 0 {
+		lw.broadcast(lw.buf)
+		lw.buf = nil
+	}
+
+	return nil
+}
+
+func (lw *lineWriter) broadcast(line []byte) {
+	// Copy the line, as the buffer is reused for following writes.
+	data := append([]byte(nil), line...)
+
+	lw.publisher.BroadcastEvent(event.Event{Type: lw.eventType, Data: data})
+}
@@ -0,0 +1,48 @@
+package broker_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWriter(t *testing.T) {
+	tt := []struct {
+		Writes        []string
+		Close         bool
+		ExpectedLines []string
+	}{
+		{Writes: []string{"hello\nworld\n"}, ExpectedLines: []string{"hello", "world"}},
+		{Writes: []string{"hel", "lo\r\nwor", "ld"}, ExpectedLines: []string{"hello"}},
+		{Writes: []string{"hel", "lo\r\nwor", "ld"}, Close: true, ExpectedLines: []string{"hello", "world"}},
+		{Writes: []string{"\n"}, ExpectedLines: []string{""}},
+	}
+
+	for _, tc := range tt {
+		b := ssetest.NewBroker()
+		w := broker.NewWriter(b, "log")
+
+		for _, data := range tc.Writes {
+			n, err := fmt.Fprint(w, data)
+
+			assert.NoError(t, err)
+			assert.Equal(t, len(data), n)
+		}
+
+		if tc.Close {
+			assert.NoError(t, w.Close())
+		}
+
+		published := b.Published()
+
+		if assert.Len(t, published, len(tc.ExpectedLines)) {
+			for i, p := range published {
+				assert.Equal(t, "log", p.Event.Type)
+				assert.Equal(t, tc.ExpectedLines[i], string(p.Event.Data))
+			}
+		}
+	}
+}
@@ -3,8 +3,12 @@ package client
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
 	"github.com/rs/xid"
 )
 
@@ -12,60 +16,522 @@ type (
 	// The Client type represents a client connected to the broker.
 	Client struct {
 		id        string
-		notify    chan []byte
 		timeout   time.Duration
-		failures  int
 		tolerance int
+		metadata  map[string]string
+		groups    []string
+		queueSize int
+		protocol  string
+
+		// Counters are updated atomically, so that they can be read & written by concurrent
+		// broadcasts without holding the client's lock.
+		state    int32 // The client's State.
+		failures int32 // The number of sequential writes that have failed.
+		evicted  int32 // Set to 1 once a disconnecting slow policy has evicted the client.
+		skipped  uint64
+		dropped  uint64
+		retried  uint64
+		replaced uint64
+
+		mux         sync.Mutex
+		topics      []string
+		queue       []*entry
+		ready       chan struct{}
+		space       chan struct{}
+		done        chan struct{}
+		slowPolicy  SlowPolicy
+		behindSince time.Time
+		paused      bool
+		holdLimit   int
+		held        []event.Event
+		retry       RetryPolicy
+		clock       clock.Clock
+		listen      chan []byte
+		listenOnce  sync.Once
+	}
+
+	// Option is a function that modifies the client's optional configuration.
+	Option func(*Client)
+
+	// The Pending type describes an event that has been written to the client but has
+	// not yet been delivered.
+	Pending struct {
+		ID   string // The event's identifier, if it has one.
+		Type string // The event's type, if it has one.
+		Size int    // The size of the event data, in bytes.
+		Data []byte // The event data, if requested.
+	}
+
+	// The entry type is an event held in the client's queue.
+	entry struct {
+		event  event.Event
+		queued time.Time
+		taken  chan struct{}
 	}
 )
 
+// Queue entries are reused once their event has been taken, so that queueing an event does
+// not allocate.
+var entries = sync.Pool{New: func() interface{} { return &entry{} }}
+
 // New creates a new instance of the Client type using the provided timeout
 // and tolerance. The 'timeout' parameter determines how long the client will attempt
 // to write. The 'tolerance' parameter determines how many sequential errors the
 // client will make before ShouldDisconnect returns true. The 'id' parameter allows
 // you to specify a custom identifier for the client, if it is blank, a random
-// identifier is created for the client.
-func New(timeout time.Duration, tolerance int, id string) *Client {
+// identifier is created for the client. The client is subscribed to the given topics,
+// see the client.NewWithOptions function for further configuration.
+func New(timeout time.Duration, tolerance int, id string, topics ...string) *Client {
+	return NewWithOptions(timeout, tolerance, id, WithTopics(topics...))
+}
+
+// NewWithOptions creates a new instance of the Client type in the same way as the
+// client.New function, applying the options to the client in the order they are given.
+func NewWithOptions(timeout time.Duration, tolerance int, id string, opts ...Option) *Client {
 	ret := &Client{
 		id:        id,
-		notify:    make(chan []byte),
 		timeout:   timeout,
-		failures:  0,
 		tolerance: tolerance,
+		ready:     make(chan struct{}, 1),
+		space:     make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		clock:     clock.Real(),
 	}
 
 	if id == "" {
 		ret.id = xid.New().String()
 	}
 
+	for _, opt := range opts {
+		opt(ret)
+	}
+
 	return ret
 }
 
+// WithTopics sets the topics that the client is subscribed to.
+func WithTopics(topics ...string) Option {
+	return func(c *Client) {
+		c.topics = topics
+	}
+}
+
+// WithMetadata sets arbitrary metadata to associate with the client.
+func WithMetadata(metadata map[string]string) Option {
+	return func(c *Client) {
+		c.metadata = metadata
+	}
+}
+
+// WithGroups sets the groups the client is a member of, such as those in the claims of the token
+// it connected with. Members of a group receive the events broadcast to it.
+func WithGroups(groups ...string) Option {
+	return func(c *Client) {
+		c.groups = groups
+	}
+}
+
+// WithQueueSize sets the number of events that can be waiting to be delivered to the
+// client before writes block. If 'size' is zero, each write waits until the event has
+// been taken from the queue.
+func WithQueueSize(size int) Option {
+	return func(c *Client) {
+		c.queueSize = size
+	}
+}
+
+// WithProtocol sets the protocol the client is connected using, such as 'HTTP/1.1' or 'HTTP/2.0'.
+func WithProtocol(proto string) Option {
+	return func(c *Client) {
+		c.protocol = proto
+	}
+}
+
+// WithClock sets the clock used to time writes & measure how long events have been queued, see
+// the clock package. If 'c' is nil, the system time is used.
+func WithClock(c clock.Clock) Option {
+	return func(cl *Client) {
+		if c != nil {
+			cl.clock = c
+		}
+	}
+}
+
 // ID returns the client's unique identifier.
 func (c *Client) ID() string {
 	return c.id
 }
 
-// Listen reads event data from the broker.
+// Topics returns the topics the client is subscribed to.
+func (c *Client) Topics() []string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.topics
+}
+
+// SetTopics replaces the topics the client is subscribed to. This only changes the topics
+// reported by the client, use the broker's UpdateSubscriptions method to change which events
+// a connected client receives.
+func (c *Client) SetTopics(topics ...string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.topics = topics
+}
+
+// Metadata returns the metadata associated with the client.
+func (c *Client) Metadata() map[string]string {
+	return c.metadata
+}
+
+// Groups returns the groups the client is a member of.
+func (c *Client) Groups() []string {
+	return c.groups
+}
+
+// Protocol returns the protocol the client is connected using, such as 'HTTP/2.0'. Clients that are
+// not connected over HTTP return a blank string.
+func (c *Client) Protocol() string {
+	return c.protocol
+}
+
+// Ready returns a channel that is signalled when events are available to be taken from
+// the client's queue using the Next method. Once signalled, Next should be called until
+// it returns false.
+func (c *Client) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// Listen returns a channel that receives the data of each event written to the client, which
+// is closed once the client is closed. Events are taken from the client's queue in the
+// background, so Listen should not be used alongside the Ready & Next methods.
 func (c *Client) Listen() <-chan []byte {
-	return c.notify
+	c.listenOnce.Do(func() {
+		c.listen = make(chan []byte)
+		go c.forward()
+	})
+
+	return c.listen
+}
+
+// forward takes events from the client's queue, sending their data to the channel returned
+// by the Listen method until the client is closed.
+func (c *Client) forward() {
+	defer close(c.listen)
+
+	for {
+		select {
+		case <-c.ready:
+		case <-c.done:
+			return
+		}
+
+		for e, ok := c.Next(); ok; e, ok = c.Next() {
+			select {
+			case c.listen <- e.Data:
+			case <-c.done:
+				return
+			}
+		}
+	}
+}
+
+// Next takes the next event from the client's queue. If the queue is empty, false is
+// returned.
+func (c *Client) Next() (event.Event, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if len(c.queue) == 0 {
+		return event.Event{}, false
+	}
+
+	e := c.queue[0]
+	c.queue[0] = nil
+
+	// Taking the last event keeps the start of the queue's capacity, so that a client
+	// that keeps up with its events never reallocates its queue.
+	if len(c.queue) == 1 {
+		c.queue = c.queue[:0]
+	} else {
+		c.queue = c.queue[1:]
+	}
+
+	evt := e.event
+
+	// Writers waiting on a handoff compare entries by pointer, so only entries without
+	// a waiting writer are reused.
+	if e.taken != nil {
+		close(e.taken)
+	} else {
+		releaseEntry(e)
+	}
+
+	signal(c.space)
+
+	// A draining client is closed once its last event has been taken.
+	if len(c.queue) == 0 && c.State() == StateDraining {
+		go c.Close()
+	}
+
+	return evt, true
+}
+
+// Pending returns the events that have been written to the client but have not yet been
+// taken from its queue, in the order they will be delivered. If 'payloads' is true, a copy
+// of each event's data is included.
+func (c *Client) Pending(payloads bool) []Pending {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	out := make([]Pending, len(c.queue))
+
+	for i, e := range c.queue {
+		out[i].ID = e.event.ID
+		out[i].Type = e.event.Type
+		out[i].Size = len(e.event.Data)
+
+		if payloads {
+			out[i].Data = append([]byte(nil), e.event.Data...)
+		}
+	}
+
+	return out
 }
 
 // Write attempts to write the provided data to the client. If writing
 // exceeds the timeout, an error is returned.
 func (c *Client) Write(data []byte) error {
+	return c.WriteEvent(event.Event{Data: data})
+}
+
+// WriteEvent attempts to write the provided event to the client. If writing
+// exceeds the timeout, or the event's deadline if it has one, an error is returned. If the client has a slow policy and
+// is considered slow, the policy's action is applied to the event. Events are
+// delivered ahead of queued events with a lower priority. If the queue is full,
+// a queued event with a lower priority is discarded to make room, and low priority
+// events are discarded instead of waiting for space. If the client is paused, the
+// event is held until it is resumed, see the Pause method. If the event has a key, any
+// queued or held event with the same key is discarded in favour of it. If the client is
+// draining or closed, ErrDraining or ErrClosed is returned.
+func (c *Client) WriteEvent(e event.Event) error {
+	if err := c.accepting(); err != nil {
+		return err
+	}
+
+	if c.hold(e) {
+		return nil
+	}
+
+	if skip, err := c.applySlowPolicy(e); skip || err != nil {
+		return err
+	}
+
+	// If the write times out, retry it according to the client's retry policy before
+	// counting it as a failure.
+	for attempt := 0; ; attempt++ {
+		err := c.write(e)
+
+		if err != errTimeout {
+			return err
+		}
+
+		if !c.retry.wait(attempt, c.clock) {
+			return c.fail()
+		}
+
+		atomic.AddUint64(&c.retried, 1)
+	}
+}
+
+// write makes a single attempt to queue the event, returning errTimeout if it could not be
+// queued within the client's timeout, or by the event's deadline.
+func (c *Client) write(e event.Event) error {
+	if c.queueSize <= 0 {
+		timeout := c.clock.NewTimer(c.writeTimeout(e))
+		defer timeout.Stop()
+
+		return c.handoff(e, timeout.C())
+	}
+
+	// The timer is only started once the writer has to wait for space, so that writing
+	// to a client with room in its queue does not allocate one.
+	var timeout clock.Timer
+
+	for {
+		c.mux.Lock()
+
+		// The client may have been closed while waiting for space.
+		if err := c.accepting(); err != nil {
+			c.mux.Unlock()
+			return err
+		}
+
+		// Only the latest event with a given key is delivered.
+		if e.Key != "" {
+			c.replaceKey(e.Key)
+		}
+
+		// If the queue is full, make room by discarding a queued event of a lower priority.
+		if len(c.queue) >= c.queueSize {
+			c.displace(e.Priority)
+		}
+
+		if len(c.queue) < c.queueSize {
+			c.enqueue(newEntry(e, c.clock.Now()))
+			atomic.StoreInt32(&c.failures, 0)
+
+			// If there is still space, let any other waiting writers know.
+			if len(c.queue) < c.queueSize {
+				signal(c.space)
+			}
+
+			c.mux.Unlock()
+			signal(c.ready)
+
+			return nil
+		}
+
+		// Low priority events are discarded rather than waiting for space.
+		if e.Priority <= event.PriorityLow {
+			atomic.AddUint64(&c.dropped, 1)
+			c.mux.Unlock()
+
+			return nil
+		}
+
+		c.mux.Unlock()
+
+		if timeout == nil {
+			timeout = c.clock.NewTimer(c.writeTimeout(e))
+			defer timeout.Stop()
+		}
+
+		select {
+		case <-c.space:
+			continue
+		case <-c.done:
+			return ErrClosed
+		case <-timeout.C():
+			return errTimeout
+		}
+	}
+}
+
+// writeTimeout returns how long to wait to queue the event: until its deadline, if it has one,
+// otherwise the client's timeout.
+func (c *Client) writeTimeout(e event.Event) time.Duration {
+	if e.Deadline.IsZero() {
+		return c.timeout
+	}
+
+	if d := e.Deadline.Sub(c.clock.Now()); d > 0 {
+		return d
+	}
+
+	return 0
+}
+
+// handoff queues the event and waits for it to be taken from the queue. If the timeout
+// is reached first, the event is removed from the queue & errTimeout is returned.
+func (c *Client) handoff(evt event.Event, timeout <-chan time.Time) error {
+	e := &entry{event: evt, queued: c.clock.Now(), taken: make(chan struct{})}
+
+	c.mux.Lock()
+
+	if err := c.accepting(); err != nil {
+		c.mux.Unlock()
+		return err
+	}
+
+	c.enqueue(e)
+	c.mux.Unlock()
+	signal(c.ready)
+
 	select {
-	case c.notify <- data:
-		c.failures = 0
+	case <-e.taken:
+		atomic.StoreInt32(&c.failures, 0)
+
 		return nil
-	case <-time.Tick(c.timeout):
-		c.failures++
-		return fmt.Errorf("failed to write to client %v, timeout exceeded", c.id)
+	case <-c.done:
+		return ErrClosed
+	case <-timeout:
 	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for i, queued := range c.queue {
+		if queued == e {
+			c.queue = append(c.queue[:i], c.queue[i+1:]...)
+
+			return errTimeout
+		}
+	}
+
+	// The event was taken while we were waiting for the lock.
+	atomic.StoreInt32(&c.failures, 0)
+
+	return nil
 }
 
-// ShouldDisconnect determines if a client has had too many sequential errors and
-// should be forcefully disconnected from the broker.
+// applySlowPolicy checks if the client is slow & applies the policy's action to the event.
+// If the event should not be queued, true is returned.
+func (c *Client) applySlowPolicy(e event.Event) (bool, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if !c.checkSlow(c.clock.Now()) {
+		return false, nil
+	}
+
+	switch c.slowPolicy.Action {
+	case ActionDisconnect:
+		atomic.StoreInt32(&c.evicted, 1)
+		return true, fmt.Errorf("client %v is too slow, disconnecting", c.id)
+	case ActionSnapshot:
+		c.collapse(e.Topic)
+		return false, nil
+	default:
+		atomic.AddUint64(&c.skipped, 1)
+		return true, nil
+	}
+}
+
+func (c *Client) fail() error {
+	atomic.AddInt32(&c.failures, 1)
+
+	return fmt.Errorf("failed to write to client %v, timeout exceeded", c.id)
+}
+
+// ShouldDisconnect determines if a client has had too many sequential errors, or has
+// been too slow under a disconnecting slow policy, and should be forcefully disconnected
+// from the broker.
 func (c *Client) ShouldDisconnect() bool {
-	return c.failures >= c.tolerance
+	return atomic.LoadInt32(&c.evicted) == 1 || int(atomic.LoadInt32(&c.failures)) >= c.tolerance
+}
+
+// signal notifies a channel without blocking if it has already been notified.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// newEntry returns an unused entry holding the event.
+func newEntry(e event.Event, queued time.Time) *entry {
+	out := entries.Get().(*entry)
+	out.event = e
+	out.queued = queued
+
+	return out
+}
+
+// releaseEntry clears the entry, so that it no longer references its event, & returns it to
+// the pool.
+func releaseEntry(e *entry) {
+	*e = entry{}
+	entries.Put(e)
 }
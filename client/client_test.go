@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -55,3 +57,120 @@ func TestClient_ReadWrite(t *testing.T) {
 		}
 	}
 }
+
+func TestClient_Pending(t *testing.T) {
+	tt := []struct {
+		QueueSize       int
+		Data            []string
+		Payloads        bool
+		ExpectedPending int
+		ExpectedError   string
+	}{
+		{QueueSize: 3, Data: []string{"a", "bb"}, ExpectedPending: 2},
+		{QueueSize: 3, Data: []string{"a", "bb", "ccc"}, Payloads: true, ExpectedPending: 3},
+		{QueueSize: 2, Data: []string{"a", "bb", "ccc"}, ExpectedPending: 2, ExpectedError: "timeout exceeded"},
+	}
+
+	for _, tc := range tt {
+		client := client.NewWithOptions(time.Millisecond*100, 3, "", client.WithQueueSize(tc.QueueSize))
+
+		for _, data := range tc.Data {
+			if err := client.Write([]byte(data)); err != nil {
+				assert.Contains(t, err.Error(), tc.ExpectedError)
+			}
+		}
+
+		pending := client.Pending(tc.Payloads)
+
+		if !assert.Len(t, pending, tc.ExpectedPending) {
+			continue
+		}
+
+		for i, p := range pending {
+			assert.Equal(t, len(tc.Data[i]), p.Size)
+
+			if tc.Payloads {
+				assert.Equal(t, tc.Data[i], string(p.Data))
+			} else {
+				assert.Nil(t, p.Data)
+			}
+		}
+
+		// Taking events from the queue should remove them from the
+		// pending events.
+		<-client.Ready()
+		e, ok := client.Next()
+
+		assert.True(t, ok)
+		assert.Equal(t, tc.Data[0], string(e.Data))
+		assert.Len(t, client.Pending(false), tc.ExpectedPending-1)
+	}
+}
+
+func TestClient_WithClock(t *testing.T) {
+	clk := ssetest.NewClock(time.Now())
+	c := client.NewWithOptions(time.Minute, 1, "", client.WithQueueSize(1), client.WithClock(clk))
+
+	assert.NoError(t, c.Write([]byte("a")))
+
+	errs := make(chan error, 1)
+	go func() { errs <- c.Write([]byte("b")) }()
+
+	// The write times out once the clock passes the timeout, without waiting for a minute.
+	assert.True(t, clk.WaitForTimers(1, time.Second))
+	clk.Advance(time.Second * 59)
+	assert.Equal(t, time.Second*59, c.Lag().Delay)
+
+	clk.Advance(time.Second)
+
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("write did not time out")
+	}
+
+	assert.True(t, c.ShouldDisconnect())
+}
+
+func TestClient_WriteEventDeadline(t *testing.T) {
+	clk := ssetest.NewClock(time.Now())
+	c := client.NewWithOptions(time.Minute, 3, "", client.WithQueueSize(1), client.WithClock(clk))
+
+	assert.NoError(t, c.Write([]byte("a")))
+
+	errs := make(chan error, 1)
+	go func() { errs <- c.WriteEvent(event.Event{Data: []byte("b"), Deadline: clk.Now().Add(time.Second)}) }()
+
+	// The write gives up at the event's deadline rather than after the client's timeout.
+	assert.True(t, clk.WaitForTimers(1, time.Second))
+	clk.Advance(time.Second)
+
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("write did not time out")
+	}
+}
+
+func TestClient_Listen(t *testing.T) {
+	c := client.NewWithOptions(time.Second, 3, "", client.WithQueueSize(2))
+
+	assert.NoError(t, c.Write([]byte("a")))
+	assert.NoError(t, c.Write([]byte("b")))
+
+	listen := c.Listen()
+
+	assert.Equal(t, []byte("a"), <-listen)
+	assert.Equal(t, []byte("b"), <-c.Listen())
+
+	c.Close()
+
+	select {
+	case _, ok := <-listen:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("the channel was not closed with the client")
+	}
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"sync/atomic"
+
+	"github.com/davidsbond/sse/event"
+)
+
+// replaceKey removes any queued events with the given key, so that only the event being written
+// is delivered. Events that a writer is waiting to hand off are kept. It must be called while
+// holding the client's lock.
+func (c *Client) replaceKey(key string) {
+	out := c.queue[:0]
+
+	for _, e := range c.queue {
+		if e.taken == nil && e.event.Key == key {
+			releaseEntry(e)
+			atomic.AddUint64(&c.replaced, 1)
+			continue
+		}
+
+		out = append(out, e)
+	}
+
+	for i := len(out); i < len(c.queue); i++ {
+		c.queue[i] = nil
+	}
+
+	c.queue = out
+}
+
+// replaceHeld removes any held events with the given key, in the same way as the replaceKey
+// method. It must be called while holding the client's lock.
+func (c *Client) replaceHeld(key string) {
+	out := c.held[:0]
+
+	for _, e := range c.held {
+		if e.Key == key {
+			atomic.AddUint64(&c.replaced, 1)
+			continue
+		}
+
+		out = append(out, e)
+	}
+
+	for i := len(out); i < len(c.held); i++ {
+		c.held[i] = event.Event{}
+	}
+
+	c.held = out
+}
@@ -0,0 +1,89 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_WriteEventKey(t *testing.T) {
+	tt := []struct {
+		Name             string
+		Paused           bool
+		Events           []event.Event
+		ExpectedData     []string
+		ExpectedReplaced uint64
+	}{
+		{
+			Name: "It should only deliver the latest event for each key",
+			Events: []event.Event{
+				{Key: "price:AAPL", Data: []byte("100")},
+				{Key: "price:MSFT", Data: []byte("200")},
+				{Data: []byte("news")},
+				{Key: "price:AAPL", Data: []byte("101")},
+				{Key: "price:AAPL", Data: []byte("102")},
+			},
+			ExpectedData:     []string{"200", "news", "102"},
+			ExpectedReplaced: 2,
+		},
+		{
+			Name: "It should not replace events without a key",
+			Events: []event.Event{
+				{Data: []byte("a")},
+				{Data: []byte("b")},
+			},
+			ExpectedData: []string{"a", "b"},
+		},
+		{
+			Name:   "It should replace held events",
+			Paused: true,
+			Events: []event.Event{
+				{Key: "price:AAPL", Data: []byte("100")},
+				{Key: "price:AAPL", Data: []byte("101")},
+			},
+			ExpectedData:     []string{"101"},
+			ExpectedReplaced: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c := client.NewWithOptions(time.Second, 3, "", client.WithQueueSize(10))
+
+			if tc.Paused {
+				c.Pause(10)
+			}
+
+			for _, e := range tc.Events {
+				assert.NoError(t, c.WriteEvent(e))
+			}
+
+			assert.Equal(t, tc.ExpectedReplaced, c.Lag().Replaced)
+			c.Resume()
+
+			var actual []string
+
+			for e, ok := c.Next(); ok; e, ok = c.Next() {
+				actual = append(actual, string(e.Data))
+			}
+
+			assert.Equal(t, tc.ExpectedData, actual)
+		})
+	}
+}
+
+func TestClient_WriteEventKeyFullQueue(t *testing.T) {
+	c := client.NewWithOptions(time.Millisecond*10, 3, "", client.WithQueueSize(1))
+
+	assert.NoError(t, c.WriteEvent(event.Event{Key: "price:AAPL", Data: []byte("100")}))
+
+	// The queue is full, but the new event replaces the queued one rather than waiting.
+	assert.NoError(t, c.WriteEvent(event.Event{Key: "price:AAPL", Data: []byte("101")}))
+
+	e, ok := c.Next()
+	assert.True(t, ok)
+	assert.Equal(t, "101", string(e.Data))
+}
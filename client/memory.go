@@ -0,0 +1,89 @@
+package client
+
+import (
+	"sync/atomic"
+
+	"github.com/davidsbond/sse/event"
+)
+
+// Buffered returns the number of bytes of event data queued for the client or held while it is
+// paused.
+func (c *Client) Buffered() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	var n int
+
+	for _, e := range c.queue {
+		n += len(e.event.Data)
+	}
+
+	for _, e := range c.held {
+		n += len(e.Data)
+	}
+
+	return n
+}
+
+// Shed discards queued events until at least 'bytes' bytes of event data have been freed, returning
+// the number of bytes freed. The oldest events with the lowest priority are discarded first, followed
+// by the oldest events held while the client is paused. Events that a writer is waiting to hand off
+// are kept. Discarded events are counted as dropped.
+func (c *Client) Shed(bytes int) int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	var freed int
+
+	for freed < bytes {
+		i := c.shedIndex()
+
+		if i < 0 {
+			break
+		}
+
+		e := c.queue[i]
+		freed += len(e.event.Data)
+
+		copy(c.queue[i:], c.queue[i+1:])
+		c.queue[len(c.queue)-1] = nil
+		c.queue = c.queue[:len(c.queue)-1]
+
+		releaseEntry(e)
+		atomic.AddUint64(&c.dropped, 1)
+	}
+
+	for freed < bytes && len(c.held) > 0 {
+		freed += len(c.held[0].Data)
+		c.held[0] = event.Event{}
+		c.held = c.held[1:]
+		atomic.AddUint64(&c.dropped, 1)
+	}
+
+	if freed > 0 {
+		signal(c.space)
+	}
+
+	return freed
+}
+
+// shedIndex returns the index of the oldest queued event with the lowest priority that no writer is
+// waiting to hand off, or -1 if there is none. It must be called while holding the client's lock.
+func (c *Client) shedIndex() int {
+	i := -1
+
+	// The queue is ordered by priority, so the lowest priority events are at the end.
+	for j := len(c.queue) - 1; j >= 0; j-- {
+		if c.queue[j].taken != nil {
+			continue
+		}
+
+		if i >= 0 && c.queue[j].event.Priority != c.queue[i].event.Priority {
+			break
+		}
+
+		i = j
+	}
+
+	return i
+}
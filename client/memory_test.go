@@ -0,0 +1,71 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Shed(t *testing.T) {
+	low := func(data string) event.Event { return event.Event{Data: []byte(data), Priority: event.PriorityLow} }
+	normal := func(data string) event.Event { return event.Event{Data: []byte(data)} }
+
+	tt := []struct {
+		Name             string
+		Events           []event.Event
+		Bytes            int
+		ExpectedFreed    int
+		ExpectedPending  []string
+		ExpectedBuffered int
+	}{
+		{
+			Name:             "It should discard the oldest events with the lowest priority first",
+			Events:           []event.Event{normal("aa"), low("bb"), normal("cc"), low("dd")},
+			Bytes:            3,
+			ExpectedFreed:    4,
+			ExpectedPending:  []string{"aa", "cc"},
+			ExpectedBuffered: 4,
+		},
+		{
+			Name:             "It should discard events of a higher priority once none of a lower priority remain",
+			Events:           []event.Event{normal("aa"), low("bb"), normal("cc")},
+			Bytes:            4,
+			ExpectedFreed:    4,
+			ExpectedPending:  []string{"cc"},
+			ExpectedBuffered: 2,
+		},
+		{
+			Name:            "It should stop once the queue is empty",
+			Events:          []event.Event{normal("aa")},
+			Bytes:           10,
+			ExpectedFreed:   2,
+			ExpectedPending: []string{},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c := client.NewWithOptions(time.Second, 3, "", client.WithQueueSize(10))
+
+			for _, e := range tc.Events {
+				assert.NoError(t, c.WriteEvent(e))
+			}
+
+			assert.Equal(t, tc.ExpectedFreed, c.Shed(tc.Bytes))
+
+			pending := c.Pending(true)
+			data := make([]string, len(pending))
+
+			for i, p := range pending {
+				data[i] = string(p.Data)
+			}
+
+			assert.Equal(t, tc.ExpectedPending, data)
+			assert.Equal(t, tc.ExpectedBuffered, c.Buffered())
+			assert.Equal(t, uint64(len(tc.Events)-len(tc.ExpectedPending)), c.Lag().Dropped)
+		})
+	}
+}
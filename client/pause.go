@@ -0,0 +1,78 @@
+package client
+
+import (
+	"sync/atomic"
+
+	"github.com/davidsbond/sse/event"
+)
+
+// Pause holds events written to the client instead of queueing them for delivery, until Resume
+// is called. Up to 'limit' events are held, further events are discarded & counted as dropped.
+// Events queued before the client was paused are still delivered. If the client is already
+// paused, only the limit is changed.
+func (c *Client) Pause(limit int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.paused = true
+	c.holdLimit = limit
+}
+
+// Resume queues the events held while the client was paused for delivery, in priority order,
+// and delivers new events as normal. Held events are queued even if this exceeds the client's
+// queue size.
+func (c *Client) Resume() {
+	c.mux.Lock()
+
+	if !c.paused {
+		c.mux.Unlock()
+		return
+	}
+
+	now := c.clock.Now()
+
+	for _, e := range c.held {
+		c.enqueue(newEntry(e, now))
+	}
+
+	c.paused = false
+	c.held = nil
+	ready := len(c.queue) > 0
+	c.mux.Unlock()
+
+	if ready {
+		signal(c.ready)
+	}
+}
+
+// Paused returns true if the client is paused, see the Pause method.
+func (c *Client) Paused() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.paused
+}
+
+// hold keeps the event until the client is resumed, if it is paused. Returns true if the client
+// is paused, in which case the event should not be queued.
+func (c *Client) hold(e event.Event) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if !c.paused {
+		return false
+	}
+
+	if e.Key != "" {
+		c.replaceHeld(e.Key)
+	}
+
+	if len(c.held) >= c.holdLimit {
+		atomic.AddUint64(&c.dropped, 1)
+		return true
+	}
+
+	c.held = append(c.held, e)
+
+	return true
+}
@@ -0,0 +1,78 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Pause(t *testing.T) {
+	tt := []struct {
+		Limit           int
+		Held            []event.Event
+		ExpectedPaused  []string
+		ExpectedResumed []string
+		ExpectedDropped uint64
+		ExpectedHeld    int
+	}{
+		{
+			Limit:           10,
+			Held:            []event.Event{{Data: []byte("b")}, {Data: []byte("c")}},
+			ExpectedPaused:  []string{"a"},
+			ExpectedResumed: []string{"a", "b", "c"},
+			ExpectedHeld:    2,
+		},
+		{
+			// Events beyond the limit are discarded.
+			Limit:           1,
+			Held:            []event.Event{{Data: []byte("b")}, {Data: []byte("c")}},
+			ExpectedPaused:  []string{"a"},
+			ExpectedResumed: []string{"a", "b"},
+			ExpectedDropped: 1,
+			ExpectedHeld:    1,
+		},
+		{
+			// Held events are queued ahead of events with a lower priority.
+			Limit:           10,
+			Held:            []event.Event{{Data: []byte("b")}, {Data: []byte("c"), Priority: event.PriorityHigh}},
+			ExpectedPaused:  []string{"a"},
+			ExpectedResumed: []string{"c", "a", "b"},
+			ExpectedHeld:    2,
+		},
+	}
+
+	for _, tc := range tt {
+		client := client.NewWithOptions(time.Millisecond*100, 3, "", client.WithQueueSize(10))
+
+		// Events queued before pausing are still delivered.
+		assert.NoError(t, client.Write([]byte("a")))
+		client.Pause(tc.Limit)
+		assert.True(t, client.Paused())
+
+		for _, e := range tc.Held {
+			assert.NoError(t, client.WriteEvent(e))
+		}
+
+		assert.Equal(t, tc.ExpectedPaused, data(client.Pending(true)))
+		assert.Equal(t, tc.ExpectedHeld, client.Lag().Held)
+		assert.Equal(t, tc.ExpectedDropped, client.Lag().Dropped)
+
+		client.Resume()
+		assert.False(t, client.Paused())
+		assert.Equal(t, tc.ExpectedResumed, data(client.Pending(true)))
+		assert.Equal(t, 0, client.Lag().Held)
+	}
+}
+
+func data(pending []client.Pending) []string {
+	out := make([]string, len(pending))
+
+	for i, p := range pending {
+		out[i] = string(p.Data)
+	}
+
+	return out
+}
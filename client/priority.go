@@ -0,0 +1,48 @@
+package client
+
+import (
+	"sync/atomic"
+
+	"github.com/davidsbond/sse/event"
+)
+
+// enqueue adds the entry to the queue ahead of any queued events with a lower priority, so
+// that events of the same priority are delivered in the order they are written. It must be
+// called while holding the client's lock.
+func (c *Client) enqueue(e *entry) {
+	i := len(c.queue)
+
+	for i > 0 && c.queue[i-1].event.Priority < e.event.Priority {
+		i--
+	}
+
+	c.queue = append(c.queue, nil)
+	copy(c.queue[i+1:], c.queue[i:])
+	c.queue[i] = e
+}
+
+// displace discards the most recently queued event with the lowest priority, if it is lower
+// than the given priority, to make room for an event of that priority. Events that a writer
+// is waiting to hand off are kept. Returns true if an event was discarded. It must be called
+// while holding the client's lock.
+func (c *Client) displace(p event.Priority) bool {
+	// The queue is ordered by priority, so the lowest priority events are at the end.
+	for i := len(c.queue) - 1; i >= 0; i-- {
+		if c.queue[i].taken != nil {
+			continue
+		}
+
+		if c.queue[i].event.Priority >= p {
+			return false
+		}
+
+		copy(c.queue[i:], c.queue[i+1:])
+		c.queue[len(c.queue)-1] = nil
+		c.queue = c.queue[:len(c.queue)-1]
+		atomic.AddUint64(&c.dropped, 1)
+
+		return true
+	}
+
+	return false
+}
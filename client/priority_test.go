@@ -0,0 +1,75 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Priority(t *testing.T) {
+	low := func(data string) event.Event { return event.Event{Data: []byte(data), Priority: event.PriorityLow} }
+	normal := func(data string) event.Event { return event.Event{Data: []byte(data)} }
+	high := func(data string) event.Event { return event.Event{Data: []byte(data), Priority: event.PriorityHigh} }
+
+	tt := []struct {
+		QueueSize       int
+		Events          []event.Event
+		ExpectedPending []string
+		ExpectedDropped uint64
+		ExpectError     bool
+	}{
+		{
+			// High priority events jump ahead of queued events of a lower priority.
+			QueueSize:       10,
+			Events:          []event.Event{low("a"), normal("b"), high("c"), normal("d"), high("e")},
+			ExpectedPending: []string{"c", "e", "b", "d", "a"},
+		},
+		{
+			// Low priority events are discarded when the queue is full.
+			QueueSize:       2,
+			Events:          []event.Event{normal("a"), normal("b"), low("c")},
+			ExpectedPending: []string{"a", "b"},
+			ExpectedDropped: 1,
+		},
+		{
+			// The most recent lowest priority event makes room for a higher priority one.
+			QueueSize:       3,
+			Events:          []event.Event{low("a"), normal("b"), low("c"), high("d"), normal("e")},
+			ExpectedPending: []string{"d", "b", "e"},
+			ExpectedDropped: 2,
+		},
+		{
+			// Events of the same priority are not displaced.
+			QueueSize:       2,
+			Events:          []event.Event{high("a"), high("b"), high("c")},
+			ExpectedPending: []string{"a", "b"},
+			ExpectError:     true,
+		},
+	}
+
+	for _, tc := range tt {
+		client := client.NewWithOptions(time.Millisecond*100, 3, "", client.WithQueueSize(tc.QueueSize))
+
+		var err error
+
+		for _, e := range tc.Events {
+			if werr := client.WriteEvent(e); werr != nil {
+				err = werr
+			}
+		}
+
+		pending := client.Pending(true)
+		data := make([]string, len(pending))
+
+		for i, p := range pending {
+			data[i] = string(p.Data)
+		}
+
+		assert.Equal(t, tc.ExpectError, err != nil)
+		assert.Equal(t, tc.ExpectedPending, data)
+		assert.Equal(t, tc.ExpectedDropped, client.Lag().Dropped)
+	}
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"errors"
+	"time"
+
+	"github.com/davidsbond/sse/clock"
+)
+
+type (
+	// The RetryPolicy type determines how writes that exceed the client's timeout are retried
+	// before they are counted as a failure. Retrying smooths over short pauses in a consumer,
+	// such as garbage collection, that would otherwise count towards the client's tolerance &
+	// eventually disconnect it. The zero value does not retry.
+	RetryPolicy struct {
+		Attempts   int           // The number of times a write is retried after the first attempt times out.
+		Backoff    time.Duration // How long to wait before the first retry, doubling before each subsequent retry.
+		MaxBackoff time.Duration // If non-zero, the longest time to wait between retries.
+	}
+)
+
+// errTimeout is returned by a single attempt to write to the client that exceeded its timeout.
+var errTimeout = errors.New("timeout exceeded")
+
+// WithRetryPolicy sets the policy used to retry writes that exceed the client's timeout. Each
+// retry waits for the client's full timeout again, so a write can take up to 'Attempts + 1'
+// times the timeout, plus the backoff, before failing.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// wait waits before retrying a write that has timed out 'attempt + 1' times, timing the backoff
+// using the clock. If the write should not be retried, false is returned immediately.
+func (p RetryPolicy) wait(attempt int, clk clock.Clock) bool {
+	if attempt >= p.Attempts {
+		return false
+	}
+
+	timer := clk.NewTimer(p.backoff(attempt))
+	<-timer.C()
+
+	return true
+}
+
+// backoff returns how long to wait before the given retry, doubling the backoff for each previous
+// retry up to the maximum.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.Backoff
+
+	for i := 0; i < attempt; i++ {
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+
+		backoff *= 2
+	}
+
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	return backoff
+}
@@ -0,0 +1,64 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_RetryPolicy(t *testing.T) {
+	tt := []struct {
+		Name             string
+		Policy           client.RetryPolicy
+		DrainAfter       time.Duration
+		ExpectError      bool
+		ExpectedRetried  uint64
+		ExpectDisconnect bool
+	}{
+		{
+			Name:             "It should count a timeout as a failure without a policy",
+			DrainAfter:       time.Millisecond * 60,
+			ExpectError:      true,
+			ExpectDisconnect: true,
+		},
+		{
+			Name:            "It should retry a write that times out",
+			Policy:          client.RetryPolicy{Attempts: 3, Backoff: time.Millisecond * 10},
+			DrainAfter:      time.Millisecond * 60,
+			ExpectedRetried: 1,
+		},
+		{
+			Name:             "It should fail once every attempt has timed out",
+			Policy:           client.RetryPolicy{Attempts: 2, Backoff: time.Millisecond},
+			ExpectError:      true,
+			ExpectedRetried:  2,
+			ExpectDisconnect: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c := client.NewWithOptions(time.Millisecond*40, 1, "", client.WithQueueSize(1), client.WithRetryPolicy(tc.Policy))
+
+			// Fill the queue, so that the next write has to wait for space.
+			assert.NoError(t, c.Write([]byte("a")))
+
+			if tc.DrainAfter > 0 {
+				time.AfterFunc(tc.DrainAfter, func() { c.Next() })
+			}
+
+			err := c.Write([]byte("b"))
+
+			if tc.ExpectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.ExpectedRetried, c.Lag().Retried)
+			assert.Equal(t, tc.ExpectDisconnect, c.ShouldDisconnect())
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// The SlowPolicy type determines when a client is considered to be too slow to keep up
+	// with the events written to it, and what happens to new events once it is. A client is
+	// slow once its queue depth or lag has exceeded the configured limits for the sustained
+	// duration. The zero value never considers clients slow.
+	SlowPolicy struct {
+		MaxDepth int           // The number of queued events at which the client is falling behind. Zero means no limit.
+		MaxLag   time.Duration // The age of the oldest queued event at which the client is falling behind. Zero means no limit.
+		Sustain  time.Duration // How long the client must be falling behind before it is considered slow.
+		Action   SlowAction    // What to do with events written to the client once it is slow.
+	}
+
+	// SlowAction determines how new events are handled once a client is considered slow.
+	SlowAction int
+
+	// The Lag type describes how far behind a client is.
+	Lag struct {
+		Pending  int           // The number of events waiting to be delivered to the client.
+		Delay    time.Duration // How long the oldest pending event has been waiting.
+		Skipped  uint64        // The number of events that were not queued because the client was slow.
+		Dropped  uint64        // The number of events discarded because the client's queue or pause buffer was full.
+		Held     int           // The number of events held while the client is paused.
+		Retried  uint64        // The number of writes that were retried after timing out, see the RetryPolicy type.
+		Replaced uint64        // The number of queued or held events replaced by a later event with the same key.
+		Slow     bool          // Whether the client is currently considered slow.
+	}
+)
+
+const (
+	// ActionSkip discards new events until the client has caught up.
+	ActionSkip SlowAction = iota
+
+	// ActionDisconnect forcefully disconnects the client.
+	ActionDisconnect
+
+	// ActionSnapshot downgrades the client to only receiving the most recent event for each
+	// topic, replacing any event for the same topic that is still queued.
+	ActionSnapshot
+)
+
+// WithSlowPolicy sets the policy used to detect & handle a client that cannot keep up with
+// the events written to it.
+func WithSlowPolicy(p SlowPolicy) Option {
+	return func(c *Client) {
+		c.slowPolicy = p
+	}
+}
+
+// Lag returns how far behind the client is.
+func (c *Client) Lag() Lag {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return Lag{
+		Pending:  len(c.queue),
+		Delay:    c.delay(c.clock.Now()),
+		Skipped:  atomic.LoadUint64(&c.skipped),
+		Dropped:  atomic.LoadUint64(&c.dropped),
+		Held:     len(c.held),
+		Retried:  atomic.LoadUint64(&c.retried),
+		Replaced: atomic.LoadUint64(&c.replaced),
+		Slow:     c.isSlow(c.clock.Now()),
+	}
+}
+
+// checkSlow updates whether the client is falling behind & returns true if it has been
+// doing so for the sustained duration of the policy. It must be called while holding
+// the client's lock.
+func (c *Client) checkSlow(now time.Time) bool {
+	p := c.slowPolicy
+
+	behind := (p.MaxDepth > 0 && len(c.queue) >= p.MaxDepth) ||
+		(p.MaxLag > 0 && c.delay(now) >= p.MaxLag)
+
+	if !behind {
+		c.behindSince = time.Time{}
+		return false
+	}
+
+	if c.behindSince.IsZero() {
+		c.behindSince = now
+	}
+
+	return c.isSlow(now)
+}
+
+// isSlow returns true if the client has been falling behind for the sustained duration.
+// It must be called while holding the client's lock.
+func (c *Client) isSlow(now time.Time) bool {
+	return !c.behindSince.IsZero() && now.Sub(c.behindSince) >= c.slowPolicy.Sustain
+}
+
+// delay returns how long the oldest event in the queue has been waiting. It must be called
+// while holding the client's lock.
+func (c *Client) delay(now time.Time) time.Duration {
+	if len(c.queue) == 0 {
+		return 0
+	}
+
+	return now.Sub(c.queue[0].queued)
+}
+
+// collapse removes any queued events for the given topic, so that only the most recent
+// event for the topic is delivered. Events that a writer is waiting to hand off are kept.
+// It must be called while holding the client's lock.
+func (c *Client) collapse(topic string) {
+	out := c.queue[:0]
+
+	for _, e := range c.queue {
+		if e.taken == nil && e.event.Topic == topic {
+			continue
+		}
+
+		out = append(out, e)
+	}
+
+	for i := len(out); i < len(c.queue); i++ {
+		c.queue[i] = nil
+	}
+
+	c.queue = out
+}
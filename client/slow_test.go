@@ -0,0 +1,80 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_SlowPolicy(t *testing.T) {
+	tt := []struct {
+		Policy           client.SlowPolicy
+		Events           []event.Event
+		ExpectedPending  []string
+		ExpectedSkipped  uint64
+		ExpectDisconnect bool
+		ExpectedError    string
+	}{
+		{
+			// Without a policy, every event is queued.
+			Events:          []event.Event{{Data: []byte("a")}, {Data: []byte("b")}, {Data: []byte("c")}},
+			ExpectedPending: []string{"a", "b", "c"},
+		},
+		{
+			Policy:          client.SlowPolicy{MaxDepth: 2, Action: client.ActionSkip},
+			Events:          []event.Event{{Data: []byte("a")}, {Data: []byte("b")}, {Data: []byte("c")}, {Data: []byte("d")}},
+			ExpectedPending: []string{"a", "b"},
+			ExpectedSkipped: 2,
+		},
+		{
+			Policy:           client.SlowPolicy{MaxDepth: 2, Action: client.ActionDisconnect},
+			Events:           []event.Event{{Data: []byte("a")}, {Data: []byte("b")}, {Data: []byte("c")}},
+			ExpectedPending:  []string{"a", "b"},
+			ExpectDisconnect: true,
+			ExpectedError:    "too slow",
+		},
+		{
+			Policy: client.SlowPolicy{MaxDepth: 2, Action: client.ActionSnapshot},
+			Events: []event.Event{
+				{Topic: "a", Data: []byte("a1")},
+				{Topic: "b", Data: []byte("b1")},
+				{Topic: "a", Data: []byte("a2")},
+				{Topic: "a", Data: []byte("a3")},
+			},
+			ExpectedPending: []string{"b1", "a3"},
+		},
+		{
+			// The client must be behind for the sustained duration before it is slow.
+			Policy:          client.SlowPolicy{MaxDepth: 2, Sustain: time.Hour, Action: client.ActionSkip},
+			Events:          []event.Event{{Data: []byte("a")}, {Data: []byte("b")}, {Data: []byte("c")}},
+			ExpectedPending: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tc := range tt {
+		client := client.NewWithOptions(time.Second, 3, "", client.WithQueueSize(10), client.WithSlowPolicy(tc.Policy))
+
+		for _, e := range tc.Events {
+			if err := client.WriteEvent(e); err != nil {
+				assert.Contains(t, err.Error(), tc.ExpectedError)
+			}
+		}
+
+		pending := client.Pending(true)
+		data := make([]string, len(pending))
+
+		for i, p := range pending {
+			data[i] = string(p.Data)
+		}
+
+		lag := client.Lag()
+
+		assert.Equal(t, tc.ExpectedPending, data)
+		assert.Equal(t, len(tc.ExpectedPending), lag.Pending)
+		assert.Equal(t, tc.ExpectedSkipped, lag.Skipped)
+		assert.Equal(t, tc.ExpectDisconnect, client.ShouldDisconnect())
+	}
+}
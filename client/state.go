@@ -0,0 +1,128 @@
+package client
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+type (
+	// State describes where a client is in its lifecycle. A client starts connected, may be
+	// drained, & is eventually closed. States only move forwards.
+	State int32
+)
+
+const (
+	// StateConnected is the state of a client that accepts new events.
+	StateConnected State = iota
+
+	// StateDraining is the state of a client that no longer accepts new events, but whose queued
+	// events can still be taken. The client is closed once its queue is empty.
+	StateDraining
+
+	// StateClosed is the state of a client that no longer accepts new events & whose queued events
+	// have been discarded.
+	StateClosed
+)
+
+var (
+	// ErrDraining is the error returned when writing to a client that is draining.
+	ErrDraining = errors.New("client is draining")
+
+	// ErrClosed is the error returned when writing to a client that is closed.
+	ErrClosed = errors.New("client is closed")
+)
+
+// String returns a human readable name for the state.
+func (s State) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDraining:
+		return "draining"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns the client's current state.
+func (c *Client) State() State {
+	return State(atomic.LoadInt32(&c.state))
+}
+
+// Done returns a channel that is closed once the client is closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Drain stops the client accepting new events, while allowing those already queued to be taken.
+// The client is closed once its queue is empty, which may be immediately. Returns false if the
+// client was not connected.
+func (c *Client) Drain() bool {
+	if !atomic.CompareAndSwapInt32(&c.state, int32(StateConnected), int32(StateDraining)) {
+		return false
+	}
+
+	c.mux.Lock()
+	empty := len(c.queue) == 0
+	c.mux.Unlock()
+
+	if empty {
+		c.Close()
+	}
+
+	// Wake the reader, so that it notices the client is draining.
+	signal(c.ready)
+
+	return true
+}
+
+// Close stops the client accepting new events & discards any that are queued or held. Writers
+// waiting for space in the queue return ErrClosed. Returns false if the client was already
+// closed.
+func (c *Client) Close() bool {
+	for {
+		state := atomic.LoadInt32(&c.state)
+
+		if State(state) == StateClosed {
+			return false
+		}
+
+		if atomic.CompareAndSwapInt32(&c.state, state, int32(StateClosed)) {
+			break
+		}
+	}
+
+	c.mux.Lock()
+
+	for i, e := range c.queue {
+		// Writers waiting on a handoff remove their own entries.
+		if e.taken == nil {
+			releaseEntry(e)
+		}
+
+		c.queue[i] = nil
+	}
+
+	c.queue = nil
+	c.held = nil
+	c.mux.Unlock()
+
+	close(c.done)
+	signal(c.ready)
+
+	return true
+}
+
+// accepting returns the error to return to writers if the client no longer accepts new events.
+func (c *Client) accepting() error {
+	switch c.State() {
+	case StateDraining:
+		return ErrDraining
+	case StateClosed:
+		return ErrClosed
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,173 @@
+package client_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_State(t *testing.T) {
+	tt := []struct {
+		Name          string
+		Queued        int
+		Drain         bool
+		Close         bool
+		ExpectedState client.State
+		ExpectedError error
+	}{
+		{
+			Name:          "It should accept events when connected",
+			ExpectedState: client.StateConnected,
+		},
+		{
+			Name:          "It should reject events when draining",
+			Queued:        1,
+			Drain:         true,
+			ExpectedState: client.StateDraining,
+			ExpectedError: client.ErrDraining,
+		},
+		{
+			Name:          "It should close immediately when draining an empty queue",
+			Drain:         true,
+			ExpectedState: client.StateClosed,
+			ExpectedError: client.ErrClosed,
+		},
+		{
+			Name:          "It should reject events when closed",
+			Queued:        1,
+			Close:         true,
+			ExpectedState: client.StateClosed,
+			ExpectedError: client.ErrClosed,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c := client.NewWithOptions(time.Second, 1, "", client.WithQueueSize(10))
+
+			for i := 0; i < tc.Queued; i++ {
+				assert.NoError(t, c.Write([]byte("queued")))
+			}
+
+			if tc.Drain {
+				assert.True(t, c.Drain())
+			}
+
+			if tc.Close {
+				assert.True(t, c.Close())
+			}
+
+			assert.Equal(t, tc.ExpectedState, c.State())
+			assert.Equal(t, tc.ExpectedError, c.Write([]byte("test")))
+			assert.False(t, c.ShouldDisconnect())
+		})
+	}
+}
+
+func TestClient_DrainDeliversQueuedEvents(t *testing.T) {
+	c := client.NewWithOptions(time.Second, 1, "", client.WithQueueSize(10))
+
+	assert.NoError(t, c.Write([]byte("a")))
+	assert.NoError(t, c.Write([]byte("b")))
+	assert.True(t, c.Drain())
+	assert.False(t, c.Drain())
+
+	for _, expected := range []string{"a", "b"} {
+		e, ok := c.Next()
+		assert.True(t, ok)
+		assert.Equal(t, expected, string(e.Data))
+	}
+
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("client was not closed once drained")
+	}
+
+	assert.Equal(t, client.StateClosed, c.State())
+	assert.False(t, c.Close())
+}
+
+func TestClient_CloseReleasesWriters(t *testing.T) {
+	tt := []struct {
+		Name      string
+		QueueSize int
+	}{
+		{Name: "It should release a writer waiting for space", QueueSize: 1},
+		{Name: "It should release a writer waiting for its event to be taken"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c := client.NewWithOptions(time.Minute, 1, "", client.WithQueueSize(tc.QueueSize))
+
+			if tc.QueueSize > 0 {
+				assert.NoError(t, c.Write([]byte("a")))
+			}
+
+			errs := make(chan error, 1)
+			go func() { errs <- c.Write([]byte("b")) }()
+
+			time.Sleep(time.Millisecond * 20)
+			assert.True(t, c.Close())
+
+			select {
+			case err := <-errs:
+				assert.Equal(t, client.ErrClosed, err)
+			case <-time.After(time.Second):
+				t.Fatal("writer was not released")
+			}
+
+			_, ok := c.Next()
+			assert.False(t, ok)
+		})
+	}
+}
+
+// TestClient_ConcurrentAccess is intended to be run using the race detector.
+func TestClient_ConcurrentAccess(t *testing.T) {
+	c := client.NewWithOptions(time.Millisecond, 100, "", client.WithQueueSize(5), client.WithSlowPolicy(client.SlowPolicy{
+		MaxDepth: 2,
+		Action:   client.ActionSkip,
+	}))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				c.Write([]byte("test"))
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				c.ShouldDisconnect()
+				c.Lag()
+				c.State()
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				c.Next()
+			}
+		}()
+	}
+
+	wg.Wait()
+	c.Close()
+
+	assert.Equal(t, client.StateClosed, c.State())
+}
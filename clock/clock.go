@@ -0,0 +1,101 @@
+// Package clock abstracts the passage of time for the SSE broker & its clients, so that timeouts,
+// heartbeats & scheduled events can be driven by a fake clock in tests rather than by sleeping.
+// See the ssetest.Clock type for a clock that is advanced manually.
+package clock
+
+import (
+	"time"
+)
+
+type (
+	// The Clock interface describes a source of the current time & of timers that fire once a
+	// duration has elapsed.
+	Clock interface {
+		// Now returns the current time.
+		Now() time.Time
+
+		// NewTimer returns a timer that sends the current time on its channel once 'd' has elapsed.
+		NewTimer(d time.Duration) Timer
+
+		// NewTicker returns a ticker that sends the current time on its channel each time 'd'
+		// elapses. Ticks are dropped for slow receivers.
+		NewTicker(d time.Duration) Ticker
+
+		// AfterFunc calls 'fn' once 'd' has elapsed, returning a timer that can be used to cancel
+		// the call. The timer's channel is not used.
+		AfterFunc(d time.Duration, fn func()) Timer
+	}
+
+	// The Timer interface describes a single event, in the same way as the time.Timer type.
+	Timer interface {
+		// C returns the channel that the time is sent on once the timer fires.
+		C() <-chan time.Time
+
+		// Stop prevents the timer from firing, returning false if it has already fired or been
+		// stopped.
+		Stop() bool
+
+		// Reset changes the timer to fire once 'd' has elapsed, returning true if it had been
+		// active.
+		Reset(d time.Duration) bool
+	}
+
+	// The Ticker interface describes a repeating event, in the same way as the time.Ticker type.
+	Ticker interface {
+		// C returns the channel that the time is sent on each time the ticker fires.
+		C() <-chan time.Time
+
+		// Stop turns off the ticker. No more ticks are sent once it returns.
+		Stop()
+	}
+
+	realClock  struct{}
+	realTimer  struct{ t *time.Timer }
+	realTicker struct{ t *time.Ticker }
+)
+
+// Real returns the Clock that uses the system time, via the time package.
+func Real() Clock {
+	return realClock{}
+}
+
+// Since returns the time elapsed on the clock since 't'.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{t: time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{t: time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, fn func()) Timer {
+	return realTimer{t: time.AfterFunc(d, fn)}
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+func (t realTimer) Reset(d time.Duration) bool {
+	return t.t.Reset(d)
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTicker) Stop() {
+	t.t.Stop()
+}
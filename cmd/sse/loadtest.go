@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/davidsbond/sse/loadtest"
+)
+
+// runLoadTest connects simulated consumers to a broker & publishes events to it from simulated
+// publishers, printing a report of how quickly events fanned out & how many were dropped.
+func runLoadTest(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	connectURL := flags.String("connect-url", "http://localhost:8080/connect", "the URL of the broker's client handler")
+	publishURL := flags.String("publish-url", "http://localhost:8080/broadcast", "the URL of the broker's event handler")
+	topic := flags.String("topic", "", "the topic to subscribe & publish to")
+	consumers := flags.Int("consumers", 10, "the number of consumers to connect")
+	publishers := flags.Int("publishers", 1, "the number of publishers to publish from")
+	rate := flags.Int("rate", 100, "the number of events each publisher publishes per second, or zero for as fast as possible")
+	duration := flags.Duration("duration", 0, "how long to publish for (default 10s)")
+	size := flags.Int("size", 0, "the size of each event's data, in bytes")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := loadtest.Run(ctx, loadtest.Config{
+		ConnectURL:  *connectURL,
+		PublishURL:  *publishURL,
+		Topic:       *topic,
+		Consumers:   *consumers,
+		Publishers:  *publishers,
+		Rate:        *rate,
+		Duration:    *duration,
+		PayloadSize: *size,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(stdout, report)
+	return err
+}
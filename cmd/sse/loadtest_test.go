@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunLoadTest(t *testing.T) {
+	cnf := server.DefaultConfig()
+	cnf.Timeout = time.Millisecond * 100
+
+	srv := server.New(cnf)
+	defer srv.Broker().Close()
+
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var out bytes.Buffer
+
+	args := []string{
+		"-connect-url", ts.URL + "/connect",
+		"-publish-url", ts.URL + "/broadcast",
+		"-consumers", "2",
+		"-duration", "100ms",
+	}
+
+	assert.NoError(t, runLoadTest(ctx, args, strings.NewReader(""), &out))
+	assert.Contains(t, out.String(), "consumers=2")
+	assert.Contains(t, out.String(), "dropped=0")
+}
@@ -0,0 +1,85 @@
+// Command sse is a tool for testing & operating SSE brokers. It can run a standalone broker,
+// publish events to a broker, subscribe to a broker's event stream & measure a broker's
+// performance under load.
+//
+// Usage:
+//
+// sse serve -addr :8080 -config sse.yaml
+// sse publish -url http://localhost:8080/broadcast -topic news "hello world"
+// sse subscribe -url http://localhost:8080/connect -topic news
+// sse loadtest -consumers 100 -rate 50 -duration 30s
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+type (
+	// The command type describes a subcommand. Each subcommand parses its own flags from the
+	// arguments that follow its name.
+	command struct {
+		name    string
+		summary string
+		run     func(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error
+	}
+)
+
+var commands = []command{
+	{name: "serve", summary: "run a standalone broker", run: serve},
+	{name: "publish", summary: "publish an event to a broker", run: publish},
+	{name: "subscribe", summary: "print the events streamed by a broker", run: subscribe},
+	{name: "loadtest", summary: "measure a broker's fan-out latency & drops under load", run: runLoadTest},
+}
+
+func main() {
+	// Commands are stopped gracefully when interrupted.
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout)
+
+	// Usage has already been printed when help is requested.
+	if err == flag.ErrHelp {
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run executes the subcommand named by the first argument.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return flag.ErrHelp
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(ctx, args[1:], stdin, stdout)
+		}
+	}
+
+	usage(os.Stderr)
+
+	return fmt.Errorf("unknown command %v", args[0])
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: sse <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10v %v\n", cmd.name, cmd.summary)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "use 'sse <command> -h' for the flags of each command")
+}
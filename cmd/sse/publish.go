@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// publish posts an event to a broker's EventHandler. The event's data is taken from the arguments
+// following the flags, joined by spaces, or read from stdin if there are none.
+func publish(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("publish", flag.ContinueOnError)
+	target := flags.String("url", "http://localhost:8080/broadcast", "the URL of the broker's event handler")
+	topic := flags.String("topic", "", "the topic to broadcast the event to")
+	id := flags.String("id", "", "the identifier of the only client to send the event to")
+	priority := flags.String("priority", "", "the priority of the event, 'low', 'normal' or 'high'")
+	audience := flags.String("audience", "", "a selector limiting the event to clients with matching metadata")
+	key := flags.String("idempotency-key", "", "discards the event if it has already been published with this key")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var data []byte
+
+	if flags.NArg() > 0 {
+		data = []byte(strings.Join(flags.Args(), " "))
+	} else {
+		var err error
+
+		if data, err = ioutil.ReadAll(stdin); err != nil {
+			return err
+		}
+	}
+
+	u, err := url.Parse(*target)
+
+	if err != nil {
+		return err
+	}
+
+	// Only the parameters that were given are added, so a blank topic broadcasts to every client.
+	query := u.Query()
+
+	for name, value := range map[string]string{"topic": *topic, "id": *id, "priority": *priority, "audience": *audience} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(data))
+
+	if err != nil {
+		return err
+	}
+
+	if *key != "" {
+		req.Header.Set("Idempotency-Key", *key)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to publish event: %v: %v", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/server"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublish(t *testing.T) {
+	tt := []struct {
+		Name          string
+		Args          []string
+		Stdin         string
+		ExpectedData  []string
+		ExpectedTopic string
+	}{
+		{
+			Name:         "It should publish the arguments as the event's data",
+			Args:         []string{"hello", "world"},
+			ExpectedData: []string{"hello world"},
+		},
+		{
+			Name:         "It should publish stdin if there are no arguments",
+			Stdin:        "from stdin",
+			ExpectedData: []string{"from stdin"},
+		},
+		{
+			Name:          "It should publish to the given topic",
+			Args:          []string{"-topic", "news", "hello"},
+			ExpectedData:  []string{"hello"},
+			ExpectedTopic: "news",
+		},
+		{
+			Name: "It should not publish to clients not subscribed to the topic",
+			Args: []string{"-topic", "sport", "hello"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			srv := server.New(server.DefaultConfig())
+			b := srv.Broker()
+			defer b.Close()
+
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+
+			c := ssetest.NewClient("test", client.WithTopics("news"))
+			defer c.Close()
+
+			if !assert.NoError(t, b.Subscribe(c.Client)) {
+				return
+			}
+
+			args := append([]string{"-url", ts.URL + "/broadcast"}, tc.Args...)
+			err := publish(context.Background(), args, strings.NewReader(tc.Stdin), &bytes.Buffer{})
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			<-time.Tick(time.Millisecond * 100)
+
+			var data []string
+
+			for _, e := range c.Events() {
+				data = append(data, string(e.Data))
+				assert.Equal(t, tc.ExpectedTopic, e.Topic)
+			}
+
+			assert.Equal(t, tc.ExpectedData, data)
+		})
+	}
+}
+
+func TestPublishError(t *testing.T) {
+	srv := server.New(server.DefaultConfig())
+	defer srv.Broker().Close()
+
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	args := []string{"-url", ts.URL + "/broadcast", "-priority", "urgent", "hello"}
+	err := publish(context.Background(), args, strings.NewReader(""), &bytes.Buffer{})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "400")
+}
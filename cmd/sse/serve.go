@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/davidsbond/sse/server"
+)
+
+// serve runs a standalone broker until the context is cancelled, at which point connected clients
+// are given the broker's timeout to disconnect. The broker is configured using a YAML file &
+// environment variables, see the server package, with flags taking precedence over both.
+func serve(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	cnf, err := parseServeFlags(args)
+
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", cnf.Addr)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "listening on %v\n", listener.Addr())
+
+	return server.New(cnf).Serve(ctx, listener)
+}
+
+// parseServeFlags returns the server configuration described by the arguments given to the serve
+// command.
+func parseServeFlags(args []string) (server.Config, error) {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	path := flags.String("config", "", "the path to a YAML configuration file")
+	addr := flags.String("addr", "", "the address to listen on (default \":8080\")")
+	timeout := flags.Duration("timeout", 0, "how long to wait to write to a client (default 5s)")
+	tolerance := flags.Int("tolerance", 0, "the number of sequential errors before a client is disconnected (default 3)")
+	queueSize := flags.Int("queue-size", 0, "the number of events queued for each client")
+	storeSize := flags.Int("store-size", 0, "the number of events stored in memory & replayed to reconnecting clients")
+	certFile := flags.String("cert", "", "the path to a PEM encoded TLS certificate")
+	keyFile := flags.String("key", "", "the path to the PEM encoded private key of the TLS certificate")
+
+	if err := flags.Parse(args); err != nil {
+		return server.Config{}, err
+	}
+
+	cnf, err := server.LoadConfig(*path)
+
+	if err != nil {
+		return cnf, err
+	}
+
+	// Flags that were given override the configuration file & environment.
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cnf.Addr = *addr
+		case "timeout":
+			cnf.Timeout = *timeout
+		case "tolerance":
+			cnf.Tolerance = *tolerance
+		case "queue-size":
+			cnf.QueueSize = *queueSize
+		case "store-size":
+			cnf.StoreSize = *storeSize
+		case "cert":
+			cnf.TLS.CertFile = *certFile
+		case "key":
+			cnf.TLS.KeyFile = *keyFile
+		}
+	})
+
+	return cnf, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseServeFlags(t *testing.T) {
+	tt := []struct {
+		Name          string
+		Args          []string
+		ExpectsError  bool
+		ExpectedValue func() server.Config
+	}{
+		{
+			Name:          "It should use the defaults when no flags are given",
+			ExpectedValue: server.DefaultConfig,
+		},
+		{
+			Name: "It should override the defaults using flags",
+			Args: []string{"-addr", ":9090", "-timeout", "10s", "-store-size", "100", "-cert", "cert.pem", "-key", "key.pem"},
+			ExpectedValue: func() server.Config {
+				cnf := server.DefaultConfig()
+				cnf.Addr = ":9090"
+				cnf.Timeout = time.Second * 10
+				cnf.StoreSize = 100
+				cnf.TLS.CertFile = "cert.pem"
+				cnf.TLS.KeyFile = "key.pem"
+				return cnf
+			},
+		},
+		{
+			Name:         "It should return an error for a missing configuration file",
+			Args:         []string{"-config", "missing.yaml"},
+			ExpectsError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			cnf, err := parseServeFlags(tc.Args)
+
+			if tc.ExpectsError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedValue(), cnf)
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+)
+
+type (
+	// The stringsFlag type is a flag that can be given more than once, collecting each value.
+	stringsFlag []string
+)
+
+// String returns the values of the flag, separated by commas.
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set adds a value to the flag.
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// subscribe connects to a broker's ClientHandler & prints each event it receives until the stream
+// ends, the context is cancelled or the requested number of events have been received.
+func subscribe(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	var topics stringsFlag
+
+	flags := flag.NewFlagSet("subscribe", flag.ContinueOnError)
+	target := flags.String("url", "http://localhost:8080/connect", "the URL of the broker's client handler")
+	id := flags.String("id", "", "the identifier to connect with")
+	lastEventID := flags.String("last-event-id", "", "replays the events stored after this event")
+	count := flags.Int("count", 0, "exit after this many events have been received")
+	raw := flags.Bool("raw", false, "print each event as a line of JSON")
+	flags.Var(&topics, "topic", "a topic to subscribe to, may be given more than once")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	u, err := url.Parse(*target)
+
+	if err != nil {
+		return err
+	}
+
+	query := u.Query()
+
+	for _, topic := range topics {
+		query.Add("topic", topic)
+	}
+
+	if *id != "" {
+		query.Set("id", *id)
+	}
+
+	u.RawQuery = query.Encode()
+
+	addr := u.String()
+	last := *lastEventID
+	received := 0
+
+	handle := func(e event.Event) (bool, error) {
+		if err := printEvent(stdout, e, *raw); err != nil {
+			return false, err
+		}
+
+		received++
+
+		return *count <= 0 || received < *count, nil
+	}
+
+	for {
+		hint, err := readStream(ctx, addr, &last, handle)
+
+		if err != nil || hint == nil {
+			return err
+		}
+
+		// The broker is closing or moving us elsewhere, so reconnect where & when
+		// it tells us to, resuming from the last event we received.
+		addr = reconnectURL(addr, hint.URL)
+
+		select {
+		case <-time.After(hint.Delay):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// readStream connects to the broker's ClientHandler at the target URL & passes each event it
+// receives to 'fn', until the stream ends, the context is cancelled or 'fn' returns false. The
+// identifier of the last event received is kept in 'last'. If the broker asks the client to
+// reconnect, its hint is returned.
+func readStream(ctx context.Context, target string, last *string, fn func(e event.Event) (bool, error)) (*broker.ReconnectHint, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	if *last != "" {
+		req.Header.Set("Last-Event-ID", *last)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to subscribe: %v: %v", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	dec := protocol.NewDecoder(resp.Body)
+	chunks := protocol.NewReassembler()
+
+	for {
+		e, err := dec.Decode()
+
+		// Cancelling the context while waiting for an event ends the stream.
+		if err == io.EOF || ctx.Err() != nil {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if hint, ok := broker.ParseReconnectHint(e); ok {
+			return &hint, nil
+		}
+
+		if e.ID != "" {
+			*last = e.ID
+		}
+
+		e, ok := chunks.Add(e)
+
+		if !ok {
+			continue
+		}
+
+		if more, err := fn(e); err != nil || !more {
+			return nil, err
+		}
+	}
+}
+
+// reconnectURL returns the URL to reconnect to after the broker has sent a reconnect hint. If the
+// hint's URL has no query, the topics & identifier of the current URL are kept.
+func reconnectURL(current, hinted string) string {
+	if hinted == "" {
+		return current
+	}
+
+	next, err := url.Parse(hinted)
+
+	if err != nil {
+		return current
+	}
+
+	if prev, err := url.Parse(current); err == nil && next.RawQuery == "" {
+		next.RawQuery = prev.RawQuery
+	}
+
+	return next.String()
+}
+
+// printEvent writes the event to 'w'. Unless 'raw' is true, a line describing the event is written
+// followed by its data, with JSON data indented.
+func printEvent(w io.Writer, e event.Event, raw bool) error {
+	if raw {
+		return protocol.NewNDJSONEncoder(w).Encode(e)
+	}
+
+	typ := e.Type
+
+	if typ == "" {
+		typ = "message"
+	}
+
+	header := fmt.Sprintf("%v %v", time.Now().Format("15:04:05"), typ)
+
+	if e.ID != "" {
+		header += " id=" + e.ID
+	}
+
+	data := e.Data
+	var indented bytes.Buffer
+
+	if json.Valid(data) && json.Indent(&indented, data, "", "  ") == nil {
+		data = indented.Bytes()
+	}
+
+	_, err := fmt.Fprintf(w, "%v\n%s\n\n", header, data)
+	return err
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Args     []string
+		Events   []event.Event
+		Expected []string
+	}{
+		{
+			Name:     "It should print each event with its type & identifier",
+			Args:     []string{"-count", "2"},
+			Events:   []event.Event{{ID: "1", Type: "greeting", Data: []byte("hello")}, {Data: []byte("world")}},
+			Expected: []string{"greeting id=1\nhello\n", "message id=1\nworld\n"},
+		},
+		{
+			Name:     "It should indent JSON data",
+			Args:     []string{"-count", "1"},
+			Events:   []event.Event{{Data: []byte(`{"a":1}`)}},
+			Expected: []string{"message\n{\n  \"a\": 1\n}\n"},
+		},
+		{
+			Name:     "It should print raw events as JSON",
+			Args:     []string{"-count", "1", "-raw"},
+			Events:   []event.Event{{ID: "1", Data: []byte("hello")}},
+			Expected: []string{`"id":"1"`},
+		},
+		{
+			Name:     "It should only print events on the given topics",
+			Args:     []string{"-count", "1", "-topic", "news"},
+			Events:   []event.Event{{Topic: "sport", Data: []byte("goal")}, {Topic: "news", Data: []byte("headline")}},
+			Expected: []string{"headline"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Streams notice the client has disconnected within the timeout.
+			cnf := server.DefaultConfig()
+			cnf.Timeout = time.Millisecond * 100
+
+			srv := server.New(cnf)
+			b := srv.Broker()
+			defer b.Close()
+
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			var out bytes.Buffer
+			done := make(chan error, 1)
+
+			go func() {
+				args := append([]string{"-url", ts.URL + "/connect"}, tc.Args...)
+				done <- subscribe(ctx, args, strings.NewReader(""), &out)
+			}()
+
+			for b.Stats().Clients == 0 {
+				<-time.Tick(time.Millisecond * 10)
+			}
+
+			for _, e := range tc.Events {
+				assert.NoError(t, b.BroadcastEvent(e))
+			}
+
+			assert.NoError(t, <-done)
+
+			for _, expected := range tc.Expected {
+				assert.Contains(t, out.String(), expected)
+			}
+
+			assert.NotContains(t, out.String(), "goal")
+		})
+	}
+}
+
+func TestSubscribe_Reconnect(t *testing.T) {
+	cnf := server.DefaultConfig()
+	cnf.Timeout = time.Millisecond * 100
+
+	first, second := server.New(cnf), server.New(cnf)
+	defer first.Broker().Close()
+	defer second.Broker().Close()
+
+	ts1, ts2 := httptest.NewServer(first), httptest.NewServer(second)
+	defer ts1.Close()
+	defer ts2.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var out bytes.Buffer
+	done := make(chan error, 1)
+
+	go func() {
+		args := []string{"-url", ts1.URL + "/connect", "-count", "2", "-topic", "news"}
+		done <- subscribe(ctx, args, strings.NewReader(""), &out)
+	}()
+
+	for first.Broker().Stats().Clients == 0 {
+		<-time.Tick(time.Millisecond * 10)
+	}
+
+	assert.NoError(t, first.Broker().BroadcastEvent(event.Event{ID: "1", Topic: "news", Data: []byte("before")}))
+	assert.NoError(t, first.Broker().Reconnect(broker.ReconnectHint{URL: ts2.URL + "/connect", Delay: time.Millisecond * 10}))
+
+	// The topics of the original URL are kept when reconnecting.
+	for second.Broker().Stats().Topics["news"].Subscribers == 0 {
+		<-time.Tick(time.Millisecond * 10)
+	}
+
+	assert.NoError(t, second.Broker().BroadcastEvent(event.Event{Topic: "news", Data: []byte("after")}))
+	assert.NoError(t, <-done)
+	assert.Contains(t, out.String(), "before")
+	assert.Contains(t, out.String(), "after")
+}
@@ -0,0 +1,66 @@
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+type (
+	gzipCodec struct {
+		level int
+	}
+
+	zstdCodec struct {
+		level zstd.EncoderLevel
+	}
+
+	brotliCodec struct {
+		quality int
+	}
+)
+
+// Gzip returns a Codec that compresses streams using gzip at the given compression level.
+// See the compress/gzip package for valid levels.
+func Gzip(level int) Codec {
+	return &gzipCodec{level: level}
+}
+
+// Zstd returns a Codec that compresses streams using Zstandard. It typically achieves a
+// better compression ratio than gzip while using less CPU, which suits high volume JSON
+// streams.
+func Zstd() Codec {
+	return &zstdCodec{level: zstd.SpeedDefault}
+}
+
+// Brotli returns a Codec that compresses streams using brotli at the given quality, which
+// ranges from 0 (fastest) to 11 (smallest output).
+func Brotli(quality int) Codec {
+	return &brotliCodec{quality: quality}
+}
+
+func (c *gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (c *gzipCodec) NewWriter(w io.Writer) (Writer, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+func (c *zstdCodec) Name() string {
+	return "zstd"
+}
+
+func (c *zstdCodec) NewWriter(w io.Writer) (Writer, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(c.level), zstd.WithEncoderConcurrency(1))
+}
+
+func (c *brotliCodec) Name() string {
+	return "br"
+}
+
+func (c *brotliCodec) NewWriter(w io.Writer) (Writer, error) {
+	return brotli.NewWriterLevel(w, c.quality), nil
+}
@@ -0,0 +1,108 @@
+// Package compress contains codecs that can be used to compress the event streams sent
+// by the SSE broker.
+package compress
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+type (
+	// The Codec interface describes a compression algorithm that can be applied to an
+	// event stream.
+	Codec interface {
+		// Name returns the content-coding token for the codec, as used in the Accept-Encoding
+		// & Content-Encoding HTTP headers.
+		Name() string
+
+		// NewWriter returns a Writer that compresses data written to it before writing it
+		// to 'w'.
+		NewWriter(w io.Writer) (Writer, error)
+	}
+
+	// The Writer interface describes a compressing writer that can be flushed partway
+	// through a stream, so that events are not held back in the compressor's buffers.
+	Writer interface {
+		io.WriteCloser
+		Flush() error
+	}
+)
+
+// Negotiate selects the codec to use for a response based on the value of a request's
+// Accept-Encoding header. The codec with the highest quality value accepted by the client
+// is returned. When multiple codecs have the same quality value, the one that appears
+// first in 'codecs' is preferred. If the client does not accept any of the given codecs,
+// nil is returned.
+func Negotiate(header string, codecs []Codec) Codec {
+	accepted := parseAcceptEncoding(header)
+
+	var (
+		best    Codec
+		bestQ   float64
+		wildQ   = -1.0
+		hasWild bool
+	)
+
+	if q, ok := accepted["*"]; ok {
+		wildQ = q
+		hasWild = true
+	}
+
+	for _, codec := range codecs {
+		q, ok := accepted[strings.ToLower(codec.Name())]
+
+		// If the codec wasn't explicitly listed, fall back to the wildcard if one
+		// was given.
+		if !ok {
+			if !hasWild {
+				continue
+			}
+
+			q = wildQ
+		}
+
+		if q > bestQ {
+			best = codec
+			bestQ = q
+		}
+	}
+
+	return best
+}
+
+// parseAcceptEncoding converts an Accept-Encoding header into a map of content-coding
+// tokens to their quality values.
+func parseAcceptEncoding(header string) map[string]float64 {
+	out := make(map[string]float64)
+
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+
+		if part == "" {
+			continue
+		}
+
+		name, q := part, 1.0
+
+		if i := strings.Index(part, ";"); i >= 0 {
+			name = strings.TrimSpace(part[:i])
+
+			for _, param := range strings.Split(part[i+1:], ";") {
+				param = strings.TrimSpace(param)
+
+				if !strings.HasPrefix(param, "q=") {
+					continue
+				}
+
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		out[strings.ToLower(name)] = q
+	}
+
+	return out
+}
@@ -0,0 +1,106 @@
+package compress_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/davidsbond/sse/compress"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress_Negotiate(t *testing.T) {
+	codecs := []compress.Codec{
+		compress.Zstd(),
+		compress.Brotli(4),
+		compress.Gzip(gzip.DefaultCompression),
+	}
+
+	tt := []struct {
+		Header   string
+		Expected string
+	}{
+		{Header: "", Expected: ""},
+		{Header: "identity", Expected: ""},
+		{Header: "gzip", Expected: "gzip"},
+		{Header: "gzip, deflate, br", Expected: "br"},
+		{Header: "gzip, br, zstd", Expected: "zstd"},
+		{Header: "gzip;q=1.0, br;q=0.5, zstd;q=0.8", Expected: "gzip"},
+		{Header: "zstd;q=0, gzip", Expected: "gzip"},
+		{Header: "*", Expected: "zstd"},
+		{Header: "*;q=0.1, GZIP", Expected: "gzip"},
+	}
+
+	for _, tc := range tt {
+		codec := compress.Negotiate(tc.Header, codecs)
+
+		if tc.Expected == "" {
+			assert.Nil(t, codec)
+			continue
+		}
+
+		if assert.NotNil(t, codec) {
+			assert.Equal(t, tc.Expected, codec.Name())
+		}
+	}
+}
+
+func TestCompress_Codecs(t *testing.T) {
+	tt := []struct {
+		Codec  compress.Codec
+		Reader func(r io.Reader) (io.Reader, error)
+	}{
+		{
+			Codec: compress.Gzip(gzip.DefaultCompression),
+			Reader: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		{
+			Codec: compress.Zstd(),
+			Reader: func(r io.Reader) (io.Reader, error) {
+				return zstd.NewReader(r)
+			},
+		},
+		{
+			Codec: compress.Brotli(4),
+			Reader: func(r io.Reader) (io.Reader, error) {
+				return brotli.NewReader(r), nil
+			},
+		},
+	}
+
+	data := []byte("data: {\"hello\": \"world\"}\n\n")
+
+	for _, tc := range tt {
+		buf := &bytes.Buffer{}
+		w, err := tc.Codec.NewWriter(buf)
+
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		// Flushing should make the written data available to the reader before
+		// the stream is closed.
+		_, err = w.Write(data)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Flush())
+		assert.NotEqual(t, 0, buf.Len())
+		assert.NoError(t, w.Close())
+
+		r, err := tc.Reader(buf)
+
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		out, err := ioutil.ReadAll(r)
+
+		assert.NoError(t, err)
+		assert.Equal(t, data, out)
+	}
+}
@@ -0,0 +1,163 @@
+package consumer
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+type (
+	// The Backoff type determines how the consumer reconnects when its stream ends or cannot be
+	// opened. Each consecutive failure to open the stream doubles the wait, up to the maximum, and
+	// the count is reset once the stream is opened.
+	Backoff struct {
+		Initial    time.Duration // How long to wait before the first reconnection. Replaced by the stream's 'retry' field if it sends one. Defaults to 3 seconds.
+		Max        time.Duration // If non-zero, the longest time to wait between reconnections.
+		Jitter     float64       // The fraction of each wait that is randomised, such as 0.2 for up to 20% either way, so that consumers do not reconnect in lockstep.
+		MaxRetries int           // The number of times in a row the consumer tries to reopen the stream before giving up. Zero means the consumer never gives up.
+	}
+
+	// State describes the connection state of a consumer.
+	State int
+
+	// StateHook is a function that is called each time the consumer's connection state changes,
+	// such as to show the connection status to users. For the retrying & gave up states, 'err' is
+	// the error that ended the previous connection, if there was one. The function is called on the
+	// goroutine reading the stream & must not block.
+	StateHook func(state State, err error)
+
+	// The StatusError type is returned when the stream could not be opened because the server
+	// responded with an error status code.
+	StatusError struct {
+		Code int    // The status code of the response.
+		Body string // The body of the response, such as the error message.
+	}
+)
+
+const (
+	// StateConnecting is the state of a consumer that is opening its stream.
+	StateConnecting State = iota
+
+	// StateOpen is the state of a consumer whose stream has been opened.
+	StateOpen
+
+	// StateRetrying is the state of a consumer waiting to reconnect after its stream ended or could
+	// not be opened.
+	StateRetrying
+
+	// StateGaveUp is the state of a consumer that stopped reconnecting because its stream could not be
+	// opened within its maximum number of retries, or the server rejected it.
+	StateGaveUp
+
+	// StateClosed is the state of a consumer whose stream ended without it reconnecting, or whose
+	// context was cancelled.
+	StateClosed
+)
+
+const (
+	// defaultRetry is how long browsers wait before reconnecting if the stream has not set a
+	// reconnection time.
+	defaultRetry = time.Second * 3
+)
+
+// WithBackoff configures the consumer to reconnect when its stream ends or cannot be opened, in the
+// same way as a browser's EventSource, waiting between attempts according to the backoff. Servers that
+// respond with a client error, other than 429 Too Many Requests, are not retried. Without a backoff,
+// Run returns once the stream ends.
+func WithBackoff(b Backoff) Option {
+	return func(c *Consumer) {
+		c.backoff = &b
+	}
+}
+
+// WithStateHook configures a function that is called each time the consumer's connection state
+// changes.
+func WithStateHook(fn StateHook) Option {
+	return func(c *Consumer) {
+		c.onState = fn
+	}
+}
+
+// String returns a human readable name for the state.
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateOpen:
+		return "open"
+	case StateRetrying:
+		return "retrying"
+	case StateGaveUp:
+		return "gave up"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// Error returns a description of the response.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to connect: %v %v: %v", e.Code, http.StatusText(e.Code), e.Body)
+}
+
+// Temporary determines if the request may succeed if it is retried, which is the case for server
+// errors & responses asking the consumer to slow down.
+func (e *StatusError) Temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= http.StatusInternalServerError
+}
+
+// delay returns how long to wait before reconnecting after the given number of consecutive failures
+// to open the stream. If the stream has set a reconnection time, it is used in place of the initial
+// wait.
+func (b Backoff) delay(failures int, retry time.Duration) time.Duration {
+	delay := b.Initial
+
+	if retry > 0 {
+		delay = retry
+	}
+
+	if delay <= 0 {
+		delay = defaultRetry
+	}
+
+	for i := 0; i < failures; i++ {
+		if b.Max > 0 && delay >= b.Max {
+			break
+		}
+
+		delay *= 2
+	}
+
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+
+	if b.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * b.Jitter * float64(delay))
+	}
+
+	return delay
+}
+
+// retryable determines if the consumer should reconnect after its stream ended with the error.
+func (c *Consumer) retryable(err error) bool {
+	if err, ok := err.(*StatusError); ok {
+		// A refreshed token may be accepted where the last one was not.
+		if err.Code == http.StatusUnauthorized && c.token != nil {
+			return true
+		}
+
+		return err.Temporary()
+	}
+
+	return true
+}
+
+// setState reports the consumer's new connection state, if it has a state hook.
+func (c *Consumer) setState(state State, err error) {
+	if c.onState != nil {
+		c.onState(state, err)
+	}
+}
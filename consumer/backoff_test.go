@@ -0,0 +1,227 @@
+package consumer_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/consumer"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// stateRecorder records the states reported by a consumer.
+	stateRecorder struct {
+		mux    sync.Mutex
+		states []consumer.State
+	}
+)
+
+func (r *stateRecorder) hook(state consumer.State, err error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.states = append(r.states, state)
+}
+
+func (r *stateRecorder) Last() consumer.State {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return r.states[len(r.states)-1]
+}
+
+func (r *stateRecorder) Contains(state consumer.State) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	for _, s := range r.states {
+		if s == state {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestConsumer_Backoff(t *testing.T) {
+	tt := []struct {
+		Name          string
+		Status        int
+		Backoff       consumer.Backoff
+		ExpectedTries int32
+		ExpectedCode  int
+	}{
+		{
+			Name:          "It should give up after the maximum number of retries",
+			Status:        http.StatusServiceUnavailable,
+			Backoff:       consumer.Backoff{Initial: time.Millisecond, MaxRetries: 2},
+			ExpectedTries: 3,
+			ExpectedCode:  http.StatusServiceUnavailable,
+		},
+		{
+			Name:          "It should retry when told to slow down",
+			Status:        http.StatusTooManyRequests,
+			Backoff:       consumer.Backoff{Initial: time.Millisecond, Jitter: 0.5, MaxRetries: 1},
+			ExpectedTries: 2,
+			ExpectedCode:  http.StatusTooManyRequests,
+		},
+		{
+			Name:          "It should not retry client errors",
+			Status:        http.StatusNotFound,
+			Backoff:       consumer.Backoff{Initial: time.Millisecond, MaxRetries: 5},
+			ExpectedTries: 1,
+			ExpectedCode:  http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var tries int32
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&tries, 1)
+				http.Error(w, "unavailable", tc.Status)
+			}))
+			defer ts.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			states := &stateRecorder{}
+			err := consumer.New(ts.URL, consumer.WithBackoff(tc.Backoff), consumer.WithStateHook(states.hook)).Run(ctx)
+
+			if assert.Error(t, err) {
+				serr, ok := err.(*consumer.StatusError)
+
+				if assert.True(t, ok) {
+					assert.Equal(t, tc.ExpectedCode, serr.Code)
+					assert.Equal(t, "unavailable", serr.Body)
+				}
+			}
+
+			assert.Equal(t, tc.ExpectedTries, atomic.LoadInt32(&tries))
+			assert.Equal(t, consumer.StateGaveUp, states.Last())
+			assert.False(t, states.Contains(consumer.StateOpen))
+		})
+	}
+}
+
+func TestConsumer_BackoffRecovers(t *testing.T) {
+	var tries int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&tries, 1) <= 2 {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: hello\n\n")
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	states := &stateRecorder{}
+	received := make(chan string, 1)
+
+	c := consumer.New(ts.URL,
+		consumer.WithBackoff(consumer.Backoff{Initial: time.Millisecond, Max: time.Millisecond * 5, MaxRetries: 2}),
+		consumer.WithStateHook(states.hook))
+
+	c.On("message", func(e event.Event) error {
+		select {
+		case received <- string(e.Data):
+		default:
+		}
+
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	select {
+	case data := <-received:
+		assert.Equal(t, "hello", data)
+	case <-ctx.Done():
+		t.Fatal("event was not received after retrying")
+	}
+
+	// The stream ends after each event, so the consumer keeps reconnecting without giving up, as
+	// each successful connection resets its retries.
+	for atomic.LoadInt32(&tries) < 6 {
+		<-time.After(time.Millisecond * 10)
+	}
+
+	cancel()
+
+	assert.NoError(t, <-done)
+	assert.True(t, states.Contains(consumer.StateRetrying))
+	assert.True(t, states.Contains(consumer.StateOpen))
+	assert.False(t, states.Contains(consumer.StateGaveUp))
+	assert.Equal(t, consumer.StateClosed, states.Last())
+}
+
+func TestConsumer_BackoffHonoursRetry(t *testing.T) {
+	var tries int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		if atomic.AddInt32(&tries, 1) == 1 {
+			fmt.Fprint(w, "retry: 10\n\n")
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// The initial wait is far longer than the test, so the consumer only reconnects in time if it
+	// uses the stream's reconnection time.
+	c := consumer.New(ts.URL, consumer.WithBackoff(consumer.Backoff{Initial: time.Hour}))
+
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	for atomic.LoadInt32(&tries) < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("consumer did not reconnect using the stream's retry field")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestState_String(t *testing.T) {
+	tt := []struct {
+		Name     string
+		State    consumer.State
+		Expected string
+	}{
+		{Name: "It should name the connecting state", State: consumer.StateConnecting, Expected: "connecting"},
+		{Name: "It should name the open state", State: consumer.StateOpen, Expected: "open"},
+		{Name: "It should name the retrying state", State: consumer.StateRetrying, Expected: "retrying"},
+		{Name: "It should name the gave up state", State: consumer.StateGaveUp, Expected: "gave up"},
+		{Name: "It should name the closed state", State: consumer.StateClosed, Expected: "closed"},
+		{Name: "It should name unknown states", State: consumer.State(99), Expected: "unknown"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, tc.State.String())
+		})
+	}
+}
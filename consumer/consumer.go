@@ -0,0 +1,344 @@
+// Package consumer contains a client that reads events from a Server Sent Events stream & dispatches
+// them to handlers registered for their type, in the same way as a browser's EventSource. It can be
+// used by Go services to consume the broker's streams, or any other SSE endpoint.
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+	"golang.org/x/sync/errgroup"
+)
+
+type (
+	// The Consumer type reads events from an SSE stream & dispatches them to the handlers registered
+	// for their type, in the order they are received. Events without a type are dispatched to handlers
+	// registered for the 'message' type, as they are in a browser. Chunked payloads are reassembled
+	// before they are dispatched. If the broker sends an 'sse:reconnect' event, the consumer reconnects
+	// where & when it is told to, resuming from the last event it received. Events numbered by the
+	// broker are checked for gaps, reconnecting to have any missed events replayed.
+	Consumer struct {
+		url         string
+		buffer      int
+		mux         sync.RWMutex
+		handlers    map[string][]Handler
+		lastEventID string
+		backoff     *Backoff
+		onState     StateHook
+		retry       time.Duration
+		client      *http.Client
+		header      http.Header
+		token       TokenFunc
+		proxy       *url.URL
+		sequences   map[string]uint64
+		recovering  bool
+		onGap       GapHook
+	}
+
+	// Handler is a function that handles an event received from the stream. If it returns an error,
+	// the consumer stops & the error is returned from its Run method.
+	Handler func(e event.Event) error
+
+	// Option is a function that modifies the consumer's optional configuration.
+	Option func(*Consumer)
+)
+
+const (
+	// The type dispatched to for events without a type.
+	messageType = "message"
+)
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// New creates a new instance of the Consumer type that reads events from the stream at the given
+// URL, such as a broker's ClientHandler. No connection is made until the Run method is called.
+func New(url string, opts ...Option) *Consumer {
+	c := &Consumer{
+		url:       url,
+		buffer:    64,
+		handlers:  make(map[string][]Handler),
+		client:    http.DefaultClient,
+		header:    make(http.Header),
+		sequences: make(map[string]uint64),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.proxy != nil {
+		c.client = proxied(c.client, c.proxy)
+	}
+
+	return c
+}
+
+// WithLastEventID configures the identifier of the last event the consumer received, so that the
+// events stored after it are replayed when it first connects.
+func WithLastEventID(id string) Option {
+	return func(c *Consumer) {
+		c.lastEventID = id
+	}
+}
+
+// WithBuffer configures the number of events that are read from the stream ahead of the handlers,
+// so that slow handlers do not hold up the stream. Defaults to 64.
+func WithBuffer(size int) Option {
+	return func(c *Consumer) {
+		if size >= 0 {
+			c.buffer = size
+		}
+	}
+}
+
+// On registers a function that is called with each event of the given type, in the same way as
+// EventSource.addEventListener. Use the 'message' type for events without a type. Handlers for the
+// same type are called in the order they were registered.
+func (c *Consumer) On(eventType string, fn Handler) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.handlers[eventType] = append(c.handlers[eventType], fn)
+}
+
+// OnJSON registers a function that is called with the data of each event of the given type, decoded
+// from JSON into the function's parameter. The function must be of the form func(T) or func(T) error,
+// such as func(p Price) error, otherwise OnJSON panics. Events whose data cannot be decoded stop the
+// consumer with an error, in the same way as an error returned by the function.
+func (c *Consumer) OnJSON(eventType string, fn interface{}) {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() > 1 || (t.NumOut() == 1 && t.Out(0) != errorType) {
+		panic(fmt.Sprintf("consumer: OnJSON requires a func(T) or func(T) error, got %v", t))
+	}
+
+	in := t.In(0)
+
+	c.On(eventType, func(e event.Event) error {
+		value := reflect.New(in)
+
+		if err := json.Unmarshal(e.Data, value.Interface()); err != nil {
+			return fmt.Errorf("failed to decode %v event %q: %v", eventType, e.ID, err)
+		}
+
+		out := v.Call([]reflect.Value{value.Elem()})
+
+		if len(out) == 1 && !out[0].IsNil() {
+			return out[0].Interface().(error)
+		}
+
+		return nil
+	})
+}
+
+// LastEventID returns the identifier of the last event received from the stream.
+func (c *Consumer) LastEventID() string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return c.lastEventID
+}
+
+// Run connects to the stream & dispatches events to the registered handlers until the stream ends
+// or the context is cancelled, in which case nil is returned. Events are read & dispatched on their
+// own goroutines, managed by an errgroup, so an error from either stops both. If the stream cannot be
+// opened or a handler returns an error, it is returned. Consumers configured with a backoff reconnect
+// instead, until they give up.
+func (c *Consumer) Run(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+	events := make(chan event.Event, c.buffer)
+
+	g.Go(func() error {
+		defer close(events)
+
+		return c.read(ctx, events)
+	})
+
+	g.Go(func() error {
+		for e := range events {
+			if err := c.dispatch(e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return g.Wait()
+}
+
+// read reads events from the stream into the channel, reconnecting when the broker sends a
+// reconnect hint, or when the stream ends if the consumer has a backoff.
+func (c *Consumer) read(ctx context.Context, events chan<- event.Event) error {
+	target := c.url
+	failures := 0
+
+	for {
+		c.setState(StateConnecting, nil)
+		hint, opened, err := c.stream(ctx, target, events)
+
+		// Cancelling the context, including when a handler fails, ends the stream.
+		if ctx.Err() != nil {
+			c.setState(StateClosed, nil)
+			return nil
+		}
+
+		if opened {
+			failures = 0
+		}
+
+		var delay time.Duration
+
+		switch {
+		case hint != nil:
+			target = reconnectURL(target, hint.URL)
+			delay = hint.Delay
+		case err == ErrMissedEvents:
+			// Reconnect immediately to have the missed events replayed.
+		case c.backoff == nil:
+			c.setState(StateClosed, err)
+			return err
+		case !c.retryable(err):
+			c.setState(StateGaveUp, err)
+			return err
+		case c.backoff.MaxRetries > 0 && failures >= c.backoff.MaxRetries:
+			c.setState(StateGaveUp, err)
+			return err
+		default:
+			delay = c.backoff.delay(failures, c.retry)
+			failures++
+		}
+
+		c.setState(StateRetrying, err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			c.setState(StateClosed, nil)
+			return nil
+		}
+	}
+}
+
+// stream reads events from a single connection to the target URL into the channel, until the stream
+// ends. If the broker asks the consumer to reconnect, its hint is returned. The returned boolean
+// indicates whether the stream was opened.
+func (c *Consumer) stream(ctx context.Context, target string, events chan<- event.Event) (*broker.ReconnectHint, bool, error) {
+	req, err := c.request(ctx, target)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := c.client.Do(req)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, false, &StatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	c.setState(StateOpen, nil)
+
+	dec := protocol.NewDecoder(resp.Body)
+	chunks := protocol.NewReassembler()
+
+	for {
+		e, err := dec.Decode()
+
+		if retry := dec.Retry(); retry > 0 {
+			c.retry = retry
+		}
+
+		if err == io.EOF {
+			return nil, true, nil
+		} else if err != nil {
+			return nil, true, err
+		}
+
+		if hint, ok := broker.ParseReconnectHint(e); ok {
+			return &hint, true, nil
+		}
+
+		if err := c.sequence(e); err != nil {
+			return nil, true, err
+		}
+
+		c.mux.Lock()
+		c.lastEventID = dec.LastEventID()
+		c.mux.Unlock()
+
+		e, ok := chunks.Add(e)
+
+		if !ok {
+			continue
+		}
+
+		select {
+		case events <- e:
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		}
+	}
+}
+
+// dispatch calls each of the handlers registered for the event's type.
+func (c *Consumer) dispatch(e event.Event) error {
+	typ := e.Type
+
+	if typ == "" {
+		typ = messageType
+	}
+
+	c.mux.RLock()
+	handlers := c.handlers[typ]
+	c.mux.RUnlock()
+
+	for _, fn := range handlers {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reconnectURL returns the URL to reconnect to after the broker has sent a reconnect hint. If the
+// hint's URL has no query, the query of the current URL is kept.
+func reconnectURL(current, hinted string) string {
+	if hinted == "" {
+		return current
+	}
+
+	next, err := url.Parse(hinted)
+
+	if err != nil {
+		return current
+	}
+
+	if prev, err := url.Parse(current); err == nil && next.RawQuery == "" {
+		next.RawQuery = prev.RawQuery
+	}
+
+	return next.String()
+}
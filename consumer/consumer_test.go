@@ -0,0 +1,208 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/consumer"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	price struct {
+		Symbol string  `json:"symbol"`
+		Price  float64 `json:"price"`
+	}
+)
+
+// connected waits until the broker has the given number of clients.
+func connected(t *testing.T, b broker.Broker, n int) {
+	deadline := time.Now().Add(time.Second * 5)
+
+	for b.Stats().Clients != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %v clients, got %v", n, b.Stats().Clients)
+		}
+
+		<-time.After(time.Millisecond * 10)
+	}
+}
+
+func TestConsumer_On(t *testing.T) {
+	brk := broker.New(time.Second, 3, nil)
+	defer brk.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(brk.ClientHandler))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var (
+		messages []string
+		prices   []price
+	)
+
+	c := consumer.New(ts.URL)
+
+	c.On("message", func(e event.Event) error {
+		messages = append(messages, string(e.Data))
+		return nil
+	})
+
+	c.OnJSON("price", func(p price) error {
+		prices = append(prices, p)
+
+		// Stop once every event has been received.
+		cancel()
+		return nil
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+
+	connected(t, brk, 1)
+
+	assert.NoError(t, brk.BroadcastEvent(event.Event{ID: "1", Data: []byte("hello")}))
+	assert.NoError(t, brk.BroadcastEvent(event.Event{ID: "2", Type: "ignored", Data: []byte("skip")}))
+	assert.NoError(t, brk.BroadcastEvent(event.Event{ID: "3", Type: "price", Data: []byte(`{"symbol":"AAPL","price":182.5}`)}))
+
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"hello"}, messages)
+	assert.Equal(t, []price{{Symbol: "AAPL", Price: 182.5}}, prices)
+	assert.Equal(t, "3", c.LastEventID())
+}
+
+func TestConsumer_Errors(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Register func(c *consumer.Consumer)
+		Event    event.Event
+		Expected string
+	}{
+		{
+			Name: "It should return errors from handlers",
+			Register: func(c *consumer.Consumer) {
+				c.On("message", func(e event.Event) error { return errors.New("failed") })
+			},
+			Event:    event.Event{Data: []byte("hello")},
+			Expected: "failed",
+		},
+		{
+			Name: "It should return errors decoding JSON",
+			Register: func(c *consumer.Consumer) {
+				c.OnJSON("price", func(p price) {})
+			},
+			Event:    event.Event{ID: "1", Type: "price", Data: []byte("not json")},
+			Expected: `failed to decode price event "1"`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			brk := broker.New(time.Second, 3, nil)
+			defer brk.Close()
+
+			ts := httptest.NewServer(http.HandlerFunc(brk.ClientHandler))
+			defer ts.Close()
+
+			c := consumer.New(ts.URL)
+			tc.Register(c)
+
+			done := make(chan error, 1)
+			go func() { done <- c.Run(context.Background()) }()
+
+			connected(t, brk, 1)
+			assert.NoError(t, brk.BroadcastEvent(tc.Event))
+
+			select {
+			case err := <-done:
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.Expected)
+				}
+			case <-time.After(time.Second * 5):
+				t.Fatal("consumer did not stop")
+			}
+		})
+	}
+}
+
+func TestConsumer_OnJSONInvalidHandler(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Handler interface{}
+	}{
+		{Name: "It should reject functions with more than one parameter", Handler: func(a, b price) {}},
+		{Name: "It should reject functions returning other values", Handler: func(p price) int { return 0 }},
+		{Name: "It should reject values that are not functions", Handler: "not a function"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			defer func() {
+				assert.NotNil(t, recover())
+			}()
+
+			consumer.New("http://localhost").OnJSON("price", tc.Handler)
+		})
+	}
+}
+
+func TestConsumer_Reconnect(t *testing.T) {
+	first := broker.New(time.Second, 3, nil)
+	defer first.Close()
+
+	second := broker.New(time.Second, 3, nil)
+	defer second.Close()
+
+	ts1 := httptest.NewServer(http.HandlerFunc(first.ClientHandler))
+	defer ts1.Close()
+
+	ts2 := httptest.NewServer(http.HandlerFunc(second.ClientHandler))
+	defer ts2.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	c := consumer.New(ts1.URL)
+	received := make(chan string, 2)
+
+	c.On("message", func(e event.Event) error {
+		received <- string(e.Data)
+		return nil
+	})
+
+	go c.Run(ctx)
+
+	connected(t, first, 1)
+	assert.NoError(t, first.Reconnect(broker.ReconnectHint{URL: ts2.URL, Delay: time.Millisecond * 10}))
+
+	connected(t, second, 1)
+	assert.NoError(t, second.Broadcast([]byte("moved")))
+
+	select {
+	case data := <-received:
+		assert.Equal(t, "moved", data)
+	case <-ctx.Done():
+		t.Fatal("event was not received after reconnecting")
+	}
+}
+
+func TestConsumer_ConnectError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no streams here", http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	err := consumer.New(ts.URL).Run(context.Background())
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no streams here")
+	}
+}
@@ -0,0 +1,58 @@
+package consumer
+
+import (
+	"errors"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// GapHook is a function that is called when the consumer has missed events that could not be
+	// replayed, with the topic & the range of sequence numbers that were missed, inclusive. Events
+	// broadcast to all clients have a blank topic. The function is called on the goroutine reading
+	// the stream & must not block.
+	GapHook func(topic string, from, to uint64)
+)
+
+var (
+	// ErrMissedEvents is reported to the state hook when the consumer reconnects to have events it
+	// missed replayed.
+	ErrMissedEvents = errors.New("missed events")
+)
+
+// WithGapHook configures a function that is called when the consumer has missed events that could
+// not be replayed, such as when the broker has no store or has discarded them.
+func WithGapHook(fn GapHook) Option {
+	return func(c *Consumer) {
+		c.onGap = fn
+	}
+}
+
+// sequence checks the event's sequence number against the last received for its topic. If events
+// were missed & the consumer can resume from the last event it received, ErrMissedEvents is
+// returned so that it reconnects to have them replayed. If they were still missed after
+// reconnecting, the consumer's gap hook is called instead.
+func (c *Consumer) sequence(e event.Event) error {
+	if e.Sequence == 0 {
+		return nil
+	}
+
+	// Numbers lower than expected are not gaps, the broker may have restarted.
+	last, ok := c.sequences[e.Topic]
+
+	if ok && e.Sequence > last+1 {
+		if !c.recovering && c.LastEventID() != "" {
+			c.recovering = true
+			return ErrMissedEvents
+		}
+
+		if c.onGap != nil {
+			c.onGap(e.Topic, last+1, e.Sequence-1)
+		}
+	}
+
+	c.recovering = false
+	c.sequences[e.Topic] = e.Sequence
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package consumer_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/consumer"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumer_Gaps(t *testing.T) {
+	tt := []struct {
+		Name         string
+		Streams      map[string]string
+		ExpectedData []string
+		ExpectedGaps []string
+	}{
+		{
+			Name: "It should replay missed events",
+			Streams: map[string]string{
+				"":  "id: 1\nseq: 1 prices\ndata: a\n\nid: 3\nseq: 3 prices\ndata: c\n\n",
+				"1": "id: 2\nseq: 2 prices\ndata: b\n\nid: 3\nseq: 3 prices\ndata: c\n\n",
+			},
+			ExpectedData: []string{"a", "b", "c"},
+		},
+		{
+			Name: "It should report events that cannot be replayed",
+			Streams: map[string]string{
+				"":  "id: 1\nseq: 1 prices\ndata: a\n\nid: 3\nseq: 3 prices\ndata: c\n\n",
+				"1": "id: 3\nseq: 3 prices\ndata: c\n\n",
+			},
+			ExpectedData: []string{"a", "c"},
+			ExpectedGaps: []string{"prices 2-2"},
+		},
+		{
+			Name: "It should track each topic separately",
+			Streams: map[string]string{
+				"": "id: 1\nseq: 1 prices\ndata: a\n\nid: 2\nseq: 1 news\ndata: b\n\nid: 3\nseq: 2 prices\ndata: c\n\n",
+			},
+			ExpectedData: []string{"a", "b", "c"},
+		},
+		{
+			Name: "It should accept numbers starting again",
+			Streams: map[string]string{
+				"": "id: 1\nseq: 5\ndata: a\n\nid: 2\nseq: 1\ndata: b\n\nid: 3\nseq: 2\ndata: c\n\n",
+			},
+			ExpectedData: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, tc.Streams[r.Header.Get("Last-Event-ID")])
+			}))
+			defer ts.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			var (
+				data []string
+				gaps []string
+			)
+
+			c := consumer.New(ts.URL, consumer.WithGapHook(func(topic string, from, to uint64) {
+				gaps = append(gaps, fmt.Sprintf("%v %v-%v", topic, from, to))
+			}))
+
+			c.On("message", func(e event.Event) error {
+				data = append(data, string(e.Data))
+				return nil
+			})
+
+			assert.NoError(t, c.Run(ctx))
+			assert.Equal(t, tc.ExpectedData, data)
+			assert.Equal(t, tc.ExpectedGaps, gaps)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package consumer
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type (
+	// TokenFunc is a function that returns the bearer token the consumer sends in the 'Authorization'
+	// header each time it connects. It is called before every connection, so it can refresh tokens
+	// that have expired, returning a cached token otherwise.
+	TokenFunc func(ctx context.Context) (string, error)
+)
+
+// WithHTTPClient configures the HTTP client used to connect to the stream, such as one with custom TLS
+// configuration or a transport that signs requests. The client should not have a timeout, as it would
+// end the stream. Defaults to http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Consumer) {
+		if client != nil {
+			c.client = client
+		}
+	}
+}
+
+// WithHeader configures a header that is sent each time the consumer connects, such as an API key.
+// It can be given more than once to send multiple headers, or multiple values of the same header.
+func WithHeader(key, value string) Option {
+	return func(c *Consumer) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithBearerToken configures a function that returns the bearer token sent each time the consumer
+// connects. If the function returns an error, the connection fails with it. Consumers with a bearer
+// token & a backoff also retry connections rejected with a 401 status code, so that a refreshed token
+// can be used.
+func WithBearerToken(fn TokenFunc) Option {
+	return func(c *Consumer) {
+		c.token = fn
+	}
+}
+
+// WithProxy configures the consumer to connect to the stream through the proxy at the given URL. The
+// proxy replaces that of the HTTP client's transport, which must be an *http.Transport or nil. Without
+// it, the proxy is taken from the environment, as described by http.ProxyFromEnvironment.
+func WithProxy(proxy *url.URL) Option {
+	return func(c *Consumer) {
+		c.proxy = proxy
+	}
+}
+
+// proxied returns a copy of the client that connects through the proxy, leaving the original client
+// unchanged.
+func proxied(client *http.Client, proxy *url.URL) *http.Client {
+	transport, ok := client.Transport.(*http.Transport)
+
+	if client.Transport == nil {
+		transport, ok = http.DefaultTransport.(*http.Transport)
+	}
+
+	if !ok {
+		return client
+	}
+
+	transport = transport.Clone()
+	transport.Proxy = http.ProxyURL(proxy)
+
+	copied := *client
+	copied.Transport = transport
+
+	return &copied
+}
+
+// request returns a request that opens the stream at the target URL, with the consumer's headers &
+// bearer token.
+func (c *Consumer) request(ctx context.Context, target string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range c.header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	if id := c.LastEventID(); id != "" {
+		req.Header.Set("Last-Event-ID", id)
+	}
+
+	if c.token != nil {
+		token, err := c.token(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,156 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/consumer"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// roundTripper is an http.RoundTripper that counts the requests it makes.
+	roundTripper struct {
+		requests int32
+	}
+)
+
+func (rt *roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&rt.requests, 1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestConsumer_Headers(t *testing.T) {
+	tt := []struct {
+		Name         string
+		Options      []consumer.Option
+		ExpectedKey  string
+		ExpectedAuth string
+		ExpectError  bool
+	}{
+		{
+			Name:        "It should send configured headers",
+			Options:     []consumer.Option{consumer.WithHeader("X-API-Key", "secret")},
+			ExpectedKey: "secret",
+		},
+		{
+			Name: "It should send the bearer token",
+			Options: []consumer.Option{consumer.WithBearerToken(func(ctx context.Context) (string, error) {
+				return "token", nil
+			})},
+			ExpectedAuth: "Bearer token",
+		},
+		{
+			Name: "It should fail if the token cannot be obtained",
+			Options: []consumer.Option{consumer.WithBearerToken(func(ctx context.Context) (string, error) {
+				return "", errors.New("no token")
+			})},
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var key, auth string
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key = r.Header.Get("X-API-Key")
+				auth = r.Header.Get("Authorization")
+				w.Header().Set("Content-Type", "text/event-stream")
+			}))
+			defer ts.Close()
+
+			err := consumer.New(ts.URL, tc.Options...).Run(context.Background())
+
+			if tc.ExpectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedKey, key)
+			assert.Equal(t, tc.ExpectedAuth, auth)
+		})
+	}
+}
+
+func TestConsumer_RefreshToken(t *testing.T) {
+	var tokens int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			http.Error(w, "token expired", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	states := &stateRecorder{}
+
+	c := consumer.New(ts.URL,
+		consumer.WithBackoff(consumer.Backoff{Initial: time.Millisecond, MaxRetries: 1}),
+		consumer.WithStateHook(states.hook),
+		consumer.WithBearerToken(func(ctx context.Context) (string, error) {
+			return fmt.Sprintf("token-%v", atomic.AddInt32(&tokens, 1)), nil
+		}))
+
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	for !states.Contains(consumer.StateOpen) {
+		select {
+		case <-ctx.Done():
+			t.Fatal("consumer did not connect with a refreshed token")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestConsumer_HTTPClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer ts.Close()
+
+	rt := &roundTripper{}
+	client := &http.Client{Transport: rt}
+
+	assert.NoError(t, consumer.New(ts.URL, consumer.WithHTTPClient(client)).Run(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&rt.requests))
+}
+
+func TestConsumer_Proxy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request was not sent through the proxy")
+	}))
+	defer ts.Close()
+
+	var proxiedURL string
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	assert.NoError(t, err)
+
+	assert.NoError(t, consumer.New(ts.URL+"/events", consumer.WithProxy(proxyURL)).Run(context.Background()))
+	assert.Equal(t, ts.URL+"/events", proxiedURL)
+}
@@ -0,0 +1,476 @@
+// Package delta contains functions for describing the changes between JSON documents as JSON
+// patches (RFC 6902), so that frequently updated documents can be sent by the SSE broker as
+// the changes made to them rather than in full.
+package delta
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// The operation type is a single operation of a JSON patch.
+	operation struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		From  string          `json:"from,omitempty"`
+		Value json.RawMessage `json:"value,omitempty"`
+	}
+)
+
+// PatchType is the type of events whose data is a JSON patch to apply to the previous document
+// broadcast to the same topic.
+const PatchType = "json-patch"
+
+var (
+	// ErrInvalidPath is the error returned when a patch refers to a location that does not exist
+	// in the document.
+	ErrInvalidPath = errors.New("invalid path")
+
+	// ErrTestFailed is the error returned when a patch's 'test' operation does not match the
+	// document.
+	ErrTestFailed = errors.New("test operation failed")
+)
+
+// Diff returns a JSON patch that transforms the 'from' document into the 'to' document. Objects
+// are compared member by member & arrays element by element, with elements added to or removed
+// from the end of an array described individually. An error is returned if either document is
+// not valid JSON.
+func Diff(from, to []byte) ([]byte, error) {
+	a, err := decode(from)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode original document: %w", err)
+	}
+
+	b, err := decode(to)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode new document: %w", err)
+	}
+
+	ops, err := diff(nil, "", a, b)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ops == nil {
+		ops = []operation{}
+	}
+
+	return json.Marshal(ops)
+}
+
+// Apply applies the JSON patch to the document, returning the patched document. The patch is
+// applied atomically, if any operation fails an error is returned & the document is unchanged.
+func Apply(doc, patch []byte) ([]byte, error) {
+	value, err := decode(doc)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode document: %w", err)
+	}
+
+	var ops []operation
+
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("failed to decode patch: %w", err)
+	}
+
+	for _, op := range ops {
+		if value, err = apply(value, op); err != nil {
+			return nil, fmt.Errorf("failed to %v %v: %w", op.Op, op.Path, err)
+		}
+	}
+
+	return json.Marshal(value)
+}
+
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	// Numbers are kept as written, so that large integers are not rounded.
+	dec.UseNumber()
+
+	var out interface{}
+
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+
+	if dec.More() {
+		return nil, errors.New("unexpected data after document")
+	}
+
+	return out, nil
+}
+
+// diff appends the operations that transform 'a' into 'b', found at 'path', to 'ops'.
+func diff(ops []operation, path string, a, b interface{}) ([]operation, error) {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok {
+			return diffObjects(ops, path, a, b)
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok {
+			return diffArrays(ops, path, a, b)
+		}
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return ops, nil
+	}
+
+	return appendOp(ops, "replace", path, b)
+}
+
+func diffObjects(ops []operation, path string, a, b map[string]interface{}) ([]operation, error) {
+	var err error
+
+	// Members are compared in order, so that the same documents always produce the same patch.
+	for _, key := range sortedKeys(a) {
+		if _, ok := b[key]; !ok {
+			ops = append(ops, operation{Op: "remove", Path: path + "/" + escape(key)})
+		}
+	}
+
+	for _, key := range sortedKeys(b) {
+		member, ok := a[key]
+
+		if !ok {
+			ops, err = appendOp(ops, "add", path+"/"+escape(key), b[key])
+		} else {
+			ops, err = diff(ops, path+"/"+escape(key), member, b[key])
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ops, nil
+}
+
+func diffArrays(ops []operation, path string, a, b []interface{}) ([]operation, error) {
+	var err error
+
+	common := len(a)
+
+	if len(b) < common {
+		common = len(b)
+	}
+
+	for i := 0; i < common; i++ {
+		if ops, err = diff(ops, path+"/"+strconv.Itoa(i), a[i], b[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := common; i < len(b); i++ {
+		if ops, err = appendOp(ops, "add", path+"/-", b[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Elements are removed from the end, so that the indexes of those remaining do not change.
+	for i := len(a) - 1; i >= common; i-- {
+		ops = append(ops, operation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+
+	return ops, nil
+}
+
+func appendOp(ops []operation, op, path string, value interface{}) ([]operation, error) {
+	raw, err := json.Marshal(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ops, operation{Op: op, Path: path, Value: raw}), nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// apply applies a single operation to the document, returning the modified document.
+func apply(doc interface{}, op operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, errors.New("missing value")
+		}
+
+		value, err := decode(op.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			if len(path) == 0 {
+				return value, nil
+			}
+
+			if _, err := get(doc, path); err != nil {
+				return nil, err
+			}
+
+			if doc, err = remove(doc, path); err != nil {
+				return nil, err
+			}
+
+			return add(doc, path, value)
+		default:
+			current, err := get(doc, path)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if !reflect.DeepEqual(current, value) {
+				return nil, ErrTestFailed
+			}
+
+			return doc, nil
+		}
+	case "remove":
+		return remove(doc, path)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := get(doc, from)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "copy" {
+			// Copies must not share containers with the original.
+			raw, err := json.Marshal(value)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if value, err = decode(raw); err != nil {
+				return nil, err
+			}
+
+			return add(doc, path, value)
+		}
+
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("%w: cannot move a value into itself", ErrInvalidPath)
+		}
+
+		if doc, err = remove(doc, from); err != nil {
+			return nil, err
+		}
+
+		return add(doc, path, value)
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// parsePointer splits a JSON pointer (RFC 6901) into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("%w: %q does not start with '/'", ErrInvalidPath, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func escape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// index parses an array index, which must be less than 'max'.
+func index(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+
+	if err != nil || i < 0 || i >= max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrInvalidPath, token)
+	}
+
+	return i, nil
+}
+
+// get returns the value at the path.
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q does not exist", ErrInvalidPath, token)
+			}
+
+			doc = value
+		case []interface{}:
+			i, err := index(token, len(node))
+
+			if err != nil {
+				return nil, err
+			}
+
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%w: %q is not a container", ErrInvalidPath, token)
+		}
+	}
+
+	return doc, nil
+}
+
+// update calls 'fn' with the container holding the value at the path & the final token of the
+// path, replacing the container with the one 'fn' returns.
+func update(doc interface{}, path []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[path[0]]
+
+		if !ok {
+			return nil, fmt.Errorf("%w: member %q does not exist", ErrInvalidPath, path[0])
+		}
+
+		updated, err := update(child, path[1:], fn)
+
+		if err != nil {
+			return nil, err
+		}
+
+		node[path[0]] = updated
+
+		return node, nil
+	case []interface{}:
+		i, err := index(path[0], len(node))
+
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := update(node[i], path[1:], fn)
+
+		if err != nil {
+			return nil, err
+		}
+
+		node[i] = updated
+
+		return node, nil
+	default:
+		return nil, fmt.Errorf("%w: %q is not a container", ErrInvalidPath, path[0])
+	}
+}
+
+// add adds the value at the path, replacing the whole document if the path is empty.
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			node[token] = value
+
+			return node, nil
+		case []interface{}:
+			if token == "-" {
+				return append(node, value), nil
+			}
+
+			// Values may be added to the end of an array by index.
+			i, err := index(token, len(node)+1)
+
+			if err != nil {
+				return nil, err
+			}
+
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+
+			return node, nil
+		default:
+			return nil, fmt.Errorf("%w: %q is not a container", ErrInvalidPath, token)
+		}
+	})
+}
+
+// remove removes the value at the path.
+func remove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPath)
+	}
+
+	return update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("%w: member %q does not exist", ErrInvalidPath, token)
+			}
+
+			delete(node, token)
+
+			return node, nil
+		case []interface{}:
+			i, err := index(token, len(node))
+
+			if err != nil {
+				return nil, err
+			}
+
+			return append(node[:i], node[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("%w: %q is not a container", ErrInvalidPath, token)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package delta_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/davidsbond/sse/delta"
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	tt := []struct {
+		Name          string
+		From          string
+		To            string
+		ExpectedPatch string
+		ExpectError   bool
+	}{
+		{
+			Name:          "It should return an empty patch for equal documents",
+			From:          `{"a":1,"b":[1,2]}`,
+			To:            `{"b":[1,2],"a":1}`,
+			ExpectedPatch: `[]`,
+		},
+		{
+			Name:          "It should describe changed members",
+			From:          `{"a":1,"b":{"c":"x"},"d":true}`,
+			To:            `{"a":2,"b":{"c":"y","e/f":null}}`,
+			ExpectedPatch: `[{"op":"remove","path":"/d"},{"op":"replace","path":"/a","value":2},{"op":"replace","path":"/b/c","value":"y"},{"op":"add","path":"/b/e~1f","value":null}]`,
+		},
+		{
+			Name:          "It should describe elements added to an array",
+			From:          `[1,2]`,
+			To:            `[1,3,4,5]`,
+			ExpectedPatch: `[{"op":"replace","path":"/1","value":3},{"op":"add","path":"/-","value":4},{"op":"add","path":"/-","value":5}]`,
+		},
+		{
+			Name:          "It should describe elements removed from an array",
+			From:          `[1,2,3]`,
+			To:            `[1]`,
+			ExpectedPatch: `[{"op":"remove","path":"/2"},{"op":"remove","path":"/1"}]`,
+		},
+		{
+			Name:          "It should replace values of a different type",
+			From:          `{"a":[1]}`,
+			To:            `{"a":{"b":1}}`,
+			ExpectedPatch: `[{"op":"replace","path":"/a","value":{"b":1}}]`,
+		},
+		{
+			Name:        "It should return an error for invalid documents",
+			From:        `{"a":1}`,
+			To:          `{"a":`,
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			patch, err := delta.Diff([]byte(tc.From), []byte(tc.To))
+
+			if tc.ExpectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedPatch, string(patch))
+
+			// Applying the patch should always produce the new document.
+			out, err := delta.Apply([]byte(tc.From), patch)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.To, string(out))
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tt := []struct {
+		Name          string
+		Document      string
+		Patch         string
+		Expected      string
+		ExpectedError error
+	}{
+		{
+			Name:     "It should add values",
+			Document: `{"a":[1,3]}`,
+			Patch:    `[{"op":"add","path":"/a/1","value":2},{"op":"add","path":"/b","value":{"c":1}}]`,
+			Expected: `{"a":[1,2,3],"b":{"c":1}}`,
+		},
+		{
+			Name:     "It should move & copy values",
+			Document: `{"a":{"b":1},"c":[]}`,
+			Patch:    `[{"op":"copy","from":"/a","path":"/c/-"},{"op":"move","from":"/a/b","path":"/d"}]`,
+			Expected: `{"a":{},"c":[{"b":1}],"d":1}`,
+		},
+		{
+			Name:     "It should replace the whole document",
+			Document: `{"a":1}`,
+			Patch:    `[{"op":"replace","path":"","value":[1]}]`,
+			Expected: `[1]`,
+		},
+		{
+			Name:     "It should pass matching tests",
+			Document: `{"a":10000000000000001}`,
+			Patch:    `[{"op":"test","path":"/a","value":10000000000000001},{"op":"remove","path":"/a"}]`,
+			Expected: `{}`,
+		},
+		{
+			Name:          "It should fail tests that do not match",
+			Document:      `{"a":1}`,
+			Patch:         `[{"op":"test","path":"/a","value":2}]`,
+			ExpectedError: delta.ErrTestFailed,
+		},
+		{
+			Name:          "It should fail to replace missing members",
+			Document:      `{"a":1}`,
+			Patch:         `[{"op":"replace","path":"/b","value":2}]`,
+			ExpectedError: delta.ErrInvalidPath,
+		},
+		{
+			Name:          "It should fail to remove elements out of range",
+			Document:      `[1]`,
+			Patch:         `[{"op":"remove","path":"/1"}]`,
+			ExpectedError: delta.ErrInvalidPath,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			out, err := delta.Apply([]byte(tc.Document), []byte(tc.Patch))
+
+			if tc.ExpectedError != nil {
+				assert.True(t, errors.Is(err, tc.ExpectedError))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.Expected, string(out))
+		})
+	}
+}
+
+func TestDocument_Update(t *testing.T) {
+	doc := delta.NewDocument()
+
+	_, err := doc.Update(event.Event{Type: delta.PatchType, Data: []byte(`[]`)})
+	assert.Equal(t, delta.ErrNoSnapshot, err)
+
+	out, err := doc.Update(event.Event{Data: []byte(`{"a":1}`)})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":1}`, string(out))
+
+	out, err = doc.Update(event.Event{Type: delta.PatchType, Data: []byte(`[{"op":"replace","path":"/a","value":2}]`)})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":2}`, string(out))
+
+	// A patch that fails to apply discards the document.
+	_, err = doc.Update(event.Event{Type: delta.PatchType, Data: []byte(`[{"op":"remove","path":"/b"}]`)})
+	assert.Error(t, err)
+	assert.Nil(t, doc.Bytes())
+
+	doc.Update(event.Event{Data: []byte(`{"a":3}`)})
+	doc.Reset()
+	assert.Nil(t, doc.Bytes())
+}
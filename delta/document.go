@@ -0,0 +1,62 @@
+package delta
+
+import (
+	"errors"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Document type rebuilds a JSON document from the events broadcast to a topic that uses
+	// delta encoding, see the broker.WithDeltaEncoding method. Events containing the whole
+	// document replace it & events containing patches are applied to it.
+	Document struct {
+		data []byte
+	}
+)
+
+// ErrNoSnapshot is the error returned when a patch is received before the whole document. The
+// patch should be ignored, as the document that is eventually received will include its changes.
+var ErrNoSnapshot = errors.New("no document to patch")
+
+// NewDocument creates a new instance of the Document type that has not yet received a document.
+func NewDocument() *Document {
+	return &Document{}
+}
+
+// Update applies the event to the document, returning the updated document. If the event is a
+// patch that cannot be applied, an error is returned & the document is discarded until the whole
+// document is next received, so that later patches are not applied to a document that differs
+// from the broker's.
+func (d *Document) Update(e event.Event) ([]byte, error) {
+	if e.Type != PatchType {
+		d.data = append([]byte(nil), e.Data...)
+		return d.data, nil
+	}
+
+	if d.data == nil {
+		return nil, ErrNoSnapshot
+	}
+
+	data, err := Apply(d.data, e.Data)
+
+	if err != nil {
+		d.data = nil
+		return nil, err
+	}
+
+	d.data = data
+
+	return d.data, nil
+}
+
+// Bytes returns the current document, or nil if no document has been received.
+func (d *Document) Bytes() []byte {
+	return d.data
+}
+
+// Reset discards the document. This should be called when reconnecting to the broker, as patches
+// queued before the whole document is received again may not apply to the document held.
+func (d *Document) Reset() {
+	d.data = nil
+}
@@ -0,0 +1,68 @@
+// Package event defines the events that are sent from the SSE broker to its clients.
+package event
+
+import (
+	"time"
+)
+
+type (
+	// The Event type represents a single server sent event.
+	Event struct {
+		ID        string    // The unique identifier of the event, sent to clients in the 'id' field.
+		Type      string    // The type of the event, sent to clients in the 'event' field. If blank, clients treat it as a 'message'.
+		Topic     string    // The topic the event is broadcast to. If blank, the event is broadcast to all clients.
+		Data      []byte    // The event payload, sent to clients in the 'data' field.
+		Timestamp time.Time // When the event was broadcast. If zero, the broker sets it when broadcasting.
+		Expires   time.Time // If non-zero, the event is not replayed to reconnecting clients after this time.
+		Deadline  time.Time // If non-zero, writes to clients whose queues are full give up at this time, rather than after the broker's timeout.
+		Priority  Priority  // Determines the order queued events are delivered in, and which are discarded first when a client's queue is full.
+		Chunk     Chunk     // If the event is one part of a larger payload, describes which part it is.
+		Audience  string    // If set, a selector over client metadata, such as 'role=admin,region=eu', limiting which clients receive the event.
+		Group     string    // If set, only members of the group, such as 'team:42', receive the event.
+		Key       string    // If set, replaces any event with the same key still queued for a client, so that slow clients only receive the latest, such as 'price:AAPL'.
+		Except    []string  // The ids of clients the event is not delivered to, such as the client whose action caused it.
+		Retain    bool      // If true, the event is kept & delivered to clients that later subscribe to its topic, until another retained event replaces it.
+		Stream    string    // If set, the logical stream the event belongs to, such as 'notifications', allowing one connection to carry several streams.
+		Sequence  uint64    // If non-zero, the position of the event within its topic, sent to clients in the 'seq' field so that they can detect missed events.
+	}
+
+	// The Chunk type describes an event that contains one part of a larger payload that has been
+	// split across several events. The zero value describes an event that has not been split.
+	Chunk struct {
+		ID    string // Identifies the payload the chunk belongs to, shared by each of its chunks.
+		Index int    // The position of the chunk within the payload, starting from zero.
+		Count int    // The number of chunks the payload was split into.
+	}
+)
+
+// Matches determines if the event should be delivered to a client subscribed to the
+// given topics. Events without a topic are delivered to every client.
+func (e Event) Matches(topics []string) bool {
+	if e.Topic == "" {
+		return true
+	}
+
+	for _, topic := range topics {
+		if topic == e.Topic {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Excludes determines if the client with the given id is one the event should not be delivered to.
+func (e Event) Excludes(id string) bool {
+	for _, except := range e.Except {
+		if except == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Expired determines if the event has passed its expiry time.
+func (e Event) Expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !now.Before(e.Expires)
+}
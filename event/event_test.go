@@ -0,0 +1,67 @@
+package event_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvent_Matches(t *testing.T) {
+	tt := []struct {
+		Topic    string
+		Topics   []string
+		Expected bool
+	}{
+		{Topic: "", Topics: nil, Expected: true},
+		{Topic: "", Topics: []string{"a"}, Expected: true},
+		{Topic: "a", Topics: []string{"a", "b"}, Expected: true},
+		{Topic: "c", Topics: []string{"a", "b"}, Expected: false},
+		{Topic: "a", Topics: nil, Expected: false},
+	}
+
+	for _, tc := range tt {
+		e := event.Event{Topic: tc.Topic}
+
+		assert.Equal(t, tc.Expected, e.Matches(tc.Topics))
+	}
+}
+
+func TestEvent_Excludes(t *testing.T) {
+	tt := []struct {
+		Except   []string
+		ID       string
+		Expected bool
+	}{
+		{Except: nil, ID: "a", Expected: false},
+		{Except: []string{"a", "b"}, ID: "b", Expected: true},
+		{Except: []string{"a", "b"}, ID: "c", Expected: false},
+	}
+
+	for _, tc := range tt {
+		e := event.Event{Except: tc.Except}
+
+		assert.Equal(t, tc.Expected, e.Excludes(tc.ID))
+	}
+}
+
+func TestEvent_Expired(t *testing.T) {
+	now := time.Now()
+
+	tt := []struct {
+		Expires  time.Time
+		Expected bool
+	}{
+		{Expected: false},
+		{Expires: now.Add(time.Minute), Expected: false},
+		{Expires: now, Expected: true},
+		{Expires: now.Add(-time.Minute), Expected: true},
+	}
+
+	for _, tc := range tt {
+		e := event.Event{Expires: tc.Expires}
+
+		assert.Equal(t, tc.Expected, e.Expired(now))
+	}
+}
@@ -0,0 +1,121 @@
+package event
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+type (
+	// The jsonEvent type is the JSON representation of an event. Data that is valid UTF-8 is
+	// represented as a string, otherwise it is base64 encoded & the encoding field is set, in
+	// the same way as the base64 payload encoding used in event streams.
+	jsonEvent struct {
+		ID        string     `json:"id,omitempty"`
+		Type      string     `json:"type,omitempty"`
+		Topic     string     `json:"topic,omitempty"`
+		Data      string     `json:"data"`
+		Encoding  string     `json:"encoding,omitempty"`
+		Timestamp *time.Time `json:"timestamp,omitempty"`
+		Expires   *time.Time `json:"expires,omitempty"`
+		Deadline  *time.Time `json:"deadline,omitempty"`
+		Priority  string     `json:"priority,omitempty"`
+		Audience  string     `json:"audience,omitempty"`
+		Group     string     `json:"group,omitempty"`
+		Key       string     `json:"key,omitempty"`
+		Except    []string   `json:"except,omitempty"`
+		Retain    bool       `json:"retain,omitempty"`
+		Stream    string     `json:"stream,omitempty"`
+		Sequence  uint64     `json:"sequence,omitempty"`
+	}
+)
+
+// MarshalJSON encodes the event as a JSON object. The data is written as a string if it is
+// valid UTF-8, otherwise it is base64 encoded and the 'encoding' field is set to 'base64'.
+func (e Event) MarshalJSON() ([]byte, error) {
+	out := jsonEvent{
+		ID:       e.ID,
+		Type:     e.Type,
+		Topic:    e.Topic,
+		Data:     string(e.Data),
+		Audience: e.Audience,
+		Group:    e.Group,
+		Key:      e.Key,
+		Except:   e.Except,
+		Retain:   e.Retain,
+		Stream:   e.Stream,
+		Sequence: e.Sequence,
+	}
+
+	if !utf8.Valid(e.Data) {
+		out.Data = base64.StdEncoding.EncodeToString(e.Data)
+		out.Encoding = "base64"
+	}
+
+	if !e.Timestamp.IsZero() {
+		out.Timestamp = &e.Timestamp
+	}
+
+	if !e.Expires.IsZero() {
+		out.Expires = &e.Expires
+	}
+
+	if !e.Deadline.IsZero() {
+		out.Deadline = &e.Deadline
+	}
+
+	if e.Priority != PriorityNormal {
+		out.Priority = e.Priority.String()
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes an event from a JSON object written by the MarshalJSON method.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var in jsonEvent
+
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*e = Event{ID: in.ID, Type: in.Type, Topic: in.Topic, Data: []byte(in.Data), Audience: in.Audience, Group: in.Group, Key: in.Key, Except: in.Except, Retain: in.Retain, Stream: in.Stream, Sequence: in.Sequence}
+
+	switch in.Encoding {
+	case "":
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(in.Data)
+
+		if err != nil {
+			return err
+		}
+
+		e.Data = decoded
+	default:
+		return fmt.Errorf("unsupported data encoding %v", in.Encoding)
+	}
+
+	if in.Timestamp != nil {
+		e.Timestamp = *in.Timestamp
+	}
+
+	if in.Expires != nil {
+		e.Expires = *in.Expires
+	}
+
+	if in.Deadline != nil {
+		e.Deadline = *in.Deadline
+	}
+
+	priority, err := ParsePriority(in.Priority)
+
+	if err != nil {
+		return err
+	}
+
+	e.Priority = priority
+
+	return nil
+}
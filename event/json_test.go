@@ -0,0 +1,88 @@
+package event_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvent_MarshalJSON(t *testing.T) {
+	timestamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tt := []struct {
+		Event        event.Event
+		ExpectedJSON string
+	}{
+		{
+			Event:        event.Event{ID: "1", Type: "greeting", Data: []byte("hello"), Timestamp: timestamp},
+			ExpectedJSON: `{"id":"1","type":"greeting","data":"hello","timestamp":"2020-01-02T03:04:05Z"}`,
+		},
+		{
+			Event:        event.Event{Topic: "binary", Data: []byte{'a', 0xff, 'b'}},
+			ExpectedJSON: `{"topic":"binary","data":"Yf9i","encoding":"base64"}`,
+		},
+		{
+			Event:        event.Event{Data: []byte{}, Expires: timestamp},
+			ExpectedJSON: `{"data":"","expires":"2020-01-02T03:04:05Z"}`,
+		},
+		{
+			Event:        event.Event{Data: []byte{}, Deadline: timestamp},
+			ExpectedJSON: `{"data":"","deadline":"2020-01-02T03:04:05Z"}`,
+		},
+		{
+			Event:        event.Event{Data: []byte("urgent"), Priority: event.PriorityHigh},
+			ExpectedJSON: `{"data":"urgent","priority":"high"}`,
+		},
+		{
+			Event:        event.Event{Data: []byte("admins"), Audience: "role=admin"},
+			ExpectedJSON: `{"data":"admins","audience":"role=admin"}`,
+		},
+		{
+			Event:        event.Event{Data: []byte("team"), Group: "team:1"},
+			ExpectedJSON: `{"data":"team","group":"team:1"}`,
+		},
+		{
+			Event:        event.Event{Data: []byte("100"), Key: "price:AAPL"},
+			ExpectedJSON: `{"data":"100","key":"price:AAPL"}`,
+		},
+		{
+			Event:        event.Event{Data: []byte("hi"), Except: []string{"a", "b"}},
+			ExpectedJSON: `{"data":"hi","except":["a","b"]}`,
+		},
+		{
+			Event:        event.Event{Topic: "status", Data: []byte("ready"), Retain: true},
+			ExpectedJSON: `{"topic":"status","data":"ready","retain":true}`,
+		},
+		{
+			Event:        event.Event{Data: []byte("hi"), Stream: "notifications"},
+			ExpectedJSON: `{"data":"hi","stream":"notifications"}`,
+		},
+		{
+			Event:        event.Event{Topic: "prices", Data: []byte("100"), Sequence: 42},
+			ExpectedJSON: `{"topic":"prices","data":"100","sequence":42}`,
+		},
+	}
+
+	for _, tc := range tt {
+		data, err := json.Marshal(tc.Event)
+
+		assert.NoError(t, err)
+		assert.Equal(t, tc.ExpectedJSON, string(data))
+
+		var decoded event.Event
+
+		assert.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, tc.Event, decoded)
+	}
+}
+
+func TestEvent_UnmarshalJSON(t *testing.T) {
+	var e event.Event
+
+	assert.Error(t, json.Unmarshal([]byte(`{"data":"a","encoding":"rot13"}`), &e))
+	assert.Error(t, json.Unmarshal([]byte(`{"data":"!","encoding":"base64"}`), &e))
+	assert.Error(t, json.Unmarshal([]byte(`{"data":"a","priority":"urgent"}`), &e))
+}
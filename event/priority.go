@@ -0,0 +1,51 @@
+package event
+
+import (
+	"fmt"
+)
+
+type (
+	// Priority determines the order in which events queued for a client are delivered, and
+	// which events are discarded first when a client's queue is full. The zero value is
+	// PriorityNormal.
+	Priority int
+)
+
+const (
+	// PriorityLow events are delivered after all other queued events, and are discarded to
+	// make room for higher priority events when a client's queue is full.
+	PriorityLow Priority = iota - 1
+
+	// PriorityNormal events are delivered in the order they are written.
+	PriorityNormal
+
+	// PriorityHigh events are delivered before any queued events of a lower priority.
+	PriorityHigh
+)
+
+// ParsePriority converts the given text into a Priority. The text must be one of 'low', 'normal'
+// or 'high', or blank for normal priority.
+func ParsePriority(text string) (Priority, error) {
+	switch text {
+	case "low":
+		return PriorityLow, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	default:
+		return PriorityNormal, fmt.Errorf("unknown priority %v", text)
+	}
+}
+
+// String returns the name of the priority, as accepted by the ParsePriority function.
+func (p Priority) String() string {
+	switch {
+	case p < PriorityNormal:
+		return "low"
+	case p > PriorityNormal:
+		return "high"
+	default:
+		return "normal"
+	}
+}
@@ -0,0 +1,38 @@
+package event_test
+
+import (
+	"testing"
+
+	"github.com/davidsbond/sse/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePriority(t *testing.T) {
+	tt := []struct {
+		Text             string
+		ExpectedPriority event.Priority
+		ExpectError      bool
+	}{
+		{Text: "low", ExpectedPriority: event.PriorityLow},
+		{Text: "", ExpectedPriority: event.PriorityNormal},
+		{Text: "normal", ExpectedPriority: event.PriorityNormal},
+		{Text: "high", ExpectedPriority: event.PriorityHigh},
+		{Text: "urgent", ExpectError: true},
+	}
+
+	for _, tc := range tt {
+		priority, err := event.ParsePriority(tc.Text)
+
+		if tc.ExpectError {
+			assert.Error(t, err)
+			continue
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, tc.ExpectedPriority, priority)
+
+		if tc.Text != "" {
+			assert.Equal(t, tc.Text, priority.String())
+		}
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/davidsbond/sse"
 )
 
-func ExampleSSE_NewBroker() {
+func ExampleNewBroker() {
 	// Create a configuration for the broker
 	cnf := sse.Config{
 		Timeout:   time.Second * 5,
@@ -0,0 +1,460 @@
+// Package loadtest measures the performance of a broker by connecting simulated consumers to its
+// ClientHandler & publishing events to its EventHandler from simulated publishers. It reports how
+// long events take to fan out to every consumer & how many are never received, so that performance
+// regressions can be measured.
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/protocol"
+)
+
+type (
+	// The Config type describes the load to place on a broker.
+	Config struct {
+		ConnectURL  string        // The URL of the broker's ClientHandler.
+		PublishURL  string        // The URL of the broker's EventHandler.
+		Topic       string        // If set, consumers subscribe to & publishers broadcast to this topic.
+		Consumers   int           // The number of consumers to connect. Defaults to 1.
+		Publishers  int           // The number of publishers to publish from. Defaults to 1.
+		Rate        int           // The number of events each publisher publishes per second. If zero, events are published as fast as possible.
+		Duration    time.Duration // How long to publish for. Defaults to 10 seconds.
+		PayloadSize int           // The size of each event's data, in bytes. Events are never smaller than the data used to measure them.
+		Drain       time.Duration // How long to wait for events to be received once publishing stops. Defaults to 1 second.
+		Client      *http.Client  // The client used to connect & publish. Defaults to http.DefaultClient.
+	}
+
+	// The Report type contains the outcome of a load test.
+	Report struct {
+		Consumers int           // The number of consumers that were connected.
+		Published int           // The number of events accepted by the broker.
+		Failed    int           // The number of events the broker did not accept.
+		Expected  int           // The number of events that should have been received, across every consumer.
+		Received  int           // The number of events received, across every consumer.
+		Dropped   int           // The number of expected events that were not received.
+		Latency   Latency       // How long events took to be received after being published.
+		Duration  time.Duration // How long events were published for.
+	}
+
+	// The Latency type contains percentiles of the time between an event being published & being
+	// received by a consumer.
+	Latency struct {
+		P50 time.Duration
+		P90 time.Duration
+		P99 time.Duration
+		Max time.Duration
+	}
+
+	// The consumer type is a simulated client of the broker, recording how long each event took to
+	// reach it.
+	consumer struct {
+		mux       sync.Mutex
+		latencies []time.Duration
+		ready     chan struct{}
+		readyOnce sync.Once
+	}
+)
+
+const (
+	// Events published before every consumer is connected are marked, so that they are not measured.
+	warmupMarker   = 'w'
+	measuredMarker = 'm'
+
+	// How often events are published while waiting for consumers to connect.
+	warmupInterval = time.Millisecond * 50
+)
+
+// ErrNotConnected is the error returned when a consumer does not connect to the broker before the
+// load test's context is cancelled.
+var ErrNotConnected = errors.New("consumers did not connect to the broker")
+
+// Run connects the configured number of consumers to the broker & publishes events to it for the
+// configured duration, returning a report of how they were received. Events are only measured once
+// every consumer has received an event, so that slow connections are not counted as drops. Each
+// event's data contains the time it was published, so the clocks of the publishers & consumers must
+// agree.
+func Run(ctx context.Context, cfg Config) (Report, error) {
+	cfg = withDefaults(cfg)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	consumers := make([]*consumer, cfg.Consumers)
+	errs := make(chan error, cfg.Consumers)
+
+	var wg sync.WaitGroup
+
+	for i := range consumers {
+		consumers[i] = &consumer{ready: make(chan struct{})}
+		wg.Add(1)
+
+		go func(c *consumer) {
+			defer wg.Done()
+
+			if err := c.consume(ctx, cfg); err != nil && ctx.Err() == nil {
+				errs <- err
+			}
+		}(consumers[i])
+	}
+
+	if err := warmup(ctx, cfg, consumers, errs); err != nil {
+		cancel()
+		wg.Wait()
+
+		return Report{}, err
+	}
+
+	report := Report{Consumers: cfg.Consumers}
+	started := time.Now()
+
+	published, failed := publishAll(ctx, cfg)
+
+	report.Duration = time.Since(started)
+	report.Published = published
+	report.Failed = failed
+	report.Expected = published * cfg.Consumers
+
+	// Give the broker time to deliver the events that were published last.
+	drain(ctx, cfg.Drain, consumers, report.Expected)
+	cancel()
+	wg.Wait()
+
+	var latencies []time.Duration
+
+	for _, c := range consumers {
+		latencies = append(latencies, c.received()...)
+	}
+
+	report.Received = len(latencies)
+
+	if report.Dropped = report.Expected - report.Received; report.Dropped < 0 {
+		report.Dropped = 0
+	}
+
+	report.Latency = percentiles(latencies)
+
+	return report, nil
+}
+
+// String returns a human readable summary of the report.
+func (r Report) String() string {
+	return fmt.Sprintf(
+		"consumers=%v published=%v failed=%v expected=%v received=%v dropped=%v duration=%v p50=%v p90=%v p99=%v max=%v",
+		r.Consumers, r.Published, r.Failed, r.Expected, r.Received, r.Dropped, r.Duration,
+		r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max,
+	)
+}
+
+func withDefaults(cfg Config) Config {
+	if cfg.Consumers <= 0 {
+		cfg.Consumers = 1
+	}
+
+	if cfg.Publishers <= 0 {
+		cfg.Publishers = 1
+	}
+
+	if cfg.Duration <= 0 {
+		cfg.Duration = time.Second * 10
+	}
+
+	if cfg.Drain <= 0 {
+		cfg.Drain = time.Second
+	}
+
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	return cfg
+}
+
+// warmup publishes unmeasured events until every consumer has received one.
+func warmup(ctx context.Context, cfg Config, consumers []*consumer, errs <-chan error) error {
+	ticker := time.NewTicker(warmupInterval)
+	defer ticker.Stop()
+
+	for _, c := range consumers {
+		for ready := false; !ready; {
+			select {
+			case <-c.ready:
+				ready = true
+			case err := <-errs:
+				return err
+			case <-ctx.Done():
+				return ErrNotConnected
+			case <-ticker.C:
+				publishEvent(ctx, cfg, warmupMarker, 0, 0)
+			}
+		}
+	}
+
+	return nil
+}
+
+// publishAll publishes events from each publisher for the configured duration, returning the number
+// of events that were & were not accepted by the broker.
+func publishAll(ctx context.Context, cfg Config) (int, int) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	var (
+		wg                sync.WaitGroup
+		mux               sync.Mutex
+		published, failed int
+	)
+
+	for i := 0; i < cfg.Publishers; i++ {
+		wg.Add(1)
+
+		go func(publisher int) {
+			defer wg.Done()
+
+			ok, notOK := publishFrom(ctx, cfg, publisher)
+
+			mux.Lock()
+			published += ok
+			failed += notOK
+			mux.Unlock()
+		}(i)
+	}
+
+	wg.Wait()
+
+	return published, failed
+}
+
+// publishFrom publishes events at the configured rate until the context is cancelled.
+func publishFrom(ctx context.Context, cfg Config, publisher int) (int, int) {
+	var (
+		tick              <-chan time.Time
+		published, failed int
+	)
+
+	if cfg.Rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(cfg.Rate))
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for seq := 0; ; seq++ {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return published, failed
+			}
+		} else if ctx.Err() != nil {
+			return published, failed
+		}
+
+		switch err := publishEvent(ctx, cfg, measuredMarker, publisher, seq); {
+		case err == nil:
+			published++
+		case ctx.Err() == nil:
+			failed++
+		}
+	}
+}
+
+// publishEvent posts an event to the broker. Its data contains a marker describing whether it is
+// measured, the publisher & sequence number that identify it & the time it was published, padded
+// to the configured payload size.
+func publishEvent(ctx context.Context, cfg Config, marker byte, publisher, seq int) error {
+	data := []byte{marker, ' '}
+	data = strconv.AppendInt(data, int64(publisher), 10)
+	data = append(data, ' ')
+	data = strconv.AppendInt(data, int64(seq), 10)
+	data = append(data, ' ')
+	data = strconv.AppendInt(data, time.Now().UnixNano(), 10)
+
+	if pad := cfg.PayloadSize - len(data) - 1; pad > 0 {
+		data = append(data, ' ')
+		data = append(data, bytes.Repeat([]byte{'x'}, pad)...)
+	}
+
+	u, err := url.Parse(cfg.PublishURL)
+
+	if err != nil {
+		return err
+	}
+
+	if cfg.Topic != "" {
+		query := u.Query()
+		query.Set("topic", cfg.Topic)
+		u.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(data))
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := cfg.Client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.New(resp.Status)
+	}
+
+	return nil
+}
+
+// drain waits until the expected number of events have been received, or the timeout elapses.
+func drain(ctx context.Context, timeout time.Duration, consumers []*consumer, expected int) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(time.Millisecond * 10)
+	defer ticker.Stop()
+
+	for {
+		var received int
+
+		for _, c := range consumers {
+			received += c.count()
+		}
+
+		if received >= expected {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// consume connects to the broker & records the latency of each measured event until the context is
+// cancelled.
+func (c *consumer) consume(ctx context.Context, cfg Config) error {
+	u, err := url.Parse(cfg.ConnectURL)
+
+	if err != nil {
+		return err
+	}
+
+	if cfg.Topic != "" {
+		query := u.Query()
+		query.Set("topic", cfg.Topic)
+		u.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := cfg.Client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("failed to connect: %v", resp.Status)
+	}
+
+	dec := protocol.NewDecoder(resp.Body)
+
+	for {
+		e, err := dec.Decode()
+
+		if err != nil {
+			return err
+		}
+
+		c.readyOnce.Do(func() { close(c.ready) })
+
+		if published, ok := parsePublished(e.Data); ok {
+			c.record(time.Since(published))
+		}
+	}
+}
+
+func (c *consumer) record(latency time.Duration) {
+	c.mux.Lock()
+	c.latencies = append(c.latencies, latency)
+	c.mux.Unlock()
+}
+
+func (c *consumer) count() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return len(c.latencies)
+}
+
+func (c *consumer) received() []time.Duration {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.latencies
+}
+
+// parsePublished returns the time a measured event was published, from its data. Events that are
+// not measured return false.
+func parsePublished(data []byte) (time.Time, bool) {
+	fields := bytes.Fields(data)
+
+	if len(fields) < 4 || len(fields[0]) != 1 || fields[0][0] != measuredMarker {
+		return time.Time{}, false
+	}
+
+	nanos, err := strconv.ParseInt(string(fields[3]), 10, 64)
+
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, nanos), true
+}
+
+// percentiles returns the percentiles of the latencies.
+func percentiles(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	at := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+
+	return Latency{
+		P50: at(0.5),
+		P90: at(0.9),
+		P99: at(0.99),
+		Max: latencies[len(latencies)-1],
+	}
+}
@@ -0,0 +1,70 @@
+package loadtest_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/loadtest"
+	"github.com/davidsbond/sse/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Config loadtest.Config
+	}{
+		{
+			Name:   "It should deliver every event to every consumer",
+			Config: loadtest.Config{Consumers: 3, Publishers: 2, Rate: 50, Duration: time.Millisecond * 200, PayloadSize: 256},
+		},
+		{
+			Name:   "It should deliver events published to a topic",
+			Config: loadtest.Config{Consumers: 2, Rate: 50, Duration: time.Millisecond * 200, Topic: "news"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			cnf := server.DefaultConfig()
+			cnf.Timeout = time.Millisecond * 100
+
+			srv := server.New(cnf)
+			defer srv.Broker().Close()
+
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+
+			tc.Config.ConnectURL = ts.URL + cnf.Paths.Connect
+			tc.Config.PublishURL = ts.URL + cnf.Paths.Broadcast
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			report, err := loadtest.Run(ctx, tc.Config)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.Config.Consumers, report.Consumers)
+			assert.True(t, report.Published > 0)
+			assert.Equal(t, 0, report.Failed)
+			assert.Equal(t, report.Published*tc.Config.Consumers, report.Expected)
+			assert.Equal(t, 0, report.Dropped)
+			assert.True(t, report.Latency.P50 <= report.Latency.P99)
+			assert.True(t, report.Latency.P99 <= report.Latency.Max)
+		})
+	}
+}
+
+func TestRun_NotConnected(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	_, err := loadtest.Run(ctx, loadtest.Config{
+		ConnectURL: "http://127.0.0.1:1/connect",
+		PublishURL: "http://127.0.0.1:1/broadcast",
+	})
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Reassembler type joins events that were split into chunks back into the original
+	// event. Chunks of different payloads may be interleaved with each other & with events
+	// that were not split.
+	Reassembler struct {
+		pending map[string][][]byte
+	}
+)
+
+// Split divides the event's data into chunks of at most 'size' bytes, returning an event for
+// each chunk. Each chunk has the same type, topic, priority & expiry as the event and is
+// identified by the given correlation identifier. Only the final chunk has the event's
+// identifier, so that a client that reconnects part way through the payload resumes from
+// the start of it. Valid UTF-8 is not split within a character or line ending. If the data
+// fits within a single chunk, the event is returned as-is.
+func Split(e event.Event, size int, id string) []event.Event {
+	if size <= 0 || len(e.Data) <= size {
+		return []event.Event{e}
+	}
+
+	var parts [][]byte
+
+	text := utf8.Valid(e.Data)
+
+	for data := e.Data; len(data) > 0; {
+		n := size
+
+		if n >= len(data) {
+			n = len(data)
+		} else if text {
+			n = boundary(data, n)
+		}
+
+		parts = append(parts, data[:n])
+		data = data[n:]
+	}
+
+	out := make([]event.Event, len(parts))
+
+	for i, part := range parts {
+		out[i] = e
+		out[i].ID = ""
+		out[i].Data = part
+		out[i].Chunk = event.Chunk{ID: id, Index: i, Count: len(parts)}
+	}
+
+	out[len(out)-1].ID = e.ID
+
+	return out
+}
+
+// boundary returns the largest position no greater than 'n' at which text can be split without
+// dividing a character or a CRLF line ending. If there is no such position, 'n' is returned.
+func boundary(data []byte, n int) int {
+	for i := n; i > 0; i-- {
+		if !utf8.RuneStart(data[i]) {
+			continue
+		}
+
+		if data[i-1] == '\r' && data[i] == '\n' {
+			continue
+		}
+
+		return i
+	}
+
+	return n
+}
+
+// NewReassembler creates a new instance of the Reassembler type with no pending chunks.
+func NewReassembler() *Reassembler {
+	return &Reassembler{pending: make(map[string][][]byte)}
+}
+
+// Add adds the event to the reassembler. If the event was not split, it is returned as-is.
+// If the event is the final chunk of a payload, the original event is returned. Otherwise,
+// false is returned until the rest of the payload is received. If the first chunk of a payload
+// is received again, such as after reconnecting, any chunks already received for it are
+// discarded.
+func (r *Reassembler) Add(e event.Event) (event.Event, bool) {
+	chunk := e.Chunk
+
+	if chunk.Count <= 0 {
+		return e, true
+	}
+
+	parts, ok := r.pending[chunk.ID]
+
+	if !ok || chunk.Index == 0 || len(parts) != chunk.Count {
+		parts = make([][]byte, chunk.Count)
+	}
+
+	if chunk.Index < 0 || chunk.Index >= chunk.Count {
+		return event.Event{}, false
+	}
+
+	parts[chunk.Index] = e.Data
+	r.pending[chunk.ID] = parts
+
+	for _, part := range parts {
+		if part == nil {
+			return event.Event{}, false
+		}
+	}
+
+	delete(r.pending, chunk.ID)
+
+	e.Data = bytes.Join(parts, nil)
+	e.Chunk = event.Chunk{}
+
+	return e, true
+}
+
+// Pending returns the number of payloads that have been partially received.
+func (r *Reassembler) Pending() int {
+	return len(r.pending)
+}
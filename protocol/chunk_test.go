@@ -0,0 +1,102 @@
+package protocol_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	tt := []struct {
+		Event          event.Event
+		Size           int
+		ExpectedChunks []string
+	}{
+		{Event: event.Event{Data: []byte("hello")}, Size: 10, ExpectedChunks: []string{"hello"}},
+		{Event: event.Event{Data: []byte("hello world")}, Size: 4, ExpectedChunks: []string{"hell", "o wo", "rld"}},
+		{Event: event.Event{Data: []byte("héllo")}, Size: 2, ExpectedChunks: []string{"h", "é", "ll", "o"}},
+		{Event: event.Event{Data: []byte("ab\r\ncd")}, Size: 3, ExpectedChunks: []string{"ab", "\r\nc", "d"}},
+		{Event: event.Event{Data: []byte{0xff, 0xfe, 0xfd}}, Size: 2, ExpectedChunks: []string{"\xff\xfe", "\xfd"}},
+	}
+
+	for _, tc := range tt {
+		tc.Event.ID = "1"
+		chunks := protocol.Split(tc.Event, tc.Size, "payload")
+		data := make([]string, len(chunks))
+
+		for i, chunk := range chunks {
+			data[i] = string(chunk.Data)
+
+			if len(chunks) > 1 {
+				assert.Equal(t, event.Chunk{ID: "payload", Index: i, Count: len(chunks)}, chunk.Chunk)
+			}
+		}
+
+		assert.Equal(t, tc.ExpectedChunks, data)
+
+		// Only the final chunk has the event's identifier.
+		assert.Equal(t, "1", chunks[len(chunks)-1].ID)
+	}
+}
+
+func TestReassembler_Add(t *testing.T) {
+	tt := []struct {
+		Data     string
+		Size     int
+		Encoding protocol.Encoding
+	}{
+		{Data: "hello world", Size: 3},
+		{Data: "line one\nline two\nline three", Size: 4},
+		{Data: "\xff\x00binary\xfe", Size: 3, Encoding: protocol.EncodingBase64},
+	}
+
+	for _, tc := range tt {
+		original := event.Event{ID: "2", Type: "document", Data: []byte(tc.Data)}
+
+		// Interleave the chunks with an event that was not split.
+		buf := &bytes.Buffer{}
+		enc := protocol.NewEncoder(buf)
+		enc.SetEncoding(tc.Encoding)
+
+		for i, chunk := range protocol.Split(original, tc.Size, "payload") {
+			assert.NoError(t, enc.Encode(chunk))
+
+			if i == 0 {
+				assert.NoError(t, enc.Encode(event.Event{ID: "1", Data: []byte("interleaved")}))
+			}
+		}
+
+		dec := protocol.NewDecoder(buf)
+		r := protocol.NewReassembler()
+
+		var out []event.Event
+
+		for {
+			e, err := dec.Decode()
+
+			if err == io.EOF {
+				break
+			}
+
+			assert.NoError(t, err)
+
+			if e, ok := r.Add(e); ok {
+				out = append(out, e)
+			}
+		}
+
+		if !assert.Len(t, out, 2) {
+			continue
+		}
+
+		assert.Equal(t, "interleaved", string(out[0].Data))
+		assert.Equal(t, string(original.Data), string(out[1].Data))
+		assert.Equal(t, original.ID, out[1].ID)
+		assert.Equal(t, original.Type, out[1].Type)
+		assert.Equal(t, 0, r.Pending())
+	}
+}
@@ -0,0 +1,219 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Decoder type reads events from a stream using the SSE wire format, following the
+	// parsing rules of the WHATWG specification. A leading byte order mark is ignored, lines
+	// may end with CRLF, LF or CR, comments & unknown fields are ignored, and events without
+	// data are not dispatched. As in a browser, the last event identifier received applies to
+	// every following event until another is received.
+	Decoder struct {
+		r           *bufio.Reader
+		started     bool
+		skipLF      bool
+		lastEventID string
+		retry       time.Duration
+	}
+)
+
+var (
+	bom = []byte("\xef\xbb\xbf")
+)
+
+// NewDecoder creates a new instance of the Decoder type that reads from 'r'.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// LastEventID returns the last event identifier received from the stream.
+func (dec *Decoder) LastEventID() string {
+	return dec.lastEventID
+}
+
+// Retry returns the last reconnection time received from the stream, or zero if none has
+// been received.
+func (dec *Decoder) Retry() time.Duration {
+	return dec.retry
+}
+
+// Decode reads the next event from the stream. Once the end of the stream is reached, any
+// incomplete event is discarded and io.EOF is returned. Payloads written using the base64
+// encoding are decoded, returning an error if they are malformed. Events that are chunks of
+// a larger payload are returned individually, use a Reassembler to join them.
+func (dec *Decoder) Decode() (event.Event, error) {
+	var (
+		data     []string
+		hasData  bool
+		typ      string
+		encoding string
+		chunk    event.Chunk
+		sequence uint64
+		topic    string
+	)
+
+	for {
+		line, err := dec.readLine()
+
+		if err != nil {
+			return event.Event{}, err
+		}
+
+		// A blank line dispatches the event, if it has any data.
+		if line == "" {
+			if !hasData {
+				data, typ, encoding, chunk, sequence, topic = nil, "", "", event.Chunk{}, 0, ""
+				continue
+			}
+
+			e := event.Event{ID: dec.lastEventID, Type: typ, Data: []byte(strings.Join(data, "\n")), Chunk: chunk, Sequence: sequence, Topic: topic}
+
+			if encoding == "base64" {
+				decoded, err := base64.StdEncoding.DecodeString(string(e.Data))
+
+				if err != nil {
+					return e, fmt.Errorf("failed to decode base64 payload: %v", err)
+				}
+
+				e.Data = decoded
+			}
+
+			return e, nil
+		}
+
+		// Lines beginning with a colon are comments.
+		if line[0] == ':' {
+			continue
+		}
+
+		name, value := line, ""
+
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			name, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch name {
+		case "event":
+			typ = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "id":
+			// Identifiers containing null are ignored.
+			if strings.IndexByte(value, 0) < 0 {
+				dec.lastEventID = value
+			}
+		case "retry":
+			// Retry values that are not entirely digits are ignored.
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				dec.retry = time.Duration(ms) * time.Millisecond
+			}
+		case "encoding":
+			encoding = value
+		case "chunk":
+			// Chunk fields that are malformed are ignored.
+			chunk = parseChunk(value)
+		case "seq":
+			// Sequence fields that are malformed are ignored.
+			sequence, topic = parseSequence(value)
+		}
+	}
+}
+
+// parseChunk parses the value of a 'chunk' field, which contains the index of the chunk, the
+// number of chunks & the identifier of the payload, separated by spaces. If the value is
+// malformed, the zero Chunk is returned.
+func parseChunk(value string) event.Chunk {
+	parts := strings.SplitN(value, " ", 3)
+
+	if len(parts) != 3 {
+		return event.Chunk{}
+	}
+
+	index, err := strconv.Atoi(parts[0])
+
+	if err != nil {
+		return event.Chunk{}
+	}
+
+	count, err := strconv.Atoi(parts[1])
+
+	if err != nil || count <= 0 || index < 0 || index >= count {
+		return event.Chunk{}
+	}
+
+	return event.Chunk{ID: parts[2], Index: index, Count: count}
+}
+
+// parseSequence parses the value of a 'seq' field, which contains the position of the event
+// within its topic, optionally followed by a space & the topic. If the value is malformed, zero
+// is returned.
+func parseSequence(value string) (uint64, string) {
+	parts := strings.SplitN(value, " ", 2)
+
+	sequence, err := strconv.ParseUint(parts[0], 10, 64)
+
+	if err != nil {
+		return 0, ""
+	}
+
+	if len(parts) == 1 {
+		return sequence, ""
+	}
+
+	return sequence, parts[1]
+}
+
+// readLine reads the next line from the stream, without its line ending. Invalid UTF-8
+// sequences are replaced with the unicode replacement character.
+func (dec *Decoder) readLine() (string, error) {
+	var line []byte
+
+	for {
+		b, err := dec.r.ReadByte()
+
+		if err != nil {
+			// Any incomplete line is discarded at the end of the stream.
+			return "", err
+		}
+
+		// A line feed directly after a carriage return belongs to the previous line.
+		if dec.skipLF {
+			dec.skipLF = false
+
+			if b == '\n' {
+				continue
+			}
+		}
+
+		if b == '\r' || b == '\n' {
+			dec.skipLF = b == '\r'
+			break
+		}
+
+		line = append(line, b)
+	}
+
+	if !dec.started {
+		dec.started = true
+		line = bytes.TrimPrefix(line, bom)
+	}
+
+	if !utf8.Valid(line) {
+		line = bytes.ToValidUTF8(line, []byte(string(utf8.RuneError)))
+	}
+
+	return string(line), nil
+}
@@ -0,0 +1,113 @@
+package protocol_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoder_Decode(t *testing.T) {
+	tt := []struct {
+		Stream         string
+		ExpectedEvents []event.Event
+		ExpectedRetry  time.Duration
+		ExpectError    bool
+	}{
+		{
+			Stream:         "\xef\xbb\xbfdata: hello\n\n",
+			ExpectedEvents: []event.Event{{Data: []byte("hello")}},
+		},
+		{
+			Stream: "data: a\r\ndata: b\r\n\r\ndata: c\rdata: d\r\r",
+			ExpectedEvents: []event.Event{
+				{Data: []byte("a\nb")},
+				{Data: []byte("c\nd")},
+			},
+		},
+		{
+			Stream: ": comment\nid: 1\nevent: greeting\ndata:hello\nunknown: field\n\ndata\n\n",
+			ExpectedEvents: []event.Event{
+				{ID: "1", Type: "greeting", Data: []byte("hello")},
+				{ID: "1", Data: []byte{}},
+			},
+		},
+		{
+			Stream:         "id: 1\nevent: ignored\n\nid: 2\x00\ndata: hello\n\n",
+			ExpectedEvents: []event.Event{{ID: "1", Data: []byte("hello")}},
+		},
+		{
+			Stream:         "retry: 1500\n\nretry: soon\n\ndata: hello\n\ndata: incomplete\n",
+			ExpectedEvents: []event.Event{{Data: []byte("hello")}},
+			ExpectedRetry:  time.Millisecond * 1500,
+		},
+		{
+			Stream:         "encoding: base64\ndata: Yf9i\n\n",
+			ExpectedEvents: []event.Event{{Data: []byte{'a', 0xff, 'b'}}},
+		},
+		{
+			Stream: "seq: 42 prices\ndata: 100\n\nseq: 7\ndata: hello\n\nseq: soon\ndata: late\n\n",
+			ExpectedEvents: []event.Event{
+				{Topic: "prices", Sequence: 42, Data: []byte("100")},
+				{Sequence: 7, Data: []byte("hello")},
+				{Data: []byte("late")},
+			},
+		},
+		{
+			Stream:      "encoding: base64\ndata: !\n\n",
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		dec := protocol.NewDecoder(strings.NewReader(tc.Stream))
+
+		var events []event.Event
+		var err error
+
+		for {
+			var e event.Event
+
+			if e, err = dec.Decode(); err != nil {
+				break
+			}
+
+			events = append(events, e)
+		}
+
+		assert.Equal(t, tc.ExpectError, err != io.EOF)
+		assert.Equal(t, tc.ExpectedEvents, events)
+		assert.Equal(t, tc.ExpectedRetry, dec.Retry())
+	}
+}
+
+func TestDecoder_RoundTrip(t *testing.T) {
+	events := []event.Event{
+		{ID: "1", Type: "greeting", Data: []byte("hello\nworld")},
+		{ID: "2", Data: []byte{'a', 0xff, 'b'}},
+	}
+
+	buf := &bytes.Buffer{}
+	enc := protocol.NewEncoder(buf)
+	enc.SetEncoding(protocol.EncodingBase64)
+
+	for _, e := range events {
+		assert.NoError(t, enc.Encode(e))
+	}
+
+	dec := protocol.NewDecoder(buf)
+
+	for _, expected := range events {
+		e, err := dec.Decode()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, e)
+	}
+
+	assert.Equal(t, "2", dec.LastEventID())
+}
@@ -0,0 +1,228 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Encoder type writes events to a stream using the SSE wire format.
+	Encoder struct {
+		w        io.Writer
+		encoding Encoding
+	}
+)
+
+var (
+	idField       = []byte("id: ")
+	typeField     = []byte("event: ")
+	chunkField    = []byte("chunk: ")
+	seqField      = []byte("seq: ")
+	dataField     = []byte("data: ")
+	encodingField = []byte("encoding: base64\n")
+	retryField    = []byte("retry: ")
+	newline       = []byte("\n")
+
+	// Buffers used to assemble frames are reused, so that encoding an event does not allocate.
+	buffers = sync.Pool{New: func() interface{} { return &bytes.Buffer{} }}
+
+	lineBreaks        = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+	lineBreakStripper = strings.NewReplacer("\r", "", "\n", "")
+)
+
+// Buffers larger than this are discarded rather than reused, so that a single large event does
+// not hold on to its memory.
+const maxPooledBuffer = 64 << 10
+
+// NewEncoder creates a new instance of the Encoder type that writes to 'w' using the
+// EncodingEscape payload encoding.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SetEncoding sets how payloads that contain newlines or are not valid UTF-8 are written.
+func (enc *Encoder) SetEncoding(encoding Encoding) {
+	enc.encoding = encoding
+}
+
+// Encode writes the event to the stream, encoding the data as necessary so that it cannot
+// corrupt the stream. Each event is written using a single call to the underlying writer, from
+// a buffer that is reused once the write returns.
+func (enc *Encoder) Encode(e event.Event) error {
+	buf := buffers.Get().(*bytes.Buffer)
+	defer releaseBuffer(buf)
+
+	// The identifier & type cannot be split across lines, so remove any
+	// line breaks from them.
+	if e.ID != "" {
+		writeStringField(buf, idField, stripLineBreaks(e.ID))
+	}
+
+	if e.Type != "" {
+		writeStringField(buf, typeField, stripLineBreaks(e.Type))
+	}
+
+	// If the event is part of a larger payload, describe which part it is.
+	if e.Chunk.Count > 0 {
+		var scratch [20]byte
+
+		buf.Write(chunkField)
+		buf.Write(strconv.AppendInt(scratch[:0], int64(e.Chunk.Index), 10))
+		buf.WriteByte(' ')
+		buf.Write(strconv.AppendInt(scratch[:0], int64(e.Chunk.Count), 10))
+		buf.WriteByte(' ')
+		buf.WriteString(stripLineBreaks(e.Chunk.ID))
+		buf.Write(newline)
+	}
+
+	// If the event is numbered, describe its position within its topic so that clients
+	// can detect events they have missed.
+	if e.Sequence > 0 {
+		var scratch [20]byte
+
+		buf.Write(seqField)
+		buf.Write(strconv.AppendUint(scratch[:0], e.Sequence, 10))
+
+		if e.Topic != "" {
+			buf.WriteByte(' ')
+			buf.WriteString(stripLineBreaks(e.Topic))
+		}
+
+		buf.Write(newline)
+	}
+
+	switch {
+	case safePayload(e.Data):
+		writeField(buf, dataField, e.Data)
+	case enc.encoding == EncodingEscape && escapablePayload(e.Data):
+		writeLines(buf, e.Data)
+	default:
+		buf.Write(encodingField)
+		buf.Write(dataField)
+		writeBase64(buf, e.Data)
+		buf.Write(newline)
+	}
+
+	// Terminate the event with a blank line.
+	buf.Write(newline)
+
+	_, err := enc.w.Write(buf.Bytes())
+	return err
+}
+
+// Retry writes a 'retry' field to the stream, informing the client how long to wait before
+// reconnecting.
+func (enc *Encoder) Retry(retry time.Duration) error {
+	buf := buffers.Get().(*bytes.Buffer)
+	defer releaseBuffer(buf)
+
+	var scratch [20]byte
+
+	buf.Write(retryField)
+	buf.Write(strconv.AppendInt(scratch[:0], int64(retry/time.Millisecond), 10))
+	buf.Write(newline)
+	buf.Write(newline)
+
+	_, err := enc.w.Write(buf.Bytes())
+	return err
+}
+
+// Comment writes a comment to the stream, which clients ignore. Comments are commonly used
+// to keep idle connections open. Each line of the text is written as its own comment.
+func (enc *Encoder) Comment(text string) error {
+	buf := buffers.Get().(*bytes.Buffer)
+	defer releaseBuffer(buf)
+
+	for _, line := range strings.Split(normalizeLineBreaks(text), "\n") {
+		buf.WriteByte(':')
+		buf.WriteString(line)
+		buf.Write(newline)
+	}
+
+	_, err := enc.w.Write(buf.Bytes())
+	return err
+}
+
+// safePayload determines if the data can be written as a single data field.
+func safePayload(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexAny(data, "\r\n") < 0
+}
+
+// escapablePayload determines if the data can be written as one data field per line & joined
+// back together exactly, which requires it to be valid UTF-8 whose only line breaks are line
+// feeds.
+func escapablePayload(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, '\r') < 0
+}
+
+// writeLines writes each line of the data as its own data field.
+func writeLines(buf *bytes.Buffer, data []byte) {
+	for {
+		i := bytes.IndexByte(data, '\n')
+
+		if i < 0 {
+			writeField(buf, dataField, data)
+			return
+		}
+
+		writeField(buf, dataField, data[:i])
+		data = data[i+1:]
+	}
+}
+
+func writeField(buf *bytes.Buffer, field, value []byte) {
+	buf.Write(field)
+	buf.Write(value)
+	buf.Write(newline)
+}
+
+func writeStringField(buf *bytes.Buffer, field []byte, value string) {
+	buf.Write(field)
+	buf.WriteString(value)
+	buf.Write(newline)
+}
+
+// writeBase64 encodes the data directly into the buffer's unused capacity.
+func writeBase64(buf *bytes.Buffer, data []byte) {
+	n := base64.StdEncoding.EncodedLen(len(data))
+	buf.Grow(n)
+
+	out := buf.AvailableBuffer()[:n]
+	base64.StdEncoding.Encode(out, data)
+	buf.Write(out)
+}
+
+// releaseBuffer returns the buffer to the pool, unless it has grown so large that keeping it
+// would waste memory.
+func releaseBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+
+	buf.Reset()
+	buffers.Put(buf)
+}
+
+func normalizeLineBreaks(value string) string {
+	if strings.IndexByte(value, '\r') < 0 {
+		return value
+	}
+
+	return lineBreaks.Replace(value)
+}
+
+func stripLineBreaks(value string) string {
+	if strings.IndexAny(value, "\r\n") < 0 {
+		return value
+	}
+
+	return lineBreakStripper.Replace(value)
+}
@@ -0,0 +1,103 @@
+package protocol_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoder_Encode(t *testing.T) {
+	tt := []struct {
+		Encoding       protocol.Encoding
+		Event          event.Event
+		ExpectedOutput string
+	}{
+		{
+			Event:          event.Event{Data: []byte("hello world")},
+			ExpectedOutput: "data: hello world\n\n",
+		},
+		{
+			Event:          event.Event{ID: "1\n", Type: "greeting", Data: []byte("hello\nworld")},
+			ExpectedOutput: "id: 1\nevent: greeting\ndata: hello\ndata: world\n\n",
+		},
+		{
+			Event:          event.Event{Data: []byte("hello\r\nworld")},
+			ExpectedOutput: "encoding: base64\ndata: aGVsbG8NCndvcmxk\n\n",
+		},
+		{
+			Event:          event.Event{Data: []byte{'a', 0xff, 'b'}},
+			ExpectedOutput: "encoding: base64\ndata: Yf9i\n\n",
+		},
+		{
+			Encoding:       protocol.EncodingBase64,
+			Event:          event.Event{Data: []byte{'a', 0xff, 'b'}},
+			ExpectedOutput: "encoding: base64\ndata: Yf9i\n\n",
+		},
+		{
+			Event:          event.Event{ID: "1", Topic: "prices", Sequence: 42, Data: []byte("100")},
+			ExpectedOutput: "id: 1\nseq: 42 prices\ndata: 100\n\n",
+		},
+		{
+			Event:          event.Event{Sequence: 7, Data: []byte("hello")},
+			ExpectedOutput: "seq: 7\ndata: hello\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		buf := &bytes.Buffer{}
+		enc := protocol.NewEncoder(buf)
+		enc.SetEncoding(tc.Encoding)
+
+		assert.NoError(t, enc.Encode(tc.Event))
+		assert.Equal(t, tc.ExpectedOutput, buf.String())
+	}
+}
+
+func TestEncoder_RoundTrip(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Encoding protocol.Encoding
+		Data     []byte
+	}{
+		{Name: "It should round trip text", Data: []byte("hello world")},
+		{Name: "It should round trip multiple lines", Data: []byte("hello\n\nworld\n")},
+		{Name: "It should round trip carriage returns", Data: []byte("hello\r\nworld\r")},
+		{Name: "It should round trip invalid UTF-8", Data: []byte{'a', 0xff, '\n', 0xfe, 'b'}},
+		{Name: "It should round trip an empty line", Data: []byte("\n")},
+		{Name: "It should round trip using base64", Encoding: protocol.EncodingBase64, Data: []byte("a\r\n\xffb")},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			enc := protocol.NewEncoder(buf)
+			enc.SetEncoding(tc.Encoding)
+
+			assert.NoError(t, enc.Encode(event.Event{Data: tc.Data}))
+
+			e, err := protocol.NewDecoder(buf).Decode()
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.Data, e.Data)
+			}
+		})
+	}
+}
+
+func TestEncoder_Retry(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	assert.NoError(t, protocol.NewEncoder(buf).Retry(time.Second))
+	assert.Equal(t, "retry: 1000\n\n", buf.String())
+}
+
+func TestEncoder_Comment(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	assert.NoError(t, protocol.NewEncoder(buf).Comment("keep\nalive"))
+	assert.Equal(t, ":keep\n:alive\n", buf.String())
+}
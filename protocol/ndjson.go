@@ -0,0 +1,56 @@
+package protocol
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The NDJSONEncoder type writes events to a stream as newline-delimited JSON, with each
+	// event written as a JSON object on its own line, for consumers that find this simpler to
+	// parse than the SSE wire format. See the event.Event type's MarshalJSON method for the
+	// representation of each event.
+	NDJSONEncoder struct {
+		w io.Writer
+	}
+)
+
+// NewNDJSONEncoder creates a new instance of the NDJSONEncoder type that writes to 'w'.
+func NewNDJSONEncoder(w io.Writer) *NDJSONEncoder {
+	return &NDJSONEncoder{w: w}
+}
+
+// Encode writes the event to the stream as a single line of JSON. Each event is written using
+// a single call to the underlying writer.
+func (enc *NDJSONEncoder) Encode(e event.Event) error {
+	data, err := json.Marshal(e)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = enc.w.Write(append(data, '\n'))
+	return err
+}
+
+// Retry writes a JSON object containing a 'retry' field to the stream, informing the client
+// how many milliseconds to wait before reconnecting.
+func (enc *NDJSONEncoder) Retry(retry time.Duration) error {
+	var scratch [32]byte
+
+	out := append(scratch[:0], `{"retry":`...)
+	out = strconv.AppendInt(out, int64(retry/time.Millisecond), 10)
+	out = append(out, "}\n"...)
+
+	_, err := enc.w.Write(out)
+	return err
+}
+
+// Comment does nothing, as newline-delimited JSON has no representation for comments.
+func (enc *NDJSONEncoder) Comment(text string) error {
+	return nil
+}
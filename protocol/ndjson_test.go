@@ -0,0 +1,47 @@
+package protocol_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNDJSONEncoder_Encode(t *testing.T) {
+	tt := []struct {
+		Events         []event.Event
+		ExpectedOutput string
+	}{
+		{
+			Events:         []event.Event{{ID: "1", Type: "greeting", Data: []byte("hello\nworld")}},
+			ExpectedOutput: "{\"id\":\"1\",\"type\":\"greeting\",\"data\":\"hello\\nworld\"}\n",
+		},
+		{
+			Events:         []event.Event{{Data: []byte("a")}, {Topic: "binary", Data: []byte{0xff}}},
+			ExpectedOutput: "{\"data\":\"a\"}\n{\"topic\":\"binary\",\"data\":\"/w==\",\"encoding\":\"base64\"}\n",
+		},
+	}
+
+	for _, tc := range tt {
+		buf := &bytes.Buffer{}
+		enc := protocol.NewNDJSONEncoder(buf)
+
+		for _, e := range tc.Events {
+			assert.NoError(t, enc.Encode(e))
+		}
+
+		assert.Equal(t, tc.ExpectedOutput, buf.String())
+	}
+}
+
+func TestNDJSONEncoder_Retry(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := protocol.NewNDJSONEncoder(buf)
+
+	assert.NoError(t, enc.Comment("ignored"))
+	assert.NoError(t, enc.Retry(time.Second))
+	assert.Equal(t, "{\"retry\":1000}\n", buf.String())
+}
@@ -0,0 +1,24 @@
+// Package protocol implements the Server Sent Events wire format, as described by the WHATWG HTML
+// specification (https://html.spec.whatwg.org/multipage/server-sent-events.html).
+package protocol
+
+type (
+	// Encoding determines how payloads that cannot be represented as-is in an SSE stream, such
+	// as those containing newlines or bytes that are not valid UTF-8, are written. Payloads that
+	// are safe are always written unmodified.
+	Encoding int
+)
+
+const (
+	// EncodingEscape writes each line of the payload as its own data field, which clients
+	// join back together with newlines. Payloads that cannot be joined back together exactly,
+	// such as those containing carriage returns or bytes that are not valid UTF-8, are
+	// written using the EncodingBase64 encoding instead, rather than being altered as a
+	// browser would do when decoding the stream. This is the default encoding.
+	EncodingEscape Encoding = iota
+
+	// EncodingBase64 writes unsafe payloads as standard base64 and precedes the data
+	// with an 'encoding' field set to 'base64', so that clients can decode the original
+	// bytes exactly. Browsers ignore the unknown field.
+	EncodingBase64
+)
@@ -0,0 +1,261 @@
+// Package server assembles a ready-made SSE relay from configuration, so that the broker can be
+// deployed without writing Go code. The broker, its handlers, TLS, authentication & metrics are
+// configured using a YAML file and environment variables.
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// The Config type contains the configuration of a server. Values are read from a YAML file,
+	// see the LoadConfig function, & may be overridden using environment variables, see the
+	// ApplyEnv function.
+	Config struct {
+		Addr             string        `yaml:"addr"`               // The address to listen on.
+		Timeout          time.Duration `yaml:"timeout"`            // How long the broker waits to write to a client.
+		Tolerance        int           `yaml:"tolerance"`          // The number of sequential errors before a client is disconnected.
+		QueueSize        int           `yaml:"queue_size"`         // The number of events queued for each client.
+		StoreSize        int           `yaml:"store_size"`         // The number of events stored in memory & replayed to reconnecting clients.
+		StoreCompaction  time.Duration `yaml:"store_compaction"`   // If non-zero, stored events older than this are compacted by their key.
+		SessionGrace     time.Duration `yaml:"session_grace"`      // How long a client's session can be resumed after its connection drops.
+		MaxConnectionAge time.Duration `yaml:"max_connection_age"` // How long client streams last before clients are asked to reconnect.
+		CollectorURL     string        `yaml:"collector_url"`      // If set, broadcast events are also published to this collector.
+		SecurityHeaders  bool          `yaml:"security_headers"`   // If true, streams set headers that stop proxies buffering or transforming them.
+		MaxConnsPerIP    int           `yaml:"max_conns_per_ip"`   // If non-zero, the number of streams that can be open at once from each IP address.
+		ProxyProfile     string        `yaml:"proxy_profile"`      // How streams are adapted to proxies, either 'direct' or 'compatible', see the broker.ProxyProfile type.
+		AsyncPublish     bool          `yaml:"async_publish"`      // If true, the broadcast handler responds with a 202 once events are queued, rather than once they are written.
+		PublisherKeys    bool          `yaml:"publisher_keys"`     // If true, publishers must present an API key created using the keys handler.
+		MaxPending       int           `yaml:"max_pending"`        // If non-zero, the number of events queued across all clients at which publishers are told to back off.
+		MemoryBudget     int           `yaml:"memory_budget"`      // If non-zero, the bytes of event data buffered for clients & by the store before the oldest are discarded.
+		Paths            Paths         `yaml:"paths"`              // The paths each of the handlers are registered to.
+		TLS              TLSConfig     `yaml:"tls"`                // If a certificate & key are set, the server is served over HTTPS.
+		Auth             AuthConfig    `yaml:"auth"`               // If tokens are set, requests must present one of them.
+		Metrics          MetricsConfig `yaml:"metrics"`            // Where the broker's statistics are pushed.
+		Faults           FaultConfig   `yaml:"faults"`             // Faults injected into client streams, for testing how clients recover. Not for production use.
+	}
+
+	// The Paths type contains the paths each of the server's handlers are registered to. Handlers
+	// with a blank path are not registered.
+	Paths struct {
+		Connect       string `yaml:"connect"`
+		Broadcast     string `yaml:"broadcast"`
+		Subscriptions string `yaml:"subscriptions"`
+		History       string `yaml:"history"`
+		Stats         string `yaml:"stats"`
+		Metrics       string `yaml:"metrics"`    // Prometheus metrics, see the broker.MetricsHandler function. Not registered by default.
+		Debug         string `yaml:"debug"`      // The debug page, see the broker.DebugHandler function. Not registered by default.
+		SelfCheck     string `yaml:"self_check"` // See the broker.SelfCheckHandler function. Not registered by default.
+		Vars          string `yaml:"vars"`       // Expvar variables, see the broker.ExpvarHandler function. Not registered by default.
+		Keys          string `yaml:"keys"`       // Publisher key management, see the broker.Broker's KeyHandler. Not registered by default & requires auth tokens.
+	}
+
+	// The TLSConfig type contains the paths to the PEM encoded certificate & private key used to
+	// serve HTTPS.
+	TLSConfig struct {
+		CertFile string `yaml:"cert_file"`
+		KeyFile  string `yaml:"key_file"`
+	}
+
+	// The AuthConfig type contains the bearer tokens accepted by the server. Tokens are read from
+	// the 'Authorization' header, or the 'access_token' query parameter for browsers that cannot
+	// set headers on an EventSource.
+	AuthConfig struct {
+		Tokens []string `yaml:"tokens"`
+	}
+
+	// The MetricsConfig type configures how the broker's statistics are pushed to a StatsD server
+	// & published as expvar variables.
+	MetricsConfig struct {
+		StatsDAddress  string        `yaml:"statsd_address"`
+		StatsDPrefix   string        `yaml:"statsd_prefix"`
+		StatsDInterval time.Duration `yaml:"statsd_interval"`
+		ExpvarPrefix   string        `yaml:"expvar_prefix"`
+	}
+
+	// The FaultConfig type configures the faults injected into client streams, so that applications
+	// can test their reconnect & replay logic against the server, see the broker.FaultConfig type.
+	FaultConfig struct {
+		DropEvery      int           `yaml:"drop_every"`
+		Latency        time.Duration `yaml:"latency"`
+		Jitter         time.Duration `yaml:"jitter"`
+		DisconnectRate float64       `yaml:"disconnect_rate"`
+		Seed           int64         `yaml:"seed"`
+	}
+)
+
+// The environment variables that override each configuration value, along with how their values
+// are applied.
+var envVars = []struct {
+	name string
+	set  func(cnf *Config, value string) error
+}{
+	{name: "SSE_ADDR", set: func(cnf *Config, v string) error { cnf.Addr = v; return nil }},
+	{name: "SSE_TIMEOUT", set: func(cnf *Config, v string) error { return parseDuration(v, &cnf.Timeout) }},
+	{name: "SSE_TOLERANCE", set: func(cnf *Config, v string) error { return parseInt(v, &cnf.Tolerance) }},
+	{name: "SSE_QUEUE_SIZE", set: func(cnf *Config, v string) error { return parseInt(v, &cnf.QueueSize) }},
+	{name: "SSE_STORE_SIZE", set: func(cnf *Config, v string) error { return parseInt(v, &cnf.StoreSize) }},
+	{name: "SSE_STORE_COMPACTION", set: func(cnf *Config, v string) error { return parseDuration(v, &cnf.StoreCompaction) }},
+	{name: "SSE_SESSION_GRACE", set: func(cnf *Config, v string) error { return parseDuration(v, &cnf.SessionGrace) }},
+	{name: "SSE_MAX_CONNECTION_AGE", set: func(cnf *Config, v string) error { return parseDuration(v, &cnf.MaxConnectionAge) }},
+	{name: "SSE_COLLECTOR_URL", set: func(cnf *Config, v string) error { cnf.CollectorURL = v; return nil }},
+	{name: "SSE_SECURITY_HEADERS", set: func(cnf *Config, v string) error { return parseBool(v, &cnf.SecurityHeaders) }},
+	{name: "SSE_MAX_CONNS_PER_IP", set: func(cnf *Config, v string) error { return parseInt(v, &cnf.MaxConnsPerIP) }},
+	{name: "SSE_PROXY_PROFILE", set: func(cnf *Config, v string) error { cnf.ProxyProfile = v; return nil }},
+	{name: "SSE_ASYNC_PUBLISH", set: func(cnf *Config, v string) error { return parseBool(v, &cnf.AsyncPublish) }},
+	{name: "SSE_PUBLISHER_KEYS", set: func(cnf *Config, v string) error { return parseBool(v, &cnf.PublisherKeys) }},
+	{name: "SSE_MAX_PENDING", set: func(cnf *Config, v string) error { return parseInt(v, &cnf.MaxPending) }},
+	{name: "SSE_MEMORY_BUDGET", set: func(cnf *Config, v string) error { return parseInt(v, &cnf.MemoryBudget) }},
+	{name: "SSE_TLS_CERT_FILE", set: func(cnf *Config, v string) error { cnf.TLS.CertFile = v; return nil }},
+	{name: "SSE_TLS_KEY_FILE", set: func(cnf *Config, v string) error { cnf.TLS.KeyFile = v; return nil }},
+	{name: "SSE_AUTH_TOKENS", set: func(cnf *Config, v string) error { cnf.Auth.Tokens = splitList(v); return nil }},
+	{name: "SSE_STATSD_ADDRESS", set: func(cnf *Config, v string) error { cnf.Metrics.StatsDAddress = v; return nil }},
+	{name: "SSE_STATSD_PREFIX", set: func(cnf *Config, v string) error { cnf.Metrics.StatsDPrefix = v; return nil }},
+	{name: "SSE_STATSD_INTERVAL", set: func(cnf *Config, v string) error { return parseDuration(v, &cnf.Metrics.StatsDInterval) }},
+	{name: "SSE_EXPVAR_PREFIX", set: func(cnf *Config, v string) error { cnf.Metrics.ExpvarPrefix = v; return nil }},
+	{name: "SSE_FAULT_DROP_EVERY", set: func(cnf *Config, v string) error { return parseInt(v, &cnf.Faults.DropEvery) }},
+	{name: "SSE_FAULT_LATENCY", set: func(cnf *Config, v string) error { return parseDuration(v, &cnf.Faults.Latency) }},
+	{name: "SSE_FAULT_JITTER", set: func(cnf *Config, v string) error { return parseDuration(v, &cnf.Faults.Jitter) }},
+	{name: "SSE_FAULT_DISCONNECT_RATE", set: func(cnf *Config, v string) error { return parseFloat(v, &cnf.Faults.DisconnectRate) }},
+}
+
+// DefaultConfig returns the configuration used for values that are not set in the configuration
+// file or environment.
+func DefaultConfig() Config {
+	return Config{
+		Addr:      ":8080",
+		Timeout:   time.Second * 5,
+		Tolerance: 3,
+		Paths: Paths{
+			Connect:       "/connect",
+			Broadcast:     "/broadcast",
+			Subscriptions: "/subscriptions",
+			History:       "/history",
+			Stats:         "/stats",
+		},
+		Metrics: MetricsConfig{
+			ExpvarPrefix: "sse.",
+		},
+	}
+}
+
+// LoadConfig reads the YAML configuration file at the given path over the defaults, then applies
+// any environment variables over the result, see the ApplyEnv function. If 'path' is blank, only
+// the environment variables are applied. Unknown fields in the file are reported as errors.
+func LoadConfig(path string) (Config, error) {
+	cnf := DefaultConfig()
+
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return cnf, err
+		}
+
+		if err := yaml.UnmarshalStrict(data, &cnf); err != nil {
+			return cnf, fmt.Errorf("failed to parse %v: %v", path, err)
+		}
+	}
+
+	if err := ApplyEnv(&cnf); err != nil {
+		return cnf, err
+	}
+
+	if _, err := broker.ParseProxyProfile(cnf.ProxyProfile); err != nil {
+		return cnf, err
+	}
+
+	return cnf, nil
+}
+
+// ApplyEnv overrides the configuration using the environment variables that are set. Each variable
+// is named after the value it overrides, prefixed with 'SSE_', such as 'SSE_ADDR' or
+// 'SSE_TLS_CERT_FILE'. The 'SSE_AUTH_TOKENS' variable contains a comma separated list of tokens.
+func ApplyEnv(cnf *Config) error {
+	return applyEnv(cnf, os.LookupEnv)
+}
+
+func applyEnv(cnf *Config, lookup func(string) (string, bool)) error {
+	for _, env := range envVars {
+		value, ok := lookup(env.name)
+
+		if !ok {
+			continue
+		}
+
+		if err := env.set(cnf, value); err != nil {
+			return fmt.Errorf("invalid value for %v: %v", env.name, err)
+		}
+	}
+
+	return nil
+}
+
+func parseDuration(value string, out *time.Duration) error {
+	d, err := time.ParseDuration(value)
+
+	if err != nil {
+		return err
+	}
+
+	*out = d
+
+	return nil
+}
+
+func parseBool(value string, out *bool) error {
+	b, err := strconv.ParseBool(value)
+
+	if err != nil {
+		return err
+	}
+
+	*out = b
+
+	return nil
+}
+
+func parseInt(value string, out *int) error {
+	n, err := strconv.Atoi(value)
+
+	if err != nil {
+		return err
+	}
+
+	*out = n
+
+	return nil
+}
+
+func parseFloat(value string, out *float64) error {
+	f, err := strconv.ParseFloat(value, 64)
+
+	if err != nil {
+		return err
+	}
+
+	*out = f
+
+	return nil
+}
+
+// splitList splits a comma separated list, discarding blank items.
+func splitList(value string) []string {
+	var out []string
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tt := []struct {
+		Name          string
+		File          string
+		ExpectsError  bool
+		ExpectedValue func() Config
+	}{
+		{
+			Name: "It should read values over the defaults",
+			File: "addr: :9090\ntimeout: 10s\nstore_size: 100\npaths:\n  history: \"\"\nauth:\n  tokens: [secret]\n",
+			ExpectedValue: func() Config {
+				cnf := DefaultConfig()
+				cnf.Addr = ":9090"
+				cnf.Timeout = time.Second * 10
+				cnf.StoreSize = 100
+				cnf.Paths.History = ""
+				cnf.Auth.Tokens = []string{"secret"}
+				return cnf
+			},
+		},
+		{
+			Name:         "It should return an error for unknown fields",
+			File:         "address: :9090\n",
+			ExpectsError: true,
+		},
+		{
+			Name:         "It should return an error for unknown proxy profiles",
+			File:         "proxy_profile: nginx\n",
+			ExpectsError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sse")
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "sse.yaml")
+
+			if !assert.NoError(t, ioutil.WriteFile(path, []byte(tc.File), 0600)) {
+				return
+			}
+
+			cnf, err := LoadConfig(path)
+
+			if tc.ExpectsError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedValue(), cnf)
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tt := []struct {
+		Name          string
+		Env           map[string]string
+		ExpectsError  bool
+		ExpectedValue func() Config
+	}{
+		{
+			Name: "It should override values using environment variables",
+			Env: map[string]string{
+				"SSE_ADDR":             ":9090",
+				"SSE_TIMEOUT":          "10s",
+				"SSE_QUEUE_SIZE":       "64",
+				"SSE_TLS_CERT_FILE":    "cert.pem",
+				"SSE_AUTH_TOKENS":      "a, b,,",
+				"SSE_STATSD_ADDRESS":   "localhost:8125",
+				"SSE_MAX_CONNS_PER_IP": "10",
+				"SSE_PROXY_PROFILE":    "compatible",
+				"SSE_ASYNC_PUBLISH":    "true",
+				"SSE_PUBLISHER_KEYS":   "true",
+				"SSE_FAULT_DROP_EVERY": "5",
+				"SSE_FAULT_LATENCY":    "100ms",
+				"SSE_MAX_PENDING":      "1000",
+				"SSE_MEMORY_BUDGET":    "1048576",
+			},
+			ExpectedValue: func() Config {
+				cnf := DefaultConfig()
+				cnf.Addr = ":9090"
+				cnf.Timeout = time.Second * 10
+				cnf.QueueSize = 64
+				cnf.TLS.CertFile = "cert.pem"
+				cnf.Auth.Tokens = []string{"a", "b"}
+				cnf.Metrics.StatsDAddress = "localhost:8125"
+				cnf.MaxConnsPerIP = 10
+				cnf.ProxyProfile = "compatible"
+				cnf.AsyncPublish = true
+				cnf.PublisherKeys = true
+				cnf.Faults.DropEvery = 5
+				cnf.Faults.Latency = time.Millisecond * 100
+				cnf.MaxPending = 1000
+				cnf.MemoryBudget = 1048576
+				return cnf
+			},
+		},
+		{
+			Name:         "It should return an error for invalid values",
+			Env:          map[string]string{"SSE_TOLERANCE": "three"},
+			ExpectsError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			cnf := DefaultConfig()
+
+			err := applyEnv(&cnf, func(name string) (string, bool) {
+				value, ok := tc.Env[name]
+				return value, ok
+			})
+
+			if tc.ExpectsError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedValue(), cnf)
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/davidsbond/sse"
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/store"
+)
+
+type (
+	// The Server type is a standalone SSE relay. It hosts a broker along with its handlers,
+	// registered to the paths given in its configuration.
+	Server struct {
+		cnf    Config
+		broker broker.Broker
+		mux    *http.ServeMux
+	}
+)
+
+var (
+	// ErrMissingToken is the error returned to requests that do not present a token when the
+	// server requires one.
+	ErrMissingToken = errors.New("missing bearer token")
+
+	// ErrInvalidToken is the error returned to requests that present a token the server does not
+	// accept.
+	ErrInvalidToken = errors.New("invalid bearer token")
+)
+
+// New creates a new instance of the Server type using the given configuration. The server's broker
+// is created immediately, so events can be published to it before the server is started.
+func New(cnf Config) *Server {
+	var s store.Store
+
+	if cnf.StoreSize > 0 {
+		s = store.NewCompactingMemory(cnf.StoreSize, cnf.StoreCompaction)
+	}
+
+	var authorizer broker.Authorizer
+
+	if len(cnf.Auth.Tokens) > 0 {
+		authorizer = tokenAuthorizer(cnf.Auth.Tokens)
+	}
+
+	// The profile is validated when the configuration is loaded.
+	profile, _ := broker.ParseProxyProfile(cnf.ProxyProfile)
+
+	b := sse.NewBroker(sse.Config{
+		Timeout:          cnf.Timeout,
+		Tolerance:        cnf.Tolerance,
+		QueueSize:        cnf.QueueSize,
+		Store:            s,
+		SessionGrace:     cnf.SessionGrace,
+		MaxConnectionAge: cnf.MaxConnectionAge,
+		CollectorURL:     cnf.CollectorURL,
+		Authorizer:       authorizer,
+		SecurityHeaders:  cnf.SecurityHeaders,
+		ConnectionLimit:  broker.ConnectionLimit{Max: cnf.MaxConnsPerIP},
+		ProxyProfile:     profile,
+		AsyncPublish:     cnf.AsyncPublish,
+		PublisherKeys:    cnf.PublisherKeys,
+		Backpressure:     broker.BackpressureLimit{MaxPending: cnf.MaxPending},
+		MemoryBudget:     broker.MemoryBudget{MaxBytes: cnf.MemoryBudget},
+		Faults: broker.FaultConfig{
+			DropEvery:      cnf.Faults.DropEvery,
+			Latency:        cnf.Faults.Latency,
+			Jitter:         cnf.Faults.Jitter,
+			DisconnectRate: cnf.Faults.DisconnectRate,
+			Seed:           cnf.Faults.Seed,
+		},
+		StatsD: broker.StatsDConfig{
+			Address:  cnf.Metrics.StatsDAddress,
+			Prefix:   cnf.Metrics.StatsDPrefix,
+			Interval: cnf.Metrics.StatsDInterval,
+		},
+	})
+
+	srv := &Server{cnf: cnf, broker: b, mux: http.NewServeMux()}
+
+	handlers := []struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		{path: cnf.Paths.Connect, handler: b.ClientHandler},
+		{path: cnf.Paths.Broadcast, handler: b.EventHandler},
+		{path: cnf.Paths.Subscriptions, handler: b.SubscriptionHandler},
+		{path: cnf.Paths.History, handler: b.HistoryHandler},
+		{path: cnf.Paths.Stats, handler: srv.statsHandler(authorizer)},
+		{path: cnf.Paths.Metrics, handler: authorized(authorizer, broker.MetricsHandler(b))},
+		{path: cnf.Paths.SelfCheck, handler: broker.SelfCheckHandler},
+		{path: cnf.Paths.Keys, handler: b.KeyHandler},
+	}
+
+	for _, h := range handlers {
+		if h.path != "" {
+			srv.mux.HandleFunc(h.path, h.handler)
+		}
+	}
+
+	// Variables can only be published once per process, so they are only published
+	// when they are served.
+	if cnf.Paths.Vars != "" {
+		srv.mux.Handle(cnf.Paths.Vars, authorized(authorizer, broker.ExpvarHandler(b, cnf.Metrics.ExpvarPrefix)))
+	}
+
+	if cnf.Paths.Debug != "" {
+		srv.mux.Handle(cnf.Paths.Debug, broker.DebugHandler(b, broker.DebugConfig{
+			ConnectURL: cnf.Paths.Connect,
+			PublishURL: cnf.Paths.Broadcast,
+		}))
+	}
+
+	return srv
+}
+
+// Broker returns the broker hosted by the server.
+func (s *Server) Broker() broker.Broker {
+	return s.broker
+}
+
+// ServeHTTP routes the request to the handler registered to its path.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe listens on the configured address & serves requests until the context is
+// cancelled, see the Serve method.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.cnf.Addr)
+
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves requests accepted by the listener until the context is cancelled, at which point
+// connected clients are given the broker's timeout to disconnect & the broker is closed. If the
+// configuration contains a TLS certificate & key, requests are served over HTTPS.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	defer s.broker.Close()
+
+	server := &http.Server{Handler: s}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdown, cancel := context.WithTimeout(context.Background(), s.cnf.Timeout)
+		defer cancel()
+
+		server.Shutdown(shutdown)
+	}()
+
+	var err error
+
+	if s.cnf.TLS.CertFile != "" || s.cnf.TLS.KeyFile != "" {
+		err = server.ServeTLS(listener, s.cnf.TLS.CertFile, s.cnf.TLS.KeyFile)
+	} else {
+		err = server.Serve(listener)
+	}
+
+	if err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// statsHandler returns an HTTP handler that writes the broker's statistics as JSON. Requests are
+// authorized in the same way as requests to the broker's handlers.
+func (s *Server) statsHandler(authorizer broker.Authorizer) http.HandlerFunc {
+	return authorized(authorizer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.broker.Stats())
+	}))
+}
+
+// authorized returns an HTTP handler that calls 'h' for requests allowed by the authorizer. If
+// 'authorizer' is nil, every request is allowed.
+func authorized(authorizer broker.Authorizer, h http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authorizer != nil {
+			if err := authorizer(r); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	}
+}
+
+// tokenAuthorizer returns a broker.Authorizer that allows requests presenting one of the given
+// tokens, either as a bearer token in the 'Authorization' header or in the 'access_token' query
+// parameter.
+func tokenAuthorizer(tokens []string) broker.Authorizer {
+	return func(r *http.Request) error {
+		token := r.URL.Query().Get("access_token")
+
+		if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			token = strings.TrimPrefix(header, "Bearer ")
+		}
+
+		if token == "" {
+			return ErrMissingToken
+		}
+
+		for _, t := range tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return nil
+			}
+		}
+
+		return ErrInvalidToken
+	}
+}
@@ -0,0 +1,152 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/server"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Auth(t *testing.T) {
+	tt := []struct {
+		Name           string
+		Header         string
+		Query          string
+		ExpectedStatus int
+	}{
+		{
+			Name:           "It should reject requests without a token",
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "It should reject requests with an unknown token",
+			Header:         "Bearer unknown",
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "It should accept bearer tokens",
+			Header:         "Bearer secret",
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "It should accept tokens in the query string",
+			Query:          "?access_token=secret",
+			ExpectedStatus: http.StatusOK,
+		},
+	}
+
+	cnf := server.DefaultConfig()
+	cnf.Timeout = time.Second
+	cnf.Auth.Tokens = []string{"secret"}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			srv := server.New(cnf)
+			defer srv.Broker().Close()
+
+			r := httptest.NewRequest("POST", "/broadcast"+tc.Query, strings.NewReader("hello"))
+			r.Header.Set("Authorization", tc.Header)
+			w := httptest.NewRecorder()
+
+			srv.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.ExpectedStatus, w.Code)
+		})
+	}
+}
+
+func TestServer_Stats(t *testing.T) {
+	cnf := server.DefaultConfig()
+	cnf.Timeout = time.Second
+
+	srv := server.New(cnf)
+	defer srv.Broker().Close()
+
+	r := httptest.NewRequest("GET", "/stats", nil)
+	w := httptest.NewRecorder()
+
+	srv.ServeHTTP(w, r)
+
+	var stats broker.Stats
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	assert.Equal(t, 0, stats.Clients)
+}
+
+func TestServer_Metrics(t *testing.T) {
+	cnf := server.DefaultConfig()
+	cnf.Timeout = time.Second
+	cnf.Paths.Metrics = "/metrics"
+	cnf.Auth.Tokens = []string{"secret"}
+
+	srv := server.New(cnf)
+	defer srv.Broker().Close()
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/metrics?access_token=secret", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "sse_clients 0\n")
+}
+
+func TestServer_Vars(t *testing.T) {
+	cnf := server.DefaultConfig()
+	cnf.Timeout = time.Second
+	cnf.Paths.Vars = "/vars"
+	cnf.Metrics.ExpvarPrefix = "server_test."
+
+	srv := server.New(cnf)
+	defer srv.Broker().Close()
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/vars", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"server_test.clients": 0`)
+}
+
+func TestServer_Keys(t *testing.T) {
+	cnf := server.DefaultConfig()
+	cnf.Timeout = time.Second
+	cnf.PublisherKeys = true
+	cnf.Paths.Keys = "/keys"
+	cnf.Auth.Tokens = []string{"token"}
+
+	srv := server.New(cnf)
+	defer srv.Broker().Close()
+
+	publish := func(key string) int {
+		r := httptest.NewRequest("POST", "/broadcast?topic=news", strings.NewReader("hello"))
+		r.Header.Set("Authorization", "Bearer token")
+		r.Header.Set("X-SSE-Key", key)
+		w := httptest.NewRecorder()
+
+		srv.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, publish(""))
+
+	r := httptest.NewRequest("POST", "/keys", strings.NewReader(`{"topics": ["news"]}`))
+	r.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var key store.Key
+
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&key))
+	assert.Equal(t, http.StatusOK, publish(key.ID))
+}
@@ -0,0 +1,191 @@
+// Package source contains types that broadcast events from external systems, such as databases,
+// to the SSE broker.
+package source
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Outbox type tails an outbox table, broadcasting each new row as an event. Applications
+	// insert rows into the outbox within the same transaction as the changes they describe, so
+	// events are only broadcast for changes that have been committed.
+	//
+	// The position of the last row broadcast is recorded using a Watermark once each row has been
+	// broadcast. If the outbox stops before recording it, the row is broadcast again when it
+	// restarts. Each event's identifier is set to the row's position, so duplicates can be
+	// discarded by the broker's idempotency window, see the broker.WithIdempotencyWindow method.
+	Outbox struct {
+		db     *sql.DB
+		pub    broker.Publisher
+		config OutboxConfig
+	}
+
+	// The OutboxConfig type configures how an Outbox reads rows from its table.
+	OutboxConfig struct {
+		// A query selecting rows positioned after its first argument, in order, limited to the
+		// number of rows given as its second argument. For example, using PostgreSQL:
+		// "SELECT id, topic, type, data FROM outbox WHERE id > $1 ORDER BY id LIMIT $2".
+		Query string
+
+		// Converts the current row into its position & an event. If nil, the row's columns
+		// are expected to be the position, topic, type & data.
+		Scan func(rows *sql.Rows) (int64, event.Event, error)
+
+		Watermark Watermark     // Records the position of the last row broadcast. Defaults to a MemoryWatermark.
+		Interval  time.Duration // How often the table is polled for new rows. Defaults to one second.
+		BatchSize int           // The maximum number of rows read by each poll. Defaults to 100.
+	}
+)
+
+var (
+	// The errors returned by the broker when it refuses to broadcast an event at all.
+	refusals = []error{
+		broker.ErrRateLimited,
+		broker.ErrBackpressure,
+		broker.ErrMemoryBudget,
+		broker.ErrEventRejected,
+		broker.ErrBudgetExceeded,
+	}
+)
+
+// NewOutbox creates a new instance of the Outbox type that reads rows from 'db' & broadcasts
+// them to 'pub' using the given configuration.
+func NewOutbox(db *sql.DB, pub broker.Publisher, config OutboxConfig) *Outbox {
+	if config.Scan == nil {
+		config.Scan = scanRow
+	}
+
+	if config.Watermark == nil {
+		config.Watermark = &MemoryWatermark{}
+	}
+
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	return &Outbox{db: db, pub: pub, config: config}
+}
+
+// Run polls the table until the context is cancelled. If a batch is full, the next batch is read
+// immediately rather than waiting for the interval. Errors are retried on the next poll, and the
+// last error is returned once the context is cancelled.
+func (o *Outbox) Run(ctx context.Context) error {
+	var last error
+
+	for {
+		n, err := o.Poll(ctx)
+
+		if err != nil {
+			last = err
+		}
+
+		// If the batch was full, there may be more rows waiting.
+		if n == o.config.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return last
+		case <-time.After(o.config.Interval):
+		}
+	}
+}
+
+// Poll reads a single batch of rows positioned after the watermark, broadcasting each one and
+// advancing the watermark after it. Returns the number of rows broadcast. If the broker refuses a
+// row, such as when it is rate limited or under backpressure, the batch stops & the watermark is
+// left at the previous row so that it is retried. Errors writing a row's event to individual
+// clients do not stop the batch, the first is returned once it is complete.
+func (o *Outbox) Poll(ctx context.Context) (int, error) {
+	position, err := o.config.Watermark.Load()
+
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := o.db.QueryContext(ctx, o.config.Query, position, o.config.BatchSize)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	var (
+		n      int
+		failed error
+	)
+
+	for rows.Next() {
+		position, e, err := o.config.Scan(rows)
+
+		if err != nil {
+			return n, err
+		}
+
+		if e.ID == "" {
+			e.ID = strconv.FormatInt(position, 10)
+		}
+
+		// Events that were broadcast but could not be written to some clients are not
+		// broadcast again, unless the broker refused to broadcast them at all.
+		if err := o.pub.BroadcastEvent(e); refused(err) {
+			return n, err
+		} else if err != nil && failed == nil {
+			failed = err
+		}
+
+		if err := o.config.Watermark.Save(position); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+
+	return n, failed
+}
+
+// refused determines if the error means the broker refused to broadcast an event, rather than
+// failing to write it to some of its clients.
+func refused(err error) bool {
+	for _, target := range refusals {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scanRow converts a row containing the position, topic, type & data into an event.
+func scanRow(rows *sql.Rows) (int64, event.Event, error) {
+	var (
+		position int64
+		topic    sql.NullString
+		typ      sql.NullString
+		data     []byte
+	)
+
+	if err := rows.Scan(&position, &topic, &typ, &data); err != nil {
+		return 0, event.Event{}, err
+	}
+
+	return position, event.Event{Topic: topic.String, Type: typ.String, Data: data}, nil
+}
@@ -0,0 +1,269 @@
+package source_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/source"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// The outboxDriver type is a database/sql driver whose queries return the rows of an
+	// in-memory outbox table positioned after the first argument, limited to the second.
+	outboxDriver struct {
+		mux  sync.Mutex
+		rows [][]driver.Value
+	}
+
+	outboxConn struct {
+		driver *outboxDriver
+	}
+
+	outboxStmt struct {
+		driver *outboxDriver
+	}
+
+	outboxRows struct {
+		rows [][]driver.Value
+	}
+)
+
+var (
+	outbox = &outboxDriver{}
+)
+
+func init() {
+	sql.Register("outbox", outbox)
+}
+
+func (d *outboxDriver) Open(name string) (driver.Conn, error) {
+	return &outboxConn{driver: d}, nil
+}
+
+func (d *outboxDriver) insert(rows ...[]driver.Value) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.rows = append(d.rows, rows...)
+}
+
+func (c *outboxConn) Prepare(query string) (driver.Stmt, error) {
+	return &outboxStmt{driver: c.driver}, nil
+}
+
+func (c *outboxConn) Close() error {
+	return nil
+}
+
+func (c *outboxConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *outboxStmt) Close() error {
+	return nil
+}
+
+func (s *outboxStmt) NumInput() int {
+	return 2
+}
+
+func (s *outboxStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *outboxStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.driver.mux.Lock()
+	defer s.driver.mux.Unlock()
+
+	after, limit := args[0].(int64), args[1].(int64)
+	out := &outboxRows{}
+
+	for _, row := range s.driver.rows {
+		if row[0].(int64) > after && int64(len(out.rows)) < limit {
+			out.rows = append(out.rows, row)
+		}
+	}
+
+	return out, nil
+}
+
+func (r *outboxRows) Columns() []string {
+	return []string{"id", "topic", "type", "data"}
+}
+
+func (r *outboxRows) Close() error {
+	return nil
+}
+
+func (r *outboxRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
+
+func TestOutbox_Poll(t *testing.T) {
+	tt := []struct {
+		Name              string
+		BatchSize         int
+		Watermark         int64
+		PublishError      error
+		ExpectedIDs       []string
+		ExpectedWatermark int64
+		ExpectedError     error
+	}{
+		{
+			Name:              "It should broadcast rows after the watermark",
+			ExpectedIDs:       []string{"1", "2", "3"},
+			ExpectedWatermark: 3,
+		},
+		{
+			Name:              "It should resume from the watermark",
+			Watermark:         2,
+			ExpectedIDs:       []string{"3"},
+			ExpectedWatermark: 3,
+		},
+		{
+			Name:              "It should read at most one batch",
+			BatchSize:         2,
+			ExpectedIDs:       []string{"1", "2"},
+			ExpectedWatermark: 2,
+		},
+		{
+			Name:              "It should not advance the watermark if the broker rate limits events",
+			PublishError:      broker.ErrRateLimited,
+			ExpectedIDs:       []string{"1"},
+			ExpectedWatermark: 0,
+			ExpectedError:     broker.ErrRateLimited,
+		},
+		{
+			Name:              "It should not advance the watermark if the broker is under backpressure",
+			PublishError:      &broker.BackpressureError{Pending: 10},
+			ExpectedIDs:       []string{"1"},
+			ExpectedWatermark: 0,
+			ExpectedError:     &broker.BackpressureError{Pending: 10},
+		},
+		{
+			Name:              "It should not advance the watermark if the broker's memory budget is exceeded",
+			PublishError:      broker.ErrMemoryBudget,
+			ExpectedIDs:       []string{"1"},
+			ExpectedWatermark: 0,
+			ExpectedError:     broker.ErrMemoryBudget,
+		},
+		{
+			Name:              "It should not advance the watermark if an interceptor rejects events",
+			PublishError:      fmt.Errorf("%w: invalid", broker.ErrEventRejected),
+			ExpectedIDs:       []string{"1"},
+			ExpectedWatermark: 0,
+			ExpectedError:     fmt.Errorf("%w: invalid", broker.ErrEventRejected),
+		},
+		{
+			Name:              "It should not advance the watermark if the error budget is exceeded",
+			PublishError:      broker.ErrBudgetExceeded,
+			ExpectedIDs:       []string{"1"},
+			ExpectedWatermark: 0,
+			ExpectedError:     broker.ErrBudgetExceeded,
+		},
+		{
+			Name:              "It should advance the watermark if events cannot be written to some clients",
+			PublishError:      errors.New("client timed out"),
+			ExpectedIDs:       []string{"1", "2", "3"},
+			ExpectedWatermark: 3,
+			ExpectedError:     errors.New("client timed out"),
+		},
+	}
+
+	outbox.rows = nil
+	outbox.insert(
+		[]driver.Value{int64(1), "orders", "created", []byte("a")},
+		[]driver.Value{int64(2), "orders", "updated", []byte("b")},
+		[]driver.Value{int64(3), nil, nil, []byte("c")},
+	)
+
+	db, err := sql.Open("outbox", "")
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer db.Close()
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			pub := ssetest.NewBroker()
+			pub.FailWith(tc.PublishError)
+
+			watermark := &source.MemoryWatermark{}
+			watermark.Save(tc.Watermark)
+
+			o := source.NewOutbox(db, pub, source.OutboxConfig{
+				Query:     "SELECT id, topic, type, data FROM outbox WHERE id > $1 ORDER BY id LIMIT $2",
+				Watermark: watermark,
+				BatchSize: tc.BatchSize,
+			})
+
+			_, err := o.Poll(context.Background())
+			assert.Equal(t, tc.ExpectedError, err)
+
+			var ids []string
+
+			for _, p := range pub.Published() {
+				ids = append(ids, p.Event.ID)
+			}
+
+			assert.Equal(t, tc.ExpectedIDs, ids)
+
+			position, _ := watermark.Load()
+			assert.Equal(t, tc.ExpectedWatermark, position)
+		})
+	}
+}
+
+func TestOutbox_Run(t *testing.T) {
+	outbox.rows = nil
+
+	db, err := sql.Open("outbox", "")
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer db.Close()
+
+	pub := ssetest.NewBroker()
+	o := source.NewOutbox(db, pub, source.OutboxConfig{
+		Query:    "SELECT id, topic, type, data FROM outbox WHERE id > $1 ORDER BY id LIMIT $2",
+		Interval: time.Millisecond * 100,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() { done <- o.Run(ctx) }()
+
+	// Rows inserted while running are broadcast on the next poll.
+	outbox.insert([]driver.Value{int64(1), "orders", "created", []byte("a")})
+	<-time.Tick(time.Millisecond * 500)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	if assert.Len(t, pub.Published(), 1) {
+		assert.Equal(t, "orders", pub.Published()[0].Event.Topic)
+		assert.Equal(t, []byte("a"), pub.Published()[0].Event.Data)
+	}
+}
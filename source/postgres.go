@@ -0,0 +1,153 @@
+package source
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/lib/pq"
+)
+
+type (
+	// The Listener interface describes types that receive notifications sent by PostgreSQL using
+	// NOTIFY, such as the pq.Listener type.
+	Listener interface {
+		Listen(channel string) error
+		NotificationChannel() <-chan *pq.Notification
+		Ping() error
+		Close() error
+	}
+
+	// The Postgres type listens on PostgreSQL channels, broadcasting each notification's payload
+	// as an event on the topic the channel is mapped to. If the connection is lost, it is
+	// re-established & the channels are listened on again.
+	Postgres struct {
+		listener Listener
+		pub      broker.Publisher
+		config   PostgresConfig
+	}
+
+	// The PostgresConfig type configures which channels are listened on & how the connection is
+	// maintained.
+	PostgresConfig struct {
+		// The channels to listen on, mapped to the topic that their notifications are broadcast
+		// to. Notifications on channels mapped to a blank topic are broadcast to every client.
+		Channels map[string]string
+
+		MinReconnectInterval time.Duration // The time to wait before the first attempt to reconnect. Defaults to one second.
+		MaxReconnectInterval time.Duration // The longest time to wait between attempts to reconnect. Defaults to one minute.
+		PingInterval         time.Duration // How often an idle connection is checked. Defaults to one minute.
+
+		// If set, called after the connection has been re-established. Notifications sent while
+		// disconnected are lost, so this can be used to broadcast the current state.
+		OnReconnect func()
+	}
+)
+
+// NewPostgres creates a new instance of the Postgres type that connects to the database described
+// by 'dsn' & broadcasts notifications to 'pub' using the given configuration.
+func NewPostgres(dsn string, pub broker.Publisher, config PostgresConfig) *Postgres {
+	if config.MinReconnectInterval <= 0 {
+		config.MinReconnectInterval = time.Second
+	}
+
+	if config.MaxReconnectInterval <= 0 {
+		config.MaxReconnectInterval = time.Minute
+	}
+
+	listener := pq.NewListener(dsn, config.MinReconnectInterval, config.MaxReconnectInterval, nil)
+
+	return NewPostgresListener(listener, pub, config)
+}
+
+// NewPostgresListener creates a new instance of the Postgres type that receives notifications using
+// the given listener, which is responsible for maintaining its connection.
+func NewPostgresListener(listener Listener, pub broker.Publisher, config PostgresConfig) *Postgres {
+	if config.PingInterval <= 0 {
+		config.PingInterval = time.Minute
+	}
+
+	return &Postgres{listener: listener, pub: pub, config: config}
+}
+
+// Run listens on each configured channel & broadcasts notifications until the context is cancelled,
+// at which point the listener is closed. Errors broadcasting notifications do not stop the source,
+// the last is returned once the context is cancelled.
+func (p *Postgres) Run(ctx context.Context) error {
+	// Closing the listener releases any call to Listen that is waiting for a connection.
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+
+		p.listener.Close()
+	}()
+
+	channels := make([]string, 0, len(p.config.Channels))
+
+	for channel := range p.config.Channels {
+		channels = append(channels, channel)
+	}
+
+	sort.Strings(channels)
+
+	for _, channel := range channels {
+		if err := p.listener.Listen(channel); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+	}
+
+	var last error
+	notifications := p.listener.NotificationChannel()
+
+	for {
+		select {
+		case n, ok := <-notifications:
+			if !ok {
+				return last
+			}
+
+			if err := p.handle(n); err != nil {
+				last = err
+			}
+
+		// Check that an idle connection is still alive, so that a lost connection is
+		// noticed & re-established.
+		case <-time.After(p.config.PingInterval):
+			go p.listener.Ping()
+
+		case <-ctx.Done():
+			return last
+		}
+	}
+}
+
+// handle broadcasts the notification as an event. A nil notification is sent by the listener
+// once it has reconnected.
+func (p *Postgres) handle(n *pq.Notification) error {
+	if n == nil {
+		if p.config.OnReconnect != nil {
+			p.config.OnReconnect()
+		}
+
+		return nil
+	}
+
+	topic, ok := p.config.Channels[n.Channel]
+
+	if !ok {
+		return nil
+	}
+
+	return p.pub.BroadcastEvent(event.Event{Topic: topic, Data: []byte(n.Extra)})
+}
@@ -0,0 +1,109 @@
+package source_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/source"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// The fakeListener type delivers notifications sent using its notify method.
+	fakeListener struct {
+		channels      []string
+		notifications chan *pq.Notification
+		closed        chan struct{}
+	}
+)
+
+func (l *fakeListener) Listen(channel string) error {
+	l.channels = append(l.channels, channel)
+	return nil
+}
+
+func (l *fakeListener) NotificationChannel() <-chan *pq.Notification {
+	return l.notifications
+}
+
+func (l *fakeListener) Ping() error {
+	return nil
+}
+
+func (l *fakeListener) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func TestPostgres_Run(t *testing.T) {
+	tt := []struct {
+		Name              string
+		Notifications     []*pq.Notification
+		ExpectedTopics    []string
+		ExpectedData      []string
+		ExpectedReconnect bool
+	}{
+		{
+			Name: "It should broadcast notifications to the channel's topic",
+			Notifications: []*pq.Notification{
+				{Channel: "orders_changed", Extra: "1"},
+				{Channel: "all", Extra: "2"},
+			},
+			ExpectedTopics: []string{"orders", ""},
+			ExpectedData:   []string{"1", "2"},
+		},
+		{
+			Name: "It should ignore notifications on unmapped channels",
+			Notifications: []*pq.Notification{
+				{Channel: "unknown", Extra: "1"},
+			},
+		},
+		{
+			Name:              "It should report reconnections",
+			Notifications:     []*pq.Notification{nil},
+			ExpectedReconnect: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			pub := ssetest.NewBroker()
+			listener := &fakeListener{
+				notifications: make(chan *pq.Notification, len(tc.Notifications)),
+				closed:        make(chan struct{}),
+			}
+
+			reconnected := false
+			pg := source.NewPostgresListener(listener, pub, source.PostgresConfig{
+				Channels:    map[string]string{"orders_changed": "orders", "all": ""},
+				OnReconnect: func() { reconnected = true },
+			})
+
+			for _, n := range tc.Notifications {
+				listener.notifications <- n
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+			defer cancel()
+
+			assert.NoError(t, pg.Run(ctx))
+			<-listener.closed
+
+			assert.Equal(t, []string{"all", "orders_changed"}, listener.channels)
+			assert.Equal(t, tc.ExpectedReconnect, reconnected)
+
+			var topics, data []string
+
+			for _, p := range pub.Published() {
+				topics = append(topics, p.Event.Topic)
+				data = append(data, string(p.Event.Data))
+			}
+
+			assert.Equal(t, tc.ExpectedTopics, topics)
+			assert.Equal(t, tc.ExpectedData, data)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package source
+
+import (
+	"database/sql"
+	"sync"
+)
+
+type (
+	// The Watermark interface describes types that record the position of the last row a source
+	// has broadcast, so that it can resume from there after restarting.
+	Watermark interface {
+		Load() (int64, error)
+		Save(position int64) error
+	}
+
+	// The MemoryWatermark type records the position in memory. Sources using it broadcast every
+	// row again after restarting, so it is best suited to tests & development.
+	MemoryWatermark struct {
+		mux      sync.Mutex
+		position int64
+	}
+
+	// The SQLWatermark type records the position in a database, so that it commits alongside the
+	// application's own data.
+	SQLWatermark struct {
+		DB  *sql.DB
+		Get string // A query returning the position as a single column, such as "SELECT position FROM watermarks WHERE name = 'sse'".
+		Set string // A statement storing the position given as its only argument.
+	}
+)
+
+// Load returns the position of the last row broadcast.
+func (m *MemoryWatermark) Load() (int64, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	return m.position, nil
+}
+
+// Save records the position of the last row broadcast.
+func (m *MemoryWatermark) Save(position int64) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.position = position
+
+	return nil
+}
+
+// Load returns the position of the last row broadcast using the 'Get' query. If the query returns
+// no rows, the position is zero.
+func (w *SQLWatermark) Load() (int64, error) {
+	var position int64
+
+	err := w.DB.QueryRow(w.Get).Scan(&position)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return position, err
+}
+
+// Save records the position of the last row broadcast using the 'Set' statement.
+func (w *SQLWatermark) Save(position int64) error {
+	_, err := w.DB.Exec(w.Set, position)
+
+	return err
+}
@@ -0,0 +1,240 @@
+package source
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Webhook type is an HTTP handler that receives webhooks from third parties, verifying
+	// their signatures & broadcasting each one as an event. The provider that sent the webhook is
+	// determined by the 'provider' query parameter.
+	Webhook struct {
+		pub       broker.Publisher
+		providers map[string]WebhookProvider
+	}
+
+	// The WebhookProvider type configures how webhooks from a single provider are verified &
+	// converted into events. By default, the signature header contains the hex encoded HMAC of
+	// the request body, optionally preceded by a prefix.
+	WebhookProvider struct {
+		Secret          []byte           // The secret shared with the provider, used to sign webhooks.
+		SignatureHeader string           // The header containing the signature, such as 'X-Hub-Signature-256'.
+		Prefix          string           // Removed from the signature before it is compared, such as 'sha256='.
+		Hash            func() hash.Hash // The hash used by the HMAC. Defaults to SHA-256.
+		Topic           string           // The topic events are broadcast to. If blank, events are broadcast to every client.
+		TypeHeader      string           // If set, the header containing the event type, such as 'X-GitHub-Event'.
+		IDHeader        string           // If set, the header containing the event identifier, such as 'X-GitHub-Delivery'.
+		MaxBody         int64            // The largest body accepted, in bytes. Defaults to DefaultWebhookMaxBody.
+
+		// If set, replaces the default verification. Returns an error if the webhook was not
+		// sent by the provider.
+		Verify func(r *http.Request, body []byte) error
+	}
+)
+
+const (
+	// DefaultWebhookMaxBody is the largest webhook body accepted from providers that do not set
+	// their own MaxBody, in bytes.
+	DefaultWebhookMaxBody = 1 << 20
+)
+
+var (
+	// ErrInvalidSignature is the error returned when a webhook's signature does not match its
+	// body.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+
+	// ErrUnverifiedProvider is the error returned by NewWebhook when a provider has neither a
+	// secret nor a Verify function, so anyone could send webhooks on its behalf.
+	ErrUnverifiedProvider = errors.New("webhook provider has neither a secret nor a verify function")
+)
+
+// NewWebhook creates a new instance of the Webhook type that broadcasts webhooks sent by the given
+// providers to 'pub'. Providers are keyed by the value of the 'provider' query parameter that
+// identifies them. An error wrapping ErrUnverifiedProvider is returned if a provider has neither a
+// Secret nor a Verify function.
+func NewWebhook(pub broker.Publisher, providers map[string]WebhookProvider) (*Webhook, error) {
+	for name, provider := range providers {
+		if len(provider.Secret) == 0 && provider.Verify == nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnverifiedProvider, name)
+		}
+	}
+
+	return &Webhook{pub: pub, providers: providers}, nil
+}
+
+// GitHubWebhook returns a provider that verifies webhooks sent by GitHub using the given secret,
+// broadcasting them to the topic with their GitHub event as the event type.
+func GitHubWebhook(secret, topic string) WebhookProvider {
+	return WebhookProvider{
+		Secret:          []byte(secret),
+		SignatureHeader: "X-Hub-Signature-256",
+		Prefix:          "sha256=",
+		Topic:           topic,
+		TypeHeader:      "X-GitHub-Event",
+		IDHeader:        "X-GitHub-Delivery",
+	}
+}
+
+// StripeWebhook returns a provider that verifies webhooks sent by Stripe using the given signing
+// secret, broadcasting them to the topic. Webhooks signed longer than 'tolerance' ago are rejected,
+// if 'tolerance' is zero, five minutes is used.
+func StripeWebhook(secret, topic string, tolerance time.Duration) WebhookProvider {
+	if tolerance <= 0 {
+		tolerance = time.Minute * 5
+	}
+
+	p := WebhookProvider{Secret: []byte(secret), Topic: topic}
+	p.Verify = func(r *http.Request, body []byte) error {
+		return verifyStripe(r.Header.Get("Stripe-Signature"), body, p.Secret, tolerance, time.Now())
+	}
+
+	return p
+}
+
+// ServeHTTP verifies the webhook & broadcasts it. Unknown providers are rejected with a 404 status
+// code, bodies larger than the provider's MaxBody with a 413 status code & webhooks with invalid
+// signatures with a 401 status code. If the webhook cannot be broadcast, a 500 status code is
+// returned without describing the error.
+func (wh *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("provider")
+	provider, ok := wh.providers[name]
+
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown provider %v", name), http.StatusNotFound)
+		return
+	}
+
+	maxBody := provider.MaxBody
+
+	if maxBody <= 0 {
+		maxBody = DefaultWebhookMaxBody
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "webhook too large", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, "failed to read webhook", http.StatusBadRequest)
+		return
+	}
+
+	if err := provider.verify(r, body); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	e := event.Event{Topic: provider.Topic, Data: body}
+
+	if provider.TypeHeader != "" {
+		e.Type = r.Header.Get(provider.TypeHeader)
+	}
+
+	if provider.IDHeader != "" {
+		e.ID = r.Header.Get(provider.IDHeader)
+	}
+
+	// The error may describe the broker's internals, so it is not returned to the provider.
+	if err := wh.pub.BroadcastEvent(e); err != nil {
+		http.Error(w, "failed to publish webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// verify determines if the webhook was sent by the provider.
+func (p WebhookProvider) verify(r *http.Request, body []byte) error {
+	if p.Verify != nil {
+		return p.Verify(r, body)
+	}
+
+	signature := strings.TrimPrefix(r.Header.Get(p.SignatureHeader), p.Prefix)
+	expected, err := hex.DecodeString(signature)
+
+	if err != nil || !hmac.Equal(expected, sign(p.Hash, p.Secret, body)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// verifyStripe verifies a Stripe signature header, which contains the time the webhook was signed
+// & one or more signatures of the time & body.
+func verifyStripe(header string, body, secret []byte, tolerance time.Duration, now time.Time) error {
+	var (
+		timestamp  string
+		signatures [][]byte
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			if signature, err := hex.DecodeString(kv[1]); err == nil {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if now.Sub(time.Unix(seconds, 0)) > tolerance {
+		return fmt.Errorf("%w, signed too long ago", ErrInvalidSignature)
+	}
+
+	expected := sign(nil, secret, append([]byte(timestamp+"."), body...))
+
+	for _, signature := range signatures {
+		if hmac.Equal(signature, expected) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// sign returns the HMAC of the data using the given hash, or SHA-256 if it is nil.
+func sign(fn func() hash.Hash, secret, data []byte) []byte {
+	if fn == nil {
+		fn = sha256.New
+	}
+
+	mac := hmac.New(fn, secret)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,186 @@
+package source_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/source"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook_ServeHTTP(t *testing.T) {
+	sign := func(data string) string {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(data))
+
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	now := fmt.Sprint(time.Now().Unix())
+	old := fmt.Sprint(time.Now().Add(-time.Hour).Unix())
+
+	tt := []struct {
+		Name           string
+		Provider       string
+		Headers        map[string]string
+		Body           string
+		Err            error
+		ExpectedStatus int
+		ExpectedBody   string
+		ExpectedType   string
+		ExpectedID     string
+		ExpectedTopic  string
+	}{
+		{
+			Name:     "It should broadcast GitHub webhooks with a valid signature",
+			Provider: "github",
+			Headers: map[string]string{
+				"X-Hub-Signature-256": "sha256=" + sign(`{"action":"opened"}`),
+				"X-GitHub-Event":      "pull_request",
+				"X-GitHub-Delivery":   "1234",
+			},
+			Body:           `{"action":"opened"}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedType:   "pull_request",
+			ExpectedID:     "1234",
+			ExpectedTopic:  "github",
+		},
+		{
+			Name:           "It should reject GitHub webhooks with an invalid signature",
+			Provider:       "github",
+			Headers:        map[string]string{"X-Hub-Signature-256": "sha256=" + sign("something else")},
+			Body:           `{"action":"opened"}`,
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "It should broadcast Stripe webhooks with a valid signature",
+			Provider:       "stripe",
+			Headers:        map[string]string{"Stripe-Signature": "t=" + now + ",v1=" + sign(now+`.{"id":"evt_1"}`)},
+			Body:           `{"id":"evt_1"}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedTopic:  "payments",
+		},
+		{
+			Name:           "It should reject Stripe webhooks signed too long ago",
+			Provider:       "stripe",
+			Headers:        map[string]string{"Stripe-Signature": "t=" + old + ",v1=" + sign(old+`.{"id":"evt_1"}`)},
+			Body:           `{"id":"evt_1"}`,
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "It should reject unknown providers",
+			Provider:       "unknown",
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			Name:           "It should reject bodies larger than the provider allows",
+			Provider:       "small",
+			Headers:        map[string]string{"X-Signature": sign(`{"id":"evt_1"}`)},
+			Body:           `{"id":"evt_1"}`,
+			ExpectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			Name:           "It should not describe errors publishing the webhook",
+			Provider:       "github",
+			Headers:        map[string]string{"X-Hub-Signature-256": "sha256=" + sign(`{"action":"opened"}`)},
+			Body:           `{"action":"opened"}`,
+			Err:            errors.New("store: /var/lib/sse is full"),
+			ExpectedStatus: http.StatusInternalServerError,
+			ExpectedBody:   "failed to publish webhook\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			pub := ssetest.NewBroker()
+			pub.FailWith(tc.Err)
+
+			wh, err := source.NewWebhook(pub, map[string]source.WebhookProvider{
+				"github": source.GitHubWebhook("secret", "github"),
+				"stripe": source.StripeWebhook("secret", "payments", 0),
+				"small":  {Secret: []byte("secret"), SignatureHeader: "X-Signature", MaxBody: 8},
+			})
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			r := httptest.NewRequest("POST", "/webhooks?provider="+tc.Provider, strings.NewReader(tc.Body))
+
+			for k, v := range tc.Headers {
+				r.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			wh.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.ExpectedStatus, w.Code)
+
+			if tc.ExpectedBody != "" {
+				assert.Equal(t, tc.ExpectedBody, w.Body.String())
+			}
+
+			// Failed publishes are still recorded by the mock broker.
+			if tc.ExpectedStatus != http.StatusOK {
+				assert.Equal(t, tc.Err != nil, len(pub.Published()) > 0)
+				return
+			}
+
+			if assert.Len(t, pub.Published(), 1) {
+				e := pub.Published()[0].Event
+				assert.Equal(t, tc.ExpectedTopic, e.Topic)
+				assert.Equal(t, tc.ExpectedType, e.Type)
+				assert.Equal(t, tc.ExpectedID, e.ID)
+				assert.Equal(t, tc.Body, string(e.Data))
+			}
+		})
+	}
+}
+
+func TestNewWebhook(t *testing.T) {
+	tt := []struct {
+		Name        string
+		Provider    source.WebhookProvider
+		ExpectError bool
+	}{
+		{
+			Name:     "It should accept providers with a secret",
+			Provider: source.GitHubWebhook("secret", "github"),
+		},
+		{
+			Name: "It should accept providers with a verify function",
+			Provider: source.WebhookProvider{Verify: func(r *http.Request, body []byte) error {
+				return nil
+			}},
+		},
+		{
+			Name:        "It should reject providers that cannot verify webhooks",
+			Provider:    source.GitHubWebhook("", "github"),
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			wh, err := source.NewWebhook(ssetest.NewBroker(), map[string]source.WebhookProvider{"test": tc.Provider})
+
+			if tc.ExpectError {
+				assert.True(t, errors.Is(err, source.ErrUnverifiedProvider))
+				assert.Nil(t, wh)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NotNil(t, wh)
+		})
+	}
+}
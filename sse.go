@@ -4,20 +4,139 @@ import (
 	"time"
 
 	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/compress"
+	"github.com/davidsbond/sse/store"
 )
 
 type (
 	// The Config type contains configuration variables for the SSE broker.
 	Config struct {
-		Timeout      time.Duration       // Determines how long the broker will wait to write to a client.
-		Tolerance    int                 // Determines how many sequential errors a client can have until they are forcefully disconnected.
-		ErrorHandler broker.ErrorHandler // Defines a custom HTTP error handling method to use when controller errors occur.
+		Timeout           time.Duration            // Determines how long the broker will wait to write to a client.
+		Tolerance         int                      // Determines how many sequential errors a client can have until they are forcefully disconnected.
+		ErrorHandler      broker.ErrorHandler      // Defines a custom HTTP error handling method to use when controller errors occur.
+		ErrorPolicy       broker.ErrorPolicy       // If set, chooses the HTTP status code & Retry-After delay used when controller errors occur.
+		CollectorURL      string                   // If set, the broker will publish all broadcast events to the collector at this URL.
+		Compression       []compress.Codec         // The codecs that may be used to compress event streams, in order of preference.
+		CoalesceWindow    time.Duration            // If non-zero, events written to a client within this window are flushed together.
+		Deduplicate       broker.Comparator        // If set, broadcasts that duplicate the previous broadcast are suppressed. Use bytes.Equal for identical payloads.
+		Encoding          broker.PayloadEncoding   // Determines how payloads containing newlines or invalid UTF-8 are written to clients.
+		Shards            int                      // The number of shards each topic's clients are split into for concurrent delivery. Defaults to the number of CPUs.
+		MaxConnectionAge  time.Duration            // If non-zero, client streams are gracefully ended after this duration so clients reconnect elsewhere.
+		QueueSize         int                      // The number of events that can be queued for each client. If zero, each write waits for the client to receive the event.
+		Store             store.Store              // If set, broadcast events are persisted in the store & replayed to reconnecting clients.
+		SlowClients       client.SlowPolicy        // Determines when clients are too slow to keep up & how new events for them are handled.
+		ClientFromContext broker.ClientFromContext // If set, derives client ids, topics & metadata from values in the request context.
+		ReplayTTL         time.Duration            // If non-zero, stored events older than this are not replayed to reconnecting clients.
+		Takeover          bool                     // If true, a client connecting with the id of a connected client replaces the existing connection.
+		OnSubscribe       broker.SubscribeHook     // If set, produces events describing the current state of a topic, sent to clients before live events.
+		ClientLimits      broker.LimitsValidator   // If set, clients may override the timeout & tolerance using query parameters accepted by this function.
+		IdempotencyWindow time.Duration            // If non-zero, events published again within this window with the same idempotency key or id are discarded.
+		TenantQuota       broker.TenantQuota       // The connection & rate limits applied to each tenant of the broker.
+		TenantStore       broker.TenantStore       // If set, returns the store used to persist events published to each tenant.
+		BandwidthQuota    broker.BandwidthQuota    // The number of bytes that can be written to each client within a window of time.
+		ChunkSize         int                      // If non-zero, events with data larger than this many bytes are split into chunks.
+		OnDelivery        broker.DeliveryHook      // If set, called after each attempt to deliver an event to a client.
+		Cluster           broker.ClusterConfig     // If the advertised URL is set, the broker joins a cluster of brokers.
+		PolyfillSupport   bool                     // If true, streams include the padding & heartbeats required by EventSource polyfills.
+		Authorizer        broker.Authorizer        // If set, determines if each request to the broker's handlers is allowed.
+		FrameWriter       broker.FrameWriterFunc   // If set, creates the writer used to write frames to each client's SSE stream.
+		SessionGrace      time.Duration            // If non-zero, how long a client's session can be resumed after its connection drops.
+		PauseLimit        int                      // The number of events held for each paused client. Defaults to 1024.
+		StatsD            broker.StatsDConfig      // If the address is set, the broker's statistics are pushed to a StatsD server.
+		SecurityHeaders   bool                     // If true, streams set headers that stop proxies buffering or transforming them.
+		Handshake         bool                     // If true, each stream begins with an 'sse:hello' event describing the broker's capabilities.
+		ClientCookie      broker.CookieConfig      // If the secret is set, browsers are given a stable client id using a signed cookie.
+		AdminTopic        string                   // If set, the broker's system events are broadcast to this topic.
+		AdminStats        time.Duration            // If non-zero, how often a snapshot of the broker's statistics is broadcast to the admin topic.
+		AdminAuthorizer   broker.Authorizer        // If set, determines if each request to subscribe to the admin topic is allowed. Otherwise, they are refused.
+		PublisherKeys     bool                     // If true, publishers must present an API key, which can limit the topics, rate & size of their events.
+		Liveness          broker.LivenessConfig    // If the interval is set, streaming clients that stop sending pings are evicted.
+		Catalog           []broker.TopicInfo       // Topics to describe to clients discovering the streams that exist, see the broker.CatalogHandler function.
+		Faults            broker.FaultConfig       // Faults injected into client streams to test reconnect & replay logic. Not for production use.
+		Backpressure      broker.BackpressureLimit // If the maximum is set, broadcasts are rejected while too many events are queued for clients.
+		MemoryBudget      broker.MemoryBudget      // If the maximum is set, limits the bytes of event data buffered for clients & by the store.
+		WriteRetry        client.RetryPolicy       // Determines how writes that exceed the timeout are retried before counting as a failure.
+		Delta             broker.DeltaConfig       // Determines which topics are sent as JSON patches between whole documents.
+		RetainedTopics    []string                 // The topics whose most recent event is sent to clients when they subscribe.
+		Encrypter         broker.Encrypter         // If set, establishes how the data of the events written to each client is encrypted.
+		ConnectionLimit   broker.ConnectionLimit   // The number of streams that can be open at once from each source, such as an IP address.
+		ShutdownHint      *broker.ReconnectHint    // If set, sent to each client when the broker closes, telling it where & when to reconnect.
+		Interceptors      []broker.Interceptor     // Functions called with each event before it is published, which can modify or reject it.
+		Sequencing        bool                     // If true, events are numbered within their topic so that clients can detect missed events.
+		KeepSubscriptions bool                     // If true, the topics & groups of each client are recorded in the store & restored when it reconnects.
+		AnyMethod         bool                     // If true, the client & event handlers accept requests using any method rather than only GET & POST respectively.
+		OnWrite           broker.WriteHook         // If set, called after each event is written to a client with the bytes written, the latency since it was broadcast & any error.
+		ProxyProfile      broker.ProxyProfile      // Determines how streams are adapted to the proxies between the broker & its clients, such as broker.ProxyCompatible.
+		DispatchQueue     int                      // The number of events that can be queued for each topic by the broker's Dispatch method. Defaults to 256.
+		AsyncPublish      bool                     // If true, the event handler responds with a 202 once broadcast events are queued, rather than once they are written.
+		ProfilerLabels    bool                     // If true, the broker's goroutines are labelled with the clients & topics they serve in CPU & goroutine profiles.
+		Clock             clock.Clock              // If set, the broker measures time using this clock rather than the system time, such as an ssetest.Clock in tests.
 	}
 )
 
 // NewBroker creates a new instance of the SSE broker using the given configuration.
 func NewBroker(cnf Config) broker.Broker {
-	broker := broker.New(cnf.Timeout, cnf.Tolerance, cnf.ErrorHandler)
+	broker := broker.New(cnf.Timeout, cnf.Tolerance, cnf.ErrorHandler,
+		broker.WithCollector(cnf.CollectorURL),
+		broker.WithCompression(cnf.Compression...),
+		broker.WithCoalesceWindow(cnf.CoalesceWindow),
+		broker.WithDeduplication(cnf.Deduplicate),
+		broker.WithPayloadEncoding(cnf.Encoding),
+		broker.WithShards(cnf.Shards),
+		broker.WithMaxConnectionAge(cnf.MaxConnectionAge),
+		broker.WithQueueSize(cnf.QueueSize),
+		broker.WithStore(cnf.Store),
+		broker.WithSlowPolicy(cnf.SlowClients),
+		broker.WithClientFromContext(cnf.ClientFromContext),
+		broker.WithReplayTTL(cnf.ReplayTTL),
+		broker.WithTakeover(cnf.Takeover),
+		broker.WithOnSubscribe(cnf.OnSubscribe),
+		broker.WithClientLimits(cnf.ClientLimits),
+		broker.WithIdempotencyWindow(cnf.IdempotencyWindow),
+		broker.WithTenantQuota(cnf.TenantQuota),
+		broker.WithTenantStore(cnf.TenantStore),
+		broker.WithBandwidthQuota(cnf.BandwidthQuota),
+		broker.WithChunking(cnf.ChunkSize),
+		broker.WithDeliveryHook(cnf.OnDelivery),
+		broker.WithCluster(cnf.Cluster),
+		broker.WithPolyfillSupport(cnf.PolyfillSupport),
+		broker.WithAuthorizer(cnf.Authorizer),
+		broker.WithFrameWriter(cnf.FrameWriter),
+		broker.WithSessions(cnf.SessionGrace),
+		broker.WithPauseLimit(cnf.PauseLimit),
+		broker.WithStatsD(cnf.StatsD),
+		broker.WithSecurityHeaders(cnf.SecurityHeaders),
+		broker.WithHandshake(cnf.Handshake),
+		broker.WithClientCookie(cnf.ClientCookie),
+		broker.WithAdminTopic(cnf.AdminTopic),
+		broker.WithWriteRetry(cnf.WriteRetry),
+		broker.WithDeltaEncoding(cnf.Delta),
+		broker.WithRetainedTopics(cnf.RetainedTopics...),
+		broker.WithEncryption(cnf.Encrypter),
+		broker.WithConnectionLimit(cnf.ConnectionLimit),
+		broker.WithShutdownHint(cnf.ShutdownHint),
+		broker.WithInterceptors(cnf.Interceptors...),
+		broker.WithSequencing(cnf.Sequencing),
+		broker.WithPersistentSubscriptions(cnf.KeepSubscriptions),
+		broker.WithProfilerLabels(cnf.ProfilerLabels),
+		broker.WithErrorPolicy(cnf.ErrorPolicy),
+		broker.WithMethodChecks(!cnf.AnyMethod),
+		broker.WithWriteHook(cnf.OnWrite),
+		broker.WithProxyProfile(cnf.ProxyProfile),
+		broker.WithTopicWorkers(cnf.DispatchQueue),
+		broker.WithAsyncPublishing(cnf.AsyncPublish),
+		broker.WithAdminStats(cnf.AdminStats),
+		broker.WithAdminAuthorizer(cnf.AdminAuthorizer),
+		broker.WithPublisherKeys(cnf.PublisherKeys),
+		broker.WithLiveness(cnf.Liveness),
+		broker.WithCatalog(cnf.Catalog...),
+		broker.WithFaults(cnf.Faults),
+		broker.WithBackpressure(cnf.Backpressure),
+		broker.WithMemoryBudget(cnf.MemoryBudget),
+		broker.WithClock(cnf.Clock),
+	)
 
 	return broker
 }
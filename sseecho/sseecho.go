@@ -0,0 +1,62 @@
+// Package sseecho provides adapters for registering the handlers of the SSE broker with echo.
+// Other routers, such as gorilla/mux & chi, can use the broker's handlers directly.
+//
+// Example using echo (https://github.com/labstack/echo)
+//
+// e := echo.New()
+// e.GET("/connect", sseecho.ClientHandler(broker))
+// e.POST("/broadcast", sseecho.EventHandler(broker))
+// e.PATCH("/subscriptions", sseecho.SubscriptionHandler(broker))
+//
+// e.Start(":8080")
+package sseecho
+
+import (
+	"github.com/davidsbond/sse/broker"
+	"github.com/labstack/echo"
+)
+
+// ClientHandler returns an echo handler that allows a client to connect to the broker. See the
+// broker's ClientHandler method for details.
+func ClientHandler(b broker.HandlerProvider) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		b.ClientHandler(c.Response(), c.Request())
+		return nil
+	}
+}
+
+// EventHandler returns an echo handler that allows a client to broadcast an event to the
+// broker. See the broker's EventHandler method for details.
+func EventHandler(b broker.HandlerProvider) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		b.EventHandler(c.Response(), c.Request())
+		return nil
+	}
+}
+
+// SubscriptionHandler returns an echo handler that allows a connected client to change the
+// topics it is subscribed to. See the broker's SubscriptionHandler method for details.
+func SubscriptionHandler(b broker.HandlerProvider) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		b.SubscriptionHandler(c.Response(), c.Request())
+		return nil
+	}
+}
+
+// HistoryHandler returns an echo handler that returns stored events. See the broker's
+// HistoryHandler method for details.
+func HistoryHandler(b broker.HandlerProvider) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		b.HistoryHandler(c.Response(), c.Request())
+		return nil
+	}
+}
+
+// ClusterHandler returns an echo handler used by the members of a cluster to communicate with
+// each other. See the broker's ClusterHandler method for details.
+func ClusterHandler(b broker.HandlerProvider) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		b.ClusterHandler(c.Response(), c.Request())
+		return nil
+	}
+}
@@ -0,0 +1,54 @@
+package sseecho_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/protocol"
+	"github.com/davidsbond/sse/sseecho"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientHandler(t *testing.T) {
+	b := broker.New(time.Second, 3, nil)
+	defer b.Close()
+
+	r := echo.New()
+	r.GET("/connect", sseecho.ClientHandler(b))
+	r.POST("/broadcast", sseecho.EventHandler(b))
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	// The response headers are not sent until the first event is written.
+	responses := make(chan *http.Response, 1)
+
+	go func() {
+		resp, err := http.Get(server.URL + "/connect")
+		assert.NoError(t, err)
+		responses <- resp
+	}()
+
+	<-time.Tick(time.Second)
+
+	resp, err := http.Post(server.URL+"/broadcast", "text/plain", bytes.NewBufferString("hello"))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	}
+
+	resp = <-responses
+	defer resp.Body.Close()
+
+	e, err := protocol.NewDecoder(resp.Body).Decode()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(e.Data))
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+}
@@ -0,0 +1,57 @@
+// Package ssegin provides adapters for registering the handlers of the SSE broker with gin.
+// Other routers, such as gorilla/mux & chi, can use the broker's handlers directly.
+//
+// Example using gin (https://github.com/gin-gonic/gin)
+//
+// r := gin.Default()
+// r.GET("/connect", ssegin.ClientHandler(broker))
+// r.POST("/broadcast", ssegin.EventHandler(broker))
+// r.PATCH("/subscriptions", ssegin.SubscriptionHandler(broker))
+//
+// r.Run(":8080")
+package ssegin
+
+import (
+	"github.com/davidsbond/sse/broker"
+	"github.com/gin-gonic/gin"
+)
+
+// ClientHandler returns a gin handler that allows a client to connect to the broker. See the
+// broker's ClientHandler method for details.
+func ClientHandler(b broker.HandlerProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		b.ClientHandler(c.Writer, c.Request)
+	}
+}
+
+// EventHandler returns a gin handler that allows a client to broadcast an event to the broker.
+// See the broker's EventHandler method for details.
+func EventHandler(b broker.HandlerProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		b.EventHandler(c.Writer, c.Request)
+	}
+}
+
+// SubscriptionHandler returns a gin handler that allows a connected client to change the topics
+// it is subscribed to. See the broker's SubscriptionHandler method for details.
+func SubscriptionHandler(b broker.HandlerProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		b.SubscriptionHandler(c.Writer, c.Request)
+	}
+}
+
+// HistoryHandler returns a gin handler that returns stored events. See the broker's
+// HistoryHandler method for details.
+func HistoryHandler(b broker.HandlerProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		b.HistoryHandler(c.Writer, c.Request)
+	}
+}
+
+// ClusterHandler returns a gin handler used by the members of a cluster to communicate with each
+// other. See the broker's ClusterHandler method for details.
+func ClusterHandler(b broker.HandlerProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		b.ClusterHandler(c.Writer, c.Request)
+	}
+}
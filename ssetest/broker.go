@@ -0,0 +1,688 @@
+// Package ssetest provides in-memory implementations of the SSE broker & its clients so that
+// code which publishes events can be unit tested without real HTTP connections.
+package ssetest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+	"github.com/davidsbond/sse/store"
+)
+
+type (
+	// The Broker type is an in-memory implementation of the broker.Broker interface that
+	// records every event published to it. Events are delivered to subscribed clients
+	// immediately, without being stored, deduplicated or forwarded to a collector.
+	Broker struct {
+		mux       sync.Mutex
+		clients   map[string]*client.Client
+		published []Publication
+		scheduled []*scheduled
+		err       error
+		tenants   map[string]*Broker
+		keys      map[string]store.Key
+		nextKey   int
+		catalog   map[string]broker.TopicInfo
+		closed    chan struct{}
+		closeOnce sync.Once
+		clock     clock.Clock
+	}
+
+	// The Publication type describes an event that was published to the mock broker.
+	Publication struct {
+		To    string      // The id of the client the event was sent to, if it was sent to a single client.
+		Event event.Event // The event that was published.
+	}
+)
+
+// NewBroker creates a new instance of the mock broker with no subscribed clients.
+func NewBroker() *Broker {
+	return &Broker{
+		clients: make(map[string]*client.Client),
+		keys:    make(map[string]store.Key),
+		closed:  make(chan struct{}),
+		clock:   clock.Real(),
+	}
+}
+
+// SetClock sets the clock used to time scheduled & periodic events, such as a Clock that is
+// advanced by the test. If 'c' is nil, the system time is used.
+func (b *Broker) SetClock(c clock.Clock) {
+	if c == nil {
+		c = clock.Real()
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.clock = c
+}
+
+// currentClock returns the clock used by the broker.
+func (b *Broker) currentClock() clock.Clock {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.clock
+}
+
+// FailWith causes all subsequent publishes to the broker to return the given error, after
+// they have been recorded. If 'err' is nil, publishes succeed again.
+func (b *Broker) FailWith(err error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.err = err
+}
+
+// Published returns the events that have been published to the broker, in the order they
+// were published.
+func (b *Broker) Published() []Publication {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return append([]Publication(nil), b.published...)
+}
+
+// Reset discards the record of published events.
+func (b *Broker) Reset() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.published = nil
+}
+
+// Broadcast writes the given data to all subscribed clients.
+func (b *Broker) Broadcast(data []byte) error {
+	return b.BroadcastEvent(event.Event{Data: data})
+}
+
+// BroadcastTopic writes the given data to all clients subscribed to the given topic.
+func (b *Broker) BroadcastTopic(topic string, data []byte) error {
+	return b.BroadcastEvent(event.Event{Topic: topic, Data: data})
+}
+
+// BroadcastExcept writes the given data to all subscribed clients other than those with the
+// given ids.
+func (b *Broker) BroadcastExcept(excludeIDs []string, data []byte) error {
+	return b.BroadcastEvent(event.Event{Data: data, Except: excludeIDs})
+}
+
+// BroadcastGroup writes the given data to all subscribed clients that are members of the
+// given group.
+func (b *Broker) BroadcastGroup(group string, data []byte) error {
+	return b.BroadcastEvent(event.Event{Group: group, Data: data})
+}
+
+// BroadcastEvent writes the given event to all clients subscribed to the event's topic, or
+// to all subscribed clients if the event has no topic. If the event has an audience or group,
+// only clients whose metadata matches it or that are members of the group receive the event.
+// Clients the event excludes do not receive it.
+func (b *Broker) BroadcastEvent(e event.Event) error {
+	_, err := b.broadcast(e)
+
+	return err
+}
+
+// BroadcastSummary writes the given event in the same way as the BroadcastEvent method, returning
+// the number of clients it was delivered to, could not be written to & that were outside of its
+// audience or excluded from it.
+func (b *Broker) BroadcastSummary(e event.Event) (broker.Summary, error) {
+	return b.broadcast(e)
+}
+
+// Dispatch publishes the event in the same way as the BroadcastSummary method before returning,
+// then calls 'fn', if set, with the outcome, so that tests do not need to wait for it. The mock
+// broker does not queue events, so ErrPublishQueueFull is never returned.
+func (b *Broker) Dispatch(e event.Event, fn broker.DispatchCallback) error {
+	summary, err := b.broadcast(e)
+
+	if fn != nil {
+		fn(summary, err)
+	}
+
+	return nil
+}
+
+// BroadcastAsync publishes the event in the same way as the BroadcastSummary method before
+// returning, so that the outcome can be received from the returned channel immediately.
+func (b *Broker) BroadcastAsync(e event.Event) <-chan broker.BroadcastResult {
+	results := make(chan broker.BroadcastResult, 1)
+
+	summary, err := b.broadcast(e)
+	results <- broker.BroadcastResult{Summary: summary, Err: err}
+	close(results)
+
+	return results
+}
+
+func (b *Broker) broadcast(e event.Event) (broker.Summary, error) {
+	started := time.Now()
+
+	b.mux.Lock()
+	b.published = append(b.published, Publication{Event: e})
+
+	if b.err != nil {
+		defer b.mux.Unlock()
+		return broker.Summary{}, b.err
+	}
+
+	selector, err := broker.ParseSelector(e.Audience)
+
+	if err != nil {
+		b.mux.Unlock()
+		return broker.Summary{}, err
+	}
+
+	var (
+		clients []*client.Client
+		summary broker.Summary
+	)
+
+	for _, c := range b.clients {
+		if !e.Matches(c.Topics()) {
+			continue
+		}
+
+		if selector.Matches(c.Metadata()) && member(c, e.Group) && !e.Excludes(c.ID()) {
+			clients = append(clients, c)
+		} else {
+			summary.Skipped++
+		}
+	}
+
+	b.mux.Unlock()
+
+	summary.Failed, err = b.deliver(clients, e)
+	summary.Delivered = len(clients) - summary.Failed
+	summary.Duration = time.Since(started)
+
+	return summary, err
+}
+
+// BroadcastWithin writes the given event in the same way as the BroadcastEvent method. The budget
+// is not used by the mock broker.
+func (b *Broker) BroadcastWithin(e event.Event, budget broker.ErrorBudget) error {
+	return b.BroadcastEvent(e)
+}
+
+// BroadcastTo writes the given data to the client with the given id. If no such client is
+// subscribed, an error is returned.
+func (b *Broker) BroadcastTo(id string, data []byte) error {
+	return b.sendTo(id, event.Event{Data: data})
+}
+
+// sendTo writes the event to the client with the given id.
+func (b *Broker) sendTo(id string, e event.Event) error {
+	b.mux.Lock()
+	b.published = append(b.published, Publication{To: id, Event: e})
+	c, ok := b.clients[id]
+	err := b.err
+	b.mux.Unlock()
+
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("no client with id %v exists", id)
+	}
+
+	_, err = b.deliver([]*client.Client{c}, e)
+
+	return err
+}
+
+// Subscribe adds the client to the broker. If a client with the same id is already
+// subscribed, an error is returned.
+func (b *Broker) Subscribe(c *client.Client) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if _, ok := b.clients[c.ID()]; ok {
+		return fmt.Errorf("a client with id %v already exists", c.ID())
+	}
+
+	b.clients[c.ID()] = c
+
+	return nil
+}
+
+// Unsubscribe removes the client from the broker.
+func (b *Broker) Unsubscribe(c *client.Client) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.clients[c.ID()] == c {
+		delete(b.clients, c.ID())
+	}
+}
+
+// SubscribeLocal subscribes a goroutine to the broker, returning a channel of the events a client
+// subscribed to the topic would receive & a function that cancels the subscription & closes the
+// channel. The channel is also closed when the broker is closed.
+func (b *Broker) SubscribeLocal(topic string) (<-chan event.Event, func()) {
+	var topics []string
+
+	if topic != "" {
+		topics = append(topics, topic)
+	}
+
+	c := client.NewWithOptions(time.Second, 3, "", client.WithTopics(topics...), client.WithQueueSize(1024))
+	b.Subscribe(c)
+
+	out := make(chan event.Event)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case <-c.Ready():
+				for e, ok := c.Next(); ok; e, ok = c.Next() {
+					select {
+					case out <- e:
+					case <-done:
+						return
+					case <-b.closed:
+						return
+					}
+				}
+			case <-done:
+				return
+			case <-b.closed:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return out, func() {
+		once.Do(func() {
+			b.Unsubscribe(c)
+			close(done)
+		})
+	}
+}
+
+// ClientHandler is an HTTP handler that subscribes a client to the broker using the 'id' query
+// parameter & the requested topics, see the broker.ParseTopics function, and writes the events it receives until the request's context
+// is done or the client is unsubscribed. POST requests can give them in a JSON encoded broker.StreamRequest.
+func (b *Broker) ClientHandler(w http.ResponseWriter, r *http.Request) {
+	r, err := broker.ParseStreamRequest(r)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		http.Error(w, "client does not support streaming", http.StatusInternalServerError)
+		return
+	}
+
+	topics, _ := broker.ParseTopics(r)
+	c := client.NewWithOptions(time.Second, 3, r.URL.Query().Get("id"), client.WithTopics(topics...))
+
+	if err = b.Subscribe(c); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	defer b.Unsubscribe(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	enc := protocol.NewEncoder(w)
+
+	for b.subscribed(c) {
+		select {
+		case <-c.Ready():
+			for e, ok := c.Next(); ok; e, ok = c.Next() {
+				enc.Encode(e)
+			}
+
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-time.Tick(time.Second):
+			continue
+		}
+	}
+}
+
+// EventHandler is an HTTP handler that publishes the request body to the broker, using the
+// 'id', 'topic' & 'priority' query parameters in the same way as the broker.Broker's EventHandler.
+func (b *Broker) EventHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	priority, err := event.ParsePriority(r.URL.Query().Get("priority"))
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	e := event.Event{Data: data, Priority: priority}
+
+	if id != "" {
+		err = b.sendTo(id, e)
+	} else {
+		e.Topic = r.URL.Query().Get("topic")
+		err = b.BroadcastEvent(e)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Stats returns the number of subscribed clients, the subscribers of each topic, the number &
+// average size of the events published to each topic & the lag of each client. Shard, bandwidth
+// & event rate statistics are not recorded by the mock broker.
+func (b *Broker) Stats() broker.Stats {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	out := broker.Stats{
+		Clients: len(b.clients),
+		Topics:  make(map[string]broker.TopicStats),
+		Lag:     make(map[string]client.Lag),
+
+		Bandwidth: make(map[string]broker.Bandwidth),
+	}
+
+	for id, c := range b.clients {
+		out.Lag[id] = c.Lag()
+
+		for _, topic := range c.Topics() {
+			stats := out.Topics[topic]
+			stats.Subscribers++
+			out.Topics[topic] = stats
+		}
+	}
+
+	payloads := make(map[string]int)
+
+	for _, p := range b.published {
+		if p.Event.Topic == "" {
+			continue
+		}
+
+		stats := out.Topics[p.Event.Topic]
+		stats.Events++
+		out.Topics[p.Event.Topic] = stats
+		payloads[p.Event.Topic] += len(p.Event.Data)
+	}
+
+	for topic, size := range payloads {
+		stats := out.Topics[topic]
+		stats.AveragePayload = float64(size) / float64(stats.Events)
+		out.Topics[topic] = stats
+	}
+
+	return out
+}
+
+// Pending returns the events written to the client with the given id that it has not yet
+// taken from its queue.
+func (b *Broker) Pending(id string, payloads bool) ([]client.Pending, error) {
+	b.mux.Lock()
+	c, ok := b.clients[id]
+	b.mux.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no client with id %v exists", id)
+	}
+
+	return c.Pending(payloads), nil
+}
+
+// Pause holds events written to the client with the given id until it is resumed. See the
+// client.Client type's Pause method for details.
+func (b *Broker) Pause(id string) error {
+	b.mux.Lock()
+	c, ok := b.clients[id]
+	b.mux.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no client with id %v exists", id)
+	}
+
+	c.Pause(1024)
+
+	return nil
+}
+
+// Resume delivers the events held for the client with the given id since it was paused.
+func (b *Broker) Resume(id string) error {
+	b.mux.Lock()
+	c, ok := b.clients[id]
+	b.mux.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no client with id %v exists", id)
+	}
+
+	c.Resume()
+
+	return nil
+}
+
+// Writer returns a writer that publishes each line written to it as an event of the given type.
+// See the broker.NewWriter function for details.
+func (b *Broker) Writer(eventType string) io.WriteCloser {
+	return broker.NewWriter(b, eventType)
+}
+
+// ClientEndpoint returns an http.Handler that subscribes a client to the broker, accepting only
+// GET & POST requests by default. See the broker.Endpoint function for the options available.
+func (b *Broker) ClientEndpoint(opts ...broker.EndpointOption) http.Handler {
+	return broker.Endpoint(b.ClientHandler, append([]broker.EndpointOption{broker.WithMethods(http.MethodGet, http.MethodPost)}, opts...)...)
+}
+
+// EventEndpoint returns an http.Handler that publishes the request body to the broker, accepting
+// only POST requests by default. See the broker.Endpoint function for the options available.
+func (b *Broker) EventEndpoint(opts ...broker.EndpointOption) http.Handler {
+	return broker.Endpoint(b.EventHandler, append([]broker.EndpointOption{broker.WithMethods(http.MethodPost)}, opts...)...)
+}
+
+// StreamEndpoint returns an http.Handler that subscribes a client to the broker for requests that open
+// a stream, see the broker.OpensStream function, & publishes the request body for others, accepting only
+// GET & POST requests by default. See the broker.Endpoint function for the options available.
+func (b *Broker) StreamEndpoint(opts ...broker.EndpointOption) http.Handler {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if broker.OpensStream(r) {
+			b.ClientHandler(w, r)
+			return
+		}
+
+		b.EventHandler(w, r)
+	}
+
+	return broker.Endpoint(handler, append([]broker.EndpointOption{broker.WithMethods(http.MethodGet, http.MethodPost)}, opts...)...)
+}
+
+// ClusterHandler is an HTTP handler that responds with a 501 status code, as the mock broker
+// cannot be a member of a cluster.
+func (b *Broker) ClusterHandler(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "the broker is not part of a cluster", http.StatusNotImplemented)
+}
+
+// PingHandler is an HTTP handler that responds with a 204 status code if the client identified by
+// the 'client' query parameter is subscribed, or a 404 status code if it is not. The mock broker does
+// not evict clients that stop sending pings.
+func (b *Broker) PingHandler(w http.ResponseWriter, r *http.Request) {
+	b.mux.Lock()
+	_, ok := b.clients[r.URL.Query().Get("client")]
+	b.mux.Unlock()
+
+	if !ok {
+		http.Error(w, broker.ErrNotStreaming.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Tenant returns the mock broker for the tenant with the given name, creating it if this is
+// its first use. Tenants record their own publications & have no quotas.
+func (b *Broker) Tenant(name string) broker.Broker {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.tenants == nil {
+		b.tenants = make(map[string]*Broker)
+	}
+
+	tenant, ok := b.tenants[name]
+
+	if !ok {
+		tenant = NewBroker()
+		b.tenants[name] = tenant
+	}
+
+	return tenant
+}
+
+// Reconnect sends an 'sse:reconnect' event containing the hint to the subscribed clients with the
+// given ids, or to all subscribed clients if no ids are given. If any of the clients is not subscribed,
+// the first error is returned.
+func (b *Broker) Reconnect(hint broker.ReconnectHint, ids ...string) error {
+	e := hint.Event()
+
+	if len(ids) == 0 {
+		return b.BroadcastEvent(e)
+	}
+
+	var first error
+
+	for _, id := range ids {
+		if err := b.sendTo(id, e); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// OnSystemEvent does nothing, as the mock broker does not emit system events. The returned
+// function also does nothing.
+func (b *Broker) OnSystemEvent(fn func(broker.SystemEvent)) func() {
+	return func() {}
+}
+
+// OnClientDisconnect does nothing, as the mock broker does not emit system events. The returned
+// function also does nothing.
+func (b *Broker) OnClientDisconnect(fn func(id string, reason broker.DisconnectReason)) func() {
+	return func() {}
+}
+
+// Kick removes the client with the given id from the broker. If no such client is subscribed, an
+// error is returned.
+func (b *Broker) Kick(id string) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if _, ok := b.clients[id]; !ok {
+		return fmt.Errorf("no client with id %v exists", id)
+	}
+
+	delete(b.clients, id)
+
+	return nil
+}
+
+// Close unsubscribes all clients from the broker, cancels any scheduled events, stops
+// any periodic events & closes any tenants.
+func (b *Broker) Close() error {
+	b.closeOnce.Do(func() { close(b.closed) })
+
+	b.mux.Lock()
+	scheduled := b.scheduled
+	tenants := b.tenants
+	b.clients = make(map[string]*client.Client)
+	b.scheduled = nil
+	b.tenants = nil
+	b.mux.Unlock()
+
+	for _, s := range scheduled {
+		s.Cancel()
+	}
+
+	for _, tenant := range tenants {
+		tenant.Close()
+	}
+
+	return nil
+}
+
+// deliver writes the event to each client, unsubscribing those that exceed their error
+// tolerance. All errors are concatenated with newlines.
+func (b *Broker) deliver(clients []*client.Client, e event.Event) (int, error) {
+	var out []string
+
+	for _, c := range clients {
+		if err := c.WriteEvent(e); err != nil {
+			out = append(out, err.Error())
+
+			if c.ShouldDisconnect() {
+				b.Unsubscribe(c)
+			}
+		}
+	}
+
+	if len(out) > 0 {
+		return len(out), errors.New(strings.Join(out, "\n"))
+	}
+
+	return 0, nil
+}
+
+func (b *Broker) subscribed(c *client.Client) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.clients[c.ID()] == c
+}
+
+// member determines if the client is a member of the group. Every client is a member of the
+// blank group.
+func member(c *client.Client, group string) bool {
+	if group == "" {
+		return true
+	}
+
+	for _, g := range c.Groups() {
+		if g == group {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,233 @@
+package ssetest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ broker.Broker = ssetest.NewBroker()
+
+func TestBroker_Publish(t *testing.T) {
+	tt := []struct {
+		Publish        func(b broker.Publisher) error
+		ExpectedTo     string
+		ExpectedNews   int
+		ExpectedSports int
+	}{
+		{
+			Publish:        func(b broker.Publisher) error { return b.Broadcast([]byte("all")) },
+			ExpectedNews:   1,
+			ExpectedSports: 1,
+		},
+		{
+			Publish:      func(b broker.Publisher) error { return b.BroadcastTopic("news", []byte("news")) },
+			ExpectedNews: 1,
+		},
+		{
+			Publish:        func(b broker.Publisher) error { return b.BroadcastTo("sports", []byte("direct")) },
+			ExpectedTo:     "sports",
+			ExpectedSports: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		b := ssetest.NewBroker()
+		news := ssetest.NewClient("news", client.WithTopics("news"))
+		sports := ssetest.NewClient("sports", client.WithTopics("sports"))
+
+		assert.NoError(t, b.Subscribe(news.Client))
+		assert.NoError(t, b.Subscribe(sports.Client))
+		assert.NoError(t, tc.Publish(b))
+
+		published := b.Published()
+
+		assert.Len(t, published, 1)
+		assert.Equal(t, tc.ExpectedTo, published[0].To)
+
+		<-time.Tick(time.Second)
+
+		assert.Len(t, news.Events(), tc.ExpectedNews)
+		assert.Len(t, sports.Events(), tc.ExpectedSports)
+
+		news.Close()
+		sports.Close()
+	}
+}
+
+func TestBroker_FailWith(t *testing.T) {
+	b := ssetest.NewBroker()
+	c := ssetest.NewClient("test")
+	defer c.Close()
+
+	assert.NoError(t, b.Subscribe(c.Client))
+	assert.Error(t, b.Subscribe(ssetest.NewClient("test").Client))
+
+	b.FailWith(errors.New("failed"))
+	assert.EqualError(t, b.BroadcastEvent(event.Event{Data: []byte("a")}), "failed")
+
+	b.FailWith(nil)
+	assert.NoError(t, b.BroadcastEvent(event.Event{Data: []byte("b")}))
+
+	events, err := c.Wait(1, time.Second)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("b"), events[0].Data)
+	assert.Len(t, b.Published(), 2)
+	assert.Equal(t, 1, b.Stats().Clients)
+
+	b.Reset()
+	assert.Empty(t, b.Published())
+}
+
+func TestBroker_BroadcastAfter(t *testing.T) {
+	b := ssetest.NewBroker()
+
+	published := b.BroadcastAfter(time.Millisecond*100, event.Event{Data: []byte("a")})
+	cancelled := b.BroadcastAfter(time.Hour, event.Event{Data: []byte("b")})
+
+	<-published.Done()
+	assert.NoError(t, published.Err())
+	assert.Len(t, b.Published(), 1)
+
+	// Closing the broker should cancel the remaining event.
+	b.Close()
+
+	<-cancelled.Done()
+	assert.Equal(t, broker.ErrCancelled, cancelled.Err())
+}
+
+func TestBroker_SubscribeLocal(t *testing.T) {
+	b := ssetest.NewBroker()
+	defer b.Close()
+
+	events, cancel := b.SubscribeLocal("news")
+
+	assert.NoError(t, b.BroadcastTopic("sport", []byte("goal")))
+	assert.NoError(t, b.BroadcastTopic("news", []byte("headline")))
+
+	select {
+	case e := <-events:
+		assert.Equal(t, "headline", string(e.Data))
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	cancel()
+
+	_, open := <-events
+	assert.False(t, open)
+	assert.Equal(t, 0, b.Stats().Clients)
+}
+
+func TestBroker_BroadcastSummary(t *testing.T) {
+	b := ssetest.NewBroker()
+	defer b.Close()
+
+	admin := ssetest.NewClient("admin", client.WithMetadata(map[string]string{"role": "admin"}))
+	defer admin.Close()
+
+	user := ssetest.NewClient("user", client.WithMetadata(map[string]string{"role": "user"}))
+	defer user.Close()
+
+	assert.NoError(t, b.Subscribe(admin.Client))
+	assert.NoError(t, b.Subscribe(user.Client))
+
+	summary, err := b.BroadcastSummary(event.Event{Audience: "role=admin", Data: []byte("hello")})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Delivered)
+	assert.Equal(t, 1, summary.Skipped)
+	assert.Equal(t, 0, summary.Failed)
+}
+
+func TestBroker_BroadcastExcept(t *testing.T) {
+	b := ssetest.NewBroker()
+	defer b.Close()
+
+	sender := ssetest.NewClient("sender")
+	defer sender.Close()
+
+	receiver := ssetest.NewClient("receiver")
+	defer receiver.Close()
+
+	assert.NoError(t, b.Subscribe(sender.Client))
+	assert.NoError(t, b.Subscribe(receiver.Client))
+
+	summary, err := b.BroadcastSummary(event.Event{Data: []byte("hello"), Except: []string{"sender"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Delivered)
+	assert.Equal(t, 1, summary.Skipped)
+	assert.NoError(t, b.BroadcastExcept([]string{"sender"}, []byte("again")))
+
+	_, err = receiver.Wait(2, time.Second)
+	assert.NoError(t, err)
+	assert.Empty(t, sender.Events())
+}
+
+func TestBroker_BroadcastGroup(t *testing.T) {
+	b := ssetest.NewBroker()
+	defer b.Close()
+
+	member := ssetest.NewClient("member", client.WithGroups("team:1"))
+	defer member.Close()
+
+	other := ssetest.NewClient("other")
+	defer other.Close()
+
+	assert.NoError(t, b.Subscribe(member.Client))
+	assert.NoError(t, b.Subscribe(other.Client))
+	assert.NoError(t, b.BroadcastGroup("team:1", []byte("hello")))
+
+	_, err := member.Wait(1, time.Second)
+	assert.NoError(t, err)
+	assert.Empty(t, other.Events())
+}
+
+func TestBroker_Stream(t *testing.T) {
+	b := ssetest.NewBroker()
+	defer b.Close()
+
+	c := ssetest.NewClient("test")
+	defer c.Close()
+
+	assert.NoError(t, b.Subscribe(c.Client))
+	assert.NoError(t, b.Stream("notifications").Broadcast([]byte("hello")))
+	assert.NoError(t, b.Stream("chat").BroadcastTo("test", []byte("hi")))
+
+	events, err := c.Wait(2, time.Second)
+	assert.NoError(t, err)
+
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "notifications", events[0].Stream)
+		assert.Equal(t, "chat", events[1].Stream)
+	}
+
+	assert.Equal(t, "chat", b.Published()[1].Event.Stream)
+}
+
+func TestBroker_Reconnect(t *testing.T) {
+	b := ssetest.NewBroker()
+	defer b.Close()
+
+	c := ssetest.NewClient("test")
+	defer c.Close()
+
+	assert.NoError(t, b.Subscribe(c.Client))
+	assert.NoError(t, b.Reconnect(broker.ReconnectHint{Delay: time.Second}, "test"))
+	assert.Error(t, b.Reconnect(broker.ReconnectHint{}, "unknown"))
+
+	events, err := c.Wait(1, time.Second)
+	assert.NoError(t, err)
+
+	hint, ok := broker.ParseReconnectHint(events[0])
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, hint.Delay)
+}
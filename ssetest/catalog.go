@@ -0,0 +1,53 @@
+package ssetest
+
+import (
+	"sort"
+
+	"github.com/davidsbond/sse/broker"
+)
+
+// RegisterTopic adds the topic to the mock broker's catalog, replacing any topic registered with the
+// same name.
+func (b *Broker) RegisterTopic(info broker.TopicInfo) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.catalog == nil {
+		b.catalog = make(map[string]broker.TopicInfo)
+	}
+
+	if info.Visibility == "" {
+		info.Visibility = broker.VisibilityPublic
+	}
+
+	b.catalog[info.Name] = info
+}
+
+// Catalog returns the topics registered with the mock broker sorted by name, with the number of
+// subscribed clients of each.
+func (b *Broker) Catalog() []broker.TopicInfo {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	out := make([]broker.TopicInfo, 0, len(b.catalog))
+
+	for _, info := range b.catalog {
+		info.Subscribers = 0
+
+		for _, c := range b.clients {
+			for _, topic := range c.Topics() {
+				if topic == info.Name {
+					info.Subscribers++
+				}
+			}
+		}
+
+		out = append(out, info)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
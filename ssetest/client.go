@@ -0,0 +1,95 @@
+package ssetest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Client type is a fake client that records every event it receives. It can be
+	// subscribed to the mock broker, or to a real broker.Broker, by passing the embedded
+	// client.Client to their Subscribe method.
+	Client struct {
+		*client.Client
+
+		mux      sync.Mutex
+		events   []event.Event
+		received chan struct{}
+		done     chan struct{}
+		once     sync.Once
+	}
+)
+
+// NewClient creates a new recording client with the given id & options. The client waits up
+// to one second for each event to be received & tolerates three sequential errors. Events are
+// taken from the client's queue in the background until the Close method is called.
+func NewClient(id string, opts ...client.Option) *Client {
+	c := &Client{
+		Client:   client.NewWithOptions(time.Second, 3, id, opts...),
+		received: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	go c.record()
+
+	return c
+}
+
+// Events returns the events received by the client, in the order they were received.
+func (c *Client) Events() []event.Event {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return append([]event.Event(nil), c.events...)
+}
+
+// Wait blocks until the client has received at least 'n' events & returns them. If the
+// timeout is reached first, the events received so far are returned with an error.
+func (c *Client) Wait(n int, timeout time.Duration) ([]event.Event, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		if events := c.Events(); len(events) >= n {
+			return events, nil
+		}
+
+		select {
+		case <-c.received:
+			continue
+		case <-timer.C:
+			events := c.Events()
+
+			return events, fmt.Errorf("received %v of %v events before timeout", len(events), n)
+		}
+	}
+}
+
+// Close stops the client from taking events from its queue.
+func (c *Client) Close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+func (c *Client) record() {
+	for {
+		select {
+		case <-c.Ready():
+			for e, ok := c.Next(); ok; e, ok = c.Next() {
+				c.mux.Lock()
+				c.events = append(c.events, e)
+				c.mux.Unlock()
+			}
+
+			select {
+			case c.received <- struct{}{}:
+			default:
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
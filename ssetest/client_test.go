@@ -0,0 +1,46 @@
+package ssetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/client"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Wait(t *testing.T) {
+	tt := []struct {
+		Broadcasts  int
+		Wait        int
+		ExpectError bool
+	}{
+		{Broadcasts: 2, Wait: 2},
+		{Broadcasts: 1, Wait: 2, ExpectError: true},
+	}
+
+	for _, tc := range tt {
+		// Use a real broker to ensure the client can subscribe to it
+		// without a HTTP connection.
+		b := broker.New(time.Second, 3, nil)
+		c := ssetest.NewClient("test", client.WithTopics("topic"))
+
+		assert.NoError(t, b.Subscribe(c.Client))
+
+		for i := 0; i < tc.Broadcasts; i++ {
+			assert.NoError(t, b.BroadcastTopic("topic", []byte("hello")))
+		}
+
+		events, err := c.Wait(tc.Wait, time.Second)
+
+		assert.Len(t, events, tc.Broadcasts)
+		assert.Equal(t, tc.ExpectError, err != nil)
+
+		b.Unsubscribe(c.Client)
+		assert.Equal(t, 0, b.Stats().Clients)
+
+		c.Close()
+		b.Close()
+	}
+}
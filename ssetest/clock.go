@@ -0,0 +1,225 @@
+package ssetest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/clock"
+)
+
+type (
+	// The Clock type is an implementation of the clock.Clock interface whose time only changes
+	// when it is advanced, so that tests of timeouts, heartbeats & scheduled events run without
+	// sleeping. Timers & tickers fire in the order they are due as the clock is advanced past
+	// them, including timers created with a duration of zero or less, which fire on the next
+	// call to Advance.
+	Clock struct {
+		mux    sync.Mutex
+		now    time.Time
+		timers []*fakeTimer
+		seq    int
+	}
+
+	// The fakeTimer type is a timer or ticker created by the fake clock.
+	fakeTimer struct {
+		clock  *Clock
+		when   time.Time
+		period time.Duration
+		seq    int
+		c      chan time.Time
+		fn     func()
+	}
+
+	// The fakeTicker type is a ticker created by the fake clock.
+	fakeTicker struct {
+		*fakeTimer
+	}
+)
+
+// NewClock creates a new instance of the Clock type, set to the given time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.now
+}
+
+// NewTimer returns a timer that fires once the clock has been advanced by 'd'.
+func (c *Clock) NewTimer(d time.Duration) clock.Timer {
+	return c.start(d, 0, nil)
+}
+
+// NewTicker returns a ticker that fires each time the clock has been advanced by 'd'.
+func (c *Clock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	return fakeTicker{c.start(d, d, nil)}
+}
+
+// AfterFunc calls 'fn' once the clock has been advanced by 'd'. The function is called by
+// the goroutine advancing the clock.
+func (c *Clock) AfterFunc(d time.Duration, fn func()) clock.Timer {
+	return c.start(d, 0, fn)
+}
+
+// Timers returns the number of timers & tickers that have not yet fired or been stopped. Tests
+// can use this to wait until the code under test is waiting on the clock before advancing it.
+func (c *Clock) Timers() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return len(c.timers)
+}
+
+// WaitForTimers waits until at least 'n' timers & tickers are active, returning false if this
+// does not happen within 'timeout' of real time.
+func (c *Clock) WaitForTimers(n int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+
+	for c.Timers() < n {
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	return true
+}
+
+// Advance moves the clock forwards by 'd', firing each timer & ticker that becomes due in the
+// order they are due. The clock's time is set to when each one is due as it fires.
+func (c *Clock) Advance(d time.Duration) {
+	c.mux.Lock()
+	target := c.now.Add(d)
+	c.mux.Unlock()
+
+	c.Set(target)
+}
+
+// Set moves the clock forwards to the given time, in the same way as the Advance method. If the
+// time is before the clock's current time, only timers that are already due are fired.
+func (c *Clock) Set(target time.Time) {
+	for {
+		c.mux.Lock()
+		t := c.next(target)
+
+		if t == nil {
+			if target.After(c.now) {
+				c.now = target
+			}
+
+			c.mux.Unlock()
+			return
+		}
+
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+
+		now := c.now
+
+		// Tickers are rescheduled, timers are removed once they fire.
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+		} else {
+			c.remove(t)
+		}
+
+		c.mux.Unlock()
+
+		if t.fn != nil {
+			t.fn()
+			continue
+		}
+
+		// As with the time package, ticks are dropped if the receiver is not keeping up.
+		select {
+		case t.c <- now:
+		default:
+		}
+	}
+}
+
+func (c *Clock) start(d, period time.Duration, fn func()) *fakeTimer {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	t := &fakeTimer{clock: c, period: period, fn: fn, c: make(chan time.Time, 1)}
+	c.schedule(t, d)
+
+	return t
+}
+
+// schedule adds the timer to the clock, due once 'd' has elapsed. It must be called while
+// holding the clock's lock.
+func (c *Clock) schedule(t *fakeTimer, d time.Duration) {
+	c.seq++
+	t.when = c.now.Add(d)
+	t.seq = c.seq
+	c.timers = append(c.timers, t)
+}
+
+// next returns the timer that is due first, at or before 'target'. Timers due at the same time
+// fire in the order they were started. It must be called while holding the clock's lock.
+func (c *Clock) next(target time.Time) *fakeTimer {
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		if c.timers[i].when.Equal(c.timers[j].when) {
+			return c.timers[i].seq < c.timers[j].seq
+		}
+
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+
+	if len(c.timers) == 0 || c.timers[0].when.After(target) {
+		return nil
+	}
+
+	return c.timers[0]
+}
+
+// remove removes the timer from the clock, returning false if it was not active. It must be
+// called while holding the clock's lock.
+func (c *Clock) remove(t *fakeTimer) bool {
+	for i, active := range c.timers {
+		if active == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mux.Lock()
+	defer t.clock.mux.Unlock()
+
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mux.Lock()
+	defer t.clock.mux.Unlock()
+
+	active := t.clock.remove(t)
+	t.clock.schedule(t, d)
+
+	return active
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
@@ -0,0 +1,80 @@
+package ssetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock_Advance(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := ssetest.NewClock(start)
+
+	var fired []string
+
+	clk.AfterFunc(time.Second*2, func() { fired = append(fired, "second") })
+	clk.AfterFunc(time.Second, func() { fired = append(fired, "first") })
+	stopped := clk.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	timer := clk.NewTimer(time.Second * 3)
+	ticker := clk.NewTicker(time.Second)
+
+	assert.Equal(t, 5, clk.Timers())
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	clk.Advance(time.Millisecond * 1500)
+	assert.Equal(t, []string{"first"}, fired)
+	assert.Equal(t, start.Add(time.Millisecond*1500), clk.Now())
+	assert.Equal(t, start.Add(time.Second), <-ticker.C())
+
+	clk.Advance(time.Second * 2)
+	assert.Equal(t, []string{"first", "second"}, fired)
+	assert.Equal(t, start.Add(time.Second*3), <-timer.C())
+
+	// Ticks are dropped for receivers that are not keeping up.
+	assert.Equal(t, start.Add(time.Second*2), <-ticker.C())
+
+	select {
+	case <-ticker.C():
+		t.Fatal("expected ticks to be dropped")
+	default:
+	}
+
+	ticker.Stop()
+	assert.Equal(t, 0, clk.Timers())
+
+	// Resetting a timer that has fired schedules it again.
+	assert.False(t, timer.Reset(time.Second))
+	clk.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Millisecond*4500), <-timer.C())
+}
+
+func TestClock_WaitForTimers(t *testing.T) {
+	clk := ssetest.NewClock(time.Now())
+
+	assert.False(t, clk.WaitForTimers(1, time.Millisecond*10))
+
+	go clk.NewTimer(time.Second)
+
+	assert.True(t, clk.WaitForTimers(1, time.Second))
+}
+
+func TestBroker_SetClock(t *testing.T) {
+	clk := ssetest.NewClock(time.Now())
+	brk := ssetest.NewBroker()
+	brk.SetClock(clk)
+	defer brk.Close()
+
+	scheduled := brk.BroadcastAfter(time.Minute, event.Event{Data: []byte("scheduled")})
+
+	clk.Advance(time.Second * 59)
+	assert.Empty(t, brk.Published())
+
+	clk.Advance(time.Second)
+	<-scheduled.Done()
+	assert.NoError(t, scheduled.Err())
+	assert.Len(t, brk.Published(), 1)
+}
@@ -0,0 +1,73 @@
+package ssetest
+
+import (
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The deadline type publishes events to the mock broker, recording each with its Deadline field
+	// set.
+	deadline struct {
+		broker  *Broker
+		timeout time.Duration
+	}
+)
+
+// Timeout returns a Publisher that publishes events with a deadline the given timeout from now,
+// measured using the broker's clock, see the broker.Broker's Timeout method.
+func (b *Broker) Timeout(d time.Duration) broker.Publisher {
+	return &deadline{broker: b, timeout: d}
+}
+
+// Broadcast publishes the data to all subscribed clients with the deadline.
+func (d *deadline) Broadcast(data []byte) error {
+	return d.BroadcastEvent(event.Event{Data: data})
+}
+
+// BroadcastTo publishes the data to the client with the given id with the deadline.
+func (d *deadline) BroadcastTo(id string, data []byte) error {
+	return d.broker.sendTo(id, event.Event{Data: data, Deadline: d.deadline()})
+}
+
+// BroadcastTopic publishes the data to the subscribers of the topic with the deadline.
+func (d *deadline) BroadcastTopic(topic string, data []byte) error {
+	return d.BroadcastEvent(event.Event{Topic: topic, Data: data})
+}
+
+// BroadcastExcept publishes the data to all subscribed clients other than those with the given ids
+// with the deadline.
+func (d *deadline) BroadcastExcept(excludeIDs []string, data []byte) error {
+	return d.BroadcastEvent(event.Event{Except: excludeIDs, Data: data})
+}
+
+// BroadcastGroup publishes the data to the members of the group with the deadline.
+func (d *deadline) BroadcastGroup(group string, data []byte) error {
+	return d.BroadcastEvent(event.Event{Group: group, Data: data})
+}
+
+// BroadcastEvent publishes the event, replacing its Deadline field.
+func (d *deadline) BroadcastEvent(e event.Event) error {
+	e.Deadline = d.deadline()
+	return d.broker.BroadcastEvent(e)
+}
+
+// BroadcastWithin publishes the event, replacing its Deadline field. The budget is not used by the
+// mock broker.
+func (d *deadline) BroadcastWithin(e event.Event, budget broker.ErrorBudget) error {
+	e.Deadline = d.deadline()
+	return d.broker.BroadcastWithin(e, budget)
+}
+
+// BroadcastSummary publishes the event, replacing its Deadline field, & returns a summary of its
+// delivery.
+func (d *deadline) BroadcastSummary(e event.Event) (broker.Summary, error) {
+	e.Deadline = d.deadline()
+	return d.broker.BroadcastSummary(e)
+}
+
+func (d *deadline) deadline() time.Time {
+	return d.broker.currentClock().Now().Add(d.timeout)
+}
@@ -0,0 +1,38 @@
+package ssetest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+)
+
+// HistoryHandler is an HTTP handler that returns the events broadcast to the broker as a JSON
+// array. Events sent to individual clients are not included. If the 'since' query parameter is
+// the identifier of a published event, only events published after it are returned. Events are
+// filtered by the requested topics in the same way as the broker.Broker's HistoryHandler, see the
+// broker.ParseTopics function.
+func (b *Broker) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	topics, _ := broker.ParseTopics(r)
+	out := make([]event.Event, 0)
+
+	for _, p := range b.Published() {
+		if p.To != "" || !p.Event.Matches(topics) {
+			continue
+		}
+
+		// The events published after the given event are returned, so
+		// discard everything up to & including it.
+		if since := query.Get("since"); since != "" && p.Event.ID == since {
+			out = out[:0]
+			continue
+		}
+
+		out = append(out, p.Event)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
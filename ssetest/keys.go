@@ -0,0 +1,89 @@
+package ssetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/store"
+)
+
+// CreateKey records a publisher key with the topics & limits of the given key, identified as 'key-1',
+// 'key-2' & so on in the order keys are created, so that tests can predict them. Keys are not checked
+// by the mock broker's EventHandler.
+func (b *Broker) CreateKey(key store.Key) (store.Key, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.nextKey++
+	key.ID = fmt.Sprintf("key-%v", b.nextKey)
+	b.keys[key.ID] = key
+
+	return key, nil
+}
+
+// RevokeKey removes the publisher key with the given identifier, returning broker.ErrUnknownKey if it
+// does not exist.
+func (b *Broker) RevokeKey(id string) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if _, ok := b.keys[id]; !ok {
+		return broker.ErrUnknownKey
+	}
+
+	delete(b.keys, id)
+
+	return nil
+}
+
+// Keys returns the publisher keys that have been created & not revoked, in the order they were
+// created.
+func (b *Broker) Keys() []store.Key {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	out := make([]store.Key, 0, len(b.keys))
+
+	for _, key := range b.keys {
+		out = append(out, key)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return len(out[i].ID) < len(out[j].ID) || (len(out[i].ID) == len(out[j].ID) && out[i].ID < out[j].ID)
+	})
+
+	return out
+}
+
+// KeyHandler is an HTTP handler that creates & revokes publisher keys in the same way as the
+// broker.Broker's KeyHandler.
+func (b *Broker) KeyHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var key store.Key
+
+		if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		key, _ = b.CreateKey(key)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(key)
+	case http.MethodDelete:
+		if err := b.RevokeKey(r.URL.Query().Get("key")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", http.MethodPost+", "+http.MethodDelete)
+		http.Error(w, fmt.Sprintf("method %v is not allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
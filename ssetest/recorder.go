@@ -0,0 +1,215 @@
+package ssetest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/protocol"
+)
+
+type (
+	// The StreamRecorder type is an http.ResponseWriter that records an event stream written
+	// by a handler. It implements the http.Flusher & http.CloseNotifier interfaces required
+	// by the broker's ClientHandler, and provides requests whose context is cancelled when
+	// the recorder is closed, simulating the client disconnecting. Only data that has been
+	// flushed is considered to have been received by the client.
+	StreamRecorder struct {
+		mux     sync.Mutex
+		header  http.Header
+		sent    http.Header
+		code    int
+		data    bytes.Buffer
+		flushed int
+		flushes int
+		written chan struct{}
+		close   chan bool
+		ctx     context.Context
+		cancel  context.CancelFunc
+		once    sync.Once
+	}
+)
+
+// NewStreamRecorder creates a new instance of the StreamRecorder type.
+func NewStreamRecorder() *StreamRecorder {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &StreamRecorder{
+		header:  http.Header{},
+		written: make(chan struct{}, 1),
+		close:   make(chan bool, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// NewRequest creates a request for the given method & target that is cancelled when the
+// recorder is closed. See httptest.NewRequest for details of the parameters.
+func (sr *StreamRecorder) NewRequest(method, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body).WithContext(sr.ctx)
+}
+
+// Header returns the response headers for the handler to modify. Use WrittenHeader to inspect
+// them from another goroutine.
+func (sr *StreamRecorder) Header() http.Header {
+	return sr.header
+}
+
+// WrittenHeader returns a copy of the response headers as they were when the response was first
+// written or flushed, or nil if nothing has been written. It is safe to call while the handler
+// is running.
+func (sr *StreamRecorder) WrittenHeader() http.Header {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+
+	return sr.sent.Clone()
+}
+
+// Write records the given data.
+func (sr *StreamRecorder) Write(data []byte) (int, error) {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+
+	if sr.code == 0 {
+		sr.code = http.StatusOK
+	}
+
+	sr.writeHeader()
+	return sr.data.Write(data)
+}
+
+// WriteHeader records the response status code.
+func (sr *StreamRecorder) WriteHeader(code int) {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+
+	if sr.code == 0 {
+		sr.code = code
+	}
+
+	sr.writeHeader()
+}
+
+// Flush marks all data written so far as received by the client.
+func (sr *StreamRecorder) Flush() {
+	sr.mux.Lock()
+	sr.writeHeader()
+	sr.flushed = sr.data.Len()
+	sr.flushes++
+	sr.mux.Unlock()
+
+	select {
+	case sr.written <- struct{}{}:
+	default:
+	}
+}
+
+// CloseNotify returns a channel that receives a value when the recorder is closed.
+func (sr *StreamRecorder) CloseNotify() <-chan bool {
+	return sr.close
+}
+
+// Close simulates the client disconnecting by cancelling the context of the recorder's
+// requests & notifying the handler.
+func (sr *StreamRecorder) Close() {
+	sr.once.Do(func() {
+		sr.cancel()
+		sr.close <- true
+	})
+}
+
+// Code returns the response status code, or zero if nothing has been written.
+func (sr *StreamRecorder) Code() int {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+
+	return sr.code
+}
+
+// Flushes returns the number of times the stream has been flushed.
+func (sr *StreamRecorder) Flushes() int {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+
+	return sr.flushes
+}
+
+// Body returns the data that has been flushed to the client.
+func (sr *StreamRecorder) Body() string {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+
+	return string(sr.data.Bytes()[:sr.flushed])
+}
+
+// Events decodes the events that have been flushed to the client, in the same way as a
+// browser would. See the protocol.Decoder type for details.
+func (sr *StreamRecorder) Events() ([]event.Event, error) {
+	events, _, err := sr.decode()
+	return events, err
+}
+
+// Retry returns the last reconnection time that has been flushed to the client, or zero if
+// none has been sent.
+func (sr *StreamRecorder) Retry() time.Duration {
+	_, retry, _ := sr.decode()
+	return retry
+}
+
+// WaitForEvents blocks until at least 'n' events have been flushed to the client & returns
+// them. If the timeout is reached first, the events received so far are returned with an
+// error.
+func (sr *StreamRecorder) WaitForEvents(n int, timeout time.Duration) ([]event.Event, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		events, err := sr.Events()
+
+		if err != nil || len(events) >= n {
+			return events, err
+		}
+
+		select {
+		case <-sr.written:
+			continue
+		case <-timer.C:
+			return events, fmt.Errorf("received %v of %v events before timeout", len(events), n)
+		}
+	}
+}
+
+// writeHeader records the response headers the first time the response is written. The caller
+// must hold the lock.
+func (sr *StreamRecorder) writeHeader() {
+	if sr.sent == nil {
+		sr.sent = sr.header.Clone()
+	}
+}
+
+// decode decodes the data that has been flushed to the client.
+func (sr *StreamRecorder) decode() ([]event.Event, time.Duration, error) {
+	var out []event.Event
+
+	dec := protocol.NewDecoder(strings.NewReader(sr.Body()))
+
+	for {
+		e, err := dec.Decode()
+
+		switch {
+		case err == io.EOF:
+			return out, dec.Retry(), nil
+		case err != nil:
+			return out, dec.Retry(), err
+		}
+
+		out = append(out, e)
+	}
+}
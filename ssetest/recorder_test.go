@@ -0,0 +1,84 @@
+package ssetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamRecorder_Events(t *testing.T) {
+	tt := []struct {
+		Written        string
+		Flush          bool
+		ExpectedEvents []event.Event
+		ExpectedRetry  time.Duration
+		ExpectError    bool
+	}{
+		{
+			Written:        "id: 1\nevent: greeting\ndata: hello\ndata: world\n\n",
+			Flush:          true,
+			ExpectedEvents: []event.Event{{ID: "1", Type: "greeting", Data: []byte("hello\nworld")}},
+		},
+		{
+			Written:       ": comment\n\nretry: 1000\n\ndata: partial\n",
+			Flush:         true,
+			ExpectedRetry: time.Second,
+		},
+		{
+			Written: "data: unflushed\n\n",
+		},
+		{
+			Written:     "encoding: base64\ndata: !\n\n",
+			Flush:       true,
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		w := ssetest.NewStreamRecorder()
+
+		w.Write([]byte(tc.Written))
+
+		if tc.Flush {
+			w.Flush()
+		}
+
+		events, err := w.Events()
+
+		assert.Equal(t, tc.ExpectError, err != nil)
+		assert.Equal(t, tc.ExpectedEvents, events)
+		assert.Equal(t, tc.ExpectedRetry, w.Retry())
+	}
+}
+
+func TestStreamRecorder_ClientHandler(t *testing.T) {
+	b := broker.New(time.Second, 3, nil)
+	w := ssetest.NewStreamRecorder()
+	r := w.NewRequest("GET", "/connect?id=test", nil)
+	done := make(chan struct{})
+
+	go func() {
+		b.ClientHandler(w, r)
+		close(done)
+	}()
+
+	<-time.Tick(time.Second)
+
+	assert.NoError(t, b.BroadcastEvent(event.Event{ID: "1", Type: "greeting", Data: []byte("hello")}))
+
+	events, err := w.WaitForEvents(1, time.Second)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []event.Event{{ID: "1", Type: "greeting", Data: []byte("hello")}}, events)
+	assert.Equal(t, "text/event-stream", w.WrittenHeader().Get("Content-Type"))
+
+	// Closing the recorder should disconnect the client.
+	w.Close()
+	<-done
+
+	assert.Equal(t, 0, b.Stats().Clients)
+}
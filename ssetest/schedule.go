@@ -0,0 +1,133 @@
+package ssetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The scheduled type is an event scheduled to be published to the mock broker.
+	scheduled struct {
+		timer clock.Timer
+
+		mux    sync.Mutex
+		firing bool
+		done   chan struct{}
+		err    error
+	}
+)
+
+// BroadcastAt schedules the event to be published at the given time.
+func (b *Broker) BroadcastAt(t time.Time, e event.Event) broker.Scheduled {
+	return b.BroadcastAfter(t.Sub(b.currentClock().Now()), e)
+}
+
+// BroadcastAfter schedules the event to be published once the given duration has elapsed.
+// Events that have not been published when the broker is closed are cancelled.
+func (b *Broker) BroadcastAfter(d time.Duration, e event.Event) broker.Scheduled {
+	s := &scheduled{done: make(chan struct{})}
+
+	s.timer = b.currentClock().AfterFunc(d, func() {
+		if s.claim() {
+			s.complete(b.BroadcastEvent(e))
+		}
+	})
+
+	b.mux.Lock()
+	b.scheduled = append(b.scheduled, s)
+	b.mux.Unlock()
+
+	return s
+}
+
+// Cancel prevents the event from being published.
+func (s *scheduled) Cancel() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.firing || s.finished() {
+		return false
+	}
+
+	s.timer.Stop()
+	s.err = broker.ErrCancelled
+	close(s.done)
+
+	return true
+}
+
+// Done returns a channel that is closed once the event has been published or cancelled.
+func (s *scheduled) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the outcome of the scheduled event.
+func (s *scheduled) Err() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.err
+}
+
+func (s *scheduled) claim() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.finished() {
+		return false
+	}
+
+	s.firing = true
+
+	return true
+}
+
+func (s *scheduled) complete(err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.err = err
+	close(s.done)
+}
+
+func (s *scheduled) finished() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Every calls 'fn' once per interval & publishes the event it returns, until the returned
+// function is called or the broker is closed.
+func (b *Broker) Every(interval time.Duration, fn broker.EventSource) func() {
+	stop := make(chan struct{})
+	once := &sync.Once{}
+
+	go func() {
+		ticker := b.currentClock().NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				if e, err := fn(); err == nil {
+					b.BroadcastEvent(e)
+				}
+			case <-stop:
+				return
+			case <-b.closed:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(stop) })
+	}
+}
@@ -0,0 +1,67 @@
+package ssetest
+
+import (
+	"github.com/davidsbond/sse/broker"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The stream type publishes events to one of the mock broker's logical streams, recording
+	// each with its Stream field set.
+	stream struct {
+		broker *Broker
+		name   string
+	}
+)
+
+// Stream returns a Publisher that publishes events to the logical stream with the given name,
+// see the broker.Broker's Stream method.
+func (b *Broker) Stream(name string) broker.Publisher {
+	return &stream{broker: b, name: name}
+}
+
+// Broadcast publishes the data to all subscribed clients as an event of the stream.
+func (s *stream) Broadcast(data []byte) error {
+	return s.BroadcastEvent(event.Event{Data: data})
+}
+
+// BroadcastTo publishes the data to the client with the given id as an event of the stream.
+func (s *stream) BroadcastTo(id string, data []byte) error {
+	return s.broker.sendTo(id, event.Event{Stream: s.name, Data: data})
+}
+
+// BroadcastTopic publishes the data to the subscribers of the topic as an event of the stream.
+func (s *stream) BroadcastTopic(topic string, data []byte) error {
+	return s.BroadcastEvent(event.Event{Topic: topic, Data: data})
+}
+
+// BroadcastExcept publishes the data to all subscribed clients other than those with the given ids
+// as an event of the stream.
+func (s *stream) BroadcastExcept(excludeIDs []string, data []byte) error {
+	return s.BroadcastEvent(event.Event{Except: excludeIDs, Data: data})
+}
+
+// BroadcastGroup publishes the data to the members of the group as an event of the stream.
+func (s *stream) BroadcastGroup(group string, data []byte) error {
+	return s.BroadcastEvent(event.Event{Group: group, Data: data})
+}
+
+// BroadcastEvent publishes the event to the stream, replacing its Stream field.
+func (s *stream) BroadcastEvent(e event.Event) error {
+	e.Stream = s.name
+	return s.broker.BroadcastEvent(e)
+}
+
+// BroadcastWithin publishes the event to the stream, replacing its Stream field. The budget is not
+// used by the mock broker.
+func (s *stream) BroadcastWithin(e event.Event, budget broker.ErrorBudget) error {
+	e.Stream = s.name
+	return s.broker.BroadcastWithin(e, budget)
+}
+
+// BroadcastSummary publishes the event to the stream, replacing its Stream field, & returns a
+// summary of its delivery.
+func (s *stream) BroadcastSummary(e event.Event) (broker.Summary, error) {
+	e.Stream = s.name
+	return s.broker.BroadcastSummary(e)
+}
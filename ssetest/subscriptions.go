@@ -0,0 +1,48 @@
+package ssetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/davidsbond/sse/broker"
+)
+
+// UpdateSubscriptions changes the topics the subscribed client with the given id is subscribed
+// to, returning its new topics.
+func (b *Broker) UpdateSubscriptions(id string, change broker.SubscriptionChange) ([]string, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	c, ok := b.clients[id]
+
+	if !ok {
+		return nil, fmt.Errorf("no client with id %v exists", id)
+	}
+
+	topics := change.Apply(c.Topics())
+	c.SetTopics(topics...)
+
+	return topics, nil
+}
+
+// SubscriptionHandler is an HTTP handler that changes the topics of the client identified by
+// the 'id' query parameter, in the same way as the broker.Broker's SubscriptionHandler.
+func (b *Broker) SubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	var change broker.SubscriptionChange
+
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	topics, err := b.UpdateSubscriptions(r.URL.Query().Get("id"), change)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(broker.Subscriptions{Topics: topics})
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"time"
+
+	"github.com/davidsbond/sse/event"
+)
+
+// Compact returns the events without those that have a key, were broadcast before the given
+// time & have been superseded by a later event with the same key. This allows a client that was
+// disconnected for a long time to be replayed the current state of each key, rather than every
+// update made to it. Events broadcast after the given time are kept regardless, so clients that
+// were disconnected briefly still receive each update. The order of the events is preserved & the
+// given slice is not modified.
+func Compact(events []event.Event, before time.Time) []event.Event {
+	kept, _ := compact(events, before)
+
+	return kept
+}
+
+// compact splits the events into those kept & those discarded by compaction, see the Compact
+// function.
+func compact(events []event.Event, before time.Time) ([]event.Event, []event.Event) {
+	var (
+		latest    = make(map[string]bool)
+		discarded = make([]bool, len(events))
+		n         int
+	)
+
+	// Walk backwards so that the latest event for each key is seen first.
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+
+		if e.Key == "" {
+			continue
+		}
+
+		if latest[e.Key] && e.Timestamp.Before(before) {
+			discarded[i] = true
+			n++
+		}
+
+		latest[e.Key] = true
+	}
+
+	if n == 0 {
+		return events, nil
+	}
+
+	kept := make([]event.Event, 0, len(events)-n)
+	dropped := make([]event.Event, 0, n)
+
+	for i, e := range events {
+		if discarded[i] {
+			dropped = append(dropped, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+
+	return kept, dropped
+}
@@ -0,0 +1,352 @@
+// Package store contains types for persisting broadcast events, so that they can be
+// replayed to clients that reconnect to the broker.
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/davidsbond/sse/clock"
+	"github.com/davidsbond/sse/event"
+)
+
+type (
+	// The Store interface describes a persistence mechanism for events that have been
+	// broadcast by the broker.
+	Store interface {
+		// Append persists the event. Events are appended in the order they are broadcast.
+		Append(e event.Event) error
+
+		// Since returns the events that were appended after the event with the given id,
+		// oldest first. If the store no longer contains the event, all events held by the
+		// store are returned.
+		Since(id string) ([]event.Event, error)
+	}
+
+	// The OffsetStore interface describes a Store that also persists the identifier of the
+	// last event delivered to each client. When a client with a fixed identifier reconnects,
+	// possibly to a different broker process sharing the same store, it can resume exactly
+	// where it left off.
+	OffsetStore interface {
+		Store
+
+		// SetOffset records the identifier of the last event delivered to the client.
+		SetOffset(clientID, eventID string) error
+
+		// Offset returns the identifier of the last event delivered to the client. If no
+		// offset has been recorded, a blank string is returned.
+		Offset(clientID string) (string, error)
+	}
+
+	// The SubscriptionStore interface describes a Store that also persists the topics & groups each
+	// client is subscribed to. When a client reconnects, possibly to a broker that has restarted,
+	// it can be subscribed to them again without requesting them.
+	SubscriptionStore interface {
+		Store
+
+		// SetSubscription records the subscription of the client identified by the key, such as
+		// its identifier or session.
+		SetSubscription(key string, sub Subscription) error
+
+		// Subscription returns the subscription of the client identified by the key. If none has
+		// been recorded, the zero Subscription is returned.
+		Subscription(key string) (Subscription, error)
+	}
+
+	// The Subscription type describes the topics & groups a client is subscribed to.
+	Subscription struct {
+		Topics []string // The topics the client is subscribed to.
+		Groups []string // The groups the client is a member of.
+	}
+
+	// The KeyStore interface describes a Store that also persists the API keys publishers use to
+	// publish events, so that keys survive restarts & are shared by brokers using the same store.
+	KeyStore interface {
+		Store
+
+		// SetKey records the key, replacing any key with the same identifier.
+		SetKey(key Key) error
+
+		// Key returns the key with the given identifier. If none has been recorded, the zero Key is
+		// returned.
+		Key(id string) (Key, error)
+
+		// DeleteKey removes the key with the given identifier, if one has been recorded.
+		DeleteKey(id string) error
+	}
+
+	// The Key type describes an API key used to publish events & the limits applied to it.
+	Key struct {
+		ID                 string   `json:"key"`                             // The secret presented by the publisher.
+		Topics             []string `json:"topics,omitempty"`                // The topics events can be published to. If empty, any topic is allowed.
+		MaxEventsPerSecond float64  `json:"max_events_per_second,omitempty"` // The rate events can be published at. Zero means no limit.
+		Burst              int      `json:"burst,omitempty"`                 // The number of events that can be published at once above the rate.
+		MaxPayload         int      `json:"max_payload,omitempty"`           // The size of the largest event that can be published, in bytes. Zero means no limit.
+	}
+
+	// The TrimNotifier interface describes a Store that discards old events to make space for
+	// new ones, and reports each event it discards.
+	TrimNotifier interface {
+		// OnTrim registers a function that is called with each event the store discards. The
+		// function must not block.
+		OnTrim(fn func(e event.Event))
+	}
+
+	// The SizedStore interface describes a Store that holds events in memory, so that the broker can
+	// account for the memory they use & discard them when its memory budget is exceeded.
+	SizedStore interface {
+		Store
+
+		// Size returns the number of bytes of event data held by the store.
+		Size() int
+
+		// Shrink discards the oldest events until at least 'bytes' bytes of event data have been
+		// freed or the store is empty, returning the number of bytes freed. Discarded events are
+		// reported in the same way as events trimmed to make space for new ones.
+		Shrink(bytes int) int
+	}
+
+	// The ClockedStore interface describes a Store that uses the current time, such as to compact
+	// old events, so that the broker can give it the same clock it uses itself.
+	ClockedStore interface {
+		// SetClock sets the clock used to tell the current time.
+		SetClock(c clock.Clock)
+	}
+
+	memoryStore struct {
+		mux     sync.RWMutex
+		clock   clock.Clock
+		size    int
+		horizon time.Duration
+		events  []event.Event
+		offsets map[string]string
+		subs    map[string]Subscription
+		keys    map[string]Key
+		onTrim  []func(e event.Event)
+	}
+)
+
+// NewMemory creates a Store that holds the most recent events in memory. The 'size'
+// parameter determines how many events are held before the oldest are discarded. The
+// returned store also implements OffsetStore, so it can be shared between brokers in the
+// same process, SubscriptionStore, KeyStore, TrimNotifier, SizedStore and ClockedStore.
+func NewMemory(size int) Store {
+	return &memoryStore{
+		clock:   clock.Real(),
+		size:    size,
+		events:  make([]event.Event, 0, size),
+		offsets: make(map[string]string),
+		subs:    make(map[string]Subscription),
+		keys:    make(map[string]Key),
+	}
+}
+
+// NewCompactingMemory creates a Store that holds the most recent events in memory in the same way
+// as NewMemory, but also compacts events older than the horizon by their key, see the Compact
+// function. Superseded events are discarded before the oldest events when the store is full, so
+// the store holds the current state of each key for longer, and are never replayed. Discarded
+// events are reported to functions registered using OnTrim. If 'horizon' is zero, events are not
+// compacted.
+func NewCompactingMemory(size int, horizon time.Duration) Store {
+	return &memoryStore{
+		clock:   clock.Real(),
+		size:    size,
+		horizon: horizon,
+		events:  make([]event.Event, 0, size),
+		offsets: make(map[string]string),
+		subs:    make(map[string]Subscription),
+		keys:    make(map[string]Key),
+	}
+}
+
+func (s *memoryStore) Append(e event.Event) error {
+	s.mux.Lock()
+
+	if s.size <= 0 {
+		s.mux.Unlock()
+		return nil
+	}
+
+	var (
+		single  [1]event.Event
+		trimmed = single[:0]
+		full    = len(s.events) >= s.size
+	)
+
+	// Discard superseded events before any others, as they will never be replayed.
+	if full && s.horizon > 0 {
+		var compacted []event.Event
+
+		s.events, compacted = compact(s.events, s.clock.Now().Add(-s.horizon))
+		trimmed = append(trimmed, compacted...)
+		full = len(s.events) >= s.size
+	}
+
+	// Discard the oldest event if the store is full.
+	if full {
+		trimmed = append(trimmed, s.events[0])
+		copy(s.events, s.events[1:])
+		s.events = s.events[:len(s.events)-1]
+	}
+
+	s.events = append(s.events, e)
+	listeners := s.onTrim
+	s.mux.Unlock()
+
+	for _, e := range trimmed {
+		for _, fn := range listeners {
+			fn(e)
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStore) SetClock(c clock.Clock) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.clock = c
+}
+
+func (s *memoryStore) OnTrim(fn func(e event.Event)) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.onTrim = append(s.onTrim, fn)
+}
+
+func (s *memoryStore) Size() int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	var n int
+
+	for _, e := range s.events {
+		n += len(e.Data)
+	}
+
+	return n
+}
+
+func (s *memoryStore) Shrink(bytes int) int {
+	s.mux.Lock()
+
+	var (
+		freed int
+		i     int
+	)
+
+	for ; i < len(s.events) && freed < bytes; i++ {
+		freed += len(s.events[i].Data)
+	}
+
+	trimmed := make([]event.Event, i)
+	copy(trimmed, s.events[:i])
+
+	n := copy(s.events, s.events[i:])
+
+	for j := n; j < len(s.events); j++ {
+		s.events[j] = event.Event{}
+	}
+
+	s.events = s.events[:n]
+	listeners := s.onTrim
+	s.mux.Unlock()
+
+	for _, e := range trimmed {
+		for _, fn := range listeners {
+			fn(e)
+		}
+	}
+
+	return freed
+}
+
+func (s *memoryStore) Since(id string) ([]event.Event, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	start := 0
+
+	// Search backwards as reconnecting clients are most likely to have
+	// missed only the most recent events.
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if s.events[i].ID == id {
+			start = i + 1
+			break
+		}
+	}
+
+	out := make([]event.Event, len(s.events)-start)
+	copy(out, s.events[start:])
+
+	if s.horizon > 0 {
+		out = Compact(out, s.clock.Now().Add(-s.horizon))
+	}
+
+	return out, nil
+}
+
+func (s *memoryStore) SetOffset(clientID, eventID string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.offsets[clientID] = eventID
+
+	return nil
+}
+
+func (s *memoryStore) Offset(clientID string) (string, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.offsets[clientID], nil
+}
+
+func (s *memoryStore) SetSubscription(key string, sub Subscription) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	// Copy the subscription so callers can reuse their slices.
+	s.subs[key] = Subscription{
+		Topics: append([]string(nil), sub.Topics...),
+		Groups: append([]string(nil), sub.Groups...),
+	}
+
+	return nil
+}
+
+func (s *memoryStore) Subscription(key string) (Subscription, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.subs[key], nil
+}
+
+func (s *memoryStore) SetKey(key Key) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	// Copy the topics so callers can reuse their slices.
+	key.Topics = append([]string(nil), key.Topics...)
+	s.keys[key.ID] = key
+
+	return nil
+}
+
+func (s *memoryStore) Key(id string) (Key, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.keys[id], nil
+}
+
+func (s *memoryStore) DeleteKey(id string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.keys, id)
+
+	return nil
+}
@@ -0,0 +1,302 @@
+package store_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/davidsbond/sse/event"
+	"github.com/davidsbond/sse/ssetest"
+	"github.com/davidsbond/sse/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_Memory(t *testing.T) {
+	tt := []struct {
+		Size        int
+		Appended    []string
+		Since       string
+		ExpectedIDs []string
+	}{
+		{Size: 5, Appended: []string{"1", "2", "3"}, Since: "1", ExpectedIDs: []string{"2", "3"}},
+		{Size: 5, Appended: []string{"1", "2", "3"}, Since: "3", ExpectedIDs: []string{}},
+		{Size: 5, Appended: []string{"1", "2", "3"}, Since: "", ExpectedIDs: []string{"1", "2", "3"}},
+		{Size: 2, Appended: []string{"1", "2", "3"}, Since: "1", ExpectedIDs: []string{"2", "3"}},
+		{Size: 2, Appended: []string{"1", "2", "3", "4"}, Since: "3", ExpectedIDs: []string{"4"}},
+		{Size: 0, Appended: []string{"1", "2"}, Since: "", ExpectedIDs: []string{}},
+	}
+
+	for _, tc := range tt {
+		s := store.NewMemory(tc.Size)
+
+		for _, id := range tc.Appended {
+			assert.NoError(t, s.Append(event.Event{ID: id}))
+		}
+
+		events, err := s.Since(tc.Since)
+		assert.NoError(t, err)
+
+		ids := make([]string, len(events))
+
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+
+		assert.Equal(t, tc.ExpectedIDs, ids)
+	}
+}
+
+func TestStore_MemoryOffsets(t *testing.T) {
+	tt := []struct {
+		ClientID       string
+		Offsets        map[string]string
+		ExpectedOffset string
+	}{
+		{ClientID: "a", Offsets: map[string]string{"a": "1", "b": "2"}, ExpectedOffset: "1"},
+		{ClientID: "c", Offsets: map[string]string{"a": "1"}, ExpectedOffset: ""},
+	}
+
+	for _, tc := range tt {
+		s, ok := store.NewMemory(10).(store.OffsetStore)
+
+		if !assert.True(t, ok) {
+			continue
+		}
+
+		for clientID, eventID := range tc.Offsets {
+			assert.NoError(t, s.SetOffset(clientID, eventID))
+		}
+
+		offset, err := s.Offset(tc.ClientID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, tc.ExpectedOffset, offset)
+	}
+}
+
+func TestStore_MemorySubscriptions(t *testing.T) {
+	tt := []struct {
+		Key                  string
+		Subscriptions        map[string]store.Subscription
+		ExpectedSubscription store.Subscription
+	}{
+		{
+			Key:                  "a",
+			Subscriptions:        map[string]store.Subscription{"a": {Topics: []string{"news"}, Groups: []string{"team:1"}}},
+			ExpectedSubscription: store.Subscription{Topics: []string{"news"}, Groups: []string{"team:1"}},
+		},
+		{
+			Key:                  "b",
+			Subscriptions:        map[string]store.Subscription{"a": {Topics: []string{"news"}}},
+			ExpectedSubscription: store.Subscription{},
+		},
+	}
+
+	for _, tc := range tt {
+		s, ok := store.NewMemory(10).(store.SubscriptionStore)
+
+		if !assert.True(t, ok) {
+			continue
+		}
+
+		for key, sub := range tc.Subscriptions {
+			assert.NoError(t, s.SetSubscription(key, sub))
+		}
+
+		sub, err := s.Subscription(tc.Key)
+
+		assert.NoError(t, err)
+		assert.Equal(t, tc.ExpectedSubscription, sub)
+	}
+}
+
+func TestStore_MemoryKeys(t *testing.T) {
+	s, ok := store.NewMemory(10).(store.KeyStore)
+
+	if !assert.True(t, ok) {
+		return
+	}
+
+	key := store.Key{ID: "secret", Topics: []string{"news"}, MaxPayload: 1024}
+	assert.NoError(t, s.SetKey(key))
+
+	actual, err := s.Key("secret")
+	assert.NoError(t, err)
+	assert.Equal(t, key, actual)
+
+	assert.NoError(t, s.DeleteKey("secret"))
+
+	actual, err = s.Key("secret")
+	assert.NoError(t, err)
+	assert.Equal(t, store.Key{}, actual)
+}
+
+func TestStore_MemoryShrink(t *testing.T) {
+	s, ok := store.NewMemory(10).(store.SizedStore)
+
+	if !assert.True(t, ok) {
+		return
+	}
+
+	var trimmed []string
+
+	s.(store.TrimNotifier).OnTrim(func(e event.Event) {
+		trimmed = append(trimmed, e.ID)
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		assert.NoError(t, s.Append(event.Event{ID: id, Data: []byte("abcd")}))
+	}
+
+	assert.Equal(t, 12, s.Size())
+	assert.Equal(t, 8, s.Shrink(5))
+	assert.Equal(t, 4, s.Size())
+	assert.Equal(t, []string{"1", "2"}, trimmed)
+
+	events, err := s.Since("")
+	assert.NoError(t, err)
+	assert.Equal(t, []event.Event{{ID: "3", Data: []byte("abcd")}}, events)
+
+	assert.Equal(t, 4, s.Shrink(100))
+	assert.Equal(t, 0, s.Size())
+}
+
+func TestStore_MemoryOnTrim(t *testing.T) {
+	tt := []struct {
+		Size            int
+		Appended        []string
+		ExpectedTrimmed []string
+	}{
+		{Size: 2, Appended: []string{"1", "2", "3", "4"}, ExpectedTrimmed: []string{"1", "2"}},
+		{Size: 5, Appended: []string{"1", "2", "3"}, ExpectedTrimmed: nil},
+		{Size: 0, Appended: []string{"1", "2"}, ExpectedTrimmed: nil},
+	}
+
+	for _, tc := range tt {
+		s, ok := store.NewMemory(tc.Size).(store.TrimNotifier)
+
+		if !assert.True(t, ok) {
+			continue
+		}
+
+		var trimmed []string
+
+		s.OnTrim(func(e event.Event) {
+			trimmed = append(trimmed, e.ID)
+		})
+
+		for _, id := range tc.Appended {
+			assert.NoError(t, s.(store.Store).Append(event.Event{ID: id}))
+		}
+
+		assert.Equal(t, tc.ExpectedTrimmed, trimmed)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-time.Hour)
+
+	tt := []struct {
+		Events      []event.Event
+		ExpectedIDs []string
+	}{
+		{
+			Events: []event.Event{
+				{ID: "1", Key: "price:AAPL", Timestamp: old},
+				{ID: "2", Key: "price:MSFT", Timestamp: old},
+				{ID: "3", Key: "price:AAPL", Timestamp: old},
+				{ID: "4", Timestamp: old},
+			},
+			ExpectedIDs: []string{"2", "3", "4"},
+		},
+		{
+			Events: []event.Event{
+				{ID: "1", Key: "price:AAPL", Timestamp: old},
+				{ID: "2", Key: "price:AAPL", Timestamp: now},
+				{ID: "3", Key: "price:AAPL", Timestamp: now},
+			},
+			ExpectedIDs: []string{"2", "3"},
+		},
+		{
+			Events: []event.Event{
+				{ID: "1", Timestamp: old},
+				{ID: "2", Timestamp: old},
+			},
+			ExpectedIDs: []string{"1", "2"},
+		},
+	}
+
+	for _, tc := range tt {
+		events := store.Compact(tc.Events, now.Add(-time.Minute))
+		ids := make([]string, len(events))
+
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+
+		assert.Equal(t, tc.ExpectedIDs, ids)
+	}
+}
+
+func TestStore_CompactingMemory(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+
+	s := store.NewCompactingMemory(3, time.Minute)
+
+	var trimmed []string
+
+	s.(store.TrimNotifier).OnTrim(func(e event.Event) {
+		trimmed = append(trimmed, e.ID)
+	})
+
+	for i, key := range []string{"a", "b", "a", "a"} {
+		assert.NoError(t, s.Append(event.Event{ID: strconv.Itoa(i + 1), Key: key, Timestamp: old}))
+	}
+
+	// The superseded update to 'a' is discarded rather than the oldest event.
+	assert.Equal(t, []string{"1"}, trimmed)
+
+	events, err := s.Since("")
+	assert.NoError(t, err)
+
+	ids := make([]string, len(events))
+
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+
+	// Replays only include the latest update to each key.
+	assert.Equal(t, []string{"2", "4"}, ids)
+}
+
+func TestStore_CompactingMemoryClock(t *testing.T) {
+	clk := ssetest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	s := store.NewCompactingMemory(3, time.Minute)
+	s.(store.ClockedStore).SetClock(clk)
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, s.Append(event.Event{ID: strconv.Itoa(i + 1), Key: "a", Timestamp: clk.Now()}))
+	}
+
+	ids := func() []string {
+		events, err := s.Since("")
+		assert.NoError(t, err)
+
+		ids := make([]string, len(events))
+
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+
+		return ids
+	}
+
+	// Events are only compacted once they are older than the horizon on the store's clock.
+	assert.Equal(t, []string{"1", "2"}, ids())
+
+	clk.Advance(time.Hour)
+
+	assert.Equal(t, []string{"2"}, ids())
+}